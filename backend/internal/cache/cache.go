@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/redis"
+)
+
+// keyPrefix namespaces every key this app writes to a shared Redis
+const keyPrefix = "auratravel"
+
+// Cache stores byte values with a TTL
+type Cache interface {
+	// Get returns the value for key and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns the application cache: Redis when REDIS_HOST is set, falling
+// back to memory when Redis is unreachable, and memory only otherwise. Keys
+// are prefixed with the app name and CACHE_VERSION, so bumping the version
+// on deploy invalidates everything previously cached.
+func New(cfg *config.Config) Cache {
+	memory := NewMemoryCache()
+
+	var backend Cache = memory
+	if client := redis.NewFromConfig(cfg); client != nil {
+		if err := client.Ping(context.Background()).Err(); err != nil {
+			log.Printf("Warning: Redis unavailable, caching in memory until it recovers: %v", err)
+		} else {
+			log.Println("Redis cache connected")
+		}
+		backend = NewRedisCache(client, memory)
+	}
+
+	return NewNamespaced(backend, keyPrefix+":v"+cfg.CacheVersion)
+}
+
+// Key joins parts into a cache key, e.g. Key("weather", "Jaipur") gives
+// "weather:jaipur". Parts are lowercased and trimmed so equivalent inputs
+// share an entry.
+func Key(namespace string, parts ...string) string {
+	normalized := make([]string, 0, len(parts)+1)
+	normalized = append(normalized, namespace)
+	for _, part := range parts {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(part)))
+	}
+	return strings.Join(normalized, ":")
+}
+
+// HashKey builds a key from the hash of value's JSON encoding, for requests
+// with too many fields to spell out in the key
+func HashKey(namespace string, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return namespace + ":" + hex.EncodeToString(sum[:16])
+}
+
+// GetJSON decodes the cached value for key into out. It reports false on a
+// miss, a nil cache, or any cache or decoding error.
+func GetJSON(ctx context.Context, c Cache, key string, out interface{}) bool {
+	if c == nil || key == "" {
+		return false
+	}
+
+	data, ok, err := c.Get(ctx, key)
+	if err != nil {
+		log.Printf("Cache get failed for %s: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		log.Printf("Cache entry %s could not be decoded: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// SetJSON caches value's JSON encoding under key. Failures are logged and
+// otherwise ignored since the cache is an optimization.
+func SetJSON(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) {
+	if c == nil || key == "" {
+		return
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Cache entry %s could not be encoded: %v", key, err)
+		return
+	}
+	if err := c.Set(ctx, key, data, ttl); err != nil {
+		log.Printf("Cache set failed for %s: %v", key, err)
+	}
+}
+
+// namespaced prefixes every key
+type namespaced struct {
+	cache  Cache
+	prefix string
+}
+
+// NewNamespaced returns a Cache that stores keys under prefix
+func NewNamespaced(c Cache, prefix string) Cache {
+	return &namespaced{cache: c, prefix: prefix + ":"}
+}
+
+func (n *namespaced) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return n.cache.Get(ctx, n.prefix+key)
+}
+
+func (n *namespaced) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return n.cache.Set(ctx, n.prefix+key, value, ttl)
+}
+
+func (n *namespaced) Delete(ctx context.Context, key string) error {
+	return n.cache.Delete(ctx, n.prefix+key)
+}
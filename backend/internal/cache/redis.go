@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// redisRetryInterval is how long the cache stays on the in-memory fallback
+// after a Redis failure before trying Redis again
+const redisRetryInterval = 30 * time.Second
+
+// RedisCache stores entries in Redis. While Redis is failing, reads and
+// writes go to the fallback cache instead of returning errors.
+type RedisCache struct {
+	client   *goredis.Client
+	fallback Cache
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// NewRedisCache creates a Redis-backed cache degrading to fallback
+func NewRedisCache(client *goredis.Client, fallback Cache) *RedisCache {
+	return &RedisCache{client: client, fallback: fallback}
+}
+
+// Get implements Cache
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if r.down() {
+		return r.fallback.Get(ctx, key)
+	}
+
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		r.markDown(err)
+		return r.fallback.Get(ctx, key)
+	}
+	return value, true, nil
+}
+
+// Set implements Cache
+func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if r.down() {
+		return r.fallback.Set(ctx, key, value, ttl)
+	}
+
+	if err := r.client.Set(ctx, key, value, ttl).Err(); err != nil {
+		r.markDown(err)
+		return r.fallback.Set(ctx, key, value, ttl)
+	}
+	return nil
+}
+
+// Delete implements Cache. The key is removed from both stores so a stale
+// fallback entry can't resurface during an outage.
+func (r *RedisCache) Delete(ctx context.Context, key string) error {
+	r.fallback.Delete(ctx, key)
+	if r.down() {
+		return nil
+	}
+
+	if err := r.client.Del(ctx, key).Err(); err != nil {
+		r.markDown(err)
+	}
+	return nil
+}
+
+func (r *RedisCache) down() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Now().Before(r.downUntil)
+}
+
+func (r *RedisCache) markDown(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Now().Before(r.downUntil) {
+		return
+	}
+	r.downUntil = time.Now().Add(redisRetryInterval)
+	log.Printf("Redis cache error, using in-memory cache for %s: %v", redisRetryInterval, err)
+}
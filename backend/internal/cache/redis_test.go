@@ -0,0 +1,102 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"auratravel-backend/internal/config"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func TestRedisCacheUsesFallbackWhileRedisIsDown(t *testing.T) {
+	client := goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	c := NewRedisCache(client, NewMemoryCache())
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "weather:jaipur", []byte("sunny"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	value, ok, err := c.Get(ctx, "weather:jaipur")
+	if err != nil || !ok || string(value) != "sunny" {
+		t.Errorf("Get = %q, %v, %v; want the fallback entry", value, ok, err)
+	}
+	if !c.down() {
+		t.Error("cache not marked down after a Redis failure")
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	defer client.Close()
+	fallback := NewMemoryCache()
+	c := NewRedisCache(client, fallback)
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "weather:jaipur", []byte("sunny"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if stored, err := server.Get("weather:jaipur"); err != nil || stored != "sunny" {
+		t.Errorf("Redis holds %q, %v; want sunny", stored, err)
+	}
+	if _, ok, _ := fallback.Get(ctx, "weather:jaipur"); ok {
+		t.Error("entry written to the fallback while Redis is up")
+	}
+	value, ok, err := c.Get(ctx, "weather:jaipur")
+	if err != nil || !ok || string(value) != "sunny" {
+		t.Errorf("Get = %q, %v, %v; want sunny", value, ok, err)
+	}
+
+	if _, ok, err := c.Get(ctx, "weather:goa"); err != nil || ok {
+		t.Errorf("Get of a missing key = %v, %v; want a miss", ok, err)
+	}
+
+	if err := c.Delete(ctx, "weather:jaipur"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok, _ := c.Get(ctx, "weather:jaipur"); ok || server.Exists("weather:jaipur") {
+		t.Error("entry survived Delete")
+	}
+
+	// Entries expire with their TTL
+	if err := c.Set(ctx, "rates:inr", []byte("83.1"), time.Minute); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ttl := server.TTL("rates:inr"); ttl != time.Minute {
+		t.Errorf("TTL = %s, want 1m", ttl)
+	}
+	server.FastForward(time.Minute + time.Second)
+	if _, ok, _ := c.Get(ctx, "rates:inr"); ok {
+		t.Error("entry outlived its TTL")
+	}
+	if c.down() {
+		t.Error("cache marked down while Redis is up")
+	}
+}
+
+func TestNewVersionsKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	newCache := func(version string) Cache {
+		return New(&config.Config{RedisHost: server.Host(), RedisPort: server.Port(), CacheVersion: version})
+	}
+	ctx := context.Background()
+
+	v1 := newCache("1")
+	SetJSON(ctx, v1, Key("recommendations", "Jaipur"), []string{"Amber Fort"}, time.Hour)
+	if !server.Exists("auratravel:v1:recommendations:jaipur") {
+		t.Fatalf("keys in Redis = %v, want auratravel:v1:recommendations:jaipur", server.Keys())
+	}
+
+	var cached []string
+	if !GetJSON(ctx, newCache("1"), Key("recommendations", " JAIPUR "), &cached) || len(cached) != 1 {
+		t.Errorf("same version missed the entry: %v", cached)
+	}
+	// Bumping CACHE_VERSION leaves every earlier entry behind
+	if GetJSON(ctx, newCache("2"), Key("recommendations", "Jaipur"), &cached) {
+		t.Error("new version read an entry cached under the old one")
+	}
+}
@@ -1,10 +1,30 @@
 package config
 
 import (
+	"errors"
+	"log"
 	"os"
 	"strconv"
+
+	"github.com/joho/godotenv"
 )
 
+// A .env file is loaded as the package initializes, so packages that read
+// configuration at init see its values. It never overrides variables
+// already set in the environment.
+func init() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using environment variables")
+	}
+}
+
+// placeholderJWTSecrets are example secrets from old defaults and the docs;
+// tokens signed with them can be forged by anyone
+var placeholderJWTSecrets = map[string]bool{
+	"your-secret-key": true,
+	"your_jwt_secret": true,
+}
+
 type Config struct {
 	// Server Configuration
 	Environment string
@@ -14,6 +34,7 @@ type Config struct {
 	GoogleCloudProjectID         string
 	GoogleCloudRegion            string
 	GoogleApplicationCredentials string
+	BigQueryDataset              string
 
 	// Firebase Configuration
 	FirebaseProjectID               string
@@ -28,6 +49,29 @@ type Config struct {
 
 	// Gemini AI Configuration
 	GeminiAPIKey string
+	// GeminiModel is the model itineraries are generated with. The default
+	// returns schema-conforming JSON; gemini-pro and gemini-1.0 models
+	// don't, and their itineraries are parsed from free text.
+	GeminiModel string
+	// Gemini generation settings per use case: structured output like
+	// itineraries runs cooler than creative suggestions. The safety
+	// threshold, like BLOCK_MEDIUM_AND_ABOVE, applies to every harm category.
+	GeminiStructuredTemperature float64
+	GeminiCreativeTemperature   float64
+	GeminiGeneralTemperature    float64
+	GeminiTopP                  float64
+	GeminiMaxOutputTokens       int
+	GeminiSafetyThreshold       string
+	// AttractionSlotWeights overrides how well attraction categories suit
+	// each time of day, as JSON like {"indoor": {"morning": 0.5,
+	// "afternoon": 1, "evening": 0.2}}
+	AttractionSlotWeights string
+
+	// Vertex AI Configuration; project and location default to the Google
+	// Cloud ones
+	VertexAIProjectID string
+	VertexAILocation  string
+	VertexAIModel     string
 
 	// External APIs
 	GoogleMapsAPIKey string
@@ -40,6 +84,73 @@ type Config struct {
 	// Rate Limiting
 	RateLimitRequests int
 	RateLimitWindow   int
+	AIRateLimitRPS    float64
+	AIRateLimitBurst  int
+
+	// Redis Configuration
+	RedisHost     string
+	RedisPort     string
+	RedisPassword string
+	RedisDB       int
+
+	// Cache Configuration
+	CacheVersion string
+
+	// Tracing Configuration
+	OTLPEndpoint    string
+	OTelServiceName string
+
+	// Trip Planning
+	MaxTripDays int
+
+	// Replanning; alternatives less similar than this to an unavailable
+	// item aren't substituted for it
+	ReplanSimilarityThreshold float64
+	// MaxTripMonitors caps the trips monitored for replanning at once; 0
+	// means no limit
+	MaxTripMonitors int
+	// FlightStatusWebhookSecret verifies flight status pushed by airlines
+	// and aggregators; the webhook rejects everything while it's empty
+	FlightStatusWebhookSecret string
+
+	// AI generation; strategies (rag, gemini, mock) are tried in this
+	// comma-separated order until one succeeds
+	GenerationOrder string
+
+	// Delivery; offline bundles larger than this leave out maps
+	OfflineBundleMaxMB int
+
+	// Email delivery. SMTPTLSMode is starttls, implicit (port 465) or none
+	// for local relays; SMTPAuthMethod is plain, or xoauth2 to send
+	// SMTPOAuth2Token in place of the password. SMTPInsecureSkipVerify
+	// accepts self-signed certificates and is for development only.
+	SMTPHost               string
+	SMTPPort               int
+	SMTPUsername           string
+	SMTPPassword           string
+	SMTPFromEmail          string
+	SMTPTLSMode            string
+	SMTPAuthMethod         string
+	SMTPOAuth2Token        string
+	SMTPInsecureSkipVerify bool
+
+	// Bookings; pending itinerary items are booked with this provider
+	// (mock), and booking is unavailable while it's empty
+	BookingProvider string
+
+	// Outbound HTTP; every service's client shares one connection pool.
+	// Calls time out after HTTPTimeoutSeconds unless a service sets its own,
+	// and transient failures of idempotent requests are retried up to
+	// HTTPRetryMax times, backing off from HTTPRetryBackoffMs.
+	HTTPTimeoutSeconds  int
+	HTTPMaxIdleConns    int
+	HTTPMaxConnsPerHost int
+	HTTPRetryMax        int
+	HTTPRetryBackoffMs  int
+
+	// Budget tracking; travelers are alerted when projected spend exceeds
+	// their budget by this factor
+	BudgetAlertThreshold float64
 }
 
 func Load() *Config {
@@ -52,6 +163,7 @@ func Load() *Config {
 		GoogleCloudProjectID:         getEnv("GOOGLE_CLOUD_PROJECT_ID", ""),
 		GoogleCloudRegion:            getEnv("GOOGLE_CLOUD_REGION", "us-central1"),
 		GoogleApplicationCredentials: getEnv("GOOGLE_APPLICATION_CREDENTIALS", ""),
+		BigQueryDataset:              getEnv("BIGQUERY_DATASET", "auratravel_analytics"),
 
 		// Firebase
 		FirebaseProjectID:               getEnv("FIREBASE_PROJECT_ID", ""),
@@ -66,23 +178,98 @@ func Load() *Config {
 
 		// Gemini AI
 		GeminiAPIKey: getEnv("GEMINI_API_KEY", ""),
+		GeminiModel:  getEnv("GEMINI_MODEL", "gemini-2.0-flash"),
+
+		GeminiStructuredTemperature: getEnvAsFloat("GEMINI_STRUCTURED_TEMPERATURE", 0.2),
+		GeminiCreativeTemperature:   getEnvAsFloat("GEMINI_CREATIVE_TEMPERATURE", 0.9),
+		GeminiGeneralTemperature:    getEnvAsFloat("GEMINI_GENERAL_TEMPERATURE", 0.7),
+		GeminiTopP:                  getEnvAsFloat("GEMINI_TOP_P", 0.95),
+		GeminiMaxOutputTokens:       getEnvAsInt("GEMINI_MAX_OUTPUT_TOKENS", 8192),
+		GeminiSafetyThreshold:       getEnv("GEMINI_SAFETY_THRESHOLD", "BLOCK_MEDIUM_AND_ABOVE"),
+		AttractionSlotWeights:       getEnv("ATTRACTION_SLOT_WEIGHTS", ""),
+
+		// Vertex AI
+		VertexAIProjectID: getEnv("VERTEX_AI_PROJECT_ID", ""),
+		VertexAILocation:  getEnv("VERTEX_AI_LOCATION", ""),
+		VertexAIModel:     getEnv("VERTEX_AI_MODEL", "gemini-1.5-flash"),
 
 		// External APIs
 		GoogleMapsAPIKey: getEnv("GOOGLE_MAPS_API_KEY", ""),
 		WeatherAPIKey:    getEnv("WEATHER_API_KEY", ""),
 
 		// JWT
-		JWTSecret:     getEnv("JWT_SECRET", "your-secret-key"),
+		JWTSecret:     getEnv("JWT_SECRET", ""),
 		JWTExpiration: getEnvAsInt("JWT_EXPIRATION", 24), // hours
 
 		// Rate Limiting
 		RateLimitRequests: getEnvAsInt("RATE_LIMIT_REQUESTS", 100),
 		RateLimitWindow:   getEnvAsInt("RATE_LIMIT_WINDOW", 3600), // seconds
+		AIRateLimitRPS:    getEnvAsFloat("AI_RATE_LIMIT_RPS", 0.2),
+		AIRateLimitBurst:  getEnvAsInt("AI_RATE_LIMIT_BURST", 10),
+
+		// Redis
+		RedisHost:     getEnv("REDIS_HOST", ""),
+		RedisPort:     getEnv("REDIS_PORT", "6379"),
+		RedisPassword: getEnv("REDIS_PASSWORD", ""),
+		RedisDB:       getEnvAsInt("REDIS_DB", 0),
+
+		// Cache; bump the version to invalidate all cached entries
+		CacheVersion: getEnv("CACHE_VERSION", "1"),
+
+		// Tracing; disabled unless an OTLP/HTTP endpoint is set
+		OTLPEndpoint:    getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTelServiceName: getEnv("OTEL_SERVICE_NAME", "auratravel-backend"),
+
+		// Trip Planning
+		MaxTripDays: getEnvAsInt("MAX_TRIP_DAYS", 30),
+
+		// Replanning
+		ReplanSimilarityThreshold: getEnvAsFloat("REPLAN_SIMILARITY_THRESHOLD", 0.6),
+		MaxTripMonitors:           getEnvAsInt("MAX_TRIP_MONITORS", 100),
+		FlightStatusWebhookSecret: getEnv("FLIGHT_STATUS_WEBHOOK_SECRET", ""),
+
+		// AI generation
+		GenerationOrder: getEnv("AI_GENERATION_ORDER", "rag,gemini,mock"),
+
+		// Delivery
+		OfflineBundleMaxMB: getEnvAsInt("OFFLINE_BUNDLE_MAX_MB", 25),
+
+		// Email delivery; a port of 0 picks the TLS mode's standard port
+		SMTPHost:               getEnv("SMTP_HOST", "smtp.gmail.com"),
+		SMTPPort:               getEnvAsInt("SMTP_PORT", 0),
+		SMTPUsername:           getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:           getEnv("SMTP_PASSWORD", ""),
+		SMTPFromEmail:          getEnv("SMTP_FROM_EMAIL", "noreply@auratravel.ai"),
+		SMTPTLSMode:            getEnv("SMTP_TLS_MODE", "starttls"),
+		SMTPAuthMethod:         getEnv("SMTP_AUTH_METHOD", "plain"),
+		SMTPOAuth2Token:        getEnv("SMTP_OAUTH2_TOKEN", ""),
+		SMTPInsecureSkipVerify: getEnvAsBool("SMTP_INSECURE_SKIP_VERIFY", false),
+
+		// Bookings
+		BookingProvider: getEnv("BOOKING_PROVIDER", ""),
+
+		// Outbound HTTP
+		HTTPTimeoutSeconds:  getEnvAsInt("HTTP_CLIENT_TIMEOUT_SECONDS", 30),
+		HTTPMaxIdleConns:    getEnvAsInt("HTTP_MAX_IDLE_CONNS", 100),
+		HTTPMaxConnsPerHost: getEnvAsInt("HTTP_MAX_CONNS_PER_HOST", 20),
+		HTTPRetryMax:        getEnvAsInt("HTTP_RETRY_MAX", 2),
+		HTTPRetryBackoffMs:  getEnvAsInt("HTTP_RETRY_BACKOFF_MS", 200),
+
+		// Budget tracking
+		BudgetAlertThreshold: getEnvAsFloat("BUDGET_ALERT_THRESHOLD", 1.0),
 	}
 }
 
 var config *Config
 
+// Validate reports configuration the server must not start with
+func (c *Config) Validate() error {
+	if c.JWTSecret == "" || placeholderJWTSecrets[c.JWTSecret] {
+		return errors.New("JWT_SECRET must be set to a private value")
+	}
+	return nil
+}
+
 // GetConfig returns the current configuration, loading it if necessary
 func GetConfig() *Config {
 	if config == nil {
@@ -106,3 +293,21 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,35 @@
+package config
+
+import "testing"
+
+func TestValidateRejectsMissingOrPlaceholderJWTSecret(t *testing.T) {
+	for _, secret := range []string{"", "your-secret-key", "your_jwt_secret"} {
+		if err := (&Config{JWTSecret: secret}).Validate(); err == nil {
+			t.Errorf("Validate accepted JWT secret %q", secret)
+		}
+	}
+	if err := (&Config{JWTSecret: "a-long-private-secret"}).Validate(); err != nil {
+		t.Errorf("Validate rejected a private secret: %v", err)
+	}
+}
+
+func TestLoadSMTPSettings(t *testing.T) {
+	t.Setenv("SMTP_HOST", "smtp.office365.com")
+	t.Setenv("SMTP_PORT", "465")
+	t.Setenv("SMTP_TLS_MODE", "implicit")
+	t.Setenv("SMTP_AUTH_METHOD", "xoauth2")
+	t.Setenv("SMTP_OAUTH2_TOKEN", "token")
+	t.Setenv("SMTP_INSECURE_SKIP_VERIFY", "true")
+
+	cfg := Load()
+	if cfg.SMTPHost != "smtp.office365.com" || cfg.SMTPPort != 465 || cfg.SMTPTLSMode != "implicit" ||
+		cfg.SMTPAuthMethod != "xoauth2" || cfg.SMTPOAuth2Token != "token" || !cfg.SMTPInsecureSkipVerify {
+		t.Errorf("Load = %+v, want the SMTP settings from the environment", cfg)
+	}
+
+	t.Setenv("SMTP_INSECURE_SKIP_VERIFY", "")
+	t.Setenv("SMTP_TLS_MODE", "")
+	if cfg := Load(); cfg.SMTPInsecureSkipVerify || cfg.SMTPTLSMode != "starttls" {
+		t.Errorf("defaults: insecure %v, TLS mode %q; want verified starttls", cfg.SMTPInsecureSkipVerify, cfg.SMTPTLSMode)
+	}
+}
@@ -2,17 +2,26 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
+	"auratravel-backend/internal/config"
 	"auratravel-backend/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
+// maxImageUploadSize caps images accepted by AnalyzeImage
+const maxImageUploadSize = 10 << 20
+
 // AITripHandler handles AI-powered trip operations
 type AITripHandler struct {
 	services *services.Services
@@ -27,15 +36,174 @@ func NewAITripHandler(services *services.Services) *AITripHandler {
 
 // PlanTripRequest represents a trip planning request
 type PlanTripRequest struct {
-	Destination string                 `json:"destination" binding:"required"`
+	Destination string                 `json:"destination"`
 	StartDate   string                 `json:"start_date" binding:"required"`
 	EndDate     string                 `json:"end_date" binding:"required"`
 	Budget      float64                `json:"budget"`
 	Travelers   int                    `json:"travelers"`
 	Preferences map[string]interface{} `json:"preferences"`
 	TripType    string                 `json:"trip_type"`
+	TravelStyle string                 `json:"travel_style"` // budget, balanced, luxury
 	Interests   []string               `json:"interests"`
-	UserID      string                 `json:"user_id"`
+	UserID      string                 `json:"-"` // set from the authenticated user
+	// RankingWeights tunes how candidate attractions and hotels are ranked
+	RankingWeights *services.RankingWeights `json:"ranking_weights,omitempty"`
+	// Destinations plans a multi-city trip; the legs' nights must add up to
+	// the trip's nights
+	Destinations []services.DestinationLeg `json:"destinations,omitempty"`
+	// TravelerProfiles personalizes parts of each day for subgroups of a
+	// group trip
+	TravelerProfiles []services.TravelerProfile `json:"traveler_profiles,omitempty"`
+
+	// Currency is the currency Budget is given in, USD when empty
+	Currency string `json:"currency,omitempty"`
+}
+
+// validate checks the request beyond what binding tags cover and returns
+// an error message per invalid field
+func (r PlanTripRequest) validate(maxDays int) map[string]string {
+	fieldErrors := make(map[string]string)
+
+	start, startErr := time.Parse("2006-01-02", r.StartDate)
+	if startErr != nil {
+		fieldErrors["start_date"] = "must be a date in YYYY-MM-DD format"
+	}
+	end, endErr := time.Parse("2006-01-02", r.EndDate)
+	if endErr != nil {
+		fieldErrors["end_date"] = "must be a date in YYYY-MM-DD format"
+	}
+	if startErr == nil && endErr == nil {
+		if !end.After(start) {
+			fieldErrors["end_date"] = "must be after start_date"
+		} else if days := int(end.Sub(start).Hours()/24) + 1; maxDays > 0 && days > maxDays {
+			fieldErrors["end_date"] = fmt.Sprintf("trip cannot be longer than %d days", maxDays)
+		}
+	}
+
+	if len(r.Destinations) == 0 && r.Destination == "" {
+		fieldErrors["destination"] = "is required"
+	}
+	for i, leg := range r.Destinations {
+		if leg.Destination == "" {
+			fieldErrors["destinations"] = fmt.Sprintf("leg %d is missing a destination", i+1)
+			break
+		}
+		if leg.Nights < 1 {
+			fieldErrors["destinations"] = fmt.Sprintf("leg %d must have at least 1 night", i+1)
+			break
+		}
+	}
+	if _, ok := fieldErrors["destinations"]; !ok && len(r.Destinations) > 0 && startErr == nil && endErr == nil {
+		if nights := int(end.Sub(start).Hours() / 24); services.TotalNights(r.Destinations) != nights {
+			fieldErrors["destinations"] = fmt.Sprintf("nights must add up to the trip's %d nights", nights)
+		}
+	}
+
+	for i, profile := range r.TravelerProfiles {
+		if err := services.ValidateTravelerProfile(profile); err != nil {
+			fieldErrors["traveler_profiles"] = fmt.Sprintf("traveler %d: %v", i+1, err)
+			break
+		}
+	}
+
+	if r.Budget < 0 {
+		fieldErrors["budget"] = "must not be negative"
+	}
+	if r.Travelers < 1 {
+		fieldErrors["travelers"] = "must be at least 1"
+	}
+	if _, err := services.BudgetAllocationForStyle(r.TravelStyle); err != nil {
+		fieldErrors["travel_style"] = "must be one of budget, balanced, luxury"
+	}
+	if r.RankingWeights != nil {
+		if _, err := r.RankingWeights.Normalized(); err != nil {
+			fieldErrors["ranking_weights"] = err.Error()
+		}
+	}
+
+	return fieldErrors
+}
+
+// ValidateTrip runs pre-flight checks on a trip request without planning or
+// saving anything, so the frontend can warn before generation. Invalid
+// fields are reported as errors alongside the feasibility checks.
+func (h *AITripHandler) ValidateTrip(c *gin.Context) {
+	var req PlanTripRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var issues []services.FeasibilityIssue
+	start, startErr := time.Parse("2006-01-02", req.StartDate)
+	end, endErr := time.Parse("2006-01-02", req.EndDate)
+	if startErr == nil && endErr == nil {
+		predictor := h.services.CostPredictor
+		if predictor == nil {
+			predictor = services.NewTravelCostPredictor()
+		}
+		rates := h.services.ExchangeRates
+		if rates == nil {
+			rates = services.DefaultExchangeRates()
+		}
+		destination := req.Destination
+		if len(req.Destinations) > 0 {
+			destination = services.RouteName(req.Destinations)
+		}
+		var err error
+		issues, err = predictor.CheckTripFeasibility(c.Request.Context(), services.TripFeasibilityRequest{
+			Destination:  destination,
+			Destinations: req.Destinations,
+			StartDate:    start,
+			EndDate:      end,
+			Budget:       req.Budget,
+			Currency:     req.Currency,
+			Travelers:    req.Travelers,
+			TravelStyle:  req.TravelStyle,
+		}, rates)
+		if err != nil {
+			respondError(c, err, "Failed to check trip feasibility")
+			return
+		}
+	}
+
+	// Field errors the feasibility checks already explain aren't repeated
+	explained := make(map[string]bool)
+	for _, issue := range issues {
+		if issue.Severity == services.SeverityError {
+			explained[issue.Field] = true
+		}
+	}
+	fieldErrors := req.validate(config.GetConfig().MaxTripDays)
+	fields := make([]string, 0, len(fieldErrors))
+	for field := range fieldErrors {
+		if !explained[field] {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	invalid := make([]services.FeasibilityIssue, 0, len(fields))
+	for _, field := range fields {
+		invalid = append(invalid, services.FeasibilityIssue{
+			Field:    field,
+			Code:     "invalid",
+			Severity: services.SeverityError,
+			Message:  fieldErrors[field],
+		})
+	}
+	issues = append(invalid, issues...)
+
+	feasible := true
+	for _, issue := range issues {
+		if issue.Severity == services.SeverityError {
+			feasible = false
+			break
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"feasible": feasible,
+		"issues":   issues,
+	})
 }
 
 // PlanTripResponse represents the AI-generated trip plan
@@ -57,6 +225,54 @@ type TripBudget struct {
 	Food           float64            `json:"food"`
 	Activities     float64            `json:"activities"`
 	Breakdown      map[string]float64 `json:"breakdown"`
+
+	// Currency labels every amount above: the traveler's preferred currency
+	// when they have one, otherwise the trip's
+	Currency string `json:"currency"`
+	// Original is the same breakdown in the trip's currency, when that
+	// differs from Currency
+	Original *TripBudget `json:"original,omitempty"`
+}
+
+// travelerProfile returns the user's nationality and preferred language,
+// which are empty when unknown
+func (h *AITripHandler) travelerProfile(ctx context.Context, userID string) (nationality, locale string) {
+	if h.services.Firebase != nil {
+		if profile, err := h.services.Firebase.GetUserProfile(ctx, userID); err == nil {
+			nationality, locale = profile.Nationality, profile.PreferredLanguage
+		}
+	}
+	return nationality, locale
+}
+
+// emergencyInfo returns the destination's emergency card for the user's
+// nationality and language, or nil when there isn't one. It's a convenience
+// on the itinerary, so failures don't fail planning.
+func (h *AITripHandler) emergencyInfo(ctx context.Context, nationality, locale, destination string) *services.EmergencyInfo {
+	if h.services.Gemini == nil {
+		return nil
+	}
+	info, err := h.services.Gemini.GetEmergencyInfo(ctx, destination, nationality, locale)
+	if err != nil {
+		log.Printf("No emergency information for %s: %v", destination, err)
+		return nil
+	}
+	return info
+}
+
+// travelAdvisory returns the destination's travel advisory for the user's
+// nationality, or nil when there isn't one. Like the emergency card, failures
+// don't fail planning.
+func (h *AITripHandler) travelAdvisory(ctx context.Context, nationality, destination string) *services.Advisory {
+	if h.services.Gemini == nil {
+		return nil
+	}
+	advisory, err := h.services.Gemini.GetTravelAdvisory(ctx, destination, nationality)
+	if err != nil {
+		log.Printf("No travel advisory for %s: %v", destination, err)
+		return nil
+	}
+	return advisory
 }
 
 // PlanTrip creates an AI-powered trip plan
@@ -66,84 +282,126 @@ func (h *AITripHandler) PlanTrip(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.UserID = currentUserID(c)
 
-	ctx := context.Background()
+	if fieldErrors := req.validate(config.GetConfig().MaxTripDays); len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  "Invalid trip request",
+			"fields": fieldErrors,
+		})
+		return
+	}
+	days, _ := h.calculateDays(req.StartDate, req.EndDate)
+	if len(req.Destinations) > 0 {
+		req.Destination = services.RouteName(req.Destinations)
+	}
 
-	// Use RAG for enhanced trip planning
-	var itinerary map[string]interface{}
-	var suggestions []string
-	var ragEnabled bool
+	ctx := context.WithoutCancel(c.Request.Context())
 
-	// Try RAG-enhanced planning first
-	if h.services.RAGRetriever != nil && h.services.Gemini != nil {
-		// Retrieve contextual data
-		ragRequest := services.RetrievalRequest{
-			UserID:      req.UserID,
-			Destination: req.Destination,
-			StartDate:   h.parseDate(req.StartDate),
-			EndDate:     h.parseDate(req.EndDate),
-			Budget:      req.Budget,
-			Travelers:   req.Travelers,
-			Interests:   req.Interests,
-			Preferences: req.Preferences,
+	budget, err := h.budgetBreakdown(ctx, req)
+	if err != nil {
+		var unknown *services.UnknownCurrencyError
+		if errors.As(err, &unknown) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported currency %s", unknown.Currency)})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to calculate budget"})
+		return
+	}
 
-		ragContext, err := h.services.RAGRetriever.RetrieveContext(ctx, ragRequest)
-		if err == nil {
-			// Generate itinerary with RAG context
-			ragItinerary, err := h.services.Gemini.GenerateItineraryWithRAG(ctx, services.ItineraryRequest{
-				Destination: req.Destination,
-				StartDate:   req.StartDate,
-				EndDate:     req.EndDate,
-				Budget:      req.Budget,
-				Travelers:   req.Travelers,
-				Preferences: req.Preferences,
-			}, *ragContext)
-
-			if err == nil {
-				itinerary = ragItinerary
-				ragEnabled = true
-
-				// Extract suggestions from attractions
-				for _, attraction := range ragContext.Attractions {
-					suggestions = append(suggestions, fmt.Sprintf("Visit %s - %s", attraction.Name, attraction.Description))
-				}
+	// A retried request with the same Idempotency-Key gets the original
+	// response instead of a duplicate trip
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	keyClaimed := false
+	stopHeartbeat := func() {}
+	if idempotencyKey != "" && h.services.Firebase != nil {
+		requestHash, err := services.HashIdempotentRequest(req)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+		record, claimed, err := h.services.Firebase.ClaimIdempotencyKey(ctx, req.UserID, idempotencyKey, requestHash)
+		if errors.Is(err, services.ErrIdempotencyKeyReused) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check Idempotency-Key"})
+			return
+		}
+		if !claimed {
+			if record.Status == services.IdempotencyPending {
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is already in progress"})
+				return
 			}
+			var replay PlanTripResponse
+			if err := record.DecodeResponse(&replay); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to replay idempotent response"})
+				return
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.JSON(http.StatusOK, replay)
+			return
 		}
+		keyClaimed = true
+		stopHeartbeat = h.services.Firebase.KeepIdempotencyKeyAlive(ctx, req.UserID, idempotencyKey)
+		defer stopHeartbeat()
 	}
 
-	// Fallback to regular AI if RAG fails
-	if itinerary == nil && h.services.Gemini != nil {
-		geminiItinerary, err := h.services.Gemini.GenerateItinerary(ctx, services.ItineraryRequest{
-			Destination: req.Destination,
-			StartDate:   req.StartDate,
-			EndDate:     req.EndDate,
-			Budget:      req.Budget,
-			Travelers:   req.Travelers,
-			Preferences: req.Preferences,
-		})
-		if err == nil {
-			itinerary = geminiItinerary
+	// releaseKey lets a retry run again after a failure
+	releaseKey := func() {
+		if !keyClaimed {
+			return
 		}
-
-		geminiSuggestions, err := h.services.Gemini.GetActivitySuggestions(ctx, req.Destination, req.Interests)
-		if err == nil {
-			suggestions = geminiSuggestions
+		stopHeartbeat()
+		if err := h.services.Firebase.ReleaseIdempotencyKey(ctx, req.UserID, idempotencyKey); err != nil {
+			log.Printf("Failed to release idempotency key: %v", err)
 		}
 	}
 
-	// Fallback to basic itinerary if AI fails
-	if itinerary == nil {
-		itinerary = h.createBasicItinerary(req)
+	// Plan with the configured strategies, falling back in order
+	if h.services.GenerationPipeline == nil {
+		releaseKey()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Itinerary generation not available"})
+		return
 	}
-	if len(suggestions) == 0 {
-		suggestions = h.getBasicSuggestions(req.Destination)
+	generated, err := h.services.GenerationPipeline.Generate(ctx, services.GenerationRequest{
+		ItineraryRequest: services.ItineraryRequest{
+			Destination:      req.Destination,
+			StartDate:        req.StartDate,
+			EndDate:          req.EndDate,
+			Budget:           req.Budget,
+			Travelers:        req.Travelers,
+			Preferences:      req.Preferences,
+			Destinations:     req.Destinations,
+			TravelerProfiles: req.TravelerProfiles,
+		},
+		UserID:         req.UserID,
+		Interests:      req.Interests,
+		TravelStyle:    req.TravelStyle,
+		RankingWeights: req.RankingWeights,
+		Days:           days,
+	})
+	if err != nil {
+		log.Printf("Failed to generate itinerary: %v", err)
+		releaseKey()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to generate itinerary"})
+		return
 	}
+	itinerary, suggestions := generated.Itinerary, generated.Suggestions
 
-	// Add RAG enhancement indicator
-	if ragEnabled {
-		itinerary["rag_enhanced"] = true
-		itinerary["data_sources"] = []string{"real_attractions", "weather_forecast", "hotel_availability", "transportation_options"}
+	// Record the budget's currency on the itinerary, which otherwise
+	// doesn't say what its costs are in
+	if _, ok := itinerary["currency"]; !ok {
+		itinerary["currency"] = budget.tripCurrency()
+	}
+	nationality, locale := h.travelerProfile(ctx, req.UserID)
+	if info := h.emergencyInfo(ctx, nationality, locale, req.Destination); info != nil {
+		itinerary["emergency_info"] = info
+	}
+	if advisory := h.travelAdvisory(ctx, nationality, req.Destination); advisory != nil {
+		itinerary["travel_advisory"] = advisory
+		itinerary["important_info"] = advisory.ImportantInfo()
 	}
 
 	// Create trip in Firestore only
@@ -163,34 +421,48 @@ func (h *AITripHandler) PlanTrip(c *gin.Context) {
 		UpdatedAt:   time.Now(),
 	}
 	if h.services.Firebase != nil {
-		if err := h.services.Firebase.SaveTrip(ctx, trip); err != nil {
+		if err := h.services.Firebase.SaveTrip(ctx, &trip); err != nil {
+			releaseKey()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save trip to Firestore"})
 			return
 		}
-	}
 
-	budget := h.calculateBudgetBreakdown(req.Budget, req.Travelers)
+		// Mirror the itinerary into the relational rows. The trip document
+		// stays the source of truth, so a failure here doesn't fail the request.
+		if rows, err := services.MapTripToRows(trip, time.Now()); err != nil {
+			log.Printf("Failed to map trip %s to rows: %v", tripID, err)
+		} else if err := h.services.Firebase.SaveTripRows(ctx, rows); err != nil {
+			log.Printf("Failed to save rows for trip %s: %v", tripID, err)
+		}
+	}
 
 	response := PlanTripResponse{
 		TripID:      tripID,
 		Title:       trip.Title,
-		Description: fmt.Sprintf("AI-powered %d-day trip to %s", h.calculateDays(req.StartDate, req.EndDate), req.Destination),
+		Description: fmt.Sprintf("AI-powered %d-day trip to %s", days, req.Destination),
 		Itinerary:   itinerary,
 		Budget:      budget,
 		Suggestions: suggestions,
 		CreatedAt:   time.Now(),
 	}
 
+	if keyClaimed {
+		stopHeartbeat()
+		if err := h.services.Firebase.CompleteIdempotencyKey(ctx, req.UserID, idempotencyKey, response); err != nil {
+			log.Printf("Failed to store idempotent response: %v", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, response)
 }
 
 // GetRecommendations gets AI-powered destination recommendations
 func (h *AITripHandler) GetRecommendations(c *gin.Context) {
-	userID := c.Query("user_id")
+	userID := currentUserID(c)
 	budget := c.Query("budget")
 	interests := c.QueryArray("interests")
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 	var recommendations []map[string]interface{}
 
 	// Get recommendations from Gemini AI
@@ -205,17 +477,17 @@ func (h *AITripHandler) GetRecommendations(c *gin.Context) {
 		}
 	}
 
-	// Get personalized recommendations from Vertex AI
-	if h.services.Vertex != nil {
-		// Convert preferences for the existing method signature
-		// vertexRecs, err := h.services.Vertex.GeneratePersonalizedRecommendations(ctx, ...)
-		// For now, skip Vertex recommendations since signature doesn't match
-	}
-
-	// Get trending destinations from BigQuery
-	if h.services.BigQuery != nil {
-		// trending, err := h.services.BigQuery.GetTrendingDestinations(ctx, 10)
-		// Method signature doesn't match - skipping for now
+	// Add recommendations from Vertex AI. Its mock fallback matches Gemini's,
+	// so only ask it when it's configured.
+	if h.services.Vertex != nil && h.services.Vertex.HasCredentials() {
+		vertexRecs, err := h.services.Vertex.GetDestinationRecommendations(ctx, services.RecommendationRequest{
+			UserID:    userID,
+			Budget:    h.parseFloat(budget),
+			Interests: interests,
+		})
+		if err == nil {
+			recommendations = mergeRecommendations(recommendations, vertexRecs)
+		}
 	}
 
 	// Fallback recommendations
@@ -234,7 +506,93 @@ func (h *AITripHandler) GetRecommendations(c *gin.Context) {
 	})
 }
 
-// OptimizeItinerary optimizes an existing itinerary using AI
+// mergeRecommendations appends extra recommendations whose destination
+// isn't already recommended
+func mergeRecommendations(recommendations, extra []map[string]interface{}) []map[string]interface{} {
+	seen := make(map[string]bool)
+	for _, rec := range recommendations {
+		if destination, ok := rec["destination"].(string); ok {
+			seen[strings.ToLower(destination)] = true
+		}
+	}
+	for _, rec := range extra {
+		destination, _ := rec["destination"].(string)
+		if destination != "" && seen[strings.ToLower(destination)] {
+			continue
+		}
+		seen[strings.ToLower(destination)] = true
+		recommendations = append(recommendations, rec)
+	}
+	return recommendations
+}
+
+// ExplainRecommendation explains why a stored recommendation was made for
+// the user
+func (h *AITripHandler) ExplainRecommendation(c *gin.Context) {
+	if h.services.RecommendationService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Recommendation service not available"})
+		return
+	}
+
+	recommendationID := c.Param("id")
+	ctx := context.WithoutCancel(c.Request.Context())
+	explanation, err := h.services.RecommendationService.ExplainRecommendation(ctx, currentUserID(c), recommendationID)
+	if err != nil {
+		var notFound *services.RecommendationNotFoundError
+		if errors.As(err, &notFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recommendation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to explain recommendation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"recommendation_id": recommendationID,
+		"explanation":       explanation,
+	})
+}
+
+// Chat answers a traveler's message in the language it's written in, or in
+// their stored locale when the language isn't clear
+func (h *AITripHandler) Chat(c *gin.Context) {
+	var req struct {
+		Message string `json:"message" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if h.services.LocalizationService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Chat not available"})
+		return
+	}
+
+	userID := currentUserID(c)
+	reply, err := h.services.LocalizationService.ReplyToChat(c.Request.Context(), userID, req.Message)
+	if err != nil {
+		respondError(c, err, "Failed to answer message")
+		return
+	}
+	h.services.AnalyticsService.EmitEvent(context.WithoutCancel(c.Request.Context()), userID, services.EventChatMessage, map[string]interface{}{
+		"language":        reply.Language,
+		"language_source": reply.LanguageSource,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"reply": reply.Reply,
+		"metadata": gin.H{
+			"language":            reply.Language,
+			"language_confidence": reply.LanguageConfidence,
+			"language_source":     reply.LanguageSource,
+		},
+	})
+}
+
+// OptimizeItinerary reorders each day's activities to minimize travel time
+// and saves the new order as the activities' scheduled times. Like other
+// edits it only applies to the trip version it was worked out from, so a
+// concurrent change is a 409 rather than a lost update.
 func (h *AITripHandler) OptimizeItinerary(c *gin.Context) {
 	tripID := c.Param("id")
 
@@ -248,72 +606,261 @@ func (h *AITripHandler) OptimizeItinerary(c *gin.Context) {
 		return
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
-	// Get existing trip from Firestore
-	if h.services.Firebase != nil {
-		t, err := h.services.Firebase.GetTrip(ctx, tripID)
-		if err != nil || t == nil {
+	if h.services.Firebase == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to optimize itinerary"})
+		return
+	}
+
+	// Get existing trip from Firestore, checking the caller may access it
+	userID, _ := c.Get("userID")
+	uid, _ := userID.(string)
+
+	trip, err := h.services.Firebase.GetTripWithItinerary(ctx, tripID, uid)
+	if err != nil {
+		var permErr *services.TripPermissionError
+		var notFoundErr *services.TripNotFoundError
+		switch {
+		case errors.As(err, &permErr):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this trip"})
+		case errors.As(err, &notFoundErr):
 			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load trip"})
+		}
+		return
+	}
+
+	if !services.RoleAllows(trip.Role, services.TripActionEdit) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Viewers cannot optimize this trip"})
+		return
+	}
+
+	// Reorder each day's activities to cut travel time
+	optimization := services.OptimizeDayRoutes(trip.Days)
+
+	version := trip.Trip.Version
+	if optimization.Reordered() {
+		version, err = h.services.Firebase.SaveOptimizedDays(ctx, tripID, version, optimization)
+		if err != nil {
+			respondTripUpdateError(c, err, "Failed to save optimized itinerary")
 			return
 		}
 	}
+	c.Header("ETag", tripETag(version))
 
-	// Optimize using Vertex AI
-	var optimizedItinerary map[string]interface{}
+	response := gin.H{
+		"trip_id":             tripID,
+		"version":             version,
+		"optimized_itinerary": optimization.Days,
+		"optimization_score":  optimization.OptimizationScore,
+		"optimized_at":        time.Now(),
+	}
+
+	// Suggest improvements beyond route ordering
 	if h.services.Vertex != nil {
-		// optimized, _, err := h.services.Vertex.OptimizeItinerary(ctx, ...)
-		// Method signature doesn't match current requirements - using mock for now
-		optimizedItinerary = map[string]interface{}{
-			"optimized": true,
-			"message":   "Itinerary optimization not yet implemented",
+		suggestions, err := h.services.Vertex.SuggestItineraryImprovements(ctx, trip.Trip.Destination, optimization.Days, optimizationReq.Preferences, optimizationReq.Constraints)
+		if err == nil && len(suggestions) > 0 {
+			response["suggestions"] = suggestions
 		}
 	}
 
-	if optimizedItinerary == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to optimize itinerary"})
+	c.JSON(http.StatusOK, response)
+}
+
+// RegenerateDay replans one day of a trip from fresh RAG context, keeping
+// the other days, the day's booked activities and the route from the
+// previous day. Nothing is saved; ConfirmDayRegeneration saves the new day.
+func (h *AITripHandler) RegenerateDay(c *gin.Context) {
+	day, err := strconv.Atoi(c.Param("dayNum"))
+	if err != nil || day < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Day must be a positive number"})
 		return
 	}
 
-	// Update trip in Firebase
-	if h.services.Firebase != nil {
-		updates := map[string]interface{}{
-			"itinerary": optimizedItinerary,
+	// The body is optional
+	var req struct {
+		Preferences map[string]interface{} `json:"preferences"`
+		Interests   []string               `json:"interests"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.services.Gemini == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI service not available"})
+		return
+	}
+	trip, ok := authorizeTrip(c, h.services.Firebase, c.Param("tripId"), services.TripActionEdit)
+	if !ok {
+		return
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	ragContext := services.TripContext{Destination: trip.Destination}
+	if h.services.RAGRetriever != nil {
+		retrieved, err := h.services.RAGRetriever.RetrieveContext(ctx, services.RetrievalRequest{
+			UserID:      currentUserID(c),
+			Destination: trip.Destination,
+			StartDate:   toTime(trip.StartDate),
+			EndDate:     toTime(trip.EndDate),
+			Budget:      trip.Budget,
+			Travelers:   trip.Travelers,
+			Interests:   req.Interests,
+			Preferences: req.Preferences,
+		})
+		if err != nil {
+			log.Printf("Failed to retrieve context for trip %s: %v", trip.ID, err)
+		} else {
+			ragContext = *retrieved
+		}
+	}
+
+	regeneration, err := h.services.Gemini.RegenerateDay(ctx, trip, day, ragContext, req.Preferences)
+	if err != nil {
+		if errors.Is(err, services.ErrDayNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Trip has no day %d", day)})
+			return
 		}
-		h.services.Firebase.UpdateTrip(ctx, tripID, updates)
+		respondError(c, err, "Failed to regenerate the day")
+		return
+	}
+
+	c.JSON(http.StatusOK, regeneration)
+}
+
+// ConfirmDayRegeneration saves a day proposed by RegenerateDay, leaving the
+// rest of the itinerary as it is
+func (h *AITripHandler) ConfirmDayRegeneration(c *gin.Context) {
+	day, err := strconv.Atoi(c.Param("dayNum"))
+	if err != nil || day < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Day must be a positive number"})
+		return
+	}
+
+	var req struct {
+		RegeneratedDay map[string]interface{} `json:"regenerated_day" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trip, ok := authorizeTrip(c, h.services.Firebase, c.Param("tripId"), services.TripActionEdit)
+	if !ok {
+		return
+	}
+
+	if err := services.SetItineraryDay(trip.Itinerary, day, req.RegeneratedDay); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Trip has no day %d", day)})
+		return
+	}
+	updates := map[string]interface{}{
+		"itinerary":  trip.Itinerary,
+		"updated_at": time.Now(),
+	}
+	// The day replaces the whole itinerary, so it mustn't race another edit
+	if _, err := h.services.Firebase.UpdateTripAtVersion(c.Request.Context(), trip.ID, trip.Version, updates); err != nil {
+		respondTripUpdateError(c, err, "Failed to save the regenerated day")
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"trip_id":             tripID,
-		"optimized_itinerary": optimizedItinerary,
-		"optimized_at":        time.Now(),
+		"success": true,
+		"trip_id": trip.ID,
+		"day":     day,
 	})
 }
 
+// SuggestBudgetCuts proposes swaps that bring a trip under a target budget,
+// without applying them
+func (h *AITripHandler) SuggestBudgetCuts(c *gin.Context) {
+	var req struct {
+		TargetBudget float64 `json:"target_budget" binding:"required,gt=0"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tripID := c.Param("id")
+	if _, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionView); !ok {
+		return
+	}
+	if h.services.BudgetCutService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Budget cut suggestions are not available"})
+		return
+	}
+
+	cuts, err := h.services.BudgetCutService.SuggestBudgetCuts(c.Request.Context(), tripID, req.TargetBudget)
+	if err != nil {
+		respondError(c, err, "Failed to suggest budget cuts")
+		return
+	}
+
+	response := gin.H{
+		"trip_id":       tripID,
+		"target_budget": req.TargetBudget,
+		"cuts":          cuts,
+	}
+	// No cuts means the trip is already under budget or nothing cheaper was
+	// found; either way there's no projected total to report
+	if len(cuts) > 0 {
+		last := cuts[len(cuts)-1]
+		response["projected_total"] = last.ProjectedTotal
+		response["target_met"] = last.ProjectedTotal <= req.TargetBudget
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 // AnalyzeImage analyzes uploaded travel images using Vision AI
 func (h *AITripHandler) AnalyzeImage(c *gin.Context) {
-	file, header, err := c.Request.FormFile("image")
+	file, _, err := c.Request.FormFile("image")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No image file provided"})
 		return
 	}
 	defer file.Close()
 
-	ctx := context.Background()
+	// Read one byte past the limit so oversized uploads can be detected
+	imageData, err := io.ReadAll(io.LimitReader(file, maxImageUploadSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read image file"})
+		return
+	}
+	if len(imageData) > maxImageUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Image exceeds the %d MB limit", maxImageUploadSize>>20)})
+		return
+	}
+
+	// Trust the content, not the client-supplied header
+	if contentType := http.DetectContentType(imageData); !strings.HasPrefix(contentType, "image/") {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Uploaded file is not an image"})
+		return
+	}
+
+	// EXIF GPS pins the location more precisely than landmark detection.
+	// Read it, then drop the metadata before the image goes anywhere else.
+	latitude, longitude, hasGPS := services.ExtractGPSCoordinates(imageData)
+	imageData = services.StripEXIF(imageData)
+
+	ctx := context.WithoutCancel(c.Request.Context())
 	var analysis map[string]interface{}
+	var landmarks []map[string]interface{}
 
 	if h.services.Vision != nil {
-		// Convert file to bytes
-		imageData := make([]byte, header.Size)
-		file.Read(imageData)
-
 		visionAnalysis, err := h.services.Vision.AnalyzeImage(ctx, imageData)
 		if err == nil {
 			analysis = visionAnalysis
 		}
+		if analysis == nil {
+			analysis = make(map[string]interface{})
+		}
 
 		// Detect landmarks
-		landmarks, err := h.services.Vision.DetectLandmarks(ctx, imageData)
+		landmarks, err = h.services.Vision.DetectLandmarks(ctx, imageData)
 		if err == nil {
 			analysis["landmarks"] = landmarks
 		}
@@ -325,6 +872,31 @@ func (h *AITripHandler) AnalyzeImage(c *gin.Context) {
 		}
 	}
 
+	if hasGPS {
+		exifLocation := map[string]interface{}{
+			"latitude":  latitude,
+			"longitude": longitude,
+			"source":    "exif",
+		}
+		if h.services.DataConnector != nil {
+			if destination, err := h.services.DataConnector.ReverseGeocode(ctx, latitude, longitude); err == nil {
+				exifLocation["destination"] = destination
+			}
+		}
+		analysis["exif_location"] = exifLocation
+		analysis["location"] = exifLocation
+	} else if len(landmarks) > 0 {
+		// Fall back to the most confident landmark
+		if location, ok := landmarks[0]["location"].(map[string]interface{}); ok {
+			analysis["location"] = map[string]interface{}{
+				"latitude":    location["latitude"],
+				"longitude":   location["longitude"],
+				"destination": landmarks[0]["name"],
+				"source":      "landmark",
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"analysis":    analysis,
 		"analyzed_at": time.Now(),
@@ -333,33 +905,45 @@ func (h *AITripHandler) AnalyzeImage(c *gin.Context) {
 
 // GetTravelInsights gets travel insights and analytics
 func (h *AITripHandler) GetTravelInsights(c *gin.Context) {
-	userID := c.Query("user_id")
-	ctx := context.Background()
+	userID := currentUserID(c)
+	ctx := context.WithoutCancel(c.Request.Context())
 
 	insights := make(map[string]interface{})
 
-	// Get travel patterns from Vertex AI
-	if h.services.Vertex != nil {
-		// patterns, err := h.services.Vertex.AnalyzeTravelPattern(ctx, ...)
-		// Method signature doesn't match - using mock for now
-		insights["travel_patterns"] = map[string]interface{}{
-			"message": "Travel pattern analysis not yet implemented",
+	// Get travel patterns from the user's trip history
+	if h.services.Firebase != nil && userID != "" {
+		patterns, err := h.services.Firebase.AnalyzeTravelPatterns(ctx, userID)
+		if err != nil {
+			log.Printf("Failed to analyze travel patterns for user %s: %v", userID, err)
+			insights["travel_patterns"] = gin.H{"error": "Travel patterns are temporarily unavailable"}
+		} else {
+			insights["travel_patterns"] = patterns
 		}
 	}
 
-	// Get price trends from BigQuery
+	// Get price trends and trending destinations from BigQuery. Each section
+	// degrades to its own error so one failing query doesn't sink the rest.
 	if h.services.BigQuery != nil {
-		// trends, err := h.services.BigQuery.GetPriceTrends(ctx, "global", 30)
-		// Method not available - using mock for now
-		insights["price_trends"] = map[string]interface{}{
-			"message": "Price trend analysis not yet implemented",
+		if userID != "" {
+			trends, err := h.services.BigQuery.GetUserPriceTrends(ctx, userID, 30)
+			if err != nil {
+				log.Printf("Failed to get price trends for user %s: %v", userID, err)
+				insights["price_trends"] = gin.H{"error": "Price trends are temporarily unavailable"}
+			} else {
+				insights["price_trends"] = trends
+			}
 		}
 
-		// popular, err := h.services.BigQuery.GetTrendingDestinations(ctx, 5)
-		// Method not available - using mock for now
-		insights["trending_destinations"] = []string{
-			"Paris", "Tokyo", "Bali", "London", "New York",
+		trending, err := h.services.BigQuery.GetTrendingDestinations(ctx, 5)
+		if err != nil {
+			log.Printf("Failed to get trending destinations: %v", err)
+			insights["trending_destinations"] = gin.H{"error": "Trending destinations are temporarily unavailable"}
+		} else {
+			insights["trending_destinations"] = trending
 		}
+	} else {
+		insights["price_trends"] = gin.H{"error": "Analytics service unavailable"}
+		insights["trending_destinations"] = gin.H{"error": "Analytics service unavailable"}
 	}
 
 	// Get user insights from Firebase
@@ -379,57 +963,189 @@ func (h *AITripHandler) GetTravelInsights(c *gin.Context) {
 	})
 }
 
-// Helper functions
+// GetTravelPatterns analyzes the authenticated user's trip history
+func (h *AITripHandler) GetTravelPatterns(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if h.services.Firebase == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trip history unavailable"})
+		return
+	}
+
+	patterns, err := h.services.Firebase.AnalyzeTravelPatterns(c.Request.Context(), userID.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze travel patterns"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"travel_patterns": patterns,
+		"generated_at":    time.Now(),
+	})
+}
+
+// PackingListRequest asks for a packing list. Either a forecast or a
+// destination with dates must be given.
+type PackingListRequest struct {
+	Forecast    *services.WeatherForecast `json:"forecast"`
+	Destination string                    `json:"destination"`
+	StartDate   string                    `json:"start_date"`
+	EndDate     string                    `json:"end_date"`
+	Activities  []string                  `json:"activities"`
+	Travelers   int                       `json:"travelers"`
+}
+
+// GeneratePackingList builds a packing checklist from the trip's weather
+// forecast and planned activities
+func (h *AITripHandler) GeneratePackingList(c *gin.Context) {
+	var req PackingListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format", "details": err.Error()})
+		return
+	}
+	if req.Travelers < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "travelers must not be negative"})
+		return
+	}
 
-func (h *AITripHandler) createBasicItinerary(req PlanTripRequest) map[string]interface{} {
-	days := h.calculateDays(req.StartDate, req.EndDate)
-	itinerary := make(map[string]interface{})
+	if h.services.Gemini == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Packing list service unavailable"})
+		return
+	}
 
-	for i := 1; i <= days; i++ {
-		dayKey := fmt.Sprintf("day_%d", i)
-		itinerary[dayKey] = map[string]interface{}{
-			"morning":   fmt.Sprintf("Explore %s attractions", req.Destination),
-			"afternoon": "Local dining and shopping",
-			"evening":   "Relaxation and local entertainment",
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	var forecast services.WeatherForecast
+	switch {
+	case req.Forecast != nil:
+		forecast = *req.Forecast
+	case req.Destination != "":
+		if _, err := h.calculateDays(req.StartDate, req.EndDate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+		if h.services.RAGRetriever == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Weather data unavailable"})
+			return
+		}
+		var err error
+		forecast, err = h.services.RAGRetriever.GetWeatherForecast(ctx, req.Destination, h.parseDate(req.StartDate), h.parseDate(req.EndDate))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to fetch weather forecast"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either forecast or destination with start_date and end_date is required"})
+		return
 	}
 
-	return itinerary
+	items, err := h.services.Gemini.GeneratePackingList(ctx, forecast, req.Activities, req.Travelers)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate packing list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":        items,
+		"generated_at": time.Now(),
+	})
 }
 
-func (h *AITripHandler) getBasicSuggestions(destination string) []string {
-	return []string{
-		fmt.Sprintf("Visit popular attractions in %s", destination),
-		"Try local cuisine and street food",
-		"Take a guided city tour",
-		"Visit local markets and shops",
-		"Experience nightlife and entertainment",
+// Helper functions
+
+// budgetBreakdown splits the trip budget by the request's travel style in
+// the traveler's preferred currency, with the split in the trip's own
+// currency as Original when the two differ. An unknown trip or preferred
+// currency is a *services.UnknownCurrencyError.
+func (h *AITripHandler) budgetBreakdown(ctx context.Context, req PlanTripRequest) (TripBudget, error) {
+	allocation, err := services.BudgetAllocationForStyle(req.TravelStyle)
+	if err != nil {
+		return TripBudget{}, err
+	}
+	rates := h.services.ExchangeRates
+	if rates == nil {
+		rates = services.DefaultExchangeRates()
+	}
+
+	tripCurrency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if tripCurrency == "" {
+		tripCurrency = "USD"
+	}
+	if !rates.Supports(tripCurrency) {
+		return TripBudget{}, &services.UnknownCurrencyError{Currency: tripCurrency}
+	}
+	original := h.calculateBudgetBreakdown(req.Budget, req.Travelers, allocation, tripCurrency)
+
+	preferred := tripCurrency
+	if h.services.Firebase != nil && req.UserID != "" {
+		if profile, err := h.services.Firebase.GetUserProfile(ctx, req.UserID); err == nil && strings.TrimSpace(profile.PreferredCurrency) != "" {
+			preferred = strings.ToUpper(strings.TrimSpace(profile.PreferredCurrency))
+		}
+	}
+	if preferred == tripCurrency {
+		return original, nil
+	}
+	if !rates.Supports(preferred) {
+		return TripBudget{}, &services.UnknownCurrencyError{Currency: preferred}
+	}
+
+	total, err := rates.Convert(req.Budget, tripCurrency, preferred)
+	if err != nil {
+		return TripBudget{}, err
 	}
+	budget := h.calculateBudgetBreakdown(total, req.Travelers, allocation, preferred)
+	budget.Original = &original
+	return budget, nil
 }
 
-func (h *AITripHandler) calculateBudgetBreakdown(total float64, travelers int) TripBudget {
+// calculateBudgetBreakdown splits total by allocation. Food gets whatever
+// the allocation leaves over.
+func (h *AITripHandler) calculateBudgetBreakdown(total float64, travelers int, allocation services.BudgetAllocation, currency string) TripBudget {
 	perPerson := total / float64(travelers)
+	food := 1 - allocation.Accommodation - allocation.Transport - allocation.Activities
+	if food < 0 {
+		food = 0
+	}
 
 	return TripBudget{
 		Total:          total,
-		Accommodation:  total * 0.4,
-		Transportation: total * 0.25,
-		Food:           total * 0.2,
-		Activities:     total * 0.15,
+		Accommodation:  total * allocation.Accommodation,
+		Transportation: total * allocation.Transport,
+		Food:           total * food,
+		Activities:     total * allocation.Activities,
 		Breakdown: map[string]float64{
 			"per_person":     perPerson,
-			"accommodation":  total * 0.4,
-			"transportation": total * 0.25,
-			"food":           total * 0.2,
-			"activities":     total * 0.15,
+			"accommodation":  total * allocation.Accommodation,
+			"transportation": total * allocation.Transport,
+			"food":           total * food,
+			"activities":     total * allocation.Activities,
 		},
+		Currency: currency,
+	}
+}
+
+// tripCurrency returns the currency of the trip's own budget
+func (b TripBudget) tripCurrency() string {
+	if b.Original != nil {
+		return b.Original.Currency
 	}
+	return b.Currency
 }
 
-func (h *AITripHandler) calculateDays(startDate, endDate string) int {
-	start, _ := time.Parse("2006-01-02", startDate)
-	end, _ := time.Parse("2006-01-02", endDate)
-	return int(end.Sub(start).Hours()/24) + 1
+func (h *AITripHandler) calculateDays(startDate, endDate string) (int, error) {
+	start, err := time.Parse("2006-01-02", startDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid start date %q: %v", startDate, err)
+	}
+	end, err := time.Parse("2006-01-02", endDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid end date %q: %v", endDate, err)
+	}
+	return int(end.Sub(start).Hours()/24) + 1, nil
 }
 
 func (h *AITripHandler) parseDate(dateStr string) time.Time {
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestValidateTripMatchesPlanTrip checks that /trips/validate reports an
+// error for exactly the fields PlanTrip would reject
+func TestValidateTripMatchesPlanTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewAITripHandler(&services.Services{})
+	router := gin.New()
+	router.POST("/trips/plan", handler.PlanTrip)
+	router.POST("/trips/validate", handler.ValidateTrip)
+
+	body := `{"destination":"Goa","start_date":"2027-03-01","end_date":"2027-03-05","travelers":2}`
+	tests := []struct {
+		name       string
+		body       string
+		wantFields string
+	}{
+		{"no travelers", strings.Replace(body, `"travelers":2`, `"travelers":0`, 1), "travelers"},
+		{"travelers left out", strings.Replace(body, `,"travelers":2`, ``, 1), "travelers"},
+		{"negative budget", strings.Replace(body, "{", `{"budget":-5,`, 1), "budget"},
+		{"end before start", strings.Replace(body, "2027-03-05", "2027-02-25", 1), "end_date"},
+		{"no destination", strings.Replace(body, `"destination":"Goa",`, ``, 1), "destination"},
+		{"negative ranking weight", strings.Replace(body, "{", `{"ranking_weights":{"rating":-1},`, 1), "ranking_weights"},
+		{"several fields", strings.Replace(strings.Replace(body, `"travelers":2`, `"travelers":0`, 1), "{", `{"travel_style":"lavish",`, 1), "travel_style,travelers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := post(router, "/trips/plan", tt.body)
+			var planned struct {
+				Fields map[string]string `json:"fields"`
+			}
+			if err := json.Unmarshal(plan.Body.Bytes(), &planned); err != nil || plan.Code != http.StatusBadRequest {
+				t.Fatalf("plan = %d %s, want 400 with field errors", plan.Code, plan.Body.String())
+			}
+			planFields := make([]string, 0, len(planned.Fields))
+			for field := range planned.Fields {
+				planFields = append(planFields, field)
+			}
+			sort.Strings(planFields)
+
+			validate := post(router, "/trips/validate", tt.body)
+			var validated struct {
+				Feasible bool                        `json:"feasible"`
+				Issues   []services.FeasibilityIssue `json:"issues"`
+			}
+			if err := json.Unmarshal(validate.Body.Bytes(), &validated); err != nil || validate.Code != http.StatusOK {
+				t.Fatalf("validate = %d %s, want 200", validate.Code, validate.Body.String())
+			}
+			var validateFields []string
+			for _, issue := range validated.Issues {
+				if issue.Severity == services.SeverityError {
+					validateFields = append(validateFields, issue.Field)
+				}
+			}
+			sort.Strings(validateFields)
+
+			if got := strings.Join(planFields, ","); got != tt.wantFields {
+				t.Errorf("plan rejected %s, want %s", got, tt.wantFields)
+			}
+			if got := strings.Join(validateFields, ","); got != tt.wantFields || validated.Feasible {
+				t.Errorf("validate reported errors on %s (feasible %v), want %s", got, validated.Feasible, tt.wantFields)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		w := post(router, "/trips/validate", body)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"feasible":true`) {
+			t.Errorf("validate = %d %s, want feasible", w.Code, w.Body.String())
+		}
+	})
+}
+
+func post(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// upload posts data as the multipart "image" field, or an empty form if data
+// is nil
+func upload(t *testing.T, router http.Handler, path string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if data != nil {
+		part, err := form.CreateFormFile("image", "photo.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(data)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAnalyzeImageValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/analyze-image", NewAITripHandler(&services.Services{}).AnalyzeImage)
+
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	oversized := append(append([]byte{}, pngData.Bytes()...), make([]byte, maxImageUploadSize)...)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		wantStatus int
+	}{
+		{"png", pngData.Bytes(), http.StatusOK},
+		{"no file", nil, http.StatusBadRequest},
+		{"not an image", []byte("%PDF-1.7 pretending to be a photo"), http.StatusUnsupportedMediaType},
+		{"over the limit", oversized, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := upload(t, router, "/analyze-image", tt.data); w.Code != tt.wantStatus {
+				t.Errorf("POST /analyze-image = %d %s, want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DestinationHandler handles destination lookups
+type DestinationHandler struct {
+	services *services.Services
+}
+
+// NewDestinationHandler creates a new destination handler
+func NewDestinationHandler(services *services.Services) *DestinationHandler {
+	return &DestinationHandler{
+		services: services,
+	}
+}
+
+// SuggestDestinations autocompletes a destination from what travelers
+// search for, boosting the caller's own searches. An empty or one-letter q
+// returns trending destinations.
+func (h *DestinationHandler) SuggestDestinations(c *gin.Context) {
+	if h.services.DestinationSuggestService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Destination suggestions not available"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 25 {
+		limit = 10
+	}
+
+	query := c.Query("q")
+	ctx := context.WithoutCancel(c.Request.Context())
+	suggestions, err := h.services.DestinationSuggestService.SuggestDestinations(ctx, currentUserID(c), query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suggest destinations"})
+		return
+	}
+	if suggestions == nil {
+		suggestions = []services.DestinationSuggestion{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":       query,
+		"suggestions": suggestions,
+		"count":       len(suggestions),
+	})
+}
+
+// SuggestNearby suggests attractions near lat, lng, optionally only those
+// open now. radius_km is capped, and tz, an IANA time zone, decides what's
+// open when given.
+func (h *DestinationHandler) SuggestNearby(c *gin.Context) {
+	if h.services.NearbyService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Nearby suggestions not available"})
+		return
+	}
+
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Valid lat and lng are required"})
+		return
+	}
+
+	opts := services.NearbyOptions{}
+	if interests := c.Query("interests"); interests != "" {
+		for _, interest := range strings.Split(interests, ",") {
+			if interest = strings.TrimSpace(interest); interest != "" {
+				opts.Interests = append(opts.Interests, interest)
+			}
+		}
+	}
+	opts.OpenNow, _ = strconv.ParseBool(c.Query("open_now"))
+	if radius := c.Query("radius_km"); radius != "" {
+		radiusKm, err := strconv.ParseFloat(radius, 64)
+		if err != nil || radiusKm <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius_km must be a positive number"})
+			return
+		}
+		opts.RadiusKm = radiusKm
+	}
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 && limit <= 50 {
+		opts.Limit = limit
+	}
+	if tz := c.Query("tz"); tz != "" {
+		location, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone: " + tz})
+			return
+		}
+		opts.Location = location
+	}
+
+	ctx := context.WithoutCancel(c.Request.Context())
+	attractions, err := h.services.NearbyService.SuggestNearbyWithOptions(ctx, lat, lng, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find nearby attractions"})
+		return
+	}
+	if attractions == nil {
+		attractions = []services.Attraction{}
+	}
+
+	response := gin.H{
+		"attractions": attractions,
+		"count":       len(attractions),
+		"radius_km":   services.NearbyRadiusKm(opts.RadiusKm),
+		"open_now":    opts.OpenNow,
+	}
+	if len(attractions) == 0 {
+		response["message"] = "No attractions found nearby; try a larger radius or fewer filters"
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errorStatus maps a service error to its HTTP status by its kind
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrValidation), errors.Is(err, services.ErrUnsupportedLocale):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// respondError writes a service error with the status for its kind. Errors
+// of a known kind are written for the caller, so their message is returned;
+// anything else is a 500 with the fallback message.
+func respondError(c *gin.Context, err error, fallback string) {
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		c.JSON(status, gin.H{"error": fallback})
+		return
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
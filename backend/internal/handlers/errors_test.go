@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    int
+		wantMessage string
+	}{
+		{"validation", fmt.Errorf("bad day: %w", services.ErrValidation), http.StatusBadRequest, "bad day: invalid request"},
+		{"unsupported locale", services.ErrUnsupportedLocale, http.StatusBadRequest, "unsupported locale"},
+		{"permission", &services.TripPermissionError{TripID: "t1", UserID: "u1"}, http.StatusForbidden, "user u1 does not have access to trip t1"},
+		{"not found", services.ErrDayNotFound, http.StatusNotFound, "day not found in itinerary"},
+		{"unavailable", fmt.Errorf("monitoring: %w", services.ErrUnavailable), http.StatusServiceUnavailable, "monitoring: service unavailable"},
+		{"internal error hidden", errors.New("firestore: deadline exceeded"), http.StatusInternalServerError, "Failed to save"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rec)
+			respondError(c, tt.err, "Failed to save")
+
+			var body struct {
+				Error string `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.wantCode || body.Error != tt.wantMessage {
+				t.Errorf("got %d %q, want %d %q", rec.Code, body.Error, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+}
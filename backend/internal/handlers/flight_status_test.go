@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type stubFlightTrips map[string][]string
+
+func (s stubFlightTrips) FindTripsWithFlight(ctx context.Context, flightNumber, bookingRef string) ([]string, error) {
+	return s[bookingRef], nil
+}
+
+func TestFlightStatusWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.GetConfig()
+	secret := cfg.FlightStatusWebhookSecret
+	cfg.FlightStatusWebhookSecret = "flight-secret"
+	defer func() { cfg.FlightStatusWebhookSecret = secret }()
+
+	replanning := services.NewDynamicReplanningService(nil, nil, nil, nil, nil, nil, "")
+	defer replanning.Shutdown(context.Background())
+	replanning.SetFlightTripFinder(stubFlightTrips{"PNR42X": {"trip-1"}})
+	if err := replanning.MonitorTrip(context.Background(), "trip-1"); err != nil {
+		t.Fatalf("MonitorTrip: %v", err)
+	}
+
+	router := gin.New()
+	router.POST("/webhooks/flight-status", NewReplanningHandler(&services.Services{DynamicReplanningService: replanning}).FlightStatusWebhook)
+
+	tests := []struct {
+		name          string
+		body          string
+		secret        string
+		wantCode      int
+		wantStatus    string
+		wantDelivered []string
+	}{
+		{
+			name:          "monitored trip",
+			body:          `{"provider":"aviationstack","flight_number":"AI 101","booking_ref":"PNR42X","status":"delayed","delay_minutes":95}`,
+			secret:        "flight-secret",
+			wantCode:      http.StatusAccepted,
+			wantStatus:    "accepted",
+			wantDelivered: []string{"trip-1"},
+		},
+		{
+			name:          "unknown flight",
+			body:          `{"provider":"aviationstack","flight_number":"6E 202","booking_ref":"PNR00Z","status":"cancelled"}`,
+			secret:        "flight-secret",
+			wantCode:      http.StatusAccepted,
+			wantStatus:    "ignored",
+			wantDelivered: []string{},
+		},
+		{
+			name:     "bad signature",
+			body:     `{"flight_number":"AI 101","booking_ref":"PNR42X","status":"delayed"}`,
+			secret:   "wrong-secret",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/flight-status", strings.NewReader(tt.body))
+			req.Header.Set(services.FlightStatusSignatureHeader, services.SignWebhookPayload(tt.secret, time.Now(), []byte(tt.body)))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantCode, w.Body.String())
+			}
+			if tt.wantCode != http.StatusAccepted {
+				return
+			}
+
+			var resp struct {
+				Status    string   `json:"status"`
+				Delivered []string `json:"delivered_trips"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.Status != tt.wantStatus || strings.Join(resp.Delivered, ",") != strings.Join(tt.wantDelivered, ",") {
+				t.Errorf("response = %+v, want %s delivering to %v", resp, tt.wantStatus, tt.wantDelivered)
+			}
+		})
+	}
+}
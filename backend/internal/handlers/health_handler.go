@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// healthCacheTTL keeps frequent probes from hammering dependencies
+	healthCacheTTL = 10 * time.Second
+	// healthProbeTimeout bounds each dependency probe
+	healthProbeTimeout = 3 * time.Second
+
+	// Dependency states
+	dependencyUp       = "up"
+	dependencyDown     = "down"
+	dependencyDegraded = "degraded"
+)
+
+// DependencyStatus is the probe result for one dependency
+type DependencyStatus struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Message  string `json:"message,omitempty"`
+}
+
+// HealthHandler serves liveness and readiness probes
+type HealthHandler struct {
+	services *services.Services
+
+	mu        sync.Mutex
+	cached    map[string]DependencyStatus
+	checkedAt time.Time
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(services *services.Services) *HealthHandler {
+	return &HealthHandler{services: services}
+}
+
+// Live only confirms the process is serving requests
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready probes the dependencies and returns 503 when a critical one is down.
+// Gemini without an API key only degrades the service since it falls back to
+// mock data.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	dependencies, checkedAt := h.probe(c.Request.Context())
+
+	status := "ok"
+	code := http.StatusOK
+	for _, dependency := range dependencies {
+		if dependency.Status == dependencyUp {
+			continue
+		}
+		if dependency.Critical {
+			status = "unavailable"
+			code = http.StatusServiceUnavailable
+			break
+		}
+		status = dependencyDegraded
+	}
+
+	c.JSON(code, gin.H{
+		"status":       status,
+		"message":      "AuraTravel API is running",
+		"dependencies": dependencies,
+		"checked_at":   checkedAt,
+	})
+}
+
+// probe returns cached results when they're fresh enough
+func (h *HealthHandler) probe(ctx context.Context) (map[string]DependencyStatus, time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached != nil && time.Since(h.checkedAt) < healthCacheTTL {
+		return h.cached, h.checkedAt
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	h.cached = checkDependencies(ctx, h.services)
+	h.checkedAt = time.Now()
+	return h.cached, h.checkedAt
+}
+
+func checkDependencies(ctx context.Context, svc *services.Services) map[string]DependencyStatus {
+	dependencies := make(map[string]DependencyStatus)
+
+	firestore := DependencyStatus{Status: dependencyUp, Critical: true}
+	switch {
+	case svc == nil || svc.Firebase == nil:
+		firestore.Status = dependencyDown
+		firestore.Message = "Firebase failed to initialize"
+	default:
+		if err := svc.Firebase.Ping(ctx); err != nil {
+			// The response is public, so the error's details only go to the log
+			log.Printf("Health check: Firestore ping failed: %v", err)
+			firestore.Status = dependencyDown
+			firestore.Message = "Firestore is unreachable"
+		}
+	}
+	dependencies["firestore"] = firestore
+
+	gemini := DependencyStatus{Status: dependencyUp}
+	switch {
+	case svc == nil || svc.Gemini == nil:
+		gemini.Status = dependencyDown
+		gemini.Message = "Gemini failed to initialize"
+	case !svc.Gemini.HasAPIKey():
+		gemini.Status = dependencyDegraded
+		gemini.Message = "GEMINI_API_KEY not set, serving mock responses"
+	}
+	dependencies["gemini"] = gemini
+
+	return dependencies
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHealthDegraded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := NewHealthHandler(&services.Services{})
+	router := gin.New()
+	router.GET("/health/live", handler.Live)
+	router.GET("/health/ready", handler.Ready)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("live = %d, want 200 with every dependency down", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	var body struct {
+		Status       string                      `json:"status"`
+		Dependencies map[string]DependencyStatus `json:"dependencies"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusServiceUnavailable || body.Status != "unavailable" {
+		t.Errorf("ready = %d %q, want 503 unavailable", w.Code, body.Status)
+	}
+
+	want := map[string]DependencyStatus{
+		"firestore": {Status: dependencyDown, Critical: true, Message: "Firebase failed to initialize"},
+		"gemini":    {Status: dependencyDown, Message: "Gemini failed to initialize"},
+	}
+	if len(body.Dependencies) != len(want) {
+		t.Errorf("dependencies = %v, want %v", body.Dependencies, want)
+	}
+	for name, status := range want {
+		if got := body.Dependencies[name]; got != status {
+			t.Errorf("%s = %+v, want %+v", name, got, status)
+		}
+	}
+}
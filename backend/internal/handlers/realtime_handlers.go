@@ -1,7 +1,13 @@
 package handlers
 
 import (
+	"auratravel-backend/internal/config"
 	"auratravel-backend/internal/services"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"time"
@@ -13,6 +19,7 @@ import (
 type NotificationHandler struct {
 	notificationService *services.NotificationService
 	localizationService *services.LocalizationService
+	firebase            *services.FirebaseService
 }
 
 // NewNotificationHandler creates a new notification handler
@@ -20,16 +27,19 @@ func NewNotificationHandler(services *services.Services) *NotificationHandler {
 	return &NotificationHandler{
 		notificationService: services.NotificationService,
 		localizationService: services.LocalizationService,
+		firebase:            services.Firebase,
 	}
 }
 
 // RegisterDevice registers a device token for push notifications
 func (h *NotificationHandler) RegisterDevice(c *gin.Context) {
+	userID := currentUserID(c)
 	var req struct {
-		UserID      string `json:"userId" binding:"required"`
 		DeviceToken string `json:"deviceToken" binding:"required"`
 		Platform    string `json:"platform" binding:"required"`
 		Locale      string `json:"locale"`
+		TripID      string `json:"tripId"`
+		Timezone    string `json:"timezone"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -42,20 +52,36 @@ func (h *NotificationHandler) RegisterDevice(c *gin.Context) {
 		req.Locale = "en"
 	}
 
-	// Adjusted to match service signature: RegisterDeviceToken(ctx, userID, deviceToken, platform)
-	err := h.notificationService.RegisterDeviceToken(c.Request.Context(), req.UserID, req.DeviceToken, req.Platform)
+	// Only travelers on the trip may follow its updates
+	var role string
+	if req.TripID != "" {
+		var ok bool
+		if _, role, ok = authorizeTripRole(c, h.firebase, req.TripID, services.TripActionView); !ok {
+			return
+		}
+	}
+
+	err := h.notificationService.RegisterDeviceToken(c.Request.Context(), userID, req.DeviceToken, req.Platform, req.Timezone)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register device token"})
+		respondError(c, err, "Failed to register device token")
 		return
 	}
 
+	// Follow trip updates through the topic for the traveler's role
+	if req.TripID != "" {
+		if err := h.notificationService.SubscribeDeviceToTripTopic(c.Request.Context(), req.TripID, userID, role, req.DeviceToken); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Device registered but failed to subscribe to trip updates"})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Device token registered successfully",
 	})
 }
 
-// SendNotification sends a push notification
+// SendNotification sends a push notification to the caller's own devices
 func (h *NotificationHandler) SendNotification(c *gin.Context) {
 	var req services.NotificationRequest
 
@@ -63,8 +89,13 @@ func (h *NotificationHandler) SendNotification(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.UserID = currentUserID(c)
 
-	result := h.notificationService.SendNotification(c.Request.Context(), &req)
+	result, err := h.notificationService.SendNotification(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.JSON(http.StatusOK, result)
 }
 
@@ -84,20 +115,29 @@ func (h *NotificationHandler) SendWeatherAlert(c *gin.Context) {
 		return
 	}
 
-	// Adjusted to match service signature: SendWeatherAlert(ctx, userID, tripID, alertData)
-	alertData := map[string]interface{}{
-		"weatherCondition": req.WeatherCondition,
-		"location":         req.Location,
-		"severity":         req.Severity,
-		"message":          req.Message,
+	if req.Severity == "" {
+		req.Severity = "watch"
+	}
+
+	alert := services.WeatherAlert{
+		AlertType:     req.WeatherCondition,
+		Severity:      req.Severity,
+		StartTime:     time.Now(),
+		EndTime:       time.Now().Add(6 * time.Hour),
+		Description:   req.Message,
+		AffectedAreas: []string{req.Location},
+	}
+	result, err := h.notificationService.SendWeatherAlert(c.Request.Context(), userID, req.TripID, alert)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
 	}
-	result := h.notificationService.SendWeatherAlert(c.Request.Context(), userID, req.TripID, alertData)
 	c.JSON(http.StatusOK, result)
 }
 
-// SendTripUpdate sends a trip update notification
+// SendTripUpdate sends a trip update notification to everyone on the trip.
+// Only travelers who can edit the trip may send one.
 func (h *NotificationHandler) SendTripUpdate(c *gin.Context) {
-	userID := c.Param("userId")
 	var req struct {
 		TripID     string                 `json:"tripId" binding:"required"`
 		UpdateType string                 `json:"updateType" binding:"required"`
@@ -112,42 +152,86 @@ func (h *NotificationHandler) SendTripUpdate(c *gin.Context) {
 		return
 	}
 
-	// Adjusted to match service signature: SendTripUpdateNotification(ctx, userID, tripID)
-	result := h.notificationService.SendTripUpdateNotification(c.Request.Context(), userID, req.TripID)
-	c.JSON(http.StatusOK, result)
+	if _, ok := authorizeTrip(c, h.firebase, req.TripID, services.TripActionEdit); !ok {
+		return
+	}
+
+	data := map[string]string{
+		"trip_id":     req.TripID,
+		"update_type": req.UpdateType,
+	}
+	for key, value := range req.CustomData {
+		data[key] = fmt.Sprintf("%v", value)
+	}
+
+	results, err := h.notificationService.SendToTrip(c.Request.Context(), &services.NotificationRequest{
+		TripID:    req.TripID,
+		Type:      services.ItineraryUpdate,
+		Priority:  services.PriorityHigh,
+		Title:     req.Title,
+		Body:      req.Message,
+		Data:      data,
+		ActionURL: req.ActionURL,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
 }
 
 // GetUserNotifications retrieves user's notification history
 func (h *NotificationHandler) GetUserNotifications(c *gin.Context) {
-	_ = c.Param("userId")
+	userID := c.Param("userId")
 	limitStr := c.DefaultQuery("limit", "50")
 	offsetStr := c.DefaultQuery("offset", "0")
 
 	limit, err := strconv.Atoi(limitStr)
-	if err != nil {
+	if err != nil || limit <= 0 || limit > 100 {
 		limit = 50
 	}
 
 	offset, err := strconv.Atoi(offsetStr)
-	if err != nil {
+	if err != nil || offset < 0 {
 		offset = 0
 	}
 
-	// This would typically fetch from a database
-	// For now, return a placeholder response
+	notifications, total, err := h.notificationService.GetNotificationHistory(c.Request.Context(), userID, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"notifications": []interface{}{},
-		"total":         0,
+		"notifications": notifications,
+		"total":         total,
 		"limit":         limit,
 		"offset":        offset,
 	})
 }
 
+// MarkNotificationRead marks a notification as read
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	userID := c.Param("userId")
+	notificationID := c.Param("notificationId")
+
+	if err := h.notificationService.MarkRead(c.Request.Context(), userID, notificationID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Notification marked as read",
+	})
+}
+
 // ReplanningHandler handles dynamic replanning HTTP requests
 type ReplanningHandler struct {
 	replanningService   *services.DynamicReplanningService
 	notificationService *services.NotificationService
 	localizationService *services.LocalizationService
+	firebase            *services.FirebaseService
 }
 
 // NewReplanningHandler creates a new replanning handler
@@ -156,41 +240,77 @@ func NewReplanningHandler(services *services.Services) *ReplanningHandler {
 		replanningService:   services.DynamicReplanningService,
 		notificationService: services.NotificationService,
 		localizationService: services.LocalizationService,
+		firebase:            services.Firebase,
 	}
 }
 
-// StartMonitoring starts monitoring a trip for replanning triggers
+// StartMonitoring starts monitoring a trip for replanning triggers. The
+// monitor checks the trip's stored itinerary, so the request has no body.
 func (h *ReplanningHandler) StartMonitoring(c *gin.Context) {
-	// tripID := c.Param("tripId")
+	tripID := c.Param("tripId")
+	td, ok := authorizeTrip(c, h.firebase, tripID, services.TripActionEdit)
+	if !ok {
+		return
+	}
 
-	var req struct {
-		UserID      string                 `json:"userId" binding:"required"`
-		Itinerary   map[string]interface{} `json:"itinerary" binding:"required"`
-		Preferences map[string]interface{} `json:"preferences,omitempty"`
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
+	if services.MonitoringEnded(td, time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Trip is already over"})
+		return
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := h.replanningService.MonitorTrip(c.Request.Context(), tripID); err != nil {
+		respondError(c, err, "Failed to start monitoring")
 		return
 	}
 
-	// Method StartMonitoring does not exist; placeholder for implementation or remove if not needed
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "StartMonitoring not implemented"})
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"tripId":     tripID,
+		"monitoring": true,
+	})
 }
 
 // StopMonitoring stops monitoring a trip
 func (h *ReplanningHandler) StopMonitoring(c *gin.Context) {
-	// tripID := c.Param("tripId")
+	tripID := c.Param("tripId")
+	if _, ok := authorizeTrip(c, h.firebase, tripID, services.TripActionEdit); !ok {
+		return
+	}
+
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
 
-	// Method StopMonitoring does not exist; placeholder for implementation or remove if not needed
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "StopMonitoring not implemented"})
+	h.replanningService.StopMonitoring(tripID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"tripId":     tripID,
+		"monitoring": false,
+	})
 }
 
 // GetMonitoringStatus gets the monitoring status for a trip
 func (h *ReplanningHandler) GetMonitoringStatus(c *gin.Context) {
+	tripID := c.Param("tripId")
+	if _, ok := authorizeTrip(c, h.firebase, tripID, services.TripActionEdit); !ok {
+		return
+	}
+
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
 
-	// Method GetMonitoringStatus does not exist; placeholder for implementation or remove if not needed
-	c.JSON(http.StatusNotImplemented, gin.H{"error": "GetMonitoringStatus not implemented"})
+	c.JSON(http.StatusOK, gin.H{
+		"tripId":     tripID,
+		"monitoring": h.replanningService.IsMonitoring(tripID),
+	})
 }
 
 // TriggerReplanning manually triggers replanning for a trip
@@ -221,7 +341,6 @@ func (h *ReplanningHandler) AcceptReplanningOption(c *gin.Context) {
 
 	var req struct {
 		OptionID string `json:"optionId" binding:"required"`
-		UserID   string `json:"userId" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -238,10 +357,229 @@ func (h *ReplanningHandler) AcceptReplanningOption(c *gin.Context) {
 	})
 }
 
+// AdaptDayForWeather proposes a revision of one day of a trip for its
+// forecast, swapping outdoor activities for indoor ones when bad weather is
+// expected. Nothing is saved; ConfirmWeatherAdaptation applies the proposal.
+func (h *ReplanningHandler) AdaptDayForWeather(c *gin.Context) {
+	var req struct {
+		Day int `json:"day" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
+	trip, ok := authorizeTrip(c, h.firebase, c.Param("tripId"), services.TripActionEdit)
+	if !ok {
+		return
+	}
+
+	adaptation, err := h.replanningService.AdaptDayForWeather(c.Request.Context(), trip, req.Day)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDayNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Trip has no day %d", req.Day)})
+		case errors.Is(err, services.ErrNoForecast):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("No forecast is available for day %d yet", req.Day)})
+		default:
+			respondError(c, err, "Failed to check the forecast")
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, adaptation)
+}
+
+// ConfirmWeatherAdaptation saves a revised day proposed by
+// AdaptDayForWeather
+func (h *ReplanningHandler) ConfirmWeatherAdaptation(c *gin.Context) {
+	var req struct {
+		Day        int                    `json:"day" binding:"required,min=1"`
+		RevisedDay map[string]interface{} `json:"revised_day" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
+	trip, ok := authorizeTrip(c, h.firebase, c.Param("tripId"), services.TripActionEdit)
+	if !ok {
+		return
+	}
+
+	if err := h.replanningService.ApplyWeatherAdaptation(c.Request.Context(), trip, req.Day, req.RevisedDay); err != nil {
+		if errors.Is(err, services.ErrDayNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Trip has no day %d", req.Day)})
+			return
+		}
+		respondTripUpdateError(c, err, "Failed to save the revised day")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"trip_id": trip.ID,
+		"day":     req.Day,
+	})
+}
+
+// RegisterWebhook registers a URL to receive signed replanning events for
+// one of the user's trips, or all of them when trip_id is omitted
+func (h *ReplanningHandler) RegisterWebhook(c *gin.Context) {
+	var req struct {
+		URL    string `json:"url" binding:"required"`
+		TripID string `json:"trip_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
+
+	allowHTTP := config.GetConfig().Environment == "development"
+	if err := services.ValidateWebhookURL(c.Request.Context(), req.URL, allowHTTP); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID := currentUserID(c)
+	if req.TripID != "" {
+		if h.firebase == nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trip storage not available"})
+			return
+		}
+		trip, err := h.firebase.GetTrip(c.Request.Context(), req.TripID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		if trip.UserID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			return
+		}
+	}
+
+	webhook, err := h.replanningService.RegisterWebhook(c.Request.Context(), userID, req.TripID, req.URL)
+	if err != nil {
+		respondError(c, err, "Failed to register webhook")
+		return
+	}
+
+	// The secret is only ever returned here
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+// ListWebhooks lists the user's replanning webhooks
+func (h *ReplanningHandler) ListWebhooks(c *gin.Context) {
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
+
+	webhooks, err := h.replanningService.ListWebhooks(c.Request.Context(), currentUserID(c))
+	if err != nil {
+		respondError(c, err, "Failed to list webhooks")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": webhooks,
+		"count":    len(webhooks),
+	})
+}
+
+// DeleteWebhook removes one of the user's replanning webhooks
+func (h *ReplanningHandler) DeleteWebhook(c *gin.Context) {
+	if h.replanningService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Replanning service not available"})
+		return
+	}
+
+	deleted, err := h.replanningService.DeleteWebhook(c.Request.Context(), currentUserID(c), c.Param("webhookId"))
+	if err != nil {
+		respondError(c, err, "Failed to delete webhook")
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// maxFlightStatusSize caps the flight status updates accepted
+const maxFlightStatusSize = 64 << 10
+
+// FlightStatusWebhook receives flight status pushed by airlines and
+// aggregators. Signed updates are matched to the trips booked on the
+// flight and fed to their monitors; updates for flights no trip has are
+// acknowledged and ignored, so providers don't retry them.
+func (h *ReplanningHandler) FlightStatusWebhook(c *gin.Context) {
+	secret := config.GetConfig().FlightStatusWebhookSecret
+	if h.replanningService == nil || secret == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Flight status updates are not accepted"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxFlightStatusSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read flight status"})
+		return
+	}
+	if len(body) > maxFlightStatusSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Flight status is too large"})
+		return
+	}
+	signature := c.GetHeader(services.FlightStatusSignatureHeader)
+	if err := services.VerifyWebhookSignature(secret, signature, body, services.DefaultWebhookTolerance, time.Now()); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var event services.FlightStatusEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid flight status: " + err.Error()})
+		return
+	}
+	result, err := h.replanningService.IngestFlightStatus(c.Request.Context(), event)
+	if err != nil {
+		respondError(c, err, "Failed to process flight status")
+		return
+	}
+
+	status := "accepted"
+	if len(result.Matched) == 0 {
+		status = "ignored"
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":          status,
+		"matched_trips":   result.Matched,
+		"delivered_trips": result.Delivered,
+	})
+}
+
 // DeliveryHandler handles itinerary delivery HTTP requests
 type DeliveryHandler struct {
 	deliveryService     *services.ItineraryDeliveryService
 	localizationService *services.LocalizationService
+	firebase            *services.FirebaseService
 }
 
 // NewDeliveryHandler creates a new delivery handler
@@ -249,6 +587,7 @@ func NewDeliveryHandler(services *services.Services) *DeliveryHandler {
 	return &DeliveryHandler{
 		deliveryService:     services.ItineraryDeliveryService,
 		localizationService: services.LocalizationService,
+		firebase:            services.Firebase,
 	}
 }
 
@@ -260,10 +599,101 @@ func (h *DeliveryHandler) DeliverItinerary(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if req.TripID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "trip_id is required"})
+		return
+	}
+
+	// Anyone on the trip can download it; sending it to others takes an
+	// editor
+	action := services.TripActionEdit
+	if req.Method == services.MethodDownload {
+		action = services.TripActionView
+	}
+	if _, ok := authorizeTrip(c, h.firebase, req.TripID, action); !ok {
+		return
+	}
+	req.UserID = currentUserID(c)
 
 	result, err := h.deliveryService.GenerateAndDeliverItinerary(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deliver itinerary"})
+		respondError(c, err, "Failed to deliver itinerary")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// PreviewDelivery renders an itinerary in the requested format and returns
+// it inline, without storing or sending it
+func (h *DeliveryHandler) PreviewDelivery(c *gin.Context) {
+	var req services.DeliveryRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.TripID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "trip_id is required"})
+		return
+	}
+
+	contentType, ok := services.PreviewContentType(req.Format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of pdf, html, json"})
+		return
+	}
+
+	if _, ok := authorizeTrip(c, h.firebase, req.TripID, services.TripActionView); !ok {
+		return
+	}
+
+	// Previews are always rendered for the caller, never another user
+	req.UserID = currentUserID(c)
+
+	fileData, fileName, err := h.deliveryService.PreviewItinerary(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Preview generation timed out"})
+			return
+		}
+		respondError(c, err, "Failed to generate preview")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", fileName))
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, contentType, fileData)
+}
+
+// maxICSImportSize caps the calendar files accepted for import
+const maxICSImportSize = 1 << 20
+
+// ImportICS updates a trip's itinerary from an edited copy of its exported
+// calendar, sent as the raw request body
+func (h *DeliveryHandler) ImportICS(c *gin.Context) {
+	tripID := c.Param("tripId")
+	if _, ok := authorizeTrip(c, h.firebase, tripID, services.TripActionEdit); !ok {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxICSImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read calendar"})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Calendar body is required"})
+		return
+	}
+	if len(data) > maxICSImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Calendar is too large"})
+		return
+	}
+
+	result, err := h.deliveryService.ImportItineraryFromICS(c.Request.Context(), tripID, currentUserID(c), data)
+	if err != nil {
+		respondError(c, err, "Failed to import calendar")
 		return
 	}
 
@@ -340,7 +770,12 @@ func (h *LocalizationHandler) LocalizeContent(c *gin.Context) {
 
 	switch req.ContentType {
 	case "itinerary":
-		result, err = h.localizationService.LocalizeItinerary(c.Request.Context(), req.Content, req.TargetLocale)
+		zone, zoneErr := h.localizationService.DestinationZone(c.Request.Context(), &req)
+		if zoneErr != nil {
+			respondError(c, zoneErr, "Failed to localize content")
+			return
+		}
+		result, err = h.localizationService.LocalizeItineraryIn(c.Request.Context(), req.Content, req.TargetLocale, zone)
 	case "notification":
 		result, err = h.localizationService.LocalizeNotification(c.Request.Context(), &req)
 	default:
@@ -349,7 +784,7 @@ func (h *LocalizationHandler) LocalizeContent(c *gin.Context) {
 	}
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to localize content"})
+		respondError(c, err, "Failed to localize content")
 		return
 	}
 
@@ -376,7 +811,7 @@ func (h *LocalizationHandler) GetGeminiPrompt(c *gin.Context) {
 
 	prompt, err := h.localizationService.GetLocalizedGeminiPrompt(locale, promptType, variables)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get localized prompt"})
+		respondError(c, err, "Failed to get localized prompt")
 		return
 	}
 
@@ -390,8 +825,8 @@ func (h *LocalizationHandler) GetGeminiPrompt(c *gin.Context) {
 
 // SetUserLocalePreference sets a user's locale preference
 func (h *LocalizationHandler) SetUserLocalePreference(c *gin.Context) {
+	userID := currentUserID(c)
 	var req struct {
-		UserID string `json:"userId" binding:"required"`
 		Locale string `json:"locale" binding:"required"`
 	}
 
@@ -400,16 +835,16 @@ func (h *LocalizationHandler) SetUserLocalePreference(c *gin.Context) {
 		return
 	}
 
-	err := h.localizationService.SetUserLocalePreference(c.Request.Context(), req.UserID, req.Locale)
+	err := h.localizationService.SetUserLocalePreference(c.Request.Context(), userID, req.Locale)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set locale preference"})
+		respondError(c, err, "Failed to set locale preference")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Locale preference updated successfully",
-		"userId":  req.UserID,
+		"userId":  userID,
 		"locale":  req.Locale,
 	})
 }
@@ -420,7 +855,7 @@ func (h *LocalizationHandler) GetUserLocalePreference(c *gin.Context) {
 
 	locale, err := h.localizationService.GetUserLocalePreference(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get locale preference"})
+		respondError(c, err, "Failed to get locale preference")
 		return
 	}
 
@@ -443,7 +878,7 @@ func (h *LocalizationHandler) FormatCurrency(c *gin.Context) {
 
 	formatted, err := h.localizationService.FormatCurrency(amount, locale)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to format currency"})
+		respondError(c, err, "Failed to format currency")
 		return
 	}
 
@@ -467,13 +902,13 @@ func (h *LocalizationHandler) FormatDateTime(c *gin.Context) {
 
 	formattedDate, err := h.localizationService.FormatDate(datetime, locale)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to format date"})
+		respondError(c, err, "Failed to format date")
 		return
 	}
 
 	formattedTime, err := h.localizationService.FormatTime(datetime, locale)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to format time"})
+		respondError(c, err, "Failed to format time")
 		return
 	}
 
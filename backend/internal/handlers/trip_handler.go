@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"auratravel-backend/internal/models"
@@ -34,8 +39,6 @@ type CreateTripRequest struct {
 
 // CreateTrip creates a new trip with AI-powered itinerary generation
 func (h *TripHandler) CreateTrip(c *gin.Context) {
-	// (userID, exists) removed: mock data does not use them
-
 	var req CreateTripRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -50,7 +53,7 @@ func (h *TripHandler) CreateTrip(c *gin.Context) {
 
 	trip := &models.Trip{
 		ID:          time.Now().Format("20060102150405"),
-		UserID:      "mock-user-id", // TODO: Replace with actual user ID from context
+		UserID:      currentUserID(c),
 		Destination: req.Destination,
 		StartDate:   req.StartDate,
 		EndDate:     req.EndDate,
@@ -73,10 +76,11 @@ func (h *TripHandler) CreateTrip(c *gin.Context) {
 	}
 	fb := h.services.Firebase
 	ctx := c.Request.Context()
-	if err := fb.SaveTrip(ctx, tripData); err != nil {
+	if err := fb.SaveTrip(ctx, &tripData); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create trip in Firestore"})
 		return
 	}
+	trip.ShareCode = tripData.ShareCode
 
 	// Generate AI-powered itinerary using Gemini (mock)
 	itinerary, err := h.services.Gemini.GenerateItinerary(c, services.ItineraryRequest{
@@ -102,13 +106,58 @@ func (h *TripHandler) CreateTrip(c *gin.Context) {
 	})
 }
 
+// maxTripImportSize caps the uploads accepted by ImportTrips
+const maxTripImportSize = 5 << 20
+
+// ImportTrips creates trips in bulk from a JSON array or, with a text/csv
+// content type, a CSV upload. Each row is validated and imported on its own,
+// and the response reports every row's outcome.
+func (h *TripHandler) ImportTrips(c *gin.Context) {
+	fb := h.services.Firebase
+	if fb == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trip storage not available"})
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxTripImportSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import"})
+		return
+	}
+	if len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import body is required"})
+		return
+	}
+	if len(data) > maxTripImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import is too large"})
+		return
+	}
+
+	var rows []services.TripImportRow
+	if strings.Contains(c.ContentType(), "csv") {
+		rows, err = services.ParseTripImportCSV(data)
+	} else {
+		rows, err = services.ParseTripImportJSON(data)
+	}
+	if err != nil {
+		respondError(c, err, "Failed to read import")
+		return
+	}
+
+	summary, err := fb.ImportTrips(c.Request.Context(), currentUserID(c), rows, time.Now())
+	if err != nil {
+		respondError(c, err, "Failed to import trips")
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
 // GetTrips gets user trips
 func (h *TripHandler) GetTrips(c *gin.Context) {
-	// (userID, exists) removed: mock data does not use them
-
 	fb := h.services.Firebase
 	ctx := c.Request.Context()
-	userID := "mock-user-id" // TODO: Replace with actual user ID from context
+	userID := currentUserID(c)
 	tripDatas, err := fb.GetUserTrips(ctx, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trips from Firestore"})
@@ -125,6 +174,8 @@ func (h *TripHandler) GetTrips(c *gin.Context) {
 			Status:      td.Status,
 			TotalBudget: td.Budget,
 			Travelers:   td.Travelers,
+			IsPublic:    td.IsPublic,
+			ShareCode:   td.ShareCode,
 			CreatedAt:   toTime(td.CreatedAt),
 			UpdatedAt:   toTime(td.UpdatedAt),
 		})
@@ -137,28 +188,16 @@ func (h *TripHandler) GetTrips(c *gin.Context) {
 
 // GetTrip gets a specific trip with detailed itinerary
 func (h *TripHandler) GetTrip(c *gin.Context) {
-	// (userID, exists, id) removed: mock data does not use them
-	tripID := c.Param("id")
+	tripID := c.Param("tripId")
 
-	fb := h.services.Firebase
-	ctx := c.Request.Context()
-	td, err := fb.GetTrip(ctx, tripID)
-	if err != nil || td == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+	td, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionView)
+	if !ok {
 		return
 	}
-	trip := models.Trip{
-		ID:          td.ID,
-		UserID:      td.UserID,
-		Destination: td.Destination,
-		StartDate:   toTime(td.StartDate),
-		EndDate:     toTime(td.EndDate),
-		Status:      td.Status,
-		TotalBudget: td.Budget,
-		Travelers:   td.Travelers,
-		CreatedAt:   toTime(td.CreatedAt),
-		UpdatedAt:   toTime(td.UpdatedAt),
-	}
+	trip := tripModel(td)
+	h.markTripViewed(c.Request.Context(), tripID, currentUserID(c), time.Now())
+	c.Header("ETag", tripETag(td.Version))
+
 	// Mock recommendations and visual insights
 	recommendations := []string{"Mock recommendation 1", "Mock recommendation 2"}
 	visualInsights := map[string]interface{}{"insight": "Mock visual insight"}
@@ -169,16 +208,83 @@ func (h *TripHandler) GetTrip(c *gin.Context) {
 	})
 }
 
-// UpdateTrip updates a trip
+// GetTripChanges lists the activities, bookings and replans of a trip
+// created or modified since a time: the "since" query parameter (RFC 3339),
+// or when the caller last viewed the trip. Viewing the changes counts as
+// viewing the trip.
+func (h *TripHandler) GetTripChanges(c *gin.Context) {
+	tripID := c.Param("tripId")
+	userID := currentUserID(c)
+	if h.services.Firebase == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trip storage not available"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	var since time.Time
+	if value := c.Query("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 time, like 2024-05-01T10:00:00Z"})
+			return
+		}
+		since = parsed
+	} else {
+		lastViewed, err := h.services.Firebase.TripLastViewed(ctx, tripID, userID)
+		if err != nil {
+			log.Printf("Failed to get last view of trip %s: %v", tripID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trip changes"})
+			return
+		}
+		since = lastViewed
+	}
+
+	changes, err := h.services.Firebase.GetTripChanges(ctx, tripID, userID, since)
+	if err != nil {
+		respondError(c, err, "Failed to get trip changes")
+		return
+	}
+	h.markTripViewed(ctx, tripID, userID, changes.Until)
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// markTripViewed records that the user has seen the trip as of at. It only
+// moves "changes since last viewed" along, so failures are just logged.
+func (h *TripHandler) markTripViewed(ctx context.Context, tripID, userID string, at time.Time) {
+	if h.services.Firebase == nil || userID == "" {
+		return
+	}
+	if err := h.services.Firebase.MarkTripViewed(ctx, tripID, userID, at); err != nil {
+		log.Printf("Failed to record view of trip %s: %v", tripID, err)
+	}
+}
+
+// UpdateTrip replaces a trip's details and regenerates its itinerary. It
+// only applies to the trip version the client read, so a write based on a
+// stale copy is a 409 rather than a lost update.
 func (h *TripHandler) UpdateTrip(c *gin.Context) {
-	// (userID, exists, id) removed: mock data does not use them
-	tripID := c.Param("id")
+	tripID := c.Param("tripId")
 
-	var req CreateTripRequest
+	var req UpdateTripRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !req.EndDate.After(req.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "end_date must be after start_date"})
+		return
+	}
+
+	baseVersion, ok := requestVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	td, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionEdit)
+	if !ok {
+		return
+	}
 
 	fb := h.services.Firebase
 	ctx := c.Request.Context()
@@ -191,10 +297,15 @@ func (h *TripHandler) UpdateTrip(c *gin.Context) {
 		"status":      "updated",
 		"updated_at":  time.Now(),
 	}
-	if err := fb.UpdateTrip(ctx, tripID, updates); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update trip in Firestore"})
+	// The update only applies to the version the client read
+	version, err := fb.UpdateTripAtVersion(ctx, tripID, baseVersion, updates)
+	if err != nil {
+		respondTripUpdateError(c, err, "Failed to update trip in Firestore")
 		return
 	}
+	c.Header("ETag", tripETag(version))
+	td.Destination = req.Destination
+	h.notifyTripUpdated(ctx, td)
 	newItinerary, err := h.services.Gemini.GenerateItinerary(c, services.ItineraryRequest{
 		Destination: req.Destination,
 		StartDate:   req.StartDate.Format("2006-01-02"),
@@ -212,22 +323,495 @@ func (h *TripHandler) UpdateTrip(c *gin.Context) {
 	})
 }
 
-// DeleteTrip deletes a trip
+// UpdateTripRequest replaces a trip's details. Version is the trip version
+// the client read; the If-Match header can carry it instead.
+type UpdateTripRequest struct {
+	CreateTripRequest
+	Version *int64 `json:"version"`
+}
+
+// PatchTripRequest is a set of field-level changes to a trip. Version is
+// the trip version the changes were made against; the If-Match header can
+// carry it instead.
+type PatchTripRequest struct {
+	Version *int64                 `json:"version"`
+	Changes map[string]interface{} `json:"changes" binding:"required"`
+}
+
+// PatchTrip applies field-level changes to a trip, like a new budget or a
+// single itinerary day ("itinerary.day_2"). The changes only apply if the
+// trip is still at the version the client read; otherwise it's a 409 with
+// the current trip, so the client can merge its changes and retry.
+func (h *TripHandler) PatchTrip(c *gin.Context) {
+	tripID := c.Param("tripId")
+
+	var req PatchTripRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	baseVersion, ok := requestVersion(c, req.Version)
+	if !ok {
+		return
+	}
+
+	td, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionEdit)
+	if !ok {
+		return
+	}
+
+	version, err := h.services.Firebase.PatchTrip(c.Request.Context(), tripID, baseVersion, req.Changes)
+	if err != nil {
+		respondTripUpdateError(c, err, "Failed to update trip")
+		return
+	}
+	h.notifyTripUpdated(c.Request.Context(), td)
+
+	c.Header("ETag", tripETag(version))
+	c.JSON(http.StatusOK, gin.H{
+		"trip_id": tripID,
+		"version": version,
+		"changes": req.Changes,
+	})
+}
+
+// DeleteTrip soft-deletes a trip. It can be restored within
+// services.TripRestoreWindow.
 func (h *TripHandler) DeleteTrip(c *gin.Context) {
-	// (userID, exists, id) removed: mock data does not use them
-	tripID := c.Param("id")
+	tripID := c.Param("tripId")
+	if _, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionManage); !ok {
+		return
+	}
 	fb := h.services.Firebase
 	ctx := c.Request.Context()
 	if err := fb.DeleteTrip(ctx, tripID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete trip from Firestore"})
 		return
 	}
+	h.setTripSearchStatus(ctx, tripID, "deleted")
+	if h.services.DynamicReplanningService != nil {
+		h.services.DynamicReplanningService.StopMonitoring(tripID)
+	}
+	if h.services.NotificationService != nil {
+		if err := h.services.NotificationService.UnsubscribeTripDevices(ctx, tripID); err != nil {
+			log.Printf("Failed to unsubscribe devices from deleted trip %s: %v", tripID, err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Trip deleted successfully",
+		"message":          "Trip deleted successfully",
+		"trip_id":          tripID,
+		"restorable_until": time.Now().Add(services.TripRestoreWindow),
+	})
+}
+
+// RestoreTrip restores a soft-deleted trip within the restore window
+func (h *TripHandler) RestoreTrip(c *gin.Context) {
+	tripID := c.Param("tripId")
+	if _, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionManage); !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	td, err := h.services.Firebase.RestoreTrip(ctx, tripID)
+	if err != nil {
+		var notFound *services.TripNotFoundError
+		switch {
+		case errors.Is(err, services.ErrTripRestoreExpired):
+			c.JSON(http.StatusGone, gin.H{"error": "Trip was deleted too long ago to be restored"})
+		case errors.As(err, &notFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore trip"})
+		}
+		return
+	}
+	h.setTripSearchStatus(ctx, tripID, td.Status)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Trip restored successfully",
 		"trip_id": tripID,
+		"status":  td.Status,
+	})
+}
+
+// ConfirmBookings books every item of a trip marked as pending with its
+// provider and reports each item's outcome. Items that fail stay bookable,
+// so calling it again retries just those.
+func (h *TripHandler) ConfirmBookings(c *gin.Context) {
+	tripID := c.Param("tripId")
+	if h.services.BookingService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Booking service not available"})
+		return
+	}
+	if _, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionEdit); !ok {
+		return
+	}
+
+	// Bookings made with providers must be recorded even if the client
+	// goes away
+	ctx := context.WithoutCancel(c.Request.Context())
+	summary, err := h.services.BookingService.ConfirmBookings(ctx, tripID)
+	if err != nil {
+		log.Printf("Failed to confirm bookings for trip %s: %v", tripID, err)
+		respondError(c, err, "Failed to confirm bookings")
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// ExpenseRequest is one line item of what a traveler spent
+type ExpenseRequest struct {
+	Description string    `json:"description" binding:"required"`
+	Category    string    `json:"category"`
+	Amount      float64   `json:"amount" binding:"required,gt=0"`
+	Currency    string    `json:"currency"`
+	SpentAt     time.Time `json:"spent_at"`
+}
+
+// AddExpenses records what travelers spent on a trip, in any supported
+// currency, and returns the trip's spending against its budget. The owner is
+// alerted when the spending pace puts the trip on course to go over budget.
+func (h *TripHandler) AddExpenses(c *gin.Context) {
+	tripID := c.Param("tripId")
+
+	var req struct {
+		Expenses []ExpenseRequest `json:"expenses" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if h.services.BudgetTracker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Budget tracking not available"})
+		return
+	}
+	td, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionEdit)
+	if !ok {
+		return
+	}
+
+	expenses := make([]services.Expense, len(req.Expenses))
+	for i, expense := range req.Expenses {
+		expenses[i] = services.Expense{
+			Description: expense.Description,
+			Category:    expense.Category,
+			Amount:      expense.Amount,
+			Currency:    expense.Currency,
+			SpentAt:     expense.SpentAt,
+		}
+	}
+	report, err := h.services.BudgetTracker.RecordExpenses(c.Request.Context(), td, currentUserID(c), expenses)
+	if err != nil {
+		log.Printf("Failed to record expenses for trip %s: %v", tripID, err)
+		respondError(c, err, "Failed to record expenses")
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// GetBudgetStatus returns a trip's spending against its budget, where budget
+// alerts link to
+func (h *TripHandler) GetBudgetStatus(c *gin.Context) {
+	tripID := c.Param("tripId")
+
+	if h.services.BudgetTracker == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Budget tracking not available"})
+		return
+	}
+	td, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionView)
+	if !ok {
+		return
+	}
+
+	status, err := h.services.BudgetTracker.Status(c.Request.Context(), td)
+	if err != nil {
+		log.Printf("Failed to load budget status for trip %s: %v", tripID, err)
+		respondError(c, err, "Failed to load budget status")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// SetTripVisibility makes a trip public, so anyone with its share code can
+// view it, or private again
+func (h *TripHandler) SetTripVisibility(c *gin.Context) {
+	tripID := c.Param("tripId")
+
+	var req struct {
+		IsPublic *bool `json:"is_public" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	td, ok := authorizeTrip(c, h.services.Firebase, tripID, services.TripActionManage)
+	if !ok {
+		return
+	}
+
+	shareCode, err := h.services.Firebase.SetTripVisibility(c.Request.Context(), td, *req.IsPublic)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update trip visibility"})
+		return
+	}
+
+	response := gin.H{
+		"trip_id":    tripID,
+		"is_public":  *req.IsPublic,
+		"share_code": shareCode,
+	}
+	if *req.IsPublic {
+		response["public_path"] = "/api/v1/public/trips/" + shareCode
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPublicTrip serves a public trip by its share code, without requiring
+// authentication. Private trips are reported as not found.
+func (h *TripHandler) GetPublicTrip(c *gin.Context) {
+	if h.services.Firebase == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trip storage not available"})
+		return
+	}
+
+	trip, err := h.services.Firebase.GetPublicTrip(c.Request.Context(), c.Param("shareCode"))
+	if err != nil {
+		var notFound *services.TripNotFoundError
+		if errors.As(err, &notFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load trip"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"trip": trip})
+}
+
+// InviteCollaborator invites a user, by email, to edit or view a trip. Only
+// the trip's owner can invite.
+func (h *TripHandler) InviteCollaborator(c *gin.Context) {
+	if h.services.CollaborationService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Collaboration service not available"})
+		return
+	}
+
+	var req struct {
+		Email string `json:"email" binding:"required,email"`
+		Role  string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tripID := c.Param("tripId")
+	collaborator, err := h.services.CollaborationService.InviteCollaborator(c.Request.Context(), tripID, currentUserID(c), req.Email, req.Role)
+	if err != nil {
+		var notFound *services.TripNotFoundError
+		var permErr *services.TripPermissionError
+		switch {
+		case errors.Is(err, services.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInviteeNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "No user found with that email"})
+		case errors.Is(err, services.ErrAlreadyCollaborator):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.As(err, &notFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		case errors.As(err, &permErr):
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the trip owner can invite collaborators"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invite collaborator"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":      "Invite sent",
+		"collaborator": collaborator,
 	})
 }
 
+// AcceptInvite accepts the authenticated user's pending invite to a trip
+func (h *TripHandler) AcceptInvite(c *gin.Context) {
+	if h.services.CollaborationService == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Collaboration service not available"})
+		return
+	}
+
+	tripID := c.Param("tripId")
+	collaborator, err := h.services.CollaborationService.AcceptInvite(c.Request.Context(), tripID, currentUserID(c))
+	if err != nil {
+		var notFound *services.TripNotFoundError
+		if errors.As(err, &notFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		if errors.Is(err, services.ErrInviteNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No pending invite for this trip"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Invite accepted",
+		"collaborator": collaborator,
+	})
+}
+
+// notifyTripUpdated tells the devices following a trip that it changed.
+// The update is already saved, so failures are only logged.
+func (h *TripHandler) notifyTripUpdated(ctx context.Context, td *services.TripData) {
+	if h.services.NotificationService == nil {
+		return
+	}
+	name := td.Title
+	if name == "" {
+		name = "Your trip to " + td.Destination
+	}
+	if _, err := h.services.NotificationService.SendTripUpdateNotification(ctx, td.ID, name+" has been updated"); err != nil {
+		log.Printf("Failed to notify devices following trip %s: %v", td.ID, err)
+	}
+}
+
+// setTripSearchStatus keeps the trip's vector search entry in step with its
+// status. The trip document is authoritative, so failures are only logged.
+func (h *TripHandler) setTripSearchStatus(ctx context.Context, tripID, tripStatus string) {
+	if h.services.VectorDB == nil {
+		return
+	}
+	if err := h.services.VectorDB.SetTripEmbeddingStatus(ctx, tripID, tripStatus); err != nil {
+		log.Printf("Failed to update search status for trip %s: %v", tripID, err)
+	}
+}
+
+// authorizeTrip loads a trip and checks that the authenticated user's role
+// permits action, writing a 404 or 403 response when not
+func authorizeTrip(c *gin.Context, fb *services.FirebaseService, tripID string, action services.TripAction) (*services.TripData, bool) {
+	td, _, ok := authorizeTripRole(c, fb, tripID, action)
+	return td, ok
+}
+
+// authorizeTripRole is authorizeTrip that also returns the user's role
+func authorizeTripRole(c *gin.Context, fb *services.FirebaseService, tripID string, action services.TripAction) (*services.TripData, string, bool) {
+	if fb == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Trip storage not available"})
+		return nil, "", false
+	}
+
+	td, role, err := fb.AuthorizeTrip(c.Request.Context(), tripID, currentUserID(c), action)
+	if err != nil {
+		var notFound *services.TripNotFoundError
+		var permErr *services.TripPermissionError
+		switch {
+		case errors.As(err, &notFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+		case errors.As(err, &permErr):
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have access to this trip"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load trip"})
+		}
+		return nil, "", false
+	}
+	return td, role, true
+}
+
+// tripModel converts a stored trip to its API representation
+func tripModel(td *services.TripData) models.Trip {
+	return models.Trip{
+		ID:          td.ID,
+		UserID:      td.UserID,
+		Destination: td.Destination,
+		StartDate:   toTime(td.StartDate),
+		EndDate:     toTime(td.EndDate),
+		Status:      td.Status,
+		TotalBudget: td.Budget,
+		Travelers:   td.Travelers,
+		IsPublic:    td.IsPublic,
+		ShareCode:   td.ShareCode,
+		CreatedAt:   toTime(td.CreatedAt),
+		UpdatedAt:   toTime(td.UpdatedAt),
+		Version:     td.Version,
+	}
+}
+
+// tripETag is the ETag of a trip version
+func tripETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion returns the trip version in the request's If-Match header,
+// and whether it had one
+func ifMatchVersion(c *gin.Context) (int64, bool, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return 0, false, nil
+	}
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 0 {
+		return 0, false, errors.New("If-Match must be a trip ETag")
+	}
+	return version, true, nil
+}
+
+// requestVersion returns the trip version a write was made against, from
+// the request body's version or the If-Match header. When neither is given,
+// or they disagree, it writes the error and returns false.
+func requestVersion(c *gin.Context, bodyVersion *int64) (int64, bool) {
+	baseVersion, conditional, err := ifMatchVersion(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, false
+	}
+	if bodyVersion != nil {
+		if conditional && *bodyVersion != baseVersion {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "version and If-Match header disagree"})
+			return 0, false
+		}
+		baseVersion, conditional = *bodyVersion, true
+	}
+	if !conditional {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "version or If-Match header is required"})
+		return 0, false
+	}
+	return baseVersion, true
+}
+
+// respondTripUpdateError writes an error from a versioned trip update. A
+// version conflict carries the trip as it is now, for the client to resolve
+// its changes against.
+func respondTripUpdateError(c *gin.Context, err error, fallback string) {
+	var conflict *services.TripVersionConflictError
+	if errors.As(err, &conflict) {
+		c.Header("ETag", tripETag(conflict.CurrentVersion))
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           err.Error(),
+			"current_version": conflict.CurrentVersion,
+			"trip":            tripModel(conflict.Current),
+			"itinerary":       conflict.Current.Itinerary,
+		})
+		return
+	}
+	respondError(c, err, fallback)
+}
+
+// currentUserID returns the user ID AuthMiddleware set for the request
+func currentUserID(c *gin.Context) string {
+	userID, _ := c.Get("userID")
+	id, _ := userID.(string)
+	return id
+}
+
 // toTime safely converts Firestore timestamp/interface{} to time.Time
 func toTime(val interface{}) time.Time {
 	switch t := val.(type) {
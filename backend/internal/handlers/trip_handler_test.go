@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUpdateTripRequiresVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.PUT("/trips/:tripId", NewTripHandler(&services.Services{}).UpdateTrip)
+
+	body := `{"destination":"Goa","start_date":"2026-07-01T00:00:00Z","end_date":"2026-07-05T00:00:00Z","total_budget":900,"travelers":2}`
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch string
+		want    int
+	}{
+		{"no version", body, "", http.StatusPreconditionRequired},
+		{"disagreeing versions", strings.Replace(body, "{", `{"version":3,`, 1), `"4"`, http.StatusBadRequest},
+		{"dates out of order", strings.Replace(body, "2026-07-05", "2026-06-25", 1), `"4"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/trips/trip-1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
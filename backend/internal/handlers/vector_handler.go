@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -38,7 +39,7 @@ type SearchSimilarTripsRequest struct {
 
 // StoreUserPreferencesRequest represents the request for storing user preferences
 type StoreUserPreferencesRequest struct {
-	UserID      string                 `json:"user_id" binding:"required"`
+	UserID      string                 `json:"-"` // set from the authenticated user
 	Preferences map[string]interface{} `json:"preferences" binding:"required"`
 	TripHistory []string               `json:"trip_history"`
 }
@@ -55,7 +56,7 @@ func (h *VectorHandler) SearchSimilarAttractions(c *gin.Context) {
 		req.Limit = 10
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
 	if h.services.VectorDB == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Vector database not available"})
@@ -87,7 +88,7 @@ func (h *VectorHandler) SearchSimilarTrips(c *gin.Context) {
 		req.Limit = 5
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
 	if h.services.VectorDB == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Vector database not available"})
@@ -100,6 +101,14 @@ func (h *VectorHandler) SearchSimilarTrips(c *gin.Context) {
 		return
 	}
 
+	// Searches feed destination autocomplete; failing to record one
+	// doesn't fail the search
+	if h.services.DestinationSuggestService != nil {
+		if err := h.services.DestinationSuggestService.RecordSearch(ctx, currentUserID(c), services.SearchTypeDestination, req.Destination, req.Preferences, len(trips)); err != nil {
+			log.Printf("Failed to record destination search: %v", err)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"trips":       trips,
 		"count":       len(trips),
@@ -114,8 +123,9 @@ func (h *VectorHandler) StoreUserPreferences(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.UserID = currentUserID(c)
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
 	if h.services.VectorDB == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Vector database not available"})
@@ -146,7 +156,7 @@ func (h *VectorHandler) StoreUserPreferences(c *gin.Context) {
 // GetRAGContext retrieves RAG context for a destination
 func (h *VectorHandler) GetRAGContext(c *gin.Context) {
 	destination := c.Query("destination")
-	userID := c.Query("user_id")
+	userID := currentUserID(c)
 	startDate := c.Query("start_date")
 	endDate := c.Query("end_date")
 	budgetStr := c.Query("budget")
@@ -180,7 +190,7 @@ func (h *VectorHandler) GetRAGContext(c *gin.Context) {
 		preferences = map[string]interface{}{"raw": preferencesStr}
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
 	if h.services.RAGRetriever == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "RAG retriever not available"})
@@ -286,7 +296,7 @@ func (h *VectorHandler) PredictTravelCost(c *gin.Context) {
 		budgetPreference = "mid-range"
 	}
 
-	ctx := context.Background()
+	ctx := context.WithoutCancel(c.Request.Context())
 
 	if h.services.CostPredictor == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Cost predictor not available"})
@@ -0,0 +1,245 @@
+// Package httpclient builds the clients services make outbound HTTP calls
+// with. Every client shares one pooled transport, so connections to the same
+// API are reused across services, and transient failures of idempotent
+// requests are retried with backoff by the configured policy. The
+// configuration is read once, when the package initializes.
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"auratravel-backend/internal/config"
+)
+
+// maxRetryAfter is the longest Retry-After wait honored; a server asking
+// for longer gets its response returned instead
+const maxRetryAfter = 10 * time.Second
+
+// Options override the configured defaults for one service's client
+type Options struct {
+	// Timeout bounds a whole call, retries included; 0 uses
+	// HTTP_CLIENT_TIMEOUT_SECONDS
+	Timeout time.Duration
+	// NoRetry turns retries off, for callers that retry on their own
+	NoRetry bool
+	// RetryAll retries POSTs and other non-idempotent methods too, for APIs
+	// where repeating a call has no side effects, like text generation
+	RetryAll bool
+	// PublicOnly refuses to connect to anything but public addresses, for
+	// calls to URLs users supply. It uses a transport of its own, without
+	// the environment's proxy, since a proxy would connect on its behalf.
+	PublicOnly bool
+}
+
+var (
+	// settings is the configuration every client is built from
+	settings = config.GetConfig()
+
+	sharedTransport = newTransport(http.ProxyFromEnvironment, nil)
+	// publicTransport checks addresses as they're dialed, after DNS
+	// resolution, so a host can't pass validation and then resolve to an
+	// internal address
+	publicTransport = newTransport(nil, dialPublicOnly)
+)
+
+// reservedPrefixes are the special-purpose ranges PublicAddress rejects
+// beyond what net/netip classifies: "this network", carrier-grade NAT, IETF
+// protocol assignments, documentation, benchmarking, reserved space and
+// NAT64
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// PublicAddress reports whether addr is a public unicast address: not
+// loopback, private (RFC 1918 or unique local), link-local (including the
+// 169.254.169.254 metadata service), multicast, unspecified or reserved
+func PublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// New returns a client on the shared transport that retries transient
+// failures: network errors, 429s and 5xx responses other than 501. Only
+// idempotent methods, and requests with an Idempotency-Key header, are
+// retried unless RetryAll is set, and only when their body can be replayed.
+func New(opts Options) *http.Client {
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(settings.HTTPTimeoutSeconds) * time.Second
+	}
+
+	var transport http.RoundTripper = sharedTransport
+	if opts.PublicOnly {
+		transport = publicTransport
+	}
+	if !opts.NoRetry && settings.HTTPRetryMax > 0 {
+		transport = &retryTransport{
+			next:     transport,
+			retries:  settings.HTTPRetryMax,
+			backoff:  time.Duration(settings.HTTPRetryBackoffMs) * time.Millisecond,
+			retryAll: opts.RetryAll,
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Transport returns the transport every client shares
+func Transport() *http.Transport {
+	return sharedTransport
+}
+
+// newTransport builds a pooled transport sized by the configuration
+func newTransport(proxy func(*http.Request) (*url.URL, error), control func(network, address string, c syscall.RawConn) error) *http.Transport {
+	return &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   control,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          settings.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost:   settings.HTTPMaxConnsPerHost,
+		MaxConnsPerHost:       settings.HTTPMaxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// dialPublicOnly refuses connections to addresses that aren't public
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("refusing to connect to %s: %w", address, err)
+	}
+	if !PublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("refusing to connect to non-public address %s", addrPort.Addr())
+	}
+	return nil
+}
+
+// retryTransport retries transient failures, doubling the wait after each
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	backoff time.Duration
+	// retryAll retries non-idempotent methods too
+	retryAll bool
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attemptReq := req
+	for attempt := 0; ; attempt++ {
+		resp, err := t.next.RoundTrip(attemptReq)
+		if attempt >= t.retries || !t.retryable(req, resp, err) {
+			return resp, err
+		}
+		wait, ok := t.wait(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+
+		next := req.Clone(req.Context())
+		if req.Body != nil && req.Body != http.NoBody {
+			body, bodyErr := req.GetBody()
+			if bodyErr != nil {
+				return resp, err
+			}
+			next.Body = body
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		attemptReq = next
+	}
+}
+
+// wait is how long to wait before retrying after attempt. A Retry-After
+// header is honored up to maxRetryAfter; a longer one isn't worth retrying.
+func (t *retryTransport) wait(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			wait := time.Duration(seconds) * time.Second
+			return wait, wait <= maxRetryAfter
+		}
+	}
+	wait := t.backoff << attempt
+	if wait <= 0 {
+		return 0, true
+	}
+	// Jitter keeps clients that failed together from retrying together
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1)), true
+}
+
+// retryable reports whether a failed attempt is worth repeating: the
+// request's context is still live, repeating it is safe, its body can be
+// sent again, and the failure was a network error or a transient status
+func (t *retryTransport) retryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if !t.retryAll && !idempotent(req) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// idempotent reports whether sending req twice has the same effect as once:
+// its method is idempotent, or it carries an idempotency key, as net/http
+// treats it
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != "" || req.Header.Get("X-Idempotency-Key") != ""
+}
@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := PublicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("PublicAddress(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestPublicOnlyClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := New(Options{Timeout: 5 * time.Second, NoRetry: true, PublicOnly: true})
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("PublicOnly client connected to a loopback server")
+	}
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		header       string
+		retryAll     bool
+		status       int
+		retryAfter   string
+		wantAttempts int32
+	}{
+		{"get on 503", http.MethodGet, "", false, http.StatusServiceUnavailable, "", 3},
+		{"get on 429", http.MethodGet, "", false, http.StatusTooManyRequests, "", 3},
+		{"put on 502", http.MethodPut, "", false, http.StatusBadGateway, "", 3},
+		{"get on 501", http.MethodGet, "", false, http.StatusNotImplemented, "", 1},
+		{"get on 404", http.MethodGet, "", false, http.StatusNotFound, "", 1},
+		{"post", http.MethodPost, "", false, http.StatusServiceUnavailable, "", 1},
+		{"post with an idempotency key", http.MethodPost, "key-1", false, http.StatusServiceUnavailable, "", 3},
+		{"post when opted in", http.MethodPost, "", true, http.StatusServiceUnavailable, "", 3},
+		{"retry-after too long", http.MethodGet, "", false, http.StatusServiceUnavailable, "60", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			client := &http.Client{Transport: &retryTransport{next: http.DefaultTransport, retries: 2, backoff: time.Millisecond, retryAll: tt.retryAll}}
+			req, _ := http.NewRequest(tt.method, server.URL, strings.NewReader("{}"))
+			if tt.header != "" {
+				req.Header.Set("Idempotency-Key", tt.header)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.status || attempts.Load() != tt.wantAttempts {
+				t.Errorf("got %d after %d attempts, want %d after %d", resp.StatusCode, attempts.Load(), tt.status, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryTransportRecovers(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{next: http.DefaultTransport, retries: 2, backoff: time.Millisecond}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || attempts.Load() != 2 {
+		t.Errorf("got %d after %d attempts, want 200 after 2", resp.StatusCode, attempts.Load())
+	}
+}
+
+func TestNewAppliesTimeout(t *testing.T) {
+	if got, want := New(Options{}).Timeout, time.Duration(settings.HTTPTimeoutSeconds)*time.Second; got != want {
+		t.Errorf("default timeout = %v, want HTTP_CLIENT_TIMEOUT_SECONDS (%v)", got, want)
+	}
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	resp, err := New(Options{Timeout: 50 * time.Millisecond, NoRetry: true}).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("request outlived the client timeout")
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("error = %v, want a timeout", err)
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import "time"
+
+// Call outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// OtherLabel stands in for label values outside a metric's known set
+const OtherLabel = "other"
+
+var (
+	// DeliveriesTotal counts finished itinerary deliveries
+	DeliveriesTotal = newCounterVec("auratravel_itinerary_deliveries_total",
+		"Itinerary deliveries by format, method and final status.",
+		"format", "method", "status")
+
+	// NotificationsTotal counts notification sends by their outcome: sent,
+	// partial, failed, skipped, suppressed, scheduled or error
+	NotificationsTotal = newCounterVec("auratravel_notifications_total",
+		"Notification sends by type, priority and outcome.",
+		"type", "priority", "outcome")
+
+	// GeminiCallsTotal counts calls to Gemini models, through the Gemini API
+	// or Vertex AI
+	GeminiCallsTotal = newCounterVec("auratravel_gemini_calls_total",
+		"Gemini generateContent calls by model and result.",
+		"model", "result")
+
+	// ExternalCallDuration times calls to external services
+	ExternalCallDuration = newHistogramVec("auratravel_external_call_duration_seconds",
+		"Latency of calls to external services by service and outcome.",
+		DefaultBuckets, "service", "outcome")
+)
+
+// Outcome labels an error as a call outcome
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeSuccess
+}
+
+// ObserveExternalCall records the latency of a call to service that started
+// at start
+func ObserveExternalCall(service string, start time.Time, err error) {
+	ExternalCallDuration.WithLabelValues(service, Outcome(err)).Observe(time.Since(start).Seconds())
+}
+
+// Bounded returns value when it's one of allowed and OtherLabel otherwise,
+// for label values that come from requests
+func Bounded(value string, allowed ...string) string {
+	for _, a := range allowed {
+		if value == a {
+			return value
+		}
+	}
+	return OtherLabel
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveExternalCall(t *testing.T) {
+	ObserveExternalCall("places", time.Now(), nil)
+	ObserveExternalCall("places", time.Now(), errors.New("timeout"))
+	ObserveExternalCall("places", time.Now(), errors.New("timeout"))
+
+	if got := testutil.CollectAndCount(ExternalCallDuration, "auratravel_external_call_duration_seconds"); got != 2 {
+		t.Errorf("external call series = %d, want success and error", got)
+	}
+}
+
+func TestHandlerServesApplicationMetrics(t *testing.T) {
+	GeminiCallsTotal.WithLabelValues("gemini-2.0-flash", OutcomeSuccess).Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	for _, want := range []string{
+		`auratravel_gemini_calls_total{model="gemini-2.0-flash",result="success"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output is missing %q", want)
+		}
+	}
+}
+
+func TestBounded(t *testing.T) {
+	if got := Bounded("pdf", "pdf", "ics"); got != "pdf" {
+		t.Errorf("Bounded(pdf) = %q", got)
+	}
+	if got := Bounded("../etc", "pdf", "ics"); got != OtherLabel {
+		t.Errorf("Bounded(../etc) = %q, want %q", got, OtherLabel)
+	}
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionIDHeader and sessionIDCookie carry the client's analytics session
+const (
+	sessionIDHeader = "X-Session-ID"
+	sessionIDCookie = "session_id"
+)
+
+// analyticsRoutes maps the routes that record an event, by method and route
+// pattern, to their event type. Chat messages are recorded by the chat
+// handler, which adds the language the reply was in.
+var analyticsRoutes = map[string]string{
+	"POST /api/v1/ai/plan-trip":                      services.EventTripPlanned,
+	"GET /api/v1/ai/recommendations":                 services.EventRecommendationViewed,
+	"GET /api/v1/ai/recommendations/:id/explanation": services.EventRecommendationViewed,
+	"GET /api/v1/trips/recommendations":              services.EventRecommendationViewed,
+	"POST /api/v1/trips/deliver":                     services.EventItineraryDelivered,
+}
+
+// AnalyticsMiddleware attaches the client's session ID, IP, user agent and
+// referrer to the request context, so handlers can emit their own events,
+// and records an event for each successful request to an analytics route.
+// Recording is queued, so it never delays or fails the request.
+func AnalyticsMiddleware(analytics *services.AnalyticsService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if analytics == nil {
+			c.Next()
+			return
+		}
+
+		sessionID := c.GetHeader(sessionIDHeader)
+		if sessionID == "" {
+			sessionID, _ = c.Cookie(sessionIDCookie)
+		}
+		if len(sessionID) > 128 {
+			sessionID = ""
+		}
+		ctx := services.WithRequestMetadata(c.Request.Context(), services.RequestMetadata{
+			SessionID: sessionID,
+			IPAddress: c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			Referrer:  c.Request.Referer(),
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		route := c.FullPath()
+		eventType, ok := analyticsRoutes[c.Request.Method+" "+route]
+		status := c.Writer.Status()
+		if !ok || status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return
+		}
+
+		data := map[string]interface{}{
+			"route":       route,
+			"status_code": status,
+		}
+		for _, param := range c.Params {
+			data[strings.ToLower(param.Key)] = param.Value
+		}
+		userID, _ := c.Get("userID")
+		id, _ := userID.(string)
+		analytics.EmitEvent(c.Request.Context(), id, eventType, data)
+	}
+}
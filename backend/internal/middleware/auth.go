@@ -1,13 +1,18 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
+
+	"auratravel-backend/internal/config"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates JWT tokens from Firebase
+// AuthMiddleware validates bearer JWTs signed with JWT_SECRET and sets the
+// authenticated user ID in the context as "userID"
 func AuthMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -38,11 +43,14 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Validate token with Firebase (we'll implement this when we set up Firebase)
-		userID, err := validateFirebaseToken(token)
+		userID, err := validateToken(token)
 		if err != nil {
+			message := "Invalid token"
+			if errors.Is(err, ErrTokenExpired) {
+				message = "Token has expired"
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired token",
+				"error": message,
 			})
 			c.Abort()
 			return
@@ -62,7 +70,7 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 			bearerToken := strings.Split(authHeader, " ")
 			if len(bearerToken) == 2 && bearerToken[0] == "Bearer" {
 				token := bearerToken[1]
-				if userID, err := validateFirebaseToken(token); err == nil {
+				if userID, err := validateToken(token); err == nil {
 					c.Set("userID", userID)
 				}
 			}
@@ -97,12 +105,34 @@ func AdminMiddleware() gin.HandlerFunc {
 	})
 }
 
-// validateFirebaseToken validates JWT token with Firebase
-func validateFirebaseToken(token string) (string, error) {
-	// TODO: Implement Firebase token validation
-	// For now, return a dummy user ID for testing
-	// In production, this should validate with Firebase Auth
-	return "test-user-id", nil
+// RequireSameUser rejects requests whose :param path segment names a user
+// other than the authenticated one. It must run after AuthMiddleware.
+func RequireSameUser(param string) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		userID, exists := c.Get("userID")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Authentication required",
+			})
+			c.Abort()
+			return
+		}
+
+		if c.Param(param) != userID {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "Access to another user's data is not allowed",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	})
+}
+
+// validateToken verifies a bearer token against the configured JWT secret
+func validateToken(token string) (string, error) {
+	return verifyToken(token, config.GetConfig().JWTSecret, time.Now())
 }
 
 // checkAdminStatus checks if user has admin privileges
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// jwtClockSkew tolerates small clock differences when checking exp and nbf
+const jwtClockSkew = 30 * time.Second
+
+var (
+	// ErrTokenInvalid covers malformed tokens, bad signatures and unsupported
+	// algorithms
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrTokenExpired is returned for well-formed tokens past their exp
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// tokenClaims are the JWT claims this API reads
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	UserID    string `json:"user_id"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// verifyToken checks an HS256-signed JWT against secret and returns the user
+// ID from its sub (or user_id) claim. Tokens must carry an exp claim.
+func verifyToken(token, secret string, now time.Time) (string, error) {
+	if secret == "" {
+		return "", ErrTokenInvalid
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrTokenInvalid
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeTokenSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return "", ErrTokenInvalid
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrTokenInvalid
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", ErrTokenInvalid
+	}
+
+	var claims tokenClaims
+	if err := decodeTokenSegment(parts[1], &claims); err != nil {
+		return "", ErrTokenInvalid
+	}
+	if claims.ExpiresAt == 0 {
+		return "", ErrTokenInvalid
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(jwtClockSkew)) {
+		return "", ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(jwtClockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return "", ErrTokenInvalid
+	}
+
+	userID := claims.Subject
+	if userID == "" {
+		userID = claims.UserID
+	}
+	if userID == "" {
+		return "", ErrTokenInvalid
+	}
+	return userID, nil
+}
+
+func decodeTokenSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
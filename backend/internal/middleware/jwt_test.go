@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auratravel-backend/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testJWTSecret = "a-long-private-test-secret"
+
+// signToken builds a JWT with the given header algorithm and claims, signed
+// with HS256 under secret
+func signToken(t *testing.T, alg, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	segment := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	unsigned := segment(map[string]string{"alg": alg, "typ": "JWT"}) + "." + segment(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyToken(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	exp := now.Add(time.Hour).Unix()
+	valid := signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": exp})
+	// valid's signature over another user's claims
+	forged := strings.Split(signToken(t, "HS256", "another-secret", map[string]interface{}{"sub": "admin", "exp": exp}), ".")
+	tampered := forged[0] + "." + forged[1] + "." + strings.Split(valid, ".")[2]
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr error
+	}{
+		{"valid sub", valid, "u1", nil},
+		{"user_id claim", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"user_id": "u2", "exp": exp}), "u2", nil},
+		{"sub preferred over user_id", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "user_id": "u2", "exp": exp}), "u1", nil},
+		{"no user", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"exp": exp}), "", ErrTokenInvalid},
+		{"bad signature", signToken(t, "HS256", "another-secret", map[string]interface{}{"sub": "u1", "exp": exp}), "", ErrTokenInvalid},
+		{"tampered claims", tampered, "", ErrTokenInvalid},
+		{"missing exp", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1"}), "", ErrTokenInvalid},
+		{"expired", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": now.Add(-time.Minute).Unix()}), "", ErrTokenExpired},
+		{"expired within skew", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": now.Add(-10 * time.Second).Unix()}), "u1", nil},
+		{"not yet valid", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": exp, "nbf": now.Add(time.Minute).Unix()}), "", ErrTokenInvalid},
+		{"nbf within skew", signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": exp, "nbf": now.Add(10 * time.Second).Unix()}), "u1", nil},
+		{"alg none", signToken(t, "none", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": exp}), "", ErrTokenInvalid},
+		{"alg RS256", signToken(t, "RS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": exp}), "", ErrTokenInvalid},
+		{"malformed", "not.a-token", "", ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyToken(tt.token, testJWTSecret, now)
+			if got != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("verifyToken = %q, %v; want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := verifyToken(valid, "", now); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("verifyToken with no secret = %v, want ErrTokenInvalid", err)
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.GetConfig()
+	previous := cfg.JWTSecret
+	cfg.JWTSecret = testJWTSecret
+	t.Cleanup(func() { cfg.JWTSecret = previous })
+
+	router := gin.New()
+	router.GET("/me", AuthMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("userID"))
+	})
+
+	now := time.Now()
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+		wantBody   string
+	}{
+		{"valid", "Bearer " + signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": now.Add(time.Hour).Unix()}), http.StatusOK, "u1"},
+		{"expired", "Bearer " + signToken(t, "HS256", testJWTSecret, map[string]interface{}{"sub": "u1", "exp": now.Add(-time.Hour).Unix()}), http.StatusUnauthorized, `{"error":"Token has expired"}`},
+		{"tampered", "Bearer " + signToken(t, "HS256", "another-secret", map[string]interface{}{"sub": "u1", "exp": now.Add(time.Hour).Unix()}), http.StatusUnauthorized, `{"error":"Invalid token"}`},
+		{"no header", "", http.StatusUnauthorized, `{"error":"Authorization header is required"}`},
+		{"not bearer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, `{"error":"Invalid authorization header format"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus || rec.Body.String() != tt.wantBody {
+				t.Errorf("GET /me = %d %s, want %d %s", rec.Code, rec.Body.String(), tt.wantStatus, tt.wantBody)
+			}
+		})
+	}
+}
@@ -5,9 +5,33 @@ import (
 	"log"
 	"time"
 
+	"auratravel-backend/internal/logging"
+
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// requestIDHeader carries the request ID in both directions
+const requestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware tags each request with an ID, reusing the caller's
+// X-Request-ID when present. The ID is echoed in the response and attached to
+// the request context so service logs can be correlated with the request.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(requestIDHeader)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.New().String()
+		}
+
+		c.Set("requestID", requestID)
+		c.Header(requestIDHeader, requestID)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), requestID))
+
+		c.Next()
+	}
+}
+
 // LoggingMiddleware provides request logging
 func LoggingMiddleware() gin.HandlerFunc {
 	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
@@ -37,11 +61,3 @@ func ErrorHandlingMiddleware() gin.HandlerFunc {
 		c.AbortWithStatus(500)
 	})
 }
-
-// RateLimitMiddleware provides basic rate limiting
-func RateLimitMiddleware() gin.HandlerFunc {
-	// TODO: Implement proper rate limiting with Redis or in-memory store
-	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Next()
-	})
-}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auratravel-backend/internal/logging"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	router := gin.New()
+	router.Use(RequestIDMiddleware())
+	router.GET("/trips", func(c *gin.Context) {
+		logging.FromContext(c.Request.Context()).Info("listing trips")
+		c.String(http.StatusOK, logging.RequestID(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name      string
+		header    string
+		wantReuse bool
+	}{
+		{"caller's ID is reused", "req-123", true},
+		{"missing ID is generated", "", false},
+		{"oversized ID is replaced", strings.Repeat("x", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/trips", nil)
+			if tt.header != "" {
+				req.Header.Set(requestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			requestID := rec.Header().Get(requestIDHeader)
+			if requestID != rec.Body.String() {
+				t.Errorf("response header %q, context %q; want the same ID", requestID, rec.Body.String())
+			}
+			if tt.wantReuse && requestID != tt.header {
+				t.Errorf("request ID = %q, want the caller's %q", requestID, tt.header)
+			}
+			if _, err := uuid.Parse(requestID); !tt.wantReuse && err != nil {
+				t.Errorf("generated request ID %q is not a UUID", requestID)
+			}
+			if !strings.Contains(logs.String(), "request_id="+requestID) {
+				t.Errorf("log line %q isn't tagged with the request ID", logs.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/redis"
+
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	// rateLimitKeyPrefix namespaces limiter state in a shared Redis
+	rateLimitKeyPrefix = "auratravel:ratelimit:v1:"
+	// rateLimitSweepInterval is how often idle in-memory buckets are dropped
+	rateLimitSweepInterval = time.Minute
+	// rateLimitRedisRetryInterval is how long limits stay in memory after a
+	// Redis failure before trying Redis again
+	rateLimitRedisRetryInterval = 30 * time.Second
+)
+
+// RateLimitStore takes tokens from named token buckets
+type RateLimitStore interface {
+	// Take removes one token from the bucket for key. When the bucket is empty
+	// it reports false and how long until a token is available.
+	Take(ctx context.Context, key string, rps float64, burst int) (bool, time.Duration, error)
+}
+
+// RateLimiter enforces a token-bucket limit per client IP and, for
+// authenticated requests, another per user. A request needs a token from
+// both, so one user can't spread out over many addresses and many accounts
+// can't share one address.
+type RateLimiter struct {
+	store RateLimitStore
+	rps   float64
+	burst int
+}
+
+// NewRateLimiter creates a limiter refilling rps tokens per second up to
+// burst. A nil store uses an in-memory store.
+func NewRateLimiter(store RateLimitStore, rps float64, burst int) *RateLimiter {
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{store: store, rps: rps, burst: burst}
+}
+
+// RateLimitMiddleware limits the expensive AI endpoints using the configured
+// AI_RATE_LIMIT_RPS and AI_RATE_LIMIT_BURST. State is kept in Redis when it's
+// configured so the limit holds across instances.
+func RateLimitMiddleware() gin.HandlerFunc {
+	cfg := config.GetConfig()
+
+	var store RateLimitStore
+	if client := redis.NewFromConfig(cfg); client != nil {
+		store = NewRedisRateLimitStore(client)
+	}
+
+	return NewRateLimiter(store, cfg.AIRateLimitRPS, cfg.AIRateLimitBurst).Middleware()
+}
+
+// Middleware returns the Gin handler. Rate-limited requests get 429 with a
+// Retry-After header in seconds.
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if l.rps <= 0 || l.burst <= 0 {
+			c.Next()
+			return
+		}
+
+		keys := []string{"ip:" + c.ClientIP()}
+		if userID, exists := c.Get("userID"); exists {
+			keys = append([]string{fmt.Sprintf("user:%v", userID)}, keys...)
+		}
+
+		allowed := true
+		var retryAfter time.Duration
+		for _, key := range keys {
+			ok, wait, err := l.store.Take(c.Request.Context(), key, l.rps, l.burst)
+			if err != nil {
+				// Fail open: a limiter outage shouldn't take the API down
+				log.Printf("Rate limiter error, allowing request: %v", err)
+				continue
+			}
+			if !ok {
+				allowed, retryAfter = false, wait
+				break
+			}
+		}
+
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Rate limit exceeded",
+				"retry_after": seconds,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	})
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process memory. It's only correct for
+// a single instance.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take implements RateLimitStore
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rps float64, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now, rps, burst)
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(burst), last: now}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*rps)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0, nil
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / rps * float64(time.Second))
+	return false, wait, nil
+}
+
+// sweep drops buckets that have been idle long enough to have refilled, since
+// a fresh bucket behaves the same
+func (s *MemoryRateLimitStore) sweep(now time.Time, rps float64, burst int) {
+	if now.Sub(s.lastSweep) < rateLimitSweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	refill := time.Duration(float64(burst) / rps * float64(time.Second))
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.last) > refill {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// redisTokenBucketScript refills and takes from a bucket atomically. It
+// returns {allowed, milliseconds until the next token}.
+const redisTokenBucketScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) / 1000 * rate)
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return {allowed, wait}
+`
+
+// redisTokenBucket runs redisTokenBucketScript by its SHA, loading it on
+// first use
+var redisTokenBucket = goredis.NewScript(redisTokenBucketScript)
+
+// RedisRateLimitStore keeps buckets in Redis so every instance shares them.
+// When Redis can't be reached it falls back to an in-memory store, retrying
+// Redis after rateLimitRedisRetryInterval.
+type RedisRateLimitStore struct {
+	client   *goredis.Client
+	fallback *MemoryRateLimitStore
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+// NewRedisRateLimitStore creates a Redis-backed store
+func NewRedisRateLimitStore(client *goredis.Client) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client, fallback: NewMemoryRateLimitStore()}
+}
+
+// Take implements RateLimitStore
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, rps float64, burst int) (bool, time.Duration, error) {
+	if s.down() {
+		return s.fallback.Take(ctx, key, rps, burst)
+	}
+
+	values, err := redisTokenBucket.Run(ctx, s.client, []string{rateLimitKeyPrefix + key},
+		rps, burst, time.Now().UnixMilli()).Int64Slice()
+	if err != nil {
+		s.markDown(err)
+		return s.fallback.Take(ctx, key, rps, burst)
+	}
+	if len(values) != 2 {
+		return s.fallback.Take(ctx, key, rps, burst)
+	}
+	return values[0] == 1, time.Duration(values[1]) * time.Millisecond, nil
+}
+
+func (s *RedisRateLimitStore) down() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().Before(s.downUntil)
+}
+
+func (s *RedisRateLimitStore) markDown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().Before(s.downUntil) {
+		return
+	}
+	s.downUntil = time.Now().Add(rateLimitRedisRetryInterval)
+	log.Printf("Redis rate limiter unavailable, using in-memory limits for %s: %v", rateLimitRedisRetryInterval, err)
+}
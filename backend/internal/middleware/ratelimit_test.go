@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+func TestRedisRateLimitStoreFallsBackToMemory(t *testing.T) {
+	client := goredis.NewClient(&goredis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	store := NewRedisRateLimitStore(client)
+
+	for i := 0; i < 2; i++ {
+		allowed, _, err := store.Take(context.Background(), "user:1", 1, 2)
+		if err != nil || !allowed {
+			t.Fatalf("take %d = %v, %v; want allowed from the in-memory bucket", i+1, allowed, err)
+		}
+	}
+	allowed, wait, err := store.Take(context.Background(), "user:1", 1, 2)
+	if err != nil || allowed || wait <= 0 {
+		t.Errorf("take 3 = %v, %v, %v; want the emptied bucket to refuse with a wait", allowed, wait, err)
+	}
+	if !store.down() {
+		t.Error("store not marked down after a Redis failure")
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	// One token every two seconds, two at once
+	router := gin.New()
+	router.GET("/plan", func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("userID", user)
+		}
+	}, NewRateLimiter(store, 0.5, 2).Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	steps := []struct {
+		name           string
+		advance        time.Duration
+		user, ip       string
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{"first", 0, "u1", "10.0.0.1", http.StatusOK, ""},
+		{"burst", 0, "u1", "10.0.0.1", http.StatusOK, ""},
+		{"user limited", 0, "u1", "10.0.0.1", http.StatusTooManyRequests, "2"},
+		{"user limited from another address", 0, "u1", "10.0.0.2", http.StatusTooManyRequests, "2"},
+		{"address limited for another user", 0, "u2", "10.0.0.1", http.StatusTooManyRequests, "2"},
+		{"address limited without a user", 0, "", "10.0.0.1", http.StatusTooManyRequests, "2"},
+		{"refilled", 2 * time.Second, "u1", "10.0.0.2", http.StatusOK, ""},
+		{"empty again", 0, "u1", "10.0.0.2", http.StatusTooManyRequests, "2"},
+		{"partly refilled", time.Second, "u1", "10.0.0.2", http.StatusTooManyRequests, "1"},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		req := httptest.NewRequest(http.MethodGet, "/plan", nil)
+		req.RemoteAddr = step.ip + ":4000"
+		if step.user != "" {
+			req.Header.Set("X-Test-User", step.user)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Code != step.wantStatus || rec.Header().Get("Retry-After") != step.wantRetryAfter {
+			t.Errorf("%s: got %d with Retry-After %q, want %d with %q", step.name, rec.Code, rec.Header().Get("Retry-After"), step.wantStatus, step.wantRetryAfter)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+
+	"auratravel-backend/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TracingMiddleware starts the parent span for each request, continuing the
+// caller's trace when a traceparent header is sent. Spans are named after
+// the route pattern rather than the raw path so IDs in URLs aren't recorded.
+func TracingMiddleware() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx := tracing.ContextWithTraceParent(c.Request.Context(), c.GetHeader("traceparent"))
+		ctx, span := tracing.Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route), tracing.KindServer,
+			tracing.Attr("http.request.method", c.Request.Method),
+			tracing.Attr("http.route", route),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(tracing.Attr("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(tracing.StatusError, fmt.Sprintf("HTTP %d", status))
+		}
+	})
+}
@@ -48,6 +48,9 @@ type NotificationSettings struct {
 	TripReminders   bool `json:"trip_reminders"`
 	PriceAlerts     bool `json:"price_alerts"`
 	Recommendations bool `json:"recommendations"`
+	// Quiet hours in the device's local time ("HH:MM"); empty uses 22:00-08:00
+	QuietHoursStart string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string `json:"quiet_hours_end,omitempty"`
 }
 
 // EmergencyContact stores emergency contact information
@@ -79,6 +82,13 @@ type Trip struct {
 	ShareCode   string    `json:"share_code"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// DeletedAt is set while the trip is soft-deleted
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// Version is the trip's version for optimistic concurrency; updates
+	// send it back as the version they're based on
+	Version int64 `json:"version"`
 }
 
 // TripPreferences stores preferences specific to a trip
@@ -98,7 +108,7 @@ type TripPreferences struct {
 
 // TripCollaborator represents users who can collaborate on a trip
 type TripCollaborator struct {
-	ID         uint       `json:"id"`
+	ID         string     `json:"id"`
 	TripID     string     `json:"trip_id"`
 	UserID     string     `json:"user_id"`
 	Role       string     `json:"role"` // owner, editor, viewer
@@ -107,6 +117,9 @@ type TripCollaborator struct {
 	AcceptedAt *time.Time `json:"accepted_at"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at"`
+
+	// InviteeEmail is the address the invite was sent to
+	InviteeEmail string `json:"invitee_email,omitempty"`
 }
 
 // Itinerary represents a trip's detailed itinerary
@@ -116,7 +129,7 @@ type Itinerary struct {
 	TotalActivities   int        `json:"total_activities"`
 	EstimatedCost     float64    `json:"estimated_cost"`
 	Currency          string     `json:"currency"`
-	GeneratedBy       string     `json:"generated_by"` // ai, manual
+	GeneratedBy       string     `json:"generated_by"` // ai, template, manual
 	GeneratedAt       time.Time  `json:"generated_at"`
 	LastOptimizedAt   *time.Time `json:"last_optimized_at"`
 	OptimizationScore float64    `json:"optimization_score"`
@@ -241,6 +254,29 @@ type Transportation struct {
 	ExternalID    string     `json:"external_id"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// FlightNumber is the carrier code and number of a flight, like "AI101",
+	// which flight status updates are matched on
+	FlightNumber string `json:"flight_number,omitempty"`
+}
+
+// TripWithDetails is a trip joined with its full itinerary graph
+type TripWithDetails struct {
+	Trip           Trip                   `json:"trip"`
+	Role           string                 `json:"role"` // requesting user's role: owner, editor, viewer
+	Itinerary      *Itinerary             `json:"itinerary,omitempty"`
+	Days           []DayPlanDetails       `json:"days"`
+	Accommodations []Accommodation        `json:"accommodations"`
+	Transportation []Transportation       `json:"transportation"`
+	Collaborators  []TripCollaborator     `json:"collaborators"`
+	RawItinerary   map[string]interface{} `json:"raw_itinerary,omitempty"` // AI-generated itinerary stored on the trip document
+}
+
+// DayPlanDetails is a day plan with its activities and meals
+type DayPlanDetails struct {
+	DayPlan    DayPlan    `json:"day_plan"`
+	Activities []Activity `json:"activities"`
+	Meals      []Meal     `json:"meals"`
 }
 
 // Location represents a geographical location
@@ -285,7 +321,7 @@ type Recommendation struct {
 
 // SearchHistory stores user search queries for analytics
 type SearchHistory struct {
-	ID        uint      `json:"id"`
+	ID        string    `json:"id"`
 	UserID    string    `json:"user_id"`
 	Query     string    `json:"query"`
 	Type      string    `json:"type"`    // destination, activity, accommodation
@@ -296,7 +332,7 @@ type SearchHistory struct {
 
 // AnalyticsEvent stores events for analytics
 type AnalyticsEvent struct {
-	ID        uint      `json:"id"`
+	ID        string    `json:"id"`
 	UserID    string    `json:"user_id"`
 	EventType string    `json:"event_type"`
 	EventData string    `json:"event_data"` // JSON string
@@ -0,0 +1,35 @@
+// Package redis builds the go-redis client for the configured Redis server
+package redis
+
+import (
+	"net"
+	"time"
+
+	"auratravel-backend/internal/config"
+
+	goredis "github.com/redis/go-redis/v9"
+)
+
+const (
+	dialTimeout = 2 * time.Second
+	// ioTimeout caps a single command when the context has no earlier
+	// deadline
+	ioTimeout = 2 * time.Second
+)
+
+// NewFromConfig returns a client for the configured Redis server, or nil when
+// REDIS_HOST is not set. No connection is made until the first command.
+func NewFromConfig(cfg *config.Config) *goredis.Client {
+	if cfg.RedisHost == "" {
+		return nil
+	}
+	return goredis.NewClient(&goredis.Options{
+		Addr:                  net.JoinHostPort(cfg.RedisHost, cfg.RedisPort),
+		Password:              cfg.RedisPassword,
+		DB:                    cfg.RedisDB,
+		DialTimeout:           dialTimeout,
+		ReadTimeout:           ioTimeout,
+		WriteTimeout:          ioTimeout,
+		ContextTimeoutEnabled: true,
+	})
+}
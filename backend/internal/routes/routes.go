@@ -2,6 +2,7 @@ package routes
 
 import (
 	"auratravel-backend/internal/handlers"
+	"auratravel-backend/internal/metrics"
 	"auratravel-backend/internal/middleware"
 	"auratravel-backend/internal/services"
 
@@ -11,11 +12,13 @@ import (
 // SetupRoutes configures all application routes
 func SetupRoutes(router *gin.Engine, services *services.Services) {
 	// Initialize handlers
+	healthHandler := handlers.NewHealthHandler(services)
 	authHandler := handlers.NewAuthHandler(services)
 	userHandler := handlers.NewUserHandler(services)
 	tripHandler := handlers.NewTripHandler(services)
 	aiTripHandler := handlers.NewAITripHandler(services)
 	vectorHandler := handlers.NewVectorHandler(services)
+	destinationHandler := handlers.NewDestinationHandler(services)
 
 	// Initialize new real-time handlers
 	notificationHandler := handlers.NewNotificationHandler(services)
@@ -23,23 +26,26 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 	deliveryHandler := handlers.NewDeliveryHandler(services)
 	localizationHandler := handlers.NewLocalizationHandler(services)
 
+	// Record analytics events for the routes registered below
+	router.Use(middleware.AnalyticsMiddleware(services.AnalyticsService))
+
+	// Prometheus scrape endpoint, outside the versioned API
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	// Shared limit for the Gemini-backed endpoints
+	aiRateLimit := middleware.RateLimitMiddleware()
+
+	// Readiness at the root too, for load balancers probing /health
+	router.GET("/health", healthHandler.Ready)
+
 	// Public routes
 	public := router.Group("/api/v1")
 	{
-		// Health check
-		public.GET("/health", func(c *gin.Context) {
-			c.JSON(200, gin.H{
-				"status":  "ok",
-				"message": "AuraTravel API is running",
-				"services": gin.H{
-					"gemini":   services.Gemini != nil,
-					"vertex":   services.Vertex != nil,
-					"vision":   services.Vision != nil,
-					"bigquery": services.BigQuery != nil,
-					"firebase": services.Firebase != nil,
-				},
-			})
-		})
+		// Health checks: live for liveness probes, ready (and the legacy
+		// /health) for readiness
+		public.GET("/health", healthHandler.Ready)
+		public.GET("/health/live", healthHandler.Live)
+		public.GET("/health/ready", healthHandler.Ready)
 
 		// Authentication routes
 		auth := public.Group("/auth")
@@ -50,10 +56,17 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 			auth.POST("/firebase-auth", authHandler.FirebaseAuth)
 		}
 
+		// Trips shared by their owners
+		public.GET("/public/trips/:shareCode", tripHandler.GetPublicTrip)
+
+		// Flight status pushed by airlines and aggregators, authenticated by
+		// its signature rather than a user token
+		public.POST("/webhooks/flight-status", replanningHandler.FlightStatusWebhook)
+
 		// Public AI endpoints (limited functionality)
-		public.GET("/recommendations", aiTripHandler.GetRecommendations)
+		public.GET("/recommendations", aiRateLimit, aiTripHandler.GetRecommendations)
 		public.GET("/insights", aiTripHandler.GetTravelInsights)
-		public.POST("/analyze-image", aiTripHandler.AnalyzeImage)
+		public.POST("/analyze-image", aiRateLimit, aiTripHandler.AnalyzeImage)
 
 		// Public localization endpoints
 		localization := public.Group("/localization")
@@ -68,6 +81,7 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 	// Protected routes
 	protected := router.Group("/api/v1")
 	protected.Use(middleware.AuthMiddleware())
+	sameUser := middleware.RequireSameUser("userId")
 	{
 		// User profile routes
 		users := protected.Group("/users")
@@ -83,9 +97,19 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 		{
 			trips.POST("/", tripHandler.CreateTrip)
 			trips.GET("/", tripHandler.GetTrips)
-			trips.GET("/:id", tripHandler.GetTrip)
-			trips.PUT("/:id", tripHandler.UpdateTrip)
-			trips.DELETE("/:id", tripHandler.DeleteTrip)
+			trips.POST("/import", tripHandler.ImportTrips)
+			trips.GET("/:tripId", tripHandler.GetTrip)
+			trips.PUT("/:tripId", tripHandler.UpdateTrip)
+			trips.PATCH("/:tripId", tripHandler.PatchTrip)
+			trips.DELETE("/:tripId", tripHandler.DeleteTrip)
+			trips.POST("/:tripId/restore", tripHandler.RestoreTrip)
+			trips.POST("/:tripId/bookings/confirm", tripHandler.ConfirmBookings)
+			trips.GET("/:tripId/expenses", tripHandler.GetBudgetStatus)
+			trips.POST("/:tripId/expenses", tripHandler.AddExpenses)
+			trips.GET("/:tripId/changes", tripHandler.GetTripChanges)
+			trips.PUT("/:tripId/visibility", tripHandler.SetTripVisibility)
+			trips.POST("/:tripId/collaborators", tripHandler.InviteCollaborator)
+			trips.POST("/:tripId/accept-invite", tripHandler.AcceptInvite)
 			trips.GET("/recommendations", tripHandler.GenerateRecommendations)
 
 			// Real-time trip features
@@ -105,24 +129,43 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 			trips.GET("/:tripId/monitoring-status", replanningHandler.GetMonitoringStatus)
 			trips.POST("/:tripId/start-monitoring", replanningHandler.StartMonitoring)
 			trips.POST("/:tripId/stop-monitoring", replanningHandler.StopMonitoring)
+			trips.POST("/validate", aiTripHandler.ValidateTrip)
 			trips.POST("/dynamic-replan", replanningHandler.TriggerReplanning)
 			trips.POST("/:tripId/accept-replan", replanningHandler.AcceptReplanningOption)
+			trips.POST("/:tripId/adapt-weather", replanningHandler.AdaptDayForWeather)
+			trips.POST("/:tripId/adapt-weather/confirm", replanningHandler.ConfirmWeatherAdaptation)
+			trips.POST("/:tripId/days/:dayNum/regenerate", aiRateLimit, aiTripHandler.RegenerateDay)
+			trips.POST("/:tripId/days/:dayNum/regenerate/confirm", aiTripHandler.ConfirmDayRegeneration)
+			trips.POST("/webhooks", replanningHandler.RegisterWebhook)
+			trips.GET("/webhooks", replanningHandler.ListWebhooks)
+			trips.DELETE("/webhooks/:webhookId", replanningHandler.DeleteWebhook)
 			trips.POST("/deliver", deliveryHandler.DeliverItinerary)
+			trips.POST("/deliver/preview", deliveryHandler.PreviewDelivery)
+			trips.POST("/:tripId/import-ics", deliveryHandler.ImportICS)
 			trips.GET("/:tripId/share", deliveryHandler.GenerateShareLink)
 		}
 
 		// AI-powered trip routes
 		aiTrips := protected.Group("/ai")
 		{
-			aiTrips.POST("/plan-trip", aiTripHandler.PlanTrip)
-			aiTrips.GET("/recommendations", aiTripHandler.GetRecommendations)
+			aiTrips.POST("/plan-trip", aiRateLimit, aiTripHandler.PlanTrip)
+			aiTrips.GET("/recommendations", aiRateLimit, aiTripHandler.GetRecommendations)
+			aiTrips.GET("/recommendations/:id/explanation", aiRateLimit, aiTripHandler.ExplainRecommendation)
 			aiTrips.POST("/optimize/:id", aiTripHandler.OptimizeItinerary)
-			aiTrips.POST("/analyze-image", aiTripHandler.AnalyzeImage)
+			aiTrips.POST("/optimize/:id/budget-cuts", aiTripHandler.SuggestBudgetCuts)
+			aiTrips.POST("/analyze-image", aiRateLimit, aiTripHandler.AnalyzeImage)
+			aiTrips.POST("/packing-list", aiRateLimit, aiTripHandler.GeneratePackingList)
+			aiTrips.POST("/chat", aiRateLimit, aiTripHandler.Chat)
 			aiTrips.GET("/insights", aiTripHandler.GetTravelInsights)
+			aiTrips.GET("/travel-patterns", aiTripHandler.GetTravelPatterns)
 			aiTrips.GET("/rag-context", vectorHandler.GetRAGContext)
 			aiTrips.POST("/validate-availability", vectorHandler.ValidateAvailability)
 		}
 
+		// Destination autocomplete
+		protected.GET("/destinations/suggest", destinationHandler.SuggestDestinations)
+		protected.GET("/suggest/nearby", destinationHandler.SuggestNearby)
+
 		// Vector database routes
 		vector := protected.Group("/vector")
 		{
@@ -159,9 +202,10 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 		{
 			notifications.POST("/register-device", notificationHandler.RegisterDevice)
 			notifications.POST("/send", notificationHandler.SendNotification)
-			notifications.POST("/weather-alert/:userId", notificationHandler.SendWeatherAlert)
-			notifications.POST("/trip-update/:userId", notificationHandler.SendTripUpdate)
-			notifications.GET("/user/:userId", notificationHandler.GetUserNotifications)
+			notifications.POST("/weather-alert/:userId", sameUser, notificationHandler.SendWeatherAlert)
+			notifications.POST("/trip-update/:userId", sameUser, notificationHandler.SendTripUpdate)
+			notifications.GET("/user/:userId", sameUser, notificationHandler.GetUserNotifications)
+			notifications.POST("/user/:userId/:notificationId/read", sameUser, notificationHandler.MarkNotificationRead)
 		}
 
 		// Delivery routes
@@ -174,7 +218,7 @@ func SetupRoutes(router *gin.Engine, services *services.Services) {
 		userPrefs := protected.Group("/user")
 		{
 			userPrefs.POST("/language-preference", localizationHandler.SetUserLocalePreference)
-			userPrefs.GET("/:userId/language-preference", localizationHandler.GetUserLocalePreference)
+			userPrefs.GET("/:userId/language-preference", sameUser, localizationHandler.GetUserLocalePreference)
 		}
 
 		// Content localization
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"testing"
+
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetupRoutesRegistersWithoutConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("registering routes panicked: %v", r)
+		}
+	}()
+	SetupRoutes(router, &services.Services{})
+
+	want := map[string]bool{
+		"GET /health":                         false,
+		"GET /api/v1/trips/:tripId":           false,
+		"GET /api/v1/trips/:tripId/changes":   false,
+		"GET /api/v1/trips/:tripId/status":    false,
+		"PUT /api/v1/trips/:tripId":           false,
+		"GET /api/v1/trips/:tripId/expenses":  false,
+		"POST /api/v1/trips/:tripId/expenses": false,
+		"GET /api/v1/recommendations":         false,
+		"GET /api/v1/insights":                false,
+		"POST /api/v1/analyze-image":          false,
+	}
+	for _, route := range router.Routes() {
+		key := route.Method + " " + route.Path
+		if _, ok := want[key]; ok {
+			want[key] = true
+		}
+	}
+	for route, found := range want {
+		if !found {
+			t.Errorf("route %s not registered", route)
+		}
+	}
+}
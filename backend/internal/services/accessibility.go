@@ -0,0 +1,78 @@
+package services
+
+import "strings"
+
+// Accessibility statuses reported on attractions and hotels
+const (
+	AccessibilityAccessible   = "accessible"
+	AccessibilityInaccessible = "inaccessible"
+	AccessibilityUnverified   = "unverified"
+)
+
+// unverifiedAccessibilityPenalty scales the score of items with unknown
+// accessibility in lenient mode so verified options rank first
+const unverifiedAccessibilityPenalty = 0.8
+
+// accessibilityMarkers are tags or amenities that confirm step-free access
+var accessibilityMarkers = []string{
+	"wheelchair_accessible",
+	"wheelchair",
+	"step_free",
+	"elevator",
+	"lift",
+	"accessible_restroom",
+	"accessible_room",
+	"accessible",
+}
+
+// inaccessibilityMarkers are tags or amenities that rule step-free access out
+var inaccessibilityMarkers = []string{
+	"not_wheelchair_accessible",
+	"no_wheelchair_access",
+	"stairs_only",
+	"no_elevator",
+}
+
+// accessibilityStatus classifies an item from its tags or amenities
+func accessibilityStatus(labels []string) string {
+	status := AccessibilityUnverified
+	for _, label := range labels {
+		normalized := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(label)), " ", "_")
+		for _, marker := range inaccessibilityMarkers {
+			if normalized == marker {
+				return AccessibilityInaccessible
+			}
+		}
+		for _, marker := range accessibilityMarkers {
+			if normalized == marker {
+				status = AccessibilityAccessible
+			}
+		}
+	}
+	return status
+}
+
+// passesAccessibility applies the criteria's accessibility mode. Items marked
+// inaccessible are always excluded; unverified items are excluded only in
+// strict mode and are otherwise kept and down-ranked.
+func passesAccessibility(status string, criteria ValidationCriteria) bool {
+	if !criteria.Accessibility {
+		return true
+	}
+	switch status {
+	case AccessibilityAccessible:
+		return true
+	case AccessibilityUnverified:
+		return !criteria.StrictAccessibility
+	default:
+		return false
+	}
+}
+
+// accessibilityScoreFactor scales a ranking score by accessibility
+func accessibilityScoreFactor(status string, criteria ValidationCriteria) float64 {
+	if criteria.Accessibility && status == AccessibilityUnverified {
+		return unverifiedAccessibilityPenalty
+	}
+	return 1.0
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAccessibilityStatus(t *testing.T) {
+	tests := []struct {
+		labels []string
+		want   string
+	}{
+		{[]string{"wifi", "Wheelchair Accessible"}, AccessibilityAccessible},
+		{[]string{" elevator "}, AccessibilityAccessible},
+		{[]string{"elevator", "stairs only"}, AccessibilityInaccessible},
+		{[]string{"No_Elevator"}, AccessibilityInaccessible},
+		{[]string{"pool", "gym"}, AccessibilityUnverified},
+		{nil, AccessibilityUnverified},
+	}
+	for _, tt := range tests {
+		if got := accessibilityStatus(tt.labels); got != tt.want {
+			t.Errorf("accessibilityStatus(%q) = %s, want %s", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestAccessibilityRanking(t *testing.T) {
+	// Plain rates best, so it only loses first place to the verified Lift
+	// when unverified items are down-ranked
+	hotels := []Hotel{
+		{Name: "Stairs", Rating: 3.5, Amenities: []string{"stairs only"}},
+		{Name: "Plain", Rating: 4.5},
+		{Name: "Lift", Rating: 4.0, Amenities: []string{"Elevator"}},
+	}
+	tests := []struct {
+		name     string
+		criteria ValidationCriteria
+		want     string
+	}{
+		{"not requested", ValidationCriteria{}, "Plain,Lift,Stairs"},
+		{"lenient down-ranks unverified", ValidationCriteria{Accessibility: true}, "Lift,Plain"},
+		{"strict drops unverified", ValidationCriteria{Accessibility: true, StrictAccessibility: true}, "Lift"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked, err := (&DataValidator{}).ValidateAndRankHotels(context.Background(), hotels, tt.criteria, DefaultRankingWeights())
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(ranked))
+			for i, hotel := range ranked {
+				names[i] = hotel.Name
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("ranked = %s, want %s", got, tt.want)
+			}
+			if tt.criteria.Accessibility && ranked[0].AccessibilityStatus != AccessibilityAccessible {
+				t.Errorf("%s reported as %q, want accessible", ranked[0].Name, ranked[0].AccessibilityStatus)
+			}
+		})
+	}
+}
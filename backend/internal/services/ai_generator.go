@@ -0,0 +1,23 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrAIUnavailable is returned by GenerateText when the provider isn't
+// configured, so callers can fall back without logging a failure
+var ErrAIUnavailable = errors.New("AI generator not configured")
+
+// AIGenerator is the language model provider the services generate
+// itineraries, recommendations and free-form text with. GeminiService is the
+// production implementation.
+type AIGenerator interface {
+	GenerateItinerary(ctx context.Context, req ItineraryRequest) (map[string]interface{}, error)
+	GenerateItineraryWithRAG(ctx context.Context, req ItineraryRequest, ragContext TripContext) (map[string]interface{}, error)
+	GetDestinationRecommendations(ctx context.Context, req RecommendationRequest) ([]map[string]interface{}, error)
+	GetActivitySuggestions(ctx context.Context, destination string, interests []string) ([]string, error)
+	GenerateText(ctx context.Context, prompt string) (string, error)
+}
+
+var _ AIGenerator = (*GeminiService)(nil)
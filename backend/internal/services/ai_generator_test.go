@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+var _ AIGenerator = (*fakeGenerator)(nil)
+
+// fakeGenerator is an AIGenerator that answers from canned responses and
+// records the prompts it was given
+type fakeGenerator struct {
+	mu      sync.Mutex
+	prompts []string
+
+	text func(prompt string) (string, error)
+	err  error
+}
+
+func (f *fakeGenerator) record(prompt string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.prompts = append(f.prompts, prompt)
+}
+
+func (f *fakeGenerator) GenerateItinerary(ctx context.Context, req ItineraryRequest) (map[string]interface{}, error) {
+	return nil, ErrAIUnavailable
+}
+
+func (f *fakeGenerator) GenerateItineraryWithRAG(ctx context.Context, req ItineraryRequest, ragContext TripContext) (map[string]interface{}, error) {
+	return nil, ErrAIUnavailable
+}
+
+func (f *fakeGenerator) GetDestinationRecommendations(ctx context.Context, req RecommendationRequest) ([]map[string]interface{}, error) {
+	return nil, ErrAIUnavailable
+}
+
+func (f *fakeGenerator) GetActivitySuggestions(ctx context.Context, destination string, interests []string) ([]string, error) {
+	return nil, ErrAIUnavailable
+}
+
+func (f *fakeGenerator) GenerateText(ctx context.Context, prompt string) (string, error) {
+	f.record(prompt)
+	if f.err != nil {
+		return "", f.err
+	}
+	if f.text == nil {
+		return "", ErrAIUnavailable
+	}
+	return f.text(prompt)
+}
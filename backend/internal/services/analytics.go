@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Analytics event types
+const (
+	EventTripPlanned          = "trip_planned"
+	EventRecommendationViewed = "recommendation_viewed"
+	EventItineraryDelivered   = "itinerary_delivered"
+	EventChatMessage          = "chat_message"
+)
+
+const (
+	// analyticsBufferSize is how many events can wait for the writer before
+	// new ones are dropped
+	analyticsBufferSize = 1024
+
+	// analyticsBatchSize and analyticsFlushInterval bound how many events,
+	// and for how long, are held before they're exported to BigQuery
+	analyticsBatchSize     = 200
+	analyticsFlushInterval = 30 * time.Second
+
+	// analyticsWriteTimeout bounds each Firestore write and BigQuery export
+	analyticsWriteTimeout = 10 * time.Second
+)
+
+// RequestMetadata describes the client a request came from
+type RequestMetadata struct {
+	SessionID string
+	IPAddress string
+	UserAgent string
+	Referrer  string
+}
+
+type requestMetadataKey struct{}
+
+// WithRequestMetadata returns a context carrying the request's client
+// metadata, which EmitEvent records with each event
+func WithRequestMetadata(ctx context.Context, meta RequestMetadata) context.Context {
+	return context.WithValue(ctx, requestMetadataKey{}, meta)
+}
+
+// RequestMetadataFrom returns the client metadata carried by ctx, if any
+func RequestMetadataFrom(ctx context.Context) RequestMetadata {
+	if ctx == nil {
+		return RequestMetadata{}
+	}
+	meta, _ := ctx.Value(requestMetadataKey{}).(RequestMetadata)
+	return meta
+}
+
+// AnalyticsService records analytics events. Events are queued and written
+// by a background worker: each is saved to Firestore as it arrives, and they
+// are exported to BigQuery in batches.
+type AnalyticsService struct {
+	firebase *FirebaseService
+	bigQuery *BigQueryService
+
+	events   chan models.AnalyticsEvent
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewAnalyticsService creates a new analytics service and starts its
+// writer. bigQuery may be nil, in which case events are only kept in
+// Firestore.
+func NewAnalyticsService(firebase *FirebaseService, bigQuery *BigQueryService) *AnalyticsService {
+	a := &AnalyticsService{
+		firebase: firebase,
+		bigQuery: bigQuery,
+		events:   make(chan models.AnalyticsEvent, analyticsBufferSize),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// EmitEvent queues an analytics event, tagged with the request metadata
+// carried by ctx. It never blocks: when the queue is full or the service is
+// shutting down the event is dropped and logged.
+func (a *AnalyticsService) EmitEvent(ctx context.Context, userID, eventType string, data map[string]interface{}) {
+	if a == nil {
+		return
+	}
+
+	eventData := ""
+	if len(data) > 0 {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to encode analytics event data", "event_type", eventType, "error", err)
+		} else {
+			eventData = string(raw)
+		}
+	}
+
+	meta := RequestMetadataFrom(ctx)
+	event := models.AnalyticsEvent{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		EventType: eventType,
+		EventData: eventData,
+		SessionID: meta.SessionID,
+		IPAddress: meta.IPAddress,
+		UserAgent: meta.UserAgent,
+		Referrer:  meta.Referrer,
+		CreatedAt: time.Now(),
+	}
+
+	select {
+	case <-a.stop:
+		logging.FromContext(ctx).Warn("Analytics service stopped, dropping event", "event_type", eventType)
+		return
+	default:
+	}
+
+	select {
+	case a.events <- event:
+	default:
+		logging.FromContext(ctx).Warn("Analytics queue full, dropping event", "event_type", eventType)
+	}
+}
+
+// run saves queued events and exports them in batches until Shutdown, then
+// drains the queue
+func (a *AnalyticsService) run() {
+	defer close(a.done)
+
+	ticker := time.NewTicker(analyticsFlushInterval)
+	defer ticker.Stop()
+
+	var batch []models.AnalyticsEvent
+	record := func(event models.AnalyticsEvent) {
+		a.save(event)
+		batch = append(batch, event)
+		if len(batch) >= analyticsBatchSize {
+			a.export(batch)
+			batch = nil
+		}
+	}
+
+	for {
+		select {
+		case event := <-a.events:
+			record(event)
+		case <-ticker.C:
+			a.export(batch)
+			batch = nil
+		case <-a.stop:
+			for {
+				select {
+				case event := <-a.events:
+					record(event)
+				default:
+					a.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// save writes an event to the analytics_events collection
+func (a *AnalyticsService) save(event models.AnalyticsEvent) {
+	if a.firebase == nil {
+		return
+	}
+
+	data, err := modelDoc(event)
+	if err != nil {
+		slog.Warn("Failed to encode analytics event", "event_type", event.EventType, "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), analyticsWriteTimeout)
+	defer cancel()
+	if _, err := a.firebase.firestore.Collection("analytics_events").Doc(event.ID).Set(ctx, data); err != nil {
+		slog.Warn("Failed to save analytics event", "event_type", event.EventType, "error", err)
+	}
+}
+
+// export sends a batch of events to BigQuery. A failed batch is only logged;
+// the events remain in Firestore.
+func (a *AnalyticsService) export(batch []models.AnalyticsEvent) {
+	if a.bigQuery == nil || len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), analyticsWriteTimeout)
+	defer cancel()
+	if err := a.bigQuery.ExportAnalyticsEvents(ctx, batch); err != nil {
+		slog.Warn("Failed to export analytics events", "events", len(batch), "error", err)
+	}
+}
+
+// Shutdown stops accepting events and waits for the queued ones to be saved
+// and exported
+func (a *AnalyticsService) Shutdown(ctx context.Context) error {
+	a.stopOnce.Do(func() { close(a.stop) })
+
+	select {
+	case <-a.done:
+		slog.Info("Analytics service shut down successfully")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out flushing analytics events: %w", ctx.Err())
+	}
+}
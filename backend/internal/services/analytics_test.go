@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAnalyticsService(t *testing.T) {
+	meta := RequestMetadata{SessionID: "s1", IPAddress: "203.0.113.7", UserAgent: "test-agent", Referrer: "https://example.com"}
+	tests := []struct {
+		name         string
+		bigQuery     *stubBigQuery
+		afterStop    int
+		wantSaved    int
+		wantExported int
+	}{
+		{name: "saved and exported on shutdown", bigQuery: &stubBigQuery{}, wantSaved: 3, wantExported: 3},
+		{name: "failed export keeps the saved copies", bigQuery: &stubBigQuery{fail: true}, wantSaved: 3},
+		{name: "no BigQuery", wantSaved: 3},
+		{name: "events after shutdown are dropped", bigQuery: &stubBigQuery{}, afterStop: 2, wantSaved: 3, wantExported: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb, fake := newTestFirebase(t)
+			var bq *BigQueryService
+			if tt.bigQuery != nil {
+				bq = newTestBigQuery(t, tt.bigQuery)
+			}
+			analytics := NewAnalyticsService(fb, bq)
+
+			ctx := WithRequestMetadata(context.Background(), meta)
+			analytics.EmitEvent(ctx, "u1", EventTripPlanned, map[string]interface{}{"destination": "Goa"})
+			analytics.EmitEvent(ctx, "u1", EventRecommendationViewed, nil)
+			analytics.EmitEvent(context.Background(), "u2", EventChatMessage, nil)
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := analytics.Shutdown(shutdownCtx); err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.afterStop; i++ {
+				analytics.EmitEvent(ctx, "u1", EventTripPlanned, nil)
+			}
+
+			if got := fake.count("analytics_events"); got != tt.wantSaved {
+				t.Errorf("saved %d events, want %d", got, tt.wantSaved)
+			}
+			exported := 0
+			if tt.bigQuery != nil {
+				exported = tt.bigQuery.insertedCount()
+			}
+			if exported != tt.wantExported {
+				t.Errorf("exported %d events, want %d", exported, tt.wantExported)
+			}
+		})
+	}
+
+	var disabled *AnalyticsService
+	disabled.EmitEvent(context.Background(), "u1", EventTripPlanned, nil)
+}
+
+func TestEmitEventRecordsRequestMetadata(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	analytics := NewAnalyticsService(fb, nil)
+	ctx := WithRequestMetadata(context.Background(), RequestMetadata{SessionID: "s1", IPAddress: "203.0.113.7", UserAgent: "test-agent"})
+	analytics.EmitEvent(ctx, "u1", EventTripPlanned, map[string]interface{}{"destination": "Goa"})
+	if err := analytics.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	docs, err := fb.firestore.Collection("analytics_events").Documents(context.Background()).GetAll()
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("saved %d events (%v), want 1", len(docs), err)
+	}
+	want := map[string]string{
+		"user_id":    "u1",
+		"event_type": EventTripPlanned,
+		"event_data": `{"destination":"Goa"}`,
+		"session_id": "s1",
+		"ip_address": "203.0.113.7",
+		"user_agent": "test-agent",
+	}
+	for field, value := range want {
+		if got := docs[0].Data()[field]; got != value {
+			t.Errorf("%s = %q, want %q", field, got, value)
+		}
+	}
+}
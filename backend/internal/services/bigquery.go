@@ -4,21 +4,32 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/models"
 
 	"cloud.google.com/go/bigquery"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+// trendingCacheTTL controls how long the user-independent trending
+// destinations query is reused
+const trendingCacheTTL = time.Hour
+
 // BigQueryService handles BigQuery analytics operations
 type BigQueryService struct {
 	client    *bigquery.Client
 	projectID string
 	dataset   string
 	cfg       *config.Config
+
+	trendingMu       sync.Mutex
+	trendingCache    []DestinationTrend
+	trendingLimit    int
+	trendingCachedAt time.Time
 }
 
 // NewBigQueryService creates a new BigQuery service
@@ -44,7 +55,7 @@ func NewBigQueryService() (*BigQueryService, error) {
 	return &BigQueryService{
 		client:    client,
 		projectID: cfg.GoogleCloudProjectID,
-		dataset:   "auratravel_analytics", // Default dataset name
+		dataset:   cfg.BigQueryDataset,
 		cfg:       cfg,
 	}, nil
 }
@@ -69,13 +80,13 @@ type TravelAnalytics struct {
 
 // DestinationTrend represents destination popularity trends
 type DestinationTrend struct {
-	Destination  string  `bigquery:"destination"`
-	Period       string  `bigquery:"period"`
-	SearchCount  int64   `bigquery:"search_count"`
-	BookingCount int64   `bigquery:"booking_count"`
-	AvgCost      float64 `bigquery:"avg_cost"`
-	AvgRating    float64 `bigquery:"avg_rating"`
-	TrendScore   float64 `bigquery:"trend_score"`
+	Destination  string  `bigquery:"destination" json:"destination"`
+	Period       string  `bigquery:"period" json:"period"`
+	SearchCount  int64   `bigquery:"search_count" json:"search_count"`
+	BookingCount int64   `bigquery:"booking_count" json:"booking_count"`
+	AvgCost      float64 `bigquery:"avg_cost" json:"avg_cost"`
+	AvgRating    float64 `bigquery:"avg_rating" json:"avg_rating"`
+	TrendScore   float64 `bigquery:"trend_score" json:"trend_score"`
 }
 
 // UserBehaviorInsight represents user behavior analytics
@@ -107,6 +118,56 @@ func (bq *BigQueryService) StoreTravelAnalytics(ctx context.Context, analytics [
 	return nil
 }
 
+// AnalyticsEventRow is an analytics event as exported to BigQuery
+type AnalyticsEventRow struct {
+	ID        string    `bigquery:"id"`
+	UserID    string    `bigquery:"user_id"`
+	EventType string    `bigquery:"event_type"`
+	EventData string    `bigquery:"event_data"`
+	SessionID string    `bigquery:"session_id"`
+	IPAddress string    `bigquery:"ip_address"`
+	UserAgent string    `bigquery:"user_agent"`
+	Referrer  string    `bigquery:"referrer"`
+	CreatedAt time.Time `bigquery:"created_at"`
+}
+
+// ExportAnalyticsEvents appends analytics events to the analytics_events
+// table. Rows are inserted with the event ID as insert ID, so a retried batch
+// isn't duplicated.
+func (bq *BigQueryService) ExportAnalyticsEvents(ctx context.Context, events []models.AnalyticsEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	table := bq.client.Dataset(bq.dataset).Table("analytics_events")
+
+	if err := bq.ensureTable(ctx, table, AnalyticsEventRow{}); err != nil {
+		return fmt.Errorf("failed to ensure table exists: %v", err)
+	}
+
+	rows := make([]*bigquery.StructSaver, len(events))
+	for i, event := range events {
+		rows[i] = &bigquery.StructSaver{
+			Struct: AnalyticsEventRow{
+				ID:        event.ID,
+				UserID:    event.UserID,
+				EventType: event.EventType,
+				EventData: event.EventData,
+				SessionID: event.SessionID,
+				IPAddress: event.IPAddress,
+				UserAgent: event.UserAgent,
+				Referrer:  event.Referrer,
+				CreatedAt: event.CreatedAt,
+			},
+			InsertID: event.ID,
+		}
+	}
+
+	if err := table.Inserter().Put(ctx, rows); err != nil {
+		return fmt.Errorf("failed to insert analytics events: %v", err)
+	}
+	return nil
+}
+
 // GetDestinationTrends analyzes destination popularity trends
 func (bq *BigQueryService) GetDestinationTrends(ctx context.Context, period string, limit int) ([]DestinationTrend, error) {
 	query := fmt.Sprintf(`
@@ -118,7 +179,7 @@ func (bq *BigQueryService) GetDestinationTrends(ctx context.Context, period stri
 				AVG(total_cost) as avg_cost,
 				AVG(satisfaction) as avg_rating
 			FROM %s.%s.travel_analytics 
-			WHERE travel_date >= DATE_SUB(CURRENT_DATE(), INTERVAL %s)
+			WHERE travel_date >= TIMESTAMP(DATE_SUB(CURRENT_DATE(), INTERVAL %s))
 			GROUP BY destination
 		),
 		trend_calculation AS (
@@ -163,6 +224,114 @@ func (bq *BigQueryService) GetDestinationTrends(ctx context.Context, period stri
 	return trends, nil
 }
 
+// GetTrendingDestinations returns the top destinations of the last 30 days.
+// The result doesn't depend on the caller, so it is cached for
+// trendingCacheTTL.
+func (bq *BigQueryService) GetTrendingDestinations(ctx context.Context, limit int) ([]DestinationTrend, error) {
+	bq.trendingMu.Lock()
+	if bq.trendingCache != nil && bq.trendingLimit >= limit && time.Since(bq.trendingCachedAt) < trendingCacheTTL {
+		trends := bq.trendingCache
+		bq.trendingMu.Unlock()
+		if len(trends) > limit {
+			trends = trends[:limit]
+		}
+		return trends, nil
+	}
+	bq.trendingMu.Unlock()
+
+	trends, err := bq.GetDestinationTrends(ctx, "30 DAY", limit)
+	if err != nil {
+		return nil, err
+	}
+	if trends == nil {
+		trends = []DestinationTrend{}
+	}
+
+	bq.trendingMu.Lock()
+	bq.trendingCache = trends
+	bq.trendingLimit = limit
+	bq.trendingCachedAt = time.Now()
+	bq.trendingMu.Unlock()
+
+	return trends, nil
+}
+
+// DailyPrice is the average trip cost on one day
+type DailyPrice struct {
+	Date      string  `bigquery:"date" json:"date"`
+	AvgCost   float64 `bigquery:"avg_cost" json:"avg_cost"`
+	TripCount int64   `bigquery:"trip_count" json:"trip_count"`
+}
+
+// PriceTrendSummary aggregates a user's trip costs over a period
+type PriceTrendSummary struct {
+	UserID    string       `json:"user_id"`
+	Days      int          `json:"days"`
+	TripCount int64        `json:"trip_count"`
+	AvgCost   float64      `json:"avg_cost"`
+	MinCost   float64      `json:"min_cost"`
+	MaxCost   float64      `json:"max_cost"`
+	Daily     []DailyPrice `json:"daily"`
+}
+
+// GetUserPriceTrends aggregates the user's trip costs over the last days days
+func (bq *BigQueryService) GetUserPriceTrends(ctx context.Context, userID string, days int) (*PriceTrendSummary, error) {
+	query := bq.client.Query(fmt.Sprintf(`
+		SELECT
+			FORMAT_DATE('%%Y-%%m-%%d', DATE(travel_date)) as date,
+			AVG(total_cost) as avg_cost,
+			COUNT(*) as trip_count
+		FROM %s.%s.travel_analytics
+		WHERE user_id = @user_id
+			AND travel_date >= TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL @days DAY)
+		GROUP BY date
+		ORDER BY date
+	`, bq.projectID, bq.dataset))
+	query.Parameters = []bigquery.QueryParameter{
+		{Name: "user_id", Value: userID},
+		{Name: "days", Value: days},
+	}
+
+	it, err := query.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute price trends query: %v", err)
+	}
+
+	summary := &PriceTrendSummary{
+		UserID: userID,
+		Days:   days,
+		Daily:  []DailyPrice{},
+	}
+
+	var totalCost float64
+	for {
+		var day DailyPrice
+		err := it.Next(&day)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read price trend: %v", err)
+		}
+
+		if summary.TripCount == 0 || day.AvgCost < summary.MinCost {
+			summary.MinCost = day.AvgCost
+		}
+		if day.AvgCost > summary.MaxCost {
+			summary.MaxCost = day.AvgCost
+		}
+		totalCost += day.AvgCost * float64(day.TripCount)
+		summary.TripCount += day.TripCount
+		summary.Daily = append(summary.Daily, day)
+	}
+
+	if summary.TripCount > 0 {
+		summary.AvgCost = totalCost / float64(summary.TripCount)
+	}
+
+	return summary, nil
+}
+
 // GetUserBehaviorInsights analyzes user behavior patterns
 func (bq *BigQueryService) GetUserBehaviorInsights(ctx context.Context) ([]UserBehaviorInsight, error) {
 	query := fmt.Sprintf(`
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/option"
+)
+
+// stubBigQuery answers jobs.query calls with a fixed result set. columns are
+// "name:TYPE" pairs; every row holds one string per column. It also reports
+// every table as existing and records the insert IDs of streamed rows.
+type stubBigQuery struct {
+	mu       sync.Mutex
+	columns  []string
+	rows     [][]string
+	fail     bool
+	queries  []string
+	params   []map[string]string
+	inserted []string
+}
+
+func (s *stubBigQuery) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/tables/"):
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"kind":"bigquery#table","type":"TABLE"}`))
+		return
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/insertAll"):
+		s.serveInsertAll(w, r)
+		return
+	}
+
+	var req struct {
+		Query           string `json:"query"`
+		QueryParameters []struct {
+			Name           string `json:"name"`
+			ParameterValue struct {
+				Value string `json:"value"`
+			} `json:"parameterValue"`
+		} `json:"queryParameters"`
+	}
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/queries") || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, `{"error":{"code":400,"message":"unexpected request"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	params := map[string]string{}
+	for _, p := range req.QueryParameters {
+		params[p.Name] = p.ParameterValue.Value
+	}
+	s.queries = append(s.queries, req.Query)
+	s.params = append(s.params, params)
+
+	w.Header().Set("Content-Type", "application/json")
+	if s.fail {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"Unrecognized name: travel_date","errors":[{"reason":"invalidQuery"}]}}`))
+		return
+	}
+
+	fields := make([]map[string]string, len(s.columns))
+	for i, column := range s.columns {
+		name, typ, _ := strings.Cut(column, ":")
+		fields[i] = map[string]string{"name": name, "type": typ}
+	}
+	rows := make([]map[string]interface{}, len(s.rows))
+	for i, row := range s.rows {
+		cells := make([]map[string]string, len(row))
+		for j, value := range row {
+			cells[j] = map[string]string{"v": value}
+		}
+		rows[i] = map[string]interface{}{"f": cells}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"kind":         "bigquery#queryResponse",
+		"jobComplete":  true,
+		"jobReference": map[string]string{"projectId": "test", "jobId": "job-1", "location": "US"},
+		"schema":       map[string]interface{}{"fields": fields},
+		"rows":         rows,
+		"totalRows":    strconv.Itoa(len(rows)),
+	})
+}
+
+func (s *stubBigQuery) serveInsertAll(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Rows []struct {
+			InsertID string `json:"insertId"`
+		} `json:"rows"`
+	}
+	if json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, `{"error":{"code":400,"message":"unexpected request"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if s.fail {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"code":400,"message":"no such field: referrer","errors":[{"reason":"invalid"}]}}`))
+		return
+	}
+	for _, row := range req.Rows {
+		s.inserted = append(s.inserted, row.InsertID)
+	}
+	w.Write([]byte(`{"kind":"bigquery#tableDataInsertAllResponse"}`))
+}
+
+func (s *stubBigQuery) insertedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.inserted)
+}
+
+func (s *stubBigQuery) queryCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queries)
+}
+
+// newTestBigQuery returns a BigQueryService whose client talks to stub
+func newTestBigQuery(t *testing.T, stub *stubBigQuery) *BigQueryService {
+	t.Helper()
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	client, err := bigquery.NewClient(context.Background(), "test", option.WithEndpoint(server.URL+"/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return &BigQueryService{client: client, projectID: "test", dataset: "analytics"}
+}
+
+func TestGetUserPriceTrends(t *testing.T) {
+	columns := []string{"date:STRING", "avg_cost:FLOAT", "trip_count:INTEGER"}
+	tests := []struct {
+		name      string
+		rows      [][]string
+		fail      bool
+		wantTrips int64
+		wantAvg   float64
+		wantMin   float64
+		wantMax   float64
+	}{
+		{"weighted by trips", [][]string{{"2026-05-01", "1000", "1"}, {"2026-05-02", "4000", "3"}, {"2026-05-03", "2500", "1"}}, false, 5, 3100, 1000, 4000},
+		{"single day", [][]string{{"2026-05-01", "1800", "2"}}, false, 2, 1800, 1800, 1800},
+		{"no trips", nil, false, 0, 0, 0, 0},
+		{"query fails", nil, true, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &stubBigQuery{columns: columns, rows: tt.rows, fail: tt.fail}
+			summary, err := newTestBigQuery(t, stub).GetUserPriceTrends(context.Background(), "u1", 30)
+			if tt.fail {
+				if err == nil {
+					t.Error("GetUserPriceTrends succeeded on a failing query")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.TripCount != tt.wantTrips || math.Abs(summary.AvgCost-tt.wantAvg) > 1e-9 || summary.MinCost != tt.wantMin || summary.MaxCost != tt.wantMax {
+				t.Errorf("summary = %d trips, avg %.2f, min %.2f, max %.2f; want %d, %.2f, %.2f, %.2f",
+					summary.TripCount, summary.AvgCost, summary.MinCost, summary.MaxCost, tt.wantTrips, tt.wantAvg, tt.wantMin, tt.wantMax)
+			}
+			if summary.Daily == nil || len(summary.Daily) != len(tt.rows) {
+				t.Errorf("daily = %v, want %d days", summary.Daily, len(tt.rows))
+			}
+			if params := stub.params[0]; params["user_id"] != "u1" || params["days"] != "30" {
+				t.Errorf("query parameters = %v, want the user and period bound as parameters", params)
+			}
+		})
+	}
+}
+
+func TestGetTrendingDestinations(t *testing.T) {
+	stub := &stubBigQuery{
+		columns: []string{"destination:STRING", "period:STRING", "search_count:INTEGER", "booking_count:INTEGER", "avg_cost:FLOAT", "avg_rating:FLOAT", "trend_score:FLOAT"},
+		rows: [][]string{
+			{"Goa", "30 DAY", "120", "40", "18000", "4.5", "0.82"},
+			{"Jaipur", "30 DAY", "90", "30", "15000", "4.6", "0.74"},
+			{"Kochi", "30 DAY", "60", "10", "12000", "4.2", "0.51"},
+		},
+	}
+	bq := newTestBigQuery(t, stub)
+
+	tests := []struct {
+		name        string
+		limit       int
+		want        string
+		wantQueries int
+	}{
+		{"first call queries", 3, "Goa,Jaipur,Kochi", 1},
+		{"same limit is cached", 3, "Goa,Jaipur,Kochi", 1},
+		{"smaller limit is served from the cache", 2, "Goa,Jaipur", 1},
+		{"larger limit queries again", 5, "Goa,Jaipur,Kochi", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trends, err := bq.GetTrendingDestinations(context.Background(), tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := make([]string, len(trends))
+			for i, trend := range trends {
+				names[i] = trend.Destination
+			}
+			if got := strings.Join(names, ","); got != tt.want || stub.queryCount() != tt.wantQueries {
+				t.Errorf("GetTrendingDestinations(%d) = %s after %d queries, want %s after %d", tt.limit, got, stub.queryCount(), tt.want, tt.wantQueries)
+			}
+		})
+	}
+
+	if trends, _ := bq.GetTrendingDestinations(context.Background(), 1); trends[0].SearchCount != 120 || trends[0].TrendScore != 0.82 {
+		t.Errorf("trend = %+v, want the row's counts and score", trends[0])
+	}
+}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Booking statuses of an itinerary item. Items the traveler wants booked are
+// marked pending; ConfirmBookings claims them as booking while their
+// providers are called, then moves them to confirmed or failed. Failed items
+// are retried on the next call.
+const (
+	BookingPending    = "pending"
+	BookingInProgress = "booking"
+	BookingConfirmed  = "confirmed"
+	BookingFailed     = "failed"
+)
+
+// bookingClaimLease is how long an item stays claimed by a ConfirmBookings
+// call. A call that dies mid-booking leaves its items claimed; after the
+// lease they can be booked again.
+const bookingClaimLease = 10 * time.Minute
+
+// Kinds of bookable itinerary item, each booked by its own provider
+const (
+	BookingHotel     = "hotel"
+	BookingTransport = "transport"
+	BookingActivity  = "activity"
+)
+
+// BookingRequest is one itinerary item to book with a provider
+type BookingRequest struct {
+	// Reference identifies the item in its trip and stays the same across
+	// retries, so a provider can refuse to book it twice
+	Reference  string                 `json:"reference"`
+	TripID     string                 `json:"trip_id"`
+	Kind       string                 `json:"kind"`
+	Name       string                 `json:"name"`
+	Provider   string                 `json:"provider,omitempty"`
+	BookingURL string                 `json:"booking_url,omitempty"`
+	Price      float64                `json:"price,omitempty"`
+	Travelers  int                    `json:"travelers"`
+	Item       map[string]interface{} `json:"item"`
+}
+
+// BookingProvider books one kind of itinerary item with an external
+// provider
+type BookingProvider interface {
+	// Book books the item and returns its confirmation number
+	Book(ctx context.Context, req BookingRequest) (string, error)
+	// Cancel cancels a booking Book confirmed
+	Cancel(ctx context.Context, req BookingRequest, confirmation string) error
+}
+
+// BookingResult is the outcome of booking one item
+type BookingResult struct {
+	Reference          string `json:"reference"`
+	Kind               string `json:"kind"`
+	Name               string `json:"name"`
+	Status             string `json:"status"`
+	ConfirmationNumber string `json:"confirmation_number,omitempty"`
+	Error              string `json:"error,omitempty"`
+}
+
+// BookingSummary reports a ConfirmBookings call item by item
+type BookingSummary struct {
+	TripID    string          `json:"trip_id"`
+	Total     int             `json:"total"`
+	Confirmed int             `json:"confirmed"`
+	Failed    int             `json:"failed"`
+	Results   []BookingResult `json:"results"`
+}
+
+// BookingService confirms a trip's pending bookings with the providers for
+// each kind of item
+type BookingService struct {
+	firebase      *FirebaseService
+	notifications *NotificationService
+	providers     map[string]BookingProvider
+}
+
+// NewBookingService creates a booking service with no providers. Bookings
+// are unavailable until SetProvider configures one.
+func NewBookingService(firebase *FirebaseService, notifications *NotificationService) *BookingService {
+	return &BookingService{
+		firebase:      firebase,
+		notifications: notifications,
+		providers:     make(map[string]BookingProvider),
+	}
+}
+
+// SetProvider sets the provider that books items of kind
+func (b *BookingService) SetProvider(kind string, provider BookingProvider) {
+	b.providers[kind] = provider
+}
+
+// NewBookingProvider returns the booking provider configured by name
+func NewBookingProvider(name string) (BookingProvider, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "mock":
+		return &MockBookingProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown booking provider %q", name)
+	}
+}
+
+// pendingBooking is an itinerary item waiting to be booked, with the path
+// of keys and indexes leading to it from the itinerary
+type pendingBooking struct {
+	path    []interface{}
+	request BookingRequest
+}
+
+// ConfirmBookings books each of the trip's pending hotels, transport and
+// activities with its provider, then records the confirmation numbers and
+// statuses in one transaction. Items are first claimed in a transaction, so
+// concurrent calls never book the same item twice. An item its provider
+// can't book is marked failed without affecting the rest. If the statuses
+// can't be saved, every booking just made is cancelled so none is left
+// untracked. Each confirmed booking is notified to the trip's owner. Without
+// any provider configured bookings are unavailable.
+func (b *BookingService) ConfirmBookings(ctx context.Context, tripID string) (*BookingSummary, error) {
+	if len(b.providers) == 0 {
+		return nil, newKindError(ErrUnavailable, "no booking providers are configured")
+	}
+	trip, err := b.firebase.GetTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := b.claimBookings(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim bookings: %w", err)
+	}
+	summary := &BookingSummary{TripID: tripID, Total: len(pending), Results: make([]BookingResult, 0, len(pending))}
+	if len(pending) == 0 {
+		return summary, nil
+	}
+
+	for _, item := range pending {
+		summary.Results = append(summary.Results, b.book(ctx, item.request))
+	}
+
+	// cancel undoes the confirmed bookings among the results at indexes
+	cancel := func(indexes []int) {
+		for _, i := range indexes {
+			result := summary.Results[i]
+			if result.Status != BookingConfirmed {
+				continue
+			}
+			if err := b.providers[result.Kind].Cancel(ctx, pending[i].request, result.ConfirmationNumber); err != nil {
+				log.Printf("Failed to cancel booking %s (%s) for trip %s: %v", result.ConfirmationNumber, result.Reference, tripID, err)
+			}
+		}
+	}
+
+	removed, err := b.saveBookings(ctx, tripID, pending, summary.Results)
+	if err != nil {
+		all := make([]int, len(summary.Results))
+		for i := range all {
+			all[i] = i
+		}
+		cancel(all)
+		return nil, fmt.Errorf("failed to save bookings, so they were cancelled: %w", err)
+	}
+	// Items removed from the itinerary while they were being booked have
+	// nowhere to record their booking
+	cancel(removed)
+	for _, i := range removed {
+		if summary.Results[i].Status == BookingConfirmed {
+			summary.Results[i].Status = BookingFailed
+			summary.Results[i].ConfirmationNumber = ""
+			summary.Results[i].Error = "item was removed from the itinerary while it was being booked"
+		}
+	}
+
+	for _, result := range summary.Results {
+		if result.Status != BookingConfirmed {
+			summary.Failed++
+			continue
+		}
+		summary.Confirmed++
+		if b.notifications != nil {
+			if _, err := b.notifications.SendBookingConfirmation(ctx, trip.UserID, tripID, result.Kind, result.ConfirmationNumber); err != nil {
+				log.Printf("Failed to send booking confirmation for %s: %v", result.Reference, err)
+			}
+		}
+	}
+	return summary, nil
+}
+
+// book books one item with the provider for its kind
+func (b *BookingService) book(ctx context.Context, req BookingRequest) BookingResult {
+	result := BookingResult{Reference: req.Reference, Kind: req.Kind, Name: req.Name}
+	provider, ok := b.providers[req.Kind]
+	if !ok {
+		result.Status = BookingFailed
+		result.Error = fmt.Sprintf("no booking provider for %s", req.Kind)
+		return result
+	}
+
+	confirmation, err := provider.Book(ctx, req)
+	if err != nil {
+		result.Status = BookingFailed
+		result.Error = err.Error()
+		log.Printf("Failed to book %s for trip %s: %v", req.Reference, req.TripID, err)
+		return result
+	}
+	result.Status = BookingConfirmed
+	result.ConfirmationNumber = confirmation
+	return result
+}
+
+// claimBookings marks the trip's pending items as booking in a transaction
+// and returns them. Items another call has claimed within the lease are
+// left to it.
+func (b *BookingService) claimBookings(ctx context.Context, tripID string) ([]pendingBooking, error) {
+	ref := b.firebase.firestore.Collection("trips").Doc(tripID)
+	var claimed []pendingBooking
+	err := b.firebase.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var trip TripData
+		if err := doc.DataTo(&trip); err != nil {
+			return err
+		}
+		trip.ID = tripID
+
+		now := time.Now()
+		claimed = pendingBookings(&trip, now)
+		if len(claimed) == 0 {
+			return nil
+		}
+		for _, item := range claimed {
+			target := itineraryItemAt(trip.Itinerary, item.path)
+			target["booking_status"] = BookingInProgress
+			target["booking_claimed_at"] = now
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "itinerary", Value: trip.Itinerary},
+			{Path: "updated_at", Value: firestore.ServerTimestamp},
+		})
+	})
+	return claimed, err
+}
+
+// saveBookings records the results in the trip's itinerary in a transaction,
+// re-reading the itinerary so concurrent edits aren't lost. It returns the
+// indexes of the items no longer in the itinerary.
+func (b *BookingService) saveBookings(ctx context.Context, tripID string, pending []pendingBooking, results []BookingResult) ([]int, error) {
+	ref := b.firebase.firestore.Collection("trips").Doc(tripID)
+	var removed []int
+	err := b.firebase.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		removed = nil
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var trip TripData
+		if err := doc.DataTo(&trip); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for i, item := range pending {
+			target := itineraryItemAt(trip.Itinerary, item.path)
+			if target == nil || activityName(target) != item.request.Name {
+				removed = append(removed, i)
+				continue
+			}
+			result := results[i]
+			target["booking_status"] = result.Status
+			delete(target, "booking_claimed_at")
+			if result.Status == BookingConfirmed {
+				target["booking_reference"] = result.ConfirmationNumber
+				target["booked_at"] = now
+				delete(target, "booking_error")
+			} else {
+				target["booking_error"] = result.Error
+			}
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "itinerary", Value: trip.Itinerary},
+			{Path: "updated_at", Value: firestore.ServerTimestamp},
+		})
+	})
+	return removed, err
+}
+
+// pendingBookings finds the items in a trip's itinerary marked for booking
+// at now: its hotel and recommended hotels, its transport, and the
+// activities of each day. Items whose claim has outlived its lease count as
+// pending again.
+func pendingBookings(trip *TripData, now time.Time) []pendingBooking {
+	var pending []pendingBooking
+	add := func(kind string, path []interface{}, item map[string]interface{}) {
+		switch strings.ToLower(getStringFromMetadata(item, "booking_status")) {
+		case BookingPending, BookingFailed:
+		case BookingInProgress:
+			claimedAt, _ := item["booking_claimed_at"].(time.Time)
+			if now.Sub(claimedAt) < bookingClaimLease {
+				return
+			}
+		default:
+			return
+		}
+		segments := make([]string, len(path))
+		for i, segment := range path {
+			segments[i] = fmt.Sprint(segment)
+		}
+		pending = append(pending, pendingBooking{
+			path: path,
+			request: BookingRequest{
+				Reference:  trip.ID + "/" + strings.Join(segments, "."),
+				TripID:     trip.ID,
+				Kind:       kind,
+				Name:       activityName(item),
+				Provider:   getStringFromMetadata(item, "provider"),
+				BookingURL: getStringFromMetadata(item, "booking_url"),
+				Price:      floatValue(item, "price", "price_per_night", "cost"),
+				Travelers:  trip.Travelers,
+				Item:       item,
+			},
+		})
+	}
+	// walk calls add for the item at path, or each item of a list there
+	walk := func(kind string, path []interface{}, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			add(kind, path, v)
+		case []interface{}:
+			for i, element := range v {
+				if item, ok := element.(map[string]interface{}); ok {
+					add(kind, append(append([]interface{}{}, path...), i), item)
+				}
+			}
+		}
+	}
+
+	itinerary := trip.Itinerary
+	for _, key := range []string{"hotel", "accommodation", "recommended_hotels"} {
+		walk(BookingHotel, []interface{}{key}, itinerary[key])
+	}
+	for _, key := range []string{"transportation", "transport"} {
+		walk(BookingTransport, []interface{}{key}, itinerary[key])
+	}
+
+	var dayPath []interface{}
+	days := itinerary
+	for _, key := range []string{"itinerary", "daily_itinerary"} {
+		if nested, ok := itinerary[key].(map[string]interface{}); ok {
+			dayPath, days = []interface{}{key}, nested
+			break
+		}
+	}
+	for dayKey, value := range days {
+		dayPlan, ok := value.(map[string]interface{})
+		if !ok || !planDayKey.MatchString(dayKey) {
+			continue
+		}
+		for _, slot := range planSlots {
+			path := append(append([]interface{}{}, dayPath...), dayKey, slot)
+			walk(BookingActivity, path, dayPlan[slot])
+		}
+	}
+	return pending
+}
+
+// itineraryItemAt follows a path of keys and indexes from pendingBookings to
+// an item, returning nil when there's no longer an item there
+func itineraryItemAt(itinerary map[string]interface{}, path []interface{}) map[string]interface{} {
+	var current interface{} = itinerary
+	for _, segment := range path {
+		switch key := segment.(type) {
+		case string:
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			current = object[key]
+		case int:
+			list, ok := current.([]interface{})
+			if !ok || key >= len(list) {
+				return nil
+			}
+			current = list[key]
+		}
+	}
+	item, _ := current.(map[string]interface{})
+	return item
+}
+
+// MockBookingProvider confirms every booking with a confirmation number
+// derived from its reference, so retries get the same number. Items named in
+// Unavailable fail instead, to exercise partial failures.
+type MockBookingProvider struct {
+	Unavailable []string
+}
+
+var _ BookingProvider = (*MockBookingProvider)(nil)
+
+// Book confirms the booking unless the item is unavailable
+func (m *MockBookingProvider) Book(ctx context.Context, req BookingRequest) (string, error) {
+	for _, name := range m.Unavailable {
+		if strings.EqualFold(name, req.Name) {
+			return "", fmt.Errorf("%s is fully booked", req.Name)
+		}
+	}
+	sum := sha256.Sum256([]byte(req.Reference))
+	return "MOCK-" + strings.ToUpper(hex.EncodeToString(sum[:4])), nil
+}
+
+// Cancel always succeeds
+func (m *MockBookingProvider) Cancel(ctx context.Context, req BookingRequest, confirmation string) error {
+	return nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPendingBookingsSkipsLiveClaims(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	trip := &TripData{
+		ID: "trip-1",
+		Itinerary: map[string]interface{}{
+			"hotel": map[string]interface{}{"name": "Taj", "booking_status": BookingPending},
+			"transportation": []interface{}{
+				map[string]interface{}{"name": "Train", "booking_status": BookingInProgress, "booking_claimed_at": now.Add(-time.Minute)},
+				map[string]interface{}{"name": "Flight", "booking_status": BookingInProgress, "booking_claimed_at": now.Add(-bookingClaimLease)},
+				map[string]interface{}{"name": "Bus", "booking_status": BookingConfirmed},
+			},
+		},
+	}
+
+	names := map[string]bool{}
+	for _, item := range pendingBookings(trip, now) {
+		names[item.request.Name] = true
+	}
+	if len(names) != 2 || !names["Taj"] || !names["Flight"] {
+		t.Errorf("pendingBookings = %v, want Taj and the expired Flight claim", names)
+	}
+}
+
+func TestConfirmBookingsWithoutProvidersIsUnavailable(t *testing.T) {
+	_, err := NewBookingService(nil, nil).ConfirmBookings(context.Background(), "trip-1")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("ConfirmBookings error = %v, want ErrUnavailable", err)
+	}
+}
+
+// recordingProvider books like MockBookingProvider and records what it
+// cancels
+type recordingProvider struct {
+	MockBookingProvider
+	cancelled []string
+}
+
+func (r *recordingProvider) Cancel(ctx context.Context, req BookingRequest, confirmation string) error {
+	r.cancelled = append(r.cancelled, req.Name)
+	return nil
+}
+
+func seedBookableTrip(t *testing.T, fb *FirebaseService) {
+	t.Helper()
+	pending := func(name string) map[string]interface{} {
+		return map[string]interface{}{"name": name, "booking_status": BookingPending}
+	}
+	seed(t, fb, "trips/t1", map[string]interface{}{
+		"user_id":   "u1",
+		"travelers": 2,
+		"itinerary": map[string]interface{}{
+			"hotel":          pending("Taj"),
+			"transportation": []interface{}{pending("Train")},
+			"day_1":          map[string]interface{}{"morning": []interface{}{pending("Fort tour"), map[string]interface{}{"name": "Walk"}}},
+		},
+	})
+}
+
+// bookingStatuses reads each named item's booking status from the stored trip
+func bookingStatuses(t *testing.T, fb *FirebaseService) string {
+	t.Helper()
+	trip, err := fb.GetTrip(context.Background(), "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, path := range [][]interface{}{{"hotel"}, {"transportation", 0}, {"day_1", "morning", 0}} {
+		item := itineraryItemAt(trip.Itinerary, path)
+		statuses = append(statuses, fmt.Sprintf("%s=%v", item["name"], item["booking_status"]))
+	}
+	return strings.Join(statuses, ",")
+}
+
+func TestConfirmBookings(t *testing.T) {
+	tests := []struct {
+		name          string
+		unavailable   []string
+		wantConfirmed int
+		wantFailed    int
+		wantStatuses  string
+	}{
+		{"all booked", nil, 3, 0, "Taj=confirmed,Train=confirmed,Fort tour=confirmed"},
+		{"partial failure", []string{"Train"}, 2, 1, "Taj=confirmed,Train=failed,Fort tour=confirmed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fb, _ := newTestFirebase(t)
+			seedBookableTrip(t, fb)
+			provider := &recordingProvider{MockBookingProvider: MockBookingProvider{Unavailable: tt.unavailable}}
+			b := NewBookingService(fb, nil)
+			for _, kind := range []string{BookingHotel, BookingTransport, BookingActivity} {
+				b.SetProvider(kind, provider)
+			}
+
+			summary, err := b.ConfirmBookings(context.Background(), "t1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if summary.Total != 3 || summary.Confirmed != tt.wantConfirmed || summary.Failed != tt.wantFailed {
+				t.Errorf("summary = %d total, %d confirmed, %d failed; want 3, %d, %d", summary.Total, summary.Confirmed, summary.Failed, tt.wantConfirmed, tt.wantFailed)
+			}
+			for _, result := range summary.Results {
+				if (result.Status == BookingConfirmed) != strings.HasPrefix(result.ConfirmationNumber, "MOCK-") {
+					t.Errorf("%s is %s with confirmation %q", result.Name, result.Status, result.ConfirmationNumber)
+				}
+			}
+			if got := bookingStatuses(t, fb); got != tt.wantStatuses {
+				t.Errorf("stored statuses = %s, want %s", got, tt.wantStatuses)
+			}
+			if len(provider.cancelled) != 0 {
+				t.Errorf("cancelled %v after a successful save", provider.cancelled)
+			}
+
+			// Only the failed items are booked again
+			again, err := b.ConfirmBookings(context.Background(), "t1")
+			if err != nil || again.Total != tt.wantFailed {
+				t.Errorf("second ConfirmBookings = %+v, %v; want %d items retried", again, err, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestConfirmBookingsCancelsWhenSaveFails(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	seedBookableTrip(t, fb)
+	provider := &recordingProvider{MockBookingProvider: MockBookingProvider{Unavailable: []string{"Train"}}}
+	b := NewBookingService(fb, nil)
+	for _, kind := range []string{BookingHotel, BookingTransport, BookingActivity} {
+		b.SetProvider(kind, provider)
+	}
+
+	// The claim commits; saving the results doesn't
+	var commits int32
+	fake.failCommit = func(*pb.CommitRequest) error {
+		if atomic.AddInt32(&commits, 1) > 1 {
+			return status.Error(codes.PermissionDenied, "save rejected")
+		}
+		return nil
+	}
+
+	if _, err := b.ConfirmBookings(context.Background(), "t1"); err == nil {
+		t.Fatal("ConfirmBookings succeeded without saving")
+	}
+	sort.Strings(provider.cancelled)
+	if got := strings.Join(provider.cancelled, ","); got != "Fort tour,Taj" {
+		t.Errorf("cancelled %s, want the confirmed Fort tour and Taj", got)
+	}
+	if got := bookingStatuses(t, fb); got != "Taj=booking,Train=booking,Fort tour=booking" {
+		t.Errorf("stored statuses = %s, want the items left claimed", got)
+	}
+}
+
+func TestNewBookingProvider(t *testing.T) {
+	if provider, err := NewBookingProvider("Mock"); err != nil || provider == nil {
+		t.Errorf("NewBookingProvider(Mock) = %v, %v", provider, err)
+	}
+	if _, err := NewBookingProvider("expedia"); err == nil {
+		t.Error("NewBookingProvider accepted an unknown provider")
+	}
+}
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/models"
+)
+
+// Kinds of trip items a budget cut swaps
+const (
+	BudgetCutAccommodation = "accommodation"
+	BudgetCutActivity      = "activity"
+)
+
+const (
+	// budgetCutCandidateLimit is how many cheaper items are read from the
+	// vector database before ranking
+	budgetCutCandidateLimit = 20
+
+	// budgetCutAlternativeLimit is how many ranked alternatives a cut offers
+	budgetCutAlternativeLimit = 3
+)
+
+// CutAlternative is a cheaper item that could replace one in a trip. Cost is
+// for the same use as the item it replaces: the whole stay for a hotel, one
+// visit for an attraction.
+type CutAlternative struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Cost    float64 `json:"cost"`
+	Rating  float64 `json:"rating"`
+	Savings float64 `json:"savings"`
+}
+
+// SuggestedCut proposes swapping one of a trip's items for a cheaper one.
+// Alternatives are ranked best first; Savings and ProjectedTotal assume the
+// first is taken, along with every cut suggested before this one.
+type SuggestedCut struct {
+	ItemType       string           `json:"item_type"`
+	ItemID         string           `json:"item_id"`
+	ItemName       string           `json:"item_name"`
+	Day            int              `json:"day,omitempty"`
+	CurrentCost    float64          `json:"current_cost"`
+	Alternatives   []CutAlternative `json:"alternatives"`
+	Savings        float64          `json:"savings"`
+	ProjectedTotal float64          `json:"projected_total"`
+}
+
+// BudgetCutService suggests cheaper swaps for the costliest items of a trip
+// that's over budget
+type BudgetCutService struct {
+	firebase  *FirebaseService
+	vectorDB  *VectorDatabase
+	validator *DataValidator
+}
+
+// NewBudgetCutService creates a new budget cut service
+func NewBudgetCutService(firebase *FirebaseService, vectorDB *VectorDatabase) *BudgetCutService {
+	return &BudgetCutService{
+		firebase:  firebase,
+		vectorDB:  vectorDB,
+		validator: NewDataValidator(nil, nil),
+	}
+}
+
+// budgetItem is a cost of a trip a cut could swap for something cheaper
+type budgetItem struct {
+	itemType   string
+	id         string
+	externalID string
+	name       string
+	query      string
+	day        int
+	nights     int
+	cost       float64
+}
+
+// SuggestBudgetCuts proposes swaps that bring a trip's total cost down to
+// targetBudget, costliest items first: a cheaper hotel for the same stay, a
+// cheaper or free attraction instead of a paid one. Alternatives are similar
+// items from the vector database, ranked by the data validator. Suggestions
+// stop once the target is met; if every item has been tried without meeting
+// it, the last cut's ProjectedTotal is as low as the trip can go. Nothing is
+// applied to the trip.
+func (s *BudgetCutService) SuggestBudgetCuts(ctx context.Context, tripID string, targetBudget float64) ([]SuggestedCut, error) {
+	if targetBudget <= 0 {
+		return nil, kindErrorf(ErrValidation, "target budget must be positive")
+	}
+	if s.firebase == nil || s.vectorDB == nil {
+		return nil, kindErrorf(ErrUnavailable, "budget cut suggestions are not available")
+	}
+
+	trip, err := s.firebase.GetTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	details, err := s.firebase.GetTripWithItinerary(ctx, tripID, trip.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	items, total := tripBudgetItems(details)
+	return planBudgetCuts(items, total, targetBudget, func(item budgetItem, taken map[string]bool) []CutAlternative {
+		return s.cheaperAlternatives(ctx, item, taken)
+	}), nil
+}
+
+// tripBudgetItems lists the items of a trip cuts can swap, and the trip's
+// total cost including what can't be swapped (meals and transport)
+func tripBudgetItems(details *models.TripWithDetails) ([]budgetItem, float64) {
+	var items []budgetItem
+	total := 0.0
+
+	for _, accommodation := range details.Accommodations {
+		nights := max(accommodation.TotalNights, 1)
+		cost := accommodation.TotalCost
+		if cost == 0 {
+			cost = accommodation.PricePerNight * float64(nights)
+		}
+		total += cost
+		items = append(items, budgetItem{
+			itemType:   BudgetCutAccommodation,
+			id:         accommodation.ID,
+			externalID: accommodation.ExternalID,
+			name:       accommodation.Name,
+			query:      strings.TrimSpace(accommodation.Name + " " + accommodation.Type + " " + details.Trip.Destination),
+			nights:     nights,
+			cost:       cost,
+		})
+	}
+
+	for _, day := range details.Days {
+		for _, activity := range day.Activities {
+			total += activity.Cost
+			items = append(items, budgetItem{
+				itemType:   BudgetCutActivity,
+				id:         activity.ID,
+				externalID: activity.ExternalID,
+				name:       activity.Name,
+				query:      strings.TrimSpace(activity.Name + " " + activity.Type + " " + activity.Description),
+				day:        day.DayPlan.DayNumber,
+				cost:       activity.Cost,
+			})
+		}
+		for _, meal := range day.Meals {
+			total += meal.Cost
+		}
+	}
+
+	for _, transport := range details.Transportation {
+		total += transport.Cost
+	}
+	return items, total
+}
+
+// planBudgetCuts takes the costliest items first, swapping each for its best
+// cheaper alternative until total is down to target. find returns an item's
+// ranked alternatives, leaving out the taken IDs so no alternative is
+// suggested twice.
+func planBudgetCuts(items []budgetItem, total, target float64, find func(item budgetItem, taken map[string]bool) []CutAlternative) []SuggestedCut {
+	cuts := []SuggestedCut{}
+	if total <= target {
+		return cuts
+	}
+
+	sorted := make([]budgetItem, 0, len(items))
+	for _, item := range items {
+		if item.cost > 0 {
+			sorted = append(sorted, item)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].cost > sorted[j].cost
+	})
+
+	taken := make(map[string]bool)
+	for _, item := range sorted {
+		alternatives := find(item, taken)
+		if len(alternatives) == 0 {
+			continue
+		}
+
+		best := alternatives[0]
+		taken[best.ID] = true
+		total -= best.Savings
+		cuts = append(cuts, SuggestedCut{
+			ItemType:       item.itemType,
+			ItemID:         item.id,
+			ItemName:       item.name,
+			Day:            item.day,
+			CurrentCost:    item.cost,
+			Alternatives:   alternatives,
+			Savings:        best.Savings,
+			ProjectedTotal: total,
+		})
+		if total <= target {
+			break
+		}
+	}
+	return cuts
+}
+
+// cheaperAlternatives searches the vector database for available items like
+// item that cost less, ranked by the data validator. Search failures are
+// logged and give no alternatives, so one item can't fail the whole plan.
+func (s *BudgetCutService) cheaperAlternatives(ctx context.Context, item budgetItem, taken map[string]bool) []CutAlternative {
+	skip := func(id string) bool {
+		return taken[id] || id == item.id || (item.externalID != "" && id == item.externalID)
+	}
+
+	var alternatives []CutAlternative
+	switch item.itemType {
+	case BudgetCutAccommodation:
+		nights := float64(item.nights)
+		results, err := s.vectorDB.SearchSimilarWithOptions(ctx, item.query, "hotel", budgetCutCandidateLimit, SearchOptions{
+			Filter: &MetadataFilter{
+				AvailableOnly: true,
+				Predicate: func(doc EmbeddingDocument) bool {
+					return !skip(doc.ID) && hotelFromDocument(doc).PricePerNight*nights < item.cost
+				},
+			},
+		})
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to search for cheaper hotels", "item_id", item.id, "error", err)
+			return nil
+		}
+		hotels := make([]Hotel, 0, len(results))
+		for _, result := range results {
+			hotels = append(hotels, hotelFromDocument(result.Document))
+		}
+		ranked, err := s.validator.ValidateAndRankHotels(ctx, hotels, ValidationCriteria{AvailabilityCheck: true, Nights: item.nights}, DefaultRankingWeights())
+		if err != nil {
+			return nil
+		}
+		for _, hotel := range ranked {
+			cost := hotel.PricePerNight * nights
+			alternatives = append(alternatives, CutAlternative{ID: hotel.ID, Name: hotel.Name, Cost: cost, Rating: hotel.Rating, Savings: item.cost - cost})
+		}
+
+	case BudgetCutActivity:
+		results, err := s.vectorDB.SearchSimilarWithOptions(ctx, item.query, "attraction", budgetCutCandidateLimit, SearchOptions{
+			Filter: &MetadataFilter{
+				AvailableOnly: true,
+				Predicate: func(doc EmbeddingDocument) bool {
+					return !skip(doc.ID) && estimatedAttractionCost(attractionFromDocument(doc)) < item.cost
+				},
+			},
+		})
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to search for cheaper attractions", "item_id", item.id, "error", err)
+			return nil
+		}
+		attractions := make([]Attraction, 0, len(results))
+		for _, result := range results {
+			attractions = append(attractions, attractionFromDocument(result.Document))
+		}
+		ranked, err := s.validator.ValidateAndRankAttractions(ctx, attractions, ValidationCriteria{AvailabilityCheck: true}, DefaultRankingWeights())
+		if err != nil {
+			return nil
+		}
+		for _, attraction := range ranked {
+			cost := estimatedAttractionCost(attraction)
+			alternatives = append(alternatives, CutAlternative{ID: attraction.ID, Name: attraction.Name, Cost: cost, Rating: attraction.Rating, Savings: item.cost - cost})
+		}
+	}
+
+	if len(alternatives) > budgetCutAlternativeLimit {
+		alternatives = alternatives[:budgetCutAlternativeLimit]
+	}
+	return alternatives
+}
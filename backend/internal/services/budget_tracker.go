@@ -0,0 +1,315 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+)
+
+// Expense categories, matching the planned budget's breakdown
+const (
+	ExpenseAccommodation  = "accommodation"
+	ExpenseTransportation = "transportation"
+	ExpenseFood           = "food"
+	ExpenseActivities     = "activities"
+	ExpenseOther          = "other"
+)
+
+// DefaultBudgetAlertThreshold alerts as soon as projected spend exceeds the
+// budget
+const DefaultBudgetAlertThreshold = 1.0
+
+// Expense is something a traveler actually spent on a trip, stored in
+// trip_expenses
+type Expense struct {
+	ID          string  `json:"id" firestore:"id"`
+	TripID      string  `json:"trip_id" firestore:"trip_id"`
+	UserID      string  `json:"user_id" firestore:"user_id"`
+	Description string  `json:"description" firestore:"description"`
+	Category    string  `json:"category" firestore:"category"`
+	Amount      float64 `json:"amount" firestore:"amount"`
+	Currency    string  `json:"currency" firestore:"currency"`
+	// TripAmount is Amount in the trip's currency, converted with the
+	// tracker's exchange rates when the expense was recorded. The default
+	// rates are fixed reference rates, not the day's market rate.
+	TripAmount float64   `json:"trip_amount" firestore:"trip_amount"`
+	SpentAt    time.Time `json:"spent_at" firestore:"spent_at"`
+	CreatedAt  time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// BudgetStatus is a trip's spending so far against its budget, and where it
+// will end up at the current rate. Amounts are in the trip's currency.
+type BudgetStatus struct {
+	TripID    string  `json:"trip_id"`
+	Currency  string  `json:"currency"`
+	Budget    float64 `json:"budget"`
+	Spent     float64 `json:"spent"`
+	Remaining float64 `json:"remaining"`
+	// SpentBeforeTrip is spending dated before the trip started, like
+	// flights and deposits, which doesn't count toward the burn rate
+	SpentBeforeTrip float64            `json:"spent_before_trip"`
+	ByCategory      map[string]float64 `json:"by_category"`
+
+	TotalDays     int `json:"total_days"`
+	DaysElapsed   int `json:"days_elapsed"`
+	DaysRemaining int `json:"days_remaining"`
+	// DailyBurnRate is the average spent per elapsed day of the trip
+	DailyBurnRate float64 `json:"daily_burn_rate"`
+	// PlannedDailyRate is the budget spread evenly over the trip
+	PlannedDailyRate float64 `json:"planned_daily_rate"`
+	// AllowedDailySpend is what's left of the budget per remaining day
+	AllowedDailySpend float64 `json:"allowed_daily_spend"`
+	// ProjectedSpend is what the trip will cost if the burn rate holds for
+	// the remaining days
+	ProjectedSpend float64 `json:"projected_spend"`
+	// OverPace is set when ProjectedSpend exceeds the budget by more than
+	// the alert threshold
+	OverPace bool `json:"over_pace"`
+}
+
+// ExpenseReport is the outcome of recording expenses
+type ExpenseReport struct {
+	Expenses []Expense    `json:"expenses"`
+	Status   BudgetStatus `json:"status"`
+	// Alerted is set when these expenses put the trip over pace and the
+	// traveler was notified
+	Alerted bool `json:"alerted"`
+}
+
+// BudgetTracker records what travelers actually spend on their trips and
+// alerts them when they're spending faster than the budget allows
+type BudgetTracker struct {
+	firebase      *FirebaseService
+	notifications *NotificationService
+	rates         ExchangeRateProvider
+	threshold     float64
+}
+
+// NewBudgetTracker creates a budget tracker that alerts when projected spend
+// exceeds the budget times threshold. A threshold of 0 or less uses
+// DefaultBudgetAlertThreshold.
+func NewBudgetTracker(firebase *FirebaseService, notifications *NotificationService, rates ExchangeRateProvider, threshold float64) *BudgetTracker {
+	if rates == nil {
+		rates = DefaultExchangeRates()
+	}
+	if threshold <= 0 {
+		threshold = DefaultBudgetAlertThreshold
+	}
+	return &BudgetTracker{
+		firebase:      firebase,
+		notifications: notifications,
+		rates:         rates,
+		threshold:     threshold,
+	}
+}
+
+// RecordExpenses adds expenses to a trip, converting each to the trip's
+// currency, and returns the trip's budget status after them. The first time
+// the trip's projected spend goes over the threshold, its owner gets a
+// high-priority alert; expenses that keep it over don't alert again. An
+// expense in a currency without an exchange rate fails the whole request.
+func (b *BudgetTracker) RecordExpenses(ctx context.Context, trip *TripData, userID string, expenses []Expense) (*ExpenseReport, error) {
+	if b.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+	if len(expenses) == 0 {
+		return nil, newKindError(ErrValidation, "at least one expense is required")
+	}
+
+	currency := tripCurrency(trip)
+	now := time.Now()
+	recorded := make([]Expense, len(expenses))
+	for i, expense := range expenses {
+		normalized, err := b.normalizeExpense(expense, currency, now)
+		if err != nil {
+			return nil, kindErrorf(ErrValidation, "expense %d: %w", i+1, err)
+		}
+		normalized.ID = uuid.New().String()
+		normalized.TripID = trip.ID
+		normalized.UserID = userID
+		recorded[i] = normalized
+	}
+
+	client := b.firebase.GetFirestoreClient()
+	var before, after BudgetStatus
+	err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing, err := queryExpenses(tx.Documents(client.Collection("trip_expenses").Where("trip_id", "==", trip.ID)))
+		if err != nil {
+			return err
+		}
+		before = ComputeBudgetStatus(trip, existing, now, b.threshold)
+		after = ComputeBudgetStatus(trip, append(existing, recorded...), now, b.threshold)
+		for _, expense := range recorded {
+			if err := tx.Create(client.Collection("trip_expenses").Doc(expense.ID), expense); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save expenses: %w", err)
+	}
+
+	report := &ExpenseReport{Expenses: recorded, Status: after}
+	if after.OverPace && !before.OverPace && b.notifications != nil {
+		if _, err := b.notifications.SendBudgetAlert(ctx, trip.UserID, trip.ID, after); err != nil {
+			log.Printf("Failed to send budget alert for trip %s: %v", trip.ID, err)
+		} else {
+			report.Alerted = true
+		}
+	}
+	return report, nil
+}
+
+// Status returns a trip's budget status from the expenses recorded so far
+func (b *BudgetTracker) Status(ctx context.Context, trip *TripData) (*BudgetStatus, error) {
+	if b.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+	client := b.firebase.GetFirestoreClient()
+	expenses, err := queryExpenses(client.Collection("trip_expenses").Where("trip_id", "==", trip.ID).Documents(ctx))
+	if err != nil {
+		return nil, err
+	}
+	status := ComputeBudgetStatus(trip, expenses, time.Now(), b.threshold)
+	return &status, nil
+}
+
+// normalizeExpense checks an expense and fills in its trip-currency amount.
+// Expenses without a currency are in the trip's, and without a date were
+// spent now.
+func (b *BudgetTracker) normalizeExpense(expense Expense, currency string, now time.Time) (Expense, error) {
+	if expense.Amount <= 0 {
+		return expense, fmt.Errorf("amount must be positive")
+	}
+	expense.Currency = strings.ToUpper(strings.TrimSpace(expense.Currency))
+	if expense.Currency == "" {
+		expense.Currency = currency
+	}
+	expense.Category = strings.ToLower(strings.TrimSpace(expense.Category))
+	switch expense.Category {
+	case "":
+		expense.Category = ExpenseOther
+	case ExpenseAccommodation, ExpenseTransportation, ExpenseFood, ExpenseActivities, ExpenseOther:
+	default:
+		return expense, fmt.Errorf("unknown category %q", expense.Category)
+	}
+	if expense.SpentAt.IsZero() {
+		expense.SpentAt = now
+	}
+
+	converted, err := b.rates.Convert(expense.Amount, expense.Currency, currency)
+	if err != nil {
+		return expense, err
+	}
+	expense.TripAmount = roundCost(converted)
+	expense.CreatedAt = now
+	return expense, nil
+}
+
+// ComputeBudgetStatus works out a trip's budget status from its expenses at
+// now. The burn rate is the spending during the trip over the days elapsed,
+// counting today, and is projected over the days left. Before the trip
+// starts the projection is just what's been spent. Trips without a budget
+// are never over pace.
+func ComputeBudgetStatus(trip *TripData, expenses []Expense, now time.Time, threshold float64) BudgetStatus {
+	status := BudgetStatus{
+		TripID:     trip.ID,
+		Currency:   tripCurrency(trip),
+		Budget:     trip.Budget,
+		ByCategory: make(map[string]float64),
+	}
+
+	start := truncateToDay(timeFromValue(trip.StartDate))
+	end := truncateToDay(timeFromValue(trip.EndDate))
+	today := truncateToDay(now)
+	if !start.IsZero() {
+		status.TotalDays = 1
+		if end.After(start) {
+			status.TotalDays = int(end.Sub(start).Hours()/24) + 1
+		}
+		if !today.Before(start) {
+			status.DaysElapsed = min(int(today.Sub(start).Hours()/24)+1, status.TotalDays)
+		}
+		status.DaysRemaining = status.TotalDays - status.DaysElapsed
+	}
+
+	var duringTrip float64
+	for _, expense := range expenses {
+		status.Spent += expense.TripAmount
+		status.ByCategory[expense.Category] += expense.TripAmount
+		if !start.IsZero() && truncateToDay(expense.SpentAt).Before(start) {
+			status.SpentBeforeTrip += expense.TripAmount
+		} else {
+			duringTrip += expense.TripAmount
+		}
+	}
+	for category, amount := range status.ByCategory {
+		status.ByCategory[category] = roundCost(amount)
+	}
+
+	status.ProjectedSpend = status.Spent
+	if status.DaysElapsed > 0 {
+		status.DailyBurnRate = duringTrip / float64(status.DaysElapsed)
+		status.ProjectedSpend += status.DailyBurnRate * float64(status.DaysRemaining)
+	}
+	status.Remaining = status.Budget - status.Spent
+	if status.TotalDays > 0 {
+		status.PlannedDailyRate = status.Budget / float64(status.TotalDays)
+	}
+	if status.DaysRemaining > 0 {
+		status.AllowedDailySpend = max(status.Remaining, 0) / float64(status.DaysRemaining)
+	}
+	if threshold <= 0 {
+		threshold = DefaultBudgetAlertThreshold
+	}
+	status.OverPace = status.Budget > 0 && status.ProjectedSpend > status.Budget*threshold
+
+	status.Spent = roundCost(status.Spent)
+	status.SpentBeforeTrip = roundCost(status.SpentBeforeTrip)
+	status.Remaining = roundCost(status.Remaining)
+	status.DailyBurnRate = roundCost(status.DailyBurnRate)
+	status.PlannedDailyRate = roundCost(status.PlannedDailyRate)
+	status.AllowedDailySpend = roundCost(status.AllowedDailySpend)
+	status.ProjectedSpend = roundCost(status.ProjectedSpend)
+	return status
+}
+
+// queryExpenses reads the expenses a query returns
+func queryExpenses(iter *firestore.DocumentIterator) ([]Expense, error) {
+	docs, err := iter.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load expenses: %w", err)
+	}
+	expenses := make([]Expense, 0, len(docs))
+	for _, doc := range docs {
+		var expense Expense
+		if err := doc.DataTo(&expense); err != nil {
+			return nil, fmt.Errorf("failed to convert expense %s: %w", doc.Ref.ID, err)
+		}
+		expenses = append(expenses, expense)
+	}
+	return expenses, nil
+}
+
+// tripCurrency is the currency of a trip's budget and costs, USD when the
+// itinerary doesn't say
+func tripCurrency(trip *TripData) string {
+	if currency, _ := trip.Itinerary["currency"].(string); currency != "" {
+		return strings.ToUpper(currency)
+	}
+	return "USD"
+}
+
+// truncateToDay returns midnight UTC of t's date, keeping zero times zero
+func truncateToDay(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeBudgetStatusOverPace(t *testing.T) {
+	trip := &TripData{
+		ID:        "trip-1",
+		StartDate: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC),
+		Budget:    1000,
+		Itinerary: map[string]interface{}{"currency": "inr"},
+	}
+	// Day 3 of 10
+	now := time.Date(2026, 6, 3, 12, 0, 0, 0, time.UTC)
+	deposit := Expense{Category: ExpenseAccommodation, TripAmount: 200, SpentAt: time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name          string
+		budget        float64
+		duringTrip    float64
+		threshold     float64
+		wantProjected float64
+		wantOverPace  bool
+	}{
+		{"on pace", 1000, 150, 1, 700, false},
+		{"over budget", 1000, 300, 1, 1200, true},
+		{"under budget but over threshold", 1000, 150, 0.6, 700, true},
+		{"threshold defaults to the budget", 1000, 150, 0, 700, false},
+		{"no budget", 0, 300, 1, 1200, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trip.Budget = tt.budget
+			expenses := []Expense{deposit, {Category: ExpenseFood, TripAmount: tt.duringTrip, SpentAt: now}}
+			status := ComputeBudgetStatus(trip, expenses, now, tt.threshold)
+			if status.Currency != "INR" || status.TotalDays != 10 || status.DaysElapsed != 3 || status.SpentBeforeTrip != 200 {
+				t.Fatalf("status = %+v, want 3 of 10 days in INR with 200 spent before the trip", status)
+			}
+			if status.ProjectedSpend != tt.wantProjected || status.OverPace != tt.wantOverPace {
+				t.Errorf("projected %v, over pace %v; want %v, %v", status.ProjectedSpend, status.OverPace, tt.wantProjected, tt.wantOverPace)
+			}
+		})
+	}
+}
+
+func TestNormalizeExpense(t *testing.T) {
+	tracker := NewBudgetTracker(nil, nil, nil, 0)
+	now := time.Date(2026, 6, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		expense      Expense
+		wantCurrency string
+		wantCategory string
+		wantAmount   float64
+		wantErr      bool
+	}{
+		{"converted to the trip currency", Expense{Amount: 10, Currency: " usd ", Category: " Food "}, "USD", ExpenseFood, 832, false},
+		{"defaults to the trip currency", Expense{Amount: 500}, "INR", ExpenseOther, 500, false},
+		{"unknown currency", Expense{Amount: 10, Currency: "XTS"}, "", "", 0, true},
+		{"unknown category", Expense{Amount: 10, Category: "souvenirs"}, "", "", 0, true},
+		{"zero amount", Expense{Amount: 0}, "", "", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tracker.normalizeExpense(tt.expense, "INR", now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("normalizeExpense = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeExpense: %v", err)
+			}
+			if got.Currency != tt.wantCurrency || got.Category != tt.wantCategory || got.TripAmount != tt.wantAmount || !got.SpentAt.Equal(now) {
+				t.Errorf("normalizeExpense = %+v, want %s %s %v spent now", got, tt.wantCurrency, tt.wantCategory, tt.wantAmount)
+			}
+		})
+	}
+}
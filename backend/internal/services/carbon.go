@@ -0,0 +1,116 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// carbonEmissionFactors are kg CO2e per passenger-km by transport mode, from
+// the UK government (DEFRA) greenhouse gas conversion factors
+var carbonEmissionFactors = map[string]float64{
+	"flight":           0.153, // short-haul economy, with radiative forcing
+	"domestic_flight":  0.246,
+	"train":            0.035,
+	"bus":              0.027, // coach
+	"public_transport": 0.079,
+	"car_rental":       0.170,
+	"taxi":             0.149,
+	"ferry":            0.019,
+}
+
+const (
+	// domesticFlightMaxKm is the distance under which flights use the higher
+	// domestic factor, since takeoff dominates short flights
+	domesticFlightMaxKm = 500.0
+	// flightDistanceUplift accounts for flights not following the great
+	// circle route
+	flightDistanceUplift = 1.08
+)
+
+// EstimateCarbonFootprint returns the kg CO2e one passenger emits travelling
+// distanceKm by mode. Unknown modes and distances return 0.
+func EstimateCarbonFootprint(mode string, distanceKm float64) float64 {
+	if distanceKm <= 0 {
+		return 0
+	}
+
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "flight" {
+		distanceKm *= flightDistanceUplift
+		if distanceKm < domesticFlightMaxKm {
+			mode = "domestic_flight"
+		}
+	}
+
+	factor, ok := carbonEmissionFactors[mode]
+	if !ok {
+		return 0
+	}
+	return math.Round(factor*distanceKm*100) / 100
+}
+
+// TransportDistanceKm is the great-circle distance between two locations, or
+// 0 when either has no coordinates
+func TransportDistanceKm(from, to Location) float64 {
+	if !hasLatLng(from) || !hasLatLng(to) {
+		return 0
+	}
+	return haversineKm(from, to)
+}
+
+// annotateCarbon fills in the option's distance from its endpoints, when
+// known, and its per-passenger footprint
+func (t *TransportOption) annotateCarbon() {
+	if t.DistanceKm == 0 && t.FromLocation != nil && t.ToLocation != nil {
+		t.DistanceKm = math.Round(TransportDistanceKm(*t.FromLocation, *t.ToLocation))
+	}
+	t.CarbonKg = EstimateCarbonFootprint(t.Type, t.DistanceKm)
+}
+
+// rankTransportByCarbon orders options from lowest to highest emissions.
+// When any option has no distance they're compared by their mode's emission
+// factor instead, which orders them the same way for a shared route.
+func rankTransportByCarbon(options []TransportOption) {
+	allEstimated := true
+	for _, option := range options {
+		if option.CarbonKg == 0 {
+			allEstimated = false
+			break
+		}
+	}
+
+	intensity := func(option TransportOption) float64 {
+		if allEstimated {
+			return option.CarbonKg
+		}
+		factor, ok := carbonEmissionFactors[strings.ToLower(option.Type)]
+		if !ok {
+			return math.MaxFloat64
+		}
+		return factor
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		return intensity(options[i]) < intensity(options[j])
+	})
+}
+
+// TripCarbonFootprint totals the emissions of the trip's transport for all
+// travelers, in kg CO2e
+func TripCarbonFootprint(data *ItineraryData) float64 {
+	travelers := data.Travelers
+	if travelers < 1 {
+		travelers = 1
+	}
+
+	total := 0.0
+	for _, transport := range data.Transportation {
+		perPassenger := transport.CarbonKg
+		if perPassenger == 0 {
+			perPassenger = EstimateCarbonFootprint(transport.Type, transport.DistanceKm)
+		}
+		total += perPassenger * float64(travelers)
+	}
+	return math.Round(total*100) / 100
+}
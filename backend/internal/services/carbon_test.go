@@ -0,0 +1,101 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateCarbonFootprint(t *testing.T) {
+	tests := []struct {
+		mode       string
+		distanceKm float64
+		want       float64
+	}{
+		{"train", 100, 3.5},
+		{" Bus ", 250, 6.75},
+		{"flight", 1000, 165.24},
+		// Short flights use the domestic factor once the uplift is applied
+		{"flight", 400, 106.27},
+		{"flight", 460, 122.21},
+		{"flight", 463, 76.51},
+		{"rickshaw", 10, 0},
+		{"train", 0, 0},
+		{"train", -5, 0},
+	}
+	for _, tt := range tests {
+		if got := EstimateCarbonFootprint(tt.mode, tt.distanceKm); got != tt.want {
+			t.Errorf("EstimateCarbonFootprint(%q, %v) = %v, want %v", tt.mode, tt.distanceKm, got, tt.want)
+		}
+	}
+}
+
+func TestAnnotateCarbon(t *testing.T) {
+	delhi, east := at(77.2), at(78.2)
+	tests := []struct {
+		name         string
+		option       TransportOption
+		wantDistance float64
+		wantCarbon   float64
+	}{
+		{"distance from endpoints", TransportOption{Type: "train", FromLocation: &delhi, ToLocation: &east}, 98, 3.43},
+		{"known distance is kept", TransportOption{Type: "train", DistanceKm: 200, FromLocation: &delhi, ToLocation: &east}, 200, 7},
+		{"missing endpoint", TransportOption{Type: "train", FromLocation: &delhi}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.option.annotateCarbon()
+			if tt.option.DistanceKm != tt.wantDistance || tt.option.CarbonKg != tt.wantCarbon {
+				t.Errorf("distance %v km, carbon %v kg; want %v, %v", tt.option.DistanceKm, tt.option.CarbonKg, tt.wantDistance, tt.wantCarbon)
+			}
+		})
+	}
+}
+
+func TestRankTransportByCarbon(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []TransportOption
+		want    string
+	}{
+		{
+			name:    "by estimate",
+			options: []TransportOption{{Type: "flight", CarbonKg: 50}, {Type: "train", CarbonKg: 3}, {Type: "bus", CarbonKg: 5}},
+			want:    "train,bus,flight",
+		},
+		{
+			name:    "by emission factor when an estimate is missing",
+			options: []TransportOption{{Type: "car_rental", CarbonKg: 20}, {Type: "hovercraft"}, {Type: "Train"}, {Type: "ferry", CarbonKg: 30}},
+			want:    "ferry,Train,car_rental,hovercraft",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rankTransportByCarbon(tt.options)
+			types := make([]string, len(tt.options))
+			for i, option := range tt.options {
+				types[i] = option.Type
+			}
+			if got := strings.Join(types, ","); got != tt.want {
+				t.Errorf("ranked = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTripCarbonFootprint(t *testing.T) {
+	transport := []TransportBooking{{Type: "train", DistanceKm: 100}, {Type: "flight", DistanceKm: 1000, CarbonKg: 10}}
+	tests := []struct {
+		name string
+		data ItineraryData
+		want float64
+	}{
+		{"per traveler", ItineraryData{Travelers: 3, Transportation: transport}, 40.5},
+		{"travelers default to one", ItineraryData{Transportation: transport}, 13.5},
+		{"no transport", ItineraryData{Travelers: 2}, 0},
+	}
+	for _, tt := range tests {
+		if got := TripCarbonFootprint(&tt.data); got != tt.want {
+			t.Errorf("%s: TripCarbonFootprint = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"unicode"
+)
+
+// ChatLanguageConfidence is the detection confidence below which chat
+// replies use the user's stored locale instead of the detected one
+const ChatLanguageConfidence = 0.6
+
+// Where a chat reply's language came from
+const (
+	ChatLanguageDetected   = "detected"
+	ChatLanguagePreference = "preference"
+)
+
+// localeScripts are the Unicode blocks of each supported locale's script.
+// Devanagari is shared by Hindi and Marathi, which are told apart by their
+// common words.
+var localeScripts = []struct {
+	locale string
+	table  *unicode.RangeTable
+}{
+	{"hi", unicode.Devanagari},
+	{"bn", unicode.Bengali},
+	{"ta", unicode.Tamil},
+	{"en", unicode.Latin},
+}
+
+// devanagariMarkers are frequent words found in Hindi but not Marathi, and
+// the other way round
+var devanagariMarkers = map[string][]string{
+	"hi": {"है", "हैं", "क्या", "मुझे", "में", "नहीं", "कैसे", "कहाँ", "और", "का", "की", "के", "लिए"},
+	"mr": {"आहे", "आहेत", "काय", "मला", "मध्ये", "नाही", "कसे", "कुठे", "आणि", "चा", "ची", "चे", "साठी"},
+}
+
+// minConfidentLetters is how many letters a message needs before its
+// script is trusted fully; shorter messages get proportionally less
+// confidence
+const minConfidentLetters = 12
+
+// DetectLocaleWithConfidence detects the supported locale a text is written
+// in, from the script most of its letters are in, and how confident that
+// guess is from 0 to 1. Confidence drops for mixed scripts, short texts,
+// and Devanagari that's neither clearly Hindi nor Marathi. Text without
+// letters gets the default locale with no confidence.
+func (l *LocalizationService) DetectLocaleWithConfidence(text string) (string, float64) {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.Is(unicode.Mn, r) && !unicode.Is(unicode.Mc, r) {
+			continue
+		}
+		letters++
+		for _, script := range localeScripts {
+			if unicode.Is(script.table, r) {
+				counts[script.locale]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return l.defaultLocale, 0
+	}
+
+	locale, best := l.defaultLocale, 0
+	for _, script := range localeScripts {
+		if counts[script.locale] > best {
+			locale, best = script.locale, counts[script.locale]
+		}
+	}
+	confidence := float64(best) / float64(letters)
+	if letters < minConfidentLetters {
+		confidence *= float64(letters) / minConfidentLetters
+	}
+
+	if locale == "hi" {
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return unicode.IsSpace(r) || unicode.IsPunct(r) || r == '।'
+		})
+		hits := make(map[string]int)
+		for _, word := range words {
+			for markerLocale, markers := range devanagariMarkers {
+				for _, marker := range markers {
+					if word == marker {
+						hits[markerLocale]++
+					}
+				}
+			}
+		}
+		switch {
+		case hits["mr"] > hits["hi"]:
+			locale = "mr"
+			confidence *= float64(hits["mr"]) / float64(hits["mr"]+hits["hi"])
+		case hits["hi"] > hits["mr"]:
+			confidence *= float64(hits["hi"]) / float64(hits["mr"]+hits["hi"])
+		default:
+			// Devanagari with no telling words is most likely Hindi
+			confidence *= 0.5
+		}
+	}
+
+	if !l.ValidateLocale(locale) {
+		return l.defaultLocale, 0
+	}
+	return locale, confidence
+}
+
+// ChatReply is the assistant's answer to a chat message, with the language
+// it's in and how that was chosen
+type ChatReply struct {
+	Reply              string  `json:"reply"`
+	Language           string  `json:"language"`
+	LanguageConfidence float64 `json:"language_confidence"`
+	// LanguageSource is ChatLanguageDetected, or ChatLanguagePreference when
+	// detection wasn't confident and the user's stored locale was used
+	LanguageSource string `json:"language_source"`
+}
+
+// ReplyToChat answers a traveler's chat message in the language it's
+// written in. When the language can't be detected confidently the reply is
+// in the user's stored locale preference.
+func (l *LocalizationService) ReplyToChat(ctx context.Context, userID, message string) (*ChatReply, error) {
+	if strings.TrimSpace(message) == "" {
+		return nil, newKindError(ErrValidation, "message is required")
+	}
+	if l.gemini == nil {
+		return nil, newKindError(ErrUnavailable, "chat is not available")
+	}
+
+	locale, confidence := l.DetectLocaleWithConfidence(message)
+	source := ChatLanguageDetected
+	if confidence < ChatLanguageConfidence {
+		preferred, err := l.GetUserLocalePreference(ctx, userID)
+		if err != nil || !l.ValidateLocale(preferred) {
+			preferred = l.defaultLocale
+		}
+		locale, source = preferred, ChatLanguagePreference
+	}
+
+	systemPrompt, err := l.GetLocalizedGeminiPrompt(locale, "chat", nil)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := l.gemini.GenerateText(ctx, systemPrompt+"\n\n"+userInput("message", message)+userInputNotice)
+	if err != nil {
+		return nil, kindErrorf(ErrUnavailable, "failed to generate chat reply: %w", err)
+	}
+
+	return &ChatReply{
+		Reply:              strings.TrimSpace(reply),
+		Language:           locale,
+		LanguageConfidence: confidence,
+		LanguageSource:     source,
+	}, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReplyToChatAnswersInTheMessageLanguage(t *testing.T) {
+	replies := map[string]string{
+		"hi": "जयपुर में आमेर किला और हवा महल ज़रूर देखें।",
+		"ta": "சென்னையில் மெரினா கடற்கரை மற்றும் கபாலீஸ்வரர் கோவிலைப் பாருங்கள்.",
+		"en": "Visit the Amber Fort and Hawa Mahal.",
+	}
+	l := NewLocalizationService(nil, nil)
+	// The stub answers in whichever language the chat prompt asks for
+	generator := &fakeGenerator{text: func(prompt string) (string, error) {
+		for locale, reply := range replies {
+			if chat, _ := l.GetLocalizedGeminiPrompt(locale, "chat", nil); strings.HasPrefix(prompt, chat) {
+				return reply, nil
+			}
+		}
+		return "", errors.New("prompt without a chat instruction")
+	}}
+	l.gemini = generator
+
+	tests := []struct {
+		name       string
+		message    string
+		wantLocale string
+		wantSource string
+	}{
+		{"hindi", "मुझे जयपुर में घूमने के लिए सबसे अच्छी जगह कौन सी है?", "hi", ChatLanguageDetected},
+		{"tamil", "சென்னையில் பார்க்க வேண்டிய சிறந்த இடங்கள் எவை?", "ta", ChatLanguageDetected},
+		{"too short to detect", "ok?", "en", ChatLanguagePreference},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply, err := l.ReplyToChat(context.Background(), "user-1", tt.message)
+			if err != nil {
+				t.Fatalf("ReplyToChat: %v", err)
+			}
+			if reply.Language != tt.wantLocale || reply.LanguageSource != tt.wantSource {
+				t.Errorf("reply in %s from %s, want %s from %s", reply.Language, reply.LanguageSource, tt.wantLocale, tt.wantSource)
+			}
+			if reply.Reply != replies[tt.wantLocale] {
+				t.Errorf("reply = %q, want the %s answer", reply.Reply, tt.wantLocale)
+			}
+			if got, _ := l.DetectLocaleWithConfidence(reply.Reply); got != tt.wantLocale {
+				t.Errorf("reply detected as %s, want %s", got, tt.wantLocale)
+			}
+		})
+	}
+}
+
+func TestReplyToChatReportsGenerationFailures(t *testing.T) {
+	l := NewLocalizationService(&fakeGenerator{err: errors.New("quota exceeded")}, nil)
+	if _, err := l.ReplyToChat(context.Background(), "user-1", "Where should I eat in Chennai?"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("ReplyToChat = %v, want ErrUnavailable", err)
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"fmt"
+	"math"
+)
+
+// DefaultCostTolerance is the largest difference, in the itinerary's
+// currency, between a stored and computed total that still counts as a match
+const DefaultCostTolerance = 1.0
+
+// CostReconciliation compares an itinerary's stored totals with the sum of
+// its line items
+type CostReconciliation struct {
+	Currency      string  `json:"currency"`
+	StoredTotal   float64 `json:"stored_total"`
+	ComputedTotal float64 `json:"computed_total"`
+	Difference    float64 `json:"difference"`
+	Matches       bool    `json:"matches"`
+	// DayMismatches lists days whose stored TotalCost disagrees with their
+	// activities and meals
+	DayMismatches []int `json:"day_mismatches,omitempty"`
+	// Unconverted lists line items whose currency has no exchange rate; they
+	// are left out of ComputedTotal
+	Unconverted []string `json:"unconverted,omitempty"`
+}
+
+// ComputeTotalCost sums the itinerary's line items in its currency: each
+// day's activities and meals, hotels, transport, and activity bookings.
+// Bookings that also appear as a day activity (same booking reference) are
+// counted once.
+func ComputeTotalCost(data *ItineraryData) float64 {
+	total, _ := computeTotalCost(data, DefaultExchangeRates())
+	return total
+}
+
+// ReconcileCosts checks the stored TotalCost, and each day's TotalCost,
+// against the line items. Differences up to tolerance are accepted.
+func ReconcileCosts(data *ItineraryData, tolerance float64) CostReconciliation {
+	rates := DefaultExchangeRates()
+	computed, unconverted := computeTotalCost(data, rates)
+
+	result := CostReconciliation{
+		Currency:      data.Currency,
+		StoredTotal:   data.TotalCost,
+		ComputedTotal: computed,
+		Difference:    roundCost(data.TotalCost - computed),
+		Unconverted:   unconverted,
+	}
+	result.Matches = math.Abs(result.Difference) <= tolerance
+
+	for dayNum := 1; dayNum <= len(data.DailyItinerary); dayNum++ {
+		day, exists := data.DailyItinerary[dayNum]
+		if !exists {
+			continue
+		}
+		dayTotal, _ := dayCost(day, data.Currency, rates)
+		if math.Abs(day.TotalCost-dayTotal) > tolerance {
+			result.DayMismatches = append(result.DayMismatches, dayNum)
+		}
+	}
+
+	return result
+}
+
+// costSum accumulates line items converted to one currency
+type costSum struct {
+	currency    string
+	rates       ExchangeRates
+	total       float64
+	unconverted []string
+}
+
+func (s *costSum) add(label string, amount float64, currency string) {
+	if amount == 0 {
+		return
+	}
+	if currency == "" {
+		currency = s.currency
+	}
+	converted, err := s.rates.Convert(amount, currency, s.currency)
+	if err != nil {
+		s.unconverted = append(s.unconverted, fmt.Sprintf("%s (%.2f %s)", label, amount, currency))
+		return
+	}
+	s.total += converted
+}
+
+func computeTotalCost(data *ItineraryData, rates ExchangeRates) (float64, []string) {
+	sum := &costSum{currency: data.Currency, rates: rates}
+
+	dayBookingRefs := make(map[string]bool)
+	for _, day := range data.DailyItinerary {
+		addDayCosts(sum, day)
+		for _, activities := range [][]Activity{day.Morning, day.Afternoon, day.Evening} {
+			for _, activity := range activities {
+				if activity.BookingRef != "" {
+					dayBookingRefs[activity.BookingRef] = true
+				}
+			}
+		}
+	}
+
+	for _, hotel := range data.Hotels {
+		sum.add(hotel.Name, hotel.TotalCost, hotel.Currency)
+	}
+	for _, transport := range data.Transportation {
+		if transport.BookingRef != "" && dayBookingRefs[transport.BookingRef] {
+			continue
+		}
+		sum.add(fmt.Sprintf("%s %s to %s", transport.Type, transport.From, transport.To), transport.Cost, transport.Currency)
+	}
+	for _, booking := range data.Activities {
+		if booking.BookingRef != "" && dayBookingRefs[booking.BookingRef] {
+			continue
+		}
+		sum.add(booking.Name, booking.Cost, booking.Currency)
+	}
+
+	return roundCost(sum.total), sum.unconverted
+}
+
+func dayCost(day DayItinerary, currency string, rates ExchangeRates) (float64, []string) {
+	sum := &costSum{currency: currency, rates: rates}
+	addDayCosts(sum, day)
+	return roundCost(sum.total), sum.unconverted
+}
+
+func addDayCosts(sum *costSum, day DayItinerary) {
+	for _, activities := range [][]Activity{day.Morning, day.Afternoon, day.Evening} {
+		for _, activity := range activities {
+			sum.add(activity.Name, activity.Cost, activity.Currency)
+		}
+	}
+	for _, meal := range day.Meals {
+		sum.add(meal.Type+" at "+meal.Restaurant, meal.Cost, meal.Currency)
+	}
+}
+
+func roundCost(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReconcileCosts(t *testing.T) {
+	itinerary := func(storedTotal float64) *ItineraryData {
+		return &ItineraryData{
+			Currency: "USD",
+			DailyItinerary: map[int]DayItinerary{
+				1: {
+					Morning:   []Activity{{Name: "Museum", Cost: 20}},
+					Meals:     []Meal{{Type: "lunch", Restaurant: "Cafe", Cost: 15}},
+					TotalCost: 35,
+				},
+				2: {
+					Afternoon: []Activity{{Name: "Safari", Cost: 100, BookingRef: "ACT-1"}},
+					Evening:   []Activity{{Name: "Show", Cost: 46, Currency: "EUR"}},
+					TotalCost: 120, // the show's 50 USD is missing
+				},
+			},
+			Hotels:         []HotelBooking{{Name: "Hotel", TotalCost: 300}},
+			Transportation: []TransportBooking{{Type: "train", From: "A", To: "B", Cost: 50}},
+			Activities: []ActivityBooking{
+				{Name: "Safari", Cost: 100, BookingRef: "ACT-1"}, // already counted on day 2
+				{Name: "Cooking class", Cost: 30, Currency: "XYZ"},
+			},
+			TotalCost: storedTotal,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		stored    float64
+		tolerance float64
+		wantDiff  float64
+		wantMatch bool
+	}{
+		{"exact", 535, DefaultCostTolerance, 0, true},
+		{"within tolerance", 535.8, DefaultCostTolerance, 0.8, true},
+		{"over", 540, DefaultCostTolerance, 5, false},
+		{"under", 500, DefaultCostTolerance, -35, false},
+		{"wider tolerance", 540, 10, 5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := itinerary(tt.stored)
+			got := ReconcileCosts(data, tt.tolerance)
+			if got.ComputedTotal != 535 || ComputeTotalCost(data) != 535 {
+				t.Errorf("computed total = %v, want 535", got.ComputedTotal)
+			}
+			if got.Difference != tt.wantDiff || got.Matches != tt.wantMatch {
+				t.Errorf("difference %v, matches %v; want %v, %v", got.Difference, got.Matches, tt.wantDiff, tt.wantMatch)
+			}
+			if fmt.Sprint(got.DayMismatches) != "[2]" {
+				t.Errorf("day mismatches = %v, want [2]", got.DayMismatches)
+			}
+			if fmt.Sprint(got.Unconverted) != "[Cooking class (30.00 XYZ)]" {
+				t.Errorf("unconverted = %v", got.Unconverted)
+			}
+		})
+	}
+}
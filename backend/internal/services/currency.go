@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExchangeRateProvider converts amounts between currencies. Unknown
+// currencies are an *UnknownCurrencyError, never a 1:1 conversion.
+type ExchangeRateProvider interface {
+	Convert(amount float64, from, to string) (float64, error)
+	Supports(code string) bool
+}
+
+// UnknownCurrencyError is returned for a currency without an exchange rate
+type UnknownCurrencyError struct {
+	Currency string
+}
+
+func (e *UnknownCurrencyError) Error() string {
+	return fmt.Sprintf("no exchange rate for %s", e.Currency)
+}
+
+// Is makes the error match ErrValidation
+func (e *UnknownCurrencyError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+var _ ExchangeRateProvider = ExchangeRates{}
+
+// ExchangeRates converts between currencies through a base currency. Rates
+// are units of each currency per one unit of Base.
+type ExchangeRates struct {
+	Base  string
+	Rates map[string]float64
+}
+
+// DefaultExchangeRates returns reference rates against USD. Mock data - in
+// production, refresh from an exchange rate API.
+func DefaultExchangeRates() ExchangeRates {
+	return ExchangeRates{
+		Base: "USD",
+		Rates: map[string]float64{
+			"USD": 1,
+			"EUR": 0.92,
+			"GBP": 0.79,
+			"INR": 83.2,
+			"JPY": 151.5,
+			"AUD": 1.52,
+			"CAD": 1.36,
+			"SGD": 1.35,
+			"AED": 3.67,
+			"THB": 36.4,
+			"IDR": 15800,
+		},
+	}
+}
+
+// Convert converts amount from one currency to another. Empty currency codes
+// are treated as the base currency.
+func (r ExchangeRates) Convert(amount float64, from, to string) (float64, error) {
+	from, to = r.normalize(from), r.normalize(to)
+	if from == to {
+		return amount, nil
+	}
+
+	fromRate, ok := r.Rates[from]
+	if !ok || fromRate <= 0 {
+		return 0, &UnknownCurrencyError{Currency: from}
+	}
+	toRate, ok := r.Rates[to]
+	if !ok || toRate <= 0 {
+		return 0, &UnknownCurrencyError{Currency: to}
+	}
+	return amount / fromRate * toRate, nil
+}
+
+// Supports reports whether code has an exchange rate. The empty code is the
+// base currency.
+func (r ExchangeRates) Supports(code string) bool {
+	rate, ok := r.Rates[r.normalize(code)]
+	return ok && rate > 0
+}
+
+func (r ExchangeRates) normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return r.Base
+	}
+	return code
+}
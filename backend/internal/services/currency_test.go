@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExchangeRatesConvert(t *testing.T) {
+	rates := DefaultExchangeRates()
+	tests := []struct {
+		amount      float64
+		from, to    string
+		want        float64
+		wantUnknown string
+	}{
+		{100, "USD", "INR", 8320, ""},
+		{92, "eur", " usd ", 100, ""},
+		{79, "GBP", "EUR", 92, ""},
+		{50, "", "USD", 50, ""},
+		{50, "XYZ", "XYZ", 50, ""}, // nothing to convert
+		{10, "XYZ", "USD", 0, "XYZ"},
+		{10, "USD", "doge", 0, "DOGE"},
+	}
+	for _, tt := range tests {
+		got, err := rates.Convert(tt.amount, tt.from, tt.to)
+		var unknown *UnknownCurrencyError
+		if errors.As(err, &unknown) != (tt.wantUnknown != "") || (unknown != nil && unknown.Currency != tt.wantUnknown) {
+			t.Errorf("Convert(%v, %q, %q) error = %v, want unknown %q", tt.amount, tt.from, tt.to, err, tt.wantUnknown)
+		}
+		if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("Convert(%v, %q, %q) = %v, want %v", tt.amount, tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	for code, want := range map[string]bool{"INR": true, " jpy ": true, "": true, "XYZ": false} {
+		if got := rates.Supports(code); got != want {
+			t.Errorf("Supports(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
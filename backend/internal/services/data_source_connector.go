@@ -3,12 +3,17 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"sync"
 	"time"
+
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/metrics"
 )
 
 // DataSourceConnector handles connections to external APIs
@@ -17,18 +22,44 @@ type DataSourceConnector struct {
 	mapsAPIKey string
 	weatherKey string
 	emtAPIKey  string
+	// vectorDB, when set, indexes fetched hotels so budget cuts can find
+	// similar cheaper ones; see SetVectorDatabase
+	vectorDB *VectorDatabase
 }
 
 // NewDataSourceConnector creates a new data source connector
 func NewDataSourceConnector(mapsAPIKey, weatherKey, emtAPIKey string) *DataSourceConnector {
 	return &DataSourceConnector{
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpclient.New(httpclient.Options{}),
 		mapsAPIKey: mapsAPIKey,
 		weatherKey: weatherKey,
 		emtAPIKey:  emtAPIKey,
 	}
 }
 
+// hotelIndexTimeout bounds indexing one search's hotels in the background
+const hotelIndexTimeout = time.Minute
+
+// SetVectorDatabase makes FetchHotels index the hotels it finds in vdb
+func (dsc *DataSourceConnector) SetVectorDatabase(vdb *VectorDatabase) {
+	dsc.vectorDB = vdb
+}
+
+// indexHotels stores hotels in the vector database without holding up the
+// caller; indexing failures are only logged
+func (dsc *DataSourceConnector) indexHotels(ctx context.Context, hotels []Hotel) {
+	if dsc.vectorDB == nil || len(hotels) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hotelIndexTimeout)
+	go func() {
+		defer cancel()
+		if err := dsc.vectorDB.StoreHotelEmbeddings(ctx, hotels); err != nil {
+			log.Printf("Failed to index hotels: %v", err)
+		}
+	}()
+}
+
 // Google Places API Response structures
 type PlacesResponse struct {
 	Results []PlaceResult `json:"results"`
@@ -136,7 +167,9 @@ func (dsc *DataSourceConnector) fetchAttractionsByType(ctx context.Context, dest
 	params.Add("key", dsc.mapsAPIKey)
 	params.Add("type", placeType)
 
+	start := time.Now()
 	resp, err := dsc.httpClient.Get(fmt.Sprintf("%s?%s", baseURL, params.Encode()))
+	metrics.ObserveExternalCall("places", start, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch attractions: %v", err)
 	}
@@ -153,31 +186,103 @@ func (dsc *DataSourceConnector) fetchAttractionsByType(ctx context.Context, dest
 
 	var attractions []Attraction
 	for _, place := range placesResp.Results {
-		attraction := Attraction{
-			ID:   place.PlaceID,
-			Name: place.Name,
-			Type: dsc.mapPlaceTypeToCategory(place.Types),
-			Location: Location{
-				Latitude:  place.Geometry.Location.Lat,
-				Longitude: place.Geometry.Location.Lng,
-				Address:   place.Vicinity,
-			},
-			Rating:     place.Rating,
-			PriceLevel: place.PriceLevel,
-			Available:  true,
-			Tags:       place.Types,
-		}
+		attractions = append(attractions, dsc.placeToAttraction(place))
+	}
+
+	return attractions, nil
+}
 
-		if place.OpeningHours != nil {
-			attraction.OpeningHours = place.OpeningHours.WeekdayText
+// FetchNearbyAttractions retrieves attractions within radiusKm of a point
+// matching the interests, only those open right now when openNow is set.
+// Without a Maps API key there is nothing to search, so no attractions are
+// returned.
+func (dsc *DataSourceConnector) FetchNearbyAttractions(ctx context.Context, latitude, longitude, radiusKm float64, interests []string, openNow bool) ([]Attraction, error) {
+	if dsc.mapsAPIKey == "" {
+		return nil, nil
+	}
+
+	var allAttractions []Attraction
+	for _, placeType := range dsc.mapInterestsToPlaceTypes(interests) {
+		attractions, err := dsc.fetchNearbyByType(ctx, latitude, longitude, radiusKm, placeType, openNow)
+		if err != nil {
+			log.Printf("Error fetching nearby attractions for type %s: %v", placeType, err)
+			continue
 		}
+		allAttractions = append(allAttractions, attractions...)
+	}
+
+	return allAttractions, nil
+}
 
-		attractions = append(attractions, attraction)
+func (dsc *DataSourceConnector) fetchNearbyByType(ctx context.Context, latitude, longitude, radiusKm float64, placeType string, openNow bool) ([]Attraction, error) {
+	baseURL := "https://maps.googleapis.com/maps/api/place/nearbysearch/json"
+
+	params := url.Values{}
+	params.Add("location", fmt.Sprintf("%f,%f", latitude, longitude))
+	params.Add("radius", strconv.Itoa(int(radiusKm*1000)))
+	params.Add("type", placeType)
+	params.Add("key", dsc.mapsAPIKey)
+	if openNow {
+		params.Add("opennow", "true")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?%s", baseURL, params.Encode()), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	start := time.Now()
+	resp, err := dsc.httpClient.Do(req)
+	metrics.ObserveExternalCall("places", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch nearby attractions: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var placesResp PlacesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&placesResp); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	// ZERO_RESULTS is an empty neighborhood, not a failure
+	if placesResp.Status == "ZERO_RESULTS" {
+		return nil, nil
+	}
+	if placesResp.Status != "OK" {
+		return nil, fmt.Errorf("places API error: %s", placesResp.Status)
+	}
+
+	var attractions []Attraction
+	for _, place := range placesResp.Results {
+		attractions = append(attractions, dsc.placeToAttraction(place))
 	}
 
 	return attractions, nil
 }
 
+// placeToAttraction converts a Places API result to an Attraction
+func (dsc *DataSourceConnector) placeToAttraction(place PlaceResult) Attraction {
+	attraction := Attraction{
+		ID:   place.PlaceID,
+		Name: place.Name,
+		Type: dsc.mapPlaceTypeToCategory(place.Types),
+		Location: Location{
+			Latitude:  place.Geometry.Location.Lat,
+			Longitude: place.Geometry.Location.Lng,
+			Address:   place.Vicinity,
+		},
+		Rating:     place.Rating,
+		PriceLevel: place.PriceLevel,
+		Available:  true,
+		Tags:       place.Types,
+	}
+
+	if place.OpeningHours != nil {
+		attraction.OpeningHours = place.OpeningHours.WeekdayText
+	}
+
+	return attraction
+}
+
 // FetchWeather retrieves weather forecast
 func (dsc *DataSourceConnector) FetchWeather(ctx context.Context, latitude, longitude float64) (*WeatherForecast, error) {
 	if dsc.weatherKey == "" {
@@ -294,11 +399,191 @@ func (dsc *DataSourceConnector) FetchHotels(ctx context.Context, destination str
 		return dsc.getMockHotels(destination), nil
 	}
 
+	dsc.indexHotels(ctx, hotels)
 	return hotels, nil
 }
 
+// placeAvailabilityConcurrency bounds parallel Place Details lookups; the API
+// has no batch endpoint, so availability checks fan out instead
+const placeAvailabilityConcurrency = 5
+
+// ErrAvailabilityUnavailable is returned when live availability can't be
+// checked at all, e.g. because no Maps API key is configured
+var ErrAvailabilityUnavailable = errors.New("live availability is not configured")
+
+// PlaceDetailsResponse is the subset of the Place Details API used for
+// availability
+type PlaceDetailsResponse struct {
+	Result struct {
+		BusinessStatus string `json:"business_status"`
+		OpeningHours   *struct {
+			Periods []struct {
+				Open struct {
+					Day int `json:"day"`
+				} `json:"open"`
+				Close *struct {
+					Day int `json:"day"`
+				} `json:"close"`
+			} `json:"periods"`
+		} `json:"opening_hours"`
+	} `json:"result"`
+	Status string `json:"status"`
+}
+
+// CheckPlaceAvailability reports whether each place is operating and open on
+// date. Places that couldn't be checked are left out of the result.
+func (dsc *DataSourceConnector) CheckPlaceAvailability(ctx context.Context, placeIDs []string, date time.Time) (map[string]bool, error) {
+	if dsc.mapsAPIKey == "" {
+		return nil, ErrAvailabilityUnavailable
+	}
+
+	availability := make(map[string]bool)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, placeAvailabilityConcurrency)
+
+	seen := make(map[string]bool)
+	for _, placeID := range placeIDs {
+		if placeID == "" || seen[placeID] {
+			continue
+		}
+		seen[placeID] = true
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(placeID string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			available, err := dsc.fetchPlaceAvailability(ctx, placeID, date)
+			if err != nil {
+				log.Printf("Availability check failed for place %s: %v", placeID, err)
+				return
+			}
+			mu.Lock()
+			availability[placeID] = available
+			mu.Unlock()
+		}(placeID)
+	}
+	wg.Wait()
+
+	return availability, nil
+}
+
+func (dsc *DataSourceConnector) fetchPlaceAvailability(ctx context.Context, placeID string, date time.Time) (bool, error) {
+	baseURL := "https://maps.googleapis.com/maps/api/place/details/json"
+
+	params := url.Values{}
+	params.Add("place_id", placeID)
+	params.Add("fields", "business_status,opening_hours")
+	params.Add("key", dsc.mapsAPIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?%s", baseURL, params.Encode()), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create place details request: %v", err)
+	}
+
+	resp, err := dsc.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch place details: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var details PlaceDetailsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&details); err != nil {
+		return false, fmt.Errorf("failed to decode response: %v", err)
+	}
+	if details.Status != "OK" {
+		return false, fmt.Errorf("place details API error: %s", details.Status)
+	}
+
+	if status := details.Result.BusinessStatus; status != "" && status != "OPERATIONAL" {
+		return false, nil
+	}
+
+	hours := details.Result.OpeningHours
+	if hours == nil || len(hours.Periods) == 0 {
+		return true, nil // No hours published; assume open
+	}
+
+	weekday := int(date.Weekday()) // Places uses 0 = Sunday, like time.Weekday
+	for _, period := range hours.Periods {
+		// A period without a close time means open around the clock
+		if period.Close == nil || period.Open.Day == weekday {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // Helper methods
 
+// GeocodeResponse is the Google Geocoding API response
+type GeocodeResponse struct {
+	Results []struct {
+		FormattedAddress  string `json:"formatted_address"`
+		AddressComponents []struct {
+			LongName string   `json:"long_name"`
+			Types    []string `json:"types"`
+		} `json:"address_components"`
+	} `json:"results"`
+	Status string `json:"status"`
+}
+
+// ReverseGeocode resolves coordinates to a destination name such as
+// "Jaipur, India"
+func (dsc *DataSourceConnector) ReverseGeocode(ctx context.Context, latitude, longitude float64) (string, error) {
+	if dsc.mapsAPIKey == "" {
+		log.Println("Maps API key not configured, returning coordinates as destination")
+		return fmt.Sprintf("%.4f, %.4f", latitude, longitude), nil
+	}
+
+	baseURL := "https://maps.googleapis.com/maps/api/geocode/json"
+
+	params := url.Values{}
+	params.Add("latlng", fmt.Sprintf("%f,%f", latitude, longitude))
+	params.Add("result_type", "locality|administrative_area_level_1|country")
+	params.Add("key", dsc.mapsAPIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?%s", baseURL, params.Encode()), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create geocode request: %v", err)
+	}
+
+	resp, err := dsc.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reverse geocode: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var geocodeResp GeocodeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&geocodeResp); err != nil {
+		return "", fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	if geocodeResp.Status != "OK" || len(geocodeResp.Results) == 0 {
+		return "", fmt.Errorf("geocoding API error: %s", geocodeResp.Status)
+	}
+
+	// Prefer "City, Country" over a full street address
+	var city, country string
+	for _, component := range geocodeResp.Results[0].AddressComponents {
+		for _, t := range component.Types {
+			switch t {
+			case "locality":
+				city = component.LongName
+			case "country":
+				country = component.LongName
+			}
+		}
+	}
+	if city != "" && country != "" {
+		return fmt.Sprintf("%s, %s", city, country), nil
+	}
+
+	return geocodeResp.Results[0].FormattedAddress, nil
+}
+
 func (dsc *DataSourceConnector) mapInterestsToPlaceTypes(interests []string) []string {
 	placeTypes := []string{"tourist_attraction"} // Default
 
@@ -385,7 +670,7 @@ func (dsc *DataSourceConnector) getMockAttractions(destination string) []Attract
 			PriceLevel:  2,
 			Description: "Local art and cultural exhibits",
 			Available:   true,
-			Tags:        []string{"art", "culture", "indoor"},
+			Tags:        []string{"art", "culture", "indoor", "wheelchair_accessible", "accessible_restroom"},
 		},
 	}
 }
@@ -398,7 +683,7 @@ func (dsc *DataSourceConnector) getMockHotels(destination string) []Hotel {
 			Rating:        4.2,
 			PricePerNight: 120,
 			Available:     true,
-			Amenities:     []string{"WiFi", "Pool", "Restaurant", "Gym"},
+			Amenities:     []string{"WiFi", "Pool", "Restaurant", "Gym", "Elevator"},
 		},
 		{
 			ID:            "hotel_2",
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc serves HTTP requests without a network
+type roundTripFunc func(*http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req), nil
+}
+
+// placeDetails answers Place Details requests with the body listed for the
+// requested place_id
+func placeDetails(bodies map[string]string) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) *http.Response {
+		body, ok := bodies[req.URL.Query().Get("place_id")]
+		if !ok {
+			body = `{"status":"NOT_FOUND"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(strings.NewReader(body))}
+	})}
+}
+
+func TestCheckPlaceAvailability(t *testing.T) {
+	// A Monday; Places numbers days from 0 = Sunday
+	monday := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	dsc := &DataSourceConnector{mapsAPIKey: "key", httpClient: placeDetails(map[string]string{
+		"open":        `{"status":"OK","result":{"business_status":"OPERATIONAL","opening_hours":{"periods":[{"open":{"day":1},"close":{"day":1}}]}}}`,
+		"weekends":    `{"status":"OK","result":{"business_status":"OPERATIONAL","opening_hours":{"periods":[{"open":{"day":0},"close":{"day":0}},{"open":{"day":6},"close":{"day":6}}]}}}`,
+		"always-open": `{"status":"OK","result":{"opening_hours":{"periods":[{"open":{"day":0}}]}}}`,
+		"no-hours":    `{"status":"OK","result":{"business_status":"OPERATIONAL"}}`,
+		"shut":        `{"status":"OK","result":{"business_status":"CLOSED_PERMANENTLY"}}`,
+	})}
+
+	got, err := dsc.CheckPlaceAvailability(context.Background(), []string{"open", "weekends", "always-open", "no-hours", "shut", "missing", "open", ""}, monday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		placeID     string
+		wantChecked bool
+		want        bool
+	}{
+		{"open", true, true},
+		{"weekends", true, false},
+		{"always-open", true, true},
+		{"no-hours", true, true},
+		{"shut", true, false},
+		{"missing", false, false},
+	}
+	for _, tt := range tests {
+		available, checked := got[tt.placeID]
+		if checked != tt.wantChecked || available != tt.want {
+			t.Errorf("%s: available %v, checked %v; want %v, %v", tt.placeID, available, checked, tt.want, tt.wantChecked)
+		}
+	}
+	if len(got) != 5 {
+		t.Errorf("got %d results, want 5 (duplicates and empty IDs skipped)", len(got))
+	}
+
+	if _, err := (&DataSourceConnector{}).CheckPlaceAvailability(context.Background(), []string{"open"}, monday); !errors.Is(err, ErrAvailabilityUnavailable) {
+		t.Errorf("CheckPlaceAvailability without a key = %v, want ErrAvailabilityUnavailable", err)
+	}
+}
+
+func TestValidateAvailability(t *testing.T) {
+	monday := time.Date(2026, 6, 1, 10, 0, 0, 0, time.UTC)
+	live := &DataSourceConnector{mapsAPIKey: "key", httpClient: placeDetails(map[string]string{
+		"open": `{"status":"OK","result":{"business_status":"OPERATIONAL"}}`,
+		"shut": `{"status":"OK","result":{"business_status":"CLOSED_TEMPORARILY"}}`,
+	})}
+
+	tests := []struct {
+		name      string
+		connector *DataSourceConnector
+		want      string
+	}{
+		// "unknown" can't be checked and keeps the flag it had
+		{"live lookups", live, "open:true,shut:false,unknown:false"},
+		{"no connector", nil, "open:true,shut:true,unknown:true"},
+		{"no API key", &DataSourceConnector{}, "open:true,shut:true,unknown:true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attractions := []Attraction{{ID: "open"}, {ID: "shut", Available: true}, {ID: "unknown"}}
+			if err := NewDataValidator(nil, tt.connector).ValidateAvailability(context.Background(), attractions, monday); err != nil {
+				t.Fatal(err)
+			}
+			flags := make([]string, len(attractions))
+			for i, attraction := range attractions {
+				flags[i] = fmt.Sprintf("%s:%v", attraction.ID, attraction.Available)
+			}
+			if got := strings.Join(flags, ","); got != tt.want {
+				t.Errorf("availability = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"math"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -31,6 +34,18 @@ type ValidationCriteria struct {
 	Accessibility     bool                   `json:"accessibility"`
 	Preferences       map[string]interface{} `json:"preferences"`
 	AvailabilityCheck bool                   `json:"availability_check"`
+	// StrictAccessibility excludes items whose accessibility is unverified
+	// instead of down-ranking them
+	StrictAccessibility bool `json:"strict_accessibility"`
+	// Nights is the length of the stay used for hotel cost; values below one
+	// are treated as a single night
+	Nights int `json:"nights"`
+	// BudgetAllocation overrides the default budget split when set
+	BudgetAllocation *BudgetAllocation `json:"budget_allocation,omitempty"`
+	// ReferenceLocation is the point distances are scored from, typically the
+	// chosen hotel when ranking attractions. When unset, the centroid of the
+	// ranked items is used.
+	ReferenceLocation *Location `json:"reference_location,omitempty"`
 }
 
 // RankingWeights defines weights for different ranking factors
@@ -42,6 +57,145 @@ type RankingWeights struct {
 	UserMatch    float64 `json:"user_match"`   // 0.1
 }
 
+// rankingWeightsTolerance is how far from 1 the weights may sum before they
+// are rescaled
+const rankingWeightsTolerance = 0.01
+
+// Normalized validates the weights and rescales them to sum to 1 when they
+// are off by more than rankingWeightsTolerance
+func (w RankingWeights) Normalized() (RankingWeights, error) {
+	if w.Rating < 0 || w.Price < 0 || w.Distance < 0 || w.Availability < 0 || w.UserMatch < 0 {
+		return RankingWeights{}, fmt.Errorf("ranking weights must not be negative")
+	}
+
+	total := w.Rating + w.Price + w.Distance + w.Availability + w.UserMatch
+	if total == 0 {
+		return RankingWeights{}, fmt.Errorf("at least one ranking weight must be positive")
+	}
+	if math.Abs(total-1) <= rankingWeightsTolerance {
+		return w, nil
+	}
+
+	return RankingWeights{
+		Rating:       w.Rating / total,
+		Price:        w.Price / total,
+		Distance:     w.Distance / total,
+		Availability: w.Availability / total,
+		UserMatch:    w.UserMatch / total,
+	}, nil
+}
+
+// BudgetAllocation splits a trip budget across spending categories. Shares
+// are fractions of the total budget; whatever they leave over is kept for
+// miscellaneous costs.
+//
+// This is the single budget policy for retrieval: each hotel must cost at most
+// the Accommodation share for the stay, each attraction at most an even split
+// of the Activities share, and each transport option at most the Transport
+// share. isHotelValid and ApplyBudgetConstraints use the same rules, so a
+// hotel that survives ranking is never dropped by a second, different
+// threshold.
+type BudgetAllocation struct {
+	Accommodation float64 `json:"accommodation"` // 0.4
+	Activities    float64 `json:"activities"`    // 0.3
+	Transport     float64 `json:"transport"`     // 0.2
+}
+
+// DefaultBudgetAllocation returns the default budget split
+func DefaultBudgetAllocation() BudgetAllocation {
+	return BudgetAllocation{
+		Accommodation: 0.4,
+		Activities:    0.3,
+		Transport:     0.2,
+	}
+}
+
+// BudgetAllocationPresets are named splits for common trip styles
+var BudgetAllocationPresets = map[string]BudgetAllocation{
+	"budget":   {Accommodation: 0.3, Activities: 0.3, Transport: 0.25},
+	"balanced": DefaultBudgetAllocation(),
+	"luxury":   {Accommodation: 0.55, Activities: 0.25, Transport: 0.15},
+}
+
+// BudgetAllocationForStyle returns the preset for a trip style, or the
+// default split when style is empty
+func BudgetAllocationForStyle(style string) (BudgetAllocation, error) {
+	if style == "" {
+		return DefaultBudgetAllocation(), nil
+	}
+	allocation, ok := BudgetAllocationPresets[strings.ToLower(style)]
+	if !ok {
+		return BudgetAllocation{}, fmt.Errorf("unknown budget allocation preset %q", style)
+	}
+	return allocation, nil
+}
+
+// Validate rejects negative shares and splits that add up to more than the
+// whole budget
+func (a BudgetAllocation) Validate() error {
+	if a.Accommodation < 0 || a.Activities < 0 || a.Transport < 0 {
+		return fmt.Errorf("budget allocation shares must not be negative")
+	}
+	if total := a.Accommodation + a.Activities + a.Transport; total > 1.0+1e-9 {
+		return fmt.Errorf("budget allocation adds up to %.0f%%, more than 100%%", total*100)
+	}
+	return nil
+}
+
+// nights returns the length of the stay, at least one night
+func (c ValidationCriteria) nights() float64 {
+	if c.Nights < 1 {
+		return 1
+	}
+	return float64(c.Nights)
+}
+
+// TripNights counts the nights between two dates, defaulting to one when
+// either date is missing or they are out of order
+func TripNights(startDate, endDate time.Time) int {
+	if startDate.IsZero() || endDate.IsZero() {
+		return 1
+	}
+	nights := int(endDate.Sub(startDate).Hours() / 24)
+	if nights < 1 {
+		return 1
+	}
+	return nights
+}
+
+// allocation returns the criteria's budget split, or the default
+func (c ValidationCriteria) allocation() BudgetAllocation {
+	if c.BudgetAllocation != nil {
+		return *c.BudgetAllocation
+	}
+	return DefaultBudgetAllocation()
+}
+
+// plannedActivityCount is how many paid activities the activity budget is
+// assumed to cover
+const plannedActivityCount = 5
+
+// hotelWithinBudget applies the accommodation share to a hotel's stay cost
+func (a BudgetAllocation) hotelWithinBudget(hotel Hotel, budget, nights float64) bool {
+	return hotel.PricePerNight*nights <= budget*a.Accommodation
+}
+
+// attractionWithinBudget applies an even split of the activity share
+func (a BudgetAllocation) attractionWithinBudget(attraction Attraction, budget float64) bool {
+	return estimatedAttractionCost(attraction) <= budget*a.Activities/plannedActivityCount
+}
+
+// estimatedAttractionCost roughly prices a visit from the attraction's
+// price level
+func estimatedAttractionCost(attraction Attraction) float64 {
+	return float64(attraction.PriceLevel) * 25
+}
+
+// transportWithinBudget applies the transport share
+func (a BudgetAllocation) transportWithinBudget(transport TransportOption, budget float64) bool {
+	return transport.Price <= budget*a.Transport
+}
+
 // DefaultRankingWeights returns default ranking weights
 func DefaultRankingWeights() RankingWeights {
 	return RankingWeights{
@@ -61,6 +215,9 @@ func (dv *DataValidator) ValidateAndRankAttractions(ctx context.Context, attract
 
 	// Step 1: Validate attractions
 	for _, attraction := range attractions {
+		if criteria.Accessibility {
+			attraction.AccessibilityStatus = accessibilityStatus(attraction.Tags)
+		}
 		if dv.isAttractionValid(attraction, criteria) {
 			validAttractions = append(validAttractions, attraction)
 		}
@@ -93,6 +250,9 @@ func (dv *DataValidator) ValidateAndRankHotels(ctx context.Context, hotels []Hot
 
 	// Step 1: Validate hotels
 	for _, hotel := range hotels {
+		if criteria.Accessibility {
+			hotel.AccessibilityStatus = accessibilityStatus(hotel.Amenities)
+		}
 		if dv.isHotelValid(hotel, criteria) {
 			validHotels = append(validHotels, hotel)
 		}
@@ -117,38 +277,120 @@ func (dv *DataValidator) ValidateAndRankHotels(ctx context.Context, hotels []Hot
 	return rankedHotels, nil
 }
 
-// ValidateAvailability checks real-time availability
+// ValidateAvailability checks live availability for checkDate and updates
+// the Available flags. Attractions and hotels are looked up through the data
+// connector; items it couldn't answer for keep their current flag. When live
+// lookups aren't possible at all, every item is treated as available rather
+// than guessed unavailable. Transport options have no live source and keep
+// the availability reported when they were fetched.
 func (dv *DataValidator) ValidateAvailability(ctx context.Context, items interface{}, checkDate time.Time) error {
-	// This would integrate with real booking systems
-	// For now, mark random items as unavailable for simulation
-
 	switch v := items.(type) {
 	case []Attraction:
+		ids := make([]string, len(v))
+		for i := range v {
+			ids[i] = v[i].ID
+		}
+		availability, live := dv.lookupAvailability(ctx, ids, checkDate)
 		for i := range v {
-			// Simulate some attractions being unavailable
-			if i%5 == 0 {
-				v[i].Available = false
+			if !live {
+				v[i].Available = true
+			} else if available, ok := availability[v[i].ID]; ok {
+				v[i].Available = available
 			}
 		}
 	case []Hotel:
+		ids := make([]string, len(v))
 		for i := range v {
-			// Simulate some hotels being fully booked
-			if i%7 == 0 {
-				v[i].Available = false
-			}
+			ids[i] = v[i].ID
 		}
-	case []TransportOption:
+		availability, live := dv.lookupAvailability(ctx, ids, checkDate)
 		for i := range v {
-			// Simulate some transport options being unavailable
-			if i%4 == 0 {
-				v[i].Available = false
+			if !live {
+				v[i].Available = true
+			} else if available, ok := availability[v[i].ID]; ok {
+				v[i].Available = available
 			}
 		}
+	case []TransportOption:
+		// No live availability source for transport yet
 	}
 
 	return nil
 }
 
+// lookupAvailability returns live availability by ID, and false when live
+// lookups aren't possible so callers fall back to optimistic availability
+func (dv *DataValidator) lookupAvailability(ctx context.Context, ids []string, checkDate time.Time) (map[string]bool, bool) {
+	if dv.dataConnector == nil {
+		return nil, false
+	}
+
+	availability, err := dv.dataConnector.CheckPlaceAvailability(ctx, ids, checkDate)
+	if err != nil {
+		if !errors.Is(err, ErrAvailabilityUnavailable) {
+			log.Printf("Error checking availability: %v", err)
+		}
+		return nil, false
+	}
+	return availability, true
+}
+
+// DayFeasibility reports whether a day's attractions can be visited within
+// the travel time limit
+type DayFeasibility struct {
+	Feasible    bool          `json:"feasible"`
+	TotalTravel time.Duration `json:"total_travel"`
+	// TooFar lists attractions reached by a leg longer than MaxTravelTime
+	TooFar []string `json:"too_far"`
+}
+
+// CheckDayFeasibility walks the day's route from the accommodation through
+// the attractions in order and back, flagging any leg that takes longer than
+// criteria.MaxTravelTime. A zero MaxTravelTime never flags a leg.
+func (dv *DataValidator) CheckDayFeasibility(accommodation Location, attractions []Attraction, criteria ValidationCriteria) DayFeasibility {
+	result := DayFeasibility{Feasible: true, TooFar: []string{}}
+
+	stops := make([]Location, 0, len(attractions)+2)
+	names := make([]string, 0, len(attractions)+2)
+	stops = append(stops, accommodation)
+	names = append(names, "")
+	for _, attraction := range attractions {
+		stops = append(stops, attraction.Location)
+		names = append(names, attraction.Name)
+	}
+	stops = append(stops, accommodation)
+	names = append(names, "")
+
+	for i := 1; i < len(stops); i++ {
+		if !hasLatLng(stops[i-1]) || !hasLatLng(stops[i]) {
+			continue
+		}
+		leg := estimateTravelTime(stops[i-1], stops[i])
+		result.TotalTravel += leg
+
+		if criteria.MaxTravelTime > 0 && leg > criteria.MaxTravelTime {
+			result.Feasible = false
+			// The return leg is blamed on the last attraction
+			name := names[i]
+			if name == "" {
+				name = names[i-1]
+			}
+			if name != "" {
+				result.TooFar = append(result.TooFar, name)
+			}
+		}
+	}
+
+	return result
+}
+
+// estimateTravelTime converts straight-line distance to travel time at the
+// average city speed
+func estimateTravelTime(from, to Location) time.Duration {
+	hours := haversineKm(from, to) / citySpeedKmh
+	return time.Duration(hours * float64(time.Hour))
+}
+
 // Internal types for scoring
 type scoredAttraction struct {
 	attraction Attraction
@@ -173,6 +415,19 @@ func (dv *DataValidator) isAttractionValid(attraction Attraction, criteria Valid
 		return false
 	}
 
+	// Check accessibility requirement
+	if !passesAccessibility(attraction.AccessibilityStatus, criteria) {
+		return false
+	}
+
+	// Check travel time from the accommodation (zero means unlimited)
+	if criteria.MaxTravelTime > 0 && criteria.ReferenceLocation != nil &&
+		hasLatLng(*criteria.ReferenceLocation) && hasLatLng(attraction.Location) {
+		if estimateTravelTime(*criteria.ReferenceLocation, attraction.Location) > criteria.MaxTravelTime {
+			return false
+		}
+	}
+
 	// Check if type matches preferences
 	if len(criteria.PreferredTypes) > 0 {
 		found := false
@@ -211,13 +466,14 @@ func (dv *DataValidator) isHotelValid(hotel Hotel, criteria ValidationCriteria)
 		return false
 	}
 
-	// Check budget (assuming per night, multiply by estimated nights)
-	if criteria.Budget > 0 {
-		estimatedNights := 7.0 // Default assumption
-		totalCost := hotel.PricePerNight * estimatedNights
-		if totalCost > criteria.Budget*0.5 { // Hotels shouldn't exceed 50% of total budget
-			return false
-		}
+	// Check accessibility requirement
+	if !passesAccessibility(hotel.AccessibilityStatus, criteria) {
+		return false
+	}
+
+	// Check budget against the accommodation share
+	if criteria.Budget > 0 && !criteria.allocation().hotelWithinBudget(hotel, criteria.Budget, criteria.nights()) {
+		return false
 	}
 
 	return true
@@ -231,7 +487,13 @@ func (dv *DataValidator) scoreAttractions(attractions []Attraction, criteria Val
 	maxRating := 5.0
 	maxPrice := dv.findMaxAttractionPrice(attractions)
 
-	for _, attraction := range attractions {
+	locations := make([]Location, len(attractions))
+	for i, attraction := range attractions {
+		locations[i] = attraction.Location
+	}
+	closeness, located := dv.distanceScores(criteria.ReferenceLocation, locations)
+
+	for i, attraction := range attractions {
 		score := 0.0
 
 		// Rating score (normalized)
@@ -261,6 +523,10 @@ func (dv *DataValidator) scoreAttractions(attractions []Attraction, criteria Val
 			score += weights.UserMatch * userMatchScore
 		}
 
+		// Distance score (closer = higher score)
+		score = dv.applyDistanceScore(score, weights, closeness[i], located[i])
+		score *= accessibilityScoreFactor(attraction.AccessibilityStatus, criteria)
+
 		scored = append(scored, scoredAttraction{
 			attraction: attraction,
 			score:      score,
@@ -276,7 +542,13 @@ func (dv *DataValidator) scoreHotels(hotels []Hotel, criteria ValidationCriteria
 	maxRating := 5.0
 	maxPrice := dv.findMaxHotelPrice(hotels)
 
-	for _, hotel := range hotels {
+	locations := make([]Location, len(hotels))
+	for i, hotel := range hotels {
+		locations[i] = hotel.Location
+	}
+	closeness, located := dv.distanceScores(criteria.ReferenceLocation, locations)
+
+	for i, hotel := range hotels {
 		score := 0.0
 
 		// Rating score (normalized)
@@ -306,6 +578,10 @@ func (dv *DataValidator) scoreHotels(hotels []Hotel, criteria ValidationCriteria
 			score += weights.UserMatch * userMatchScore
 		}
 
+		// Distance score (closer = higher score)
+		score = dv.applyDistanceScore(score, weights, closeness[i], located[i])
+		score *= accessibilityScoreFactor(hotel.AccessibilityStatus, criteria)
+
 		scored = append(scored, scoredHotel{
 			hotel: hotel,
 			score: score,
@@ -317,6 +593,81 @@ func (dv *DataValidator) scoreHotels(hotels []Hotel, criteria ValidationCriteria
 
 // Helper methods
 
+// distanceScores returns each location's closeness to the reference point,
+// normalized against the farthest location so the farthest scores 0 and the
+// reference itself 1. located[i] is false when location i (or the reference)
+// has no coordinates.
+func (dv *DataValidator) distanceScores(reference *Location, locations []Location) ([]float64, []bool) {
+	closeness := make([]float64, len(locations))
+	located := make([]bool, len(locations))
+
+	var ref Location
+	if reference != nil && hasLatLng(*reference) {
+		ref = *reference
+	} else {
+		// Fall back to the centroid of the items themselves
+		count := 0
+		for _, location := range locations {
+			if hasLatLng(location) {
+				ref.Latitude += location.Latitude
+				ref.Longitude += location.Longitude
+				count++
+			}
+		}
+		if count == 0 {
+			return closeness, located
+		}
+		ref.Latitude /= float64(count)
+		ref.Longitude /= float64(count)
+	}
+
+	distances := make([]float64, len(locations))
+	maxDistance := 0.0
+	for i, location := range locations {
+		if !hasLatLng(location) {
+			continue
+		}
+		located[i] = true
+		distances[i] = haversineKm(ref, location)
+		maxDistance = math.Max(maxDistance, distances[i])
+	}
+
+	for i := range locations {
+		if !located[i] {
+			continue
+		}
+		if maxDistance == 0 {
+			closeness[i] = 1.0
+		} else {
+			closeness[i] = 1.0 - distances[i]/maxDistance
+		}
+	}
+
+	return closeness, located
+}
+
+// applyDistanceScore adds the distance component to score. For items without
+// coordinates the distance weight is redistributed proportionally over the
+// other factors so their scores stay on the same scale as located items.
+func (dv *DataValidator) applyDistanceScore(score float64, weights RankingWeights, closeness float64, located bool) float64 {
+	if weights.Distance <= 0 {
+		return score
+	}
+	if located {
+		return score + weights.Distance*closeness
+	}
+
+	others := weights.Rating + weights.Price + weights.Availability + weights.UserMatch
+	if others <= 0 {
+		return score
+	}
+	return score * (others + weights.Distance) / others
+}
+
+func hasLatLng(location Location) bool {
+	return location.Latitude != 0 || location.Longitude != 0
+}
+
 func (dv *DataValidator) findMaxAttractionPrice(attractions []Attraction) float64 {
 	max := 0.0
 	for _, attraction := range attractions {
@@ -341,20 +692,10 @@ func (dv *DataValidator) calculateAttractionUserMatch(attraction Attraction, cri
 	score := 0.0
 	totalFactors := 0.0
 
-	// Check if attraction type matches preferred types
+	// Interest relevance: the share of the user's interests the attraction
+	// matches, already normalized to 0-1
 	if len(criteria.PreferredTypes) > 0 {
-		for _, preferredType := range criteria.PreferredTypes {
-			if attraction.Type == preferredType {
-				score += 1.0
-			}
-			// Check tags too
-			for _, tag := range attraction.Tags {
-				if tag == preferredType {
-					score += 0.5
-					break
-				}
-			}
-		}
+		score += interestRelevance(attraction, criteria.PreferredTypes)
 		totalFactors += 1.0
 	}
 
@@ -384,13 +725,37 @@ func (dv *DataValidator) calculateAttractionUserMatch(attraction Attraction, cri
 	return math.Min(score/totalFactors, 1.0)
 }
 
+// interestRelevance returns the fraction of interests matched by the
+// attraction's type or tags. This is the single relevance signal in ranking;
+// it feeds the UserMatch weight rather than a separate sort.
+func interestRelevance(attraction Attraction, interests []string) float64 {
+	if len(interests) == 0 {
+		return 0
+	}
+
+	matched := 0
+	for _, interest := range interests {
+		if strings.EqualFold(attraction.Type, interest) {
+			matched++
+			continue
+		}
+		for _, tag := range attraction.Tags {
+			if strings.EqualFold(tag, interest) {
+				matched++
+				break
+			}
+		}
+	}
+
+	return float64(matched) / float64(len(interests))
+}
+
 func (dv *DataValidator) calculateHotelUserMatch(hotel Hotel, criteria ValidationCriteria) float64 {
 	score := 0.5 // Base score
 
 	// Budget alignment
 	if criteria.Budget > 0 {
-		estimatedNights := 7.0
-		hotelBudgetRatio := (hotel.PricePerNight * estimatedNights) / criteria.Budget
+		hotelBudgetRatio := (hotel.PricePerNight * criteria.nights()) / criteria.Budget
 
 		if hotelBudgetRatio <= 0.3 { // Very affordable
 			score += 0.3
@@ -419,26 +784,28 @@ func (dv *DataValidator) calculateHotelUserMatch(hotel Hotel, criteria Validatio
 	return math.Min(math.Max(score, 0.0), 1.0)
 }
 
-// ApplyBudgetConstraints applies budget constraints to the entire trip context
-func (dv *DataValidator) ApplyBudgetConstraints(ctx context.Context, tripContext *TripContext, totalBudget float64) error {
+// ApplyBudgetConstraints applies the budget policy to the entire trip
+// context using the criteria's budget, allocation and length of stay.
+// Filtering keeps the existing order, so lists that were already ranked stay
+// ranked.
+func (dv *DataValidator) ApplyBudgetConstraints(ctx context.Context, tripContext *TripContext, criteria ValidationCriteria) error {
+	totalBudget := criteria.Budget
 	if totalBudget <= 0 {
 		return nil // No budget constraints
 	}
 
-	log.Printf("Applying budget constraints: $%.2f", totalBudget)
+	allocation := criteria.allocation()
+	if err := allocation.Validate(); err != nil {
+		return err
+	}
+	nights := criteria.nights()
 
-	// Allocate budget percentages
-	hotelBudget := totalBudget * 0.4     // 40% for accommodation
-	activityBudget := totalBudget * 0.3  // 30% for activities
-	transportBudget := totalBudget * 0.2 // 20% for transportation
-	// Remaining 10% for miscellaneous
+	log.Printf("Applying budget constraints: $%.2f over %.0f nights", totalBudget, nights)
 
 	// Filter hotels by budget
 	var affordableHotels []Hotel
 	for _, hotel := range tripContext.Hotels {
-		estimatedNights := 7.0 // Default
-		totalHotelCost := hotel.PricePerNight * estimatedNights
-		if totalHotelCost <= hotelBudget {
+		if allocation.hotelWithinBudget(hotel, totalBudget, nights) {
 			affordableHotels = append(affordableHotels, hotel)
 		}
 	}
@@ -447,8 +814,7 @@ func (dv *DataValidator) ApplyBudgetConstraints(ctx context.Context, tripContext
 	// Filter attractions by price level and budget
 	var affordableAttractions []Attraction
 	for _, attraction := range tripContext.Attractions {
-		estimatedCost := float64(attraction.PriceLevel) * 25 // Rough estimation
-		if estimatedCost <= activityBudget/5 {               // Assuming 5 activities
+		if allocation.attractionWithinBudget(attraction, totalBudget) {
 			affordableAttractions = append(affordableAttractions, attraction)
 		}
 	}
@@ -457,7 +823,7 @@ func (dv *DataValidator) ApplyBudgetConstraints(ctx context.Context, tripContext
 	// Filter transportation by budget
 	var affordableTransport []TransportOption
 	for _, transport := range tripContext.Transportation {
-		if transport.Price <= transportBudget {
+		if allocation.transportWithinBudget(transport, totalBudget) {
 			affordableTransport = append(affordableTransport, transport)
 		}
 	}
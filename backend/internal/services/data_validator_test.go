@@ -0,0 +1,374 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// at is a point on one latitude, so distances between points are
+// proportional to the longitude difference
+func at(longitude float64) Location {
+	return Location{Latitude: 28.6, Longitude: longitude}
+}
+
+func attractionNames(attractions []Attraction) string {
+	names := make([]string, len(attractions))
+	for i, attraction := range attractions {
+		names[i] = attraction.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func TestDistanceScores(t *testing.T) {
+	reference := at(77.20)
+	tests := []struct {
+		name          string
+		reference     *Location
+		locations     []Location
+		wantCloseness []float64
+		wantLocated   []bool
+	}{
+		{"from the reference", &reference, []Location{at(77.21), at(77.30), at(77.25), {}}, []float64{0.9, 0, 0.5, 0}, []bool{true, true, true, false}},
+		{"centroid without a reference", nil, []Location{at(77.00), at(77.02), at(77.04)}, []float64{0, 1, 0}, []bool{true, true, true}},
+		{"reference without coordinates", &Location{}, []Location{at(77.00), at(77.04)}, []float64{0, 0}, []bool{true, true}},
+		{"everything at the reference", &reference, []Location{at(77.20), at(77.20)}, []float64{1, 1}, []bool{true, true}},
+		{"nothing located", nil, []Location{{}, {}}, []float64{0, 0}, []bool{false, false}},
+	}
+	dv := &DataValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closeness, located := dv.distanceScores(tt.reference, tt.locations)
+			for i := range tt.locations {
+				if math.Abs(closeness[i]-tt.wantCloseness[i]) > 1e-3 || located[i] != tt.wantLocated[i] {
+					t.Errorf("location %d: closeness %.3f, located %v; want %.3f, %v", i, closeness[i], located[i], tt.wantCloseness[i], tt.wantLocated[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRankAttractionsByDistance(t *testing.T) {
+	hotel, otherHotel := at(77.20), at(77.30)
+	attraction := func(name string, location Location) Attraction {
+		return Attraction{Name: name, Location: location, Rating: 4, Available: true}
+	}
+	attractions := []Attraction{
+		attraction("Far", at(77.30)),
+		attraction("Unmapped", Location{}),
+		attraction("Mid", at(77.25)),
+		attraction("Near", at(77.21)),
+	}
+
+	tests := []struct {
+		name      string
+		reference *Location
+		want      string
+	}{
+		// Unmapped has the distance weight spread over its other factors,
+		// which lands it between Near and Mid
+		{"closest to the hotel first", &hotel, "Near,Unmapped,Mid,Far"},
+		{"from another hotel", &otherHotel, "Far,Unmapped,Mid,Near"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked, err := (&DataValidator{}).ValidateAndRankAttractions(context.Background(), attractions, ValidationCriteria{ReferenceLocation: tt.reference}, DefaultRankingWeights())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := attractionNames(ranked); got != tt.want {
+				t.Errorf("ranked = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckDayFeasibility(t *testing.T) {
+	hotel := at(77.20)
+	stops := func(longitudes ...float64) []Attraction {
+		attractions := make([]Attraction, len(longitudes))
+		for i, longitude := range longitudes {
+			attractions[i] = Attraction{Name: string(rune('A' + i)), Location: at(longitude)}
+			if longitude == 0 {
+				attractions[i].Location = Location{}
+			}
+		}
+		return attractions
+	}
+	// 0.01 degrees of longitude is about 2.3 minutes at city speed here
+	tests := []struct {
+		name         string
+		attractions  []Attraction
+		maxTravel    time.Duration
+		wantFeasible bool
+		wantTooFar   string
+		wantTotal    time.Duration
+	}{
+		{"all close", stops(77.21, 77.22), 10 * time.Minute, true, "", 9 * time.Minute},
+		{"long way back", stops(77.21, 77.30), 22 * time.Minute, false, "B", 46 * time.Minute},
+		{"one stop out of the way", stops(77.21, 77.32, 77.22), 20 * time.Minute, false, "B,C", 56 * time.Minute},
+		{"no limit", stops(77.21, 77.30), 0, true, "", 46 * time.Minute},
+		{"unmapped stops are skipped", stops(0, 77.21), 10 * time.Minute, true, "", 2 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := (&DataValidator{}).CheckDayFeasibility(hotel, tt.attractions, ValidationCriteria{MaxTravelTime: tt.maxTravel})
+			if got.Feasible != tt.wantFeasible || strings.Join(got.TooFar, ",") != tt.wantTooFar {
+				t.Errorf("feasible %v, too far %v; want %v, %s", got.Feasible, got.TooFar, tt.wantFeasible, tt.wantTooFar)
+			}
+			if got.TotalTravel.Truncate(time.Minute) != tt.wantTotal {
+				t.Errorf("total travel = %v, want about %v", got.TotalTravel, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestMaxTravelTimeFilter(t *testing.T) {
+	hotel := at(77.20)
+	attractions := []Attraction{
+		{Name: "Near", Location: at(77.21)},
+		{Name: "Far", Location: at(77.30)},
+		{Name: "Unmapped"},
+	}
+	tests := []struct {
+		name      string
+		criteria  ValidationCriteria
+		wantNames []string
+	}{
+		{"far attraction dropped", ValidationCriteria{MaxTravelTime: 15 * time.Minute, ReferenceLocation: &hotel}, []string{"Near", "Unmapped"}},
+		{"no limit", ValidationCriteria{ReferenceLocation: &hotel}, []string{"Far", "Near", "Unmapped"}},
+		{"no accommodation to measure from", ValidationCriteria{MaxTravelTime: 15 * time.Minute}, []string{"Far", "Near", "Unmapped"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var kept []string
+			for _, attraction := range attractions {
+				if (&DataValidator{}).isAttractionValid(attraction, tt.criteria) {
+					kept = append(kept, attraction.Name)
+				}
+			}
+			sort.Strings(kept)
+			if strings.Join(kept, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("kept %v, want %v", kept, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestBudgetPolicyIsConsistent(t *testing.T) {
+	// With the default split a 1000 budget allows 400 for the stay, 60 per
+	// attraction (a fifth of 300) and 200 for transport
+	criteria := ValidationCriteria{Budget: 1000}
+	tests := []struct {
+		name           string
+		hotelPrice     float64
+		attractionTier int
+		transportPrice float64
+		wantKept       bool
+	}{
+		{"well within", 300, 2, 150, true},
+		{"exactly at the share", 400, 2, 200, true},
+		{"just over", 401, 3, 201, false},
+		{"far over", 1000, 4, 900, false},
+	}
+	dv := &DataValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hotel := Hotel{Name: "Hotel", PricePerNight: tt.hotelPrice}
+			tripContext := &TripContext{
+				Hotels:         []Hotel{hotel},
+				Attractions:    []Attraction{{Name: "Attraction", PriceLevel: tt.attractionTier}},
+				Transportation: []TransportOption{{Type: "train", Price: tt.transportPrice}},
+			}
+			if err := dv.ApplyBudgetConstraints(context.Background(), tripContext, criteria); err != nil {
+				t.Fatal(err)
+			}
+			valid := dv.isHotelValid(hotel, criteria)
+			constrained := len(tripContext.Hotels) == 1
+			if valid != tt.wantKept || constrained != tt.wantKept {
+				t.Errorf("hotel at %.0f: validation keeps it %v, budget constraints keep it %v; want %v", tt.hotelPrice, valid, constrained, tt.wantKept)
+			}
+			if kept := len(tripContext.Attractions) == 1; kept != tt.wantKept {
+				t.Errorf("attraction at price level %d kept %v, want %v", tt.attractionTier, kept, tt.wantKept)
+			}
+			if kept := len(tripContext.Transportation) == 1; kept != tt.wantKept {
+				t.Errorf("transport at %.0f kept %v, want %v", tt.transportPrice, kept, tt.wantKept)
+			}
+		})
+	}
+
+	t.Run("no budget", func(t *testing.T) {
+		tripContext := &TripContext{Hotels: []Hotel{{PricePerNight: 5000}}}
+		if err := dv.ApplyBudgetConstraints(context.Background(), tripContext, ValidationCriteria{}); err != nil || len(tripContext.Hotels) != 1 {
+			t.Errorf("ApplyBudgetConstraints without a budget = %v, kept %d hotels; want everything kept", err, len(tripContext.Hotels))
+		}
+	})
+}
+
+func TestBudgetAllocationForStyle(t *testing.T) {
+	custom := BudgetAllocation{Accommodation: 0.5, Activities: 0.4, Transport: 0.1}
+	overspent := BudgetAllocation{Accommodation: 0.6, Activities: 0.4, Transport: 0.2}
+	tests := []struct {
+		name    string
+		req     RetrievalRequest
+		want    BudgetAllocation
+		wantErr bool
+	}{
+		{"no style", RetrievalRequest{}, DefaultBudgetAllocation(), false},
+		{"budget", RetrievalRequest{TravelStyle: "budget"}, BudgetAllocationPresets["budget"], false},
+		{"case-insensitive", RetrievalRequest{TravelStyle: "Luxury"}, BudgetAllocationPresets["luxury"], false},
+		{"unknown style", RetrievalRequest{TravelStyle: "lavish"}, BudgetAllocation{}, true},
+		{"explicit split wins", RetrievalRequest{TravelStyle: "budget", BudgetAllocation: &custom}, custom, false},
+		{"split over 100%", RetrievalRequest{BudgetAllocation: &overspent}, BudgetAllocation{}, true},
+		{"negative share", RetrievalRequest{BudgetAllocation: &BudgetAllocation{Accommodation: -0.1}}, BudgetAllocation{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.req.budgetAllocation()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("budgetAllocation = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	for style, allocation := range BudgetAllocationPresets {
+		if err := allocation.Validate(); err != nil {
+			t.Errorf("preset %s: %v", style, err)
+		}
+	}
+
+	// The same hotel fits a luxury budget split but not a budget one
+	hotel := Hotel{PricePerNight: 500}
+	for style, want := range map[string]bool{"budget": false, "luxury": true} {
+		allocation := BudgetAllocationPresets[style]
+		if got := (&DataValidator{}).isHotelValid(hotel, ValidationCriteria{Budget: 1000, BudgetAllocation: &allocation}); got != want {
+			t.Errorf("500 a night on a %s split of 1000: valid = %v, want %v", style, got, want)
+		}
+	}
+}
+
+func TestTripNights(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 5, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       int
+	}{
+		{"weekend", day(1), day(3), 2},
+		{"week", day(1), day(8), 7},
+		{"day trip", day(1), day(1), 1},
+		{"out of order", day(5), day(1), 1},
+		{"no end date", day(1), time.Time{}, 1},
+	}
+	for _, tt := range tests {
+		if got := TripNights(tt.start, tt.end); got != tt.want {
+			t.Errorf("%s: TripNights = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHotelBudgetUsesLengthOfStay(t *testing.T) {
+	// 40% of 1000 is 400 for the whole stay
+	hotel := Hotel{PricePerNight: 150}
+	tests := []struct {
+		nights int
+		want   bool
+	}{
+		{0, true},
+		{1, true},
+		{2, true},
+		{3, false},
+		{7, false},
+	}
+	for _, tt := range tests {
+		criteria := ValidationCriteria{Budget: 1000, Nights: tt.nights}
+		tripContext := &TripContext{Hotels: []Hotel{hotel}}
+		if err := (&DataValidator{}).ApplyBudgetConstraints(context.Background(), tripContext, criteria); err != nil {
+			t.Fatal(err)
+		}
+		valid := (&DataValidator{}).isHotelValid(hotel, criteria)
+		if valid != tt.want || (len(tripContext.Hotels) == 1) != tt.want {
+			t.Errorf("150 a night for %d nights: valid %v, kept %v; want %v", tt.nights, valid, len(tripContext.Hotels) == 1, tt.want)
+		}
+	}
+}
+
+func TestInterestRelevance(t *testing.T) {
+	museum := Attraction{Name: "City Museum", Type: "museum", Tags: []string{"History", "indoor"}}
+	tests := []struct {
+		name      string
+		interests []string
+		want      float64
+	}{
+		{"type match", []string{"museum"}, 1},
+		{"tag match ignores case", []string{"history"}, 1},
+		{"half matched", []string{"Museum", "beaches"}, 0.5},
+		{"type and tag each count once", []string{"museum", "history", "food", "nightlife"}, 0.5},
+		{"nothing matched", []string{"beaches"}, 0},
+		{"no interests", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := interestRelevance(museum, tt.interests); got != tt.want {
+			t.Errorf("%s: interestRelevance(%v) = %.2f, want %.2f", tt.name, tt.interests, got, tt.want)
+		}
+	}
+}
+
+func TestRankAttractionsByInterests(t *testing.T) {
+	attractions := []Attraction{
+		{Name: "Fort", Type: "history", Tags: []string{"architecture"}, Rating: 4.2, Available: true},
+		{Name: "Market", Type: "shopping", Tags: []string{"food", "history"}, Rating: 4.2, Available: true},
+		{Name: "Museum", Type: "museum", Tags: []string{"history", "architecture", "art"}, Rating: 4.2, Available: true},
+	}
+	// Only user match differs, so relevance decides the order
+	weights := RankingWeights{Rating: 0.5, UserMatch: 0.5}
+	tests := []struct {
+		name      string
+		interests []string
+		want      string
+	}{
+		{"architecture and art", []string{"architecture", "art"}, "Museum,Fort"},
+		{"food", []string{"food"}, "Market"},
+		{"more matches rank higher", []string{"art", "architecture", "history"}, "Museum,Fort,Market"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked, err := (&DataValidator{}).ValidateAndRankAttractions(context.Background(), attractions, ValidationCriteria{PreferredTypes: tt.interests}, weights)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := attractionNames(ranked); got != tt.want {
+				t.Errorf("ranked = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankingWeightsNormalized(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights RankingWeights
+		want    RankingWeights
+		wantErr bool
+	}{
+		{"defaults unchanged", DefaultRankingWeights(), DefaultRankingWeights(), false},
+		{"within tolerance unchanged", RankingWeights{Rating: 0.5, Price: 0.505}, RankingWeights{Rating: 0.5, Price: 0.505}, false},
+		{"rescaled", RankingWeights{Rating: 3, Price: 1}, RankingWeights{Rating: 0.75, Price: 0.25}, false},
+		{"single factor", RankingWeights{Distance: 0.2}, RankingWeights{Distance: 1}, false},
+		{"negative", RankingWeights{Rating: 1.2, Price: -0.2}, RankingWeights{}, true},
+		{"all zero", RankingWeights{}, RankingWeights{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.weights.Normalized()
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("Normalized = %+v, %v; want %+v, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,343 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/logging"
+)
+
+// DayRegeneration is a freshly planned replacement for one day of a trip.
+// It isn't saved until SetItineraryDay writes it into the trip's itinerary.
+type DayRegeneration struct {
+	TripID         string                 `json:"trip_id"`
+	Day            int                    `json:"day"`
+	OriginalDay    map[string]interface{} `json:"original_day"`
+	RegeneratedDay map[string]interface{} `json:"regenerated_day"`
+	// Hotel is where the traveler stays that night, when the trip says
+	Hotel string `json:"hotel,omitempty"`
+	// StartFrom is where the previous day ended, which the new day starts
+	// near; the hotel on day 1
+	StartFrom string   `json:"start_from,omitempty"`
+	Kept      []string `json:"kept,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+// dayAnchor is a named place a day is planned around
+type dayAnchor struct {
+	name     string
+	location Location
+}
+
+// RegenerateDay plans one day of a trip afresh from ragContext, leaving the
+// other days alone. Attractions already on other days aren't repeated, booked
+// activities on the day itself are kept in their slots, and the new day
+// starts near where the previous day ended, or the hotel on the first day.
+// Nothing is saved.
+func (g *GeminiService) RegenerateDay(ctx context.Context, trip *TripData, day int, ragContext TripContext, preferences map[string]interface{}) (*DayRegeneration, error) {
+	if day < 1 {
+		return nil, kindErrorf(ErrValidation, "day must be 1 or later, got %d", day)
+	}
+	days, err := planDays(trip.Itinerary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid itinerary: %w", err)
+	}
+	originalDay, ok := days[day]
+	if !ok {
+		return nil, ErrDayNotFound
+	}
+
+	if preferences == nil {
+		preferences = make(map[string]interface{})
+	}
+	if _, ok := preferences["pace"]; !ok {
+		if pace, ok := originalDay["pace"].(string); ok {
+			preferences["pace"] = pace
+		}
+	}
+
+	regeneration := &DayRegeneration{
+		TripID:      trip.ID,
+		Day:         day,
+		OriginalDay: originalDay,
+	}
+
+	hotel, hasHotel := tripHotel(trip.Itinerary, ragContext)
+	start, hasStart := dayEndPoint(days[day-1])
+	if hasHotel {
+		regeneration.Hotel = hotel.name
+		if !hasStart {
+			start, hasStart = hotel, true
+		}
+	}
+	if hasStart {
+		regeneration.StartFrom = start.name
+	}
+
+	// Skip what's planned elsewhere, booked or not, so the trip doesn't
+	// visit the same place twice
+	var elsewhere []string
+	for number, other := range days {
+		if number == day {
+			continue
+		}
+		for _, slot := range planSlots {
+			for _, activity := range planActivities(other[slot]) {
+				if name := activityName(activity); name != "" {
+					elsewhere = append(elsewhere, name)
+				}
+			}
+		}
+	}
+
+	// The day's own booked activities stay, so they aren't candidates either
+	taken := elsewhere
+	for _, slot := range planSlots {
+		for _, activity := range planActivities(originalDay[slot]) {
+			if isBookedActivity(activity) {
+				taken = append(taken, activityName(activity))
+			}
+		}
+	}
+
+	var candidates []Attraction
+	for _, attraction := range ragContext.Attractions {
+		if mentionedIn(attraction.Name, taken) {
+			continue
+		}
+		candidates = append(candidates, attraction)
+	}
+	if hasStart && hasLatLng(start.location) {
+		candidates = nearestFirst(start.location, candidates)
+	}
+	if len(candidates) == 0 {
+		regeneration.Notes = append(regeneration.Notes, "Every available attraction is already on another day, so only the booked activities and the evening are planned")
+	}
+
+	regenerated := g.generateDay(ctx, trip, day, candidates, elsewhere, regeneration, preferences)
+	regeneration.Kept = keepBookedActivities(originalDay, regenerated)
+	regenerated["regenerated"] = true
+	regeneration.RegeneratedDay = regenerated
+	return regeneration, nil
+}
+
+// generateDay asks Gemini for the new day, laying it out from the candidates
+// when there's no API key or the response can't be used
+func (g *GeminiService) generateDay(ctx context.Context, trip *TripData, day int, candidates []Attraction, elsewhere []string, regeneration *DayRegeneration, preferences map[string]interface{}) map[string]interface{} {
+	fallback := func() map[string]interface{} {
+		daytime, evening := g.splitEveningAttractions(candidates)
+		var eveningAttraction *Attraction
+		if len(evening) > 0 {
+			eveningAttraction = &evening[0]
+		}
+		return g.layoutDay(daytime, eveningAttraction, nil, preferences)
+	}
+	if g.apiKey == "" {
+		return fallback()
+	}
+
+	logger := logging.FromContext(ctx).With("trip_id", trip.ID, "day", day)
+	start := time.Now()
+
+	response, err := g.callGeminiAPI(ctx, g.buildDayPrompt(trip, day, candidates, elsewhere, regeneration, preferences), GeminiCallOptions{UseCase: GeminiStructured})
+	if err != nil {
+		logger.Warn("Gemini API call failed, falling back to mock day", "error", err, "latency", time.Since(start))
+		return fallback()
+	}
+	var dayPlan map[string]interface{}
+	if err := json.Unmarshal([]byte(response), &dayPlan); err != nil || len(dayPlan) == 0 {
+		logger.Warn("Failed to parse regenerated day as JSON, using mock day", "error", err)
+		return fallback()
+	}
+	logger.Info("Regenerated day", "latency", time.Since(start))
+
+	dayPlan["ai_generated"] = true
+	return dayPlan
+}
+
+// buildDayPrompt creates a prompt to replan one day of an existing trip
+func (g *GeminiService) buildDayPrompt(trip *TripData, day int, candidates []Attraction, elsewhere []string, regeneration *DayRegeneration, preferences map[string]interface{}) string {
+	var options strings.Builder
+	for _, attraction := range candidates[:min(10, len(candidates))] {
+		fmt.Fprintf(&options, "- %s: %s\n", attraction.Name, attraction.Description)
+	}
+	if options.Len() == 0 {
+		options.WriteString("- (none; suggest restaurants, markets or neighbourhood walks)\n")
+	}
+
+	constraints := ""
+	if regeneration.Hotel != "" {
+		constraints += fmt.Sprintf("- The traveler is staying at %s; end the day within easy reach of it.\n", regeneration.Hotel)
+	}
+	if regeneration.StartFrom != "" && regeneration.StartFrom != regeneration.Hotel {
+		constraints += fmt.Sprintf("- The previous day ended at %s; start nearby.\n", regeneration.StartFrom)
+	}
+	if len(elsewhere) > 0 {
+		constraints += fmt.Sprintf("- Don't repeat anything planned on other days: %s.\n", userInput("other_days", strings.Join(elsewhere, "; ")))
+	}
+
+	return fmt.Sprintf(`Replan day %d of a trip to %s for %d travelers with a total budget of $%.2f. The other days are fixed.
+
+Constraints:
+%s
+Available attractions:
+%s
+Format the response as a single JSON object with "morning", "afternoon" and "evening" keys.`,
+		day, userInput("destination", trip.Destination), trip.Travelers, trip.Budget, constraints, options.String()) +
+		buildPacePromptSection(preferences) + userInputNotice
+}
+
+// SetItineraryDay replaces the day numbered day in an itinerary, wherever
+// the itinerary keeps its days
+func SetItineraryDay(itinerary map[string]interface{}, day int, dayPlan map[string]interface{}) error {
+	days := itinerary
+	if nested, ok := itinerary["itinerary"].(map[string]interface{}); ok {
+		days = nested
+	} else if nested, ok := itinerary["daily_itinerary"].(map[string]interface{}); ok {
+		days = nested
+	}
+
+	for key := range days {
+		if match := planDayKey.FindStringSubmatch(key); match != nil && match[1] == fmt.Sprint(day) {
+			days[key] = dayPlan
+			return nil
+		}
+	}
+	return ErrDayNotFound
+}
+
+// isBookedActivity reports whether an activity has a booking that a replan
+// must not drop
+func isBookedActivity(activity map[string]interface{}) bool {
+	if booked, ok := activity["booked"].(bool); ok && booked {
+		return true
+	}
+	switch strings.ToLower(getStringFromMetadata(activity, "booking_status")) {
+	case "booked", "confirmed", "paid":
+		return true
+	}
+	return getStringFromMetadata(activity, "booking_reference") != ""
+}
+
+// keepBookedActivities carries the original day's booked activities into the
+// regenerated day, in the slots they were booked for, and returns their names
+func keepBookedActivities(original, regenerated map[string]interface{}) []string {
+	var kept []string
+	for _, slot := range planSlots {
+		var booked []map[string]interface{}
+		for _, activity := range planActivities(original[slot]) {
+			if isBookedActivity(activity) {
+				booked = append(booked, activity)
+				kept = append(kept, activityName(activity))
+			}
+		}
+		if len(booked) == 0 {
+			continue
+		}
+
+		// Booked activities go first, and anything new in the slot that
+		// duplicates one is dropped
+		activities := booked
+		for _, activity := range planActivities(regenerated[slot]) {
+			duplicate := false
+			for _, name := range kept {
+				duplicate = duplicate || mentionedIn(name, []string{activityName(activity)})
+			}
+			if !duplicate {
+				activities = append(activities, activity)
+			}
+		}
+		regenerated[slot] = slotValue(original[slot], activities)
+	}
+	return kept
+}
+
+// tripHotel returns the hotel the trip stays at: the itinerary's own hotel,
+// then its first recommended hotel, then the best retrieved one
+func tripHotel(itinerary map[string]interface{}, ragContext TripContext) (dayAnchor, bool) {
+	for _, key := range []string{"hotel", "accommodation"} {
+		switch hotel := itinerary[key].(type) {
+		case string:
+			if hotel != "" {
+				return dayAnchor{name: hotel}, true
+			}
+		case map[string]interface{}:
+			if name := activityName(hotel); name != "" {
+				location, _ := metadataLocation(hotel)
+				return dayAnchor{name: name, location: location}, true
+			}
+		}
+	}
+	if hotels := planActivities(itinerary["recommended_hotels"]); len(hotels) > 0 {
+		if name := activityName(hotels[0]); name != "" {
+			location, _ := metadataLocation(hotels[0])
+			return dayAnchor{name: name, location: location}, true
+		}
+	}
+	if len(ragContext.Hotels) > 0 {
+		return dayAnchor{name: ragContext.Hotels[0].Name, location: ragContext.Hotels[0].Location}, true
+	}
+	return dayAnchor{}, false
+}
+
+// dayEndPoint returns the last named activity of a day, which is where the
+// next day starts from
+func dayEndPoint(dayPlan map[string]interface{}) (dayAnchor, bool) {
+	for i := len(planSlots) - 1; i >= 0; i-- {
+		activities := planActivities(dayPlan[planSlots[i]])
+		for j := len(activities) - 1; j >= 0; j-- {
+			if name := activityName(activities[j]); name != "" {
+				location, _ := metadataLocation(activities[j])
+				return dayAnchor{name: name, location: location}, true
+			}
+		}
+	}
+	return dayAnchor{}, false
+}
+
+// nearestFirst orders attractions as a walk from origin, each stop the
+// nearest one left. Attractions without coordinates keep their order at the
+// end.
+func nearestFirst(origin Location, attractions []Attraction) []Attraction {
+	var located, unlocated []Attraction
+	for _, attraction := range attractions {
+		if hasLatLng(attraction.Location) {
+			located = append(located, attraction)
+		} else {
+			unlocated = append(unlocated, attraction)
+		}
+	}
+
+	ordered := make([]Attraction, 0, len(attractions))
+	current := origin
+	for len(located) > 0 {
+		nearest := 0
+		for i := range located {
+			if haversineKm(current, located[i].Location) < haversineKm(current, located[nearest].Location) {
+				nearest = i
+			}
+		}
+		ordered = append(ordered, located[nearest])
+		current = located[nearest].Location
+		located = append(located[:nearest], located[nearest+1:]...)
+	}
+	return append(ordered, unlocated...)
+}
+
+// mentionedIn reports whether name appears in any of texts, ignoring case.
+// Generated plans often embed names in longer text, like "Visit X - ...".
+func mentionedIn(name string, texts []string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return false
+	}
+	for _, text := range texts {
+		if strings.Contains(strings.ToLower(text), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dayNames lists a day plan's activities slot by slot
+func dayNames(dayPlan map[string]interface{}) string {
+	var slots []string
+	for _, slot := range planSlots {
+		var names []string
+		for _, activity := range planActivities(dayPlan[slot]) {
+			names = append(names, activityName(activity))
+		}
+		if len(names) > 0 {
+			slots = append(slots, slot+": "+strings.Join(names, ", "))
+		}
+	}
+	return strings.Join(slots, "; ")
+}
+
+func TestRegenerateDay(t *testing.T) {
+	trip := &TripData{
+		ID:          "t1",
+		Destination: "Jaipur",
+		Itinerary: map[string]interface{}{
+			"hotel": "Rambagh Palace",
+			"day_1": map[string]interface{}{"morning": "Visit Amber Fort - hilltop fort", "afternoon": "Explore Jal Mahal - lake palace"},
+			"day_2": map[string]interface{}{
+				"pace":      PaceModerate,
+				"morning":   map[string]interface{}{"name": "Cooking class", "booked": true},
+				"afternoon": "Explore City Palace - royal residence",
+			},
+			"day_3": map[string]interface{}{"morning": "Visit Hawa Mahal - palace of winds"},
+		},
+	}
+	attraction := func(name string) Attraction {
+		return Attraction{Name: name, Type: "museum", Description: "sights"}
+	}
+	ragContext := TripContext{Attractions: []Attraction{
+		attraction("Amber Fort"), attraction("Cooking class"), attraction("City Palace"),
+		attraction("Albert Hall"), attraction("Hawa Mahal"), attraction("Jantar Mantar"),
+	}}
+
+	tests := []struct {
+		name          string
+		day           int
+		ragContext    TripContext
+		want          string
+		wantStartFrom string
+		wantKept      string
+		wantNote      bool
+		wantErr       error
+	}{
+		{
+			name: "skips other days and keeps bookings", day: 2, ragContext: ragContext,
+			want:          "morning: Cooking class, Visit City Palace - sights; afternoon: Explore Albert Hall - sights; evening: Dinner at a local restaurant and a relaxed evening",
+			wantStartFrom: "Explore Jal Mahal - lake palace", wantKept: "Cooking class",
+		},
+		{
+			name: "first day starts at the hotel", day: 1, ragContext: ragContext,
+			want:          "morning: Visit Amber Fort - sights; afternoon: Explore Albert Hall - sights; evening: Dinner at a local restaurant and a relaxed evening",
+			wantStartFrom: "Rambagh Palace",
+		},
+		{
+			name: "nothing left to visit", day: 2, ragContext: TripContext{Attractions: []Attraction{attraction("Hawa Mahal")}},
+			want:          "morning: Cooking class; evening: Dinner at a local restaurant and a relaxed evening",
+			wantStartFrom: "Explore Jal Mahal - lake palace", wantKept: "Cooking class", wantNote: true,
+		},
+		{name: "no such day", day: 9, ragContext: ragContext, wantErr: ErrDayNotFound},
+		{name: "day zero", day: 0, ragContext: ragContext, wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			regeneration, err := (&GeminiService{}).RegenerateDay(context.Background(), trip, tt.day, tt.ragContext, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RegenerateDay error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := dayNames(regeneration.RegeneratedDay); got != tt.want {
+				t.Errorf("regenerated day = %s\nwant               %s", got, tt.want)
+			}
+			if regeneration.StartFrom != tt.wantStartFrom || regeneration.Hotel != "Rambagh Palace" || strings.Join(regeneration.Kept, ",") != tt.wantKept {
+				t.Errorf("start %q, hotel %q, kept %v; want %q, Rambagh Palace, %s", regeneration.StartFrom, regeneration.Hotel, regeneration.Kept, tt.wantStartFrom, tt.wantKept)
+			}
+			if (len(regeneration.Notes) > 0) != tt.wantNote {
+				t.Errorf("notes = %q, want a note: %v", regeneration.Notes, tt.wantNote)
+			}
+			if regeneration.RegeneratedDay["regenerated"] != true {
+				t.Error("regenerated day isn't marked as regenerated")
+			}
+		})
+	}
+
+	// Nothing is saved until SetItineraryDay writes the day in
+	if got := dayNames(trip.Itinerary["day_2"].(map[string]interface{})); got != "morning: Cooking class; afternoon: Explore City Palace - royal residence" {
+		t.Errorf("trip's day 2 became %s", got)
+	}
+}
+
+func TestSetItineraryDay(t *testing.T) {
+	newDay := map[string]interface{}{"morning": "Visit Albert Hall"}
+	tests := []struct {
+		name      string
+		itinerary map[string]interface{}
+		nested    string
+		day       int
+		wantErr   error
+	}{
+		{"flat", map[string]interface{}{"day_1": "old", "day_2": "old"}, "", 2, nil},
+		{"nested itinerary", map[string]interface{}{"itinerary": map[string]interface{}{"Day 2": "old"}}, "itinerary", 2, nil},
+		{"daily itinerary", map[string]interface{}{"daily_itinerary": map[string]interface{}{"Day2": "old"}}, "daily_itinerary", 2, nil},
+		{"missing day", map[string]interface{}{"day_1": "old"}, "", 2, ErrDayNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := SetItineraryDay(tt.itinerary, tt.day, newDay)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SetItineraryDay error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			days := tt.itinerary
+			if tt.nested != "" {
+				days = tt.itinerary[tt.nested].(map[string]interface{})
+			}
+			replaced := 0
+			for _, plan := range days {
+				if fmt.Sprint(plan) == fmt.Sprint(newDay) {
+					replaced++
+				}
+			}
+			if replaced != 1 {
+				t.Errorf("itinerary = %v, want day %d replaced", tt.itinerary, tt.day)
+			}
+		})
+	}
+}
+
+func TestNearestFirst(t *testing.T) {
+	attractions := []Attraction{
+		{Name: "Far", Location: at(77.3)},
+		{Name: "Unmapped"},
+		{Name: "Near", Location: at(77.21)},
+		{Name: "Middle", Location: at(77.25)},
+	}
+	if got := attractionNames(nearestFirst(at(77.2), attractions)); got != "Near,Middle,Far,Unmapped" {
+		t.Errorf("nearestFirst = %s, want Near,Middle,Far,Unmapped", got)
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// defaultMaxAttachmentBytes is the largest file attached to an email when
+// EmailConfig doesn't set one. Base64 grows it by a third, which keeps it
+// under the 25MB most providers accept.
+const defaultMaxAttachmentBytes = 10 << 20
+
+// e164Phone matches an E.164 phone number, which is what Twilio accepts
+var e164Phone = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
+// phoneFormatting is the punctuation people write phone numbers with
+var phoneFormatting = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// normalizeRecipient checks a recipient is well formed for the delivery
+// method and returns it in the form the provider expects: the bare address
+// of an RFC 5322 email, or an E.164 phone number with its formatting removed.
+// Methods without a recipient accept anything.
+func normalizeRecipient(method DeliveryMethod, recipient string) (string, error) {
+	switch method {
+	case MethodEmail:
+		address, err := mail.ParseAddress(strings.TrimSpace(recipient))
+		if err != nil {
+			return "", kindErrorf(ErrValidation, "recipient %q is not a valid email address", recipient)
+		}
+		return address.Address, nil
+	case MethodSMS:
+		phone := phoneFormatting.Replace(strings.TrimSpace(recipient))
+		if !e164Phone.MatchString(phone) {
+			return "", kindErrorf(ErrValidation, "recipient %q is not an E.164 phone number, like +919876543210", recipient)
+		}
+		return phone, nil
+	default:
+		return recipient, nil
+	}
+}
+
+// maxAttachmentBytes is the largest file the email config allows attaching
+func (c *EmailConfig) maxAttachmentBytes() int {
+	if c.MaxAttachmentBytes > 0 {
+		return c.MaxAttachmentBytes
+	}
+	return defaultMaxAttachmentBytes
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeRecipient(t *testing.T) {
+	tests := []struct {
+		method    DeliveryMethod
+		recipient string
+		want      string
+		wantErr   bool
+	}{
+		{MethodEmail, " traveler@example.com ", "traveler@example.com", false},
+		{MethodEmail, "Asha Rao <asha@example.com>", "asha@example.com", false},
+		{MethodEmail, "traveler@", "", true},
+		{MethodEmail, "asha@example.com, ravi@example.com", "", true},
+		{MethodSMS, "+91 98765-43210", "+919876543210", false},
+		{MethodSMS, "+1 (415) 555.0100", "+14155550100", false},
+		{MethodSMS, "9876543210", "", true},
+		{MethodSMS, "+0123456", "", true},
+		{MethodDownload, "anything", "anything", false},
+	}
+	for _, tt := range tests {
+		got, err := normalizeRecipient(tt.method, tt.recipient)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("normalizeRecipient(%s, %q) = %q, %v; want %q, error %v", tt.method, tt.recipient, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrValidation) {
+			t.Errorf("normalizeRecipient(%s, %q) error %v isn't a validation error", tt.method, tt.recipient, err)
+		}
+	}
+}
+
+func TestDeliverByEmailAttachmentLimit(t *testing.T) {
+	stub := newSMTPStub(t, false, false)
+	host, port, _ := net.SplitHostPort(stub.addr)
+	smtpPort, _ := strconv.Atoi(port)
+	d := &ItineraryDeliveryService{emailConfig: &EmailConfig{
+		Enabled: true, TLSMode: SMTPNoTLS, SMTPHost: host, SMTPPort: smtpPort,
+		FromEmail: "noreply@auratravel.ai", MaxAttachmentBytes: 16,
+	}}
+
+	tests := []struct {
+		name         string
+		recipient    string
+		file         string
+		wantLinkOnly bool
+		wantErr      error
+	}{
+		{"attached", "Asha <asha@example.com>", "small itinerary", false, nil},
+		{"at the limit", "asha@example.com", strings.Repeat("x", 16), false, nil},
+		{"too large to attach", "asha@example.com", strings.Repeat("x", 17), true, nil},
+		{"invalid recipient", "asha@", "small itinerary", false, ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &DeliveryRequest{Method: MethodEmail, Recipient: tt.recipient}
+			linkOnly, err := d.deliverByEmail(context.Background(), req, []byte(tt.file), "https://example.com/trip.pdf", "trip.pdf", &ItineraryData{Destination: "Goa"})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("deliverByEmail error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if linkOnly != tt.wantLinkOnly {
+				t.Errorf("link only = %v, want %v", linkOnly, tt.wantLinkOnly)
+			}
+
+			stub.mu.Lock()
+			data := stub.sessions[len(stub.sessions)-1].data
+			stub.mu.Unlock()
+			want := tt.file
+			if tt.wantLinkOnly {
+				want = ""
+			}
+			if got := attachedFile(t, data); got != want {
+				t.Errorf("attachment = %q, want %q", got, want)
+			}
+			if !strings.Contains(data, "https://example.com/trip.pdf") {
+				t.Error("email doesn't link to the file")
+			}
+		})
+	}
+}
+
+// attachedFile returns the decoded attachment of an email, or "" when it
+// has none
+func attachedFile(t *testing.T, data string) string {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if mediaType != "multipart/mixed" {
+		return ""
+	}
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return ""
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		encoded, _ := io.ReadAll(part)
+		decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(decoded)
+	}
+}
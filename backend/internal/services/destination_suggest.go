@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
+)
+
+// Search history types
+const (
+	SearchTypeDestination   = "destination"
+	SearchTypeActivity      = "activity"
+	SearchTypeAccommodation = "accommodation"
+)
+
+const (
+	// searchPopularitySample is how many recent searches are aggregated into
+	// destination popularity
+	searchPopularitySample = 2000
+
+	// searchPopularityTTL controls how long the aggregated popularity is
+	// reused before it's read again
+	searchPopularityTTL = 10 * time.Minute
+
+	// userSearchSample is how many of the user's own searches are read to
+	// personalize suggestions
+	userSearchSample = 200
+
+	// minSuggestQueryLength is the shortest query matched against
+	// destinations; shorter queries get trending destinations instead
+	minSuggestQueryLength = 2
+)
+
+// Suggestion match strengths, strongest first
+const (
+	matchNamePrefix = 3
+	matchWordPrefix = 2
+	matchContains   = 1
+)
+
+// knownDestinations are suggested even before anyone has searched for them
+var knownDestinations = []string{
+	"Paris, France",
+	"Tokyo, Japan",
+	"London, United Kingdom",
+	"New York, USA",
+	"Rome, Italy",
+	"Barcelona, Spain",
+	"Amsterdam, Netherlands",
+	"Dubai, UAE",
+	"Singapore",
+	"Bangkok, Thailand",
+	"Bali, Indonesia",
+	"Sydney, Australia",
+	"Istanbul, Turkey",
+	"Prague, Czech Republic",
+	"Lisbon, Portugal",
+	"Kyoto, Japan",
+	"Seoul, South Korea",
+	"Cape Town, South Africa",
+	"Marrakech, Morocco",
+	"Cairo, Egypt",
+	"Reykjavik, Iceland",
+	"Santorini, Greece",
+	"Vienna, Austria",
+	"Hanoi, Vietnam",
+	"Delhi, India",
+	"Mumbai, India",
+	"Goa, India",
+	"Jaipur, India",
+	"Kerala, India",
+	"Agra, India",
+	"Udaipur, India",
+	"Varanasi, India",
+	"Rishikesh, India",
+	"Leh, India",
+}
+
+// DestinationSuggestion is one autocomplete result
+type DestinationSuggestion struct {
+	Destination string  `json:"destination"`
+	Searches    int     `json:"searches"`
+	Personal    bool    `json:"personal"`
+	Score       float64 `json:"score"`
+}
+
+// DestinationSuggestService records searches into SearchHistory and suggests
+// destinations from them
+type DestinationSuggestService struct {
+	firebase *FirebaseService
+	bigQuery *BigQueryService
+
+	popularityMu       sync.Mutex
+	popularity         map[string]int
+	popularityNames    map[string]string
+	popularityCachedAt time.Time
+}
+
+// NewDestinationSuggestService creates a new destination suggestion service.
+// bigQuery may be nil, in which case trending destinations come from
+// SearchHistory alone.
+func NewDestinationSuggestService(firebase *FirebaseService, bigQuery *BigQueryService) *DestinationSuggestService {
+	return &DestinationSuggestService{
+		firebase: firebase,
+		bigQuery: bigQuery,
+	}
+}
+
+// RecordSearch saves a search to SearchHistory
+func (s *DestinationSuggestService) RecordSearch(ctx context.Context, userID, searchType, query string, filters map[string]interface{}, results int) error {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+
+	entry := models.SearchHistory{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Query:     query,
+		Type:      searchType,
+		Results:   results,
+		CreatedAt: time.Now().UTC(),
+	}
+	if len(filters) > 0 {
+		raw, err := json.Marshal(filters)
+		if err != nil {
+			return fmt.Errorf("failed to encode search filters: %v", err)
+		}
+		entry.Filters = string(raw)
+	}
+
+	data, err := modelDoc(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := s.firebase.firestore.Collection("search_history").Doc(entry.ID).Set(ctx, data); err != nil {
+		return fmt.Errorf("failed to save search history: %v", err)
+	}
+	return nil
+}
+
+// SuggestDestinations returns up to limit destinations completing query,
+// ranked by match strength and how often they're searched, with the user's
+// own past searches boosted. Queries shorter than two characters return
+// trending destinations instead.
+func (s *DestinationSuggestService) SuggestDestinations(ctx context.Context, userID, query string, limit int) ([]DestinationSuggestion, error) {
+	popularity, names := s.searchPopularity(ctx)
+
+	var userSearches map[string]int
+	if userID != "" {
+		counts, userNames, err := s.userSearches(ctx, userID)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to load user search history", "user_id", userID, "error", err)
+		}
+		userSearches = counts
+		for key, name := range userNames {
+			if _, ok := names[key]; !ok {
+				names[key] = name
+			}
+		}
+	}
+
+	if len([]rune(strings.TrimSpace(query))) < minSuggestQueryLength {
+		return s.trendingDestinations(ctx, popularity, names, userSearches, limit), nil
+	}
+	return RankDestinationSuggestions(query, candidateNames(names), popularity, userSearches, limit), nil
+}
+
+// RankDestinationSuggestions ranks the candidates matching query. A
+// destination starting with the query beats one with a word starting with
+// it, which beats one merely containing it; within a match strength,
+// destinations searched more often rank higher, and the user's own searches
+// count extra. Counts are keyed by normalized destination.
+func RankDestinationSuggestions(query string, candidates []string, popularity, userSearches map[string]int, limit int) []DestinationSuggestion {
+	query = normalizeDestination(query)
+	var suggestions []DestinationSuggestion
+	for _, name := range candidates {
+		key := normalizeDestination(name)
+		match := destinationMatch(key, query)
+		if match == 0 {
+			continue
+		}
+		suggestions = append(suggestions, DestinationSuggestion{
+			Destination: name,
+			Searches:    popularity[key],
+			Personal:    userSearches[key] > 0,
+			Score:       float64(match)*10 + popularityScore(popularity[key], userSearches[key]),
+		})
+	}
+	sortSuggestions(suggestions)
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// popularityScore grows with the log of the search counts, so a handful of
+// the user's own searches can lift a destination past much more popular ones
+// of the same match strength
+func popularityScore(searches, userSearches int) float64 {
+	score := math.Log1p(float64(searches))
+	if userSearches > 0 {
+		score += 3 + 2*math.Log1p(float64(userSearches))
+	}
+	return score
+}
+
+// destinationMatch returns how strongly a normalized destination matches a
+// normalized query, or 0 when it doesn't
+func destinationMatch(destination, query string) int {
+	switch {
+	case strings.HasPrefix(destination, query):
+		return matchNamePrefix
+	case strings.Contains(" "+strings.NewReplacer(",", " ", "-", " ").Replace(destination), " "+query):
+		return matchWordPrefix
+	case strings.Contains(destination, query):
+		return matchContains
+	default:
+		return 0
+	}
+}
+
+// trendingDestinations returns the most searched destinations, preferring
+// BigQuery's trends when available and padding with known destinations
+func (s *DestinationSuggestService) trendingDestinations(ctx context.Context, popularity map[string]int, names map[string]string, userSearches map[string]int, limit int) []DestinationSuggestion {
+	seen := make(map[string]bool)
+	var suggestions []DestinationSuggestion
+	add := func(name string, score float64) {
+		key := normalizeDestination(name)
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		suggestions = append(suggestions, DestinationSuggestion{
+			Destination: name,
+			Searches:    popularity[key],
+			Personal:    userSearches[key] > 0,
+			Score:       score,
+		})
+	}
+
+	if s.bigQuery != nil {
+		trends, err := s.bigQuery.GetTrendingDestinations(ctx, limit)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to get trending destinations", "error", err)
+		}
+		for _, trend := range trends {
+			add(trend.Destination, trend.TrendScore+popularityScore(popularity[normalizeDestination(trend.Destination)], userSearches[normalizeDestination(trend.Destination)]))
+		}
+	}
+
+	var searched []DestinationSuggestion
+	for key, count := range popularity {
+		searched = append(searched, DestinationSuggestion{
+			Destination: names[key],
+			Score:       popularityScore(count, userSearches[key]),
+		})
+	}
+	sortSuggestions(searched)
+	for _, suggestion := range searched {
+		add(suggestion.Destination, suggestion.Score)
+	}
+	for _, name := range knownDestinations {
+		if limit > 0 && len(suggestions) >= limit {
+			break
+		}
+		add(name, 0)
+	}
+
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions
+}
+
+// searchPopularity returns how often each destination appears among recent
+// destination searches, keyed by normalized destination, and the display
+// name for each key. It's cached for searchPopularityTTL; when it can't be
+// read the counts are empty.
+func (s *DestinationSuggestService) searchPopularity(ctx context.Context) (map[string]int, map[string]string) {
+	s.popularityMu.Lock()
+	if s.popularity != nil && time.Since(s.popularityCachedAt) < searchPopularityTTL {
+		popularity, names := s.popularity, copyNames(s.popularityNames)
+		s.popularityMu.Unlock()
+		return popularity, names
+	}
+	s.popularityMu.Unlock()
+
+	// Ordering by created_at alone uses the automatic single-field index;
+	// the type is filtered here rather than in the query
+	iter := s.firebase.firestore.Collection("search_history").
+		OrderBy("created_at", firestore.Desc).
+		Limit(searchPopularitySample).
+		Documents(ctx)
+	popularity, names, err := countSearches(iter)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to aggregate search history", "error", err)
+		return map[string]int{}, knownDestinationNames()
+	}
+
+	s.popularityMu.Lock()
+	s.popularity = popularity
+	s.popularityNames = names
+	s.popularityCachedAt = time.Now()
+	s.popularityMu.Unlock()
+
+	return popularity, copyNames(names)
+}
+
+// userSearches counts the user's own destination searches
+func (s *DestinationSuggestService) userSearches(ctx context.Context, userID string) (map[string]int, map[string]string, error) {
+	iter := s.firebase.firestore.Collection("search_history").
+		Where("user_id", "==", userID).
+		Limit(userSearchSample).
+		Documents(ctx)
+	counts, names, err := countSearches(iter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return counts, names, nil
+}
+
+// countSearches counts destination searches by normalized query. Queries
+// naming a known destination are displayed as that destination, others by
+// their first spelling seen.
+func countSearches(iter *firestore.DocumentIterator) (map[string]int, map[string]string, error) {
+	defer iter.Stop()
+
+	counts := make(map[string]int)
+	names := knownDestinationNames()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		data := doc.Data()
+		if searchType, _ := data["type"].(string); searchType != SearchTypeDestination {
+			continue
+		}
+		query, _ := data["query"].(string)
+		key := normalizeDestination(query)
+		if key == "" {
+			continue
+		}
+		counts[key]++
+		if _, ok := names[key]; !ok {
+			names[key] = strings.TrimSpace(query)
+		}
+	}
+	return counts, names, nil
+}
+
+// normalizeDestination folds case and whitespace so searches for the same
+// destination are counted together
+func normalizeDestination(destination string) string {
+	return strings.Join(strings.Fields(strings.ToLower(destination)), " ")
+}
+
+// knownDestinationNames returns the display names of knownDestinations keyed
+// by normalized destination
+func knownDestinationNames() map[string]string {
+	names := make(map[string]string, len(knownDestinations))
+	for _, name := range knownDestinations {
+		names[normalizeDestination(name)] = name
+	}
+	return names
+}
+
+func copyNames(names map[string]string) map[string]string {
+	copied := make(map[string]string, len(names))
+	for key, name := range names {
+		copied[key] = name
+	}
+	return copied
+}
+
+// candidateNames returns the display names in a stable order
+func candidateNames(names map[string]string) []string {
+	candidates := make([]string, 0, len(names))
+	for _, name := range names {
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// sortSuggestions orders suggestions by score, then alphabetically
+func sortSuggestions(suggestions []DestinationSuggestion) {
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Destination < suggestions[j].Destination
+	})
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// suggestionList formats suggestions as "name" or "name*" for personal ones
+func suggestionList(suggestions []DestinationSuggestion) string {
+	names := make([]string, len(suggestions))
+	for i, suggestion := range suggestions {
+		names[i] = suggestion.Destination
+		if suggestion.Personal {
+			names[i] += "*"
+		}
+	}
+	return strings.Join(names, "; ")
+}
+
+func TestRankDestinationSuggestions(t *testing.T) {
+	candidates := []string{"Tokyo, Japan", "Ajanta Caves", "Kyoto, Japan", "Jaipur, India", "Goa, India"}
+	tests := []struct {
+		name         string
+		query        string
+		popularity   map[string]int
+		userSearches map[string]int
+		limit        int
+		want         string
+	}{
+		{
+			name: "match strength, then name", query: "ja",
+			want: "Jaipur, India; Kyoto, Japan; Tokyo, Japan; Ajanta Caves",
+		},
+		{
+			name: "popular first within a match strength", query: "  JA ",
+			popularity: map[string]int{"tokyo, japan": 50, "ajanta caves": 500},
+			want:       "Jaipur, India; Tokyo, Japan; Kyoto, Japan; Ajanta Caves",
+		},
+		{
+			name: "own searches beat popularity", query: "ja",
+			popularity:   map[string]int{"tokyo, japan": 50},
+			userSearches: map[string]int{"kyoto, japan": 1},
+			want:         "Jaipur, India; Kyoto, Japan*; Tokyo, Japan; Ajanta Caves",
+		},
+		{
+			name: "limited", query: "ja", limit: 2,
+			want: "Jaipur, India; Kyoto, Japan",
+		},
+		{name: "no match", query: "zz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggestions := RankDestinationSuggestions(tt.query, candidates, tt.popularity, tt.userSearches, tt.limit)
+			if got := suggestionList(suggestions); got != tt.want {
+				t.Errorf("suggestions = %s\nwant          %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestDestinations(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	recorder := NewDestinationSuggestService(fb, nil)
+	searches := []struct{ userID, searchType, query string }{
+		{"u2", SearchTypeDestination, "goa, india"},
+		{"u2", SearchTypeDestination, " GOA,  India "},
+		{"u3", SearchTypeDestination, "Goa, India"},
+		{"u1", SearchTypeDestination, "Gokarna"},
+		{"u1", SearchTypeActivity, "golf"},
+		{"u1", SearchTypeDestination, "  "},
+	}
+	for _, search := range searches {
+		if err := recorder.RecordSearch(context.Background(), search.userID, search.searchType, search.query, nil, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		userID string
+		query  string
+		limit  int
+		want   string
+	}{
+		{"u1", "go", 5, "Gokarna*; Goa, India"},
+		{"u2", "go", 5, "Goa, India*; Gokarna"},
+		{"", "go", 1, "Goa, India"},
+		// Short queries get trending destinations, padded with known ones
+		{"", "g", 3, "Goa, India; Gokarna; " + knownDestinations[0]},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %q", tt.userID, tt.query), func(t *testing.T) {
+			suggestions, err := NewDestinationSuggestService(fb, nil).SuggestDestinations(context.Background(), tt.userID, tt.query, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := suggestionList(suggestions); got != tt.want {
+				t.Errorf("suggestions = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,47 +2,131 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/logging"
+
 	"cloud.google.com/go/firestore"
 )
 
 // DynamicReplanningService handles real-time itinerary adjustments
 type DynamicReplanningService struct {
 	ragRetriever     *RAGRetriever
-	gemini           *GeminiService
+	gemini           AIGenerator
 	vectorDB         *VectorDatabase
 	firebase         *FirebaseService
 	notificationSvc  *NotificationService
+	localizationSvc  *LocalizationService
 	weatherKey       string
 	httpClient       *http.Client
 	monitoringActive bool
+
+	// monitors holds the cancel func of each running trip monitor so they can
+	// be stopped individually or all at once on shutdown
+	monitorsMu sync.Mutex
+	monitors   map[string]context.CancelFunc
+	monitorsWG sync.WaitGroup
+
+	// webhooksWG tracks webhook deliveries running in the background
+	webhooksWG sync.WaitGroup
+
+	// forecasts supplies the forecasts AdaptDayForWeather checks
+	forecasts ForecastProvider
+	// flights finds the trips a flight status update is for
+	flights FlightTripFinder
+
+	// similarityThreshold is the least similarity, from 0 to 1, an
+	// alternative needs to replace an unavailable item
+	similarityThreshold float64
+	// maxMonitors caps the trips monitored at once; 0 means no limit
+	maxMonitors int
+
+	// wakeups lets pushed alerts run a monitor's check right away, and
+	// pendingDelays holds the delay alerts it hasn't seen yet; both are
+	// guarded by monitorsMu
+	wakeups       map[string]chan struct{}
+	pendingDelays map[string][]DelayAlert
 }
 
+// ErrMonitorLimitReached is returned when a trip can't be monitored because
+// the most trips allowed already are
+var ErrMonitorLimitReached = newKindError(ErrUnavailable, "too many trips are being monitored; try again later")
+
+// DefaultSimilarityThreshold is the similarity threshold used when none is
+// configured
+const DefaultSimilarityThreshold = 0.6
+
+// similarAlternativeLimit caps the alternatives searched for an unavailable
+// item
+const similarAlternativeLimit = 5
+
 // NewDynamicReplanningService creates a new dynamic replanning service
 func NewDynamicReplanningService(
 	ragRetriever *RAGRetriever,
-	gemini *GeminiService,
+	gemini AIGenerator,
 	vectorDB *VectorDatabase,
 	firebase *FirebaseService,
 	notificationSvc *NotificationService,
+	localizationSvc *LocalizationService,
 	weatherKey string,
 ) *DynamicReplanningService {
-	return &DynamicReplanningService{
-		ragRetriever:     ragRetriever,
-		gemini:           gemini,
-		vectorDB:         vectorDB,
-		firebase:         firebase,
-		notificationSvc:  notificationSvc,
-		weatherKey:       weatherKey,
-		httpClient:       &http.Client{Timeout: 30 * time.Second},
-		monitoringActive: true,
+	d := &DynamicReplanningService{
+		ragRetriever:        ragRetriever,
+		gemini:              gemini,
+		vectorDB:            vectorDB,
+		firebase:            firebase,
+		notificationSvc:     notificationSvc,
+		localizationSvc:     localizationSvc,
+		weatherKey:          weatherKey,
+		httpClient:          httpclient.New(httpclient.Options{NoRetry: true, PublicOnly: true}), // webhook deliveries retry on their own
+		monitoringActive:    true,
+		monitors:            make(map[string]context.CancelFunc),
+		wakeups:             make(map[string]chan struct{}),
+		pendingDelays:       make(map[string][]DelayAlert),
+		similarityThreshold: DefaultSimilarityThreshold,
+	}
+	if ragRetriever != nil {
+		d.forecasts = ragRetriever
+	}
+	if firebase != nil {
+		d.flights = firebase
+	}
+	d.SetSimilarityThreshold(config.GetConfig().ReplanSimilarityThreshold)
+	d.SetMaxMonitors(config.GetConfig().MaxTripMonitors)
+	return d
+}
+
+// SetMaxMonitors caps the trips monitored at once; 0 means no limit.
+// Monitors already running over a lowered cap keep running.
+func (d *DynamicReplanningService) SetMaxMonitors(max int) {
+	if max < 0 {
+		slog.Warn("Ignoring invalid trip monitor limit", "max", max)
+		return
+	}
+	d.monitorsMu.Lock()
+	d.maxMonitors = max
+	d.monitorsMu.Unlock()
+}
+
+// SetSimilarityThreshold sets the least similarity, from 0 to 1, an
+// alternative needs to replace an unavailable item. Values outside that range
+// are ignored.
+func (d *DynamicReplanningService) SetSimilarityThreshold(threshold float64) {
+	if threshold < 0 || threshold > 1 || math.IsNaN(threshold) {
+		slog.Warn("Ignoring invalid replanning similarity threshold", "threshold", threshold)
+		return
 	}
+	d.similarityThreshold = threshold
 }
 
 // ReplanningTrigger represents the reason for replanning
@@ -64,11 +148,13 @@ type ReplanningResult struct {
 	Confidence       float64             `json:"confidence"`
 	EstimatedSavings float64             `json:"estimated_savings,omitempty"`
 	ReplanTimestamp  time.Time           `json:"replan_timestamp"`
+	// Diff compares OriginalPlan and RevisedPlan day by day, slot by slot
+	Diff *PlanDiff `json:"diff,omitempty"`
 }
 
 // ItineraryChange represents a specific change made to the itinerary
 type ItineraryChange struct {
-	Type        string      `json:"type"`      // replacement, cancellation, addition, time_shift
+	Type        string      `json:"type"`      // replacement, cancellation, addition, time_shift, needs_review
 	Day         string      `json:"day"`       // day1, day2, etc.
 	TimeSlot    string      `json:"time_slot"` // morning, afternoon, evening
 	Original    interface{} `json:"original,omitempty"`
@@ -76,6 +162,14 @@ type ItineraryChange struct {
 	Reason      string      `json:"reason"`
 	Impact      string      `json:"impact"` // minor, moderate, major
 	CostDelta   float64     `json:"cost_delta"`
+
+	// Similarity is how similar, from 0 to 1, the replacement or best match
+	// is to the original; SimilarityThreshold is the least a replacement
+	// needed. A needs_review change found nothing similar enough, so the
+	// slot is left alone and BestMatch is only offered to the traveler.
+	Similarity          float64     `json:"similarity,omitempty"`
+	SimilarityThreshold float64     `json:"similarity_threshold,omitempty"`
+	BestMatch           interface{} `json:"best_match,omitempty"`
 }
 
 // WeatherAlert represents a weather-based alert
@@ -107,32 +201,62 @@ type AvailabilityAlert struct {
 	Alternatives []string   `json:"alternatives,omitempty"`
 }
 
-// MonitorTrip starts monitoring a trip for real-time changes
+// MonitorTrip starts monitoring a trip for real-time changes. The monitor
+// outlives ctx (which is usually a request context) and runs until
+// StopMonitoring or Shutdown is called, or until a check finds the trip
+// deleted or over (see MonitoringEnded). A trip has at most one monitor, so
+// monitoring one already monitored does nothing. When the most trips allowed
+// are already monitored it returns ErrMonitorLimitReached.
 func (d *DynamicReplanningService) MonitorTrip(ctx context.Context, tripID string) error {
+	d.monitorsMu.Lock()
+	defer d.monitorsMu.Unlock()
+
 	if !d.monitoringActive {
-		return fmt.Errorf("monitoring is not active")
+		return newKindError(ErrUnavailable, "monitoring is not active")
+	}
+
+	if _, ok := d.monitors[tripID]; ok {
+		return nil
+	}
+	if d.maxMonitors > 0 && len(d.monitors) >= d.maxMonitors {
+		return ErrMonitorLimitReached
 	}
+	monitorCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	d.monitors[tripID] = cancel
+	wake := make(chan struct{}, 1)
+	d.wakeups[tripID] = wake
 
 	// Start background monitoring goroutine
-	go d.monitorTripBackground(ctx, tripID)
+	d.monitorsWG.Add(1)
+	go func() {
+		defer d.monitorsWG.Done()
+		d.monitorTripBackground(monitorCtx, tripID, wake)
+	}()
 
-	log.Printf("Started monitoring trip: %s", tripID)
+	logging.FromContext(ctx).Info("Started monitoring trip", "trip_id", tripID)
 	return nil
 }
 
-// monitorTripBackground runs continuous monitoring in the background
-func (d *DynamicReplanningService) monitorTripBackground(ctx context.Context, tripID string) {
+// monitorTripBackground runs continuous monitoring in the background,
+// checking every 15 minutes and whenever an alert is pushed through wake
+func (d *DynamicReplanningService) monitorTripBackground(ctx context.Context, tripID string, wake <-chan struct{}) {
 	ticker := time.NewTicker(15 * time.Minute) // Check every 15 minutes
 	defer ticker.Stop()
 
+	logger := logging.FromContext(ctx).With("trip_id", tripID)
+
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("Monitoring stopped for trip: %s", tripID)
+			logger.Info("Monitoring stopped")
 			return
 		case <-ticker.C:
 			if err := d.checkAndReplan(ctx, tripID); err != nil {
-				log.Printf("Error during monitoring check for trip %s: %v", tripID, err)
+				logger.Error("Error during monitoring check", "error", err)
+			}
+		case <-wake:
+			if err := d.checkAndReplan(ctx, tripID); err != nil {
+				logger.Error("Error during monitoring check", "error", err)
 			}
 		}
 	}
@@ -140,11 +264,23 @@ func (d *DynamicReplanningService) monitorTripBackground(ctx context.Context, tr
 
 // checkAndReplan checks for triggers and replans if necessary
 func (d *DynamicReplanningService) checkAndReplan(ctx context.Context, tripID string) error {
-	// Get current trip details
+	// Get current trip details, ending the monitor once there's nothing left
+	// to monitor
 	trip, err := d.getCurrentTrip(ctx, tripID)
+	var notFound *TripNotFoundError
+	if errors.As(err, &notFound) {
+		logging.FromContext(ctx).Info("Trip deleted, ending its monitor", "trip_id", tripID)
+		d.StopMonitoring(tripID)
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to get trip details: %w", err)
 	}
+	if tripData, ok := trip.(*TripData); ok && MonitoringEnded(tripData, time.Now()) {
+		logging.FromContext(ctx).Info("Trip is over, ending its monitor", "trip_id", tripID)
+		d.StopMonitoring(tripID)
+		return nil
+	}
 
 	// Check for various triggers
 	triggers := d.checkForTriggers(ctx, trip)
@@ -158,7 +294,9 @@ func (d *DynamicReplanningService) checkAndReplan(ctx context.Context, tripID st
 		return nil // No critical triggers requiring immediate replanning
 	}
 
-	log.Printf("Found %d critical triggers for trip %s, initiating replanning", len(criticalTriggers), tripID)
+	logger := logging.FromContext(ctx).With("trip_id", tripID)
+	logger.Info("Critical triggers found, initiating replanning", "triggers", len(criticalTriggers))
+	start := time.Now()
 
 	// Perform replanning
 	result, err := d.performReplanning(ctx, trip, criticalTriggers)
@@ -168,14 +306,23 @@ func (d *DynamicReplanningService) checkAndReplan(ctx context.Context, tripID st
 
 	// Save replanned itinerary
 	if err := d.saveReplanResult(ctx, result); err != nil {
-		log.Printf("Failed to save replan result: %v", err)
+		logger.Error("Failed to save replan result", "error", err)
 	}
+	logger.Info("Replanning finished", "changes", len(result.Changes), "latency", time.Since(start))
 
-	// Send notifications
+	var userID, destination string
+	if tripData, ok := trip.(*TripData); ok {
+		userID, destination = tripData.UserID, tripData.Destination
+	}
+
+	// Send notifications in each traveler's preferred language
 	if d.notificationSvc != nil {
-		d.sendReplanNotifications(ctx, result)
+		d.sendReplanNotifications(ctx, result, destination, "")
 	}
 
+	// Deliver webhooks registered for the trip or its owner
+	d.dispatchReplanWebhooks(ctx, userID, result)
+
 	return nil
 }
 
@@ -277,7 +424,7 @@ func (d *DynamicReplanningService) performReplanning(ctx context.Context, trip i
 	for _, trigger := range triggers {
 		changes, err := d.generateReplacements(ctx, tripData, trigger)
 		if err != nil {
-			log.Printf("Failed to generate replacements for trigger %s: %v", trigger.Type, err)
+			logging.FromContext(ctx).Warn("Failed to generate replacements", "trigger", trigger.Type, "error", err)
 			continue
 		}
 		result.Changes = append(result.Changes, changes...)
@@ -287,7 +434,7 @@ func (d *DynamicReplanningService) performReplanning(ctx context.Context, trip i
 	if d.gemini != nil {
 		revisedPlan, confidence, err := d.optimizeWithAI(ctx, tripData, result.Changes, triggers)
 		if err != nil {
-			log.Printf("AI optimization failed: %v", err)
+			logging.FromContext(ctx).Warn("AI optimization failed", "error", err)
 			result.Confidence = 0.7 // Default confidence
 		} else {
 			result.RevisedPlan = revisedPlan
@@ -295,6 +442,15 @@ func (d *DynamicReplanningService) performReplanning(ctx context.Context, trip i
 		}
 	}
 
+	if result.RevisedPlan != nil {
+		diff, err := ComputePlanDiff(result.OriginalPlan, result.RevisedPlan)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to diff replanned itinerary", "error", err)
+		} else {
+			result.Diff = diff
+		}
+	}
+
 	// Calculate cost impact
 	result.EstimatedSavings = d.calculateCostImpact(result.Changes)
 
@@ -419,38 +575,18 @@ func (d *DynamicReplanningService) generateAvailabilityReplacements(ctx context.
 	var changes []ItineraryChange
 
 	availAlert, ok := trigger.Data.(AvailabilityAlert)
-	if !ok {
+	if !ok || d.vectorDB == nil {
 		return changes
 	}
 
-	// Find similar alternatives using vector search
-	if d.vectorDB != nil {
-		alternatives := d.findSimilarAlternatives(ctx, availAlert.ItemID, availAlert.ItemType)
-		if len(alternatives) > 0 {
-			best := alternatives[0] // Take best match
-
-			// Find where the unavailable item was scheduled
-			for dayKey, dayData := range trip.Itinerary {
-				if dayMap, ok := dayData.(map[string]interface{}); ok {
-					if activitiesData, exists := dayMap["activities"]; exists {
-						if activities, ok := activitiesData.([]interface{}); ok {
-							for i, activityData := range activities {
-								if activity, ok := activityData.(map[string]interface{}); ok {
-									if d.matchesUnavailableItem(activity, availAlert) {
-										changes = append(changes, ItineraryChange{
-											Type:        "replacement",
-											Day:         dayKey,
-											TimeSlot:    fmt.Sprintf("activity_%d", i),
-											Original:    activity,
-											Replacement: best,
-											Reason:      fmt.Sprintf("Unavailable: %s", availAlert.Status),
-											Impact:      "moderate",
-											CostDelta:   d.calculateReplacementCostDelta(activity, best),
-										})
-									}
-								}
-							}
-						}
+	// Find where the unavailable item was scheduled
+	for dayKey, dayData := range trip.Itinerary {
+		if dayMap, ok := dayData.(map[string]interface{}); ok {
+			if activities, ok := dayMap["activities"].([]interface{}); ok {
+				for i, activityData := range activities {
+					if activity, ok := activityData.(map[string]interface{}); ok && d.matchesUnavailableItem(activity, availAlert) {
+						alternatives := d.findSimilarAlternatives(ctx, activity, availAlert)
+						changes = append(changes, d.availabilityChange(dayKey, fmt.Sprintf("activity_%d", i), activity, availAlert, alternatives))
 					}
 				}
 			}
@@ -462,23 +598,24 @@ func (d *DynamicReplanningService) generateAvailabilityReplacements(ctx context.
 
 // Helper methods and mock implementations
 
+// getCurrentTrip loads the monitored trip, returning *TripNotFoundError once
+// it has been deleted
 func (d *DynamicReplanningService) getCurrentTrip(ctx context.Context, tripID string) (interface{}, error) {
-	// Mock implementation - in production, fetch from database
-	return &TripData{
-		ID:          tripID,
-		Destination: "Delhi",
-		StartDate:   time.Now(),
-		EndDate:     time.Now().AddDate(0, 0, 3),
-		Itinerary: map[string]interface{}{
-			"day1": map[string]interface{}{
-				"activities": []interface{}{
-					map[string]interface{}{"name": "Red Fort", "type": "outdoor"},
-					map[string]interface{}{"name": "India Gate", "type": "outdoor"},
-					map[string]interface{}{"name": "Connaught Place", "type": "mixed"},
-				},
-			},
-		},
-	}, nil
+	if d.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+	return d.firebase.LoadTrip(ctx, tripID)
+}
+
+// MonitoringEnded reports whether a trip has nothing left to monitor at now:
+// it was deleted, or the last day of the trip has passed. Trips without an
+// end date are monitored until stopped.
+func MonitoringEnded(trip *TripData, now time.Time) bool {
+	if trip.Status == "deleted" {
+		return true
+	}
+	end := truncateToDay(timeFromValue(trip.EndDate))
+	return !end.IsZero() && !now.Before(end.AddDate(0, 0, 1))
 }
 
 func (d *DynamicReplanningService) fetchWeatherAlerts(ctx context.Context, trip interface{}) []WeatherAlert {
@@ -495,17 +632,18 @@ func (d *DynamicReplanningService) fetchWeatherAlerts(ctx context.Context, trip
 	}
 }
 
+// fetchDelayAlerts takes the delay alerts pushed for the trip since its
+// last check
 func (d *DynamicReplanningService) fetchDelayAlerts(ctx context.Context, trip interface{}) []DelayAlert {
-	// Mock delay alerts
-	return []DelayAlert{
-		{
-			ServiceType: "metro",
-			ServiceID:   "blue_line",
-			DelayTime:   45 * time.Minute,
-			Status:      "delayed",
-			Reason:      "Technical issues",
-		},
+	tripData, ok := trip.(*TripData)
+	if !ok {
+		return nil
 	}
+	d.monitorsMu.Lock()
+	defer d.monitorsMu.Unlock()
+	alerts := d.pendingDelays[tripData.ID]
+	delete(d.pendingDelays, tripData.ID)
+	return alerts
 }
 
 func (d *DynamicReplanningService) fetchAvailabilityAlerts(ctx context.Context, trip interface{}) []AvailabilityAlert {
@@ -617,6 +755,12 @@ func (d *DynamicReplanningService) buildOptimizationPrompt(trip *TripData, chang
 		prompt.WriteString(fmt.Sprintf("%d. %s: %s (Impact: %s)\n",
 			i+1, change.Type, change.Reason, change.Impact))
 	}
+	for _, change := range changes {
+		if change.Type == "needs_review" {
+			prompt.WriteString("\nLeave the needs_review items where they are; the traveler will choose their replacements.\n")
+			break
+		}
+	}
 
 	prompt.WriteString("\nTriggers requiring attention:\n")
 	for i, trigger := range triggers {
@@ -655,7 +799,7 @@ func (d *DynamicReplanningService) calculateCostImpact(changes []ItineraryChange
 
 func (d *DynamicReplanningService) saveReplanResult(ctx context.Context, result *ReplanningResult) error {
 	if d.firebase == nil {
-		return fmt.Errorf("firebase service not available")
+		return newKindError(ErrUnavailable, "firebase service not available")
 	}
 
 	// Save to Firebase collection
@@ -667,22 +811,62 @@ func (d *DynamicReplanningService) saveReplanResult(ctx context.Context, result
 	return err
 }
 
-func (d *DynamicReplanningService) sendReplanNotifications(ctx context.Context, result *ReplanningResult) {
+// sendReplanNotifications pushes the replanning result to everyone on the
+// trip. The message is localized to locale, or to each recipient's saved
+// preference when locale is empty.
+func (d *DynamicReplanningService) sendReplanNotifications(ctx context.Context, result *ReplanningResult, destination, locale string) {
 	if d.notificationSvc == nil {
 		return
 	}
 
-	// Send push notification about replanning
-	message := d.buildReplanNotificationMessage(result)
-	d.notificationSvc.SendTripUpdateNotification(ctx, result.TripID, message)
+	type localized struct{ title, body string }
+	messages := make(map[string]localized)
+	d.notificationSvc.SendLocalizedTripUpdateNotification(ctx, result.TripID, func(userID string) (string, string) {
+		userLocale := locale
+		if userLocale == "" && d.localizationSvc != nil {
+			userLocale, _ = d.localizationSvc.GetUserLocalePreference(ctx, userID)
+		}
+		message, ok := messages[userLocale]
+		if !ok {
+			message.title, message.body = d.buildReplanNotificationMessage(ctx, result, destination, userLocale)
+			messages[userLocale] = message
+		}
+		return message.title, message.body
+	})
 }
 
-func (d *DynamicReplanningService) buildReplanNotificationMessage(result *ReplanningResult) string {
+// buildReplanNotificationMessage returns the notification title and body in
+// locale, falling back to English when it can't be localized
+func (d *DynamicReplanningService) buildReplanNotificationMessage(ctx context.Context, result *ReplanningResult, destination, locale string) (string, string) {
+	if destination == "" {
+		destination = "trip"
+	}
+	key := "replan_updated"
 	if len(result.Changes) == 0 {
-		return "Your itinerary has been reviewed - no changes needed."
+		key = "replan_reviewed"
+	}
+
+	if d.localizationSvc != nil && locale != "" {
+		variables := map[string]string{
+			"destination": destination,
+			"count":       strconv.Itoa(len(result.Changes)),
+		}
+		title, titleErr := d.localizationSvc.LocalizeMessage(ctx, locale, "replan_title", nil)
+		body, bodyErr := d.localizationSvc.LocalizeMessage(ctx, locale, key, variables)
+		if bodyErr == nil && titleErr == nil {
+			return title, body
+		}
+		err := bodyErr
+		if err == nil {
+			err = titleErr
+		}
+		logging.FromContext(ctx).Warn("Failed to localize replanning notification", "locale", locale, "error", err)
 	}
 
-	return fmt.Sprintf("Your itinerary has been updated with %d changes due to real-time conditions. Tap to view details.", len(result.Changes))
+	if len(result.Changes) == 0 {
+		return "Trip Update", fmt.Sprintf("Your %s itinerary has been reviewed - no changes needed.", destination)
+	}
+	return "Trip Update", fmt.Sprintf("Your %s itinerary has been updated with %d changes due to real-time conditions. Tap to view details.", destination, len(result.Changes))
 }
 
 // Additional helper methods (simplified for brevity)
@@ -717,23 +901,112 @@ func (d *DynamicReplanningService) findTimeAlternative(ctx context.Context, acti
 	}
 }
 
-func (d *DynamicReplanningService) findSimilarAlternatives(ctx context.Context, itemID, itemType string) []interface{} {
-	// Mock alternatives using vector search
-	return []interface{}{
-		map[string]interface{}{
-			"name": "Similar Attraction",
-			"type": itemType,
-			"cost": 120,
+// availabilityChange replaces an unavailable activity with the best
+// alternative, as long as it's at least similarityThreshold similar. Anything
+// less similar is left for the traveler to review rather than substituted.
+func (d *DynamicReplanningService) availabilityChange(dayKey, timeSlot string, activity map[string]interface{}, alert AvailabilityAlert, alternatives []SimilarityResult) ItineraryChange {
+	reason := fmt.Sprintf("Unavailable: %s", alert.Status)
+	if len(alternatives) == 0 {
+		return ItineraryChange{
+			Type:                "needs_review",
+			Day:                 dayKey,
+			TimeSlot:            timeSlot,
+			Original:            activity,
+			Reason:              reason + "; no alternatives found",
+			Impact:              "major",
+			SimilarityThreshold: d.similarityThreshold,
+		}
+	}
+
+	best := alternatives[0]
+	bestItem := alternativeItem(best.Document)
+	if best.Similarity < d.similarityThreshold {
+		return ItineraryChange{
+			Type:     "needs_review",
+			Day:      dayKey,
+			TimeSlot: timeSlot,
+			Original: activity,
+			Reason: fmt.Sprintf("%s; best match %s was only %.0f%% similar (needs %.0f%%)",
+				reason, activityName(bestItem), best.Similarity*100, d.similarityThreshold*100),
+			Impact:              "major",
+			Similarity:          best.Similarity,
+			SimilarityThreshold: d.similarityThreshold,
+			BestMatch:           bestItem,
+		}
+	}
+
+	return ItineraryChange{
+		Type:                "replacement",
+		Day:                 dayKey,
+		TimeSlot:            timeSlot,
+		Original:            activity,
+		Replacement:         bestItem,
+		Reason:              reason,
+		Impact:              "moderate",
+		CostDelta:           d.calculateReplacementCostDelta(activity, bestItem),
+		Similarity:          best.Similarity,
+		SimilarityThreshold: d.similarityThreshold,
+	}
+}
+
+// findSimilarAlternatives searches the vector database for available items
+// like an unavailable activity, most similar first. The item itself is left
+// out.
+func (d *DynamicReplanningService) findSimilarAlternatives(ctx context.Context, activity map[string]interface{}, alert AvailabilityAlert) []SimilarityResult {
+	query := strings.TrimSpace(activityName(activity) + " " + getStringFromMetadata(activity, "description"))
+	if query == "" {
+		query = alert.ItemID
+	}
+	itemType := alert.ItemType
+	if itemType == "" {
+		itemType = "attraction"
+	}
+
+	results, err := d.vectorDB.SearchSimilarWithOptions(ctx, query, itemType, similarAlternativeLimit, SearchOptions{
+		Filter: &MetadataFilter{
+			AvailableOnly: true,
+			Predicate: func(doc EmbeddingDocument) bool {
+				return !d.matchesUnavailableItem(alternativeItem(doc), alert)
+			},
 		},
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to search for alternatives", "item_id", alert.ItemID, "error", err)
+		return nil
 	}
+	return results
+}
+
+// alternativeItem turns a vector database document into an itinerary item
+func alternativeItem(doc EmbeddingDocument) map[string]interface{} {
+	item := make(map[string]interface{}, len(doc.Metadata)+2)
+	for key, value := range doc.Metadata {
+		item[key] = value
+	}
+	item["id"] = doc.ID
+	if activityName(item) == "" {
+		item["name"] = doc.Content
+	}
+	return item
 }
 
 func (d *DynamicReplanningService) isActivityAffected(activity interface{}, alert DelayAlert) bool {
 	return false // Simplified check
 }
 
+// matchesUnavailableItem reports whether an activity is the item an alert
+// is about, by id or else by name
 func (d *DynamicReplanningService) matchesUnavailableItem(activity interface{}, alert AvailabilityAlert) bool {
-	return false // Simplified check
+	actMap, ok := activity.(map[string]interface{})
+	if !ok || alert.ItemID == "" {
+		return false
+	}
+	for _, key := range []string{"id", "place_id", "item_id"} {
+		if id := getStringFromMetadata(actMap, key); id != "" {
+			return id == alert.ItemID
+		}
+	}
+	return strings.EqualFold(strings.TrimSpace(activityName(actMap)), strings.TrimSpace(alert.ItemID))
 }
 
 func (d *DynamicReplanningService) calculateActivityCostDelta(original, replacement interface{}) float64 {
@@ -747,13 +1020,63 @@ func (d *DynamicReplanningService) calculateReplacementCostDelta(original, repla
 // TripData represents a trip for replanning purposes
 // StopMonitoring stops monitoring for a specific trip
 func (d *DynamicReplanningService) StopMonitoring(tripID string) {
-	log.Printf("Stopped monitoring trip: %s", tripID)
+	d.monitorsMu.Lock()
+	cancel, ok := d.monitors[tripID]
+	delete(d.monitors, tripID)
+	delete(d.wakeups, tripID)
+	delete(d.pendingDelays, tripID)
+	d.monitorsMu.Unlock()
+
+	if ok {
+		cancel()
+		slog.Info("Stopped monitoring trip", "trip_id", tripID)
+	}
+}
+
+// IsMonitoring reports whether a monitor is running for the trip
+func (d *DynamicReplanningService) IsMonitoring(tripID string) bool {
+	d.monitorsMu.Lock()
+	defer d.monitorsMu.Unlock()
+	_, ok := d.monitors[tripID]
+	return ok
+}
+
+// Shutdown stops accepting new monitors, cancels every running one and waits
+// for them and any webhook deliveries to finish, giving up when ctx expires
+func (d *DynamicReplanningService) Shutdown(ctx context.Context) error {
+	d.monitorsMu.Lock()
+	d.monitoringActive = false
+	count := len(d.monitors)
+	for tripID, cancel := range d.monitors {
+		cancel()
+		delete(d.monitors, tripID)
+	}
+	clear(d.wakeups)
+	clear(d.pendingDelays)
+	d.monitorsMu.Unlock()
+
+	slog.Info("Stopping trip monitors", "monitors", count)
+
+	done := make(chan struct{})
+	go func() {
+		d.monitorsWG.Wait()
+		d.webhooksWG.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		slog.Info("Dynamic replanning service shut down successfully")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("timed out waiting for trip monitors and webhook deliveries to stop: %w", ctx.Err())
+	}
 }
 
 // GetReplanHistory retrieves replanning history for a trip
 func (d *DynamicReplanningService) GetReplanHistory(ctx context.Context, tripID string) ([]*ReplanningResult, error) {
 	if d.firebase == nil {
-		return nil, fmt.Errorf("firebase service not available")
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
 	}
 
 	docs, err := d.firebase.GetFirestoreClient().
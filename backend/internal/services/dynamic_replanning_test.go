@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMonitoringEnded(t *testing.T) {
+	now := time.Date(2026, 3, 10, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		trip TripData
+		want bool
+	}{
+		{"ongoing", TripData{EndDate: now.AddDate(0, 0, 2)}, false},
+		{"last day", TripData{EndDate: time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)}, false},
+		{"over", TripData{EndDate: time.Date(2026, 3, 9, 23, 0, 0, 0, time.UTC)}, true},
+		{"no end date", TripData{}, false},
+		{"deleted", TripData{Status: "deleted", EndDate: now.AddDate(0, 0, 2)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MonitoringEnded(&tt.trip, now); got != tt.want {
+				t.Errorf("MonitoringEnded = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookURLRejectsInternalAddresses(t *testing.T) {
+	for _, rawURL := range []string{
+		"http://example.com/hook",
+		"https://127.0.0.1/hook",
+		"https://10.0.0.5/hook",
+		"https://169.254.169.254/latest/meta-data",
+		"https://[::1]:8443/hook",
+		"https://localhost/hook",
+	} {
+		if err := ValidateWebhookURL(context.Background(), rawURL, false); !errors.Is(err, ErrValidation) {
+			t.Errorf("ValidateWebhookURL(%s) = %v, want ErrValidation", rawURL, err)
+		}
+	}
+	if err := ValidateWebhookURL(context.Background(), "https://93.184.215.14/hook", false); err != nil {
+		t.Errorf("ValidateWebhookURL rejected a public address: %v", err)
+	}
+}
+
+func TestMonitorLifecycle(t *testing.T) {
+	tests := []struct {
+		name     string
+		run      func(d *DynamicReplanningService) error
+		want     map[string]bool
+		wantKind error
+	}{
+		{
+			name: "stop one trip",
+			run: func(d *DynamicReplanningService) error {
+				d.StopMonitoring("trip-a")
+				return nil
+			},
+			want: map[string]bool{"trip-a": false, "trip-b": true},
+		},
+		{
+			name: "stopping an unmonitored trip is a no-op",
+			run: func(d *DynamicReplanningService) error {
+				d.StopMonitoring("trip-c")
+				return nil
+			},
+			want: map[string]bool{"trip-a": true, "trip-b": true},
+		},
+		{
+			name: "shutdown stops every monitor and refuses new ones",
+			run: func(d *DynamicReplanningService) error {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				if err := d.Shutdown(ctx); err != nil {
+					return err
+				}
+				return d.MonitorTrip(context.Background(), "trip-c")
+			},
+			want:     map[string]bool{"trip-a": false, "trip-b": false, "trip-c": false},
+			wantKind: ErrUnavailable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDynamicReplanningService(nil, nil, nil, nil, nil, nil, "")
+			d.SetMaxMonitors(0)
+			// Monitors outlive the context that started them
+			ctx, cancel := context.WithCancel(context.Background())
+			for _, tripID := range []string{"trip-a", "trip-b"} {
+				if err := d.MonitorTrip(ctx, tripID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cancel()
+			t.Cleanup(func() { d.Shutdown(context.Background()) })
+
+			if err := tt.run(d); !errors.Is(err, tt.wantKind) {
+				t.Fatalf("err = %v, want %v", err, tt.wantKind)
+			}
+			for tripID, want := range tt.want {
+				if got := d.IsMonitoring(tripID); got != want {
+					t.Errorf("IsMonitoring(%s) = %v, want %v", tripID, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildReplanNotificationMessage(t *testing.T) {
+	localization := NewLocalizationService(nil, nil)
+	changed := &ReplanningResult{Changes: []ItineraryChange{{}, {}}}
+	unchanged := &ReplanningResult{}
+
+	tests := []struct {
+		name         string
+		localization *LocalizationService
+		result       *ReplanningResult
+		destination  string
+		locale       string
+		wantTitle    string
+		wantBody     string
+	}{
+		{"english", localization, changed, "Jaipur", "en", "Trip Update", "Your Jaipur itinerary has been updated with 2 changes due to real-time conditions. Tap to view details."},
+		{"hindi", localization, unchanged, "", "hi", "यात्रा अपडेट", "आपके trip यात्रा कार्यक्रम की समीक्षा की गई - किसी बदलाव की आवश्यकता नहीं है।"},
+		{"unsupported locale", localization, changed, "Goa", "xx", "Trip Update", "Your Goa itinerary has been updated with 2 changes due to real-time conditions. Tap to view details."},
+		{"no localization service", nil, unchanged, "Goa", "hi", "Trip Update", "Your Goa itinerary has been reviewed - no changes needed."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DynamicReplanningService{localizationSvc: tt.localization}
+			title, body := d.buildReplanNotificationMessage(context.Background(), tt.result, tt.destination, tt.locale)
+			if title != tt.wantTitle || body != tt.wantBody {
+				t.Errorf("message = %q / %q, want %q / %q", title, body, tt.wantTitle, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSetSimilarityThreshold(t *testing.T) {
+	tests := []struct {
+		threshold float64
+		want      float64
+	}{
+		{0.8, 0.8},
+		{0, 0},
+		{1, 1},
+		{1.5, DefaultSimilarityThreshold},
+		{-0.1, DefaultSimilarityThreshold},
+		{math.NaN(), DefaultSimilarityThreshold},
+	}
+	for _, tt := range tests {
+		d := &DynamicReplanningService{similarityThreshold: DefaultSimilarityThreshold}
+		d.SetSimilarityThreshold(tt.threshold)
+		if d.similarityThreshold != tt.want {
+			t.Errorf("SetSimilarityThreshold(%v) left %v, want %v", tt.threshold, d.similarityThreshold, tt.want)
+		}
+	}
+}
+
+func TestAvailabilityChange(t *testing.T) {
+	d := &DynamicReplanningService{similarityThreshold: 0.7}
+	activity := map[string]interface{}{"id": "fort", "name": "Amber Fort"}
+	alert := AvailabilityAlert{ItemID: "fort", Status: "sold_out"}
+	alternative := func(similarity float64) SimilarityResult {
+		return SimilarityResult{Similarity: similarity, Document: EmbeddingDocument{ID: "palace", Content: "City Palace museum"}}
+	}
+
+	tests := []struct {
+		name          string
+		alternatives  []SimilarityResult
+		wantType      string
+		wantReplaced  bool
+		wantBestMatch bool
+		wantReason    string
+	}{
+		{"similar enough", []SimilarityResult{alternative(0.9)}, "replacement", true, false, "Unavailable: sold_out"},
+		{"at the threshold", []SimilarityResult{alternative(0.7)}, "replacement", true, false, "Unavailable: sold_out"},
+		{"too different", []SimilarityResult{alternative(0.45)}, "needs_review", false, true, "Unavailable: sold_out; best match City Palace museum was only 45% similar (needs 70%)"},
+		{"nothing found", nil, "needs_review", false, false, "Unavailable: sold_out; no alternatives found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			change := d.availabilityChange("day_1", "activity_0", activity, alert, tt.alternatives)
+			if change.Type != tt.wantType || change.Reason != tt.wantReason {
+				t.Errorf("change = %s %q, want %s %q", change.Type, change.Reason, tt.wantType, tt.wantReason)
+			}
+			if (change.Replacement != nil) != tt.wantReplaced || (change.BestMatch != nil) != tt.wantBestMatch {
+				t.Errorf("replacement %v, best match %v; want replaced %v, best match %v", change.Replacement, change.BestMatch, tt.wantReplaced, tt.wantBestMatch)
+			}
+			if change.SimilarityThreshold != 0.7 {
+				t.Errorf("similarity threshold = %v, want 0.7", change.SimilarityThreshold)
+			}
+		})
+	}
+}
+
+func TestMatchesUnavailableItem(t *testing.T) {
+	d := &DynamicReplanningService{}
+	alert := AvailabilityAlert{ItemID: "fort"}
+	tests := []struct {
+		activity interface{}
+		want     bool
+	}{
+		{map[string]interface{}{"id": "fort"}, true},
+		{map[string]interface{}{"place_id": "fort", "name": "Amber Fort"}, true},
+		{map[string]interface{}{"id": "palace", "name": "fort"}, false},
+		{map[string]interface{}{"name": " Fort "}, true},
+		{map[string]interface{}{"name": "Amber Fort"}, false},
+		{"fort", false},
+	}
+	for _, tt := range tests {
+		if got := d.matchesUnavailableItem(tt.activity, alert); got != tt.want {
+			t.Errorf("matchesUnavailableItem(%v) = %v, want %v", tt.activity, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+
+	"cloud.google.com/go/firestore"
+)
+
+// contentHashKey is the metadata key holding the hash of the content a
+// document's embedding was generated from
+const contentHashKey = "content_hash"
+
+// embeddingModel is the model real embeddings come from
+const embeddingModel = "textembedding-gecko"
+
+// embeddingSource names what generates embeddings. It's part of the content
+// hash, so mock embeddings are replaced once the real model is configured.
+func (vdb *VectorDatabase) embeddingSource() string {
+	if vdb.embeddingService == nil || vdb.embeddingService.projectID == "" {
+		return "mock"
+	}
+	return embeddingModel
+}
+
+// contentHash hashes the content an embedding is generated from
+func (vdb *VectorDatabase) contentHash(content string) string {
+	sum := sha256.Sum256([]byte(vdb.embeddingSource() + "\n" + content))
+	return hex.EncodeToString(sum[:])
+}
+
+// tryGenerateEmbeddings generates embeddings for texts, returning an error
+// rather than mock embeddings when the embedding API fails
+func (vdb *VectorDatabase) tryGenerateEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	if vdb.predictEmbeddings == nil {
+		embeddings := make([][]float64, len(texts))
+		for i, text := range texts {
+			embeddings[i] = vdb.generateMockEmbedding(text)
+		}
+		return embeddings, nil
+	}
+	return vdb.predictEmbeddings(ctx, texts)
+}
+
+// storedEmbeddings reads the stored versions of docs, keyed by type and ID.
+// Documents that aren't stored yet, or can't be read, are left out.
+func (vdb *VectorDatabase) storedEmbeddings(ctx context.Context, docs []EmbeddingDocument) map[string]*EmbeddingDocument {
+	stored := make(map[string]*EmbeddingDocument)
+	if len(docs) == 0 {
+		return stored
+	}
+
+	refs := make([]*firestore.DocumentRef, len(docs))
+	for i, doc := range docs {
+		refs[i] = vdb.firestore.Collection(vdb.getCollectionName(doc.Type)).Doc(doc.ID)
+	}
+	snaps, err := vdb.firestore.GetAll(ctx, refs)
+	if err != nil {
+		log.Printf("Failed to read stored embeddings, regenerating: %v", err)
+		return stored
+	}
+	for i, snap := range snaps {
+		if !snap.Exists() {
+			continue
+		}
+		var existing EmbeddingDocument
+		if err := snap.DataTo(&existing); err != nil {
+			log.Printf("Failed to unmarshal stored embedding %s: %v", snap.Ref.ID, err)
+			continue
+		}
+		stored[storedEmbeddingKey(docs[i])] = &existing
+	}
+	return stored
+}
+
+func storedEmbeddingKey(doc EmbeddingDocument) string {
+	return doc.Type + "/" + doc.ID
+}
+
+// reuseEmbedding gives doc the embedding already stored for the same
+// content, reporting whether there was one. doc keeps the stored creation
+// time either way.
+func reuseEmbedding(doc, existing *EmbeddingDocument, hash string) bool {
+	if existing == nil {
+		return false
+	}
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = existing.CreatedAt
+	}
+	if len(existing.Embedding) == 0 || getStringFromMetadata(existing.Metadata, contentHashKey) != hash {
+		return false
+	}
+	doc.Embedding = existing.Embedding
+	doc.Metadata = withMetadata(doc.Metadata, contentHashKey, hash)
+	return true
+}
+
+// settleEmbedding gives doc the embedding generated for its content. When
+// generation failed, a stored embedding is kept rather than overwritten, along
+// with its old hash so the next store retries; with nothing stored, the
+// document gets a mock embedding and no hash.
+func (vdb *VectorDatabase) settleEmbedding(doc, existing *EmbeddingDocument, hash string, embedding []float64, err error) {
+	if err == nil && len(embedding) > 0 {
+		doc.Embedding = embedding
+		doc.Metadata = withMetadata(doc.Metadata, contentHashKey, hash)
+		return
+	}
+
+	if existing != nil && len(existing.Embedding) > 0 {
+		log.Printf("Failed to generate embedding for %s, keeping the stored one: %v", doc.ID, err)
+		doc.Embedding = existing.Embedding
+		doc.Metadata = withMetadata(doc.Metadata, contentHashKey, getStringFromMetadata(existing.Metadata, contentHashKey))
+		return
+	}
+	log.Printf("Failed to generate embedding for %s, using mock: %v", doc.ID, err)
+	doc.Embedding = vdb.generateMockEmbedding(doc.Content)
+	doc.Metadata = withMetadata(doc.Metadata, contentHashKey, "")
+}
+
+// withMetadata returns a copy of metadata with key set to value, or removed
+// when value is empty, leaving the caller's map alone
+func withMetadata(metadata map[string]interface{}, key, value string) map[string]interface{} {
+	updated := make(map[string]interface{}, len(metadata)+1)
+	for k, v := range metadata {
+		updated[k] = v
+	}
+	if value == "" {
+		delete(updated, key)
+	} else {
+		updated[key] = value
+	}
+	return updated
+}
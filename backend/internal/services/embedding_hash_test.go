@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestContentHash(t *testing.T) {
+	mock := &VectorDatabase{}
+	model := &VectorDatabase{embeddingService: &EmbeddingService{projectID: "project"}}
+
+	if mock.contentHash("Amber Fort") != mock.contentHash("Amber Fort") {
+		t.Error("the same content hashed differently")
+	}
+	if mock.contentHash("Amber Fort") == mock.contentHash("Amber Fort, Jaipur") {
+		t.Error("different content hashed the same")
+	}
+	if mock.contentHash("Amber Fort") == model.contentHash("Amber Fort") {
+		t.Error("mock and real embeddings share a hash, so mock ones would never be replaced")
+	}
+}
+
+func TestReuseEmbedding(t *testing.T) {
+	created := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	stored := func(hash string, embedding ...float64) *EmbeddingDocument {
+		return &EmbeddingDocument{Embedding: embedding, CreatedAt: created, Metadata: map[string]interface{}{contentHashKey: hash}}
+	}
+	tests := []struct {
+		name          string
+		existing      *EmbeddingDocument
+		want          bool
+		wantEmbedding string
+		wantCreated   bool
+	}{
+		{"nothing stored", nil, false, "[]", false},
+		{"same content", stored("h1", 1, 2), true, "[1 2]", true},
+		{"edited content", stored("old", 1, 2), false, "[]", true},
+		{"stored without an embedding", stored("h1"), false, "[]", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := EmbeddingDocument{ID: "fort", Content: "Amber Fort"}
+			if got := reuseEmbedding(&doc, tt.existing, "h1"); got != tt.want {
+				t.Errorf("reuseEmbedding = %v, want %v", got, tt.want)
+			}
+			if got := fmt.Sprint(doc.Embedding); got != tt.wantEmbedding {
+				t.Errorf("embedding = %s, want %s", got, tt.wantEmbedding)
+			}
+			if doc.CreatedAt.Equal(created) != tt.wantCreated {
+				t.Errorf("created at %v, want the stored time kept: %v", doc.CreatedAt, tt.wantCreated)
+			}
+		})
+	}
+}
+
+func TestSettleEmbedding(t *testing.T) {
+	vdb := &VectorDatabase{}
+	stored := &EmbeddingDocument{Embedding: []float64{7, 7}, Metadata: map[string]interface{}{contentHashKey: "old"}}
+	failure := errors.New("embedding API unavailable")
+
+	tests := []struct {
+		name      string
+		existing  *EmbeddingDocument
+		embedding []float64
+		err       error
+		wantFirst float64
+		wantHash  string
+	}{
+		{"generated", stored, []float64{3, 4}, nil, 3, "new"},
+		{"failed, stored kept", stored, nil, failure, 7, "old"},
+		{"failed, nothing stored", nil, nil, failure, vdb.generateMockEmbedding("Amber Fort")[0], ""},
+		{"empty result", nil, []float64{}, nil, vdb.generateMockEmbedding("Amber Fort")[0], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := EmbeddingDocument{ID: "fort", Content: "Amber Fort", Metadata: map[string]interface{}{contentHashKey: "stale"}}
+			vdb.settleEmbedding(&doc, tt.existing, "new", tt.embedding, tt.err)
+			if len(doc.Embedding) == 0 || doc.Embedding[0] != tt.wantFirst {
+				t.Errorf("embedding = %v, want one starting %v", doc.Embedding, tt.wantFirst)
+			}
+			if got := getStringFromMetadata(doc.Metadata, contentHashKey); got != tt.wantHash {
+				t.Errorf("content hash = %q, want %q", got, tt.wantHash)
+			}
+		})
+	}
+}
+
+func TestStoreEmbeddingKeepsGoodEmbeddings(t *testing.T) {
+	stub := &stubEmbeddings{}
+	vdb, fake := newTestVectorDatabase(t, stub)
+
+	// Each store of the same document either reuses, regenerates or, when
+	// the API fails, keeps what's stored
+	steps := []struct {
+		content       string
+		fail          string
+		wantCalls     int
+		wantEmbedding float64
+		wantHashOf    string
+	}{
+		{"Amber Fort", "", 1, 10, "Amber Fort"},
+		{"Amber Fort", "", 1, 10, "Amber Fort"},
+		{"Amber Fort palace", "palace", 2, 10, "Amber Fort"},
+		{"Amber Fort palace", "", 3, 17, "Amber Fort palace"},
+	}
+	for i, step := range steps {
+		stub.fail = step.fail
+		if err := vdb.StoreEmbedding(context.Background(), EmbeddingDocument{ID: "fort", Type: "attraction", Content: step.content}); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		fields := fake.fields("embeddings_attraction/fort")
+		embedding := fields["embedding"].GetArrayValue().GetValues()
+		hash := fields["metadata"].GetMapValue().GetFields()[contentHashKey].GetStringValue()
+		if len(stub.calls) != step.wantCalls || len(embedding) == 0 || embedding[0].GetDoubleValue() != step.wantEmbedding {
+			t.Errorf("step %d: %d embedding calls, stored %v; want %d calls, one starting %v", i, len(stub.calls), embedding, step.wantCalls, step.wantEmbedding)
+		}
+		if hash != vdb.contentHash(step.wantHashOf) {
+			t.Errorf("step %d: stored hash isn't the hash of %q", i, step.wantHashOf)
+		}
+	}
+}
@@ -9,9 +9,9 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"time"
 
 	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/httpclient"
 )
 
 // EmbeddingService handles text embedding generation
@@ -55,7 +55,7 @@ func NewEmbeddingService() (*EmbeddingService, error) {
 	return &EmbeddingService{
 		projectID:  cfg.GoogleCloudProjectID,
 		location:   "us-central1", // Default location for Vertex AI
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpclient.New(httpclient.Options{RetryAll: true}),
 		cfg:        cfg,
 	}, nil
 }
@@ -123,23 +123,102 @@ func (e *EmbeddingService) GenerateEmbedding(ctx context.Context, text string) (
 	return response.Predictions[0].Embeddings.Values, nil
 }
 
-// GenerateBatchEmbeddings generates embeddings for multiple texts
+// embeddingBatchSize is the maximum number of instances sent in a single
+// textembedding-gecko predict request
+const embeddingBatchSize = 5
+
+// GenerateBatchEmbeddings generates embeddings for multiple texts, sending up to
+// embeddingBatchSize texts per API request. The returned slice is index-aligned
+// with texts.
 func (e *EmbeddingService) GenerateBatchEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
-	var embeddings [][]float64
+	embeddings := make([][]float64, 0, len(texts))
+
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		chunk := texts[start:end]
 
-	// For simplicity, process one by one. In production, you'd want to batch these.
-	for _, text := range texts {
-		embedding, err := e.GenerateEmbedding(ctx, text)
+		values, err := e.predictEmbeddings(ctx, chunk)
 		if err != nil {
-			log.Printf("Failed to generate embedding for text, using mock: %v", err)
-			embedding = e.generateMockEmbedding(text)
+			log.Printf("Failed to generate batch embeddings, using mock: %v", err)
+			values = make([][]float64, len(chunk))
+			for i, text := range chunk {
+				values[i] = e.generateMockEmbedding(text)
+			}
 		}
-		embeddings = append(embeddings, embedding)
+		embeddings = append(embeddings, values...)
 	}
 
 	return embeddings, nil
 }
 
+// predictEmbeddings calls the embeddings API with several instances at once
+func (e *EmbeddingService) predictEmbeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	if e.projectID == "" {
+		values := make([][]float64, len(texts))
+		for i, text := range texts {
+			values[i] = e.generateMockEmbedding(text)
+		}
+		return values, nil
+	}
+
+	url := fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/textembedding-gecko:predict",
+		e.location, e.projectID, e.location)
+
+	request := EmbeddingRequest{Instances: make([]EmbeddingInstance, len(texts))}
+	for i, text := range texts {
+		request.Instances[i] = EmbeddingInstance{Content: text}
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if e.accessToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", e.accessToken))
+	}
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call embedding API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding API failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var response EmbeddingResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
+	}
+
+	if len(response.Predictions) != len(texts) {
+		return nil, fmt.Errorf("expected %d predictions, got %d", len(texts), len(response.Predictions))
+	}
+
+	values := make([][]float64, len(texts))
+	for i, prediction := range response.Predictions {
+		values[i] = prediction.Embeddings.Values
+	}
+
+	return values, nil
+}
+
 // SetAccessToken sets the access token for API authentication
 func (e *EmbeddingService) SetAccessToken(token string) {
 	e.accessToken = token
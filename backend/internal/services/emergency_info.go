@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"auratravel-backend/internal/logging"
+)
+
+// Where an emergency card's details came from
+const (
+	EmergencySourceDataset = "dataset"
+	EmergencySourceGemini  = "gemini"
+)
+
+// EmergencyInfo is the emergency card for one destination: who to call, the
+// traveler's embassy and phrases to ask for help in the local language
+type EmergencyInfo struct {
+	Destination string            `json:"destination"`
+	Country     string            `json:"country"`
+	CountryCode string            `json:"country_code,omitempty"`
+	Numbers     EmergencyNumbers  `json:"numbers"`
+	Embassy     *EmbassyInfo      `json:"embassy,omitempty"`
+	Language    string            `json:"language"`
+	Phrases     []EmergencyPhrase `json:"phrases"`
+	Source      string            `json:"source"`
+	Note        string            `json:"note,omitempty"`
+}
+
+// EmergencyNumbers are a country's emergency phone numbers. General is the
+// number that reaches every service, where there is one.
+type EmergencyNumbers struct {
+	General       string `json:"general,omitempty"`
+	Police        string `json:"police,omitempty"`
+	Ambulance     string `json:"ambulance,omitempty"`
+	Fire          string `json:"fire,omitempty"`
+	TouristPolice string `json:"tourist_police,omitempty"`
+}
+
+// EmbassyInfo points a traveler to their country's embassy at the
+// destination
+type EmbassyInfo struct {
+	Name         string `json:"name"`
+	City         string `json:"city"`
+	DirectoryURL string `json:"directory_url,omitempty"`
+}
+
+// EmergencyPhrase is a phrase in the local language, with its meaning in the
+// traveler's language and a romanization for non-Latin scripts
+type EmergencyPhrase struct {
+	Meaning   string `json:"meaning"`
+	Phrase    string `json:"phrase"`
+	Romanized string `json:"romanized,omitempty"`
+}
+
+// emergencyPhraseKeys are the phrases every card carries, in order
+var emergencyPhraseKeys = []string{"help", "police", "ambulance", "hospital"}
+
+// emergencyPhrases holds each phrase in each language as its text and, for
+// non-Latin scripts, a romanization
+var emergencyPhrases = map[string]map[string][2]string{
+	"en": {
+		"help":      {"I need help"},
+		"police":    {"Call the police"},
+		"ambulance": {"Call an ambulance"},
+		"hospital":  {"Where is the hospital?"},
+	},
+	"hi": {
+		"help":      {"मुझे मदद चाहिए", "Mujhe madad chahiye"},
+		"police":    {"पुलिस को बुलाइए", "Police ko bulaiye"},
+		"ambulance": {"एम्बुलेंस बुलाइए", "Ambulance bulaiye"},
+		"hospital":  {"अस्पताल कहाँ है?", "Aspatal kahan hai?"},
+	},
+	"bn": {
+		"help":      {"আমার সাহায্য দরকার", "Amar sahajjo dorkar"},
+		"police":    {"পুলিশ ডাকুন", "Police dakun"},
+		"ambulance": {"অ্যাম্বুলেন্স ডাকুন", "Ambulance dakun"},
+		"hospital":  {"হাসপাতাল কোথায়?", "Haspatal kothay?"},
+	},
+	"ta": {
+		"help":      {"எனக்கு உதவி தேவை", "Enakku udhavi thevai"},
+		"police":    {"காவல்துறையை அழையுங்கள்", "Kavalthuraiyai azhaiyungal"},
+		"ambulance": {"ஆம்புலன்ஸை அழையுங்கள்", "Ambulance-ai azhaiyungal"},
+		"hospital":  {"மருத்துவமனை எங்கே?", "Maruthuvamanai enge?"},
+	},
+	"mr": {
+		"help":      {"मला मदत हवी आहे", "Mala madat havi aahe"},
+		"police":    {"पोलिसांना बोलवा", "Polisanna bolva"},
+		"ambulance": {"रुग्णवाहिका बोलवा", "Rugnavahika bolva"},
+		"hospital":  {"रुग्णालय कुठे आहे?", "Rugnalay kuthe aahe?"},
+	},
+	"ne": {
+		"help":      {"मलाई मद्दत चाहियो", "Malai maddat chahiyo"},
+		"police":    {"प्रहरी बोलाउनुहोस्", "Prahari bolaunuhos"},
+		"ambulance": {"एम्बुलेन्स बोलाउनुहोस्", "Ambulance bolaunuhos"},
+		"hospital":  {"अस्पताल कहाँ छ?", "Aspatal kaha chha?"},
+	},
+	"fr": {
+		"help":      {"J'ai besoin d'aide"},
+		"police":    {"Appelez la police"},
+		"ambulance": {"Appelez une ambulance"},
+		"hospital":  {"Où est l'hôpital ?"},
+	},
+	"es": {
+		"help":      {"Necesito ayuda"},
+		"police":    {"Llame a la policía"},
+		"ambulance": {"Llame a una ambulancia"},
+		"hospital":  {"¿Dónde está el hospital?"},
+	},
+	"it": {
+		"help":      {"Ho bisogno di aiuto"},
+		"police":    {"Chiami la polizia"},
+		"ambulance": {"Chiami un'ambulanza"},
+		"hospital":  {"Dov'è l'ospedale?"},
+	},
+	"de": {
+		"help":      {"Ich brauche Hilfe"},
+		"police":    {"Rufen Sie die Polizei"},
+		"ambulance": {"Rufen Sie einen Krankenwagen"},
+		"hospital":  {"Wo ist das Krankenhaus?"},
+	},
+	"ja": {
+		"help":      {"助けてください", "Tasukete kudasai"},
+		"police":    {"警察を呼んでください", "Keisatsu o yonde kudasai"},
+		"ambulance": {"救急車を呼んでください", "Kyukyusha o yonde kudasai"},
+		"hospital":  {"病院はどこですか？", "Byoin wa doko desu ka?"},
+	},
+	"th": {
+		"help":      {"ช่วยด้วย", "Chuay duay"},
+		"police":    {"เรียกตำรวจ", "Riak tamruat"},
+		"ambulance": {"เรียกรถพยาบาล", "Riak rot payaban"},
+		"hospital":  {"โรงพยาบาลอยู่ที่ไหน", "Rong payaban yu tee nai?"},
+	},
+	"ar": {
+		"help":      {"أحتاج إلى مساعدة", "Ahtaju ila musa'ada"},
+		"police":    {"اتصل بالشرطة", "Ittasil bil-shurta"},
+		"ambulance": {"اتصل بالإسعاف", "Ittasil bil-is'af"},
+		"hospital":  {"أين المستشفى؟", "Ayna al-mustashfa?"},
+	},
+}
+
+// emergencyCountry is a destination country in the emergency dataset
+type emergencyCountry struct {
+	code     string
+	name     string
+	capital  string
+	language string
+	numbers  EmergencyNumbers
+	// places name the country in a destination, besides its name
+	places []string
+	// regionLanguages are places whose language isn't the country's
+	regionLanguages map[string]string
+}
+
+// emergencyCountries is the maintained emergency dataset. Numbers are the
+// public emergency numbers dialed from inside each country.
+var emergencyCountries = []emergencyCountry{
+	{
+		code: "IN", name: "India", capital: "New Delhi", language: "hi",
+		numbers: EmergencyNumbers{General: "112", Police: "100", Ambulance: "102", Fire: "101", TouristPolice: "1363"},
+		places:  []string{"Delhi", "Mumbai", "Bengaluru", "Bangalore", "Chennai", "Kolkata", "Hyderabad", "Pune", "Jaipur", "Agra", "Goa", "Kerala", "Varanasi", "Amritsar", "Udaipur", "Rishikesh"},
+		regionLanguages: map[string]string{
+			"chennai": "ta", "tamil nadu": "ta", "madurai": "ta",
+			"kolkata": "bn", "west bengal": "bn", "darjeeling": "bn",
+			"mumbai": "mr", "pune": "mr", "maharashtra": "mr",
+		},
+	},
+	{
+		code: "NP", name: "Nepal", capital: "Kathmandu", language: "ne",
+		numbers: EmergencyNumbers{Police: "100", Ambulance: "102", Fire: "101", TouristPolice: "1144"},
+		places:  []string{"Kathmandu", "Pokhara"},
+	},
+	{
+		code: "US", name: "United States", capital: "Washington, D.C.", language: "en",
+		numbers: EmergencyNumbers{General: "911"},
+		places:  []string{"USA", "United States of America", "New York", "Los Angeles", "San Francisco", "Chicago", "Las Vegas", "Miami"},
+	},
+	{
+		code: "GB", name: "United Kingdom", capital: "London", language: "en",
+		numbers: EmergencyNumbers{General: "999"},
+		places:  []string{"UK", "England", "Scotland", "Wales", "London", "Edinburgh", "Manchester"},
+	},
+	{
+		code: "FR", name: "France", capital: "Paris", language: "fr",
+		numbers: EmergencyNumbers{General: "112", Police: "17", Ambulance: "15", Fire: "18"},
+		places:  []string{"Paris", "Nice", "Lyon", "Marseille"},
+	},
+	{
+		code: "ES", name: "Spain", capital: "Madrid", language: "es",
+		numbers: EmergencyNumbers{General: "112", Police: "091"},
+		places:  []string{"Madrid", "Barcelona", "Seville", "Valencia"},
+	},
+	{
+		code: "IT", name: "Italy", capital: "Rome", language: "it",
+		numbers: EmergencyNumbers{General: "112", Police: "113", Ambulance: "118", Fire: "115"},
+		places:  []string{"Rome", "Milan", "Venice", "Florence", "Naples"},
+	},
+	{
+		code: "DE", name: "Germany", capital: "Berlin", language: "de",
+		numbers: EmergencyNumbers{General: "112", Police: "110"},
+		places:  []string{"Berlin", "Munich", "Frankfurt", "Hamburg"},
+	},
+	{
+		code: "JP", name: "Japan", capital: "Tokyo", language: "ja",
+		numbers: EmergencyNumbers{Police: "110", Ambulance: "119", Fire: "119"},
+		places:  []string{"Tokyo", "Kyoto", "Osaka"},
+	},
+	{
+		code: "TH", name: "Thailand", capital: "Bangkok", language: "th",
+		numbers: EmergencyNumbers{Police: "191", Ambulance: "1669", Fire: "199", TouristPolice: "1155"},
+		places:  []string{"Bangkok", "Phuket", "Chiang Mai", "Pattaya"},
+	},
+	{
+		code: "AE", name: "United Arab Emirates", capital: "Abu Dhabi", language: "ar",
+		numbers: EmergencyNumbers{Police: "999", Ambulance: "998", Fire: "997"},
+		places:  []string{"UAE", "Dubai", "Abu Dhabi", "Sharjah"},
+	},
+	{
+		code: "SG", name: "Singapore", capital: "Singapore", language: "en",
+		numbers: EmergencyNumbers{Police: "999", Ambulance: "995", Fire: "995"},
+	},
+	{
+		code: "AU", name: "Australia", capital: "Canberra", language: "en",
+		numbers: EmergencyNumbers{General: "000"},
+		places:  []string{"Sydney", "Melbourne", "Brisbane", "Perth"},
+	},
+}
+
+// nationality is a traveler's country, for finding their embassy
+type nationality struct {
+	code    string
+	name    string
+	demonym string
+	// directoryURL is the government's list of its embassies abroad
+	directoryURL string
+}
+
+var nationalities = []nationality{
+	{code: "IN", name: "India", demonym: "Indian", directoryURL: "https://www.mea.gov.in/indian-missions-abroad-new.htm"},
+	{code: "US", name: "United States", demonym: "American", directoryURL: "https://www.usembassy.gov/"},
+	{code: "GB", name: "United Kingdom", demonym: "British", directoryURL: "https://www.gov.uk/world/embassies"},
+	{code: "AU", name: "Australia", demonym: "Australian", directoryURL: "https://www.dfat.gov.au/about-us/our-locations/missions"},
+	{code: "CA", name: "Canada", demonym: "Canadian", directoryURL: "https://travel.gc.ca/assistance/embassies-consulates"},
+	{code: "NP", name: "Nepal", demonym: "Nepali"},
+	{code: "FR", name: "France", demonym: "French"},
+	{code: "ES", name: "Spain", demonym: "Spanish"},
+	{code: "IT", name: "Italy", demonym: "Italian"},
+	{code: "DE", name: "Germany", demonym: "German"},
+	{code: "JP", name: "Japan", demonym: "Japanese"},
+	{code: "TH", name: "Thailand", demonym: "Thai"},
+	{code: "AE", name: "United Arab Emirates", demonym: "Emirati"},
+	{code: "SG", name: "Singapore", demonym: "Singaporean"},
+}
+
+// GetEmergencyInfo returns the emergency card for a destination: its
+// emergency numbers, the embassy of the traveler's nationality there, and
+// phrases in the local language with their meaning in locale. Destinations
+// outside the dataset are asked of Gemini when an API key is configured;
+// otherwise they're ErrNotFound.
+func (g *GeminiService) GetEmergencyInfo(ctx context.Context, destination, userNationality, locale string) (*EmergencyInfo, error) {
+	if strings.TrimSpace(destination) == "" {
+		return nil, newKindError(ErrValidation, "destination is required")
+	}
+	if _, ok := emergencyPhrases[locale]; !ok {
+		locale = "en"
+	}
+	traveler, hasNationality := findNationality(userNationality)
+
+	if country, ok := findEmergencyCountry(destination); ok {
+		info := &EmergencyInfo{
+			Destination: destination,
+			Country:     country.name,
+			CountryCode: country.code,
+			Numbers:     country.numbers,
+			Language:    emergencyLanguage(country, destination),
+			Source:      EmergencySourceDataset,
+		}
+		info.Phrases = emergencyPhrasesIn(info.Language, locale)
+		if hasNationality && traveler.code != country.code {
+			info.Embassy = &EmbassyInfo{
+				Name:         embassyName(traveler),
+				City:         country.capital,
+				DirectoryURL: traveler.directoryURL,
+			}
+		}
+		return info, nil
+	}
+
+	if g.apiKey == "" {
+		return nil, kindErrorf(ErrNotFound, "no emergency information for %s", destination)
+	}
+	return g.generateEmergencyInfo(ctx, destination, userNationality, locale, traveler, hasNationality)
+}
+
+// generateEmergencyInfo asks Gemini for a destination's emergency card
+func (g *GeminiService) generateEmergencyInfo(ctx context.Context, destination, userNationality, locale string, traveler nationality, hasNationality bool) (*EmergencyInfo, error) {
+	meanings := make([]string, len(emergencyPhraseKeys))
+	for i, key := range emergencyPhraseKeys {
+		meanings[i] = fmt.Sprintf("%q", emergencyPhrases[locale][key][0])
+	}
+	prompt := fmt.Sprintf(`Give the emergency information a traveler needs in %s.
+The traveler's nationality is %s.
+
+Respond with only a JSON object:
+{"country": "...", "country_code": "ISO 3166 alpha-2", "language": "ISO 639-1 code of the local language",
+ "numbers": {"general": "...", "police": "...", "ambulance": "...", "fire": "...", "tourist_police": "..."},
+ "embassy": {"name": "...", "city": "..."},
+ "phrases": [{"meaning": "...", "phrase": "...", "romanized": "..."}]}
+
+Leave out numbers that don't exist. Give the embassy of the traveler's country at the destination, or leave
+it out if unknown. The phrases are %s, in that order, translated into the local language, with "meaning"
+as given and "romanized" only for non-Latin scripts.`,
+		userInput("destination", destination), userInput("nationality", userNationality), strings.Join(meanings, ", ")) + userInputNotice
+
+	response, err := g.callGeminiAPI(ctx, prompt, GeminiCallOptions{UseCase: GeminiStructured})
+	if err != nil {
+		return nil, kindErrorf(ErrUnavailable, "failed to generate emergency information: %w", err)
+	}
+	var info EmergencyInfo
+	if err := json.Unmarshal([]byte(extractJSON(response)), &info); err != nil || info.Country == "" {
+		logging.FromContext(ctx).Warn("Failed to parse emergency information response", "destination", destination, "error", err)
+		return nil, kindErrorf(ErrNotFound, "no emergency information for %s", destination)
+	}
+
+	info.Destination = destination
+	info.Source = EmergencySourceGemini
+	info.Note = "Generated by AI; confirm these numbers locally"
+	if info.Embassy != nil && hasNationality && info.Embassy.DirectoryURL == "" {
+		info.Embassy.DirectoryURL = traveler.directoryURL
+	}
+	return &info, nil
+}
+
+// findEmergencyCountry finds the dataset country a destination is in, by
+// name or by one of its places
+func findEmergencyCountry(destination string) (emergencyCountry, bool) {
+	return findCountryIn(emergencyCountries, destination)
+}
+
+// findCountryIn finds the country of countries a destination is in
+func findCountryIn(countries []emergencyCountry, destination string) (emergencyCountry, bool) {
+	for _, country := range countries {
+		if containsWord(destination, country.name) {
+			return country, true
+		}
+		for _, place := range country.places {
+			if containsWord(destination, place) {
+				return country, true
+			}
+		}
+	}
+	return emergencyCountry{}, false
+}
+
+// findNationality reads a nationality given as a country code, name or
+// demonym, like "IN", "India" or "Indian"
+func findNationality(value string) (nationality, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nationality{}, false
+	}
+	for _, n := range nationalities {
+		if strings.EqualFold(value, n.code) || strings.EqualFold(value, n.name) || strings.EqualFold(value, n.demonym) {
+			return n, true
+		}
+	}
+	return nationality{}, false
+}
+
+// embassyName names a country's embassy, like "Embassy of the United
+// States"
+func embassyName(n nationality) string {
+	if strings.HasPrefix(n.name, "United ") {
+		return "Embassy of the " + n.name
+	}
+	return "Embassy of " + n.name
+}
+
+// emergencyLanguage is the language spoken at the destination, which for
+// some regions isn't the country's main one
+func emergencyLanguage(country emergencyCountry, destination string) string {
+	for place, language := range country.regionLanguages {
+		if containsWord(destination, place) {
+			return language
+		}
+	}
+	return country.language
+}
+
+// emergencyPhrasesIn returns the card's phrases in language, explained in
+// locale
+func emergencyPhrasesIn(language, locale string) []EmergencyPhrase {
+	local, ok := emergencyPhrases[language]
+	if !ok {
+		local = emergencyPhrases["en"]
+	}
+	phrases := make([]EmergencyPhrase, len(emergencyPhraseKeys))
+	for i, key := range emergencyPhraseKeys {
+		phrases[i] = EmergencyPhrase{
+			Meaning:   emergencyPhrases[locale][key][0],
+			Phrase:    local[key][0],
+			Romanized: local[key][1],
+		}
+	}
+	return phrases
+}
+
+// containsWord reports whether text contains word as a whole word, ignoring
+// case
+func containsWord(text, word string) bool {
+	pattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(word) + `\b`)
+	return pattern.MatchString(text)
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetEmergencyInfoFromDataset(t *testing.T) {
+	tests := []struct {
+		name         string
+		destination  string
+		nationality  string
+		locale       string
+		wantCountry  string
+		wantLanguage string
+		wantGeneral  string
+		wantEmbassy  string
+		wantPhrase   EmergencyPhrase
+	}{
+		{
+			name: "regional language", destination: "Chennai, Tamil Nadu", nationality: "Indian", locale: "en",
+			wantCountry: "IN", wantLanguage: "ta", wantGeneral: "112",
+			wantPhrase: EmergencyPhrase{Meaning: "I need help", Phrase: "எனக்கு உதவி தேவை", Romanized: "Enakku udhavi thevai"},
+		},
+		{
+			name: "foreign traveler", destination: "Kyoto", nationality: "us", locale: "hi",
+			wantCountry: "JP", wantLanguage: "ja", wantEmbassy: "Embassy of the United States, Tokyo",
+			wantPhrase: EmergencyPhrase{Meaning: "मुझे मदद चाहिए", Phrase: "助けてください", Romanized: "Tasukete kudasai"},
+		},
+		{
+			name: "unsupported locale", destination: "Barcelona, Spain", nationality: "Martian", locale: "xx",
+			wantCountry: "ES", wantLanguage: "es", wantGeneral: "112",
+			wantPhrase: EmergencyPhrase{Meaning: "I need help", Phrase: "Necesito ayuda"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := (&GeminiService{}).GetEmergencyInfo(context.Background(), tt.destination, tt.nationality, tt.locale)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.CountryCode != tt.wantCountry || info.Language != tt.wantLanguage || info.Numbers.General != tt.wantGeneral || info.Source != EmergencySourceDataset {
+				t.Errorf("card = %s %s general %q from %s; want %s %s general %q from the dataset", info.CountryCode, info.Language, info.Numbers.General, info.Source, tt.wantCountry, tt.wantLanguage, tt.wantGeneral)
+			}
+			embassy := ""
+			if info.Embassy != nil {
+				embassy = info.Embassy.Name + ", " + info.Embassy.City
+			}
+			if embassy != tt.wantEmbassy {
+				t.Errorf("embassy = %q, want %q", embassy, tt.wantEmbassy)
+			}
+			if len(info.Phrases) != len(emergencyPhraseKeys) || info.Phrases[0] != tt.wantPhrase {
+				t.Errorf("phrases = %+v, want %d starting %+v", info.Phrases, len(emergencyPhraseKeys), tt.wantPhrase)
+			}
+		})
+	}
+}
+
+func TestGetEmergencyInfoFromGemini(t *testing.T) {
+	card := `{"country":"Iceland","country_code":"IS","language":"is","numbers":{"general":"112"},"embassy":{"name":"Embassy of India","city":"Reykjavik"},"phrases":[{"meaning":"I need help","phrase":"Ég þarf hjálp"}]}`
+	tests := []struct {
+		name        string
+		destination string
+		apiKey      string
+		reply       string
+		wantErr     error
+	}{
+		{name: "generated", destination: "Reykjavik", apiKey: "key", reply: "```json\n" + card + "\n```"},
+		{name: "unusable reply", destination: "Reykjavik", apiKey: "key", reply: "I can't help with that", wantErr: ErrNotFound},
+		{name: "no API key", destination: "Reykjavik", wantErr: ErrNotFound},
+		{name: "no destination", destination: " ", apiKey: "key", wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				text, _ := json.Marshal(tt.reply)
+				fmt.Fprintf(w, `{"candidates":[{"content":{"parts":[{"text":%s}]}}]}`, text)
+			}))
+			defer server.Close()
+
+			g := &GeminiService{apiKey: tt.apiKey, httpClient: server.Client(), baseURL: server.URL}
+			info, err := g.GetEmergencyInfo(context.Background(), tt.destination, "Indian", "en")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetEmergencyInfo error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if info.Country != "Iceland" || info.Source != EmergencySourceGemini || info.Note == "" {
+				t.Errorf("card = %+v, want Iceland from Gemini with a note", info)
+			}
+			if info.Embassy == nil || info.Embassy.DirectoryURL != "https://www.mea.gov.in/indian-missions-abroad-new.htm" {
+				t.Errorf("embassy = %+v, want the Indian missions directory filled in", info.Embassy)
+			}
+		})
+	}
+}
+
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		text string
+		word string
+		want bool
+	}{
+		{"Goa, India", "goa", true},
+		{"Goalpara, Assam", "Goa", false},
+		{"Abu Dhabi, UAE", "Abu Dhabi", true},
+		{"Romeo's Cafe", "Rome", false},
+	}
+	for _, tt := range tests {
+		if got := containsWord(tt.text, tt.word); got != tt.want {
+			t.Errorf("containsWord(%q, %q) = %v, want %v", tt.text, tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Service errors match one of these with errors.Is, so callers
+// can tell a bad request from a missing resource or an outage without
+// matching messages.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrUnsupportedLocale = errors.New("unsupported locale")
+	ErrUnavailable       = errors.New("service unavailable")
+	ErrPermission        = errors.New("permission denied")
+	ErrValidation        = errors.New("invalid request")
+	ErrConflict          = errors.New("conflict")
+)
+
+// kindError is an error of one of the error kinds. errors.Is matches both
+// the error itself and its kind.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+// newKindError creates a sentinel error of kind
+func newKindError(kind error, message string) error {
+	return &kindError{kind: kind, err: errors.New(message)}
+}
+
+// kindErrorf formats an error of kind. %w wraps as with fmt.Errorf, so the
+// wrapped error still matches too.
+func kindErrorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
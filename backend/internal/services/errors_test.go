@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	kinds := []error{ErrNotFound, ErrUnsupportedLocale, ErrUnavailable, ErrPermission, ErrValidation}
+	tests := []struct {
+		name     string
+		err      error
+		wantKind error
+	}{
+		{"sentinel", newKindError(ErrUnavailable, "monitoring is not active"), ErrUnavailable},
+		{"formatted", kindErrorf(ErrValidation, "unsupported format %q", "ics"), ErrValidation},
+		{"wrapped by a caller", fmt.Errorf("adapting day 2: %w", ErrDayNotFound), ErrNotFound},
+		{"permission error type", &TripPermissionError{TripID: "t1", UserID: "u1"}, ErrPermission},
+		{"unknown currency type", &UnknownCurrencyError{Currency: "XYZ"}, ErrValidation},
+		{"plain error", errors.New("boom"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, kind := range kinds {
+				if got := errors.Is(tt.err, kind); got != (kind == tt.wantKind) {
+					t.Errorf("errors.Is(%v, %v) = %v", tt.err, kind, got)
+				}
+			}
+		})
+	}
+
+	// A formatted error still matches what it wraps
+	cause := errors.New("firestore down")
+	if err := kindErrorf(ErrUnavailable, "saving trip: %w", cause); !errors.Is(err, cause) || err.Error() != "saving trip: firestore down" {
+		t.Errorf("kindErrorf = %v, want it to wrap %v", err, cause)
+	}
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	jpegSOI  = 0xD8 // start of image
+	jpegSOS  = 0xDA // start of scan; compressed data follows
+	jpegAPP1 = 0xE1 // EXIF and XMP segments
+
+	exifGPSIFDTag      = 0x8825
+	exifGPSLatRefTag   = 0x0001
+	exifGPSLatTag      = 0x0002
+	exifGPSLngRefTag   = 0x0003
+	exifGPSLngTag      = 0x0004
+	exifTypeASCII      = 2
+	exifTypeLong       = 4
+	exifTypeRational   = 5
+	exifIFDEntrySize   = 12
+	exifMaxIFDEntries  = 512
+	exifHeader         = "Exif\x00\x00"
+	exifRationalLength = 8
+)
+
+// ExtractGPSCoordinates reads the GPS position from a JPEG's EXIF block.
+// It reports false when the image isn't a JPEG, has no EXIF block, or the
+// EXIF block carries no usable GPS tags.
+func ExtractGPSCoordinates(imageData []byte) (float64, float64, bool) {
+	tiff := findEXIFPayload(imageData)
+	if tiff == nil {
+		return 0, 0, false
+	}
+	return parseEXIFGPS(tiff)
+}
+
+// StripEXIF returns a copy of a JPEG without its APP1 (EXIF/XMP) segments, so
+// camera, owner and location metadata isn't kept with stored images. Non-JPEG
+// data is returned unchanged.
+func StripEXIF(imageData []byte) []byte {
+	if len(imageData) < 4 || imageData[0] != 0xFF || imageData[1] != jpegSOI {
+		return imageData
+	}
+
+	var out bytes.Buffer
+	out.Write(imageData[:2])
+
+	pos := 2
+	for pos+4 <= len(imageData) {
+		if imageData[pos] != 0xFF {
+			break
+		}
+		marker := imageData[pos+1]
+		if marker == jpegSOS {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(imageData[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(imageData) {
+			break
+		}
+		if marker != jpegAPP1 {
+			out.Write(imageData[pos:end])
+		}
+		pos = end
+	}
+
+	// Copy the scan data and anything we couldn't walk as-is
+	out.Write(imageData[pos:])
+	return out.Bytes()
+}
+
+// findEXIFPayload returns the TIFF structure inside a JPEG's EXIF segment
+func findEXIFPayload(imageData []byte) []byte {
+	if len(imageData) < 4 || imageData[0] != 0xFF || imageData[1] != jpegSOI {
+		return nil
+	}
+
+	pos := 2
+	for pos+4 <= len(imageData) {
+		if imageData[pos] != 0xFF {
+			return nil
+		}
+		marker := imageData[pos+1]
+		if marker == jpegSOS {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(imageData[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(imageData) {
+			return nil
+		}
+		segment := imageData[pos+4 : end]
+		if marker == jpegAPP1 && bytes.HasPrefix(segment, []byte(exifHeader)) {
+			return segment[len(exifHeader):]
+		}
+		pos = end
+	}
+
+	return nil
+}
+
+// parseEXIFGPS walks IFD0 to the GPS IFD and decodes latitude and longitude
+func parseEXIFGPS(tiff []byte) (float64, float64, bool) {
+	if len(tiff) < 8 {
+		return 0, 0, false
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, 0, false
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return 0, 0, false
+	}
+
+	ifd0 := int(order.Uint32(tiff[4:8]))
+	gpsEntry, ok := findIFDEntry(tiff, order, ifd0, exifGPSIFDTag)
+	if !ok || order.Uint16(gpsEntry[2:4]) != exifTypeLong {
+		return 0, 0, false
+	}
+	gpsIFD := int(order.Uint32(gpsEntry[8:12]))
+
+	lat, okLat := readGPSCoordinate(tiff, order, gpsIFD, exifGPSLatTag, exifGPSLatRefTag, "S")
+	lng, okLng := readGPSCoordinate(tiff, order, gpsIFD, exifGPSLngTag, exifGPSLngRefTag, "W")
+	if !okLat || !okLng || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, false
+	}
+	// Cameras without a fix often write zeros
+	if lat == 0 && lng == 0 {
+		return 0, 0, false
+	}
+
+	return lat, lng, true
+}
+
+// readGPSCoordinate decodes a degrees/minutes/seconds rational triple and
+// negates it when the reference tag equals negativeRef
+func readGPSCoordinate(tiff []byte, order binary.ByteOrder, ifd int, valueTag, refTag uint16, negativeRef string) (float64, bool) {
+	entry, ok := findIFDEntry(tiff, order, ifd, valueTag)
+	if !ok || order.Uint16(entry[2:4]) != exifTypeRational || order.Uint32(entry[4:8]) != 3 {
+		return 0, false
+	}
+
+	offset := int(order.Uint32(entry[8:12]))
+	if offset < 0 || offset+3*exifRationalLength > len(tiff) {
+		return 0, false
+	}
+
+	var parts [3]float64
+	for i := range parts {
+		start := offset + i*exifRationalLength
+		num := order.Uint32(tiff[start : start+4])
+		den := order.Uint32(tiff[start+4 : start+8])
+		if den == 0 {
+			return 0, false
+		}
+		parts[i] = float64(num) / float64(den)
+	}
+	value := parts[0] + parts[1]/60 + parts[2]/3600
+
+	// The reference is a short ASCII string stored inline, e.g. "N\x00"
+	if ref, ok := findIFDEntry(tiff, order, ifd, refTag); ok && order.Uint16(ref[2:4]) == exifTypeASCII {
+		if string(ref[8]) == negativeRef {
+			value = -value
+		}
+	}
+
+	return value, true
+}
+
+// findIFDEntry returns the raw 12-byte entry for tag in the IFD at offset
+func findIFDEntry(tiff []byte, order binary.ByteOrder, offset int, tag uint16) ([]byte, bool) {
+	if offset < 0 || offset+2 > len(tiff) {
+		return nil, false
+	}
+
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	if count > exifMaxIFDEntries {
+		return nil, false
+	}
+
+	for i := 0; i < count; i++ {
+		start := offset + 2 + i*exifIFDEntrySize
+		if start+exifIFDEntrySize > len(tiff) {
+			return nil, false
+		}
+		entry := tiff[start : start+exifIFDEntrySize]
+		if order.Uint16(entry[0:2]) == tag {
+			return entry, true
+		}
+	}
+
+	return nil, false
+}
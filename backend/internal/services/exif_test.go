@@ -0,0 +1,146 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+// gpsCoordinate is an EXIF degrees/minutes/seconds triple; seconds are in
+// hundredths
+type gpsCoordinate struct {
+	ref                  string
+	degrees, minutes, cs uint32
+	zeroDenominator      bool
+}
+
+// exifTIFF builds the TIFF structure of an EXIF block holding only a GPS IFD
+func exifTIFF(order binary.ByteOrder, lat, lng gpsCoordinate) []byte {
+	const (
+		ifd0   = 8
+		gpsIFD = ifd0 + 2 + exifIFDEntrySize + 4
+		values = gpsIFD + 2 + 4*exifIFDEntrySize + 4
+	)
+	tiff := make([]byte, values+6*exifRationalLength)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], ifd0)
+
+	entry := func(at int, tag, typ uint16, count, value uint32) {
+		order.PutUint16(tiff[at:], tag)
+		order.PutUint16(tiff[at+2:], typ)
+		order.PutUint32(tiff[at+4:], count)
+		order.PutUint32(tiff[at+8:], value)
+	}
+	order.PutUint16(tiff[ifd0:], 1)
+	entry(ifd0+2, exifGPSIFDTag, exifTypeLong, 1, gpsIFD)
+
+	order.PutUint16(tiff[gpsIFD:], 4)
+	for i, c := range []struct {
+		refTag, valueTag uint16
+		coordinate       gpsCoordinate
+	}{{exifGPSLatRefTag, exifGPSLatTag, lat}, {exifGPSLngRefTag, exifGPSLngTag, lng}} {
+		at := gpsIFD + 2 + 2*i*exifIFDEntrySize
+		offset := values + 3*i*exifRationalLength
+		entry(at, c.refTag, exifTypeASCII, 2, 0)
+		copy(tiff[at+8:], c.coordinate.ref)
+		entry(at+exifIFDEntrySize, c.valueTag, exifTypeRational, 3, uint32(offset))
+
+		rationals := [3][2]uint32{{c.coordinate.degrees, 1}, {c.coordinate.minutes, 1}, {c.coordinate.cs, 100}}
+		if c.coordinate.zeroDenominator {
+			rationals[2][1] = 0
+		}
+		for j, r := range rationals {
+			order.PutUint32(tiff[offset+j*exifRationalLength:], r[0])
+			order.PutUint32(tiff[offset+j*exifRationalLength+4:], r[1])
+		}
+	}
+	return tiff
+}
+
+// jpegSegment encodes a JPEG marker segment with its length
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// testJPEG assembles SOI, the given segments, a scan and EOI
+func testJPEG(segments ...[]byte) []byte {
+	data := []byte{0xFF, jpegSOI}
+	for _, segment := range segments {
+		data = append(data, segment...)
+	}
+	data = append(data, jpegSegment(jpegSOS, []byte{1, 2, 3})...)
+	return append(data, 0xAB, 0xCD, 0xFF, 0xD9)
+}
+
+func TestExtractGPSCoordinates(t *testing.T) {
+	jfif := jpegSegment(0xE0, []byte("JFIF\x00\x01\x02"))
+	exif := func(order binary.ByteOrder, lat, lng gpsCoordinate) []byte {
+		return jpegSegment(jpegAPP1, append([]byte(exifHeader), exifTIFF(order, lat, lng)...))
+	}
+	// 26°55'30.00"N 75°49'12.00"E and 22°54'36.00"S 43°10'21.00"W
+	jaipur := [2]gpsCoordinate{{"N", 26, 55, 3000, false}, {"E", 75, 49, 1200, false}}
+	rio := [2]gpsCoordinate{{"S", 22, 54, 3600, false}, {"W", 43, 10, 2100, false}}
+	broken := jaipur
+	broken[1].zeroDenominator = true
+	truncated := testJPEG(jfif, exif(binary.BigEndian, jaipur[0], jaipur[1]))[:40]
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantLat float64
+		wantLng float64
+		wantGPS bool
+	}{
+		{"big-endian north east", testJPEG(jfif, exif(binary.BigEndian, jaipur[0], jaipur[1])), 26.925, 75.82, true},
+		{"little-endian south west", testJPEG(exif(binary.LittleEndian, rio[0], rio[1])), -22.91, -43.1725, true},
+		{"no fix", testJPEG(exif(binary.BigEndian, gpsCoordinate{ref: "N"}, gpsCoordinate{ref: "E"})), 0, 0, false},
+		{"zero denominator", testJPEG(exif(binary.BigEndian, broken[0], broken[1])), 0, 0, false},
+		{"no EXIF", testJPEG(jfif), 0, 0, false},
+		{"truncated", truncated, 0, 0, false},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, lng, ok := ExtractGPSCoordinates(tt.data)
+			if ok != tt.wantGPS || math.Abs(lat-tt.wantLat) > 1e-9 || math.Abs(lng-tt.wantLng) > 1e-9 {
+				t.Errorf("ExtractGPSCoordinates = %v, %v, %v; want %v, %v, %v", lat, lng, ok, tt.wantLat, tt.wantLng, tt.wantGPS)
+			}
+		})
+	}
+}
+
+func TestStripEXIF(t *testing.T) {
+	jfif := jpegSegment(0xE0, []byte("JFIF\x00\x01\x02"))
+	exif := jpegSegment(jpegAPP1, append([]byte(exifHeader), exifTIFF(binary.BigEndian, gpsCoordinate{"N", 26, 55, 0, false}, gpsCoordinate{"E", 75, 49, 0, false})...))
+	xmp := jpegSegment(jpegAPP1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	tests := []struct {
+		name string
+		data []byte
+		want []byte
+	}{
+		{"EXIF and XMP removed", testJPEG(jfif, exif, xmp), testJPEG(jfif)},
+		{"nothing to strip", testJPEG(jfif), testJPEG(jfif)},
+		{"not a JPEG", png, png},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := StripEXIF(tt.data)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("StripEXIF = % x, want % x", got, tt.want)
+			}
+			if _, _, ok := ExtractGPSCoordinates(got); ok {
+				t.Error("stripped image still has GPS coordinates")
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+)
+
+// Tests in services_test drive the handlers, which import this package, so
+// they reach the package's test fakes through these
+
+// NewTestFirebase returns a FirebaseService backed by an in-memory Firestore
+func NewTestFirebase(t testing.TB) *FirebaseService {
+	fb, _ := newTestFirebase(t)
+	return fb
+}
+
+// NewTestGemini returns a GeminiService that sends requests to baseURL
+func NewTestGemini(baseURL string, client *http.Client) *GeminiService {
+	return &GeminiService{apiKey: "test-key", httpClient: client, baseURL: baseURL}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 
@@ -14,6 +15,8 @@ import (
 	"firebase.google.com/go/v4/auth"
 	"firebase.google.com/go/v4/messaging"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // FirebaseService handles Firebase operations
@@ -112,6 +115,19 @@ type TripData struct {
 	Travelers   int                    `firestore:"travelers"`
 	CreatedAt   interface{}            `firestore:"created_at"`
 	UpdatedAt   interface{}            `firestore:"updated_at"`
+	DeletedAt   *time.Time             `firestore:"deleted_at,omitempty"`
+	ShareCode   string                 `firestore:"share_code,omitempty"`
+	IsPublic    bool                   `firestore:"is_public"`
+
+	// PreviousStatus is the status a deleted trip returns to when restored
+	PreviousStatus string `firestore:"previous_status,omitempty"`
+
+	// ExternalKey is the client's own ID for an imported trip
+	ExternalKey string `firestore:"external_key,omitempty"`
+
+	// Version counts the trip's updates. Writes based on an older version
+	// are rejected; trips saved before versioning are at version 0.
+	Version int64 `firestore:"version"`
 }
 
 // VerifyIDToken verifies Firebase ID token
@@ -123,6 +139,16 @@ func (f *FirebaseService) VerifyIDToken(ctx context.Context, idToken string) (*a
 	return token, nil
 }
 
+// Ping checks that Firestore is reachable by reading a probe document. A
+// missing document still proves the round trip worked.
+func (f *FirebaseService) Ping(ctx context.Context) error {
+	_, err := f.firestore.Collection("health").Doc("probe").Get(ctx)
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("firestore unreachable: %v", err)
+	}
+	return nil
+}
+
 // GetMessagingClient returns the Firebase messaging client
 func (f *FirebaseService) GetMessagingClient() (*messaging.Client, error) {
 	if f.messaging == nil {
@@ -186,8 +212,17 @@ func (f *FirebaseService) UpdateUserPreferences(ctx context.Context, uid string,
 	return nil
 }
 
-// SaveTrip saves trip data to Firestore
-func (f *FirebaseService) SaveTrip(ctx context.Context, trip TripData) error {
+// SaveTrip saves trip data to Firestore, reserving the trip a share code
+// first if it has none
+func (f *FirebaseService) SaveTrip(ctx context.Context, trip *TripData) error {
+	if trip.ShareCode == "" {
+		code, err := f.reserveShareCode(ctx, trip.ID)
+		if err != nil {
+			return err
+		}
+		trip.ShareCode = code
+	}
+
 	_, err := f.firestore.Collection("trips").Doc(trip.ID).Set(ctx, trip)
 	if err != nil {
 		return fmt.Errorf("failed to save trip: %v", err)
@@ -196,23 +231,23 @@ func (f *FirebaseService) SaveTrip(ctx context.Context, trip TripData) error {
 	return nil
 }
 
-// GetUserTrips retrieves all trips for a user
+// GetUserTrips retrieves all trips for a user, excluding deleted trips
 func (f *FirebaseService) GetUserTrips(ctx context.Context, userID string) ([]TripData, error) {
-	iter := f.firestore.Collection("trips").Where("user_id", "==", userID).Documents(ctx)
-	defer iter.Stop()
+	docs, err := f.firestore.Collection("trips").Where("user_id", "==", userID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trips: %w", err)
+	}
 
 	var trips []TripData
-	for {
-		doc, err := iter.Next()
-		if err != nil {
-			break
-		}
-
+	for _, doc := range docs {
 		var trip TripData
 		if err := doc.DataTo(&trip); err != nil {
 			log.Printf("Error converting trip data: %v", err)
 			continue
 		}
+		if trip.Status == "deleted" {
+			continue
+		}
 		trips = append(trips, trip)
 	}
 
@@ -234,7 +269,32 @@ func (f *FirebaseService) GetTrip(ctx context.Context, tripID string) (*TripData
 	return &trip, nil
 }
 
-// UpdateTrip updates trip data
+// LoadTrip retrieves a trip that hasn't been deleted, for work done on its
+// behalf outside a request. It returns *TripNotFoundError when the trip is
+// missing or deleted.
+func (f *FirebaseService) LoadTrip(ctx context.Context, tripID string) (*TripData, error) {
+	doc, err := f.firestore.Collection("trips").Doc(tripID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &TripNotFoundError{TripID: tripID}
+		}
+		return nil, fmt.Errorf("failed to get trip: %v", err)
+	}
+
+	var trip TripData
+	if err := doc.DataTo(&trip); err != nil {
+		return nil, fmt.Errorf("failed to convert trip data: %v", err)
+	}
+	if trip.Status == "deleted" {
+		return nil, &TripNotFoundError{TripID: tripID}
+	}
+	trip.ID = tripID
+	return &trip, nil
+}
+
+// UpdateTrip updates trip data regardless of its version, bumping the
+// version so clients holding an older one are rejected by
+// UpdateTripAtVersion and PatchTrip
 func (f *FirebaseService) UpdateTrip(ctx context.Context, tripID string, updates map[string]interface{}) error {
 	var firestoreUpdates []firestore.Update
 	for key, value := range updates {
@@ -246,6 +306,9 @@ func (f *FirebaseService) UpdateTrip(ctx context.Context, tripID string, updates
 	firestoreUpdates = append(firestoreUpdates, firestore.Update{
 		Path:  "updated_at",
 		Value: firestore.ServerTimestamp,
+	}, firestore.Update{
+		Path:  "version",
+		Value: firestore.Increment(1),
 	})
 
 	_, err := f.firestore.Collection("trips").Doc(tripID).Update(ctx, firestoreUpdates)
@@ -255,24 +318,102 @@ func (f *FirebaseService) UpdateTrip(ctx context.Context, tripID string, updates
 	return nil
 }
 
-// DeleteTrip deletes a trip (soft delete by updating status)
+// TripRestoreWindow is how long after deletion a trip can be restored
+const TripRestoreWindow = 30 * 24 * time.Hour
+
+// ErrTripRestoreExpired is returned when restoring a trip deleted more than
+// TripRestoreWindow ago
+var ErrTripRestoreExpired = errors.New("trip restore window has expired")
+
+// DeleteTrip soft-deletes a trip. The document is kept, so deliveries and
+// other records referencing the trip stay valid, and its status is saved so
+// RestoreTrip can bring it back. Deleting a deleted trip is a no-op.
 func (f *FirebaseService) DeleteTrip(ctx context.Context, tripID string) error {
-	_, err := f.firestore.Collection("trips").Doc(tripID).Update(ctx, []firestore.Update{
-		{Path: "status", Value: "deleted"},
-		{Path: "updated_at", Value: firestore.ServerTimestamp},
+	ref := f.firestore.Collection("trips").Doc(tripID)
+	err := f.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &TripNotFoundError{TripID: tripID}
+			}
+			return err
+		}
+		tripStatus, _ := doc.Data()["status"].(string)
+		if tripStatus == "deleted" {
+			return nil
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: "deleted"},
+			{Path: "previous_status", Value: tripStatus},
+			{Path: "deleted_at", Value: time.Now()},
+			{Path: "updated_at", Value: firestore.ServerTimestamp},
+		})
 	})
 	if err != nil {
+		var notFound *TripNotFoundError
+		if errors.As(err, &notFound) {
+			return err
+		}
 		return fmt.Errorf("failed to delete trip: %v", err)
 	}
 	return nil
 }
 
+// RestoreTrip undoes DeleteTrip within TripRestoreWindow, returning the trip
+// to its status before deletion. Restoring a trip that isn't deleted returns
+// it unchanged.
+func (f *FirebaseService) RestoreTrip(ctx context.Context, tripID string) (*TripData, error) {
+	ref := f.firestore.Collection("trips").Doc(tripID)
+	var trip TripData
+	err := f.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &TripNotFoundError{TripID: tripID}
+			}
+			return err
+		}
+		if err := doc.DataTo(&trip); err != nil {
+			return err
+		}
+		if trip.Status != "deleted" {
+			return nil
+		}
+		if trip.DeletedAt != nil && time.Since(*trip.DeletedAt) > TripRestoreWindow {
+			return ErrTripRestoreExpired
+		}
+
+		restoredStatus := trip.PreviousStatus
+		if restoredStatus == "" {
+			restoredStatus = "planned"
+		}
+		trip.Status = restoredStatus
+		trip.DeletedAt = nil
+		trip.PreviousStatus = ""
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: restoredStatus},
+			{Path: "previous_status", Value: firestore.Delete},
+			{Path: "deleted_at", Value: firestore.Delete},
+			{Path: "updated_at", Value: firestore.ServerTimestamp},
+		})
+	})
+	if err != nil {
+		var notFound *TripNotFoundError
+		if errors.As(err, &notFound) || errors.Is(err, ErrTripRestoreExpired) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to restore trip: %v", err)
+	}
+	return &trip, nil
+}
+
 // SaveRecommendations saves AI recommendations to Firestore
 func (f *FirebaseService) SaveRecommendations(ctx context.Context, userID string, recommendations []map[string]interface{}) error {
 	batch := f.firestore.Batch()
 
 	for i, rec := range recommendations {
 		docRef := f.firestore.Collection("recommendations").NewDoc()
+		rec["id"] = docRef.ID
 		rec["user_id"] = userID
 		rec["created_at"] = firestore.ServerTimestamp
 		batch.Set(docRef, rec)
@@ -313,6 +454,36 @@ func (f *FirebaseService) GetRecommendations(ctx context.Context, userID string,
 	return recommendations, nil
 }
 
+// GetRecommendation retrieves a stored recommendation by ID
+func (f *FirebaseService) GetRecommendation(ctx context.Context, recommendationID string) (map[string]interface{}, error) {
+	doc, err := f.firestore.Collection("recommendations").Doc(recommendationID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &RecommendationNotFoundError{RecommendationID: recommendationID}
+		}
+		return nil, fmt.Errorf("failed to get recommendation: %v", err)
+	}
+
+	data := doc.Data()
+	data["id"] = doc.Ref.ID
+	return data, nil
+}
+
+// UpdateRecommendation updates fields of a stored recommendation
+func (f *FirebaseService) UpdateRecommendation(ctx context.Context, recommendationID string, updates map[string]interface{}) error {
+	var firestoreUpdates []firestore.Update
+	for key, value := range updates {
+		firestoreUpdates = append(firestoreUpdates, firestore.Update{Path: key, Value: value})
+	}
+	firestoreUpdates = append(firestoreUpdates, firestore.Update{Path: "updated_at", Value: firestore.ServerTimestamp})
+
+	_, err := f.firestore.Collection("recommendations").Doc(recommendationID).Update(ctx, firestoreUpdates)
+	if err != nil {
+		return fmt.Errorf("failed to update recommendation: %v", err)
+	}
+	return nil
+}
+
 // SaveAnalyticsEvent saves analytics events to Firestore
 func (f *FirebaseService) SaveAnalyticsEvent(ctx context.Context, event map[string]interface{}) error {
 	event["timestamp"] = firestore.ServerTimestamp
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteAndRestoreTrip(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	ctx := context.Background()
+	seed(t, fb, "trips/t1", map[string]interface{}{"id": "t1", "user_id": "u1", "status": "confirmed"})
+	seed(t, fb, "trips/t2", map[string]interface{}{"id": "t2", "user_id": "u1", "status": "planned"})
+	tripIDs := func() []string {
+		trips, err := fb.GetUserTrips(ctx, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(trips))
+		for i, trip := range trips {
+			ids[i] = trip.ID
+		}
+		return ids
+	}
+
+	if err := fb.DeleteTrip(ctx, "t1"); err != nil {
+		t.Fatalf("DeleteTrip = %v", err)
+	}
+	fields := fake.fields("trips/t1")
+	if fields["status"].GetStringValue() != "deleted" || fields["previous_status"].GetStringValue() != "confirmed" || fields["deleted_at"] == nil {
+		t.Errorf("deleted trip = %v, want it marked deleted with its previous status", fields)
+	}
+	if ids := tripIDs(); len(ids) != 1 || ids[0] != "t2" {
+		t.Errorf("listed trips = %v, want only t2", ids)
+	}
+	if err := fb.DeleteTrip(ctx, "t1"); err != nil {
+		t.Errorf("deleting again = %v, want a no-op", err)
+	}
+
+	trip, err := fb.RestoreTrip(ctx, "t1")
+	if err != nil || trip.Status != "confirmed" || trip.DeletedAt != nil {
+		t.Fatalf("RestoreTrip = %+v, %v; want it confirmed again", trip, err)
+	}
+	if fields := fake.fields("trips/t1"); fields["deleted_at"] != nil || fields["previous_status"] != nil {
+		t.Errorf("restored trip = %v, want the deletion fields cleared", fields)
+	}
+	if ids := tripIDs(); len(ids) != 2 {
+		t.Errorf("listed trips = %v, want both", ids)
+	}
+	if trip, err := fb.RestoreTrip(ctx, "t2"); err != nil || trip.Status != "planned" {
+		t.Errorf("restoring a live trip = %+v, %v; want it unchanged", trip, err)
+	}
+
+	var notFound *TripNotFoundError
+	if err := fb.DeleteTrip(ctx, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("DeleteTrip(missing) = %v, want not found", err)
+	}
+	if _, err := fb.RestoreTrip(ctx, "missing"); !errors.As(err, &notFound) {
+		t.Errorf("RestoreTrip(missing) = %v, want not found", err)
+	}
+}
+
+func TestRestoreTripWindow(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	ctx := context.Background()
+	deleted := func(id string, ago time.Duration) {
+		seed(t, fb, "trips/"+id, map[string]interface{}{
+			"id": id, "user_id": "u1", "status": "deleted", "previous_status": "planned",
+			"deleted_at": time.Now().Add(-ago),
+		})
+	}
+	deleted("recent", TripRestoreWindow-time.Hour)
+	deleted("expired", TripRestoreWindow+time.Hour)
+
+	if trip, err := fb.RestoreTrip(ctx, "recent"); err != nil || trip.Status != "planned" {
+		t.Errorf("restoring inside the window = %+v, %v; want it planned", trip, err)
+	}
+	if _, err := fb.RestoreTrip(ctx, "expired"); !errors.Is(err, ErrTripRestoreExpired) {
+		t.Errorf("restoring after the window = %v, want ErrTripRestoreExpired", err)
+	}
+	if got := fake.fields("trips/expired")["status"].GetStringValue(); got != "deleted" {
+		t.Errorf("expired trip status = %s, want it still deleted", got)
+	}
+}
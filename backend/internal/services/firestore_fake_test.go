@@ -0,0 +1,613 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/firestore"
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const fakeFirestoreRoot = "projects/test/databases/(default)/documents"
+
+// fakeFirestore is an in-memory Firestore server covering the reads,
+// queries, commits, batch writes and transactions the services use. Transactions run one
+// at a time, which is enough for their read-then-write checks to hold.
+type fakeFirestore struct {
+	pb.UnimplementedFirestoreServer
+
+	mu   sync.Mutex
+	docs map[string]*pb.Document
+	txn  chan struct{}
+
+	// failCommit, when set, is consulted before applying each commit
+	failCommit func(*pb.CommitRequest) error
+	// failWrite, when set, is consulted before each write of a batch write
+	failWrite func(*pb.Write) error
+}
+
+// newTestFirebase starts a fake Firestore server and returns a
+// FirebaseService backed by it
+func newTestFirebase(t testing.TB) (*FirebaseService, *fakeFirestore) {
+	t.Helper()
+	fake := &fakeFirestore{docs: make(map[string]*pb.Document), txn: make(chan struct{}, 1)}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pb.RegisterFirestoreServer(server, fake)
+	go server.Serve(lis)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := firestore.NewClient(context.Background(), "test", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+	return &FirebaseService{firestore: client}, fake
+}
+
+// seed writes data to the document at path, e.g. "trips/t1"
+func seed(t testing.TB, fb *FirebaseService, path string, data interface{}) {
+	t.Helper()
+	if _, err := fb.firestore.Doc(path).Set(context.Background(), data); err != nil {
+		t.Fatalf("seeding %s: %v", path, err)
+	}
+}
+
+// fields returns the stored fields of the document at path, or nil
+func (f *fakeFirestore) fields(path string) map[string]*pb.Value {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if doc, ok := f.docs[fakeFirestoreRoot+"/"+path]; ok {
+		return doc.Fields
+	}
+	return nil
+}
+
+// count returns how many documents are stored directly in collection
+func (f *fakeFirestore) count(collection string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for name := range f.docs {
+		if parent, _ := splitDocName(name); parent == fakeFirestoreRoot+"/"+collection {
+			n++
+		}
+	}
+	return n
+}
+
+func splitDocName(name string) (collection, id string) {
+	i := strings.LastIndex(name, "/")
+	return name[:i], name[i+1:]
+}
+
+func (f *fakeFirestore) BeginTransaction(ctx context.Context, req *pb.BeginTransactionRequest) (*pb.BeginTransactionResponse, error) {
+	select {
+	case f.txn <- struct{}{}:
+		return &pb.BeginTransactionResponse{Transaction: []byte("txn")}, nil
+	case <-ctx.Done():
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+func (f *fakeFirestore) Rollback(ctx context.Context, req *pb.RollbackRequest) (*emptypb.Empty, error) {
+	f.endTransaction(req.Transaction)
+	return &emptypb.Empty{}, nil
+}
+
+func (f *fakeFirestore) endTransaction(txn []byte) {
+	if len(txn) > 0 {
+		<-f.txn
+	}
+}
+
+func (f *fakeFirestore) BatchGetDocuments(req *pb.BatchGetDocumentsRequest, stream pb.Firestore_BatchGetDocumentsServer) error {
+	f.mu.Lock()
+	var responses []*pb.BatchGetDocumentsResponse
+	now := timestamppb.Now()
+	for _, name := range req.Documents {
+		if doc, ok := f.docs[name]; ok {
+			responses = append(responses, &pb.BatchGetDocumentsResponse{Result: &pb.BatchGetDocumentsResponse_Found{Found: proto.Clone(doc).(*pb.Document)}, ReadTime: now})
+		} else {
+			responses = append(responses, &pb.BatchGetDocumentsResponse{Result: &pb.BatchGetDocumentsResponse_Missing{Missing: name}, ReadTime: now})
+		}
+	}
+	f.mu.Unlock()
+
+	for _, resp := range responses {
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeFirestore) Commit(ctx context.Context, req *pb.CommitRequest) (*pb.CommitResponse, error) {
+	defer f.endTransaction(req.Transaction)
+	if f.failCommit != nil {
+		if err := f.failCommit(req); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := timestamppb.Now()
+
+	// Check every precondition before applying anything
+	for _, w := range req.Writes {
+		if err := f.checkPrecondition(w); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]*pb.WriteResult, len(req.Writes))
+	for i, w := range req.Writes {
+		f.apply(w, now)
+		results[i] = &pb.WriteResult{UpdateTime: now}
+	}
+	return &pb.CommitResponse{WriteResults: results, CommitTime: now}, nil
+}
+
+// BatchWrite applies each write on its own, as the bulk writer expects
+func (f *fakeFirestore) BatchWrite(ctx context.Context, req *pb.BatchWriteRequest) (*pb.BatchWriteResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := timestamppb.Now()
+
+	resp := &pb.BatchWriteResponse{}
+	for _, w := range req.Writes {
+		err := f.checkPrecondition(w)
+		if err == nil && f.failWrite != nil {
+			err = f.failWrite(w)
+		}
+		if err != nil {
+			resp.WriteResults = append(resp.WriteResults, &pb.WriteResult{})
+			resp.Status = append(resp.Status, status.Convert(err).Proto())
+			continue
+		}
+		f.apply(w, now)
+		resp.WriteResults = append(resp.WriteResults, &pb.WriteResult{UpdateTime: now})
+		resp.Status = append(resp.Status, status.New(codes.OK, "").Proto())
+	}
+	return resp, nil
+}
+
+// checkPrecondition reports whether w's precondition fails. f.mu must be held.
+func (f *fakeFirestore) checkPrecondition(w *pb.Write) error {
+	name := writeName(w)
+	existing, exists := f.docs[name]
+	switch pre := w.GetCurrentDocument().GetConditionType().(type) {
+	case *pb.Precondition_Exists:
+		if pre.Exists && !exists {
+			return status.Errorf(codes.NotFound, "no document %s", name)
+		}
+		if !pre.Exists && exists {
+			return status.Errorf(codes.AlreadyExists, "document %s exists", name)
+		}
+	case *pb.Precondition_UpdateTime:
+		if !exists || !proto.Equal(existing.UpdateTime, pre.UpdateTime) {
+			return status.Errorf(codes.FailedPrecondition, "document %s changed", name)
+		}
+	}
+	return nil
+}
+
+// apply applies w at now. f.mu must be held.
+func (f *fakeFirestore) apply(w *pb.Write, now *timestamppb.Timestamp) {
+	name := writeName(w)
+	if w.GetDelete() != "" {
+		delete(f.docs, name)
+		return
+	}
+
+	doc, exists := f.docs[name]
+	if !exists {
+		doc = &pb.Document{Name: name, Fields: map[string]*pb.Value{}, CreateTime: now}
+	} else {
+		doc = proto.Clone(doc).(*pb.Document)
+	}
+	update := w.GetUpdate()
+	if mask := w.GetUpdateMask(); mask != nil {
+		for _, path := range mask.FieldPaths {
+			parts := splitFieldPath(path)
+			if v := lookupField(update.Fields, parts); v != nil {
+				setField(doc.Fields, parts, v)
+			} else {
+				deleteField(doc.Fields, parts)
+			}
+		}
+	} else {
+		doc.Fields = proto.Clone(&pb.MapValue{Fields: update.Fields}).(*pb.MapValue).Fields
+		if doc.Fields == nil {
+			doc.Fields = map[string]*pb.Value{}
+		}
+	}
+	for _, tr := range w.UpdateTransforms {
+		parts := splitFieldPath(tr.FieldPath)
+		setField(doc.Fields, parts, applyTransform(lookupField(doc.Fields, parts), tr, now))
+	}
+	doc.UpdateTime = now
+	f.docs[name] = doc
+}
+
+func writeName(w *pb.Write) string {
+	if name := w.GetDelete(); name != "" {
+		return name
+	}
+	return w.GetUpdate().GetName()
+}
+
+func applyTransform(current *pb.Value, tr *pb.DocumentTransform_FieldTransform, now *timestamppb.Timestamp) *pb.Value {
+	switch t := tr.TransformType.(type) {
+	case *pb.DocumentTransform_FieldTransform_SetToServerValue:
+		return &pb.Value{ValueType: &pb.Value_TimestampValue{TimestampValue: now}}
+	case *pb.DocumentTransform_FieldTransform_Increment:
+		if inc, ok := t.Increment.ValueType.(*pb.Value_IntegerValue); ok {
+			if current == nil {
+				return t.Increment
+			}
+			if base, ok := current.ValueType.(*pb.Value_IntegerValue); ok {
+				return &pb.Value{ValueType: &pb.Value_IntegerValue{IntegerValue: base.IntegerValue + inc.IntegerValue}}
+			}
+		}
+		base, _ := numericValue(current)
+		inc, _ := numericValue(t.Increment)
+		return &pb.Value{ValueType: &pb.Value_DoubleValue{DoubleValue: base + inc}}
+	case *pb.DocumentTransform_FieldTransform_AppendMissingElements:
+		values := current.GetArrayValue().GetValues()
+		for _, v := range t.AppendMissingElements.Values {
+			if !containsValue(values, v) {
+				values = append(values, v)
+			}
+		}
+		return &pb.Value{ValueType: &pb.Value_ArrayValue{ArrayValue: &pb.ArrayValue{Values: values}}}
+	case *pb.DocumentTransform_FieldTransform_RemoveAllFromArray:
+		var values []*pb.Value
+		for _, v := range current.GetArrayValue().GetValues() {
+			if !containsValue(t.RemoveAllFromArray.Values, v) {
+				values = append(values, v)
+			}
+		}
+		return &pb.Value{ValueType: &pb.Value_ArrayValue{ArrayValue: &pb.ArrayValue{Values: values}}}
+	}
+	return current
+}
+
+func (f *fakeFirestore) RunQuery(req *pb.RunQueryRequest, stream pb.Firestore_RunQueryServer) error {
+	docs, err := f.query(req.Parent, req.GetStructuredQuery())
+	if err != nil {
+		return err
+	}
+	now := timestamppb.Now()
+	if len(docs) == 0 {
+		return stream.Send(&pb.RunQueryResponse{ReadTime: now})
+	}
+	for _, doc := range docs {
+		if err := stream.Send(&pb.RunQueryResponse{Document: doc, ReadTime: now}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeFirestore) RunAggregationQuery(req *pb.RunAggregationQueryRequest, stream pb.Firestore_RunAggregationQueryServer) error {
+	aggregation := req.GetStructuredAggregationQuery()
+	docs, err := f.query(req.Parent, aggregation.GetStructuredQuery())
+	if err != nil {
+		return err
+	}
+	result := &pb.AggregationResult{AggregateFields: map[string]*pb.Value{}}
+	for _, agg := range aggregation.Aggregations {
+		if agg.GetCount() == nil {
+			return status.Error(codes.Unimplemented, "only count aggregations are supported")
+		}
+		result.AggregateFields[agg.Alias] = &pb.Value{ValueType: &pb.Value_IntegerValue{IntegerValue: int64(len(docs))}}
+	}
+	return stream.Send(&pb.RunAggregationQueryResponse{Result: result, ReadTime: timestamppb.Now()})
+}
+
+// query evaluates a structured query against the stored documents
+func (f *fakeFirestore) query(parent string, q *pb.StructuredQuery) ([]*pb.Document, error) {
+	if q == nil || len(q.From) != 1 {
+		return nil, status.Error(codes.Unimplemented, "only single-collection queries are supported")
+	}
+	if q.StartAt != nil || q.EndAt != nil {
+		return nil, status.Error(codes.Unimplemented, "cursors are not supported")
+	}
+	collection := parent + "/" + q.From[0].CollectionId
+
+	f.mu.Lock()
+	var docs []*pb.Document
+	for name, doc := range f.docs {
+		dir, _ := splitDocName(name)
+		if dir != collection && !(q.From[0].AllDescendants && strings.HasSuffix(dir, "/"+q.From[0].CollectionId)) {
+			continue
+		}
+		if q.Where == nil || matchesFilter(doc, q.Where) {
+			docs = append(docs, proto.Clone(doc).(*pb.Document))
+		}
+	}
+	f.mu.Unlock()
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, order := range q.OrderBy {
+			c := compareValues(docField(docs[i], order.Field.FieldPath), docField(docs[j], order.Field.FieldPath))
+			if order.Direction == pb.StructuredQuery_DESCENDING {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return docs[i].Name < docs[j].Name
+	})
+	// Ordering on a field excludes documents without it
+	for _, order := range q.OrderBy {
+		kept := docs[:0]
+		for _, doc := range docs {
+			if docField(doc, order.Field.FieldPath) != nil {
+				kept = append(kept, doc)
+			}
+		}
+		docs = kept
+	}
+
+	if offset := int(q.Offset); offset > 0 {
+		if offset > len(docs) {
+			offset = len(docs)
+		}
+		docs = docs[offset:]
+	}
+	if q.Limit != nil && int(q.Limit.Value) < len(docs) {
+		docs = docs[:q.Limit.Value]
+	}
+	return docs, nil
+}
+
+func matchesFilter(doc *pb.Document, filter *pb.StructuredQuery_Filter) bool {
+	switch ft := filter.FilterType.(type) {
+	case *pb.StructuredQuery_Filter_CompositeFilter:
+		or := ft.CompositeFilter.Op == pb.StructuredQuery_CompositeFilter_OR
+		for _, sub := range ft.CompositeFilter.Filters {
+			if matchesFilter(doc, sub) == or {
+				return or
+			}
+		}
+		return !or
+	case *pb.StructuredQuery_Filter_UnaryFilter:
+		v := docField(doc, ft.UnaryFilter.GetField().FieldPath)
+		_, isNull := v.GetValueType().(*pb.Value_NullValue)
+		switch ft.UnaryFilter.Op {
+		case pb.StructuredQuery_UnaryFilter_IS_NULL:
+			return isNull
+		case pb.StructuredQuery_UnaryFilter_IS_NOT_NULL:
+			return v != nil && !isNull
+		case pb.StructuredQuery_UnaryFilter_IS_NAN:
+			return math.IsNaN(v.GetDoubleValue())
+		case pb.StructuredQuery_UnaryFilter_IS_NOT_NAN:
+			return v != nil && !math.IsNaN(v.GetDoubleValue())
+		}
+	case *pb.StructuredQuery_Filter_FieldFilter:
+		v := docField(doc, ft.FieldFilter.Field.FieldPath)
+		want := ft.FieldFilter.Value
+		switch ft.FieldFilter.Op {
+		case pb.StructuredQuery_FieldFilter_NOT_EQUAL:
+			return v != nil && compareValues(v, want) != 0
+		case pb.StructuredQuery_FieldFilter_NOT_IN:
+			return v != nil && !containsValue(want.GetArrayValue().GetValues(), v)
+		}
+		if v == nil {
+			return false
+		}
+		switch ft.FieldFilter.Op {
+		case pb.StructuredQuery_FieldFilter_EQUAL:
+			return compareValues(v, want) == 0
+		case pb.StructuredQuery_FieldFilter_LESS_THAN:
+			return sameKind(v, want) && compareValues(v, want) < 0
+		case pb.StructuredQuery_FieldFilter_LESS_THAN_OR_EQUAL:
+			return sameKind(v, want) && compareValues(v, want) <= 0
+		case pb.StructuredQuery_FieldFilter_GREATER_THAN:
+			return sameKind(v, want) && compareValues(v, want) > 0
+		case pb.StructuredQuery_FieldFilter_GREATER_THAN_OR_EQUAL:
+			return sameKind(v, want) && compareValues(v, want) >= 0
+		case pb.StructuredQuery_FieldFilter_IN:
+			return containsValue(want.GetArrayValue().GetValues(), v)
+		case pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS:
+			return containsValue(v.GetArrayValue().GetValues(), want)
+		case pb.StructuredQuery_FieldFilter_ARRAY_CONTAINS_ANY:
+			for _, w := range want.GetArrayValue().GetValues() {
+				if containsValue(v.GetArrayValue().GetValues(), w) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// docField resolves a field path, including __name__, on a document
+func docField(doc *pb.Document, path string) *pb.Value {
+	if path == "__name__" {
+		return &pb.Value{ValueType: &pb.Value_ReferenceValue{ReferenceValue: doc.Name}}
+	}
+	return lookupField(doc.Fields, splitFieldPath(path))
+}
+
+func splitFieldPath(path string) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range path {
+		switch {
+		case r == '`':
+			quoted = !quoted
+		case r == '.' && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
+
+func lookupField(fields map[string]*pb.Value, parts []string) *pb.Value {
+	v, ok := fields[parts[0]]
+	if !ok {
+		return nil
+	}
+	if len(parts) == 1 {
+		return v
+	}
+	return lookupField(v.GetMapValue().GetFields(), parts[1:])
+}
+
+func setField(fields map[string]*pb.Value, parts []string, v *pb.Value) {
+	if len(parts) == 1 {
+		fields[parts[0]] = proto.Clone(v).(*pb.Value)
+		return
+	}
+	child := fields[parts[0]].GetMapValue()
+	if child == nil {
+		child = &pb.MapValue{}
+		fields[parts[0]] = &pb.Value{ValueType: &pb.Value_MapValue{MapValue: child}}
+	}
+	if child.Fields == nil {
+		child.Fields = map[string]*pb.Value{}
+	}
+	setField(child.Fields, parts[1:], v)
+}
+
+func deleteField(fields map[string]*pb.Value, parts []string) {
+	if len(parts) == 1 {
+		delete(fields, parts[0])
+		return
+	}
+	if child := fields[parts[0]].GetMapValue(); child != nil {
+		deleteField(child.Fields, parts[1:])
+	}
+}
+
+func containsValue(values []*pb.Value, v *pb.Value) bool {
+	for _, candidate := range values {
+		if compareValues(candidate, v) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// valueRank orders value types the way Firestore sorts mixed types
+func valueRank(v *pb.Value) int {
+	switch v.GetValueType().(type) {
+	case nil, *pb.Value_NullValue:
+		return 0
+	case *pb.Value_BooleanValue:
+		return 1
+	case *pb.Value_IntegerValue, *pb.Value_DoubleValue:
+		return 2
+	case *pb.Value_TimestampValue:
+		return 3
+	case *pb.Value_StringValue:
+		return 4
+	case *pb.Value_BytesValue:
+		return 5
+	case *pb.Value_ReferenceValue:
+		return 6
+	case *pb.Value_GeoPointValue:
+		return 7
+	case *pb.Value_ArrayValue:
+		return 8
+	default:
+		return 9
+	}
+}
+
+func sameKind(a, b *pb.Value) bool {
+	return valueRank(a) == valueRank(b)
+}
+
+func numericValue(v *pb.Value) (float64, bool) {
+	switch n := v.GetValueType().(type) {
+	case *pb.Value_IntegerValue:
+		return float64(n.IntegerValue), true
+	case *pb.Value_DoubleValue:
+		return n.DoubleValue, true
+	}
+	return 0, false
+}
+
+func compareValues(a, b *pb.Value) int {
+	if ra, rb := valueRank(a), valueRank(b); ra != rb {
+		return ra - rb
+	}
+	switch av := a.GetValueType().(type) {
+	case *pb.Value_BooleanValue:
+		bv := b.GetBooleanValue()
+		switch {
+		case av.BooleanValue == bv:
+			return 0
+		case bv:
+			return -1
+		}
+		return 1
+	case *pb.Value_IntegerValue, *pb.Value_DoubleValue:
+		x, _ := numericValue(a)
+		y, _ := numericValue(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case *pb.Value_TimestampValue:
+		return av.TimestampValue.AsTime().Compare(b.GetTimestampValue().AsTime())
+	case *pb.Value_StringValue:
+		return strings.Compare(av.StringValue, b.GetStringValue())
+	case *pb.Value_BytesValue:
+		return bytes.Compare(av.BytesValue, b.GetBytesValue())
+	case *pb.Value_ReferenceValue:
+		return strings.Compare(av.ReferenceValue, b.GetReferenceValue())
+	case *pb.Value_ArrayValue:
+		x, y := av.ArrayValue.GetValues(), b.GetArrayValue().GetValues()
+		for i := 0; i < len(x) && i < len(y); i++ {
+			if c := compareValues(x[i], y[i]); c != 0 {
+				return c
+			}
+		}
+		return len(x) - len(y)
+	case *pb.Value_MapValue:
+		if proto.Equal(a, b) {
+			return 0
+		}
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Flight status updates are pushed by airlines and aggregators, signed like
+// AuraTravel's own webhooks (see VerifyWebhookSignature) with the shared
+// FLIGHT_STATUS_WEBHOOK_SECRET:
+//
+//	X-Flight-Status-Signature: t=<unix seconds>,v1=<hex signature>
+const FlightStatusSignatureHeader = "X-Flight-Status-Signature"
+
+// FlightStatusEvent is a flight status update pushed by a provider. A flight
+// is identified by its number, its booking reference, or both.
+type FlightStatusEvent struct {
+	Provider           string     `json:"provider"`
+	FlightNumber       string     `json:"flight_number"`
+	BookingRef         string     `json:"booking_ref"`
+	Status             string     `json:"status"` // scheduled, on_time, delayed, cancelled, diverted, rescheduled
+	ScheduledDeparture *time.Time `json:"scheduled_departure,omitempty"`
+	EstimatedDeparture *time.Time `json:"estimated_departure,omitempty"`
+	DelayMinutes       int        `json:"delay_minutes,omitempty"`
+	Reason             string     `json:"reason,omitempty"`
+}
+
+// flightAlertStatuses maps provider flight statuses to DelayAlert statuses
+var flightAlertStatuses = map[string]string{
+	"scheduled":   "on_time",
+	"on_time":     "on_time",
+	"delayed":     "delayed",
+	"cancelled":   "cancelled",
+	"canceled":    "cancelled",
+	"diverted":    "rescheduled",
+	"rescheduled": "rescheduled",
+}
+
+// normalizeFlightNumber writes flight numbers one way, so "ai 101" and
+// "AI101" match
+func normalizeFlightNumber(number string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(number), ""))
+}
+
+// DelayAlert maps the update to the alert replanning works from. The delay
+// is the one reported, or else the time between the scheduled and estimated
+// departures.
+func (e FlightStatusEvent) DelayAlert() (DelayAlert, error) {
+	flightNumber := normalizeFlightNumber(e.FlightNumber)
+	bookingRef := strings.TrimSpace(e.BookingRef)
+	if flightNumber == "" && bookingRef == "" {
+		return DelayAlert{}, kindErrorf(ErrValidation, "flight_number or booking_ref is required")
+	}
+	status, ok := flightAlertStatuses[strings.ToLower(strings.TrimSpace(e.Status))]
+	if !ok {
+		return DelayAlert{}, kindErrorf(ErrValidation, "unknown flight status %q", e.Status)
+	}
+	if e.DelayMinutes < 0 {
+		return DelayAlert{}, kindErrorf(ErrValidation, "delay_minutes must not be negative")
+	}
+
+	delay := time.Duration(e.DelayMinutes) * time.Minute
+	if delay == 0 && e.ScheduledDeparture != nil && e.EstimatedDeparture != nil {
+		delay = max(e.EstimatedDeparture.Sub(*e.ScheduledDeparture), 0)
+	}
+	if status == "on_time" && delay > 0 {
+		status = "delayed"
+	}
+
+	serviceID := flightNumber
+	if serviceID == "" {
+		serviceID = bookingRef
+	}
+	reason := e.Reason
+	if reason == "" {
+		reason = fmt.Sprintf("flight %s is %s", serviceID, strings.ReplaceAll(status, "_", " "))
+	}
+	return DelayAlert{
+		ServiceType: "flight",
+		ServiceID:   serviceID,
+		DelayTime:   delay,
+		Status:      status,
+		Reason:      reason,
+		NewSchedule: e.EstimatedDeparture,
+	}, nil
+}
+
+// FlightTripFinder finds the trips booked on a flight, by flight number or
+// booking reference. FirebaseService is the production implementation.
+type FlightTripFinder interface {
+	FindTripsWithFlight(ctx context.Context, flightNumber, bookingRef string) ([]string, error)
+}
+
+// SetFlightTripFinder replaces where flight status updates look up the trips
+// booked on a flight
+func (d *DynamicReplanningService) SetFlightTripFinder(flights FlightTripFinder) {
+	d.flights = flights
+}
+
+// FlightStatusResult is what became of a flight status update: the trips
+// booked on the flight, and which of them were being monitored and so had the
+// alert fed into replanning. An update matching no trip is ignored.
+type FlightStatusResult struct {
+	Alert     DelayAlert `json:"alert"`
+	Matched   []string   `json:"matched_trips"`
+	Delivered []string   `json:"delivered_trips"`
+}
+
+// IngestFlightStatus correlates a flight status update with the trips
+// booked on the flight, by booking reference or flight number, and passes
+// the alert to the monitor of each such trip, which checks for replanning
+// right away.
+func (d *DynamicReplanningService) IngestFlightStatus(ctx context.Context, event FlightStatusEvent) (*FlightStatusResult, error) {
+	alert, err := event.DelayAlert()
+	if err != nil {
+		return nil, err
+	}
+	if d.flights == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+
+	tripIDs, err := d.flights.FindTripsWithFlight(ctx, normalizeFlightNumber(event.FlightNumber), strings.TrimSpace(event.BookingRef))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FlightStatusResult{Alert: alert, Matched: tripIDs, Delivered: []string{}}
+	for _, tripID := range tripIDs {
+		if d.DeliverDelayAlert(tripID, alert) {
+			result.Delivered = append(result.Delivered, tripID)
+		}
+	}
+	return result, nil
+}
+
+// DeliverDelayAlert queues a delay alert for a trip's monitor and wakes it
+// to check for replanning. It reports whether the trip is monitored;
+// alerts for unmonitored trips are dropped.
+func (d *DynamicReplanningService) DeliverDelayAlert(tripID string, alert DelayAlert) bool {
+	d.monitorsMu.Lock()
+	defer d.monitorsMu.Unlock()
+
+	wake, ok := d.wakeups[tripID]
+	if !ok {
+		return false
+	}
+	d.pendingDelays[tripID] = append(d.pendingDelays[tripID], alert)
+	select {
+	case wake <- struct{}{}:
+	default:
+		// A wakeup is already pending; it will see this alert too
+	}
+	return true
+}
+
+// FindTripsWithFlight returns the IDs of trips with a flight matching the
+// booking reference or flight number. Either may be empty.
+func (f *FirebaseService) FindTripsWithFlight(ctx context.Context, flightNumber, bookingRef string) ([]string, error) {
+	seen := make(map[string]bool)
+	tripIDs := []string{}
+	lookups := []struct{ field, value string }{
+		{"booking_ref", bookingRef},
+		{"flight_number", flightNumber},
+	}
+	for _, lookup := range lookups {
+		if lookup.value == "" {
+			continue
+		}
+		docs, err := f.firestore.Collection("transportation").Where(lookup.field, "==", lookup.value).Documents(ctx).GetAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find trips with flight: %v", err)
+		}
+		for _, doc := range docs {
+			data := doc.Data()
+			if transportType, _ := data["type"].(string); transportType != "" && transportType != "flight" {
+				continue
+			}
+			tripID, _ := data["trip_id"].(string)
+			if tripID != "" && !seen[tripID] {
+				seen[tripID] = true
+				tripIDs = append(tripIDs, tripID)
+			}
+		}
+	}
+	return tripIDs, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// stubFlightTrips finds trips by flight number
+type stubFlightTrips map[string][]string
+
+func (s stubFlightTrips) FindTripsWithFlight(ctx context.Context, flightNumber, bookingRef string) ([]string, error) {
+	return s[flightNumber], nil
+}
+
+func TestIngestFlightStatusTriggersMonitoredTrips(t *testing.T) {
+	d := NewDynamicReplanningService(nil, nil, nil, nil, nil, nil, "")
+	d.SetFlightTripFinder(stubFlightTrips{"AI101": {"monitored", "unmonitored"}})
+	// Stand in for a running monitor of one trip
+	wake := make(chan struct{}, 1)
+	d.wakeups["monitored"] = wake
+
+	var event FlightStatusEvent
+	payload := `{"provider":"aviationstack","flight_number":"ai 101","status":"delayed",
+		"scheduled_departure":"2026-12-01T06:00:00Z","estimated_departure":"2026-12-01T09:30:00Z","reason":"late inbound aircraft"}`
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	result, err := d.IngestFlightStatus(context.Background(), event)
+	if err != nil {
+		t.Fatalf("IngestFlightStatus: %v", err)
+	}
+	if len(result.Matched) != 2 || len(result.Delivered) != 1 || result.Delivered[0] != "monitored" {
+		t.Fatalf("matched %v, delivered %v; want both matched and the monitored trip delivered", result.Matched, result.Delivered)
+	}
+
+	select {
+	case <-wake:
+	default:
+		t.Fatal("monitor wasn't woken")
+	}
+	triggers := d.checkDelayTriggers(context.Background(), &TripData{ID: "monitored"})
+	if len(triggers) != 1 {
+		t.Fatalf("monitor saw %d delay triggers, want 1", len(triggers))
+	}
+	alert, _ := triggers[0].Data.(DelayAlert)
+	if triggers[0].Type != "delay" || alert.ServiceID != "AI101" || alert.DelayTime != 210*time.Minute {
+		t.Errorf("trigger = %+v, want AI101 delayed by 3h30m", triggers[0])
+	}
+	if again := d.checkDelayTriggers(context.Background(), &TripData{ID: "monitored"}); len(again) != 0 {
+		t.Errorf("delay trigger seen again on the next check: %+v", again)
+	}
+}
+
+func TestIngestFlightStatusIgnoresUnknownFlights(t *testing.T) {
+	d := NewDynamicReplanningService(nil, nil, nil, nil, nil, nil, "")
+	d.SetFlightTripFinder(stubFlightTrips{})
+
+	result, err := d.IngestFlightStatus(context.Background(), FlightStatusEvent{FlightNumber: "6E 202", Status: "cancelled"})
+	if err != nil {
+		t.Fatalf("IngestFlightStatus: %v", err)
+	}
+	if len(result.Matched) != 0 || len(result.Delivered) != 0 {
+		t.Errorf("unknown flight matched %v and delivered %v", result.Matched, result.Delivered)
+	}
+}
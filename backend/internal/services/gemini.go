@@ -4,14 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
+	"auratravel-backend/internal/cache"
 	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/metrics"
+	"auratravel-backend/internal/tracing"
 )
 
 // GeminiService handles Gemini AI interactions
@@ -20,11 +28,32 @@ type GeminiService struct {
 	cfg        *config.Config
 	httpClient *http.Client
 	baseURL    string
+	cache      cache.Cache
+
+	// now and mockSeed make generated output reproducible in tests; see
+	// SetClock and SetMockSeed
+	now      func() time.Time
+	mockSeed *int64
+
+	// model is the Gemini model requests are sent to
+	model string
+	// schemaRejected holds the models that rejected a response schema, so
+	// their later requests go straight to the text path
+	schemaRejected sync.Map
+	// slotWeights override DefaultSlotWeights when laying out days; see
+	// SetSlotWeights
+	slotWeights map[string]SlotWeights
 }
 
+// recommendationsCacheTTL is how long Gemini destination recommendations are
+// reused for an identical request
+const recommendationsCacheTTL = 6 * time.Hour
+
 // GeminiRequest represents a request to Gemini API
 type GeminiRequest struct {
-	Contents []GeminiContent `json:"contents"`
+	Contents         []GeminiContent         `json:"contents"`
+	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings   []GeminiSafetySetting   `json:"safetySettings,omitempty"`
 }
 
 // GeminiContent represents content in Gemini request
@@ -47,26 +76,72 @@ type GeminiCandidate struct {
 	Content GeminiContent `json:"content"`
 }
 
-// NewGeminiService creates a new Gemini AI service
-func NewGeminiService() (*GeminiService, error) {
+// defaultGeminiModel is used when no model is configured; it supports
+// response schemas
+const defaultGeminiModel = "gemini-2.0-flash"
+
+// NewGeminiService creates a new Gemini AI service. resultCache may be nil.
+func NewGeminiService(resultCache cache.Cache) (*GeminiService, error) {
 	cfg := config.GetConfig()
 
-	if cfg.GeminiAPIKey == "" {
-		log.Println("Warning: GEMINI_API_KEY not set, using mock service")
-		return &GeminiService{
-			apiKey:     "",
-			cfg:        cfg,
-			httpClient: &http.Client{Timeout: 30 * time.Second},
-			baseURL:    "https://generativelanguage.googleapis.com/v1beta",
-		}, nil
+	if cfg.GeminiModel != "" && !modelSupportsSchema(cfg.GeminiModel) {
+		slog.Error("GEMINI_MODEL does not support response schemas; itineraries will be parsed from free text", "model", cfg.GeminiModel)
+	}
+
+	apiKey := cfg.GeminiAPIKey
+	if apiKey == "" {
+		slog.Warn("GEMINI_API_KEY not set, using mock service")
 	}
 
-	return &GeminiService{
-		apiKey:     cfg.GeminiAPIKey,
+	g := &GeminiService{
+		apiKey:     apiKey,
 		cfg:        cfg,
-		httpClient: &http.Client{Timeout: 30 * time.Second},
+		httpClient: httpclient.New(httpclient.Options{RetryAll: true}),
 		baseURL:    "https://generativelanguage.googleapis.com/v1beta",
-	}, nil
+		cache:      resultCache,
+		model:      cfg.GeminiModel,
+	}
+
+	weights, err := ParseSlotWeights(cfg.AttractionSlotWeights)
+	if err != nil {
+		slog.Error("Ignoring ATTRACTION_SLOT_WEIGHTS, using the default weights", "error", err)
+	} else if weights != nil {
+		g.SetSlotWeights(weights)
+	}
+
+	return g, nil
+}
+
+// SetClock replaces the clock used for created_at timestamps, so generated
+// itineraries don't change with the time they're made. A nil clock restores
+// real time.
+func (g *GeminiService) SetClock(now func() time.Time) {
+	g.now = now
+}
+
+// SetMockSeed makes mock output vary with seed, reproducibly: the same seed
+// always orders mock recommendations and activity suggestions the same way.
+// Without a seed mock output is fixed.
+func (g *GeminiService) SetMockSeed(seed int64) {
+	g.mockSeed = &seed
+}
+
+// clock returns the current time from the injected clock, if any
+func (g *GeminiService) clock() time.Time {
+	if g.now != nil {
+		return g.now()
+	}
+	return time.Now()
+}
+
+// mockRand returns a source for mock selection seeded with the mock seed, or
+// nil when no seed is set. Each call starts the sequence afresh, so a
+// generation depends only on the seed, not on earlier calls.
+func (g *GeminiService) mockRand() *rand.Rand {
+	if g.mockSeed == nil {
+		return nil
+	}
+	return rand.New(rand.NewSource(*g.mockSeed))
 }
 
 // ItineraryRequest represents itinerary generation request
@@ -77,6 +152,12 @@ type ItineraryRequest struct {
 	Budget      float64                `json:"budget"`
 	Travelers   int                    `json:"travelers"`
 	Preferences map[string]interface{} `json:"preferences"`
+	// Destinations, when set, plans a multi-city trip visiting each leg in
+	// order; Destination is then only used as a label
+	Destinations []DestinationLeg `json:"destinations,omitempty"`
+	// TravelerProfiles, when they differ, add per-group alternatives to each
+	// day on top of the shared plan
+	TravelerProfiles []TravelerProfile `json:"traveler_profiles,omitempty"`
 }
 
 // RecommendationRequest represents recommendation request
@@ -92,15 +173,19 @@ func (g *GeminiService) GenerateItinerary(ctx context.Context, req ItineraryRequ
 		return g.mockItinerary(req), nil
 	}
 
+	logger := logging.FromContext(ctx).With("destination", req.Destination)
+	start := time.Now()
+
 	prompt := g.buildItineraryPrompt(req)
-	response, err := g.callGeminiAPI(ctx, prompt)
+	response, err := g.callGeminiAPI(ctx, prompt, GeminiCallOptions{UseCase: GeminiStructured, Schema: itinerarySchema})
 	if err != nil {
-		log.Printf("Gemini API call failed, falling back to mock: %v", err)
+		logger.Warn("Gemini API call failed, falling back to mock", "error", err, "latency", time.Since(start))
 		return g.mockItinerary(req), nil
 	}
+	logger.Info("Generated itinerary", "latency", time.Since(start))
 
 	// Parse the response and create structured itinerary
-	itinerary := g.parseItineraryResponse(response, req)
+	itinerary := g.parseItineraryResponse(ctx, response, req)
 	return itinerary, nil
 }
 
@@ -110,15 +195,19 @@ func (g *GeminiService) GenerateItineraryWithRAG(ctx context.Context, req Itiner
 		return g.mockItineraryWithRAG(req, ragContext), nil
 	}
 
+	logger := logging.FromContext(ctx).With("destination", req.Destination)
+	start := time.Now()
+
 	prompt := g.buildRAGItineraryPrompt(req, ragContext)
-	response, err := g.callGeminiAPI(ctx, prompt)
+	response, err := g.callGeminiAPI(ctx, prompt, GeminiCallOptions{UseCase: GeminiStructured, Schema: itinerarySchema})
 	if err != nil {
-		log.Printf("Gemini API call failed, falling back to mock: %v", err)
+		logger.Warn("Gemini API call failed, falling back to mock", "error", err, "latency", time.Since(start))
 		return g.mockItineraryWithRAG(req, ragContext), nil
 	}
+	logger.Info("Generated RAG itinerary", "latency", time.Since(start))
 
 	// Parse the response and create structured itinerary with RAG context
-	itinerary := g.parseRAGItineraryResponse(response, req, ragContext)
+	itinerary := g.parseRAGItineraryResponse(ctx, response, req, ragContext)
 	return itinerary, nil
 }
 
@@ -128,15 +217,22 @@ func (g *GeminiService) GetDestinationRecommendations(ctx context.Context, req R
 		return g.mockRecommendations(req), nil
 	}
 
+	cacheKey := cache.HashKey("recommendations", req)
+	var recommendations []map[string]interface{}
+	if cache.GetJSON(ctx, g.cache, cacheKey, &recommendations) {
+		return recommendations, nil
+	}
+
 	prompt := g.buildRecommendationPrompt(req)
-	response, err := g.callGeminiAPI(ctx, prompt)
+	response, err := g.callGeminiAPI(ctx, prompt, GeminiCallOptions{UseCase: GeminiCreative})
 	if err != nil {
-		log.Printf("Gemini API call failed, falling back to mock: %v", err)
+		logging.FromContext(ctx).Warn("Gemini API call failed, falling back to mock", "error", err)
 		return g.mockRecommendations(req), nil
 	}
 
 	// Parse recommendations from response
-	recommendations := g.parseRecommendationsResponse(response, req)
+	recommendations = g.parseRecommendationsResponse(ctx, response, req)
+	cache.SetJSON(ctx, g.cache, cacheKey, recommendations, recommendationsCacheTTL)
 	return recommendations, nil
 }
 
@@ -147,9 +243,9 @@ func (g *GeminiService) GetActivitySuggestions(ctx context.Context, destination
 	}
 
 	prompt := g.buildActivityPrompt(destination, interests)
-	response, err := g.callGeminiAPI(ctx, prompt)
+	response, err := g.callGeminiAPI(ctx, prompt, GeminiCallOptions{UseCase: GeminiCreative})
 	if err != nil {
-		log.Printf("Gemini API call failed, falling back to mock: %v", err)
+		logging.FromContext(ctx).Warn("Gemini API call failed, falling back to mock", "error", err, "destination", destination)
 		return g.mockActivitySuggestions(destination, interests), nil
 	}
 
@@ -158,10 +254,31 @@ func (g *GeminiService) GetActivitySuggestions(ctx context.Context, destination
 	return activities, nil
 }
 
-// callGeminiAPI makes a request to the Gemini API
-func (g *GeminiService) callGeminiAPI(ctx context.Context, prompt string) (string, error) {
-	url := fmt.Sprintf("%s/models/gemini-pro:generateContent?key=%s", g.baseURL, g.apiKey)
+// GenerateText returns Gemini's response to a free-form prompt
+func (g *GeminiService) GenerateText(ctx context.Context, prompt string) (string, error) {
+	return g.GenerateTextWithOptions(ctx, prompt, GeminiCallOptions{UseCase: GeminiGeneral})
+}
 
+// GenerateTextWithOptions is GenerateText with the use case's generation
+// settings, or any of them individually, overridden by opts
+func (g *GeminiService) GenerateTextWithOptions(ctx context.Context, prompt string, opts GeminiCallOptions) (string, error) {
+	if g.apiKey == "" {
+		return "", ErrAIUnavailable
+	}
+	return g.callGeminiAPI(ctx, prompt, opts)
+}
+
+// callGeminiAPI makes a request to the Gemini API with the generation and
+// safety settings of opts. With a schema, Gemini is asked for JSON matching
+// it; models that don't support schemas get the plain prompt instead, and
+// the caller parses the text as before.
+func (g *GeminiService) callGeminiAPI(ctx context.Context, prompt string, opts GeminiCallOptions) (string, error) {
+	schema := opts.Schema
+	if schema != nil && !g.schemaSupported(g.geminiModel()) {
+		schema = nil
+	}
+
+	generationConfig, safetySettings := g.generationSettings(opts)
 	request := GeminiRequest{
 		Contents: []GeminiContent{
 			{
@@ -170,7 +287,59 @@ func (g *GeminiService) callGeminiAPI(ctx context.Context, prompt string) (strin
 				},
 			},
 		},
+		GenerationConfig: generationConfig,
+		SafetySettings:   safetySettings,
+	}
+	if schema != nil {
+		request.GenerationConfig.ResponseMimeType = "application/json"
+		request.GenerationConfig.ResponseSchema = schema
+	}
+
+	text, err := g.generateContent(ctx, request)
+	if schema != nil && errors.Is(err, errSchemaUnsupported) {
+		logging.FromContext(ctx).Error("Gemini model rejected the response schema; its responses will be parsed from free text", "model", g.geminiModel(), "error", err)
+		g.schemaRejected.Store(g.geminiModel(), struct{}{})
+		request.GenerationConfig.ResponseMimeType = ""
+		request.GenerationConfig.ResponseSchema = nil
+		return g.generateContent(ctx, request)
+	}
+	return text, err
+}
+
+// geminiModel is the model requests are sent to
+func (g *GeminiService) geminiModel() string {
+	if g.model == "" {
+		return defaultGeminiModel
 	}
+	return g.model
+}
+
+// schemaSupported reports whether requests to model may carry a response
+// schema: the model must be new enough and must not have rejected one before
+func (g *GeminiService) schemaSupported(model string) bool {
+	if !modelSupportsSchema(model) {
+		return false
+	}
+	_, rejected := g.schemaRejected.Load(model)
+	return !rejected
+}
+
+// generateContent sends one generateContent request and returns the text of
+// the first candidate
+func (g *GeminiService) generateContent(ctx context.Context, request GeminiRequest) (text string, err error) {
+	model := g.geminiModel()
+	ctx, span := tracing.Start(ctx, "gemini.generateContent", tracing.KindClient,
+		tracing.Attr("peer.service", "gemini"),
+		tracing.Attr("gen_ai.request.model", model),
+	)
+	start := time.Now()
+	defer func() {
+		span.EndWithError(err)
+		metrics.GeminiCallsTotal.WithLabelValues(model, metrics.Outcome(err)).Inc()
+		metrics.ObserveExternalCall("gemini", start, err)
+	}()
+
+	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", g.baseURL, model, g.apiKey)
 
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -189,9 +358,13 @@ func (g *GeminiService) callGeminiAPI(ctx context.Context, prompt string) (strin
 		return "", fmt.Errorf("failed to make request: %v", err)
 	}
 	defer resp.Body.Close()
+	span.SetAttributes(tracing.Attr("http.response.status_code", resp.StatusCode))
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusBadRequest && request.GenerationConfig != nil && request.GenerationConfig.ResponseSchema != nil && isSchemaRejection(string(body)) {
+			return "", fmt.Errorf("%w: %s", errSchemaUnsupported, string(body))
+		}
 		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
@@ -214,12 +387,13 @@ func (g *GeminiService) callGeminiAPI(ctx context.Context, prompt string) (strin
 
 // buildItineraryPrompt creates a prompt for basic itinerary generation
 func (g *GeminiService) buildItineraryPrompt(req ItineraryRequest) string {
-	days := g.calculateDays(req.StartDate, req.EndDate)
-	preferences := ""
-	for key, value := range req.Preferences {
-		preferences += fmt.Sprintf("%s: %v, ", key, value)
+	if len(req.Destinations) > 0 {
+		return g.buildMultiCityPrompt(req, nil)
 	}
 
+	days := g.calculateDays(req.StartDate, req.EndDate)
+	destination := userInput("destination", req.Destination)
+
 	return fmt.Sprintf(`Generate a detailed %d-day travel itinerary for %s with the following requirements:
 - Destination: %s
 - Budget: $%.2f
@@ -234,11 +408,16 @@ Please provide a day-by-day breakdown with morning, afternoon, and evening activ
 - Practical tips for travelers
 
 Format the response as a structured JSON with clear day-by-day organization.`,
-		days, req.Destination, req.Destination, req.Budget, req.Travelers, preferences)
+		days, destination, destination, req.Budget, req.Travelers, preferencesInput(req.Preferences)) +
+		buildPacePromptSection(req.Preferences) + buildGroupPromptSection(req.TravelerProfiles) + userInputNotice
 }
 
 // buildRAGItineraryPrompt creates a prompt for RAG-enhanced itinerary generation
 func (g *GeminiService) buildRAGItineraryPrompt(req ItineraryRequest, ragContext TripContext) string {
+	if len(req.Destinations) > 0 {
+		return g.buildMultiCityPrompt(req, &ragContext)
+	}
+
 	days := g.calculateDays(req.StartDate, req.EndDate)
 
 	// Include real-time context in prompt
@@ -273,13 +452,12 @@ Use the provided real-time data to create a personalized, accurate itinerary. In
 - Local events and cultural experiences
 
 Format as structured JSON with day-by-day breakdown and real-time validation.`,
-		days, req.Destination, contextInfo, req.Budget, req.Travelers, req.StartDate, req.EndDate)
+		days, userInput("destination", req.Destination), contextInfo, req.Budget, req.Travelers, req.StartDate, req.EndDate) +
+		buildPacePromptSection(req.Preferences) + buildGroupPromptSection(req.TravelerProfiles) + userInputNotice
 }
 
 // buildRecommendationPrompt creates a prompt for destination recommendations
 func (g *GeminiService) buildRecommendationPrompt(req RecommendationRequest) string {
-	interests := strings.Join(req.Interests, ", ")
-
 	return fmt.Sprintf(`Recommend 5 travel destinations based on:
 - Budget: $%.2f
 - Interests: %s
@@ -292,13 +470,11 @@ For each destination, provide:
 - Top 3 must-see attractions
 - Cultural highlights
 
-Format as JSON array with structured destination objects.`, req.Budget, interests)
+Format as JSON array with structured destination objects.`, req.Budget, userInputList("interests", req.Interests)) + userInputNotice
 }
 
 // buildActivityPrompt creates a prompt for activity suggestions
 func (g *GeminiService) buildActivityPrompt(destination string, interests []string) string {
-	interestList := strings.Join(interests, ", ")
-
 	return fmt.Sprintf(`Suggest 10 specific activities in %s for travelers interested in: %s
 
 Include:
@@ -308,48 +484,56 @@ Include:
 - Best time of day/season
 - Difficulty level or requirements
 
-Format as a simple list of activity descriptions.`, destination, interestList)
+Format as a simple list of activity descriptions.`, userInput("destination", destination), userInputList("interests", interests)) + userInputNotice
 }
 
 // parseItineraryResponse parses Gemini response into structured itinerary
-func (g *GeminiService) parseItineraryResponse(response string, req ItineraryRequest) map[string]interface{} {
-	// Try to parse JSON response, fallback to mock if parsing fails
-	var itinerary map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &itinerary); err != nil {
-		log.Printf("Failed to parse Gemini response as JSON, using enhanced mock: %v", err)
-		return g.enhanceItineraryWithAI(g.mockItinerary(req), response)
+func (g *GeminiService) parseItineraryResponse(ctx context.Context, response string, req ItineraryRequest) map[string]interface{} {
+	// Schema-conforming responses map straight to days; otherwise try the
+	// text as JSON, falling back to mock if parsing fails
+	itinerary, err := itineraryFromStructured(response)
+	if err != nil {
+		if err := json.Unmarshal([]byte(response), &itinerary); err != nil {
+			logging.FromContext(ctx).Warn("Failed to parse Gemini response as JSON, using enhanced mock", "error", err, "destination", req.Destination)
+			return g.enhanceItineraryWithAI(g.mockItinerary(req), response)
+		}
 	}
 
 	// Enhance with standard fields
 	itinerary["ai_generated"] = true
-	itinerary["created_at"] = time.Now().Format(time.RFC3339)
+	itinerary["created_at"] = g.clock().Format(time.RFC3339)
+	addGroupAlternatives(itinerary, req.TravelerProfiles, req.Destination, nil)
 
 	return itinerary
 }
 
 // parseRAGItineraryResponse parses RAG-enhanced response
-func (g *GeminiService) parseRAGItineraryResponse(response string, req ItineraryRequest, ragContext TripContext) map[string]interface{} {
-	// Try to parse JSON response
-	var itinerary map[string]interface{}
-	if err := json.Unmarshal([]byte(response), &itinerary); err != nil {
-		log.Printf("Failed to parse RAG response as JSON, using enhanced mock: %v", err)
-		baseItinerary := g.mockItineraryWithRAG(req, ragContext)
-		return g.enhanceItineraryWithAI(baseItinerary, response)
+func (g *GeminiService) parseRAGItineraryResponse(ctx context.Context, response string, req ItineraryRequest, ragContext TripContext) map[string]interface{} {
+	// Schema-conforming responses map straight to days; otherwise try the
+	// text as JSON
+	itinerary, err := itineraryFromStructured(response)
+	if err != nil {
+		if err := json.Unmarshal([]byte(response), &itinerary); err != nil {
+			logging.FromContext(ctx).Warn("Failed to parse RAG response as JSON, using enhanced mock", "error", err, "destination", req.Destination)
+			baseItinerary := g.mockItineraryWithRAG(req, ragContext)
+			return g.enhanceItineraryWithAI(baseItinerary, response)
+		}
 	}
 
 	// Enhance with RAG context
 	itinerary["rag_enhanced"] = true
 	itinerary["ai_generated"] = true
-	itinerary["created_at"] = time.Now().Format(time.RFC3339)
+	itinerary["created_at"] = g.clock().Format(time.RFC3339)
+	addGroupAlternatives(itinerary, req.TravelerProfiles, req.Destination, ragContext.attractionsByCity())
 
 	return itinerary
 }
 
 // parseRecommendationsResponse parses recommendations from AI response
-func (g *GeminiService) parseRecommendationsResponse(response string, req RecommendationRequest) []map[string]interface{} {
+func (g *GeminiService) parseRecommendationsResponse(ctx context.Context, response string, req RecommendationRequest) []map[string]interface{} {
 	var recommendations []map[string]interface{}
 	if err := json.Unmarshal([]byte(response), &recommendations); err != nil {
-		log.Printf("Failed to parse recommendations as JSON, using mock: %v", err)
+		logging.FromContext(ctx).Warn("Failed to parse recommendations as JSON, using mock", "error", err)
 		return g.mockRecommendations(req)
 	}
 
@@ -405,7 +589,11 @@ func (g *GeminiService) enhanceItineraryWithAI(baseItinerary map[string]interfac
 // Mock implementations
 
 func (g *GeminiService) mockItinerary(req ItineraryRequest) map[string]interface{} {
-	return map[string]interface{}{
+	if len(req.Destinations) > 0 {
+		return g.mockMultiCityItinerary(req, nil)
+	}
+
+	itinerary := map[string]interface{}{
 		"destination": req.Destination,
 		"duration":    g.calculateDays(req.StartDate, req.EndDate),
 		"budget":      req.Budget,
@@ -432,8 +620,11 @@ func (g *GeminiService) mockItinerary(req ItineraryRequest) map[string]interface
 			"Keep important documents safe",
 		},
 		"ai_generated": true,
-		"created_at":   time.Now().Format(time.RFC3339),
+		"created_at":   g.clock().Format(time.RFC3339),
 	}
+
+	addGroupAlternatives(itinerary, req.TravelerProfiles, req.Destination, nil)
+	return itinerary
 }
 
 func (g *GeminiService) mockRecommendations(req RecommendationRequest) []map[string]interface{} {
@@ -491,10 +682,19 @@ func (g *GeminiService) mockRecommendations(req RecommendationRequest) []map[str
 		},
 	}
 
+	if rng := g.mockRand(); rng != nil {
+		rng.Shuffle(len(recommendations), func(i, j int) {
+			recommendations[i], recommendations[j] = recommendations[j], recommendations[i]
+		})
+	}
 	return recommendations
 }
 
 func (g *GeminiService) mockItineraryWithRAG(req ItineraryRequest, ragContext TripContext) map[string]interface{} {
+	if len(req.Destinations) > 0 {
+		return g.mockMultiCityItinerary(req, &ragContext)
+	}
+
 	itinerary := map[string]interface{}{
 		"destination":  req.Destination,
 		"duration":     g.calculateDays(req.StartDate, req.EndDate),
@@ -502,7 +702,7 @@ func (g *GeminiService) mockItineraryWithRAG(req ItineraryRequest, ragContext Tr
 		"travelers":    req.Travelers,
 		"ai_generated": true,
 		"rag_enhanced": true,
-		"created_at":   time.Now().Format(time.RFC3339),
+		"created_at":   g.clock().Format(time.RFC3339),
 	}
 
 	// Add real-time weather context
@@ -585,41 +785,84 @@ func (g *GeminiService) mockItineraryWithRAG(req ItineraryRequest, ragContext Tr
 	// Generate contextual tips based on real data
 	itinerary["tips"] = g.generateContextualTips(ragContext, req)
 
+	addGroupAlternatives(itinerary, req.TravelerProfiles, req.Destination, ragContext.attractionsByCity())
 	return itinerary
 }
 
+// buildDayPlan fills a day with the next retrieved attractions, as many as
+// the pace preference allows, each at the time of day that suits it in the
+// day's weather. Attractions best seen in the evening, like sunset
+// viewpoints, are kept for the evenings, one a day.
 func (g *GeminiService) buildDayPlan(day int, ragContext TripContext, preferences map[string]interface{}) map[string]interface{} {
-	dayPlan := map[string]interface{}{}
+	daytime, evening := g.splitEveningAttractions(ragContext.Attractions)
 
-	// Distribute attractions across time slots
-	attractionsPerDay := 2
+	attractionsPerDay := ActivitiesPerDay(tripPace(preferences))
 	startIdx := (day - 1) * attractionsPerDay
-	endIdx := min(startIdx+attractionsPerDay, len(ragContext.Attractions))
-
-	if startIdx < len(ragContext.Attractions) {
-		dayAttractions := ragContext.Attractions[startIdx:endIdx]
+	endIdx := min(startIdx+attractionsPerDay, len(daytime))
+
+	var dayAttractions []Attraction
+	if startIdx < len(daytime) {
+		dayAttractions = daytime[startIdx:endIdx]
+	}
+	var eveningAttraction *Attraction
+	if day-1 < len(evening) {
+		eveningAttraction = &evening[day-1]
+	}
+	return g.layoutDay(dayAttractions, eveningAttraction, dayWeather(ragContext, day), preferences)
+}
+
+// layoutDay spreads attractions over a day's slots at the pace preference,
+// placing each where it suits the time of day and is open, and dropping
+// any beyond what the pace allows. A slot with one attraction holds a
+// string; a packed slot holds a list, each with its start time. The evening
+// goes to the evening attraction when there is one.
+func (g *GeminiService) layoutDay(dayAttractions []Attraction, evening *Attraction, weather *WeatherCondition, preferences map[string]interface{}) map[string]interface{} {
+	pace := tripPace(preferences)
+	stops := paceStops[pace]
+	dayPlan := map[string]interface{}{"pace": pace}
+
+	slotted := g.arrangeBySlot(dayAttractions, stops, weather)
+	if len(slotted) > 0 {
+		var slotOrder []string
+		bySlot := make(map[string][]slottedAttraction)
+		for _, placed := range slotted {
+			slot := stops[placed.stop].slot
+			if _, ok := bySlot[slot]; !ok {
+				slotOrder = append(slotOrder, slot)
+			}
+			bySlot[slot] = append(bySlot[slot], placed)
+		}
 
-		if len(dayAttractions) > 0 {
-			dayPlan["morning"] = fmt.Sprintf("Visit %s - %s",
-				dayAttractions[0].Name,
-				dayAttractions[0].Description)
+		for _, slot := range slotOrder {
+			verb := "Visit"
+			if slot == "afternoon" {
+				verb = "Explore"
+			}
+			placed := bySlot[slot]
+			if len(placed) == 1 {
+				dayPlan[slot] = fmt.Sprintf("%s %s - %s", verb, placed[0].attraction.Name, placed[0].attraction.Description)
+				continue
+			}
+			timed := make([]interface{}, len(placed))
+			for i, p := range placed {
+				timed[i] = fmt.Sprintf("%s %s %s - %s", stops[p.stop].start, verb, p.attraction.Name, p.attraction.Description)
+			}
+			dayPlan[slot] = timed
 		}
 
-		if len(dayAttractions) > 1 {
-			dayPlan["afternoon"] = fmt.Sprintf("Explore %s - %s",
-				dayAttractions[1].Name,
-				dayAttractions[1].Description)
+		if len(slotted) > 2 {
+			dayPlan["lunch"] = "Lunch break, 12:30-14:00"
 		}
 	}
 
-	// Add evening recommendations based on day
-	switch day % 3 {
-	case 1:
-		dayPlan["evening"] = "Welcome dinner at local restaurant, orientation walk"
-	case 2:
-		dayPlan["evening"] = "Cultural performance or local entertainment"
-	case 0:
-		dayPlan["evening"] = "Sunset viewing and leisure dining"
+	if evening != nil {
+		verb := "Evening at"
+		if AttractionCategory(*evening) == CategoryViewpoint {
+			verb = "Sunset at"
+		}
+		dayPlan["evening"] = fmt.Sprintf("%s %s %s - %s", eveningStop.start, verb, evening.Name, evening.Description)
+	} else {
+		dayPlan["evening"] = "Dinner at a local restaurant and a relaxed evening"
 	}
 
 	return dayPlan
@@ -740,6 +983,11 @@ func (g *GeminiService) mockActivitySuggestions(destination string, interests []
 		}
 	}
 
+	if rng := g.mockRand(); rng != nil {
+		rng.Shuffle(len(baseActivities), func(i, j int) {
+			baseActivities[i], baseActivities[j] = baseActivities[j], baseActivities[i]
+		})
+	}
 	return baseActivities
 }
 
@@ -749,8 +997,14 @@ func (g *GeminiService) calculateDays(startDate, endDate string) int {
 	return int(end.Sub(start).Hours()/24) + 1
 }
 
+// HasAPIKey reports whether real Gemini calls are made; without a key every
+// method returns mock data
+func (g *GeminiService) HasAPIKey() bool {
+	return g.apiKey != ""
+}
+
 // Shutdown closes the Gemini service
 func (g *GeminiService) Shutdown(ctx context.Context) error {
-	log.Println("Gemini service shut down successfully")
+	logging.FromContext(ctx).Info("Gemini service shut down successfully")
 	return nil
 }
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"log/slog"
+)
+
+// GeminiUseCase picks the generation settings a Gemini call starts from
+type GeminiUseCase string
+
+const (
+	// GeminiStructured is for JSON the app parses, like itineraries, where
+	// a low temperature keeps the output to the requested shape
+	GeminiStructured GeminiUseCase = "structured"
+	// GeminiCreative is for suggestions, where variety is the point
+	GeminiCreative GeminiUseCase = "creative"
+	// GeminiGeneral is for free text
+	GeminiGeneral GeminiUseCase = "general"
+)
+
+// GeminiSafetySetting sets how readily Gemini blocks a category of harm
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// geminiHarmCategories are the categories safety settings are sent for
+var geminiHarmCategories = []string{
+	"HARM_CATEGORY_HARASSMENT",
+	"HARM_CATEGORY_HATE_SPEECH",
+	"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+	"HARM_CATEGORY_DANGEROUS_CONTENT",
+}
+
+// geminiSafetyThresholds are the block thresholds Gemini accepts
+var geminiSafetyThresholds = map[string]bool{
+	"BLOCK_NONE":             true,
+	"BLOCK_ONLY_HIGH":        true,
+	"BLOCK_MEDIUM_AND_ABOVE": true,
+	"BLOCK_LOW_AND_ABOVE":    true,
+}
+
+// Generation settings used when config has none
+const (
+	defaultGeminiTopP            = 0.95
+	defaultGeminiMaxOutputTokens = 8192
+	defaultGeminiSafetyThreshold = "BLOCK_MEDIUM_AND_ABOVE"
+)
+
+// defaultGeminiTemperatures are the temperatures of each use case when
+// config has none
+var defaultGeminiTemperatures = map[GeminiUseCase]float64{
+	GeminiStructured: 0.2,
+	GeminiCreative:   0.9,
+	GeminiGeneral:    0.7,
+}
+
+// GeminiCallOptions configures one Gemini call. Settings left zero come
+// from the use case's configured defaults.
+type GeminiCallOptions struct {
+	UseCase GeminiUseCase
+	// Schema asks for JSON matching it, on models that support schemas
+	Schema *GeminiSchema
+
+	Temperature     *float64
+	TopP            *float64
+	MaxOutputTokens int
+	SafetySettings  []GeminiSafetySetting
+}
+
+// generationSettings resolves a call's options against the configured
+// defaults for its use case
+func (g *GeminiService) generationSettings(opts GeminiCallOptions) (*GeminiGenerationConfig, []GeminiSafetySetting) {
+	useCase := opts.UseCase
+	if _, ok := defaultGeminiTemperatures[useCase]; !ok {
+		useCase = GeminiGeneral
+	}
+
+	temperature := defaultGeminiTemperatures[useCase]
+	topP := defaultGeminiTopP
+	maxOutputTokens := defaultGeminiMaxOutputTokens
+	threshold := defaultGeminiSafetyThreshold
+	if g.cfg != nil {
+		switch useCase {
+		case GeminiStructured:
+			temperature = g.cfg.GeminiStructuredTemperature
+		case GeminiCreative:
+			temperature = g.cfg.GeminiCreativeTemperature
+		default:
+			temperature = g.cfg.GeminiGeneralTemperature
+		}
+		if g.cfg.GeminiTopP > 0 {
+			topP = g.cfg.GeminiTopP
+		}
+		if g.cfg.GeminiMaxOutputTokens > 0 {
+			maxOutputTokens = g.cfg.GeminiMaxOutputTokens
+		}
+		if geminiSafetyThresholds[g.cfg.GeminiSafetyThreshold] {
+			threshold = g.cfg.GeminiSafetyThreshold
+		} else if g.cfg.GeminiSafetyThreshold != "" {
+			slog.Warn("Unknown GEMINI_SAFETY_THRESHOLD, using the default", "threshold", g.cfg.GeminiSafetyThreshold, "default", defaultGeminiSafetyThreshold)
+		}
+	}
+
+	if opts.Temperature != nil {
+		temperature = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		topP = *opts.TopP
+	}
+	if opts.MaxOutputTokens > 0 {
+		maxOutputTokens = opts.MaxOutputTokens
+	}
+
+	safety := opts.SafetySettings
+	if safety == nil {
+		safety = make([]GeminiSafetySetting, len(geminiHarmCategories))
+		for i, category := range geminiHarmCategories {
+			safety[i] = GeminiSafetySetting{Category: category, Threshold: threshold}
+		}
+	}
+
+	return &GeminiGenerationConfig{
+		Temperature:     &temperature,
+		TopP:            &topP,
+		MaxOutputTokens: maxOutputTokens,
+	}, safety
+}
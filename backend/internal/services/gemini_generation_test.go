@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auratravel-backend/internal/config"
+)
+
+func TestGenerationSettings(t *testing.T) {
+	cfg := &config.Config{
+		GeminiStructuredTemperature: 0.1,
+		GeminiCreativeTemperature:   1.0,
+		GeminiGeneralTemperature:    0.5,
+		GeminiTopP:                  0.8,
+		GeminiMaxOutputTokens:       2048,
+		GeminiSafetyThreshold:       "BLOCK_ONLY_HIGH",
+	}
+	zero := 0.0
+	custom := []GeminiSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_NONE"}}
+
+	tests := []struct {
+		name          string
+		cfg           *config.Config
+		opts          GeminiCallOptions
+		want          string
+		wantThreshold string
+		wantSettings  int
+	}{
+		{"structured defaults", nil, GeminiCallOptions{UseCase: GeminiStructured}, "0.20/0.95/8192", "BLOCK_MEDIUM_AND_ABOVE", 4},
+		{"creative defaults", nil, GeminiCallOptions{UseCase: GeminiCreative}, "0.90/0.95/8192", "BLOCK_MEDIUM_AND_ABOVE", 4},
+		{"unknown use case is general", nil, GeminiCallOptions{UseCase: "poetry"}, "0.70/0.95/8192", "BLOCK_MEDIUM_AND_ABOVE", 4},
+		{"configured", cfg, GeminiCallOptions{UseCase: GeminiCreative}, "1.00/0.80/2048", "BLOCK_ONLY_HIGH", 4},
+		{"overridden per call", cfg, GeminiCallOptions{UseCase: GeminiStructured, Temperature: &zero, MaxOutputTokens: 100}, "0.00/0.80/100", "BLOCK_ONLY_HIGH", 4},
+		{"unknown threshold ignored", &config.Config{GeminiSafetyThreshold: "BLOCK_EVERYTHING"}, GeminiCallOptions{}, "0.00/0.95/8192", "BLOCK_MEDIUM_AND_ABOVE", 4},
+		{"custom safety settings", nil, GeminiCallOptions{SafetySettings: custom}, "0.70/0.95/8192", "BLOCK_NONE", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generation, safety := (&GeminiService{cfg: tt.cfg}).generationSettings(tt.opts)
+			if got := fmt.Sprintf("%.2f/%.2f/%d", *generation.Temperature, *generation.TopP, generation.MaxOutputTokens); got != tt.want {
+				t.Errorf("temperature/top-p/max tokens = %s, want %s", got, tt.want)
+			}
+			if len(safety) != tt.wantSettings || safety[0].Threshold != tt.wantThreshold {
+				t.Errorf("safety settings = %+v, want %d at %s", safety, tt.wantSettings, tt.wantThreshold)
+			}
+		})
+	}
+}
+
+func TestCallGeminiAPISendsGenerationSettings(t *testing.T) {
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"ok"}]}}]}`)
+	}))
+	defer server.Close()
+
+	g := &GeminiService{apiKey: "key", httpClient: server.Client(), baseURL: server.URL}
+	if _, err := g.GenerateTextWithOptions(context.Background(), "hello", GeminiCallOptions{UseCase: GeminiCreative}); err != nil {
+		t.Fatal(err)
+	}
+
+	generation, _ := request["generationConfig"].(map[string]interface{})
+	safety, _ := request["safetySettings"].([]interface{})
+	if generation["temperature"] != 0.9 || generation["topP"] != 0.95 || len(safety) != len(geminiHarmCategories) {
+		t.Errorf("request = %v, want creative settings and a safety setting per category", request)
+	}
+}
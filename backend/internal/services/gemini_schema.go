@@ -0,0 +1,161 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GeminiSchema describes the JSON a structured Gemini response must match,
+// in the OpenAPI subset the API accepts
+type GeminiSchema struct {
+	Type        string                   `json:"type"`
+	Description string                   `json:"description,omitempty"`
+	Properties  map[string]*GeminiSchema `json:"properties,omitempty"`
+	Items       *GeminiSchema            `json:"items,omitempty"`
+	Required    []string                 `json:"required,omitempty"`
+	Enum        []string                 `json:"enum,omitempty"`
+}
+
+// GeminiGenerationConfig tunes how Gemini generates a response. With
+// ResponseSchema set it answers in JSON matching the schema.
+type GeminiGenerationConfig struct {
+	Temperature      *float64      `json:"temperature,omitempty"`
+	TopP             *float64      `json:"topP,omitempty"`
+	MaxOutputTokens  int           `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string        `json:"responseMimeType,omitempty"`
+	ResponseSchema   *GeminiSchema `json:"responseSchema,omitempty"`
+}
+
+// Gemini schema types
+const (
+	schemaObject  = "OBJECT"
+	schemaArray   = "ARRAY"
+	schemaString  = "STRING"
+	schemaNumber  = "NUMBER"
+	schemaInteger = "INTEGER"
+)
+
+// errSchemaUnsupported is returned when the model rejects a response schema
+var errSchemaUnsupported = errors.New("model does not support response schemas")
+
+// schemaUnsupportedModels are model name prefixes that predate structured
+// output; their requests are sent as plain text prompts
+var schemaUnsupportedModels = []string{"gemini-pro", "gemini-1.0"}
+
+// modelSupportsSchema reports whether a model accepts a response schema
+func modelSupportsSchema(model string) bool {
+	for _, prefix := range schemaUnsupportedModels {
+		if model == prefix || strings.HasPrefix(model, prefix+"-") {
+			return false
+		}
+	}
+	return true
+}
+
+// isSchemaRejection reports whether an error response body rejects the
+// request's response schema or MIME type
+func isSchemaRejection(body string) bool {
+	body = strings.ToLower(body)
+	for _, field := range []string{"response_schema", "responseschema", "response_mime_type", "responsemimetype"} {
+		if strings.Contains(body, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// itineraryActivitySchema is one activity of a day's slot
+var itineraryActivitySchema = &GeminiSchema{
+	Type: schemaObject,
+	Properties: map[string]*GeminiSchema{
+		"name":             {Type: schemaString},
+		"description":      {Type: schemaString},
+		"type":             {Type: schemaString, Description: "sightseeing, museum, food, adventure, cultural, shopping or relaxation"},
+		"location":         {Type: schemaString},
+		"time":             {Type: schemaString, Description: "start time as HH:MM"},
+		"duration_minutes": {Type: schemaInteger},
+		"estimated_cost":   {Type: schemaNumber, Description: "per traveler, in USD"},
+		"tips":             {Type: schemaArray, Items: &GeminiSchema{Type: schemaString}},
+	},
+	Required: []string{"name", "description"},
+}
+
+// itinerarySchema is the shape of a generated itinerary: one entry per day
+// with activities for each part of it
+var itinerarySchema = &GeminiSchema{
+	Type: schemaObject,
+	Properties: map[string]*GeminiSchema{
+		"title":   {Type: schemaString},
+		"summary": {Type: schemaString},
+		"days": {
+			Type: schemaArray,
+			Items: &GeminiSchema{
+				Type: schemaObject,
+				Properties: map[string]*GeminiSchema{
+					"day":       {Type: schemaInteger, Description: "day number, starting at 1"},
+					"title":     {Type: schemaString},
+					"city":      {Type: schemaString, Description: "city of a multi-city trip the day is spent in"},
+					"morning":   {Type: schemaArray, Items: itineraryActivitySchema},
+					"afternoon": {Type: schemaArray, Items: itineraryActivitySchema},
+					"evening":   {Type: schemaArray, Items: itineraryActivitySchema},
+				},
+				Required: []string{"day", "morning", "afternoon", "evening"},
+			},
+		},
+		"tips":                 {Type: schemaArray, Items: &GeminiSchema{Type: schemaString}},
+		"estimated_total_cost": {Type: schemaNumber, Description: "for all travelers, in USD"},
+	},
+	Required: []string{"days"},
+}
+
+// structuredItinerary is a response matching itinerarySchema
+type structuredItinerary struct {
+	Title              string                   `json:"title,omitempty"`
+	Summary            string                   `json:"summary,omitempty"`
+	Days               []map[string]interface{} `json:"days"`
+	Tips               []string                 `json:"tips,omitempty"`
+	EstimatedTotalCost float64                  `json:"estimated_total_cost,omitempty"`
+}
+
+// itineraryFromStructured converts a response matching itinerarySchema to
+// the "day_N" itinerary shape the rest of the app reads
+func itineraryFromStructured(response string) (map[string]interface{}, error) {
+	var structured structuredItinerary
+	if err := json.Unmarshal([]byte(strings.TrimSpace(response)), &structured); err != nil {
+		return nil, err
+	}
+	if len(structured.Days) == 0 {
+		return nil, errors.New("structured itinerary has no days")
+	}
+
+	itinerary := make(map[string]interface{})
+	for i, day := range structured.Days {
+		number := i + 1
+		if n, ok := day["day"].(float64); ok && n >= 1 {
+			number = int(n)
+		}
+		key := fmt.Sprintf("day_%d", number)
+		if _, exists := itinerary[key]; exists {
+			return nil, fmt.Errorf("structured itinerary has day %d twice", number)
+		}
+		delete(day, "day")
+		itinerary[key] = day
+	}
+
+	if structured.Title != "" {
+		itinerary["title"] = structured.Title
+	}
+	if structured.Summary != "" {
+		itinerary["summary"] = structured.Summary
+	}
+	if len(structured.Tips) > 0 {
+		itinerary["tips"] = structured.Tips
+	}
+	if structured.EstimatedTotalCost > 0 {
+		itinerary["estimated_total_cost"] = structured.EstimatedTotalCost
+	}
+	itinerary["duration"] = len(structured.Days)
+	return itinerary, nil
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestModelSupportsSchema(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"gemini-pro", false},
+		{"gemini-pro-vision", false},
+		{"gemini-1.0-pro", false},
+		{"gemini-1.5-pro", true},
+		{"gemini-2.0-flash", true},
+		{defaultGeminiModel, true},
+	}
+	for _, tt := range tests {
+		if got := modelSupportsSchema(tt.model); got != tt.want {
+			t.Errorf("modelSupportsSchema(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestSchemaRejectionIsTrackedPerModel(t *testing.T) {
+	var schemaRequests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/models/"), ":generateContent")
+		var request GeminiRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if request.GenerationConfig != nil && request.GenerationConfig.ResponseSchema != nil {
+			schemaRequests = append(schemaRequests, model)
+			if model == "gemini-tuned" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":{"message":"Unknown name \"response_schema\""}}`)
+				return
+			}
+		}
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"{}"}]}}]}`)
+	}))
+	defer server.Close()
+
+	g := &GeminiService{apiKey: "key", httpClient: server.Client(), baseURL: server.URL}
+	opts := GeminiCallOptions{UseCase: GeminiStructured, Schema: itinerarySchema}
+	call := func(model string) {
+		t.Helper()
+		g.model = model
+		if _, err := g.callGeminiAPI(context.Background(), "plan", opts); err != nil {
+			t.Fatalf("callGeminiAPI(%s): %v", model, err)
+		}
+	}
+
+	call("gemini-tuned")
+	call("gemini-tuned")
+	call(defaultGeminiModel)
+
+	want := []string{"gemini-tuned", defaultGeminiModel}
+	if strings.Join(schemaRequests, ",") != strings.Join(want, ",") {
+		t.Errorf("requests with a schema = %v, want %v", schemaRequests, want)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"auratravel-backend/internal/httpclient"
 )
 
 // GeminiServiceSimple is a minimal interface used by some components/tests.
@@ -34,7 +36,7 @@ func NewGeminiServiceSimple(apiKey, baseURL string) GeminiServiceSimple {
 	return &geminiClient{
 		apiKey:  apiKey,
 		baseURL: baseURL,
-		client:  &http.Client{Timeout: 20 * time.Second},
+		client:  httpclient.New(httpclient.Options{Timeout: 20 * time.Second}),
 	}
 }
 
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Generation strategy names, as listed in the configured order
+const (
+	StrategyRAG    = "rag"
+	StrategyGemini = "gemini"
+	StrategyMock   = "mock"
+)
+
+// DefaultGenerationOrder tries retrieved data first, then Gemini alone, then
+// a basic itinerary that can't fail
+var DefaultGenerationOrder = []string{StrategyRAG, StrategyGemini, StrategyMock}
+
+// GenerationRequest is a trip for a generation strategy to plan
+type GenerationRequest struct {
+	ItineraryRequest
+
+	UserID         string          `json:"user_id"`
+	Interests      []string        `json:"interests"`
+	TravelStyle    string          `json:"travel_style"`
+	RankingWeights *RankingWeights `json:"ranking_weights,omitempty"`
+	// Days is the length of the trip
+	Days int `json:"days"`
+}
+
+// GeneratedItinerary is a planned itinerary and the strategy that planned it
+type GeneratedItinerary struct {
+	Itinerary   map[string]interface{} `json:"itinerary"`
+	Suggestions []string               `json:"suggestions"`
+	Strategy    string                 `json:"strategy"`
+}
+
+// GenerationStrategy is one way of planning an itinerary
+type GenerationStrategy interface {
+	Name() string
+	Generate(ctx context.Context, req GenerationRequest) (*GeneratedItinerary, error)
+}
+
+// ItineraryGenerationPipeline plans itineraries with a list of strategies,
+// trying each in order until one succeeds
+type ItineraryGenerationPipeline struct {
+	strategies []GenerationStrategy
+}
+
+// NewItineraryGenerationPipeline creates a pipeline trying strategies in the
+// order given
+func NewItineraryGenerationPipeline(strategies ...GenerationStrategy) *ItineraryGenerationPipeline {
+	return &ItineraryGenerationPipeline{strategies: strategies}
+}
+
+// NewGenerationPipelineFromOrder creates a pipeline from strategy names.
+// Strategies whose services aren't available, and unknown names, are skipped.
+func NewGenerationPipelineFromOrder(order []string, retriever *RAGRetriever, gemini *GeminiService) *ItineraryGenerationPipeline {
+	var strategies []GenerationStrategy
+	for _, name := range order {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case StrategyRAG:
+			if retriever != nil && gemini != nil {
+				strategies = append(strategies, &RAGGenerationStrategy{retriever: retriever, gemini: gemini})
+			}
+		case StrategyGemini:
+			if gemini != nil {
+				strategies = append(strategies, &GeminiGenerationStrategy{gemini: gemini})
+			}
+		case StrategyMock:
+			strategies = append(strategies, MockGenerationStrategy{})
+		case "":
+		default:
+			log.Printf("Warning: Ignoring unknown generation strategy %q", name)
+		}
+	}
+	return NewItineraryGenerationPipeline(strategies...)
+}
+
+// Strategies returns the names of the strategies in the order they're tried
+func (p *ItineraryGenerationPipeline) Strategies() []string {
+	names := make([]string, len(p.strategies))
+	for i, strategy := range p.strategies {
+		names[i] = strategy.Name()
+	}
+	return names
+}
+
+// Generate plans the trip with the first strategy that succeeds, recording
+// its name in the itinerary's "generated_by". When every strategy fails, the
+// error lists why each did.
+func (p *ItineraryGenerationPipeline) Generate(ctx context.Context, req GenerationRequest) (*GeneratedItinerary, error) {
+	var failures []error
+	for _, strategy := range p.strategies {
+		result, err := strategy.Generate(ctx, req)
+		if err == nil && (result == nil || result.Itinerary == nil) {
+			err = errors.New("no itinerary generated")
+		}
+		if err != nil {
+			log.Printf("Generation strategy %s failed, trying the next: %v", strategy.Name(), err)
+			failures = append(failures, fmt.Errorf("%s: %w", strategy.Name(), err))
+			continue
+		}
+
+		result.Strategy = strategy.Name()
+		result.Itinerary["generated_by"] = strategy.Name()
+		if len(result.Suggestions) == 0 {
+			result.Suggestions = basicSuggestions(req.Destination)
+		}
+		return result, nil
+	}
+	if len(failures) == 0 {
+		return nil, newKindError(ErrUnavailable, "no itinerary generation strategies configured")
+	}
+	return nil, kindErrorf(ErrUnavailable, "all itinerary generation strategies failed: %w", errors.Join(failures...))
+}
+
+// RAGGenerationStrategy plans with Gemini from retrieved attractions,
+// weather, hotels and transport
+type RAGGenerationStrategy struct {
+	retriever *RAGRetriever
+	gemini    *GeminiService
+}
+
+func (s *RAGGenerationStrategy) Name() string { return StrategyRAG }
+
+func (s *RAGGenerationStrategy) Generate(ctx context.Context, req GenerationRequest) (*GeneratedItinerary, error) {
+	startDate, _ := time.Parse("2006-01-02", req.StartDate)
+	endDate, _ := time.Parse("2006-01-02", req.EndDate)
+	ragContext, err := s.retriever.RetrieveContext(ctx, RetrievalRequest{
+		UserID:         req.UserID,
+		Destination:    req.Destination,
+		StartDate:      startDate,
+		EndDate:        endDate,
+		Budget:         req.Budget,
+		Travelers:      req.Travelers,
+		Interests:      req.Interests,
+		Preferences:    req.Preferences,
+		TravelStyle:    req.TravelStyle,
+		RankingWeights: req.RankingWeights,
+		Destinations:   req.Destinations,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve context: %w", err)
+	}
+
+	itinerary, err := s.gemini.GenerateItineraryWithRAG(ctx, req.ItineraryRequest, *ragContext)
+	if err != nil {
+		return nil, err
+	}
+	itinerary["rag_enhanced"] = true
+	itinerary["data_sources"] = []string{"real_attractions", "weather_forecast", "hotel_availability", "transportation_options"}
+
+	// Suggest the retrieved attractions
+	var suggestions []string
+	for _, attraction := range ragContext.Attractions {
+		suggestions = append(suggestions, fmt.Sprintf("Visit %s - %s", attraction.Name, attraction.Description))
+	}
+	return &GeneratedItinerary{Itinerary: itinerary, Suggestions: suggestions}, nil
+}
+
+// GeminiGenerationStrategy plans with Gemini alone
+type GeminiGenerationStrategy struct {
+	gemini *GeminiService
+}
+
+func (s *GeminiGenerationStrategy) Name() string { return StrategyGemini }
+
+func (s *GeminiGenerationStrategy) Generate(ctx context.Context, req GenerationRequest) (*GeneratedItinerary, error) {
+	itinerary, err := s.gemini.GenerateItinerary(ctx, req.ItineraryRequest)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := s.gemini.GetActivitySuggestions(ctx, req.Destination, req.Interests)
+	if err != nil {
+		log.Printf("Failed to get activity suggestions: %v", err)
+	}
+	return &GeneratedItinerary{Itinerary: itinerary, Suggestions: suggestions}, nil
+}
+
+// MockGenerationStrategy lays out a basic itinerary without any AI. It never
+// fails, so it belongs last.
+type MockGenerationStrategy struct{}
+
+func (MockGenerationStrategy) Name() string { return StrategyMock }
+
+func (MockGenerationStrategy) Generate(ctx context.Context, req GenerationRequest) (*GeneratedItinerary, error) {
+	itinerary := make(map[string]interface{})
+	for i := 1; i <= req.Days; i++ {
+		itinerary[fmt.Sprintf("day_%d", i)] = map[string]interface{}{
+			"morning":   fmt.Sprintf("Explore %s attractions", req.Destination),
+			"afternoon": "Local dining and shopping",
+			"evening":   "Relaxation and local entertainment",
+		}
+	}
+	return &GeneratedItinerary{Itinerary: itinerary, Suggestions: basicSuggestions(req.Destination)}, nil
+}
+
+func basicSuggestions(destination string) []string {
+	return []string{
+		fmt.Sprintf("Visit popular attractions in %s", destination),
+		"Try local cuisine and street food",
+		"Take a guided city tour",
+		"Visit local markets and shops",
+		"Experience nightlife and entertainment",
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// stubStrategy returns a fixed itinerary or error, counting its calls
+type stubStrategy struct {
+	name      string
+	itinerary map[string]interface{}
+	err       error
+	calls     int
+}
+
+func (s *stubStrategy) Name() string { return s.name }
+
+func (s *stubStrategy) Generate(ctx context.Context, req GenerationRequest) (*GeneratedItinerary, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &GeneratedItinerary{Itinerary: s.itinerary}, nil
+}
+
+func TestItineraryGenerationPipeline(t *testing.T) {
+	succeeds := func(name string) *stubStrategy {
+		return &stubStrategy{name: name, itinerary: map[string]interface{}{"day_1": "Beach"}}
+	}
+	fails := func(name string) *stubStrategy {
+		return &stubStrategy{name: name, err: errors.New(name + " is down")}
+	}
+
+	tests := []struct {
+		name       string
+		strategies []*stubStrategy
+		want       string
+		wantCalls  string
+		wantErr    string
+	}{
+		{"first succeeds", []*stubStrategy{succeeds("rag"), succeeds("mock")}, "rag", "1,0", ""},
+		{"falls through failures", []*stubStrategy{fails("rag"), fails("gemini"), succeeds("mock")}, "mock", "1,1,1", ""},
+		{"nothing generated", []*stubStrategy{{name: "rag"}, succeeds("mock")}, "mock", "1,1", ""},
+		{"all fail", []*stubStrategy{fails("rag"), fails("gemini")}, "", "1,1", "rag: rag is down\ngemini: gemini is down"},
+		{"none configured", nil, "", "", "no itinerary generation strategies configured"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategies := make([]GenerationStrategy, len(tt.strategies))
+			for i, strategy := range tt.strategies {
+				strategies[i] = strategy
+			}
+			result, err := NewItineraryGenerationPipeline(strategies...).Generate(context.Background(), GenerationRequest{ItineraryRequest: ItineraryRequest{Destination: "Goa"}})
+
+			calls := make([]string, len(tt.strategies))
+			for i, strategy := range tt.strategies {
+				calls[i] = strconv.Itoa(strategy.calls)
+			}
+			if got := strings.Join(calls, ","); got != tt.wantCalls {
+				t.Errorf("calls = %s, want %s", got, tt.wantCalls)
+			}
+			if tt.wantErr != "" {
+				if !errors.Is(err, ErrUnavailable) || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Generate error = %v, want an unavailable error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Strategy != tt.want || result.Itinerary["generated_by"] != tt.want {
+				t.Errorf("generated by %s (%v), want %s", result.Strategy, result.Itinerary["generated_by"], tt.want)
+			}
+			if len(result.Suggestions) == 0 || !strings.Contains(result.Suggestions[0], "Goa") {
+				t.Errorf("suggestions = %q, want the basic ones for Goa", result.Suggestions)
+			}
+		})
+	}
+}
+
+func TestNewGenerationPipelineFromOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		order     []string
+		retriever *RAGRetriever
+		gemini    *GeminiService
+		want      string
+	}{
+		{"default order", DefaultGenerationOrder, &RAGRetriever{}, &GeminiService{}, "rag,gemini,mock"},
+		{"custom order", []string{" Mock ", "gemini"}, &RAGRetriever{}, &GeminiService{}, "mock,gemini"},
+		{"unknown and blank names skipped", []string{"rag", "", "llama"}, &RAGRetriever{}, &GeminiService{}, "rag"},
+		{"no retriever", DefaultGenerationOrder, nil, &GeminiService{}, "gemini,mock"},
+		{"no gemini", DefaultGenerationOrder, &RAGRetriever{}, nil, "mock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipeline := NewGenerationPipelineFromOrder(tt.order, tt.retriever, tt.gemini)
+			if got := strings.Join(pipeline.Strategies(), ","); got != tt.want {
+				t.Errorf("strategies = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMockGenerationStrategy(t *testing.T) {
+	result, err := MockGenerationStrategy{}.Generate(context.Background(), GenerationRequest{ItineraryRequest: ItineraryRequest{Destination: "Goa"}, Days: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Itinerary) != 3 || result.Itinerary["day_3"] == nil {
+		t.Errorf("itinerary = %v, want days 1 to 3", result.Itinerary)
+	}
+}
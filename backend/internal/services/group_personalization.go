@@ -0,0 +1,290 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Traveler age groups
+const (
+	AgeGroupChild  = "child"
+	AgeGroupTeen   = "teen"
+	AgeGroupAdult  = "adult"
+	AgeGroupSenior = "senior"
+)
+
+// Traveler mobility levels
+const (
+	MobilityFull       = "full"
+	MobilityLimited    = "limited"
+	MobilityWheelchair = "wheelchair"
+)
+
+// personalizedSlots are the day slots that get per-group alternatives; meals,
+// evenings and transport stay shared
+var personalizedSlots = []string{"morning", "afternoon"}
+
+// TravelerProfile describes one member of a group trip
+type TravelerProfile struct {
+	Name      string   `json:"name,omitempty"`
+	AgeGroup  string   `json:"age_group"` // child, teen, adult, senior
+	Interests []string `json:"interests"`
+	Mobility  string   `json:"mobility"` // full, limited, wheelchair
+}
+
+// TravelerGroup is a set of travelers whose profiles call for the same plan
+type TravelerGroup struct {
+	Label     string   `json:"label"`
+	Members   []string `json:"members"`
+	AgeGroup  string   `json:"age_group"`
+	Interests []string `json:"interests"`
+	Mobility  string   `json:"mobility"`
+}
+
+// ValidateTravelerProfile checks a profile's age group and mobility
+func ValidateTravelerProfile(profile TravelerProfile) error {
+	switch profile.AgeGroup {
+	case AgeGroupChild, AgeGroupTeen, AgeGroupAdult, AgeGroupSenior:
+	default:
+		return fmt.Errorf("age_group must be one of child, teen, adult, senior")
+	}
+	switch profile.Mobility {
+	case "", MobilityFull, MobilityLimited, MobilityWheelchair:
+	default:
+		return fmt.Errorf("mobility must be one of full, limited, wheelchair")
+	}
+	return nil
+}
+
+// GroupTravelers groups profiles that share an age group, mobility and
+// interests. Groups are returned in the order their first member appears.
+func GroupTravelers(profiles []TravelerProfile) []TravelerGroup {
+	var groups []TravelerGroup
+	index := make(map[string]int)
+
+	for i, profile := range profiles {
+		mobility := profile.Mobility
+		if mobility == "" {
+			mobility = MobilityFull
+		}
+		interests := make([]string, 0, len(profile.Interests))
+		for _, interest := range profile.Interests {
+			if interest = strings.ToLower(strings.TrimSpace(interest)); interest != "" {
+				interests = append(interests, interest)
+			}
+		}
+		interests = removeDuplicateStrings(interests)
+		sort.Strings(interests)
+
+		name := profile.Name
+		if name == "" {
+			name = fmt.Sprintf("traveler_%d", i+1)
+		}
+
+		key := profile.AgeGroup + "|" + mobility + "|" + strings.Join(interests, ",")
+		if idx, ok := index[key]; ok {
+			groups[idx].Members = append(groups[idx].Members, name)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, TravelerGroup{
+			Members:   []string{name},
+			AgeGroup:  profile.AgeGroup,
+			Interests: interests,
+			Mobility:  mobility,
+		})
+	}
+
+	labels := make(map[string]int)
+	for i := range groups {
+		label := groups[i].describe()
+		labels[label]++
+		if labels[label] > 1 {
+			label = fmt.Sprintf("%s %d", label, labels[label])
+		}
+		groups[i].Label = label
+	}
+	return groups
+}
+
+// describe builds a readable label such as "child, limited mobility: nature"
+func (g TravelerGroup) describe() string {
+	label := g.AgeGroup
+	if g.Mobility != MobilityFull {
+		label += ", " + g.Mobility + " mobility"
+	}
+	if len(g.Interests) > 0 {
+		label += ": " + strings.Join(g.Interests, ", ")
+	}
+	return label
+}
+
+// buildGroupPromptSection describes the subgroups for itinerary prompts. It's
+// empty when every traveler fits the same plan.
+func buildGroupPromptSection(profiles []TravelerProfile) string {
+	groups := GroupTravelers(profiles)
+	if len(groups) < 2 {
+		return ""
+	}
+
+	var section strings.Builder
+	section.WriteString("\nTraveler subgroups:\n")
+	for _, group := range groups {
+		section.WriteString(fmt.Sprintf("- %s (%d traveler(s))\n", group.Label, len(group.Members)))
+	}
+	section.WriteString(`Keep meals, evenings and transportation shared by the whole group. For each day's
+morning and afternoon, add an "alternatives_by_group" object keyed by subgroup label
+with an activity suited to that subgroup.
+`)
+	return section.String()
+}
+
+// addGroupAlternatives adds "alternatives_by_group" to each day of itinerary
+// when the travelers' profiles differ. attractionsByCity maps a day's "city"
+// (or "" for single-destination trips) to retrieved attractions, which are
+// matched to each group's interests and mobility; once a group's matches run
+// out it gets a generic suggestion. Days Gemini already personalized are
+// left alone.
+func addGroupAlternatives(itinerary map[string]interface{}, profiles []TravelerProfile, destination string, attractionsByCity map[string][]Attraction) {
+	groups := GroupTravelers(profiles)
+	if len(groups) < 2 {
+		return
+	}
+
+	ranked := make(map[string][][]Attraction)
+	for day := 1; ; day++ {
+		dayPlan, ok := itinerary[fmt.Sprintf("day_%d", day)].(map[string]interface{})
+		if !ok {
+			break
+		}
+		if _, exists := dayPlan["alternatives_by_group"]; exists {
+			continue
+		}
+
+		city, _ := dayPlan["city"].(string)
+		if _, ok := ranked[city]; !ok {
+			ranked[city] = make([][]Attraction, len(groups))
+			for i, group := range groups {
+				ranked[city][i] = rankAttractionsForGroup(group, attractionsByCity[city])
+			}
+		}
+		place := destination
+		if city != "" {
+			place = city
+		}
+
+		alternatives := make(map[string]interface{}, len(groups))
+		for i, group := range groups {
+			options := ranked[city][i]
+			slots := make(map[string]string, len(personalizedSlots))
+			for s, slot := range personalizedSlots {
+				pick := (day-1)*len(personalizedSlots) + s
+				if pick < len(options) {
+					attraction := options[pick]
+					slots[slot] = fmt.Sprintf("Visit %s - %s", attraction.Name, attraction.Description)
+				} else {
+					slots[slot] = groupActivity(group, place, pick)
+				}
+			}
+			alternatives[group.Label] = map[string]interface{}{
+				"members": group.Members,
+				"slots":   slots,
+			}
+		}
+		dayPlan["alternatives_by_group"] = alternatives
+	}
+
+	itinerary["traveler_groups"] = groups
+}
+
+// rankAttractionsForGroup orders attractions by how well they suit the group,
+// dropping those that don't match it or that it can't use
+func rankAttractionsForGroup(group TravelerGroup, attractions []Attraction) []Attraction {
+	type scored struct {
+		attraction Attraction
+		score      int
+	}
+
+	var candidates []scored
+	for _, attraction := range attractions {
+		if attraction.Type == "restaurant" {
+			continue
+		}
+		labels := append([]string{attraction.Type}, attraction.Tags...)
+		status := accessibilityStatus(labels)
+		if group.Mobility == MobilityWheelchair && status != AccessibilityAccessible {
+			continue
+		}
+		if group.Mobility == MobilityLimited && status == AccessibilityInaccessible {
+			continue
+		}
+
+		score := 0
+		for _, label := range labels {
+			label = strings.ToLower(label)
+			for _, interest := range group.Interests {
+				if strings.Contains(label, interest) {
+					score += 2
+				}
+			}
+			for _, preferred := range ageGroupTags[group.AgeGroup] {
+				if label == preferred {
+					score++
+				}
+			}
+		}
+		// The attraction's type says more than any single tag
+		for _, preferred := range ageGroupTags[group.AgeGroup] {
+			if strings.EqualFold(attraction.Type, preferred) {
+				score++
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		candidates = append(candidates, scored{attraction: attraction, score: score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	ranked := make([]Attraction, 0, len(candidates))
+	for _, candidate := range candidates {
+		ranked = append(ranked, candidate.attraction)
+	}
+	return ranked
+}
+
+// ageGroupTags are attraction types and tags that suit each age group
+var ageGroupTags = map[string][]string{
+	AgeGroupChild:  {"park", "zoo", "aquarium", "playground", "family", "educational", "beach"},
+	AgeGroupTeen:   {"adventure", "outdoor", "sports", "entertainment", "beach"},
+	AgeGroupAdult:  {"culture", "history", "museum", "nightlife", "food"},
+	AgeGroupSenior: {"museum", "culture", "history", "relaxation", "garden"},
+}
+
+// groupActivity suggests an activity for a group when there's no retrieved
+// attraction data
+func groupActivity(group TravelerGroup, destination string, pick int) string {
+	if len(group.Interests) > 0 {
+		interest := group.Interests[pick%len(group.Interests)]
+		if group.Mobility != MobilityFull {
+			return fmt.Sprintf("Step-free %s experience in %s", interest, destination)
+		}
+		return fmt.Sprintf("%s experience in %s", strings.ToUpper(interest[:1])+interest[1:], destination)
+	}
+
+	switch {
+	case group.Mobility != MobilityFull:
+		return fmt.Sprintf("Accessible guided tour of %s with rest stops", destination)
+	case group.AgeGroup == AgeGroupChild:
+		return fmt.Sprintf("Kid-friendly park or zoo visit in %s", destination)
+	case group.AgeGroup == AgeGroupTeen:
+		return fmt.Sprintf("Outdoor adventure activity in %s", destination)
+	case group.AgeGroup == AgeGroupSenior:
+		return fmt.Sprintf("Relaxed museum or garden visit in %s", destination)
+	default:
+		return fmt.Sprintf("Explore %s's landmarks", destination)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestGroupTravelers(t *testing.T) {
+	tests := []struct {
+		name        string
+		profiles    []TravelerProfile
+		want        string
+		wantSection bool
+	}{
+		{
+			name: "matching profiles share a group",
+			profiles: []TravelerProfile{
+				{Name: "Asha", AgeGroup: AgeGroupAdult, Interests: []string{"Food", " history "}},
+				{AgeGroup: AgeGroupAdult, Interests: []string{"history", "food", "food"}, Mobility: MobilityFull},
+				{AgeGroup: AgeGroupChild},
+				{Name: "Nani", AgeGroup: AgeGroupSenior, Mobility: MobilityWheelchair},
+			},
+			want:        "adult: food, history=Asha+traveler_2;child=traveler_3;senior, wheelchair mobility=Nani",
+			wantSection: true,
+		},
+		{
+			name: "mobility splits a group",
+			profiles: []TravelerProfile{
+				{Name: "Ravi", AgeGroup: AgeGroupTeen, Interests: []string{"sports"}},
+				{Name: "Meera", AgeGroup: AgeGroupTeen, Interests: []string{"sports"}, Mobility: MobilityLimited},
+			},
+			want:        "teen: sports=Ravi;teen, limited mobility: sports=Meera",
+			wantSection: true,
+		},
+		{
+			name:     "one plan fits everyone",
+			profiles: []TravelerProfile{{AgeGroup: AgeGroupAdult}, {AgeGroup: AgeGroupAdult, Mobility: MobilityFull}},
+			want:     "adult=traveler_1+traveler_2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := GroupTravelers(tt.profiles)
+			parts := make([]string, len(groups))
+			for i, group := range groups {
+				parts[i] = group.Label + "=" + strings.Join(group.Members, "+")
+			}
+			if got := strings.Join(parts, ";"); got != tt.want {
+				t.Errorf("groups = %s, want %s", got, tt.want)
+			}
+			if section := buildGroupPromptSection(tt.profiles); (section != "") != tt.wantSection {
+				t.Errorf("prompt section = %q, want present %v", section, tt.wantSection)
+			}
+		})
+	}
+}
+
+func TestRankAttractionsForGroup(t *testing.T) {
+	attractions := []Attraction{
+		{Name: "Park", Type: "park"},
+		{Name: "Zoo", Type: "zoo", Tags: []string{"nature", "family"}},
+		{Name: "Museum", Type: "museum", Tags: []string{"history", "Wheelchair Accessible"}},
+		{Name: "Fort", Type: "fort", Tags: []string{"history", "stairs only"}},
+		{Name: "Garden", Type: "garden"},
+		{Name: "Cafe", Type: "restaurant", Tags: []string{"family", "food"}},
+	}
+	tests := []struct {
+		group TravelerGroup
+		want  string
+	}{
+		{TravelerGroup{AgeGroup: AgeGroupChild, Interests: []string{"nature"}, Mobility: MobilityFull}, "Zoo,Park"},
+		{TravelerGroup{AgeGroup: AgeGroupAdult, Interests: []string{"history"}, Mobility: MobilityFull}, "Museum,Fort"},
+		{TravelerGroup{AgeGroup: AgeGroupAdult, Interests: []string{"history"}, Mobility: MobilityWheelchair}, "Museum"},
+		{TravelerGroup{AgeGroup: AgeGroupSenior, Mobility: MobilityLimited}, "Museum,Garden"},
+		{TravelerGroup{AgeGroup: AgeGroupTeen, Interests: []string{"surfing"}, Mobility: MobilityFull}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.group.describe(), func(t *testing.T) {
+			ranked := rankAttractionsForGroup(tt.group, attractions)
+			names := make([]string, len(ranked))
+			for i, attraction := range ranked {
+				names[i] = attraction.Name
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("ranked = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddGroupAlternatives(t *testing.T) {
+	profiles := []TravelerProfile{{AgeGroup: AgeGroupChild}, {AgeGroup: AgeGroupAdult}}
+	personalized := map[string]interface{}{"adult": "from Gemini"}
+	itinerary := map[string]interface{}{
+		"day_1": map[string]interface{}{"morning": "Old town walk"},
+		"day_2": map[string]interface{}{"alternatives_by_group": personalized},
+	}
+	addGroupAlternatives(itinerary, profiles, "Goa", map[string][]Attraction{"": {{Name: "Zoo", Type: "zoo", Description: "big cats"}}})
+
+	alternatives := itinerary["day_1"].(map[string]interface{})["alternatives_by_group"].(map[string]interface{})
+	tests := []struct {
+		group, slot, want string
+	}{
+		{"child", "morning", "Visit Zoo - big cats"},
+		{"child", "afternoon", "Kid-friendly park or zoo visit in Goa"},
+		{"adult", "morning", "Explore Goa's landmarks"},
+	}
+	for _, tt := range tests {
+		slots := alternatives[tt.group].(map[string]interface{})["slots"].(map[string]string)
+		if slots[tt.slot] != tt.want {
+			t.Errorf("%s %s = %q, want %q", tt.group, tt.slot, slots[tt.slot], tt.want)
+		}
+	}
+	if got := fmt.Sprint(itinerary["day_2"].(map[string]interface{})["alternatives_by_group"]); got != fmt.Sprint(personalized) {
+		t.Errorf("day 2 alternatives = %s, want Gemini's left alone", got)
+	}
+	if _, ok := itinerary["traveler_groups"]; !ok {
+		t.Error("traveler_groups missing")
+	}
+}
@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ICSEventChange describes an activity updated from an imported calendar
+type ICSEventChange struct {
+	UID              string    `json:"uid"`
+	Activity         string    `json:"activity"`
+	PreviousStart    time.Time `json:"previous_start"`
+	PreviousEnd      time.Time `json:"previous_end"`
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	PreviousLocation string    `json:"previous_location,omitempty"`
+	Location         string    `json:"location,omitempty"`
+}
+
+// ICSUnmatchedEvent is an imported event that no longer matches an activity
+type ICSUnmatchedEvent struct {
+	UID     string    `json:"uid"`
+	Summary string    `json:"summary"`
+	Start   time.Time `json:"start"`
+	Reason  string    `json:"reason"`
+}
+
+// ICSImportResult is the outcome of reconciling a calendar with an itinerary
+type ICSImportResult struct {
+	TripID    string              `json:"trip_id"`
+	Version   int64               `json:"version"`
+	Updated   []ICSEventChange    `json:"updated"`
+	Unmatched []ICSUnmatchedEvent `json:"unmatched,omitempty"`
+	Itinerary *ItineraryData      `json:"itinerary"`
+}
+
+// icsActivityUID is the UID generateICS gives the nth activity of a trip,
+// counting through the days in order and each day's morning, afternoon and
+// evening
+func icsActivityUID(tripID string, n int) string {
+	return fmt.Sprintf("%s-%d@auratravel.com", tripID, n)
+}
+
+// icsActivities returns the itinerary's activities in UID order
+func icsActivities(data *ItineraryData) []*Activity {
+	dayNums := make([]int, 0, len(data.DailyItinerary))
+	for dayNum := range data.DailyItinerary {
+		dayNums = append(dayNums, dayNum)
+	}
+	sort.Ints(dayNums)
+
+	var activities []*Activity
+	for _, dayNum := range dayNums {
+		day := data.DailyItinerary[dayNum]
+		for _, slot := range [][]Activity{day.Morning, day.Afternoon, day.Evening} {
+			for i := range slot {
+				activities = append(activities, &slot[i])
+			}
+		}
+	}
+	return activities
+}
+
+// icsEscape escapes text property values
+func icsEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
+
+func icsUnescape(value string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(value)
+}
+
+// icsProperty is one content line, e.g. DTSTART;TZID=Asia/Kolkata:20240101T090000
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// ParseICS reads the VEVENTs of a calendar into days, ordered by date. Each
+// event becomes an activity whose ID is the event's UID, placed in the
+// morning, afternoon or evening by its start time.
+func ParseICS(data []byte) ([]DayItinerary, error) {
+	lines := unfoldICSLines(string(data))
+
+	var (
+		inCalendar, sawCalendar, inEvent bool
+		eventLine                        int
+		event                            Activity
+		activities                       []Activity
+	)
+	for _, line := range lines {
+		prop, err := parseICSProperty(line.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ICS at line %d: %w", line.number, err)
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VCALENDAR"):
+			if sawCalendar {
+				return nil, fmt.Errorf("invalid ICS at line %d: more than one VCALENDAR", line.number)
+			}
+			inCalendar, sawCalendar = true, true
+		case prop.name == "END" && strings.EqualFold(prop.value, "VCALENDAR"):
+			if !inCalendar || inEvent {
+				return nil, fmt.Errorf("invalid ICS at line %d: unexpected END:VCALENDAR", line.number)
+			}
+			inCalendar = false
+		case !inCalendar:
+			return nil, fmt.Errorf("invalid ICS at line %d: content outside VCALENDAR", line.number)
+		case prop.name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT"):
+			if inEvent {
+				return nil, fmt.Errorf("invalid ICS at line %d: nested VEVENT", line.number)
+			}
+			inEvent, eventLine, event = true, line.number, Activity{}
+		case prop.name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			if !inEvent {
+				return nil, fmt.Errorf("invalid ICS at line %d: END:VEVENT without BEGIN", line.number)
+			}
+			if event.StartTime.IsZero() {
+				return nil, fmt.Errorf("invalid ICS: event starting at line %d has no DTSTART", eventLine)
+			}
+			if event.EndTime.IsZero() {
+				event.EndTime = event.StartTime
+			}
+			activities = append(activities, event)
+			inEvent = false
+		case !inEvent:
+			// Calendar properties and other components are ignored
+		default:
+			if err := applyICSProperty(&event, prop); err != nil {
+				return nil, fmt.Errorf("invalid ICS at line %d: %w", line.number, err)
+			}
+		}
+	}
+	if !sawCalendar {
+		return nil, fmt.Errorf("invalid ICS: missing BEGIN:VCALENDAR")
+	}
+	if inCalendar || inEvent {
+		return nil, fmt.Errorf("invalid ICS: calendar is not terminated")
+	}
+
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].StartTime.Before(activities[j].StartTime)
+	})
+
+	var days []DayItinerary
+	for _, activity := range activities {
+		date := truncateToDate(activity.StartTime)
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			days = append(days, DayItinerary{Date: date, DayNumber: len(days) + 1})
+		}
+		day := &days[len(days)-1]
+		switch icsSlot(activity.StartTime) {
+		case "morning":
+			day.Morning = append(day.Morning, activity)
+		case "afternoon":
+			day.Afternoon = append(day.Afternoon, activity)
+		default:
+			day.Evening = append(day.Evening, activity)
+		}
+	}
+	return days, nil
+}
+
+type icsLine struct {
+	number int
+	text   string
+}
+
+// unfoldICSLines joins folded continuation lines and drops blank ones
+func unfoldICSLines(data string) []icsLine {
+	var lines []icsLine
+	for i, raw := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		if (strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1].text += raw[1:]
+			continue
+		}
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		lines = append(lines, icsLine{number: i + 1, text: raw})
+	}
+	return lines
+}
+
+func parseICSProperty(line string) (icsProperty, error) {
+	// The value starts at the first colon outside a quoted parameter value
+	inQuotes, colon := false, -1
+	for i, r := range line {
+		if r == '"' {
+			inQuotes = !inQuotes
+		} else if r == ':' && !inQuotes {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return icsProperty{}, fmt.Errorf("expected NAME:VALUE, got %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := icsProperty{
+		name:   strings.ToUpper(strings.TrimSpace(parts[0])),
+		params: make(map[string]string),
+		value:  line[colon+1:],
+	}
+	for _, param := range parts[1:] {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return icsProperty{}, fmt.Errorf("malformed parameter %q", param)
+		}
+		prop.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return prop, nil
+}
+
+func applyICSProperty(event *Activity, prop icsProperty) error {
+	var err error
+	switch prop.name {
+	case "UID":
+		event.ID = strings.TrimSpace(prop.value)
+	case "SUMMARY":
+		event.Name = icsUnescape(prop.value)
+	case "DESCRIPTION":
+		event.Description = icsUnescape(prop.value)
+	case "LOCATION":
+		event.Location.Address = icsUnescape(prop.value)
+	case "DTSTART":
+		event.StartTime, err = parseICSTime(prop)
+	case "DTEND":
+		event.EndTime, err = parseICSTime(prop)
+	}
+	return err
+}
+
+// parseICSTime reads UTC, TZID-qualified, floating and all-day values.
+// Floating times are read as UTC.
+func parseICSTime(prop icsProperty) (time.Time, error) {
+	value := strings.TrimSpace(prop.value)
+	if prop.params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		t, err := time.Parse("20060102", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s date %q", prop.name, value)
+		}
+		return t, nil
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid %s time %q", prop.name, value)
+		}
+		return t, nil
+	}
+
+	loc := time.UTC
+	if tzid := prop.params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown %s time zone %q", prop.name, tzid)
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s time %q", prop.name, value)
+	}
+	return t, nil
+}
+
+func truncateToDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// icsSlot is the part of the day an activity starting at t belongs in
+func icsSlot(t time.Time) string {
+	switch {
+	case t.Hour() < 12:
+		return "morning"
+	case t.Hour() < 17:
+		return "afternoon"
+	default:
+		return "evening"
+	}
+}
+
+// ImportItineraryFromICS reconciles an edited calendar, previously exported
+// with generateICS, against the user's stored itinerary and saves the
+// result. Events are mapped back to activities by UID; matching activities
+// get the event's times and location, and are moved to the day and slot
+// they now fall in. Events whose UID or title no longer matches an activity
+// are reported, not applied. If the trip changes while the calendar is
+// imported, *TripVersionConflictError is returned and nothing is saved.
+func (d *ItineraryDeliveryService) ImportItineraryFromICS(ctx context.Context, tripID, userID string, data []byte) (*ICSImportResult, error) {
+	days, err := ParseICS(data)
+	if err != nil {
+		return nil, kindErrorf(ErrValidation, "%w", err)
+	}
+
+	if d.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+	trip, _, err := d.firebase.AuthorizeTrip(ctx, tripID, userID, TripActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	itineraryData := ItineraryFromTrip(trip)
+
+	activities := icsActivities(itineraryData)
+	result := &ICSImportResult{TripID: tripID, Version: trip.Version, Updated: []ICSEventChange{}, Itinerary: itineraryData}
+	moved := make(map[*Activity]bool)
+
+	for _, day := range days {
+		for _, slot := range [][]Activity{day.Morning, day.Afternoon, day.Evening} {
+			for _, event := range slot {
+				if isICSBookingUID(tripID, event.ID) {
+					continue
+				}
+
+				n, ok := icsActivityIndex(tripID, event.ID)
+				if !ok || n >= len(activities) {
+					result.Unmatched = append(result.Unmatched, ICSUnmatchedEvent{
+						UID: event.ID, Summary: event.Name, Start: event.StartTime,
+						Reason: "event is not part of this itinerary",
+					})
+					continue
+				}
+				activity := activities[n]
+				if !strings.EqualFold(strings.TrimSpace(activity.Name), strings.TrimSpace(event.Name)) {
+					result.Unmatched = append(result.Unmatched, ICSUnmatchedEvent{
+						UID: event.ID, Summary: event.Name, Start: event.StartTime,
+						Reason: fmt.Sprintf("activity is now %q", activity.Name),
+					})
+					continue
+				}
+
+				locationChanged := event.Location.Address != "" && event.Location.Address != activity.Location.Address
+				// ICS times have whole-second precision
+				startChanged := !activity.StartTime.Truncate(time.Second).Equal(event.StartTime)
+				endChanged := !activity.EndTime.Truncate(time.Second).Equal(event.EndTime)
+				if !startChanged && !endChanged && !locationChanged {
+					continue
+				}
+
+				change := ICSEventChange{
+					UID:           event.ID,
+					Activity:      activity.Name,
+					PreviousStart: activity.StartTime,
+					PreviousEnd:   activity.EndTime,
+					Start:         event.StartTime,
+					End:           event.EndTime,
+				}
+				if locationChanged {
+					change.PreviousLocation = activity.Location.Address
+					change.Location = event.Location.Address
+					// The old coordinates describe the old place
+					activity.Location = Location{Address: event.Location.Address}
+				}
+				if startChanged {
+					moved[activity] = true
+				}
+				activity.StartTime, activity.EndTime = event.StartTime, event.EndTime
+				result.Updated = append(result.Updated, change)
+			}
+		}
+	}
+
+	if len(result.Updated) == 0 {
+		return result, nil
+	}
+	if len(moved) > 0 {
+		reslotMovedActivities(itineraryData, moved)
+	}
+	storeICSChanges(itineraryData)
+	version, err := d.firebase.UpdateTripAtVersion(ctx, tripID, trip.Version, map[string]interface{}{"itinerary": trip.Itinerary})
+	if err != nil {
+		return nil, err
+	}
+	result.Version = version
+	return result, nil
+}
+
+// storeICSChanges writes the reconciled days back into the stored day plans
+// they were read from. Each day's activities are stored in its morning,
+// afternoon and evening, which take in the night and unslotted activities
+// ItineraryFromTrip placed there; the stored activities keep their other
+// fields.
+func storeICSChanges(data *ItineraryData) {
+	for _, day := range data.DailyItinerary {
+		if day.source == nil {
+			continue
+		}
+		for _, slot := range []struct {
+			key        string
+			activities []Activity
+		}{
+			{"morning", day.Morning},
+			{"afternoon", day.Afternoon},
+			{"evening", day.Evening},
+		} {
+			stored := make([]map[string]interface{}, len(slot.activities))
+			for i, activity := range slot.activities {
+				stored[i] = storedICSActivity(activity)
+			}
+			day.source[slot.key] = slotValue(day.source[slot.key], stored)
+		}
+		delete(day.source, "night")
+		delete(day.source, "activities")
+	}
+}
+
+// storedICSActivity updates an activity's stored fields with its imported
+// times and location
+func storedICSActivity(activity Activity) map[string]interface{} {
+	stored := activity.source
+	if stored == nil {
+		stored = map[string]interface{}{"name": activity.Name}
+	}
+	if !activity.StartTime.IsZero() {
+		stored["start_time"] = activity.StartTime.Format(time.RFC3339)
+		if _, ok := stored["time"]; ok {
+			stored["time"] = activity.StartTime.Format("15:04")
+		}
+	}
+	if !activity.EndTime.IsZero() {
+		stored["end_time"] = activity.EndTime.Format(time.RFC3339)
+	}
+	if activity.Location.Address != "" && activity.Location.Address != locationFromPlan(stored["location"]).Address {
+		stored["location"] = activity.Location.Address
+	}
+	return stored
+}
+
+// isICSBookingUID reports whether uid is one of the hotel or transport events
+// generateICS adds alongside the activities
+func isICSBookingUID(tripID, uid string) bool {
+	for _, suffix := range []string{"checkin", "checkout", "transport"} {
+		if uid == fmt.Sprintf("%s-%s@auratravel.com", tripID, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// icsActivityIndex parses the activity number out of an icsActivityUID
+func icsActivityIndex(tripID, uid string) (int, bool) {
+	rest, ok := strings.CutPrefix(uid, tripID+"-")
+	if !ok {
+		return 0, false
+	}
+	number, ok := strings.CutSuffix(rest, "@auratravel.com")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// reslotMovedActivities moves rescheduled activities to the day and slot
+// their new start time falls in. Activities moved to a date outside the trip
+// stay on their original day.
+func reslotMovedActivities(data *ItineraryData, moved map[*Activity]bool) {
+	dayForDate := make(map[time.Time]int)
+	for dayNum, day := range data.DailyItinerary {
+		dayForDate[truncateToDate(day.Date)] = dayNum
+	}
+
+	type placement struct {
+		dayNum   int
+		activity Activity
+	}
+	var pending []placement
+	for dayNum, day := range data.DailyItinerary {
+		keep := func(activities []Activity) []Activity {
+			var kept []Activity
+			for i := range activities {
+				if !moved[&activities[i]] {
+					kept = append(kept, activities[i])
+					continue
+				}
+				target := dayNum
+				start := activities[i].StartTime.In(day.Date.Location())
+				if other, ok := dayForDate[truncateToDate(start)]; ok {
+					target = other
+				}
+				pending = append(pending, placement{dayNum: target, activity: activities[i]})
+			}
+			return kept
+		}
+		day.Morning, day.Afternoon, day.Evening = keep(day.Morning), keep(day.Afternoon), keep(day.Evening)
+		data.DailyItinerary[dayNum] = day
+	}
+
+	for _, p := range pending {
+		day := data.DailyItinerary[p.dayNum]
+		switch icsSlot(p.activity.StartTime.In(day.Date.Location())) {
+		case "morning":
+			day.Morning = sortedByStart(append(day.Morning, p.activity))
+		case "afternoon":
+			day.Afternoon = sortedByStart(append(day.Afternoon, p.activity))
+		default:
+			day.Evening = sortedByStart(append(day.Evening, p.activity))
+		}
+		data.DailyItinerary[p.dayNum] = day
+	}
+}
+
+func sortedByStart(activities []Activity) []Activity {
+	sort.SliceStable(activities, func(i, j int) bool {
+		return activities[i].StartTime.Before(activities[j].StartTime)
+	})
+	return activities
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreICSChangesUpdatesStoredDayPlans(t *testing.T) {
+	start := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	fort := map[string]interface{}{"name": "Red Fort", "time": "09:00", "rating": 4.6}
+	trip := &TripData{
+		ID:        "trip-1",
+		StartDate: start,
+		Itinerary: map[string]interface{}{
+			"itinerary": map[string]interface{}{
+				"day_1": map[string]interface{}{"morning": []interface{}{fort}},
+				"day_2": map[string]interface{}{"evening": map[string]interface{}{"name": "Kingdom of Dreams"}},
+			},
+		},
+	}
+
+	data := ItineraryFromTrip(trip)
+	activities := icsActivities(data)
+	moved := activities[0]
+	moved.StartTime = start.AddDate(0, 0, 1).Add(15 * time.Hour)
+	moved.EndTime = moved.StartTime.Add(2 * time.Hour)
+	reslotMovedActivities(data, map[*Activity]bool{moved: true})
+	storeICSChanges(data)
+
+	days := trip.Itinerary["itinerary"].(map[string]interface{})
+	if morning := days["day_1"].(map[string]interface{})["morning"].([]interface{}); len(morning) != 0 {
+		t.Errorf("day 1 morning = %v, want it empty", morning)
+	}
+	afternoon, ok := days["day_2"].(map[string]interface{})["afternoon"].([]interface{})
+	if !ok || len(afternoon) != 1 {
+		t.Fatalf("day 2 afternoon = %v, want the Red Fort", days["day_2"])
+	}
+	stored := afternoon[0].(map[string]interface{})
+	if stored["time"] != "15:00" || stored["start_time"] != "2026-05-02T15:00:00Z" || stored["rating"] != 4.6 {
+		t.Errorf("stored Red Fort = %v, want the new time and its other fields kept", stored)
+	}
+	if _, ok := days["day_2"].(map[string]interface{})["evening"].(map[string]interface{}); !ok {
+		t.Errorf("day 2 evening = %v, want it still a single activity", days["day_2"])
+	}
+}
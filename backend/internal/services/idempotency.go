@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// idempotencyKeyTTL is how long a completed response is replayed for a
+	// repeated key
+	idempotencyKeyTTL = 24 * time.Hour
+	// idempotencyPendingTimeout lets a key be reclaimed when the request that
+	// claimed it died before completing. A live request heartbeats its claim
+	// every idempotencyHeartbeatInterval, however long generation takes.
+	idempotencyPendingTimeout    = 2 * time.Minute
+	idempotencyHeartbeatInterval = idempotencyPendingTimeout / 4
+
+	// Idempotency key states
+	IdempotencyPending   = "pending"
+	IdempotencyCompleted = "completed"
+)
+
+// ErrIdempotencyKeyReused is returned when a key is claimed again for a
+// different request than the one it was first used for
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// IdempotencyRecord is the stored state of an idempotency key
+type IdempotencyRecord struct {
+	Key         string    `firestore:"key"`
+	Scope       string    `firestore:"scope"`
+	Status      string    `firestore:"status"`
+	RequestHash string    `firestore:"request_hash"`
+	Response    string    `firestore:"response"` // JSON-encoded response body
+	CreatedAt   time.Time `firestore:"created_at"`
+	HeartbeatAt time.Time `firestore:"heartbeat_at"`
+	ExpiresAt   time.Time `firestore:"expires_at"`
+}
+
+// stale reports whether a pending claim's request has stopped heartbeating
+func (r *IdempotencyRecord) stale(now time.Time) bool {
+	lastSeen := r.HeartbeatAt
+	if lastSeen.IsZero() {
+		lastSeen = r.CreatedAt
+	}
+	return r.Status == IdempotencyPending && now.Sub(lastSeen) > idempotencyPendingTimeout
+}
+
+// HashIdempotentRequest fingerprints a request, so a key reused for a
+// different request can be told apart from a retry
+func HashIdempotentRequest(request interface{}) (string, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode idempotent request: %v", err)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// DecodeResponse unmarshals the stored response into out
+func (r *IdempotencyRecord) DecodeResponse(out interface{}) error {
+	return json.Unmarshal([]byte(r.Response), out)
+}
+
+// ClaimIdempotencyKey reserves key within scope (typically the user ID) for
+// the request with requestHash. It returns claimed=true when the caller
+// should do the work, heartbeating with KeepIdempotencyKeyAlive, and then
+// call CompleteIdempotencyKey or ReleaseIdempotencyKey. Otherwise the
+// existing record is returned: completed records carry the response to
+// replay, pending ones mean another request is still running. A key already
+// used for a different request fails with ErrIdempotencyKeyReused. The claim
+// is made in a transaction so concurrent requests with the same key can't
+// both win.
+func (f *FirebaseService) ClaimIdempotencyKey(ctx context.Context, scope, key, requestHash string) (*IdempotencyRecord, bool, error) {
+	ref := f.firestore.Collection("idempotency_keys").Doc(idempotencyDocID(scope, key))
+
+	var existing *IdempotencyRecord
+	err := f.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing = nil
+		now := time.Now()
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var record IdempotencyRecord
+			if err := doc.DataTo(&record); err != nil {
+				return err
+			}
+			if now.Before(record.ExpiresAt) && !record.stale(now) {
+				if record.RequestHash != "" && record.RequestHash != requestHash {
+					return ErrIdempotencyKeyReused
+				}
+				existing = &record
+				return nil
+			}
+		}
+
+		return tx.Set(ref, IdempotencyRecord{
+			Key:         key,
+			Scope:       scope,
+			Status:      IdempotencyPending,
+			RequestHash: requestHash,
+			CreatedAt:   now,
+			HeartbeatAt: now,
+			ExpiresAt:   now.Add(idempotencyKeyTTL),
+		})
+	})
+	if errors.Is(err, ErrIdempotencyKeyReused) {
+		return nil, false, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %v", err)
+	}
+
+	return existing, existing == nil, nil
+}
+
+// KeepIdempotencyKeyAlive heartbeats a claimed key until the returned stop
+// is called, so a request that runs longer than idempotencyPendingTimeout
+// isn't mistaken for a dead one. stop may be called more than once.
+func (f *FirebaseService) KeepIdempotencyKeyAlive(ctx context.Context, scope, key string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(idempotencyHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := f.heartbeatIdempotencyKey(ctx, scope, key); err != nil && ctx.Err() == nil {
+					log.Printf("Failed to heartbeat idempotency key: %v", err)
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cancel()
+			<-done
+		})
+	}
+}
+
+// heartbeatIdempotencyKey marks a pending claim as still running
+func (f *FirebaseService) heartbeatIdempotencyKey(ctx context.Context, scope, key string) error {
+	_, err := f.firestore.Collection("idempotency_keys").Doc(idempotencyDocID(scope, key)).Update(ctx, []firestore.Update{
+		{Path: "heartbeat_at", Value: time.Now()},
+	})
+	return err
+}
+
+// CompleteIdempotencyKey stores the response for replay on repeated requests
+func (f *FirebaseService) CompleteIdempotencyKey(ctx context.Context, scope, key string, response interface{}) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response: %v", err)
+	}
+
+	_, err = f.firestore.Collection("idempotency_keys").Doc(idempotencyDocID(scope, key)).Update(ctx, []firestore.Update{
+		{Path: "status", Value: IdempotencyCompleted},
+		{Path: "response", Value: string(body)},
+		{Path: "expires_at", Value: time.Now().Add(idempotencyKeyTTL)},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key: %v", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops a claim after a failed request so the client
+// can retry with the same key
+func (f *FirebaseService) ReleaseIdempotencyKey(ctx context.Context, scope, key string) error {
+	_, err := f.firestore.Collection("idempotency_keys").Doc(idempotencyDocID(scope, key)).Delete(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key: %v", err)
+	}
+	return nil
+}
+
+// idempotencyDocID hashes scope and key so arbitrary client keys are safe
+// document IDs
+func idempotencyDocID(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	fb, _ := newTestFirebase(t)
+	planA, _ := HashIdempotentRequest(map[string]string{"destination": "Goa"})
+	planB, _ := HashIdempotentRequest(map[string]string{"destination": "Jaipur"})
+
+	// First call claims the key
+	if record, claimed, err := fb.ClaimIdempotencyKey(ctx, "u1", "k1", planA); err != nil || !claimed || record != nil {
+		t.Fatalf("first claim = %v, %v, %v; want claimed", record, claimed, err)
+	}
+
+	// A retry while it runs finds it pending
+	record, claimed, err := fb.ClaimIdempotencyKey(ctx, "u1", "k1", planA)
+	if err != nil || claimed || record.Status != IdempotencyPending {
+		t.Fatalf("retry while pending = %v, %v, %v; want the pending record", record, claimed, err)
+	}
+
+	// After completion a retry gets the response to replay
+	if err := fb.CompleteIdempotencyKey(ctx, "u1", "k1", map[string]string{"trip_id": "t1"}); err != nil {
+		t.Fatal(err)
+	}
+	record, claimed, err = fb.ClaimIdempotencyKey(ctx, "u1", "k1", planA)
+	if err != nil || claimed || record.Status != IdempotencyCompleted {
+		t.Fatalf("retry after completion = %v, %v, %v; want the completed record", record, claimed, err)
+	}
+	var replay map[string]string
+	if err := record.DecodeResponse(&replay); err != nil || replay["trip_id"] != "t1" {
+		t.Errorf("replayed response = %v, %v", replay, err)
+	}
+
+	// The same key for another request is refused
+	if _, _, err := fb.ClaimIdempotencyKey(ctx, "u1", "k1", planB); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("claim for a different request = %v, want ErrIdempotencyKeyReused", err)
+	}
+
+	// Keys are scoped to their user
+	if _, claimed, err := fb.ClaimIdempotencyKey(ctx, "u2", "k1", planB); err != nil || !claimed {
+		t.Errorf("another user's claim = %v, %v; want claimed", claimed, err)
+	}
+
+	// A released key can be claimed again
+	if err := fb.ReleaseIdempotencyKey(ctx, "u2", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, claimed, err := fb.ClaimIdempotencyKey(ctx, "u2", "k1", planA); err != nil || !claimed {
+		t.Errorf("claim after release = %v, %v; want claimed", claimed, err)
+	}
+}
+
+func TestClaimIdempotencyKeyConcurrently(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	hash, _ := HashIdempotentRequest(map[string]string{"destination": "Goa"})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	claims, pending := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record, claimed, err := fb.ClaimIdempotencyKey(context.Background(), "u1", "k1", hash)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				t.Errorf("concurrent claim: %v", err)
+			case claimed:
+				claims++
+			case record.Status == IdempotencyPending:
+				pending++
+			}
+		}()
+	}
+	wg.Wait()
+	if claims != 1 || pending != 7 {
+		t.Errorf("%d claims and %d pending, want 1 and 7", claims, pending)
+	}
+}
+
+func TestClaimIdempotencyKeyHeartbeat(t *testing.T) {
+	ctx := context.Background()
+	fb, _ := newTestFirebase(t)
+	hash, _ := HashIdempotentRequest(map[string]string{"destination": "Goa"})
+	started := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		heartbeatAt time.Time
+		wantClaimed bool
+	}{
+		{"long request still heartbeating", time.Now().Add(-idempotencyHeartbeatInterval), false},
+		{"heartbeat stopped", time.Now().Add(-2 * idempotencyPendingTimeout), true},
+		{"never heartbeated", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := IdempotencyRecord{Key: "k1", Scope: "u1", Status: IdempotencyPending, RequestHash: hash, CreatedAt: started, HeartbeatAt: tt.heartbeatAt, ExpiresAt: started.Add(idempotencyKeyTTL)}
+			seed(t, fb, "idempotency_keys/"+idempotencyDocID("u1", "k1"), record)
+			if _, claimed, err := fb.ClaimIdempotencyKey(ctx, "u1", "k1", hash); err != nil || claimed != tt.wantClaimed {
+				t.Errorf("claim = %v, %v; want claimed %v", claimed, err, tt.wantClaimed)
+			}
+		})
+	}
+
+	// A heartbeat keeps a claim from going stale
+	seed(t, fb, "idempotency_keys/"+idempotencyDocID("u1", "k1"), IdempotencyRecord{Key: "k1", Scope: "u1", Status: IdempotencyPending, RequestHash: hash, CreatedAt: started, ExpiresAt: started.Add(idempotencyKeyTTL)})
+	if err := fb.heartbeatIdempotencyKey(ctx, "u1", "k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, claimed, err := fb.ClaimIdempotencyKey(ctx, "u1", "k1", hash); err != nil || claimed {
+		t.Errorf("claim after heartbeat = %v, %v; want the key still held", claimed, err)
+	}
+
+	stop := fb.KeepIdempotencyKeyAlive(ctx, "u1", "k1")
+	stop()
+	stop()
+}
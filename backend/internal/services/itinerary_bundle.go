@@ -0,0 +1,326 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staticMapsURL is the Maps Static API endpoint bundle maps are fetched from
+const staticMapsURL = "https://maps.googleapis.com/maps/api/staticmap"
+
+const (
+	// defaultMaxBundleBytes caps the files in a bundle when config has no cap
+	defaultMaxBundleBytes = 25 << 20
+	// maxBundleMaps caps how many locations a bundle has maps of
+	maxBundleMaps = 50
+	// bundleManifestReserve is the room kept for the manifest when the
+	// other files are fitted under the cap
+	bundleManifestReserve = 64 << 10
+	// bundleMapFetchers caps the maps fetched at once
+	bundleMapFetchers = 8
+	// bundleMapsTimeout bounds fetching all of a bundle's maps; maps not
+	// fetched by then are left out
+	bundleMapsTimeout = 30 * time.Second
+)
+
+// BundleConfig configures offline itinerary bundles
+type BundleConfig struct {
+	// MapsAPIKey fetches static maps; without one bundles have no maps
+	MapsAPIKey string
+	// MaxBytes caps the total size of the files in a bundle. Maps that
+	// would go over it are left out. Defaults to 25MB.
+	MaxBytes int
+	// StaticMapsURL overrides the Maps Static API endpoint
+	StaticMapsURL string
+}
+
+// SetBundleConfig sets how offline bundles are built
+func (d *ItineraryDeliveryService) SetBundleConfig(cfg *BundleConfig) {
+	d.bundleConfig = cfg
+}
+
+// BundleManifest describes the contents of an offline bundle. It's written
+// to the bundle as manifest.json.
+type BundleManifest struct {
+	TripID      string       `json:"trip_id"`
+	Title       string       `json:"title"`
+	Destination string       `json:"destination"`
+	StartDate   time.Time    `json:"start_date"`
+	EndDate     time.Time    `json:"end_date"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	Files       []BundleFile `json:"files"`
+	Maps        []BundleMap  `json:"maps"`
+	// SkippedMaps are the locations whose maps couldn't be fetched or
+	// didn't fit, with the reason why
+	SkippedMaps []BundleMap `json:"skipped_maps,omitempty"`
+}
+
+// BundleFile is a file in an offline bundle
+type BundleFile struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Bytes       int    `json:"bytes"`
+}
+
+// BundleMap is the map of a location in the itinerary
+type BundleMap struct {
+	Name      string  `json:"name"`
+	Address   string  `json:"address,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	File      string  `json:"file,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// bundleEntry is a file to write to a bundle
+type bundleEntry struct {
+	file BundleFile
+	data []byte
+}
+
+// generateBundle creates a zip of the itinerary that works offline: the
+// HTML, PDF and ICS versions, a PNG map of each location and a manifest.
+// Maps that fail to fetch or would take the bundle over its size cap are
+// left out and listed in the manifest.
+func (d *ItineraryDeliveryService) generateBundle(ctx context.Context, data *ItineraryData, req *DeliveryRequest) ([]byte, string, error) {
+	maxBytes := defaultMaxBundleBytes
+	if d.bundleConfig != nil && d.bundleConfig.MaxBytes > 0 {
+		maxBytes = d.bundleConfig.MaxBytes
+	}
+
+	var entries []bundleEntry
+	size := 0
+	renderers := []struct {
+		name        string
+		contentType string
+		render      func(*ItineraryData, *DeliveryRequest) ([]byte, string, error)
+	}{
+		{"itinerary.html", "text/html; charset=utf-8", d.generateHTML},
+		{"itinerary.pdf", "application/pdf", d.generatePDF},
+		{"itinerary.ics", "text/calendar; charset=utf-8", d.generateICS},
+	}
+	for _, renderer := range renderers {
+		fileData, _, err := renderer.render(data, req)
+		if err != nil {
+			return nil, "", err
+		}
+		entries = append(entries, bundleEntry{BundleFile{renderer.name, renderer.contentType, len(fileData)}, fileData})
+		size += len(fileData)
+	}
+	if size+bundleManifestReserve > maxBytes {
+		return nil, "", kindErrorf(ErrValidation, "itinerary is too large for an offline bundle (%d bytes, limit %d)", size, maxBytes)
+	}
+
+	manifest := BundleManifest{
+		TripID:      data.TripID,
+		Title:       data.Title,
+		Destination: data.Destination,
+		StartDate:   data.StartDate,
+		EndDate:     data.EndDate,
+		GeneratedAt: time.Now(),
+		Maps:        []BundleMap{},
+	}
+
+	locations := bundleLocations(data)
+	images, fetchErrs := d.fetchStaticMaps(ctx, locations, maxBytes-bundleManifestReserve-size)
+	for i, location := range locations {
+		reason := ""
+		var image []byte
+		switch {
+		case i >= maxBundleMaps:
+			reason = fmt.Sprintf("bundles have maps of at most %d locations", maxBundleMaps)
+		case d.bundleConfig == nil || d.bundleConfig.MapsAPIKey == "":
+			reason = "Maps API key not configured"
+		case fetchErrs[i] != nil:
+			reason = fetchErrs[i].Error()
+		case size+len(images[i])+bundleManifestReserve > maxBytes:
+			reason = "bundle size limit reached"
+		default:
+			image = images[i]
+		}
+		if reason != "" {
+			location.Error = reason
+			manifest.SkippedMaps = append(manifest.SkippedMaps, location)
+			continue
+		}
+
+		location.File = fmt.Sprintf("maps/%02d.png", len(manifest.Maps)+1)
+		manifest.Maps = append(manifest.Maps, location)
+		entries = append(entries, bundleEntry{BundleFile{location.File, "image/png", len(image)}, image})
+		size += len(image)
+	}
+	if len(manifest.SkippedMaps) > 0 {
+		log.Printf("Offline bundle for trip %s left out %d of %d maps", data.TripID, len(manifest.SkippedMaps), len(manifest.SkippedMaps)+len(manifest.Maps))
+	}
+
+	for _, entry := range entries {
+		manifest.Files = append(manifest.Files, entry.file)
+	}
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal bundle manifest: %w", err)
+	}
+	entries = append(entries, bundleEntry{BundleFile{"manifest.json", "application/json", len(manifestData)}, manifestData})
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.file.Name, Method: zip.Deflate, Modified: manifest.GeneratedAt}
+		if entry.file.ContentType == "image/png" {
+			// PNGs are already compressed
+			header.Method = zip.Store
+		}
+		w, err := archive.CreateHeader(header)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to add %s to bundle: %w", entry.file.Name, err)
+		}
+		if _, err := w.Write(entry.data); err != nil {
+			return nil, "", fmt.Errorf("failed to add %s to bundle: %w", entry.file.Name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	fileName := fmt.Sprintf("itinerary_%s_%s.zip", data.TripID, time.Now().Format("20060102"))
+	return buf.Bytes(), fileName, nil
+}
+
+// bundleLocations returns the places in the itinerary worth a map, in day
+// order: activities, meals, then hotels. Places at the same coordinates or
+// address are listed once.
+func bundleLocations(data *ItineraryData) []BundleMap {
+	var locations []BundleMap
+	seen := make(map[string]bool)
+	add := func(name string, location Location) {
+		key := strings.ToLower(strings.TrimSpace(location.Address))
+		if location.Latitude != 0 || location.Longitude != 0 {
+			key = fmt.Sprintf("%.5f,%.5f", location.Latitude, location.Longitude)
+		}
+		if key == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		locations = append(locations, BundleMap{
+			Name:      name,
+			Address:   strings.TrimSpace(location.Address),
+			Latitude:  location.Latitude,
+			Longitude: location.Longitude,
+		})
+	}
+
+	dayNums := make([]int, 0, len(data.DailyItinerary))
+	for dayNum := range data.DailyItinerary {
+		dayNums = append(dayNums, dayNum)
+	}
+	sort.Ints(dayNums)
+	for _, dayNum := range dayNums {
+		day := data.DailyItinerary[dayNum]
+		for _, slot := range [][]Activity{day.Morning, day.Afternoon, day.Evening} {
+			for _, activity := range slot {
+				add(activity.Name, activity.Location)
+			}
+		}
+		for _, meal := range day.Meals {
+			add(meal.Restaurant, meal.Location)
+		}
+	}
+	for _, hotel := range data.Hotels {
+		add(hotel.Name, Location{Address: hotel.Address})
+	}
+	return locations
+}
+
+// fetchStaticMaps fetches the maps of the first maxBundleMaps locations,
+// bundleMapFetchers at a time, each no larger than maxBytes. Maps not
+// fetched within bundleMapsTimeout fail. Without a Maps API key nothing is
+// fetched.
+func (d *ItineraryDeliveryService) fetchStaticMaps(ctx context.Context, locations []BundleMap, maxBytes int) ([][]byte, []error) {
+	count := min(len(locations), maxBundleMaps)
+	images := make([][]byte, count)
+	errs := make([]error, count)
+	if d.bundleConfig == nil || d.bundleConfig.MapsAPIKey == "" {
+		return images, errs
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bundleMapsTimeout)
+	defer cancel()
+	slots := make(chan struct{}, bundleMapFetchers)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				errs[i] = fmt.Errorf("map not fetched in time: %w", ctx.Err())
+				return
+			}
+			images[i], errs[i] = d.fetchStaticMap(ctx, locations[i], maxBytes)
+		}(i)
+	}
+	wg.Wait()
+	return images, errs
+}
+
+// fetchStaticMap fetches a PNG map centred on the location, failing if the
+// image is larger than maxBytes
+func (d *ItineraryDeliveryService) fetchStaticMap(ctx context.Context, location BundleMap, maxBytes int) ([]byte, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("bundle size limit reached")
+	}
+
+	center := location.Address
+	if location.Latitude != 0 || location.Longitude != 0 {
+		center = fmt.Sprintf("%f,%f", location.Latitude, location.Longitude)
+	}
+	params := url.Values{}
+	params.Set("center", center)
+	params.Set("zoom", "15")
+	params.Set("size", "640x400")
+	params.Set("format", "png")
+	params.Set("markers", "color:red|"+center)
+	params.Set("key", d.bundleConfig.MapsAPIKey)
+
+	baseURL := staticMapsURL
+	if d.bundleConfig.StaticMapsURL != "" {
+		baseURL = d.bundleConfig.StaticMapsURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create map request: %w", err)
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch map: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("maps API returned status %d", resp.StatusCode)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/png") {
+		return nil, fmt.Errorf("maps API returned %s instead of a PNG", contentType)
+	}
+	image, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read map: %w", err)
+	}
+	if len(image) > maxBytes {
+		return nil, fmt.Errorf("bundle size limit reached")
+	}
+	return image, nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchStaticMapsFetchesConcurrently(t *testing.T) {
+	var inFlight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Query().Get("center") == "broken" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprint(w, "png")
+	}))
+	defer server.Close()
+
+	d := &ItineraryDeliveryService{
+		httpClient:   server.Client(),
+		bundleConfig: &BundleConfig{MapsAPIKey: "key", StaticMapsURL: server.URL},
+	}
+	locations := make([]BundleMap, maxBundleMaps+2)
+	for i := range locations {
+		locations[i] = BundleMap{Address: fmt.Sprintf("place %d", i)}
+	}
+	locations[3].Address = "broken"
+
+	images, errs := d.fetchStaticMaps(context.Background(), locations, 1024)
+
+	if len(images) != maxBundleMaps {
+		t.Fatalf("fetched %d maps, want %d", len(images), maxBundleMaps)
+	}
+	for i := range images {
+		if (errs[i] != nil) != (i == 3) {
+			t.Errorf("map %d error = %v", i, errs[i])
+		}
+	}
+	if peak < 2 || peak > bundleMapFetchers {
+		t.Errorf("peak concurrent fetches = %d, want between 2 and %d", peak, bundleMapFetchers)
+	}
+}
@@ -3,14 +3,24 @@ package services
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"log"
-	"net/smtp"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/metrics"
+	"auratravel-backend/internal/tracing"
 
 	"cloud.google.com/go/firestore"
 	"github.com/jung-kurt/gofpdf"
@@ -25,6 +35,15 @@ type ItineraryDeliveryService struct {
 	storageConfig *StorageConfig
 	templateDir   string
 	firebase      *FirebaseService
+
+	// localization writes SMS summaries in the requested language
+	localization *LocalizationService
+	// gemini looks up the destination's emergency information
+	gemini *GeminiService
+	// bundleConfig sets up offline bundles; nil bundles have no maps
+	bundleConfig *BundleConfig
+	// httpClient fetches the static maps of offline bundles
+	httpClient *http.Client
 }
 
 // EmailConfig contains email service configuration
@@ -36,6 +55,22 @@ type EmailConfig struct {
 	FromEmail string
 	FromName  string
 	Enabled   bool
+
+	// TLSMode is how the connection is encrypted: SMTPStartTLS (the
+	// default), SMTPImplicitTLS for port 465, or SMTPNoTLS for local relays
+	TLSMode string
+	// InsecureSkipVerify accepts any server certificate, for self-signed
+	// servers in development only
+	InsecureSkipVerify bool
+	// AuthMethod is SMTPAuthPlain (the default) or SMTPAuthXOAUTH2, which
+	// sends an OAuth2 access token instead of Password
+	AuthMethod string
+	// OAuth2Token returns a current access token for SMTPAuthXOAUTH2
+	OAuth2Token func(ctx context.Context) (string, error)
+
+	// MaxAttachmentBytes is the largest file attached to an email; larger
+	// files are only linked. Defaults to 10MB.
+	MaxAttachmentBytes int
 }
 
 // SMSConfig contains SMS service configuration
@@ -67,9 +102,20 @@ func NewItineraryDeliveryService(
 		storageConfig: storageConfig,
 		templateDir:   "templates",
 		firebase:      firebase,
+		httpClient:    httpclient.New(httpclient.Options{Timeout: 10 * time.Second}),
 	}
 }
 
+// SetLocalization sets the service SMS summaries are localized with
+func (d *ItineraryDeliveryService) SetLocalization(localization *LocalizationService) {
+	d.localization = localization
+}
+
+// SetGemini sets the service emergency information is looked up with
+func (d *ItineraryDeliveryService) SetGemini(gemini *GeminiService) {
+	d.gemini = gemini
+}
+
 // DeliveryFormat represents the format for itinerary delivery
 type DeliveryFormat string
 
@@ -78,6 +124,9 @@ const (
 	FormatICS  DeliveryFormat = "ics"
 	FormatJSON DeliveryFormat = "json"
 	FormatHTML DeliveryFormat = "html"
+	// FormatBundle is a zip of the HTML, PDF and ICS itineraries with maps
+	// of each location, for use offline
+	FormatBundle DeliveryFormat = "bundle"
 )
 
 // DeliveryMethod represents how the itinerary should be delivered
@@ -102,6 +151,9 @@ type DeliveryRequest struct {
 	IncludeMap      bool           `json:"include_map"`
 	CustomMessage   string         `json:"custom_message,omitempty"`
 	Template        string         `json:"template,omitempty"`
+
+	// Summary puts the trip's key details in an SMS, not just the link
+	Summary bool `json:"summary,omitempty"`
 }
 
 // DeliveryResult represents the result of a delivery operation
@@ -118,6 +170,10 @@ type DeliveryResult struct {
 	ErrorMessage  string     `json:"error_message,omitempty"`
 	DownloadCount int        `json:"download_count"`
 	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+
+	// LinkOnly is set when the file was too large to attach to the email,
+	// so only its download link was sent
+	LinkOnly bool `json:"link_only,omitempty"`
 }
 
 // ItineraryData represents structured itinerary data for delivery
@@ -140,6 +196,15 @@ type ItineraryData struct {
 	TotalCost         float64              `json:"total_cost"`
 	CreatedAt         time.Time            `json:"created_at"`
 	LastModified      time.Time            `json:"last_modified"`
+	// CarbonFootprintKg is the estimated kg CO2e of all transport for all
+	// travelers
+	CarbonFootprintKg float64 `json:"carbon_footprint_kg,omitempty"`
+	// EmergencyInfo is the destination's emergency numbers, embassy and
+	// phrases for the traveler
+	EmergencyInfo *EmergencyInfo `json:"emergency_info,omitempty"`
+	// Advisory is the destination's travel advisory for the traveler,
+	// also listed first in ImportantInfo
+	Advisory *Advisory `json:"advisory,omitempty"`
 }
 
 // DayItinerary represents a single day's activities
@@ -154,6 +219,13 @@ type DayItinerary struct {
 	Notes     string       `json:"notes,omitempty"`
 	Weather   *WeatherInfo `json:"weather,omitempty"`
 	TotalCost float64      `json:"total_cost"`
+
+	// Conflicts are the day's scheduling conflicts, filled in when the
+	// itinerary is rendered
+	Conflicts []Conflict `json:"conflicts,omitempty"`
+
+	// source is the stored day plan this was read from, if any
+	source map[string]interface{}
 }
 
 // Activity represents a single activity
@@ -169,6 +241,10 @@ type Activity struct {
 	BookingRef  string    `json:"booking_ref,omitempty"`
 	Status      string    `json:"status"` // confirmed, pending, optional
 	Tips        []string  `json:"tips,omitempty"`
+	Currency    string    `json:"currency,omitempty"` // defaults to the itinerary's
+
+	// source is the stored activity this was read from, if any
+	source map[string]interface{}
 }
 
 // Meal represents a meal/dining activity
@@ -180,6 +256,7 @@ type Meal struct {
 	Cost       float64   `json:"cost"`
 	Cuisine    string    `json:"cuisine"`
 	BookingRef string    `json:"booking_ref,omitempty"`
+	Currency   string    `json:"currency,omitempty"` // defaults to the itinerary's
 }
 
 // HotelBooking represents hotel booking information
@@ -194,6 +271,7 @@ type HotelBooking struct {
 	ConfirmationNum string    `json:"confirmation_number"`
 	Contact         string    `json:"contact"`
 	Amenities       []string  `json:"amenities"`
+	Currency        string    `json:"currency,omitempty"` // defaults to the itinerary's
 }
 
 // TransportBooking represents transportation booking
@@ -208,6 +286,9 @@ type TransportBooking struct {
 	SeatNumber    string    `json:"seat_number,omitempty"`
 	Cost          float64   `json:"cost"`
 	Status        string    `json:"status"`
+	DistanceKm    float64   `json:"distance_km,omitempty"`
+	CarbonKg      float64   `json:"carbon_kg,omitempty"` // per passenger
+	Currency      string    `json:"currency,omitempty"`  // defaults to the itinerary's
 }
 
 // ActivityBooking represents activity booking information
@@ -222,6 +303,7 @@ type ActivityBooking struct {
 	BookingRef   string    `json:"booking_ref"`
 	MeetingPoint string    `json:"meeting_point"`
 	Instructions []string  `json:"instructions"`
+	Currency     string    `json:"currency,omitempty"` // defaults to the itinerary's
 }
 
 // EmergencyContact represents emergency contact information
@@ -243,12 +325,30 @@ type WeatherInfo struct {
 }
 
 // GenerateAndDeliverItinerary generates and delivers an itinerary
-func (d *ItineraryDeliveryService) GenerateAndDeliverItinerary(ctx context.Context, req *DeliveryRequest) (*DeliveryResult, error) {
+func (d *ItineraryDeliveryService) GenerateAndDeliverItinerary(ctx context.Context, req *DeliveryRequest) (result *DeliveryResult, err error) {
+	defer func() { recordDelivery(req, result) }()
+
+	// Check the recipient before doing any work, so a typo fails fast
+	if req.Recipient != "" {
+		recipient, err := normalizeRecipient(req.Method, req.Recipient)
+		if err != nil {
+			return nil, err
+		}
+		req.Recipient = recipient
+	}
+
 	// Get itinerary data
 	itineraryData, err := d.getItineraryData(ctx, req.TripID, req.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get itinerary data: %w", err)
 	}
+	itineraryData.CarbonFootprintKg = TripCarbonFootprint(itineraryData)
+	nationality, language := d.travelerProfile(ctx, req.UserID, req.Language)
+	itineraryData.EmergencyInfo = d.emergencyInfo(ctx, itineraryData.Destination, nationality, language)
+	if advisory := d.travelAdvisory(ctx, itineraryData.Destination, nationality); advisory != nil {
+		itineraryData.Advisory = advisory
+		itineraryData.ImportantInfo = append(advisory.ImportantInfo(), itineraryData.ImportantInfo...)
+	}
 
 	// Generate file based on format
 	fileData, fileName, err := d.generateFile(ctx, itineraryData, req)
@@ -263,7 +363,7 @@ func (d *ItineraryDeliveryService) GenerateAndDeliverItinerary(ctx context.Conte
 	}
 
 	// Create delivery result
-	result := &DeliveryResult{
+	result = &DeliveryResult{
 		DeliveryID:  d.generateDeliveryID(req.TripID, req.UserID),
 		TripID:      req.TripID,
 		UserID:      req.UserID,
@@ -278,16 +378,16 @@ func (d *ItineraryDeliveryService) GenerateAndDeliverItinerary(ctx context.Conte
 	// Deliver based on method
 	switch req.Method {
 	case MethodEmail:
-		err = d.deliverByEmail(ctx, req, fileURL, fileName, itineraryData)
+		result.LinkOnly, err = d.deliverByEmail(ctx, req, fileData, fileURL, fileName, itineraryData)
 	case MethodSMS:
-		err = d.deliverBySMS(ctx, req, fileURL, fileName)
+		err = d.deliverBySMS(ctx, req, fileURL, fileName, itineraryData)
 	case MethodDownload:
 		// No additional delivery needed
 		result.Status = "success"
 	case MethodPush:
 		err = d.deliverByPush(ctx, req, fileURL, fileName)
 	default:
-		err = fmt.Errorf("unsupported delivery method: %s", req.Method)
+		err = kindErrorf(ErrValidation, "unsupported delivery method: %s", req.Method)
 	}
 
 	if err != nil {
@@ -303,8 +403,90 @@ func (d *ItineraryDeliveryService) GenerateAndDeliverItinerary(ctx context.Conte
 	return result, err
 }
 
+// recordDelivery counts a finished delivery. Deliveries that failed before
+// a result was created count as failed.
+func recordDelivery(req *DeliveryRequest, result *DeliveryResult) {
+	status := "failed"
+	if result != nil {
+		status = result.Status
+	}
+	metrics.DeliveriesTotal.WithLabelValues(
+		metrics.Bounded(string(req.Format), string(FormatPDF), string(FormatICS), string(FormatJSON), string(FormatHTML), string(FormatBundle)),
+		metrics.Bounded(string(req.Method), string(MethodEmail), string(MethodSMS), string(MethodDownload), string(MethodPush)),
+		metrics.Bounded(status, "success", "failed", "pending"),
+	).Inc()
+}
+
+// previewTimeout caps how long rendering a preview may take
+const previewTimeout = 20 * time.Second
+
+// previewContentTypes are the formats that can be previewed inline
+var previewContentTypes = map[DeliveryFormat]string{
+	FormatPDF:  "application/pdf",
+	FormatHTML: "text/html; charset=utf-8",
+	FormatJSON: "application/json",
+}
+
+// PreviewContentType returns the Content-Type for a previewable format
+func PreviewContentType(format DeliveryFormat) (string, bool) {
+	contentType, ok := previewContentTypes[format]
+	return contentType, ok
+}
+
+// PreviewItinerary renders the itinerary in the requested format without
+// storing or delivering it. It returns the file bytes and name.
+func (d *ItineraryDeliveryService) PreviewItinerary(ctx context.Context, req *DeliveryRequest) ([]byte, string, error) {
+	if _, ok := PreviewContentType(req.Format); !ok {
+		return nil, "", kindErrorf(ErrValidation, "format %s can't be previewed", req.Format)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, previewTimeout)
+	defer cancel()
+
+	itineraryData, err := d.getItineraryData(ctx, req.TripID, req.UserID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get itinerary data: %w", err)
+	}
+	itineraryData.CarbonFootprintKg = TripCarbonFootprint(itineraryData)
+
+	type rendered struct {
+		data     []byte
+		fileName string
+		err      error
+	}
+	done := make(chan rendered, 1)
+	go func() {
+		fileData, fileName, err := d.generateFile(ctx, itineraryData, req)
+		done <- rendered{fileData, fileName, err}
+	}()
+
+	select {
+	case result := <-done:
+		if result.err != nil {
+			return nil, "", fmt.Errorf("failed to generate file: %w", result.err)
+		}
+		return result.data, result.fileName, nil
+	case <-ctx.Done():
+		return nil, "", fmt.Errorf("preview generation timed out: %w", ctx.Err())
+	}
+}
+
 // generateFile generates the file in the requested format
 func (d *ItineraryDeliveryService) generateFile(ctx context.Context, data *ItineraryData, req *DeliveryRequest) ([]byte, string, error) {
+	// Render the sum of the line items rather than a possibly stale total
+	if reconciliation := ReconcileCosts(data, DefaultCostTolerance); !reconciliation.Matches {
+		log.Printf("Trip %s total cost %.2f doesn't match its line items (%.2f), using computed total",
+			data.TripID, reconciliation.StoredTotal, reconciliation.ComputedTotal)
+	}
+	rendered := *data
+	rendered.TotalCost = ComputeTotalCost(data)
+	rendered.DailyItinerary = make(map[int]DayItinerary, len(data.DailyItinerary))
+	for dayNum, day := range data.DailyItinerary {
+		day.Conflicts = DetectScheduleConflicts(day)
+		rendered.DailyItinerary[dayNum] = day
+	}
+	data = &rendered
+
 	switch req.Format {
 	case FormatPDF:
 		return d.generatePDF(data, req)
@@ -314,8 +496,10 @@ func (d *ItineraryDeliveryService) generateFile(ctx context.Context, data *Itine
 		return d.generateJSON(data, req)
 	case FormatHTML:
 		return d.generateHTML(data, req)
+	case FormatBundle:
+		return d.generateBundle(ctx, data, req)
 	default:
-		return nil, "", fmt.Errorf("unsupported format: %s", req.Format)
+		return nil, "", kindErrorf(ErrValidation, "unsupported format: %s", req.Format)
 	}
 }
 
@@ -363,6 +547,11 @@ func (d *ItineraryDeliveryService) generatePDF(data *ItineraryData, req *Deliver
 	// Transportation section
 	if len(data.Transportation) > 0 {
 		d.addTransportationToPDF(pdf, data.Transportation)
+		if data.CarbonFootprintKg > 0 {
+			pdf.SetFont("Arial", "", 10)
+			pdf.Cell(0, 6, fmt.Sprintf("Total transport emissions: %.1f kg CO2e", data.CarbonFootprintKg))
+			pdf.Ln(9)
+		}
 	}
 
 	// Important information
@@ -375,6 +564,11 @@ func (d *ItineraryDeliveryService) generatePDF(data *ItineraryData, req *Deliver
 		d.addEmergencyContactsToPDF(pdf, data.EmergencyContacts)
 	}
 
+	// Emergency information for the destination
+	if data.EmergencyInfo != nil {
+		d.addEmergencyInfoToPDF(pdf, data.EmergencyInfo)
+	}
+
 	// Generate file data
 	var buf bytes.Buffer
 	err := pdf.Output(&buf)
@@ -398,11 +592,7 @@ func (d *ItineraryDeliveryService) generateICS(data *ItineraryData, req *Deliver
 	ics.WriteString("METHOD:PUBLISH\r\n")
 
 	// Add each activity as an event
-	for _, dayData := range data.DailyItinerary {
-		d.addActivitiesToICS(&ics, dayData.Morning, data.TripID)
-		d.addActivitiesToICS(&ics, dayData.Afternoon, data.TripID)
-		d.addActivitiesToICS(&ics, dayData.Evening, data.TripID)
-	}
+	d.addActivitiesToICS(&ics, icsActivities(data), data.TripID)
 
 	// Add hotel check-ins/check-outs
 	for _, hotel := range data.Hotels {
@@ -456,10 +646,12 @@ func (d *ItineraryDeliveryService) generateHTML(data *ItineraryData, req *Delive
 	return buf.Bytes(), fileName, nil
 }
 
-// deliverByEmail sends the itinerary via email
-func (d *ItineraryDeliveryService) deliverByEmail(ctx context.Context, req *DeliveryRequest, fileURL, fileName string, data *ItineraryData) error {
+// deliverByEmail sends the itinerary via email, attaching the file unless
+// it's larger than the configured maximum. It reports whether the email only
+// links to the file.
+func (d *ItineraryDeliveryService) deliverByEmail(ctx context.Context, req *DeliveryRequest, fileData []byte, fileURL, fileName string, data *ItineraryData) (bool, error) {
 	if !d.emailConfig.Enabled {
-		return fmt.Errorf("email delivery not enabled")
+		return false, newKindError(ErrUnavailable, "email delivery not enabled")
 	}
 
 	// Get user email if not provided
@@ -467,23 +659,35 @@ func (d *ItineraryDeliveryService) deliverByEmail(ctx context.Context, req *Deli
 	if recipient == "" {
 		userEmail, err := d.getUserEmail(ctx, req.UserID)
 		if err != nil {
-			return fmt.Errorf("failed to get user email: %w", err)
+			return false, fmt.Errorf("failed to get user email: %w", err)
 		}
 		recipient = userEmail
 	}
+	recipient, err := normalizeRecipient(MethodEmail, recipient)
+	if err != nil {
+		return false, err
+	}
 
 	// Prepare email content
 	subject := fmt.Sprintf("Your Travel Itinerary - %s", data.Destination)
 	body := d.buildEmailBody(data, fileURL, req.CustomMessage)
 
+	// Files too large to attach are only linked
+	attachment := fileData
+	linkOnly := len(fileData) > d.emailConfig.maxAttachmentBytes()
+	if linkOnly {
+		log.Printf("Itinerary %s is %d bytes, too large to attach; sending a link only", fileName, len(fileData))
+		attachment = nil
+	}
+
 	// Send email
-	return d.sendEmail(recipient, subject, body, fileURL, fileName)
+	return linkOnly, d.sendEmail(ctx, recipient, subject, body, fileURL, fileName, attachment)
 }
 
 // deliverBySMS sends a download link via SMS
-func (d *ItineraryDeliveryService) deliverBySMS(ctx context.Context, req *DeliveryRequest, fileURL, fileName string) error {
+func (d *ItineraryDeliveryService) deliverBySMS(ctx context.Context, req *DeliveryRequest, fileURL, fileName string, data *ItineraryData) error {
 	if !d.smsConfig.Enabled {
-		return fmt.Errorf("SMS delivery not enabled")
+		return newKindError(ErrUnavailable, "SMS delivery not enabled")
 	}
 
 	// Get user phone if not provided
@@ -495,15 +699,38 @@ func (d *ItineraryDeliveryService) deliverBySMS(ctx context.Context, req *Delive
 		}
 		recipient = userPhone
 	}
+	recipient, err := normalizeRecipient(MethodSMS, recipient)
+	if err != nil {
+		return err
+	}
 
-	// Prepare SMS content
+	// Prepare SMS content; a summary carries the key details inline, with
+	// just the link after it
 	message := fmt.Sprintf("Your travel itinerary is ready! Download it here: %s", fileURL)
+	if req.Summary {
+		summaryLen := smsSummaryMaxLen - utf8.RuneCountInString(fileURL) - 1
+		message = tripSummary(data, summaryLen, d.summaryLocale(req.Language)) + "\n" + fileURL
+	}
 	if req.CustomMessage != "" {
 		message = req.CustomMessage + "\n\n" + message
 	}
 
 	// Send SMS
-	return d.sendSMS(recipient, message)
+	return d.sendSMS(ctx, recipient, message)
+}
+
+// summaryLocale returns the locale trip summaries are written in, falling
+// back to English when the language isn't supported
+func (d *ItineraryDeliveryService) summaryLocale(language string) *LocaleConfig {
+	if language == "" || d.localization == nil {
+		return summaryDefaultLocale
+	}
+	config, err := d.localization.GetLocaleConfig(language)
+	if err != nil {
+		log.Printf("Writing trip summary in English: %v", err)
+		return summaryDefaultLocale
+	}
+	return config
 }
 
 // deliverByPush sends a push notification with download link
@@ -556,6 +783,20 @@ func (d *ItineraryDeliveryService) addDayToPDF(pdf *gofpdf.Fpdf, dayNum int, day
 		pdf.Ln(3)
 	}
 
+	if len(dayData.Conflicts) > 0 {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.SetTextColor(180, 0, 0)
+		pdf.Cell(40, 6, "Schedule conflicts:")
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "", 10)
+		for _, conflict := range dayData.Conflicts {
+			pdf.Cell(10, 5, "")
+			pdf.MultiCell(0, 5, fmt.Sprintf("• [%s] %s", conflict.Severity, conflict.Message), "", "", false)
+		}
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Ln(3)
+	}
+
 	pdf.Ln(5)
 }
 
@@ -600,6 +841,10 @@ func (d *ItineraryDeliveryService) addTransportationToPDF(pdf *gofpdf.Fpdf, tran
 			pdf.Cell(0, 6, fmt.Sprintf("Booking Reference: %s", transport.BookingRef))
 			pdf.Ln(6)
 		}
+		if transport.CarbonKg > 0 {
+			pdf.Cell(0, 6, fmt.Sprintf("Estimated emissions: %.1f kg CO2e per traveler", transport.CarbonKg))
+			pdf.Ln(6)
+		}
 		pdf.Ln(3)
 	}
 }
@@ -629,15 +874,128 @@ func (d *ItineraryDeliveryService) addEmergencyContactsToPDF(pdf *gofpdf.Fpdf, c
 	}
 }
 
-func (d *ItineraryDeliveryService) addActivitiesToICS(ics *strings.Builder, activities []Activity, tripID string) {
+// addEmergencyInfoToPDF adds the destination's emergency card. The core
+// fonts only cover Latin-1, so phrases in other scripts are shown romanized.
+func (d *ItineraryDeliveryService) addEmergencyInfoToPDF(pdf *gofpdf.Fpdf, info *EmergencyInfo) {
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(0, 10, fmt.Sprintf("Emergency Information - %s", tr(info.Country)))
+	pdf.Ln(12)
+
+	pdf.SetFont("Arial", "", 10)
+	numbers := []struct{ label, number string }{
+		{"Emergency", info.Numbers.General},
+		{"Police", info.Numbers.Police},
+		{"Ambulance", info.Numbers.Ambulance},
+		{"Fire", info.Numbers.Fire},
+		{"Tourist police", info.Numbers.TouristPolice},
+	}
+	for _, n := range numbers {
+		if n.number != "" {
+			pdf.Cell(0, 6, fmt.Sprintf("%s: %s", n.label, n.number))
+			pdf.Ln(6)
+		}
+	}
+
+	if info.Embassy != nil {
+		pdf.Ln(2)
+		pdf.Cell(0, 6, tr(fmt.Sprintf("%s, %s", info.Embassy.Name, info.Embassy.City)))
+		pdf.Ln(6)
+		if info.Embassy.DirectoryURL != "" {
+			pdf.Cell(0, 6, info.Embassy.DirectoryURL)
+			pdf.Ln(6)
+		}
+	}
+
+	if len(info.Phrases) > 0 {
+		pdf.Ln(2)
+		for _, phrase := range info.Phrases {
+			text := phrase.Phrase
+			if phrase.Romanized != "" {
+				text = phrase.Romanized
+			}
+			if !isLatin1(text) {
+				continue
+			}
+			line := text
+			if phrase.Meaning != "" && phrase.Meaning != text && isLatin1(phrase.Meaning) {
+				line = fmt.Sprintf("%s - %s", phrase.Meaning, text)
+			}
+			pdf.Cell(0, 6, tr(line))
+			pdf.Ln(6)
+		}
+	}
+	if info.Note != "" {
+		pdf.SetFont("Arial", "I", 9)
+		pdf.Cell(0, 6, tr(info.Note))
+		pdf.Ln(6)
+	}
+	pdf.Ln(5)
+}
+
+// isLatin1 reports whether the PDF core fonts can show text
+func isLatin1(text string) bool {
+	for _, r := range text {
+		if r > unicode.MaxLatin1 {
+			return false
+		}
+	}
+	return true
+}
+
+// travelerProfile returns the user's nationality, and the requested
+// language or else their preferred one
+func (d *ItineraryDeliveryService) travelerProfile(ctx context.Context, userID, language string) (string, string) {
+	var nationality string
+	if d.firebase != nil {
+		if profile, err := d.firebase.GetUserProfile(ctx, userID); err == nil {
+			nationality = profile.Nationality
+			if language == "" {
+				language = profile.PreferredLanguage
+			}
+		}
+	}
+	return nationality, language
+}
+
+// emergencyInfo looks up the destination's emergency card for the user's
+// nationality and language. It's a convenience, so failures leave it out.
+func (d *ItineraryDeliveryService) emergencyInfo(ctx context.Context, destination, nationality, language string) *EmergencyInfo {
+	if d.gemini == nil {
+		return nil
+	}
+	info, err := d.gemini.GetEmergencyInfo(ctx, destination, nationality, language)
+	if err != nil {
+		log.Printf("No emergency information for %s: %v", destination, err)
+		return nil
+	}
+	return info
+}
+
+// travelAdvisory looks up the destination's travel advisory for the user's
+// nationality. Like the emergency card, failures leave it out.
+func (d *ItineraryDeliveryService) travelAdvisory(ctx context.Context, destination, nationality string) *Advisory {
+	if d.gemini == nil {
+		return nil
+	}
+	advisory, err := d.gemini.GetTravelAdvisory(ctx, destination, nationality)
+	if err != nil {
+		log.Printf("No travel advisory for %s: %v", destination, err)
+		return nil
+	}
+	return advisory
+}
+
+func (d *ItineraryDeliveryService) addActivitiesToICS(ics *strings.Builder, activities []*Activity, tripID string) {
 	for i, activity := range activities {
 		ics.WriteString("BEGIN:VEVENT\r\n")
-		ics.WriteString(fmt.Sprintf("UID:%s-%d@auratravel.com\r\n", tripID, i))
+		ics.WriteString(fmt.Sprintf("UID:%s\r\n", icsActivityUID(tripID, i)))
 		ics.WriteString(fmt.Sprintf("DTSTART:%s\r\n", activity.StartTime.UTC().Format("20060102T150405Z")))
 		ics.WriteString(fmt.Sprintf("DTEND:%s\r\n", activity.EndTime.UTC().Format("20060102T150405Z")))
-		ics.WriteString(fmt.Sprintf("SUMMARY:%s\r\n", activity.Name))
-		ics.WriteString(fmt.Sprintf("DESCRIPTION:%s\r\n", activity.Description))
-		ics.WriteString(fmt.Sprintf("LOCATION:%s\r\n", activity.Location.Address))
+		ics.WriteString(fmt.Sprintf("SUMMARY:%s\r\n", icsEscape(activity.Name)))
+		ics.WriteString(fmt.Sprintf("DESCRIPTION:%s\r\n", icsEscape(activity.Description)))
+		ics.WriteString(fmt.Sprintf("LOCATION:%s\r\n", icsEscape(activity.Location.Address)))
 		ics.WriteString("END:VEVENT\r\n")
 	}
 }
@@ -672,37 +1030,81 @@ func (d *ItineraryDeliveryService) addTransportToICS(ics *strings.Builder, trans
 
 // Email and SMS sending methods
 
-func (d *ItineraryDeliveryService) sendEmail(to, subject, body, attachmentURL, attachmentName string) error {
-	// Set up authentication
-	auth := smtp.PlainAuth("", d.emailConfig.Username, d.emailConfig.Password, d.emailConfig.SMTPHost)
+func (d *ItineraryDeliveryService) sendEmail(ctx context.Context, to, subject, body, attachmentURL, attachmentName string, attachment []byte) (err error) {
+	_, span := tracing.Start(ctx, "smtp.send_mail", tracing.KindClient,
+		tracing.Attr("peer.service", "smtp"),
+		tracing.Attr("server.address", d.emailConfig.SMTPHost),
+	)
+	start := time.Now()
+	defer func() {
+		span.EndWithError(err)
+		metrics.ObserveExternalCall("smtp", start, err)
+	}()
 
 	// Build message
-	msg := d.buildEmailMessage(to, subject, body, attachmentURL, attachmentName)
+	msg := d.buildEmailMessage(to, subject, body, attachmentURL, attachmentName, attachment)
 
 	// Send email
-	addr := fmt.Sprintf("%s:%d", d.emailConfig.SMTPHost, d.emailConfig.SMTPPort)
-	return smtp.SendMail(addr, auth, d.emailConfig.FromEmail, []string{to}, []byte(msg))
+	return sendSMTPMail(ctx, d.emailConfig, []string{to}, []byte(msg))
 }
 
-func (d *ItineraryDeliveryService) buildEmailMessage(to, subject, body, attachmentURL, attachmentName string) string {
+// buildEmailMessage builds the email, with the file attached when attachment
+// is given and only linked otherwise
+func (d *ItineraryDeliveryService) buildEmailMessage(to, subject, body, attachmentURL, attachmentName string, attachment []byte) string {
 	var msg strings.Builder
 
 	msg.WriteString(fmt.Sprintf("From: %s <%s>\r\n", d.emailConfig.FromName, d.emailConfig.FromEmail))
 	msg.WriteString(fmt.Sprintf("To: %s\r\n", to))
 	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", subject))
 	msg.WriteString("MIME-Version: 1.0\r\n")
-	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
-	msg.WriteString("\r\n")
-	msg.WriteString(body)
 
+	html := body
 	if attachmentURL != "" {
-		msg.WriteString(fmt.Sprintf("\r\n\r\nDownload your itinerary: <a href=\"%s\">%s</a>", attachmentURL, attachmentName))
+		html += fmt.Sprintf("\r\n\r\nDownload your itinerary: <a href=\"%s\">%s</a>", attachmentURL, attachmentName)
+	}
+	if attachment == nil {
+		msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+		msg.WriteString("\r\n")
+		msg.WriteString(html)
+		return msg.String()
 	}
 
+	writer := multipart.NewWriter(&msg)
+	msg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=%q\r\n", writer.Boundary()))
+	msg.WriteString("\r\n")
+
+	part, _ := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=UTF-8"}})
+	part.Write([]byte(html))
+
+	contentType := mime.TypeByExtension(filepath.Ext(attachmentName))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	part, _ = writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachmentName})},
+	})
+	// Base64 lines are kept to 76 characters, as MIME requires
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	part.Write([]byte(encoded + "\r\n"))
+	writer.Close()
+
 	return msg.String()
 }
 
-func (d *ItineraryDeliveryService) sendSMS(to, message string) error {
+func (d *ItineraryDeliveryService) sendSMS(ctx context.Context, to, message string) (err error) {
+	_, span := tracing.Start(ctx, "twilio.create_message", tracing.KindClient, tracing.Attr("peer.service", "twilio"))
+	start := time.Now()
+	defer func() {
+		span.EndWithError(err)
+		metrics.ObserveExternalCall("twilio", start, err)
+	}()
+
 	client := twilio.NewRestClientWithParams(twilio.ClientParams{
 		Username: d.smsConfig.TwilioAccountSID,
 		Password: d.smsConfig.TwilioAuthToken,
@@ -713,7 +1115,7 @@ func (d *ItineraryDeliveryService) sendSMS(to, message string) error {
 	params.SetTo(to)
 	params.SetBody(message)
 
-	_, err := client.Api.CreateMessage(params)
+	_, err = client.Api.CreateMessage(params)
 	return err
 }
 
@@ -752,110 +1154,17 @@ func (d *ItineraryDeliveryService) buildEmailBody(data *ItineraryData, fileURL,
 
 // Utility methods
 
+// getItineraryData loads the trip the user is delivering, which they must be
+// able to view
 func (d *ItineraryDeliveryService) getItineraryData(ctx context.Context, tripID, userID string) (*ItineraryData, error) {
-	// Mock implementation - in production, fetch from database
-	return &ItineraryData{
-		TripID:      tripID,
-		Destination: "Delhi, India",
-		StartDate:   time.Now().AddDate(0, 0, 7),
-		EndDate:     time.Now().AddDate(0, 0, 10),
-		Travelers:   2,
-		Budget:      50000,
-		Currency:    "INR",
-		Title:       "Amazing Delhi Adventure",
-		Description: "A wonderful journey through India's capital",
-		DailyItinerary: map[int]DayItinerary{
-			1: {
-				Date:      time.Now().AddDate(0, 0, 7),
-				DayNumber: 1,
-				Title:     "Arrival & Old Delhi Exploration",
-				Morning: []Activity{
-					{
-						Name:        "Arrival at Delhi Airport",
-						Type:        "transport",
-						StartTime:   time.Now().AddDate(0, 0, 7).Add(8 * time.Hour),
-						EndTime:     time.Now().AddDate(0, 0, 7).Add(9 * time.Hour),
-						Location:    Location{Address: "Indira Gandhi International Airport"},
-						Description: "Flight arrival and airport pickup",
-						Cost:        0,
-					},
-				},
-				Afternoon: []Activity{
-					{
-						Name:        "Red Fort",
-						Type:        "sightseeing",
-						StartTime:   time.Now().AddDate(0, 0, 7).Add(14 * time.Hour),
-						EndTime:     time.Now().AddDate(0, 0, 7).Add(16 * time.Hour),
-						Location:    Location{Address: "Red Fort, Old Delhi"},
-						Description: "Historic Mughal fortress",
-						Cost:        50,
-					},
-				},
-				Evening: []Activity{
-					{
-						Name:        "Chandni Chowk Market",
-						Type:        "shopping",
-						StartTime:   time.Now().AddDate(0, 0, 7).Add(18 * time.Hour),
-						EndTime:     time.Now().AddDate(0, 0, 7).Add(20 * time.Hour),
-						Location:    Location{Address: "Chandni Chowk, Old Delhi"},
-						Description: "Traditional market and street food",
-						Cost:        200,
-					},
-				},
-				TotalCost: 250,
-			},
-		},
-		Hotels: []HotelBooking{
-			{
-				Name:            "Hotel New Delhi",
-				Address:         "Connaught Place, New Delhi",
-				CheckIn:         time.Now().AddDate(0, 0, 7),
-				CheckOut:        time.Now().AddDate(0, 0, 10),
-				RoomType:        "Deluxe Double",
-				Nights:          3,
-				TotalCost:       15000,
-				ConfirmationNum: "HTL123456",
-				Contact:         "+91-11-1234-5678",
-				Amenities:       []string{"WiFi", "Breakfast", "Pool", "Gym"},
-			},
-		},
-		Transportation: []TransportBooking{
-			{
-				Type:          "flight",
-				From:          "Mumbai",
-				To:            "Delhi",
-				DepartureTime: time.Now().AddDate(0, 0, 7).Add(6 * time.Hour),
-				ArrivalTime:   time.Now().AddDate(0, 0, 7).Add(8 * time.Hour),
-				Provider:      "Air India",
-				BookingRef:    "AI123456",
-				Cost:          8000,
-				Status:        "confirmed",
-			},
-		},
-		ImportantInfo: []string{
-			"Carry valid ID proof for all activities",
-			"Weather might be hot - carry water and sun protection",
-			"Respect local customs and dress codes",
-			"Keep emergency contacts handy",
-		},
-		EmergencyContacts: []EmergencyContact{
-			{
-				Name:         "Emergency Services",
-				Relationship: "emergency",
-				Phone:        "112",
-				Available24h: true,
-			},
-			{
-				Name:         "Tourist Helpline",
-				Relationship: "support",
-				Phone:        "1363",
-				Available24h: true,
-			},
-		},
-		TotalCost:    25000,
-		CreatedAt:    time.Now().AddDate(0, 0, -1),
-		LastModified: time.Now(),
-	}, nil
+	if d.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+	trip, _, err := d.firebase.AuthorizeTrip(ctx, tripID, userID, TripActionView)
+	if err != nil {
+		return nil, err
+	}
+	return ItineraryFromTrip(trip), nil
 }
 
 func (d *ItineraryDeliveryService) storeFile(ctx context.Context, fileData []byte, fileName, tripID string) (string, error) {
@@ -968,7 +1277,7 @@ func (d *ItineraryDeliveryService) getDefaultHTMLTemplate() string {
 // GetDeliveryHistory retrieves delivery history for a trip
 func (d *ItineraryDeliveryService) GetDeliveryHistory(ctx context.Context, tripID string) ([]*DeliveryResult, error) {
 	if d.firebase == nil {
-		return nil, fmt.Errorf("firebase service not available")
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
 	}
 
 	docs, err := d.firebase.GetFirestoreClient().
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPreviewItinerary(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	seed(t, fb, "trips/t1", map[string]interface{}{"id": "t1", "user_id": "owner", "title": "Jaipur", "destination": "Jaipur", "status": "planned"})
+	d := &ItineraryDeliveryService{firebase: fb}
+
+	tests := []struct {
+		name           string
+		req            DeliveryRequest
+		wantPrefix     string
+		wantExt        string
+		wantValidation bool
+		wantPermission bool
+		wantNotFound   bool
+	}{
+		{name: "json", req: DeliveryRequest{TripID: "t1", UserID: "owner", Format: FormatJSON}, wantPrefix: "{", wantExt: ".json"},
+		{name: "html", req: DeliveryRequest{TripID: "t1", UserID: "owner", Format: FormatHTML}, wantPrefix: "<!DOCTYPE html>", wantExt: ".html"},
+		{name: "pdf", req: DeliveryRequest{TripID: "t1", UserID: "owner", Format: FormatPDF}, wantPrefix: "%PDF", wantExt: ".pdf"},
+		{name: "calendar can't be previewed", req: DeliveryRequest{TripID: "t1", UserID: "owner", Format: FormatICS}, wantValidation: true},
+		{name: "someone else's trip", req: DeliveryRequest{TripID: "t1", UserID: "stranger", Format: FormatJSON}, wantPermission: true},
+		{name: "missing trip", req: DeliveryRequest{TripID: "nope", UserID: "owner", Format: FormatJSON}, wantNotFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, fileName, err := d.PreviewItinerary(context.Background(), &tt.req)
+			var permission *TripPermissionError
+			if errors.Is(err, ErrValidation) != tt.wantValidation || errors.As(err, &permission) != tt.wantPermission || errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Fatalf("PreviewItinerary = %v, want validation %v, permission %v, not found %v", err, tt.wantValidation, tt.wantPermission, tt.wantNotFound)
+			}
+			if err != nil {
+				return
+			}
+			if !bytes.HasPrefix(bytes.TrimSpace(data), []byte(tt.wantPrefix)) || !strings.HasSuffix(fileName, tt.wantExt) {
+				t.Errorf("preview %q starts %q, want %s file starting %q", fileName, data[:min(len(data), 20)], tt.wantExt, tt.wantPrefix)
+			}
+		})
+	}
+	if fake.count("itinerary_deliveries") != 0 {
+		t.Error("preview stored a delivery record")
+	}
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mergeDateLayout keys merged days by calendar date
+const mergeDateLayout = "2006-01-02"
+
+// MergeItineraries combines two trips into one continuous itinerary, like a
+// work trip followed by a holiday. Days are ordered by date and renumbered
+// from 1. A date planned in both trips keeps both plans, with clashes
+// flagged in the day's Conflicts rather than dropped, and a gap between the
+// trips becomes a single free-time placeholder day, however long it is.
+// Bookings are combined, and costs are recomputed in the earlier trip's
+// currency; an amount that can't be converted is an error. The merged
+// itinerary has no TripID until it's saved.
+func MergeItineraries(a, b *ItineraryData) (*ItineraryData, error) {
+	if a == nil || b == nil {
+		return nil, newKindError(ErrValidation, "two itineraries are required")
+	}
+	if b.StartDate.Before(a.StartDate) {
+		a, b = b, a
+	}
+	currency := a.Currency
+	if currency == "" {
+		currency = b.Currency
+	}
+
+	budget := a.Budget
+	if b.Budget != 0 {
+		converted, err := DefaultExchangeRates().Convert(b.Budget, currencyOr(b.Currency, currency), currency)
+		if err != nil {
+			return nil, kindErrorf(ErrValidation, "can't combine budgets: %w", err)
+		}
+		budget += converted
+	}
+
+	merged := &ItineraryData{
+		Destination:    mergedName(a.Destination, b.Destination, " → "),
+		StartDate:      minTime(a.StartDate, b.StartDate),
+		EndDate:        maxTime(a.EndDate, b.EndDate),
+		Travelers:      max(a.Travelers, b.Travelers),
+		Budget:         roundCost(budget),
+		Currency:       currency,
+		Title:          mergedName(a.Title, b.Title, " + "),
+		Description:    strings.TrimSpace(a.Description + "\n" + b.Description),
+		DailyItinerary: make(map[int]DayItinerary),
+		CreatedAt:      time.Now(),
+		LastModified:   time.Now(),
+	}
+
+	// Collect each trip's days by date, with line items labeled in the
+	// trip's currency so they still add up after merging
+	days := make(map[string][]DayItinerary)
+	for _, trip := range []*ItineraryData{a, b} {
+		for dayNum, day := range trip.DailyItinerary {
+			if day.Date.IsZero() {
+				day.Date = trip.StartDate.AddDate(0, 0, dayNum-1)
+			}
+			day = dayInCurrency(day, currencyOr(trip.Currency, currency))
+			key := day.Date.Format(mergeDateLayout)
+			days[key] = append(days[key], day)
+		}
+	}
+	if len(days) == 0 {
+		return nil, newKindError(ErrValidation, "neither itinerary has any days")
+	}
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	first, _ := time.Parse(mergeDateLayout, dates[0])
+	last, _ := time.Parse(mergeDateLayout, dates[len(dates)-1])
+
+	rates := DefaultExchangeRates()
+	dayNum := 0
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		planned := days[date.Format(mergeDateLayout)]
+		if len(planned) == 0 {
+			// Collapse the whole gap into one placeholder
+			gapEnd := date
+			for next := date.AddDate(0, 0, 1); len(days[next.Format(mergeDateLayout)]) == 0; next = next.AddDate(0, 0, 1) {
+				gapEnd = next
+			}
+			dayNum++
+			merged.DailyItinerary[dayNum] = freeTimeDay(dayNum, date, gapEnd)
+			date = gapEnd
+			continue
+		}
+
+		dayNum++
+		day := planned[0]
+		if len(planned) > 1 {
+			for _, other := range planned[1:] {
+				day = mergeDays(day, other)
+			}
+			merged.ImportantInfo = append(merged.ImportantInfo, fmt.Sprintf("Day %d combines %d plans", dayNum, len(planned)))
+			if len(day.Conflicts) > 0 {
+				merged.ImportantInfo = append(merged.ImportantInfo, fmt.Sprintf("Day %d has %d scheduling conflicts to resolve", dayNum, len(day.Conflicts)))
+			}
+		}
+		day.DayNumber = dayNum
+		total, unconverted := dayCost(day, currency, rates)
+		if len(unconverted) > 0 {
+			return nil, kindErrorf(ErrValidation, "can't total day %d in %s: %s", dayNum, currency, strings.Join(unconverted, ", "))
+		}
+		day.TotalCost = total
+		merged.DailyItinerary[dayNum] = day
+	}
+
+	merged.Hotels = mergeHotels(a, b, currency)
+	for i := 1; i < len(merged.Hotels); i++ {
+		previous, hotel := merged.Hotels[i-1], merged.Hotels[i]
+		if hotel.CheckIn.Before(previous.CheckOut) {
+			merged.ImportantInfo = append(merged.ImportantInfo, fmt.Sprintf("Hotel stays at %s and %s overlap", previous.Name, hotel.Name))
+		}
+	}
+	merged.Transportation = mergeTransport(a, b, currency)
+	merged.Activities = mergeActivityBookings(a, b, currency)
+	merged.ImportantInfo = appendUnique(merged.ImportantInfo, a.ImportantInfo, b.ImportantInfo)
+	merged.EmergencyContacts = mergeEmergencyContacts(a.EmergencyContacts, b.EmergencyContacts)
+	if strings.EqualFold(a.Destination, b.Destination) {
+		merged.EmergencyInfo = a.EmergencyInfo
+		merged.Advisory = a.Advisory
+	}
+
+	total, unconverted := computeTotalCost(merged, rates)
+	if len(unconverted) > 0 {
+		return nil, kindErrorf(ErrValidation, "can't total bookings in %s: %s", currency, strings.Join(unconverted, ", "))
+	}
+	merged.TotalCost = total
+	merged.CarbonFootprintKg = TripCarbonFootprint(merged)
+	return merged, nil
+}
+
+// freeTimeDay is the placeholder for the unplanned dates from start to end
+// between two trips
+func freeTimeDay(dayNum int, start, end time.Time) DayItinerary {
+	day := DayItinerary{
+		Date:      start,
+		DayNumber: dayNum,
+		Title:     "Free day",
+		Notes:     "Nothing planned between the two trips yet",
+	}
+	if count := int(end.Sub(start).Hours()/24) + 1; count > 1 {
+		day.Title = fmt.Sprintf("%d free days", count)
+		day.Notes = fmt.Sprintf("Nothing planned from %s to %s, between the two trips", start.Format("Jan 2"), end.Format("Jan 2, 2006"))
+	}
+	return day
+}
+
+// mergeDays combines two plans for the same date. Items in both plans are
+// kept once; everything else is kept and any clashes are flagged.
+func mergeDays(first, second DayItinerary) DayItinerary {
+	day := first
+	day.Title = mergedName(first.Title, second.Title, " / ")
+	day.Notes = strings.TrimSpace(first.Notes + "\n" + second.Notes)
+	day.Morning = appendActivities(first.Morning, second.Morning)
+	day.Afternoon = appendActivities(first.Afternoon, second.Afternoon)
+	day.Evening = appendActivities(first.Evening, second.Evening)
+	day.Meals = append(append([]Meal{}, first.Meals...), second.Meals...)
+	if day.Weather == nil {
+		day.Weather = second.Weather
+	}
+	day.Conflicts = DetectScheduleConflicts(day)
+	return day
+}
+
+// appendActivities adds the second plan's activities to the first's,
+// skipping ones the first already has
+func appendActivities(first, second []Activity) []Activity {
+	activities := append([]Activity{}, first...)
+	for _, activity := range second {
+		duplicate := false
+		for _, existing := range first {
+			if sameActivity(existing, activity) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			activities = append(activities, activity)
+		}
+	}
+	return activities
+}
+
+// sameActivity reports whether two activities are the same plan, by ID or
+// by name and start time
+func sameActivity(a, b Activity) bool {
+	if a.ID != "" && a.ID == b.ID {
+		return true
+	}
+	return strings.EqualFold(a.Name, b.Name) && a.StartTime.Equal(b.StartTime)
+}
+
+// dayInCurrency labels the day's line items without a currency with the
+// trip's
+func dayInCurrency(day DayItinerary, currency string) DayItinerary {
+	for _, slot := range []*[]Activity{&day.Morning, &day.Afternoon, &day.Evening} {
+		activities := append([]Activity{}, *slot...)
+		for i := range activities {
+			activities[i].Currency = currencyOr(activities[i].Currency, currency)
+		}
+		*slot = activities
+	}
+	meals := append([]Meal{}, day.Meals...)
+	for i := range meals {
+		meals[i].Currency = currencyOr(meals[i].Currency, currency)
+	}
+	day.Meals = meals
+	return day
+}
+
+// mergeHotels combines both trips' hotels by check-in, keeping bookings
+// with the same confirmation number once
+func mergeHotels(a, b *ItineraryData, currency string) []HotelBooking {
+	var hotels []HotelBooking
+	seen := make(map[string]bool)
+	for _, trip := range []*ItineraryData{a, b} {
+		for _, hotel := range trip.Hotels {
+			if hotel.ConfirmationNum != "" {
+				if seen[hotel.ConfirmationNum] {
+					continue
+				}
+				seen[hotel.ConfirmationNum] = true
+			}
+			hotel.Currency = currencyOr(hotel.Currency, currencyOr(trip.Currency, currency))
+			hotels = append(hotels, hotel)
+		}
+	}
+	sort.SliceStable(hotels, func(i, j int) bool {
+		return hotels[i].CheckIn.Before(hotels[j].CheckIn)
+	})
+	return hotels
+}
+
+// mergeTransport combines both trips' transport by departure, keeping
+// bookings with the same reference once
+func mergeTransport(a, b *ItineraryData, currency string) []TransportBooking {
+	var transport []TransportBooking
+	seen := make(map[string]bool)
+	for _, trip := range []*ItineraryData{a, b} {
+		for _, booking := range trip.Transportation {
+			if booking.BookingRef != "" {
+				if seen[booking.BookingRef] {
+					continue
+				}
+				seen[booking.BookingRef] = true
+			}
+			booking.Currency = currencyOr(booking.Currency, currencyOr(trip.Currency, currency))
+			transport = append(transport, booking)
+		}
+	}
+	sort.SliceStable(transport, func(i, j int) bool {
+		return transport[i].DepartureTime.Before(transport[j].DepartureTime)
+	})
+	return transport
+}
+
+// mergeActivityBookings combines both trips' activity bookings by date,
+// keeping bookings with the same reference once
+func mergeActivityBookings(a, b *ItineraryData, currency string) []ActivityBooking {
+	var bookings []ActivityBooking
+	seen := make(map[string]bool)
+	for _, trip := range []*ItineraryData{a, b} {
+		for _, booking := range trip.Activities {
+			if booking.BookingRef != "" {
+				if seen[booking.BookingRef] {
+					continue
+				}
+				seen[booking.BookingRef] = true
+			}
+			booking.Currency = currencyOr(booking.Currency, currencyOr(trip.Currency, currency))
+			bookings = append(bookings, booking)
+		}
+	}
+	sort.SliceStable(bookings, func(i, j int) bool {
+		return bookings[i].Date.Before(bookings[j].Date)
+	})
+	return bookings
+}
+
+// mergeEmergencyContacts combines contact lists, keeping each phone number
+// once
+func mergeEmergencyContacts(first, second []EmergencyContact) []EmergencyContact {
+	var contacts []EmergencyContact
+	seen := make(map[string]bool)
+	for _, contact := range append(append([]EmergencyContact{}, first...), second...) {
+		if seen[contact.Phone] {
+			continue
+		}
+		seen[contact.Phone] = true
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
+
+// appendUnique appends the lists' lines to lines, skipping repeats
+func appendUnique(lines []string, lists ...[]string) []string {
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		seen[line] = true
+	}
+	for _, list := range lists {
+		for _, line := range list {
+			if !seen[line] {
+				seen[line] = true
+				lines = append(lines, line)
+			}
+		}
+	}
+	return lines
+}
+
+// mergedName joins two names, or gives one when they're the same or the
+// other is empty
+func mergedName(first, second, separator string) string {
+	switch {
+	case second == "" || strings.EqualFold(first, second):
+		return first
+	case first == "":
+		return second
+	default:
+		return first + separator + second
+	}
+}
+
+// currencyOr returns currency, or fallback when it's empty
+func currencyOr(currency, fallback string) string {
+	if currency == "" {
+		return fallback
+	}
+	return currency
+}
+
+// minTime returns the earlier time, ignoring a zero one
+func minTime(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+	return a
+}
+
+// maxTime returns the later time
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mergeTrip is a trip of one-activity days starting at start
+func mergeTrip(title string, start time.Time, currency string, activities ...string) *ItineraryData {
+	trip := &ItineraryData{
+		Title:          title,
+		Destination:    title,
+		StartDate:      start,
+		EndDate:        start.AddDate(0, 0, len(activities)-1),
+		Currency:       currency,
+		DailyItinerary: make(map[int]DayItinerary),
+	}
+	for i, name := range activities {
+		date := start.AddDate(0, 0, i)
+		trip.DailyItinerary[i+1] = DayItinerary{
+			Date:      date,
+			DayNumber: i + 1,
+			Title:     name,
+			Morning: []Activity{{
+				Name:      name,
+				StartTime: date.Add(10 * time.Hour),
+				EndTime:   date.Add(12 * time.Hour),
+				Cost:      100,
+			}},
+		}
+	}
+	return trip
+}
+
+func TestMergeItineraries(t *testing.T) {
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		a, b       *ItineraryData
+		wantDays   int
+		wantTitles []string
+		wantTotal  float64
+	}{
+		{
+			name:       "back to back",
+			a:          mergeTrip("Mumbai", start, "INR", "Gateway", "Elephanta"),
+			b:          mergeTrip("Goa", start.AddDate(0, 0, 2), "INR", "Baga", "Old Goa"),
+			wantDays:   4,
+			wantTitles: []string{"Gateway", "Elephanta", "Baga", "Old Goa"},
+			wantTotal:  400,
+		},
+		{
+			name:       "overlapping",
+			a:          mergeTrip("Mumbai", start, "INR", "Gateway", "Elephanta"),
+			b:          mergeTrip("Pune", start.AddDate(0, 0, 1), "INR", "Shaniwar Wada", "Sinhagad"),
+			wantDays:   3,
+			wantTitles: []string{"Gateway", "Elephanta / Shaniwar Wada", "Sinhagad"},
+			wantTotal:  400,
+		},
+		{
+			name:       "a year apart",
+			a:          mergeTrip("Mumbai", start, "INR", "Gateway"),
+			b:          mergeTrip("Goa", start.AddDate(1, 0, 0), "INR", "Baga"),
+			wantDays:   3,
+			wantTitles: []string{"Gateway", "364 free days", "Baga"},
+			wantTotal:  200,
+		},
+		{
+			name:       "one day gap",
+			a:          mergeTrip("Mumbai", start, "INR", "Gateway"),
+			b:          mergeTrip("Goa", start.AddDate(0, 0, 2), "INR", "Baga"),
+			wantDays:   3,
+			wantTitles: []string{"Gateway", "Free day", "Baga"},
+			wantTotal:  200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, err := MergeItineraries(tt.b, tt.a)
+			if err != nil {
+				t.Fatalf("MergeItineraries: %v", err)
+			}
+			if len(merged.DailyItinerary) != tt.wantDays {
+				t.Fatalf("merged %d days, want %d", len(merged.DailyItinerary), tt.wantDays)
+			}
+			for i, title := range tt.wantTitles {
+				if day := merged.DailyItinerary[i+1]; day.Title != title || day.DayNumber != i+1 {
+					t.Errorf("day %d = %d %q, want %q", i+1, day.DayNumber, day.Title, title)
+				}
+			}
+			if merged.TotalCost != tt.wantTotal {
+				t.Errorf("total = %v, want %v", merged.TotalCost, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestMergeItinerariesMergesEveryPlanForADate(t *testing.T) {
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	a := mergeTrip("Mumbai", start, "INR", "Gateway")
+	b := mergeTrip("Mumbai", start, "INR", "Colaba Causeway", "Marine Drive")
+	// A second plan for the first date in the same trip
+	extra := b.DailyItinerary[2]
+	extra.Date = start
+	b.DailyItinerary[2] = extra
+
+	merged, err := MergeItineraries(a, b)
+	if err != nil {
+		t.Fatalf("MergeItineraries: %v", err)
+	}
+	day := merged.DailyItinerary[1]
+	if len(merged.DailyItinerary) != 1 || len(day.Morning) != 3 {
+		t.Fatalf("merged days = %+v, want one day with all three activities", merged.DailyItinerary)
+	}
+	if len(day.Conflicts) == 0 {
+		t.Error("overlapping 10:00 activities not flagged as conflicts")
+	}
+}
+
+func TestMergeItinerariesRejectsUnknownCurrencies(t *testing.T) {
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	a := mergeTrip("Mumbai", start, "INR", "Gateway")
+	b := mergeTrip("Atlantis", start.AddDate(0, 0, 1), "XTS", "Trench")
+
+	_, err := MergeItineraries(a, b)
+	if !errors.Is(err, ErrValidation) || !strings.Contains(err.Error(), "Trench") {
+		t.Errorf("MergeItineraries = %v, want a validation error naming the unconverted item", err)
+	}
+}
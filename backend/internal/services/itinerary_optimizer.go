@@ -0,0 +1,346 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	// citySpeedKmh is the assumed average door-to-door speed in a city,
+	// used to turn straight-line distance into travel time
+	citySpeedKmh = 25.0
+	// optimizerDayStart is the default start of a day, in minutes after midnight
+	optimizerDayStart = 9 * 60
+	// optimizerMealDuration is how long a scheduled meal blocks the day
+	optimizerMealDuration = 60 * time.Minute
+	// optimizerDefaultDuration is used for activities without a duration
+	optimizerDefaultDuration = 60 * time.Minute
+)
+
+// OptimizedDay is one day of an optimized itinerary
+type OptimizedDay struct {
+	Day                models.DayPlanDetails `json:"day"`
+	Reordered          bool                  `json:"reordered"`
+	DistanceBeforeKm   float64               `json:"distance_before_km"`
+	DistanceAfterKm    float64               `json:"distance_after_km"`
+	TravelMinutesSaved float64               `json:"travel_minutes_saved"`
+}
+
+// ItineraryOptimization is the result of OptimizeDayRoutes
+type ItineraryOptimization struct {
+	Days []OptimizedDay `json:"days"`
+	// OptimizationScore is the total travel time saved, in minutes
+	OptimizationScore float64 `json:"optimization_score"`
+}
+
+// OptimizeDayRoutes reorders each day's activities to minimize travel between
+// them. Routes are built with nearest-neighbor and refined with 2-opt, keeping
+// the day's first activity as the starting point. A reordering is only kept
+// when it still fits every activity inside its opening hours and around the
+// day's scheduled meals; otherwise the original order stays.
+//
+// Activities without coordinates can't be routed and are kept, in their
+// original relative order, after the routed ones.
+func OptimizeDayRoutes(days []models.DayPlanDetails) *ItineraryOptimization {
+	result := &ItineraryOptimization{}
+
+	for _, day := range days {
+		optimized := optimizeDay(day)
+		result.Days = append(result.Days, optimized)
+		result.OptimizationScore += optimized.TravelMinutesSaved
+	}
+
+	return result
+}
+
+// Reordered reports whether any day's activities were reordered
+func (o *ItineraryOptimization) Reordered() bool {
+	for _, day := range o.Days {
+		if day.Reordered {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveOptimizedDays stores the new order of each reordered day as its
+// activities' scheduled times, provided the trip is still at baseVersion,
+// and returns the trip's new version. A trip changed since returns
+// *TripVersionConflictError and nothing is written.
+func (f *FirebaseService) SaveOptimizedDays(ctx context.Context, tripID string, baseVersion int64, optimization *ItineraryOptimization) (int64, error) {
+	updates := []firestore.Update{{Path: "optimization_score", Value: optimization.OptimizationScore}}
+	return f.updateTripAtVersion(ctx, tripID, baseVersion, updates, func(tx *firestore.Transaction, _ *TripData) error {
+		for _, day := range optimization.Days {
+			if !day.Reordered {
+				continue
+			}
+			for _, activity := range day.Day.Activities {
+				if activity.ID == "" || activity.ScheduledTime == nil {
+					continue
+				}
+				// Activity rows are stored through their JSON form
+				err := tx.Update(f.firestore.Collection("activities").Doc(activity.ID), []firestore.Update{
+					{Path: "scheduled_time", Value: activity.ScheduledTime.Format(time.RFC3339Nano)},
+					{Path: "updated_at", Value: time.Now().Format(time.RFC3339Nano)},
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func optimizeDay(day models.DayPlanDetails) OptimizedDay {
+	var routed, unrouted []models.Activity
+	for _, activity := range day.Activities {
+		if hasCoordinates(activity.Location) {
+			routed = append(routed, activity)
+		} else {
+			unrouted = append(unrouted, activity)
+		}
+	}
+
+	original := append(append([]models.Activity{}, routed...), unrouted...)
+	before := routeDistanceKm(routed)
+	result := OptimizedDay{
+		Day:              day,
+		DistanceBeforeKm: before,
+		DistanceAfterKm:  before,
+	}
+
+	// Nothing to reorder with fewer than three stops when the start is fixed
+	if len(routed) < 3 {
+		return result
+	}
+
+	nearest := nearestNeighborRoute(routed)
+	improved := twoOptRoute(nearest)
+
+	// Prefer the shortest candidate that still respects the schedule
+	best := routed
+	bestDistance := before
+	for _, candidate := range [][]models.Activity{improved, nearest} {
+		distance := routeDistanceKm(candidate)
+		if distance >= bestDistance {
+			continue
+		}
+		ordered := append(append([]models.Activity{}, candidate...), unrouted...)
+		if _, ok := scheduleDay(day, ordered); ok {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	if bestDistance >= before {
+		return result
+	}
+
+	ordered := append(append([]models.Activity{}, best...), unrouted...)
+	times, _ := scheduleDay(day, ordered)
+	for i := range ordered {
+		scheduled := times[i]
+		ordered[i].ScheduledTime = &scheduled
+	}
+
+	result.Day.Activities = ordered
+	result.Reordered = !sameOrder(original, ordered)
+	result.DistanceAfterKm = bestDistance
+	result.TravelMinutesSaved = (before - bestDistance) / citySpeedKmh * 60
+	return result
+}
+
+// nearestNeighborRoute builds a route from the first stop by always visiting
+// the closest unvisited stop next
+func nearestNeighborRoute(stops []models.Activity) []models.Activity {
+	route := []models.Activity{stops[0]}
+	visited := make([]bool, len(stops))
+	visited[0] = true
+
+	for len(route) < len(stops) {
+		last := route[len(route)-1]
+		next := -1
+		var nextDistance float64
+		for i, stop := range stops {
+			if visited[i] {
+				continue
+			}
+			d := activityDistanceKm(last, stop)
+			if next == -1 || d < nextDistance {
+				next, nextDistance = i, d
+			}
+		}
+		visited[next] = true
+		route = append(route, stops[next])
+	}
+
+	return route
+}
+
+// twoOptRoute repeatedly reverses route segments while that shortens the
+// route. The first stop stays fixed and the route is open (no return leg).
+func twoOptRoute(route []models.Activity) []models.Activity {
+	best := append([]models.Activity{}, route...)
+
+	for improved := true; improved; {
+		improved = false
+		for i := 1; i < len(best)-1; i++ {
+			for j := i + 1; j < len(best); j++ {
+				// Replace edges (i-1,i) and (j,j+1) with (i-1,j) and (i,j+1)
+				delta := activityDistanceKm(best[i-1], best[j]) - activityDistanceKm(best[i-1], best[i])
+				if j+1 < len(best) {
+					delta += activityDistanceKm(best[i], best[j+1]) - activityDistanceKm(best[j], best[j+1])
+				}
+				if delta < -1e-9 {
+					for l, r := i, j; l < r; l, r = l+1, r-1 {
+						best[l], best[r] = best[r], best[l]
+					}
+					improved = true
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// scheduleDay assigns start times to activities in order, waiting for opening
+// hours and stepping around meals. It reports false when an activity can't
+// finish before closing time.
+func scheduleDay(day models.DayPlanDetails, activities []models.Activity) ([]time.Time, bool) {
+	dayStart := optimizerStartTime(day)
+
+	type window struct{ start, end time.Time }
+	var meals []window
+	for _, meal := range day.Meals {
+		if meal.ScheduledTime != nil {
+			meals = append(meals, window{*meal.ScheduledTime, meal.ScheduledTime.Add(optimizerMealDuration)})
+		}
+	}
+
+	times := make([]time.Time, len(activities))
+	current := dayStart
+	feasible := true
+
+	for i, activity := range activities {
+		if i > 0 && hasCoordinates(activity.Location) && hasCoordinates(activities[i-1].Location) {
+			travel := activityDistanceKm(activities[i-1], activity) / citySpeedKmh * 60
+			current = current.Add(time.Duration(math.Ceil(travel)) * time.Minute)
+		}
+
+		duration := time.Duration(activity.Duration) * time.Minute
+		if duration <= 0 {
+			duration = optimizerDefaultDuration
+		}
+
+		opensMin, closesMin, hasHours := parseOpeningHours(activity.OpeningHours)
+		midnight := time.Date(current.Year(), current.Month(), current.Day(), 0, 0, 0, 0, current.Location())
+		if hasHours {
+			if opensAt := midnight.Add(time.Duration(opensMin) * time.Minute); current.Before(opensAt) {
+				current = opensAt
+			}
+		}
+
+		// Step past any meal the activity would overlap
+		for moved := true; moved; {
+			moved = false
+			for _, meal := range meals {
+				if current.Before(meal.end) && current.Add(duration).After(meal.start) {
+					current = meal.end
+					moved = true
+				}
+			}
+		}
+
+		if hasHours && current.Add(duration).After(midnight.Add(time.Duration(closesMin)*time.Minute)) {
+			feasible = false
+		}
+
+		times[i] = current
+		current = current.Add(duration)
+	}
+
+	return times, feasible
+}
+
+// optimizerStartTime is the earliest scheduled activity of the day, or the
+// default day start on the day's date
+func optimizerStartTime(day models.DayPlanDetails) time.Time {
+	var start time.Time
+	for _, activity := range day.Activities {
+		if activity.ScheduledTime != nil && (start.IsZero() || activity.ScheduledTime.Before(start)) {
+			start = *activity.ScheduledTime
+		}
+	}
+	if !start.IsZero() {
+		return start
+	}
+
+	date := day.DayPlan.Date
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location()).
+		Add(optimizerDayStart * time.Minute)
+}
+
+// parseOpeningHours reads an activity's opening hours as minutes after
+// midnight. Both {"open":"09:00","close":"17:00"} and "09:00-17:00" are
+// accepted.
+func parseOpeningHours(value string) (int, int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, false
+	}
+
+	var openStr, closeStr string
+	var hours struct {
+		Open  string `json:"open"`
+		Close string `json:"close"`
+	}
+	if err := json.Unmarshal([]byte(value), &hours); err == nil {
+		openStr, closeStr = hours.Open, hours.Close
+	} else if parts := strings.SplitN(value, "-", 2); len(parts) == 2 {
+		openStr, closeStr = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	}
+
+	opens, okOpen := parseClock(openStr)
+	closes, okClose := parseClock(closeStr)
+	if !okOpen || !okClose || closes <= opens {
+		return 0, 0, false
+	}
+	return opens, closes, true
+}
+
+func routeDistanceKm(route []models.Activity) float64 {
+	var total float64
+	for i := 1; i < len(route); i++ {
+		total += activityDistanceKm(route[i-1], route[i])
+	}
+	return total
+}
+
+func activityDistanceKm(a, b models.Activity) float64 {
+	return haversineKm(
+		Location{Latitude: a.Location.Latitude, Longitude: a.Location.Longitude},
+		Location{Latitude: b.Location.Latitude, Longitude: b.Location.Longitude},
+	)
+}
+
+func hasCoordinates(location models.Location) bool {
+	return location.Latitude != 0 || location.Longitude != 0
+}
+
+func sameOrder(a, b []models.Activity) bool {
+	for i := range a {
+		if a[i].ID != b[i].ID || a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"auratravel-backend/internal/models"
+)
+
+// stop is an activity at a longitude along one latitude, so distances
+// between stops are proportional to the longitude difference
+func stop(name string, longitude float64) models.Activity {
+	return models.Activity{ID: strings.ToLower(name), Name: name, Duration: 60, Location: models.Location{Latitude: 28.6, Longitude: longitude}}
+}
+
+func activityNames(activities []models.Activity) string {
+	names := make([]string, len(activities))
+	for i, activity := range activities {
+		names[i] = activity.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func optimizerDay(activities ...models.Activity) models.DayPlanDetails {
+	return models.DayPlanDetails{
+		DayPlan:    models.DayPlan{Date: time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC)},
+		Activities: activities,
+	}
+}
+
+func TestOptimizeDayRoutes(t *testing.T) {
+	zigzag := []models.Activity{stop("A", 77.00), stop("B", 77.04), stop("C", 77.01), stop("D", 77.03), stop("E", 77.02)}
+	closesEarly := append([]models.Activity{}, zigzag...)
+	closesEarly[1].OpeningHours = "09:00-12:00"
+	unrouted := models.Activity{Name: "Show", Duration: 90}
+
+	tests := []struct {
+		name          string
+		day           models.DayPlanDetails
+		wantOrder     string
+		wantReordered bool
+	}{
+		{"zigzag is straightened", optimizerDay(zigzag...), "A,C,E,D,B", true},
+		{"unroutable activities go last", optimizerDay(append([]models.Activity{unrouted}, zigzag...)...), "A,C,E,D,B,Show", true},
+		{"kept when the new order misses opening hours", optimizerDay(closesEarly...), "A,B,C,D,E", false},
+		{"two stops are left alone", optimizerDay(stop("A", 77.00), stop("B", 77.04)), "A,B", false},
+		{"already shortest", optimizerDay(stop("A", 77.00), stop("B", 77.01), stop("C", 77.02)), "A,B,C", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := OptimizeDayRoutes([]models.DayPlanDetails{tt.day})
+			day := result.Days[0]
+			if got := activityNames(day.Day.Activities); got != tt.wantOrder {
+				t.Errorf("order = %s, want %s", got, tt.wantOrder)
+			}
+			if day.Reordered != tt.wantReordered || result.Reordered() != tt.wantReordered {
+				t.Errorf("reordered = %v, want %v", day.Reordered, tt.wantReordered)
+			}
+			if !tt.wantReordered {
+				if day.DistanceAfterKm != day.DistanceBeforeKm || result.OptimizationScore != 0 {
+					t.Errorf("distance %.2f -> %.2f km, score %.1f; want unchanged", day.DistanceBeforeKm, day.DistanceAfterKm, result.OptimizationScore)
+				}
+				return
+			}
+			if day.DistanceAfterKm >= day.DistanceBeforeKm || day.TravelMinutesSaved <= 0 || result.OptimizationScore != day.TravelMinutesSaved {
+				t.Errorf("distance %.2f -> %.2f km, saved %.1f min, score %.1f; want a shorter route", day.DistanceBeforeKm, day.DistanceAfterKm, day.TravelMinutesSaved, result.OptimizationScore)
+			}
+			for i := 1; i < len(day.Day.Activities); i++ {
+				if !day.Day.Activities[i].ScheduledTime.After(*day.Day.Activities[i-1].ScheduledTime) {
+					t.Errorf("activity %d scheduled at %v, not after the one before", i, day.Day.Activities[i].ScheduledTime)
+				}
+			}
+		})
+	}
+}
+
+func TestTwoOptRoute(t *testing.T) {
+	tests := []struct {
+		name  string
+		route []models.Activity
+		want  string
+	}{
+		{"crossing edges are uncrossed", []models.Activity{stop("A", 77.00), stop("C", 77.02), stop("B", 77.01), stop("D", 77.03)}, "A,B,C,D"},
+		{"reversed tail", []models.Activity{stop("A", 77.00), stop("D", 77.03), stop("C", 77.02), stop("B", 77.01)}, "A,B,C,D"},
+		{"start stays first", []models.Activity{stop("B", 77.01), stop("A", 77.00), stop("C", 77.02)}, "B,A,C"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := twoOptRoute(tt.route)
+			if names := activityNames(got); names != tt.want {
+				t.Errorf("twoOptRoute = %s, want %s", names, tt.want)
+			}
+			if routeDistanceKm(got) > routeDistanceKm(tt.route) {
+				t.Errorf("route grew from %.2f to %.2f km", routeDistanceKm(tt.route), routeDistanceKm(got))
+			}
+		})
+	}
+}
+
+func TestScheduleDay(t *testing.T) {
+	day := optimizerDay()
+	at := func(hour, minute int) time.Time {
+		return time.Date(2026, 4, 2, hour, minute, 0, 0, time.UTC)
+	}
+	lunch := at(12, 0)
+	day.Meals = []models.Meal{{Name: "Lunch", ScheduledTime: &lunch}}
+
+	museum := models.Activity{Name: "Museum", Duration: 120, OpeningHours: `{"open":"10:00","close":"18:00"}`}
+	walk := models.Activity{Name: "Walk", Duration: 30}
+	latePark := models.Activity{Name: "Park", Duration: 60, OpeningHours: "09:00-13:00"}
+
+	tests := []struct {
+		name         string
+		activities   []models.Activity
+		want         []time.Time
+		wantFeasible bool
+	}{
+		{"waits for opening", []models.Activity{museum}, []time.Time{at(10, 0)}, true},
+		{"steps around lunch", []models.Activity{museum, walk}, []time.Time{at(10, 0), at(13, 0)}, true},
+		{"misses closing time", []models.Activity{museum, latePark}, []time.Time{at(10, 0), at(13, 0)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			times, feasible := scheduleDay(day, tt.activities)
+			if feasible != tt.wantFeasible {
+				t.Errorf("feasible = %v, want %v", feasible, tt.wantFeasible)
+			}
+			for i := range tt.want {
+				if !times[i].Equal(tt.want[i]) {
+					t.Errorf("activity %d at %s, want %s", i, times[i].Format("15:04"), tt.want[i].Format("15:04"))
+				}
+			}
+		})
+	}
+}
+
+func TestSaveOptimizedDaysChecksVersion(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	seed(t, fb, "trips/t1", map[string]interface{}{"user_id": "u1", "version": 3})
+	for _, id := range []string{"a", "b", "c"} {
+		seed(t, fb, "activities/"+id, map[string]interface{}{"trip_id": "t1", "scheduled_time": "2026-04-02T09:00:00Z"})
+	}
+
+	optimization := OptimizeDayRoutes([]models.DayPlanDetails{optimizerDay(stop("A", 77.00), stop("C", 77.02), stop("B", 77.01))})
+	if !optimization.Reordered() {
+		t.Fatal("expected the day to be reordered")
+	}
+
+	version, err := fb.SaveOptimizedDays(context.Background(), "t1", 3, optimization)
+	if err != nil || version != 4 {
+		t.Fatalf("SaveOptimizedDays = %d, %v; want version 4", version, err)
+	}
+	saved := map[string]string{}
+	for _, id := range []string{"a", "b", "c"} {
+		saved[id] = fake.fields("activities/" + id)["scheduled_time"].GetStringValue()
+	}
+	if !(saved["a"] < saved["b"] && saved["b"] < saved["c"]) {
+		t.Errorf("saved times = %v, want a before b before c", saved)
+	}
+
+	// A second save from the same read conflicts and writes nothing
+	seed(t, fb, "activities/a", map[string]interface{}{"trip_id": "t1", "scheduled_time": "unchanged"})
+	var conflict *TripVersionConflictError
+	if _, err := fb.SaveOptimizedDays(context.Background(), "t1", 3, optimization); !errors.As(err, &conflict) {
+		t.Fatalf("stale SaveOptimizedDays = %v, want a version conflict", err)
+	}
+	if got := fake.fields("activities/a")["scheduled_time"].GetStringValue(); got != "unchanged" {
+		t.Errorf("stale save wrote scheduled_time %s", got)
+	}
+}
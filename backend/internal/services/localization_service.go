@@ -5,16 +5,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // LocalizationService handles multilingual support and localization
 type LocalizationService struct {
-	gemini           *GeminiService
+	gemini           AIGenerator
 	firebase         *FirebaseService
 	supportedLocales map[string]*LocaleConfig
 	defaultLocale    string
+	zones            *ZoneResolver
 }
 
 // LocaleConfig represents configuration for a specific locale
@@ -32,10 +35,42 @@ type LocaleConfig struct {
 	Translations   map[string]string      `json:"translations"`    // Key-value pairs for common terms
 	GeminiPrompts  map[string]string      `json:"gemini_prompts"`  // Localized Gemini prompts
 	RegionalData   map[string]interface{} `json:"regional_data"`   // Region-specific preferences
+
+	// DecimalPlaces is how many decimals fractional numbers are shown with.
+	// Whole numbers are always shown without any.
+	DecimalPlaces int `json:"decimal_places"`
+	// CurrencyDecimalPlaces overrides the decimals amounts in a currency are
+	// shown with, e.g. {"INR": 0} for whole rupees. Currencies not listed use
+	// their ISO 4217 minor units.
+	CurrencyDecimalPlaces map[string]int `json:"currency_decimal_places,omitempty"`
+}
+
+// defaultDecimalPlaces is used for numbers and for currencies without known
+// minor units
+const defaultDecimalPlaces = 2
+
+// currencyMinorUnits are the ISO 4217 decimals of currencies that don't use
+// two
+var currencyMinorUnits = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "IDR": 0, "CLP": 0, "ISK": 0, "UGX": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3, "LYD": 3, "IQD": 3,
+}
+
+// currencyDecimalPlaces returns how many decimals amounts in currency are
+// shown with in the locale
+func (c *LocaleConfig) currencyDecimalPlaces(currency string) int {
+	currency = strings.ToUpper(currency)
+	if places, ok := c.CurrencyDecimalPlaces[currency]; ok {
+		return places
+	}
+	if places, ok := currencyMinorUnits[currency]; ok {
+		return places
+	}
+	return defaultDecimalPlaces
 }
 
 // NewLocalizationService creates a new localization service
-func NewLocalizationService(gemini *GeminiService, firebase *FirebaseService) *LocalizationService {
+func NewLocalizationService(gemini AIGenerator, firebase *FirebaseService) *LocalizationService {
 	service := &LocalizationService{
 		gemini:           gemini,
 		firebase:         firebase,
@@ -61,6 +96,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 		DateFormat:     "DD/MM/YYYY",
 		TimeFormat:     "12h",
 		NumberFormat:   "1,23,456",
+		DecimalPlaces:  2,
 		RTL:            false,
 		Timezone:       "Asia/Kolkata",
 		Translations: map[string]string{
@@ -110,6 +146,10 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"business":        "Business",
 			"solo_travel":     "Solo Travel",
 			"group_travel":    "Group Travel",
+			// Notification templates; {{placeholders}} are filled by LocalizeMessage
+			"replan_title":    "Trip Update",
+			"replan_updated":  "Your {{destination}} itinerary has been updated with {{count}} changes due to real-time conditions. Tap to view details.",
+			"replan_reviewed": "Your {{destination}} itinerary has been reviewed - no changes needed.",
 		},
 		GeminiPrompts: map[string]string{
 			"generate_itinerary":       "Generate a detailed travel itinerary for {{destination}} with the following requirements:",
@@ -122,6 +162,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"cultural_experiences":     "Suggest cultural and historical experiences in {{destination}}:",
 			"shopping_guide":           "Provide a shopping guide for {{destination}} including markets and specialty items:",
 			"transportation_guide":     "Explain transportation options and tips for getting around {{destination}}:",
+			"chat":                     "You are AuraTravel's travel assistant. Answer the traveler's message in English, briefly and helpfully:",
 		},
 		RegionalData: map[string]interface{}{
 			"preferred_meal_times": map[string]string{
@@ -147,6 +188,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 		DateFormat:     "DD/MM/YYYY",
 		TimeFormat:     "12h",
 		NumberFormat:   "1,23,456",
+		DecimalPlaces:  2,
 		RTL:            false,
 		Timezone:       "Asia/Kolkata",
 		Translations: map[string]string{
@@ -196,6 +238,10 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"business":        "व्यापारिक",
 			"solo_travel":     "अकेली यात्रा",
 			"group_travel":    "समूहिक यात्रा",
+			// Notification templates; {{placeholders}} are filled by LocalizeMessage
+			"replan_title":    "यात्रा अपडेट",
+			"replan_updated":  "वास्तविक समय की स्थितियों के कारण आपके {{destination}} यात्रा कार्यक्रम में {{count}} बदलाव किए गए हैं। विवरण देखने के लिए टैप करें।",
+			"replan_reviewed": "आपके {{destination}} यात्रा कार्यक्रम की समीक्षा की गई - किसी बदलाव की आवश्यकता नहीं है।",
 		},
 		GeminiPrompts: map[string]string{
 			"generate_itinerary":       "{{destination}} के लिए निम्नलिखित आवश्यकताओं के साथ एक विस्तृत यात्रा कार्यक्रम बनाएं:",
@@ -208,6 +254,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"cultural_experiences":     "{{destination}} में सांस्कृतिक और ऐतिहासिक अनुभवों का सुझाव दें:",
 			"shopping_guide":           "बाजारों और विशेष वस्तुओं सहित {{destination}} के लिए एक खरीदारी गाइड प्रदान करें:",
 			"transportation_guide":     "{{destination}} में घूमने के लिए परिवहन विकल्प और सुझाव समझाएं:",
+			"chat":                     "आप AuraTravel के यात्रा सहायक हैं। यात्री के संदेश का उत्तर हिंदी में, संक्षेप में और मददगार तरीके से दें:",
 		},
 		RegionalData: map[string]interface{}{
 			"preferred_meal_times": map[string]string{
@@ -233,6 +280,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 		DateFormat:     "DD/MM/YYYY",
 		TimeFormat:     "12h",
 		NumberFormat:   "1,23,456",
+		DecimalPlaces:  2,
 		RTL:            false,
 		Timezone:       "Asia/Kolkata",
 		Translations: map[string]string{
@@ -282,6 +330,10 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"business":        "ব্যবসায়িক",
 			"solo_travel":     "একা ভ্রমণ",
 			"group_travel":    "দলীয় ভ্রমণ",
+			// Notification templates; {{placeholders}} are filled by LocalizeMessage
+			"replan_title":    "ভ্রমণ আপডেট",
+			"replan_updated":  "রিয়েল-টাইম পরিস্থিতির কারণে আপনার {{destination}} ভ্রমণসূচিতে {{count}}টি পরিবর্তন করা হয়েছে। বিস্তারিত দেখতে ট্যাপ করুন।",
+			"replan_reviewed": "আপনার {{destination}} ভ্রমণসূচি পর্যালোচনা করা হয়েছে - কোনো পরিবর্তনের প্রয়োজন নেই।",
 		},
 		GeminiPrompts: map[string]string{
 			"generate_itinerary":       "{{destination}} এর জন্য নিম্নলিখিত প্রয়োজনীয়তার সাথে একটি বিস্তারিত ভ্রমণসূচি তৈরি করুন:",
@@ -294,6 +346,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"cultural_experiences":     "{{destination}} এ সাংস্কৃতিক এবং ঐতিহাসিক অভিজ্ঞতার পরামর্শ দিন:",
 			"shopping_guide":           "বাজার এবং বিশেষ পণ্য সহ {{destination}} এর জন্য একটি কেনাকাটা গাইড প্রদান করুন:",
 			"transportation_guide":     "{{destination}} এ ঘোরাফেরার জন্য পরিবহন বিকল্প এবং টিপস ব্যাখ্যা করুন:",
+			"chat":                     "আপনি AuraTravel-এর ভ্রমণ সহকারী। যাত্রীর বার্তার উত্তর বাংলায়, সংক্ষেপে এবং সহায়কভাবে দিন:",
 		},
 		RegionalData: map[string]interface{}{
 			"preferred_meal_times": map[string]string{
@@ -319,6 +372,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 		DateFormat:     "DD/MM/YYYY",
 		TimeFormat:     "12h",
 		NumberFormat:   "1,23,456",
+		DecimalPlaces:  2,
 		RTL:            false,
 		Timezone:       "Asia/Kolkata",
 		Translations: map[string]string{
@@ -368,6 +422,10 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"business":        "வணிகம்",
 			"solo_travel":     "தனி பயணம்",
 			"group_travel":    "குழு பயணம்",
+			// Notification templates; {{placeholders}} are filled by LocalizeMessage
+			"replan_title":    "பயண புதுப்பிப்பு",
+			"replan_updated":  "நிகழ்நேர சூழ்நிலைகள் காரணமாக உங்கள் {{destination}} பயணத் திட்டத்தில் {{count}} மாற்றங்கள் செய்யப்பட்டுள்ளன. விவரங்களைக் காண தட்டவும்.",
+			"replan_reviewed": "உங்கள் {{destination}} பயணத் திட்டம் மதிப்பாய்வு செய்யப்பட்டது - மாற்றங்கள் தேவையில்லை.",
 		},
 		GeminiPrompts: map[string]string{
 			"generate_itinerary":       "{{destination}} க்கான பின்வரும் தேவைகளுடன் விரிவான பயணத் திட்டத்தை உருவாக்குங்கள்:",
@@ -380,6 +438,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"cultural_experiences":     "{{destination}} இல் கலாச்சார மற்றும் வரலாற்று அனுபவங்களை பரிந்துரைக்கவும்:",
 			"shopping_guide":           "சந்தைகள் மற்றும் சிறப்பு பொருட்கள் உட்பட {{destination}} க்கான ஷாப்பிங் வழிகாட்டியை வழங்கவும்:",
 			"transportation_guide":     "{{destination}} இல் நகர்வதற்கான போக்குவரத்து விருப்பங்கள் மற்றும் குறிப்புகளை விளக்கவும்:",
+			"chat":                     "நீங்கள் AuraTravel இன் பயண உதவியாளர். பயணியின் செய்திக்கு தமிழில் சுருக்கமாகவும் பயனுள்ளதாகவும் பதிலளிக்கவும்:",
 		},
 		RegionalData: map[string]interface{}{
 			"preferred_meal_times": map[string]string{
@@ -405,6 +464,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 		DateFormat:     "DD/MM/YYYY",
 		TimeFormat:     "12h",
 		NumberFormat:   "1,23,456",
+		DecimalPlaces:  2,
 		RTL:            false,
 		Timezone:       "Asia/Kolkata",
 		Translations: map[string]string{
@@ -454,6 +514,10 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"business":        "व्यवसाय",
 			"solo_travel":     "एकट्या प्रवास",
 			"group_travel":    "गट प्रवास",
+			// Notification templates; {{placeholders}} are filled by LocalizeMessage
+			"replan_title":    "प्रवास अपडेट",
+			"replan_updated":  "रिअल-टाइम परिस्थितीमुळे तुमच्या {{destination}} प्रवास कार्यक्रमात {{count}} बदल करण्यात आले आहेत. तपशील पाहण्यासाठी टॅप करा.",
+			"replan_reviewed": "तुमच्या {{destination}} प्रवास कार्यक्रमाचे पुनरावलोकन केले - कोणत्याही बदलांची गरज नाही.",
 		},
 		GeminiPrompts: map[string]string{
 			"generate_itinerary":       "{{destination}} साठी खालील आवश्यकतांसह तपशीलवार प्रवास कार्यक्रम तयार करा:",
@@ -466,6 +530,7 @@ func (l *LocalizationService) initializeSupportedLocales() {
 			"cultural_experiences":     "{{destination}} मध्ये सांस्कृतिक आणि ऐतिहासिक अनुभवांचे सुझाव द्या:",
 			"shopping_guide":           "बाजार आणि विशेष वस्तूंसह {{destination}} साठी खरेदी मार्गदर्शक प्रदान करा:",
 			"transportation_guide":     "{{destination}} मध्ये फिरण्यासाठी वाहतूक पर्याय आणि टिप्स स्पष्ट करा:",
+			"chat":                     "तुम्ही AuraTravel चे प्रवास सहाय्यक आहात. प्रवाशाच्या संदेशाला मराठीत, थोडक्यात आणि उपयुक्त पद्धतीने उत्तर द्या:",
 		},
 		RegionalData: map[string]interface{}{
 			"preferred_meal_times": map[string]string{
@@ -497,6 +562,33 @@ type LocalizationRequest struct {
 	TargetLocale string                 `json:"target_locale"`
 	ContentType  string                 `json:"content_type"` // itinerary, notification, email, etc.
 	Context      map[string]interface{} `json:"context,omitempty"`
+
+	// DestinationTimezone is the IANA zone an itinerary's times are shown
+	// in, such as Asia/Kolkata. When it's empty the zone is resolved from
+	// DestinationLocation.
+	DestinationTimezone string    `json:"destination_timezone,omitempty"`
+	DestinationLocation *Location `json:"destination_location,omitempty"`
+}
+
+// SetZoneResolver sets how a request's destination zone is looked up
+func (l *LocalizationService) SetZoneResolver(zones *ZoneResolver) {
+	l.zones = zones
+}
+
+// DestinationZone returns the zone the request's itinerary times are shown
+// in, or nil when it names no destination zone
+func (l *LocalizationService) DestinationZone(ctx context.Context, r *LocalizationRequest) (*time.Location, error) {
+	if r.DestinationTimezone != "" {
+		zone, err := time.LoadLocation(r.DestinationTimezone)
+		if err != nil {
+			return nil, kindErrorf(ErrValidation, "unknown timezone: %s", r.DestinationTimezone)
+		}
+		return zone, nil
+	}
+	if r.DestinationLocation != nil && hasLatLng(*r.DestinationLocation) {
+		return l.zones.Resolve(ctx, *r.DestinationLocation), nil
+	}
+	return nil, nil
 }
 
 // GetSupportedLocales returns all supported locales
@@ -508,13 +600,23 @@ func (l *LocalizationService) GetSupportedLocales() map[string]*LocaleConfig {
 func (l *LocalizationService) GetLocaleConfig(locale string) (*LocaleConfig, error) {
 	config, exists := l.supportedLocales[locale]
 	if !exists {
-		return nil, fmt.Errorf("unsupported locale: %s", locale)
+		return nil, kindErrorf(ErrUnsupportedLocale, "unsupported locale: %s", locale)
 	}
 	return config, nil
 }
 
-// LocalizeItinerary localizes an itinerary to a specific locale
+// LocalizeItinerary localizes an itinerary to a specific locale, showing
+// times in the itinerary's own "timezone", or the locale's when it has none
 func (l *LocalizationService) LocalizeItinerary(ctx context.Context, itinerary interface{}, targetLocale string) (*LocalizedContent, error) {
+	return l.LocalizeItineraryIn(ctx, itinerary, targetLocale, nil)
+}
+
+// LocalizeItineraryIn localizes an itinerary to a specific locale with its
+// dates and times shown in the destination's zone. The locale still picks the
+// format, such as DD/MM or 12-hour clocks, so a Delhi trip viewed from the US
+// shows Delhi times in US style. A nil zone falls back as LocalizeItinerary
+// does.
+func (l *LocalizationService) LocalizeItineraryIn(ctx context.Context, itinerary interface{}, targetLocale string, destinationZone *time.Location) (*LocalizedContent, error) {
 	config, err := l.GetLocaleConfig(targetLocale)
 	if err != nil {
 		return nil, err
@@ -540,8 +642,17 @@ func (l *LocalizationService) LocalizeItinerary(ctx context.Context, itinerary i
 	// Apply currency formatting
 	l.formatCurrency(localizedItinerary, config)
 
-	// Apply date/time formatting
-	l.formatDateTimes(localizedItinerary, config)
+	// Apply date/time formatting in the destination's zone
+	zone := destinationZone
+	if zone == nil {
+		if name, ok := itineraryMap["timezone"].(string); ok {
+			zone, _ = time.LoadLocation(name)
+		}
+	}
+	if zone == nil {
+		zone = localeZone(config)
+	}
+	l.formatDateTimes(localizedItinerary, config, zone)
 
 	// Apply number formatting
 	l.formatNumbers(localizedItinerary, config)
@@ -566,7 +677,8 @@ func (l *LocalizationService) LocalizeItinerary(ctx context.Context, itinerary i
 			"time_format":     config.TimeFormat,
 			"number_format":   config.NumberFormat,
 			"rtl":             config.RTL,
-			"timezone":        config.Timezone,
+			"timezone":        zone.String(),
+			"viewer_timezone": config.Timezone,
 		},
 	}, nil
 }
@@ -580,7 +692,7 @@ func (l *LocalizationService) LocalizeNotification(ctx context.Context, req *Loc
 
 	notification, ok := req.Content.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("invalid notification format")
+		return nil, newKindError(ErrValidation, "invalid notification format")
 	}
 
 	// Translate notification fields
@@ -625,7 +737,7 @@ func (l *LocalizationService) GetLocalizedGeminiPrompt(locale, promptType string
 		enConfig := l.supportedLocales["en"]
 		promptTemplate, exists = enConfig.GeminiPrompts[promptType]
 		if !exists {
-			return "", fmt.Errorf("prompt type %s not found", promptType)
+			return "", kindErrorf(ErrNotFound, "prompt type %s not found", promptType)
 		}
 	}
 
@@ -639,6 +751,44 @@ func (l *LocalizationService) GetLocalizedGeminiPrompt(locale, promptType string
 	return prompt, nil
 }
 
+// LocalizeMessage renders the message template stored under key in the
+// locale's translations, falling back to the English template, and fills in
+// its {{placeholders}}. For non-English locales Gemini then adapts the
+// wording; the adaptation is discarded if it drops any variable's value.
+func (l *LocalizationService) LocalizeMessage(ctx context.Context, locale, key string, variables map[string]string) (string, error) {
+	config, err := l.GetLocaleConfig(locale)
+	if err != nil {
+		return "", err
+	}
+
+	template, exists := config.Translations[key]
+	if !exists {
+		template, exists = l.supportedLocales["en"].Translations[key]
+		if !exists {
+			return "", kindErrorf(ErrNotFound, "message %s not found", key)
+		}
+	}
+
+	message := template
+	for name, value := range variables {
+		message = strings.ReplaceAll(message, "{{"+name+"}}", value)
+	}
+
+	if l.gemini == nil || locale == "en" {
+		return message, nil
+	}
+	adapted, err := l.adaptTextWithGemini(ctx, message, locale, "notification_body")
+	if err != nil || strings.TrimSpace(adapted) == "" {
+		return message, nil
+	}
+	for _, value := range variables {
+		if !strings.Contains(adapted, value) {
+			return message, nil
+		}
+	}
+	return adapted, nil
+}
+
 // FormatCurrency formats a number according to locale-specific currency rules
 func (l *LocalizationService) FormatCurrency(amount float64, locale string) (string, error) {
 	config, err := l.GetLocaleConfig(locale)
@@ -647,7 +797,7 @@ func (l *LocalizationService) FormatCurrency(amount float64, locale string) (str
 	}
 
 	// Apply locale-specific number formatting
-	formattedAmount := l.formatNumber(amount, config.NumberFormat)
+	formattedAmount := l.formatNumber(amount, config.NumberFormat, config.currencyDecimalPlaces(config.Currency))
 
 	return fmt.Sprintf("%s %s", config.CurrencySymbol, formattedAmount), nil
 }
@@ -658,25 +808,7 @@ func (l *LocalizationService) FormatDate(date time.Time, locale string) (string,
 	if err != nil {
 		return "", err
 	}
-
-	// Convert timezone
-	tz, err := time.LoadLocation(config.Timezone)
-	if err != nil {
-		tz = time.UTC
-	}
-	localDate := date.In(tz)
-
-	// Apply date format
-	switch config.DateFormat {
-	case "DD/MM/YYYY":
-		return localDate.Format("02/01/2006"), nil
-	case "MM/DD/YYYY":
-		return localDate.Format("01/02/2006"), nil
-	case "YYYY-MM-DD":
-		return localDate.Format("2006-01-02"), nil
-	default:
-		return localDate.Format("02/01/2006"), nil
-	}
+	return formatLocaleDate(date.In(localeZone(config)), config), nil
 }
 
 // FormatTime formats a time according to locale-specific rules
@@ -685,22 +817,43 @@ func (l *LocalizationService) FormatTime(t time.Time, locale string) (string, er
 	if err != nil {
 		return "", err
 	}
+	return formatLocaleTime(t.In(localeZone(config)), config), nil
+}
 
-	// Convert timezone
+// localeZone returns the locale's zone, or UTC when it can't be loaded
+func localeZone(config *LocaleConfig) *time.Location {
 	tz, err := time.LoadLocation(config.Timezone)
 	if err != nil {
-		tz = time.UTC
+		return time.UTC
 	}
-	localTime := t.In(tz)
+	return tz
+}
 
-	// Apply time format
+// formatLocaleDate formats a date in the locale's style, in the date's own
+// zone
+func formatLocaleDate(localDate time.Time, config *LocaleConfig) string {
+	switch config.DateFormat {
+	case "DD/MM/YYYY":
+		return localDate.Format("02/01/2006")
+	case "MM/DD/YYYY":
+		return localDate.Format("01/02/2006")
+	case "YYYY-MM-DD":
+		return localDate.Format("2006-01-02")
+	default:
+		return localDate.Format("02/01/2006")
+	}
+}
+
+// formatLocaleTime formats a time of day in the locale's style, in the
+// time's own zone
+func formatLocaleTime(localTime time.Time, config *LocaleConfig) string {
 	switch config.TimeFormat {
 	case "12h":
-		return localTime.Format("3:04 PM"), nil
+		return localTime.Format("3:04 PM")
 	case "24h":
-		return localTime.Format("15:04"), nil
+		return localTime.Format("15:04")
 	default:
-		return localTime.Format("3:04 PM"), nil
+		return localTime.Format("3:04 PM")
 	}
 }
 
@@ -766,7 +919,7 @@ func (l *LocalizationService) formatCurrency(data map[string]interface{}, config
 			if strings.Contains(strings.ToLower(key), "cost") ||
 				strings.Contains(strings.ToLower(key), "price") ||
 				strings.Contains(strings.ToLower(key), "budget") {
-				data[key] = fmt.Sprintf("%s %s", config.CurrencySymbol, l.formatNumber(v, config.NumberFormat))
+				data[key] = fmt.Sprintf("%s %s", config.CurrencySymbol, l.formatNumber(v, config.NumberFormat, config.currencyDecimalPlaces(config.Currency)))
 			}
 		case map[string]interface{}:
 			l.formatCurrency(v, config)
@@ -774,22 +927,22 @@ func (l *LocalizationService) formatCurrency(data map[string]interface{}, config
 	}
 }
 
-func (l *LocalizationService) formatDateTimes(data map[string]interface{}, config *LocaleConfig) {
+// formatDateTimes formats RFC 3339 date and time values in zone, in the
+// locale's style
+func (l *LocalizationService) formatDateTimes(data map[string]interface{}, config *LocaleConfig, zone *time.Location) {
 	for key, value := range data {
 		switch v := value.(type) {
 		case string:
 			// Try to parse as time
 			if t, err := time.Parse(time.RFC3339, v); err == nil {
 				if strings.Contains(strings.ToLower(key), "date") {
-					formatted, _ := l.FormatDate(t, config.Code)
-					data[key] = formatted
+					data[key] = formatLocaleDate(t.In(zone), config)
 				} else if strings.Contains(strings.ToLower(key), "time") {
-					formatted, _ := l.FormatTime(t, config.Code)
-					data[key] = formatted
+					data[key] = formatLocaleTime(t.In(zone), config)
 				}
 			}
 		case map[string]interface{}:
-			l.formatDateTimes(v, config)
+			l.formatDateTimes(v, config, zone)
 		}
 	}
 }
@@ -801,7 +954,7 @@ func (l *LocalizationService) formatNumbers(data map[string]interface{}, config
 			if !strings.Contains(strings.ToLower(key), "cost") &&
 				!strings.Contains(strings.ToLower(key), "price") &&
 				!strings.Contains(strings.ToLower(key), "budget") {
-				data[key] = l.formatNumber(v, config.NumberFormat)
+				data[key] = l.formatNumber(v, config.NumberFormat, config.DecimalPlaces)
 			}
 		case map[string]interface{}:
 			l.formatNumbers(v, config)
@@ -809,16 +962,26 @@ func (l *LocalizationService) formatNumbers(data map[string]interface{}, config
 	}
 }
 
-func (l *LocalizationService) formatNumber(number float64, format string) string {
-	str := fmt.Sprintf("%.2f", number)
+// formatNumber formats a number with the given decimals in the locale's
+// grouping style. Whole numbers are shown without decimals, so a count of 2
+// isn't shown as "2.00".
+func (l *LocalizationService) formatNumber(number float64, format string, decimals int) string {
+	if decimals < 0 || number == math.Trunc(number) {
+		decimals = 0
+	}
+	str := strconv.FormatFloat(math.Abs(number), 'f', decimals, 64)
+	sign := ""
+	if number < 0 && strings.Trim(str, "0.") != "" {
+		sign = "-"
+	}
 
 	switch format {
 	case "1,23,456": // Indian format
-		return l.formatIndianNumber(str)
+		return sign + l.formatIndianNumber(str)
 	case "123,456": // Western format
-		return l.formatWesternNumber(str)
+		return sign + l.formatWesternNumber(str)
 	default:
-		return str
+		return sign + str
 	}
 }
 
@@ -946,35 +1109,11 @@ func (l *LocalizationService) adaptTextWithGemini(ctx context.Context, text, loc
 	prompt := fmt.Sprintf("Translate and culturally adapt the following %s for %s (%s) audience while maintaining the original meaning and tone:\n\n%s",
 		textType, config.Name, config.NativeName, text)
 
-	// Use simple Gemini interface for text adaptation
-	if simpleGemini, ok := interface{}(l.gemini).(interface {
-		GenerateText(ctx context.Context, prompt string) (string, error)
-	}); ok {
-		return simpleGemini.GenerateText(ctx, prompt)
-	}
-
-	// Fallback to basic itinerary generation
-	req := ItineraryRequest{
-		Destination: "text_adaptation",
-		Preferences: map[string]interface{}{
-			"text_adaptation": true,
-			"original_text":   text,
-			"target_locale":   locale,
-		},
-	}
-
-	response, err := l.gemini.GenerateItinerary(ctx, req)
+	adapted, err := l.gemini.GenerateText(ctx, prompt)
 	if err != nil {
 		return text, err
 	}
-
-	// Extract adapted text from response (response is already map[string]interface{})
-	respMap := response
-	if adaptedText, exists := respMap["adapted_text"].(string); exists {
-		return adaptedText, nil
-	}
-
-	return text, nil
+	return strings.TrimSpace(adapted), nil
 }
 
 // GetRegionalPreferences returns regional preferences for a locale
@@ -1026,11 +1165,11 @@ func (l *LocalizationService) GetDefaultLocale() string {
 // SetUserLocalePreference stores user's locale preference
 func (l *LocalizationService) SetUserLocalePreference(ctx context.Context, userID, locale string) error {
 	if !l.ValidateLocale(locale) {
-		return fmt.Errorf("unsupported locale: %s", locale)
+		return kindErrorf(ErrUnsupportedLocale, "unsupported locale: %s", locale)
 	}
 
 	if l.firebase == nil {
-		return fmt.Errorf("firebase service not available")
+		return newKindError(ErrUnavailable, "firebase service not available")
 	}
 
 	preference := map[string]interface{}{
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLocalizeMessage(t *testing.T) {
+	variables := map[string]string{"destination": "Jaipur", "count": "3"}
+	tests := []struct {
+		name    string
+		gemini  AIGenerator
+		locale  string
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"english template", nil, "en", "replan_updated", "Your Jaipur itinerary has been updated with 3 changes due to real-time conditions. Tap to view details.", false},
+		{"locale template", nil, "hi", "replan_updated", "वास्तविक समय की स्थितियों के कारण आपके Jaipur यात्रा कार्यक्रम में 3 बदलाव किए गए हैं। विवरण देखने के लिए टैप करें।", false},
+		{
+			"adaptation keeps the values",
+			&fakeGenerator{text: func(string) (string, error) { return " Jaipur: 3 बदलाव ", nil }},
+			"hi", "replan_updated", "Jaipur: 3 बदलाव", false,
+		},
+		{
+			"adaptation dropping a value is discarded",
+			&fakeGenerator{text: func(string) (string, error) { return "जयपुर: 3 बदलाव", nil }},
+			"hi", "replan_updated", "वास्तविक समय की स्थितियों के कारण आपके Jaipur यात्रा कार्यक्रम में 3 बदलाव किए गए हैं। विवरण देखने के लिए टैप करें।", false,
+		},
+		{
+			"failed adaptation falls back to the template",
+			&fakeGenerator{err: errors.New("quota exceeded")},
+			"hi", "replan_title", "यात्रा अपडेट", false,
+		},
+		{"unknown key", nil, "en", "no_such_message", "", true},
+		{"unsupported locale", nil, "xx", "replan_title", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLocalizationService(tt.gemini, nil).LocalizeMessage(context.Background(), tt.locale, tt.key, variables)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("LocalizeMessage = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
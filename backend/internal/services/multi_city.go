@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/tracing"
+)
+
+// DestinationLeg is one city of a multi-city trip
+type DestinationLeg struct {
+	Destination string `json:"destination"`
+	Nights      int    `json:"nights"`
+	// Order is the arrival order; legs with the same Order keep the order
+	// they were given in
+	Order int `json:"order"`
+}
+
+// ScheduledLeg is a DestinationLeg with its arrival and departure dates
+type ScheduledLeg struct {
+	DestinationLeg
+	ArrivalDate   time.Time `json:"arrival_date"`
+	DepartureDate time.Time `json:"departure_date"`
+}
+
+// LegContext is the retrieved context for one leg of a multi-city trip
+type LegContext struct {
+	ScheduledLeg
+	Attractions []Attraction    `json:"attractions"`
+	Hotels      []Hotel         `json:"hotels"`
+	Weather     WeatherForecast `json:"weather"`
+	LocalEvents []LocalEvent    `json:"local_events"`
+}
+
+// ScheduleLegs orders legs by arrival and assigns consecutive dates starting
+// at startDate. Legs with fewer than one night get one.
+func ScheduleLegs(legs []DestinationLeg, startDate time.Time) []ScheduledLeg {
+	ordered := append([]DestinationLeg(nil), legs...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Order < ordered[j].Order
+	})
+
+	scheduled := make([]ScheduledLeg, 0, len(ordered))
+	arrival := startDate
+	for _, leg := range ordered {
+		if leg.Nights < 1 {
+			leg.Nights = 1
+		}
+		departure := arrival.AddDate(0, 0, leg.Nights)
+		scheduled = append(scheduled, ScheduledLeg{
+			DestinationLeg: leg,
+			ArrivalDate:    arrival,
+			DepartureDate:  departure,
+		})
+		arrival = departure
+	}
+	return scheduled
+}
+
+// RouteName joins the legs' destinations in arrival order, e.g.
+// "Delhi → Agra → Jaipur"
+func RouteName(legs []DestinationLeg) string {
+	scheduled := ScheduleLegs(legs, time.Time{})
+	names := make([]string, 0, len(scheduled))
+	for _, leg := range scheduled {
+		names = append(names, leg.Destination)
+	}
+	return strings.Join(names, " → ")
+}
+
+// TotalNights is the number of nights across all legs
+func TotalNights(legs []DestinationLeg) int {
+	total := 0
+	for _, leg := range ScheduleLegs(legs, time.Time{}) {
+		total += leg.Nights
+	}
+	return total
+}
+
+// legForDay returns the index of the leg the traveler is in on day (1-based)
+// and which day of that leg it is. Transfer days count as the arrival day of
+// the next leg; the final departure day belongs to the last leg.
+func legForDay(legs []ScheduledLeg, day int) (int, int) {
+	offset := 0
+	for i, leg := range legs {
+		if day <= offset+leg.Nights || i == len(legs)-1 {
+			return i, day - offset
+		}
+		offset += leg.Nights
+	}
+	return 0, day
+}
+
+// intercityTransport returns a transport option for each hop between
+// consecutive legs, departing on the day the earlier leg ends
+func intercityTransport(legs []ScheduledLeg) []TransportOption {
+	var options []TransportOption
+	for i := 1; i < len(legs); i++ {
+		from, to := legs[i-1], legs[i]
+		// Mock transportation data - in production, query rail and flight APIs
+		options = append(options, TransportOption{
+			Type:          "train",
+			From:          from.Destination,
+			To:            to.Destination,
+			Duration:      "4h 00m",
+			Price:         49.99,
+			Available:     true,
+			Provider:      "RailService",
+			DepartureDate: from.DepartureDate,
+		})
+	}
+	return options
+}
+
+// retrieveMultiCityContext retrieves context for every leg of a multi-city
+// request and merges it. The budget is split across legs by nights.
+func (r *RAGRetriever) retrieveMultiCityContext(ctx context.Context, req RetrievalRequest) (*TripContext, error) {
+	legs := ScheduleLegs(req.Destinations, req.StartDate)
+	totalNights := TotalNights(req.Destinations)
+
+	tripContext := &TripContext{
+		Destination: RouteName(req.Destinations),
+	}
+
+	for i, leg := range legs {
+		legReq := req
+		legReq.Destinations = nil
+		legReq.Destination = leg.Destination
+		legReq.StartDate = leg.ArrivalDate
+		legReq.EndDate = leg.DepartureDate
+		legReq.Budget = req.Budget * float64(leg.Nights) / float64(totalNights)
+
+		legContext, err := r.RetrieveContext(ctx, legReq)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve context for %s: %v", leg.Destination, err)
+		}
+
+		// Each leg's forecast ends on its departure day, which is the next
+		// leg's arrival day
+		forecast := legContext.Weather.Forecast
+		if i < len(legs)-1 && len(forecast) > 0 && !forecast[len(forecast)-1].Date.Before(leg.DepartureDate) {
+			forecast = forecast[:len(forecast)-1]
+		}
+
+		tripContext.Legs = append(tripContext.Legs, LegContext{
+			ScheduledLeg: leg,
+			Attractions:  legContext.Attractions,
+			Hotels:       legContext.Hotels,
+			Weather:      legContext.Weather,
+			LocalEvents:  legContext.LocalEvents,
+		})
+
+		if i == 0 {
+			tripContext.UserProfile = legContext.UserProfile
+			tripContext.SimilarTrips = legContext.SimilarTrips
+			tripContext.Transportation = legContext.Transportation
+			tripContext.Weather.Current = legContext.Weather.Current
+		}
+		tripContext.Attractions = append(tripContext.Attractions, legContext.Attractions...)
+		tripContext.Hotels = append(tripContext.Hotels, legContext.Hotels...)
+		tripContext.LocalEvents = append(tripContext.LocalEvents, legContext.LocalEvents...)
+		tripContext.EMTInventory = append(tripContext.EMTInventory, legContext.EMTInventory...)
+		tripContext.Weather.Forecast = append(tripContext.Weather.Forecast, forecast...)
+	}
+
+	tripContext.IntercityTransport = r.fetchIntercityTransport(ctx, legs)
+
+	// Use each leg's top hotel as its city's location for distance estimates
+	for i := range tripContext.IntercityTransport {
+		from, to := tripContext.Legs[i].Hotels, tripContext.Legs[i+1].Hotels
+		if len(from) > 0 && len(to) > 0 {
+			tripContext.IntercityTransport[i].FromLocation = &from[0].Location
+			tripContext.IntercityTransport[i].ToLocation = &to[0].Location
+		}
+		tripContext.IntercityTransport[i].annotateCarbon()
+	}
+	return tripContext, nil
+}
+
+// fetchIntercityTransport retrieves transport between consecutive legs
+func (r *RAGRetriever) fetchIntercityTransport(ctx context.Context, legs []ScheduledLeg) []TransportOption {
+	_, span := tracing.Start(ctx, "transport.fetch_intercity", tracing.KindClient, tracing.Attr("legs", len(legs)))
+	defer span.End()
+
+	return intercityTransport(legs)
+}
+
+// attractionsByCity indexes retrieved attractions by leg destination for
+// multi-city trips, or under "" otherwise
+func (c TripContext) attractionsByCity() map[string][]Attraction {
+	if len(c.Legs) == 0 {
+		return map[string][]Attraction{"": c.Attractions}
+	}
+	byCity := make(map[string][]Attraction, len(c.Legs))
+	for _, leg := range c.Legs {
+		byCity[leg.Destination] = append(byCity[leg.Destination], leg.Attractions...)
+	}
+	return byCity
+}
+
+// scheduledLegs resolves the request's legs against its start date
+func (req ItineraryRequest) scheduledLegs() []ScheduledLeg {
+	start, _ := time.Parse("2006-01-02", req.StartDate)
+	return ScheduleLegs(req.Destinations, start)
+}
+
+// buildMultiCityPrompt creates an itinerary prompt for a multi-city trip.
+// ragContext is nil when no retrieved data is available.
+func (g *GeminiService) buildMultiCityPrompt(req ItineraryRequest, ragContext *TripContext) string {
+	legs := req.scheduledLegs()
+
+	var route strings.Builder
+	for i, leg := range legs {
+		route.WriteString(fmt.Sprintf("%d. %s: %d night(s), %s to %s\n", i+1, userInput("destination", leg.Destination), leg.Nights,
+			leg.ArrivalDate.Format("2006-01-02"), leg.DepartureDate.Format("2006-01-02")))
+		if ragContext == nil || i >= len(ragContext.Legs) {
+			continue
+		}
+		legContext := ragContext.Legs[i]
+		if len(legContext.Attractions) > 0 {
+			route.WriteString(fmt.Sprintf("   Available attractions: %d locations including %s\n",
+				len(legContext.Attractions), legContext.Attractions[0].Name))
+		}
+		if len(legContext.Hotels) > 0 {
+			route.WriteString(fmt.Sprintf("   Recommended hotels: %d options starting from $%.2f\n",
+				len(legContext.Hotels), legContext.Hotels[0].PricePerNight))
+		}
+	}
+
+	transport := intercityTransport(legs)
+	if ragContext != nil && len(ragContext.IntercityTransport) > 0 {
+		transport = ragContext.IntercityTransport
+	}
+	var hops strings.Builder
+	for _, option := range transport {
+		hops.WriteString(fmt.Sprintf("- %s to %s on %s (%s option: %s, $%.2f)\n", option.From, option.To,
+			option.DepartureDate.Format("2006-01-02"), option.Type, option.Duration, option.Price))
+	}
+
+	return fmt.Sprintf(`Generate a detailed %d-day multi-city travel itinerary visiting these cities in order:
+
+%s
+Inter-city transfers:
+%s
+Trip Requirements:
+- Budget: $%.2f for the whole trip
+- Travelers: %d
+- Travel preferences: %s
+
+Please provide a day-by-day breakdown with morning, afternoon, and evening activities, and the city for each day.
+Include a transportation leg between each pair of consecutive cities with the mode, departure day, duration and cost.
+Keep transfer days light and plan arrival-day activities close to the hotel.
+
+Format the response as a structured JSON with clear day-by-day organization and a "transport_legs" list.`,
+		g.calculateDays(req.StartDate, req.EndDate), route.String(), hops.String(), req.Budget, req.Travelers, preferencesInput(req.Preferences)) +
+		buildPacePromptSection(req.Preferences) + buildGroupPromptSection(req.TravelerProfiles) + userInputNotice
+}
+
+// mockMultiCityItinerary lays out a day-by-day plan across the legs, using
+// the retrieved attractions for each leg when ragContext is set
+func (g *GeminiService) mockMultiCityItinerary(req ItineraryRequest, ragContext *TripContext) map[string]interface{} {
+	legs := req.scheduledLegs()
+	transport := intercityTransport(legs)
+	if ragContext != nil && len(ragContext.IntercityTransport) > 0 {
+		transport = ragContext.IntercityTransport
+	}
+
+	itinerary := map[string]interface{}{
+		"destination":    RouteName(req.Destinations),
+		"legs":           legs,
+		"transport_legs": transport,
+		"duration":       g.calculateDays(req.StartDate, req.EndDate),
+		"budget":         req.Budget,
+		"travelers":      req.Travelers,
+		"ai_generated":   true,
+		"created_at":     g.clock().Format(time.RFC3339),
+	}
+
+	days := g.calculateDays(req.StartDate, req.EndDate)
+	for day := 1; day <= days; day++ {
+		legIndex, legDay := legForDay(legs, day)
+		leg := legs[legIndex]
+
+		var dayPlan map[string]interface{}
+		if ragContext != nil && legIndex < len(ragContext.Legs) {
+			dayPlan = g.buildDayPlan(legDay, TripContext{Attractions: ragContext.Legs[legIndex].Attractions}, req.Preferences)
+		} else {
+			dayPlan = map[string]interface{}{
+				"morning":   fmt.Sprintf("Explore %s attractions", leg.Destination),
+				"afternoon": "Local dining and shopping",
+				"evening":   "Relaxation and local entertainment",
+			}
+		}
+		dayPlan["city"] = leg.Destination
+
+		if legDay == 1 {
+			if legIndex == 0 {
+				dayPlan["morning"] = fmt.Sprintf("Arrive in %s, check into hotel", leg.Destination)
+			} else if legIndex-1 < len(transport) {
+				hop := transport[legIndex-1]
+				dayPlan["morning"] = fmt.Sprintf("Travel from %s to %s by %s (%s)", hop.From, hop.To, hop.Type, hop.Duration)
+			}
+		}
+
+		itinerary[fmt.Sprintf("day_%d", day)] = dayPlan
+	}
+
+	var attractionsByCity map[string][]Attraction
+	if ragContext != nil {
+		itinerary["rag_enhanced"] = true
+		itinerary["tips"] = g.generateContextualTips(*ragContext, req)
+		attractionsByCity = ragContext.attractionsByCity()
+	}
+
+	addGroupAlternatives(itinerary, req.TravelerProfiles, RouteName(req.Destinations), attractionsByCity)
+	return itinerary
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScheduleLegs(t *testing.T) {
+	start := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		legs       []DestinationLeg
+		want       string
+		wantRoute  string
+		wantNights int
+	}{
+		{
+			name:       "ordered by arrival",
+			legs:       []DestinationLeg{{Destination: "Jaipur", Nights: 2, Order: 3}, {Destination: "Delhi", Nights: 3, Order: 1}, {Destination: "Agra", Nights: 1, Order: 2}},
+			want:       "Delhi 11-01..11-04,Agra 11-04..11-05,Jaipur 11-05..11-07",
+			wantRoute:  "Delhi → Agra → Jaipur",
+			wantNights: 6,
+		},
+		{
+			name:       "ties keep the given order",
+			legs:       []DestinationLeg{{Destination: "Goa", Nights: 2}, {Destination: "Mumbai", Nights: 2}},
+			want:       "Goa 11-01..11-03,Mumbai 11-03..11-05",
+			wantRoute:  "Goa → Mumbai",
+			wantNights: 4,
+		},
+		{
+			name:       "legs get at least one night",
+			legs:       []DestinationLeg{{Destination: "Kochi"}, {Destination: "Munnar", Nights: -2, Order: 1}},
+			want:       "Kochi 11-01..11-02,Munnar 11-02..11-03",
+			wantRoute:  "Kochi → Munnar",
+			wantNights: 2,
+		},
+		{name: "no legs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheduled := ScheduleLegs(tt.legs, start)
+			parts := make([]string, len(scheduled))
+			for i, leg := range scheduled {
+				parts[i] = fmt.Sprintf("%s %s..%s", leg.Destination, leg.ArrivalDate.Format("01-02"), leg.DepartureDate.Format("01-02"))
+			}
+			if got := strings.Join(parts, ","); got != tt.want {
+				t.Errorf("scheduled = %s, want %s", got, tt.want)
+			}
+			if got := RouteName(tt.legs); got != tt.wantRoute {
+				t.Errorf("RouteName = %q, want %q", got, tt.wantRoute)
+			}
+			if got := TotalNights(tt.legs); got != tt.wantNights {
+				t.Errorf("TotalNights = %d, want %d", got, tt.wantNights)
+			}
+
+			hops := intercityTransport(scheduled)
+			if len(scheduled) > 0 && len(hops) != len(scheduled)-1 {
+				t.Fatalf("got %d transfers for %d legs", len(hops), len(scheduled))
+			}
+			for i, hop := range hops {
+				if hop.From != scheduled[i].Destination || hop.To != scheduled[i+1].Destination || !hop.DepartureDate.Equal(scheduled[i].DepartureDate) {
+					t.Errorf("transfer %d = %s→%s on %s, want it to leave %s when that leg ends", i, hop.From, hop.To, hop.DepartureDate.Format("01-02"), scheduled[i].Destination)
+				}
+			}
+		})
+	}
+}
+
+func TestLegForDay(t *testing.T) {
+	legs := ScheduleLegs([]DestinationLeg{{Destination: "Delhi", Nights: 2}, {Destination: "Agra", Nights: 1}, {Destination: "Jaipur", Nights: 2}}, time.Time{})
+	tests := []struct {
+		day, wantLeg, wantLegDay int
+	}{
+		{1, 0, 1},
+		{2, 0, 2},
+		{3, 1, 1}, // transfer day counts as arrival in Agra
+		{4, 2, 1},
+		{5, 2, 2},
+		{6, 2, 3}, // final departure day stays in the last city
+	}
+	for _, tt := range tests {
+		if leg, legDay := legForDay(legs, tt.day); leg != tt.wantLeg || legDay != tt.wantLegDay {
+			t.Errorf("legForDay(%d) = leg %d day %d, want leg %d day %d", tt.day, leg, legDay, tt.wantLeg, tt.wantLegDay)
+		}
+	}
+}
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/logging"
+)
+
+const (
+	// DefaultNearbyRadiusKm is searched when no radius is given
+	DefaultNearbyRadiusKm = 2.0
+
+	// MaxNearbyRadiusKm caps the radius, since "nearby" further than this
+	// isn't somewhere to walk over to now
+	MaxNearbyRadiusKm = 10.0
+
+	// defaultNearbyLimit is how many suggestions are returned by default
+	defaultNearbyLimit = 20
+
+	// nearbyCandidateLimit is how many attractions are read from the vector
+	// database before ranking
+	nearbyCandidateLimit = 100
+
+	// nearbyDistanceWeight is how many stars of rating a place at the edge
+	// of the radius gives up against one right here
+	nearbyDistanceWeight = 1.5
+
+	// unratedAttractionRating stands in for attractions nobody has rated, so
+	// they neither top nor trail the list for it
+	unratedAttractionRating = 3.5
+)
+
+// NearbyOptions tunes SuggestNearbyWithOptions
+type NearbyOptions struct {
+	Interests []string
+	OpenNow   bool
+
+	// RadiusKm defaults to DefaultNearbyRadiusKm and is capped at
+	// MaxNearbyRadiusKm
+	RadiusKm float64
+
+	// Limit defaults to 20
+	Limit int
+
+	// Location is the time zone at the coordinates, used to decide what's
+	// open. When it's nil the zone is resolved from the coordinates.
+	Location *time.Location
+
+	// Now defaults to time.Now
+	Now func() time.Time
+}
+
+// NearbyService suggests attractions near a point, from the vector
+// database's attractions and, with a Maps API key, Google Places
+type NearbyService struct {
+	vectorDB      *VectorDatabase
+	dataConnector *DataSourceConnector
+	zones         *ZoneResolver
+}
+
+// NewNearbyService creates a new nearby suggestion service. Either source
+// may be nil.
+func NewNearbyService(vectorDB *VectorDatabase, dataConnector *DataSourceConnector) *NearbyService {
+	return &NearbyService{
+		vectorDB:      vectorDB,
+		dataConnector: dataConnector,
+	}
+}
+
+// SetZoneResolver sets how the local time at a search's origin is found
+func (s *NearbyService) SetZoneResolver(zones *ZoneResolver) {
+	s.zones = zones
+}
+
+// SuggestNearby returns attractions within the default radius of lat, lng
+// matching the interests, ranked by rating and distance. With openNow set,
+// only attractions known to be open at the current local time are kept.
+func (s *NearbyService) SuggestNearby(ctx context.Context, lat, lng float64, interests []string, openNow bool) ([]Attraction, error) {
+	return s.SuggestNearbyWithOptions(ctx, lat, lng, NearbyOptions{
+		Interests: interests,
+		OpenNow:   openNow,
+	})
+}
+
+// SuggestNearbyWithOptions is SuggestNearby with a configurable radius,
+// limit and time zone. When no attraction is nearby the result is empty
+// rather than an error; an error is returned only when no source could be
+// searched at all.
+func (s *NearbyService) SuggestNearbyWithOptions(ctx context.Context, lat, lng float64, opts NearbyOptions) ([]Attraction, error) {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return nil, fmt.Errorf("invalid coordinates: %f, %f", lat, lng)
+	}
+	origin := Location{Latitude: lat, Longitude: lng}
+	radiusKm := NearbyRadiusKm(opts.RadiusKm)
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultNearbyLimit
+	}
+
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	zone := opts.Location
+	if zone == nil {
+		zone = s.zones.Resolve(ctx, origin)
+	}
+	localNow := now().In(zone)
+
+	var candidates []Attraction
+	var searchErr error
+	searched := false
+
+	if s.vectorDB != nil {
+		attractions, err := s.nearbyFromVectorDB(ctx, origin, radiusKm, opts.Interests)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to search nearby attractions", "error", err)
+			searchErr = err
+		} else {
+			searched = true
+		}
+		for _, attraction := range attractions {
+			if opts.OpenNow {
+				if open, known := attractionOpenAt(attraction.OpeningHours, localNow); !open || !known {
+					continue
+				}
+			}
+			candidates = append(candidates, attraction)
+		}
+	}
+
+	if s.dataConnector != nil {
+		// Places filters by opening hours itself, in the place's own time zone
+		attractions, err := s.dataConnector.FetchNearbyAttractions(ctx, lat, lng, radiusKm, opts.Interests, opts.OpenNow)
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to fetch nearby places", "error", err)
+			searchErr = err
+		} else {
+			searched = true
+		}
+		candidates = append(candidates, attractions...)
+	}
+
+	if !searched && searchErr != nil {
+		return nil, searchErr
+	}
+	return RankNearbyAttractions(origin, radiusKm, candidates, limit), nil
+}
+
+// RankNearbyAttractions drops attractions outside radiusKm of origin or
+// listed twice, and orders the rest by rating less a penalty growing with
+// distance, so a close, decent place can beat a far, excellent one
+func RankNearbyAttractions(origin Location, radiusKm float64, attractions []Attraction, limit int) []Attraction {
+	type rankedAttraction struct {
+		attraction Attraction
+		distanceKm float64
+		score      float64
+	}
+
+	seen := make(map[string]bool)
+	ranked := make([]rankedAttraction, 0, len(attractions))
+	for _, attraction := range attractions {
+		if !hasLatLng(attraction.Location) {
+			continue
+		}
+		key := attraction.ID
+		if key == "" {
+			key = strings.ToLower(attraction.Name)
+		}
+		if seen[key] {
+			continue
+		}
+		distance := haversineKm(origin, attraction.Location)
+		if distance > radiusKm {
+			continue
+		}
+		seen[key] = true
+
+		rating := attraction.Rating
+		if rating <= 0 {
+			rating = unratedAttractionRating
+		}
+		ranked = append(ranked, rankedAttraction{
+			attraction: attraction,
+			distanceKm: distance,
+			score:      rating - nearbyDistanceWeight*distance/radiusKm,
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].distanceKm < ranked[j].distanceKm
+	})
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	results := make([]Attraction, len(ranked))
+	for i, r := range ranked {
+		results[i] = r.attraction
+	}
+	return results
+}
+
+// NearbyRadiusKm returns the radius actually searched for a requested one
+func NearbyRadiusKm(requested float64) float64 {
+	switch {
+	case requested <= 0:
+		return DefaultNearbyRadiusKm
+	case requested > MaxNearbyRadiusKm:
+		return MaxNearbyRadiusKm
+	default:
+		return requested
+	}
+}
+
+// nearbyFromVectorDB reads the vector database's attractions within the
+// radius, most relevant to the interests first
+func (s *NearbyService) nearbyFromVectorDB(ctx context.Context, origin Location, radiusKm float64, interests []string) ([]Attraction, error) {
+	query := strings.Join(interests, " ")
+	if query == "" {
+		query = "popular tourist attraction"
+	}
+	results, err := s.vectorDB.SearchSimilarWithOptions(ctx, query, "attraction", nearbyCandidateLimit, SearchOptions{
+		Filter: &MetadataFilter{
+			AvailableOnly: true,
+			Near:          &origin,
+			RadiusKm:      radiusKm,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attractions := make([]Attraction, 0, len(results))
+	for _, result := range results {
+		attractions = append(attractions, attractionFromDocument(result.Document))
+	}
+	return attractions, nil
+}
+
+// approximateZone estimates the time zone at a longitude from the nearest
+// whole-hour meridian. It's off where zones follow borders, so callers that
+// know the real zone should pass it.
+func approximateZone(lng float64) *time.Location {
+	offset := int(math.Round(lng / 15))
+	return time.FixedZone(fmt.Sprintf("UTC%+d", offset), offset*3600)
+}
+
+// openingWindow is a span of opening in minutes after midnight. closes is
+// past 24h for places open past midnight.
+type openingWindow struct {
+	opens  int
+	closes int
+}
+
+// attractionOpenAt reports whether opening hours include at, and whether
+// the hours could be read at all. Both Places' "Monday: 9:00 AM – 5:00 PM"
+// lines and plain "09:00-17:00" ranges are understood; with per-day lines
+// only today's and yesterday's past-midnight hours apply.
+func attractionOpenAt(hours []string, at time.Time) (bool, bool) {
+	minute := at.Hour()*60 + at.Minute()
+	today := int(at.Weekday())
+	yesterday := (today + 6) % 7
+	known := false
+
+	for _, entry := range hours {
+		day, text := splitWeekday(entry)
+		if day != -1 && day != today && day != yesterday {
+			continue
+		}
+		windows, ok := openingWindows(text)
+		if !ok {
+			continue
+		}
+		known = true
+
+		for _, window := range windows {
+			if day != yesterday && minute >= window.opens && minute < window.closes {
+				return true, true
+			}
+			// Hours running past midnight cover the small hours of the
+			// next day
+			if day != today && minute+24*60 < window.closes {
+				return true, true
+			}
+		}
+	}
+	return false, known
+}
+
+// weekdayNames are matched against the start of per-day opening hours
+var weekdayNames = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// splitWeekday splits "Monday: 9:00 AM – 5:00 PM" into the weekday and its
+// hours. Entries without a weekday return -1.
+func splitWeekday(entry string) (int, string) {
+	name, text, found := strings.Cut(entry, ":")
+	if !found {
+		return -1, entry
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) < 3 {
+		return -1, entry
+	}
+	for i, weekday := range weekdayNames {
+		if strings.HasPrefix(weekday, name) {
+			return i, strings.TrimSpace(text)
+		}
+	}
+	return -1, entry
+}
+
+// openingWindows parses one day's opening hours: "Closed", "Open 24 hours",
+// or comma-separated ranges in 24-hour or 12-hour clock
+func openingWindows(text string) ([]openingWindow, bool) {
+	text = strings.NewReplacer("\u202f", " ", "\u2009", " ", "\u00a0", " ", "\u2013", "-", "\u2014", "-").Replace(strings.TrimSpace(text))
+	switch strings.ToLower(text) {
+	case "":
+		return nil, false
+	case "closed":
+		return []openingWindow{}, true
+	case "open 24 hours", "24 hours", "24/7":
+		return []openingWindow{{opens: 0, closes: 24 * 60}}, true
+	}
+
+	var windows []openingWindow
+	for _, part := range strings.Split(text, ",") {
+		if opens, closes, ok := parseOpeningHours(part); ok {
+			windows = append(windows, openingWindow{opens: opens, closes: closes})
+			continue
+		}
+
+		openStr, closeStr, found := strings.Cut(part, "-")
+		if !found {
+			return nil, false
+		}
+		closes, closeMeridiem, ok := parseClock12(closeStr, "")
+		if !ok {
+			return nil, false
+		}
+		// "5:00 - 10:00 PM" leaves the meridiem off the opening time
+		opens, _, ok := parseClock12(openStr, closeMeridiem)
+		if !ok {
+			return nil, false
+		}
+		if closes <= opens {
+			closes += 24 * 60
+		}
+		windows = append(windows, openingWindow{opens: opens, closes: closes})
+	}
+	return windows, len(windows) > 0
+}
+
+// parseClock12 reads "9:00 AM", "9 PM" or "21:00" as minutes after
+// midnight, using meridiem when the value has none. It returns the
+// meridiem it used.
+func parseClock12(value, meridiem string) (int, string, bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if strings.HasSuffix(value, "AM") || strings.HasSuffix(value, "PM") {
+		meridiem = value[len(value)-2:]
+		value = strings.TrimSpace(value[:len(value)-2])
+	}
+	if meridiem == "" {
+		minutes, ok := parseClock(value)
+		return minutes, "", ok
+	}
+
+	for _, layout := range []string{"3:04 PM", "3 PM"} {
+		if t, err := time.Parse(layout, value+" "+meridiem); err == nil {
+			return t.Hour()*60 + t.Minute(), meridiem, true
+		}
+	}
+	return 0, "", false
+}
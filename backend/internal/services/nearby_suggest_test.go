@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttractionOpenAt(t *testing.T) {
+	// June 1, 2026 is a Monday
+	monday := func(hour int) time.Time {
+		return time.Date(2026, 6, 1, hour, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		hours     []string
+		at        time.Time
+		wantOpen  bool
+		wantKnown bool
+	}{
+		{[]string{"Monday: 9:00 AM – 5:00 PM"}, monday(10), true, true},
+		{[]string{"Monday: 9:00 AM – 5:00 PM"}, monday(18), false, true},
+		{[]string{"Monday: 9:00 AM – 1:00 PM, 4:00 – 8:00 PM"}, monday(14), false, true},
+		{[]string{"Monday: 5:00 – 10:00 PM"}, monday(20), true, true},
+		{[]string{"Monday: Closed"}, monday(10), false, true},
+		{[]string{"Monday: Open 24 hours"}, monday(3), true, true},
+		{[]string{"Sunday: 6:00 PM – 2:00 AM", "Monday: Closed"}, monday(1), true, true},
+		{[]string{"Tuesday: 9:00 AM – 5:00 PM"}, monday(10), false, false},
+		{[]string{"09:00-17:00"}, monday(10), true, true},
+		{[]string{"22:00-02:00"}, monday(1), true, true},
+		{[]string{"whenever"}, monday(10), false, false},
+		{nil, monday(10), false, false},
+	}
+	for _, tt := range tests {
+		if open, known := attractionOpenAt(tt.hours, tt.at); open != tt.wantOpen || known != tt.wantKnown {
+			t.Errorf("attractionOpenAt(%q, %s) = %v, %v; want %v, %v", tt.hours, tt.at.Format("Mon 15:04"), open, known, tt.wantOpen, tt.wantKnown)
+		}
+	}
+}
+
+func TestNearbyRadiusKm(t *testing.T) {
+	tests := []struct {
+		requested float64
+		want      float64
+	}{
+		{0, DefaultNearbyRadiusKm},
+		{-3, DefaultNearbyRadiusKm},
+		{5, 5},
+		{50, MaxNearbyRadiusKm},
+	}
+	for _, tt := range tests {
+		if got := NearbyRadiusKm(tt.requested); got != tt.want {
+			t.Errorf("NearbyRadiusKm(%v) = %v, want %v", tt.requested, got, tt.want)
+		}
+	}
+}
+
+func TestRankNearbyAttractions(t *testing.T) {
+	// A thousandth of a degree of longitude is about 100m here
+	attractions := []Attraction{
+		{ID: "fort", Name: "Fort", Rating: 4.8, Location: at(77.219)},
+		{Name: "Bazaar", Rating: 4.2, Location: at(77.201)},
+		{ID: "lake", Name: "Lake", Rating: 5, Location: at(77.3)},
+		{Name: "BAZAAR", Rating: 4.2, Location: at(77.201)},
+		{ID: "nowhere", Name: "Nowhere", Rating: 5},
+		{ID: "stall", Name: "Stall", Location: at(77.2)},
+		{ID: "garden", Name: "Garden", Rating: 4, Location: at(77.21)},
+	}
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{"close beats far", 0, "Bazaar,Stall,Fort,Garden"},
+		{"limited", 2, "Bazaar,Stall"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := RankNearbyAttractions(at(77.2), 2, attractions, tt.limit)
+			if got := attractionNames(ranked); got != tt.want {
+				t.Errorf("ranked = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestNearby(t *testing.T) {
+	var searched []string
+	places := &http.Client{Transport: roundTripFunc(func(req *http.Request) *http.Response {
+		query := req.URL.Query()
+		searched = append(searched, query.Get("type")+"/"+query.Get("radius")+"/"+query.Get("opennow"))
+
+		body := `{"status":"ZERO_RESULTS"}`
+		switch query.Get("type") {
+		case "tourist_attraction":
+			body = `{"status":"OK","results":[{"place_id":"fort","name":"Fort","rating":4.5,"geometry":{"location":{"lat":28.6,"lng":77.201}}}]}`
+		case "restaurant":
+			body = `{"status":"OK","results":[{"place_id":"far","name":"Far Diner","geometry":{"location":{"lat":28.6,"lng":77.5}}}]}`
+		case "cafe":
+			body = `{"status":"REQUEST_DENIED"}`
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: io.NopCloser(strings.NewReader(body))}
+	})}
+	connector := &DataSourceConnector{mapsAPIKey: "key", httpClient: places}
+
+	tests := []struct {
+		name         string
+		service      *NearbyService
+		lat          float64
+		opts         NearbyOptions
+		want         string
+		wantSearched string
+		wantErr      bool
+	}{
+		{
+			name: "places within the radius", service: NewNearbyService(nil, connector), lat: 28.6,
+			opts: NearbyOptions{Interests: []string{"food"}, OpenNow: true, RadiusKm: 3},
+			want: "Fort", wantSearched: "tourist_attraction/3000/true,restaurant/3000/true,cafe/3000/true",
+		},
+		{
+			name: "default radius", service: NewNearbyService(nil, connector), lat: 28.6,
+			want: "Fort", wantSearched: "tourist_attraction/2000/",
+		},
+		{name: "no sources", service: NewNearbyService(nil, nil), lat: 28.6},
+		{name: "invalid coordinates", service: NewNearbyService(nil, connector), lat: 91, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			searched = nil
+			tt.opts.Location = time.UTC
+			suggestions, err := tt.service.SuggestNearbyWithOptions(context.Background(), tt.lat, 77.2, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SuggestNearbyWithOptions error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := attractionNames(suggestions); got != tt.want {
+				t.Errorf("suggestions = %s, want %s", got, tt.want)
+			}
+			if got := strings.Join(searched, ","); got != tt.wantSearched {
+				t.Errorf("searched %s, want %s", got, tt.wantSearched)
+			}
+		})
+	}
+}
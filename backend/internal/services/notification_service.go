@@ -2,21 +2,72 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
+	"auratravel-backend/internal/metrics"
+	"auratravel-backend/internal/models"
+	"auratravel-backend/internal/tracing"
+
+	"cloud.google.com/go/firestore"
+	"cloud.google.com/go/firestore/apiv1/firestorepb"
 	"firebase.google.com/go/v4/messaging"
+	"github.com/google/uuid"
 )
 
+// fcmClient is the part of the FCM client NotificationService uses
+type fcmClient interface {
+	Send(ctx context.Context, message *messaging.Message) (string, error)
+	SendEachForMulticast(ctx context.Context, message *messaging.MulticastMessage) (*messaging.BatchResponse, error)
+	SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error)
+	UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error)
+}
+
 // NotificationService handles push notifications via Firebase Cloud Messaging
 type NotificationService struct {
-	messagingClient *messaging.Client
+	messagingClient fcmClient
 	firebase        *FirebaseService
 	enabled         bool
+
+	settingsMu    sync.Mutex
+	settingsCache map[string]cachedNotificationSettings
+
+	templatesMu sync.RWMutex
+	templates   map[string]*NotificationTemplate
+
+	badgeMu    sync.Mutex
+	badgeCache map[string]cachedBadgeCount
+}
+
+// cachedBadgeCount is a user's unread count with the time it was computed
+type cachedBadgeCount struct {
+	count    int
+	loadedAt time.Time
+}
+
+const (
+	// badgeCountTTL is how long an unread count is reused between pushes
+	badgeCountTTL = 30 * time.Second
+	// maxBadgeCount caps the unread count query and the displayed badge
+	maxBadgeCount = 99
+)
+
+// cachedNotificationSettings is a user's notification settings with the time
+// they were loaded
+type cachedNotificationSettings struct {
+	settings models.NotificationSettings
+	loadedAt time.Time
 }
 
+// notificationSettingsTTL is how long a user's settings are reused before
+// being re-read from Firestore
+const notificationSettingsTTL = 5 * time.Minute
+
 // NewNotificationService creates a new notification service
 func NewNotificationService(firebase *FirebaseService) (*NotificationService, error) {
 	if firebase == nil {
@@ -33,11 +84,17 @@ func NewNotificationService(firebase *FirebaseService) (*NotificationService, er
 		}, nil
 	}
 
-	return &NotificationService{
+	service := &NotificationService{
 		messagingClient: messagingClient,
 		firebase:        firebase,
 		enabled:         true,
-	}, nil
+	}
+
+	if err := service.ReloadTemplates(context.Background()); err != nil {
+		log.Printf("Warning: Failed to load notification templates, using defaults: %v", err)
+	}
+
+	return service, nil
 }
 
 // NotificationType represents different types of notifications
@@ -51,6 +108,10 @@ const (
 	BookingConfirm   NotificationType = "booking_confirmation"
 	GeneralUpdate    NotificationType = "general_update"
 	EmergencyAlert   NotificationType = "emergency_alert"
+	PriceAlertType   NotificationType = "price_alert"
+	Recommendation   NotificationType = "recommendation"
+	TripInviteType   NotificationType = "trip_invite"
+	BudgetAlertType  NotificationType = "budget_alert"
 )
 
 // NotificationPriority represents notification priority levels
@@ -80,37 +141,133 @@ type NotificationRequest struct {
 
 // UserDeviceToken represents a user's FCM device token
 type UserDeviceToken struct {
-	UserID      string    `json:"user_id"`
-	DeviceToken string    `json:"device_token"`
-	DeviceType  string    `json:"device_type"` // ios, android, web
-	Language    string    `json:"language"`
-	Timezone    string    `json:"timezone"`
-	Active      bool      `json:"active"`
-	LastUsed    time.Time `json:"last_used"`
-	CreatedAt   time.Time `json:"created_at"`
+	UserID      string    `firestore:"user_id" json:"user_id"`
+	DeviceToken string    `firestore:"device_token" json:"device_token"`
+	DeviceType  string    `firestore:"device_type" json:"device_type"` // ios, android, web
+	Language    string    `firestore:"language" json:"language"`
+	Timezone    string    `firestore:"timezone" json:"timezone"`
+	Active      bool      `firestore:"active" json:"active"`
+	LastUsed    time.Time `firestore:"last_used" json:"last_used"`
+	CreatedAt   time.Time `firestore:"created_at" json:"created_at"`
 }
 
 // NotificationTemplate represents localized notification templates
 type NotificationTemplate struct {
-	Type      NotificationType  `json:"type"`
-	Language  string            `json:"language"`
-	TitleTmpl string            `json:"title_template"`
-	BodyTmpl  string            `json:"body_template"`
-	Variables map[string]string `json:"variables,omitempty"`
+	Type      NotificationType  `firestore:"type" json:"type"`
+	Language  string            `firestore:"language" json:"language"`
+	TitleTmpl string            `firestore:"title_template" json:"title_template"`
+	BodyTmpl  string            `firestore:"body_template" json:"body_template"`
+	Variables map[string]string `firestore:"variables" json:"variables,omitempty"` // default values for template variables
+}
+
+// defaultNotificationTemplates seeds the template set before (and in case of
+// failure, instead of) loading the notification_templates collection
+func defaultNotificationTemplates() map[string]*NotificationTemplate {
+	return map[string]*NotificationTemplate{
+		templateKey(WeatherAlertType, "hi"): {
+			Type:      WeatherAlertType,
+			Language:  "hi",
+			TitleTmpl: "मौसम चेतावनी",
+			BodyTmpl:  "आपकी यात्रा के लिए मौसम की चेतावनी: {{description}}",
+		},
+		templateKey(ItineraryUpdate, "hi"): {
+			Type:      ItineraryUpdate,
+			Language:  "hi",
+			TitleTmpl: "यात्रा अपडेट",
+			BodyTmpl:  "आपका यात्रा कार्यक्रम अपडेट किया गया है",
+		},
+		// Reminder bodies are written in the recipient's language by
+		// getTripReminderBody; the templates only localize the title
+		templateKey(TripReminder, "hi"): {
+			Type:      TripReminder,
+			Language:  "hi",
+			TitleTmpl: "यात्रा अनुस्मारक",
+			BodyTmpl:  "{{body}}",
+		},
+		templateKey(TripReminder, "bn"): {
+			Type:      TripReminder,
+			Language:  "bn",
+			TitleTmpl: "ভ্রমণ অনুস্মারক",
+			BodyTmpl:  "{{body}}",
+		},
+		templateKey(TripReminder, "ta"): {
+			Type:      TripReminder,
+			Language:  "ta",
+			TitleTmpl: "பயண நினைவூட்டல்",
+			BodyTmpl:  "{{body}}",
+		},
+		templateKey(TripReminder, "mr"): {
+			Type:      TripReminder,
+			Language:  "mr",
+			TitleTmpl: "प्रवास स्मरणपत्र",
+			BodyTmpl:  "{{body}}",
+		},
+	}
+}
+
+// templateKey builds the lookup key (and Firestore document ID) of a template
+func templateKey(notifType NotificationType, language string) string {
+	return string(notifType) + "_" + language
+}
+
+// ReloadTemplates loads notification templates from the notification_templates
+// collection on top of the built-in defaults
+func (n *NotificationService) ReloadTemplates(ctx context.Context) error {
+	templates := defaultNotificationTemplates()
+
+	if n.firebase != nil {
+		docs, err := n.firebase.GetFirestoreClient().
+			Collection("notification_templates").
+			Documents(ctx).
+			GetAll()
+		if err != nil {
+			n.setTemplates(templates)
+			return fmt.Errorf("failed to load notification templates: %w", err)
+		}
+
+		for _, doc := range docs {
+			var template NotificationTemplate
+			if err := doc.DataTo(&template); err != nil {
+				log.Printf("Failed to parse notification template %s: %v", doc.Ref.ID, err)
+				continue
+			}
+			if template.Type == "" || template.Language == "" {
+				continue
+			}
+			templates[templateKey(template.Type, template.Language)] = &template
+		}
+		log.Printf("Loaded %d notification templates", len(docs))
+	}
+
+	n.setTemplates(templates)
+	return nil
+}
+
+func (n *NotificationService) setTemplates(templates map[string]*NotificationTemplate) {
+	n.templatesMu.Lock()
+	defer n.templatesMu.Unlock()
+	n.templates = templates
 }
 
-// RegisterDeviceToken registers a user's device for notifications
-func (n *NotificationService) RegisterDeviceToken(ctx context.Context, userID, deviceToken, deviceType string) error {
+// RegisterDeviceToken registers a user's device for notifications. timezone
+// is the device's IANA zone, used for quiet hours; empty means UTC.
+func (n *NotificationService) RegisterDeviceToken(ctx context.Context, userID, deviceToken, deviceType, timezone string) error {
 	if !n.enabled {
 		return fmt.Errorf("notification service not enabled")
 	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return kindErrorf(ErrValidation, "unknown timezone %q", timezone)
+	}
 
 	token := &UserDeviceToken{
 		UserID:      userID,
 		DeviceToken: deviceToken,
 		DeviceType:  deviceType,
 		Language:    "en", // Default language
-		Timezone:    "UTC",
+		Timezone:    timezone,
 		Active:      true,
 		LastUsed:    time.Now(),
 		CreatedAt:   time.Now(),
@@ -130,34 +287,88 @@ func (n *NotificationService) RegisterDeviceToken(ctx context.Context, userID, d
 	return nil
 }
 
-// SendNotification sends a single notification to a user
-func (n *NotificationService) SendNotification(ctx context.Context, req *NotificationRequest) error {
+// NotificationResult describes what happened to a notification
+type NotificationResult struct {
+	UserID            string           `json:"user_id"`
+	Status            string           `json:"status"` // sent, partial, failed, suppressed, scheduled, skipped
+	SuccessCount      int              `json:"success_count"`
+	FailureCount      int              `json:"failure_count"`
+	Devices           []DeviceDelivery `json:"devices,omitempty"`
+	SuppressionReason string           `json:"suppression_reason,omitempty"`
+	ScheduledFor      *time.Time       `json:"scheduled_for,omitempty"`
+}
+
+// DeviceDelivery is the outcome of a notification for a single device
+type DeviceDelivery struct {
+	DeviceType string `json:"device_type"`
+	Success    bool   `json:"success"`
+	MessageID  string `json:"message_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SendNotification sends a single notification to a user. The returned error
+// is non-nil only for genuine failures; suppressed, skipped and deferred
+// notifications are reported through the result's Status.
+func (n *NotificationService) SendNotification(ctx context.Context, req *NotificationRequest) (result *NotificationResult, err error) {
+	defer func() {
+		outcome := metrics.OutcomeError
+		if err == nil {
+			outcome = result.Status
+		}
+		recordNotification(req, outcome)
+	}()
+
+	result = &NotificationResult{UserID: req.UserID}
+
 	if !n.enabled {
 		log.Printf("Notification service disabled, skipping: %s", req.Title)
-		return nil
+		result.Status = "skipped"
+		result.SuppressionReason = "notification service disabled"
+		return result, nil
+	}
+
+	// Respect the user's notification settings unless the alert is critical
+	if reason := n.suppressionReason(ctx, req); reason != "" {
+		log.Printf("Notification %q for user %s suppressed by preference: %s", req.Title, req.UserID, reason)
+		result.Status = "suppressed"
+		result.SuppressionReason = reason
+		return result, nil
 	}
 
 	// Get user's device tokens
 	tokens, err := n.getUserDeviceTokens(ctx, req.UserID)
 	if err != nil {
-		return fmt.Errorf("failed to get user device tokens: %w", err)
+		return nil, fmt.Errorf("failed to get user device tokens: %w", err)
 	}
 
 	if len(tokens) == 0 {
 		log.Printf("No device tokens found for user %s", req.UserID)
-		return nil
+		result.Status = "skipped"
+		result.SuppressionReason = "no active devices"
+		return result, nil
+	}
+
+	// Defer non-critical notifications that would land in the user's quiet hours
+	if wakeAt, quiet := n.quietHoursDeferral(ctx, req, tokens, time.Now()); quiet {
+		deferred := *req
+		deferred.ScheduleTime = &wakeAt
+		log.Printf("Deferring notification %q for user %s until %v (quiet hours)", req.Title, req.UserID, wakeAt)
+		if err := n.ScheduleNotification(ctx, &deferred); err != nil {
+			return nil, err
+		}
+		result.Status = "scheduled"
+		result.SuppressionReason = "quiet hours"
+		result.ScheduledFor = &wakeAt
+		return result, nil
 	}
 
 	// Apply localization if needed
 	localizedReq := n.localizeNotification(req, tokens[0].Language)
 
-	// Build FCM message
-	fcmMessage := n.buildFCMMessage(localizedReq, tokens)
-
-	// Send message
-	response, err := n.messagingClient.SendMulticast(ctx, fcmMessage)
+	// Send message, chunked to the FCM multicast limit
+	response, err := n.sendMulticastChunked(ctx, localizedReq, tokens)
 	if err != nil {
-		return fmt.Errorf("failed to send notification: %w", err)
+		return nil, fmt.Errorf("failed to send notification: %w", err)
 	}
 
 	// Log results
@@ -170,14 +381,52 @@ func (n *NotificationService) SendNotification(ctx context.Context, req *Notific
 	// Store notification history
 	n.storeNotificationHistory(ctx, req, response)
 
-	return nil
+	result.SuccessCount = response.SuccessCount
+	result.FailureCount = response.FailureCount
+	for i, resp := range response.Responses {
+		if i >= len(tokens) {
+			break
+		}
+		device := DeviceDelivery{
+			DeviceType: tokens[i].DeviceType,
+			Success:    resp.Success,
+			MessageID:  resp.MessageID,
+		}
+		if resp.Error != nil {
+			device.Error = resp.Error.Error()
+		}
+		result.Devices = append(result.Devices, device)
+	}
+
+	switch {
+	case response.FailureCount == 0:
+		result.Status = "sent"
+	case response.SuccessCount == 0:
+		result.Status = "failed"
+	default:
+		result.Status = "partial"
+	}
+
+	return result, nil
+}
+
+// recordNotification counts a notification send by its outcome
+func recordNotification(req *NotificationRequest, outcome string) {
+	metrics.NotificationsTotal.WithLabelValues(
+		metrics.Bounded(string(req.Type),
+			string(WeatherAlertType), string(ItineraryUpdate), string(TripReminder), string(DelayAlertType), string(BookingConfirm),
+			string(GeneralUpdate), string(EmergencyAlert), string(PriceAlertType), string(Recommendation), string(TripInviteType),
+			string(BudgetAlertType)),
+		metrics.Bounded(string(req.Priority), string(PriorityLow), string(PriorityNormal), string(PriorityHigh), string(PriorityCritical)),
+		outcome,
+	).Inc()
 }
 
 // SendWeatherAlert sends weather-related notifications
-func (n *NotificationService) SendWeatherAlert(ctx context.Context, userID, tripID string, alert interface{}) error {
+func (n *NotificationService) SendWeatherAlert(ctx context.Context, userID, tripID string, alert interface{}) (*NotificationResult, error) {
 	weatherAlert, ok := alert.(WeatherAlert)
 	if !ok {
-		return fmt.Errorf("invalid weather alert type")
+		return nil, fmt.Errorf("invalid weather alert type")
 	}
 
 	req := &NotificationRequest{
@@ -200,22 +449,47 @@ func (n *NotificationService) SendWeatherAlert(ctx context.Context, userID, trip
 	return n.SendNotification(ctx, req)
 }
 
-// SendTripUpdateNotification sends itinerary update notifications
-func (n *NotificationService) SendTripUpdateNotification(ctx context.Context, tripID, message string) error {
+// SendTripUpdateNotification sends an itinerary update once to the devices
+// following the trip. When roles are given, only devices of collaborators
+// with one of those roles receive it; the owner's always do.
+func (n *NotificationService) SendTripUpdateNotification(ctx context.Context, tripID, message string, roles ...string) (string, error) {
+	return n.SendTripTopicNotification(ctx, tripID, &NotificationRequest{
+		TripID:   tripID,
+		Type:     ItineraryUpdate,
+		Priority: PriorityHigh,
+		Title:    "Trip Update",
+		Body:     message,
+		Data: map[string]string{
+			"trip_id": tripID,
+			"type":    "itinerary_update",
+		},
+		ActionURL: fmt.Sprintf("/trips/%s", tripID),
+	}, roles...)
+}
+
+// SendLocalizedTripUpdateNotification sends itinerary update notifications to
+// every user on the trip, with a title and body built for each recipient,
+// e.g. in their own language. It returns one result per user reached. When
+// roles are given, only collaborators with one of those roles are notified;
+// the owner always is.
+func (n *NotificationService) SendLocalizedTripUpdateNotification(ctx context.Context, tripID string, message func(userID string) (title, body string), roles ...string) ([]*NotificationResult, error) {
 	// Get all users for this trip
-	userIDs, err := n.getTripUserIDs(ctx, tripID)
+	userIDs, err := n.getTripUserIDs(ctx, tripID, roles...)
 	if err != nil {
-		return fmt.Errorf("failed to get trip users: %w", err)
+		return nil, fmt.Errorf("failed to get trip users: %w", err)
 	}
 
+	var results []*NotificationResult
+
 	for _, userID := range userIDs {
+		title, body := message(userID)
 		req := &NotificationRequest{
 			UserID:   userID,
 			TripID:   tripID,
 			Type:     ItineraryUpdate,
 			Priority: PriorityHigh,
-			Title:    "Trip Update",
-			Body:     message,
+			Title:    title,
+			Body:     body,
 			Data: map[string]string{
 				"trip_id": tripID,
 				"type":    "itinerary_update",
@@ -223,19 +497,45 @@ func (n *NotificationService) SendTripUpdateNotification(ctx context.Context, tr
 			ActionURL: fmt.Sprintf("/trips/%s", tripID),
 		}
 
-		if err := n.SendNotification(ctx, req); err != nil {
+		result, err := n.SendNotification(ctx, req)
+		if err != nil {
 			log.Printf("Failed to send trip update to user %s: %v", userID, err)
+			result = &NotificationResult{UserID: userID, Status: "failed"}
 		}
+		results = append(results, result)
 	}
 
-	return nil
+	return results, nil
+}
+
+// SendToTrip sends req to every user on its trip, the owner and accepted
+// collaborators, and returns one result per user reached
+func (n *NotificationService) SendToTrip(ctx context.Context, req *NotificationRequest) ([]*NotificationResult, error) {
+	userIDs, err := n.getTripUserIDs(ctx, req.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip users: %w", err)
+	}
+
+	var results []*NotificationResult
+	for _, userID := range userIDs {
+		userReq := *req
+		userReq.UserID = userID
+		result, err := n.SendNotification(ctx, &userReq)
+		if err != nil {
+			log.Printf("Failed to send trip notification to user %s: %v", userID, err)
+			result = &NotificationResult{UserID: userID, Status: "failed"}
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
 }
 
 // SendDelayAlert sends transportation/event delay notifications
-func (n *NotificationService) SendDelayAlert(ctx context.Context, userID, tripID string, alert interface{}) error {
+func (n *NotificationService) SendDelayAlert(ctx context.Context, userID, tripID string, alert interface{}) (*NotificationResult, error) {
 	delayAlert, ok := alert.(DelayAlert)
 	if !ok {
-		return fmt.Errorf("invalid delay alert type")
+		return nil, fmt.Errorf("invalid delay alert type")
 	}
 
 	req := &NotificationRequest{
@@ -258,15 +558,16 @@ func (n *NotificationService) SendDelayAlert(ctx context.Context, userID, tripID
 	return n.SendNotification(ctx, req)
 }
 
-// SendTripReminder sends trip reminders (e.g., "Trip starts tomorrow")
-func (n *NotificationService) SendTripReminder(ctx context.Context, userID, tripID string, reminderType string, timeUntil time.Duration) error {
+// SendTripReminder sends trip reminders (e.g., "Trip starts tomorrow"),
+// saying how long until the trip in the recipient's language
+func (n *NotificationService) SendTripReminder(ctx context.Context, userID, tripID string, reminderType string, timeUntil time.Duration) (*NotificationResult, error) {
 	req := &NotificationRequest{
 		UserID:   userID,
 		TripID:   tripID,
 		Type:     TripReminder,
 		Priority: PriorityNormal,
 		Title:    n.getTripReminderTitle(reminderType, timeUntil),
-		Body:     n.getTripReminderBody(reminderType, timeUntil),
+		Body:     n.getTripReminderBody(reminderType, timeUntil, n.recipientLanguage(ctx, userID)),
 		Data: map[string]string{
 			"trip_id":       tripID,
 			"reminder_type": reminderType,
@@ -279,7 +580,7 @@ func (n *NotificationService) SendTripReminder(ctx context.Context, userID, trip
 }
 
 // SendBookingConfirmation sends booking confirmation notifications
-func (n *NotificationService) SendBookingConfirmation(ctx context.Context, userID, tripID, bookingType, confirmationNumber string) error {
+func (n *NotificationService) SendBookingConfirmation(ctx context.Context, userID, tripID, bookingType, confirmationNumber string) (*NotificationResult, error) {
 	req := &NotificationRequest{
 		UserID:   userID,
 		TripID:   tripID,
@@ -298,17 +599,343 @@ func (n *NotificationService) SendBookingConfirmation(ctx context.Context, userI
 	return n.SendNotification(ctx, req)
 }
 
-// ScheduleNotification schedules a notification for future delivery
+// SendBudgetAlert warns a traveler that they're spending faster than their
+// trip's budget allows
+func (n *NotificationService) SendBudgetAlert(ctx context.Context, userID, tripID string, status BudgetStatus) (*NotificationResult, error) {
+	body := fmt.Sprintf("At your current pace you'll spend %.2f %s of your %.2f %s budget.",
+		status.ProjectedSpend, status.Currency, status.Budget, status.Currency)
+	if status.DaysRemaining > 0 {
+		body += fmt.Sprintf(" Keep to %.2f %s a day to stay within it.", status.AllowedDailySpend, status.Currency)
+	}
+	req := &NotificationRequest{
+		UserID:   userID,
+		TripID:   tripID,
+		Type:     BudgetAlertType,
+		Priority: PriorityHigh,
+		Title:    "Spending Ahead of Budget",
+		Body:     body,
+		Data: map[string]string{
+			"trip_id":         tripID,
+			"spent":           fmt.Sprintf("%.2f", status.Spent),
+			"projected_spend": fmt.Sprintf("%.2f", status.ProjectedSpend),
+			"budget":          fmt.Sprintf("%.2f", status.Budget),
+			"currency":        status.Currency,
+		},
+		ActionURL: fmt.Sprintf("/trips/%s/expenses", tripID),
+	}
+
+	return n.SendNotification(ctx, req)
+}
+
+// NotificationHistoryItem is a sent notification stored in notification_history
+type NotificationHistoryItem struct {
+	ID           string           `firestore:"-" json:"id"`
+	UserID       string           `firestore:"user_id" json:"user_id"`
+	TripID       string           `firestore:"trip_id" json:"trip_id,omitempty"`
+	Type         NotificationType `firestore:"type" json:"type"`
+	Title        string           `firestore:"title" json:"title"`
+	Body         string           `firestore:"body" json:"body"`
+	SentAt       time.Time        `firestore:"sent_at" json:"sent_at"`
+	SuccessCount int              `firestore:"success_count" json:"success_count"`
+	FailureCount int              `firestore:"failure_count" json:"failure_count"`
+	Read         bool             `firestore:"read" json:"read"`
+	ReadAt       *time.Time       `firestore:"read_at" json:"read_at,omitempty"`
+}
+
+// GetNotificationHistory returns a page of a user's notifications, newest
+// first, together with the total number of notifications the user has
+func (n *NotificationService) GetNotificationHistory(ctx context.Context, userID string, limit, offset int) ([]NotificationHistoryItem, int, error) {
+	if n.firebase == nil {
+		return nil, 0, fmt.Errorf("firebase service not available")
+	}
+
+	query := n.firebase.GetFirestoreClient().
+		Collection("notification_history").
+		Where("user_id", "==", userID)
+
+	total, err := countQuery(ctx, query)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+
+	docs, err := query.
+		OrderBy("sent_at", firestore.Desc).
+		Offset(offset).
+		Limit(limit).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get notifications: %w", err)
+	}
+
+	items := make([]NotificationHistoryItem, 0, len(docs))
+	for _, doc := range docs {
+		var item NotificationHistoryItem
+		if err := doc.DataTo(&item); err != nil {
+			log.Printf("Failed to parse notification history %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		item.ID = doc.Ref.ID
+		items = append(items, item)
+	}
+
+	return items, total, nil
+}
+
+// MarkRead marks one of the user's notifications as read
+func (n *NotificationService) MarkRead(ctx context.Context, userID, notificationID string) error {
+	if n.firebase == nil {
+		return fmt.Errorf("firebase service not available")
+	}
+
+	ref := n.firebase.GetFirestoreClient().Collection("notification_history").Doc(notificationID)
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("notification not found: %w", err)
+	}
+
+	if owner, _ := doc.Data()["user_id"].(string); owner != userID {
+		return fmt.Errorf("notification not found")
+	}
+
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "read", Value: true},
+		{Path: "read_at", Value: time.Now()},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark notification read: %w", err)
+	}
+
+	n.badgeMu.Lock()
+	delete(n.badgeCache, userID)
+	n.badgeMu.Unlock()
+
+	return nil
+}
+
+// tripTopic returns the FCM topic that devices of travelers with role on a
+// trip subscribe to. Each role has its own topic so an update can go to
+// editors without reaching viewers.
+func tripTopic(tripID, role string) string {
+	var b strings.Builder
+	b.WriteString("trip_")
+	for _, r := range tripID {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || strings.ContainsRune("-_.~%", r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	b.WriteString("_")
+	b.WriteString(role)
+	return b.String()
+}
+
+// tripTopicCondition is an FCM condition matching devices subscribed to the
+// trip's topic for any of roles, or for every role when none are given. The
+// owner's topic is always included.
+func tripTopicCondition(tripID string, roles ...string) string {
+	if len(roles) == 0 {
+		roles = []string{TripRoleEditor, TripRoleViewer}
+	}
+	parts := []string{fmt.Sprintf("'%s' in topics", tripTopic(tripID, TripRoleOwner))}
+	for _, role := range roles {
+		if role != TripRoleOwner {
+			parts = append(parts, fmt.Sprintf("'%s' in topics", tripTopic(tripID, role)))
+		}
+	}
+	return strings.Join(parts, " || ")
+}
+
+// TripTopicSubscription records a device following a trip, so it can be
+// unsubscribed when the trip goes away
+type TripTopicSubscription struct {
+	TripID      string    `firestore:"trip_id"`
+	UserID      string    `firestore:"user_id"`
+	Role        string    `firestore:"role"`
+	DeviceToken string    `firestore:"device_token"`
+	CreatedAt   time.Time `firestore:"created_at"`
+}
+
+// tripTopicSubscriptionID is the trip_topic_subscriptions document ID for a
+// device on a trip
+func tripTopicSubscriptionID(tripID, deviceToken string) string {
+	sum := sha256.Sum256([]byte(deviceToken))
+	return fmt.Sprintf("%s_%x", tripID, sum[:8])
+}
+
+// SubscribeDeviceToTripTopic subscribes a device to the topic for the user's
+// role on a trip. Callers must have checked the user can view the trip.
+func (n *NotificationService) SubscribeDeviceToTripTopic(ctx context.Context, tripID, userID, role, deviceToken string) error {
+	if !n.enabled {
+		return fmt.Errorf("notification service not enabled")
+	}
+
+	topic := tripTopic(tripID, role)
+	resp, err := n.messagingClient.SubscribeToTopic(ctx, []string{deviceToken}, topic)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to trip topic: %w", err)
+	}
+	if resp.FailureCount > 0 && len(resp.Errors) > 0 {
+		return fmt.Errorf("failed to subscribe to trip topic: %s", resp.Errors[0].Reason)
+	}
+
+	_, err = n.firebase.GetFirestoreClient().
+		Collection("trip_topic_subscriptions").
+		Doc(tripTopicSubscriptionID(tripID, deviceToken)).
+		Set(ctx, TripTopicSubscription{
+			TripID:      tripID,
+			UserID:      userID,
+			Role:        role,
+			DeviceToken: deviceToken,
+			CreatedAt:   time.Now(),
+		})
+	if err != nil {
+		return fmt.Errorf("failed to record trip topic subscription: %w", err)
+	}
+
+	log.Printf("Subscribed device to topic %s", topic)
+	return nil
+}
+
+// UnsubscribeDeviceFromTripTopic removes a device from a trip's topic for
+// role
+func (n *NotificationService) UnsubscribeDeviceFromTripTopic(ctx context.Context, tripID, role, deviceToken string) error {
+	if !n.enabled {
+		return fmt.Errorf("notification service not enabled")
+	}
+
+	topic := tripTopic(tripID, role)
+	resp, err := n.messagingClient.UnsubscribeFromTopic(ctx, []string{deviceToken}, topic)
+	if err != nil {
+		return fmt.Errorf("failed to unsubscribe from trip topic: %w", err)
+	}
+	if resp.FailureCount > 0 && len(resp.Errors) > 0 {
+		return fmt.Errorf("failed to unsubscribe from trip topic: %s", resp.Errors[0].Reason)
+	}
+
+	log.Printf("Unsubscribed device from topic %s", topic)
+	return nil
+}
+
+// UnsubscribeTripDevices unsubscribes every device following a trip, e.g.
+// once it's deleted. Subscriptions that fail stay recorded so a later call
+// can retry them.
+func (n *NotificationService) UnsubscribeTripDevices(ctx context.Context, tripID string) error {
+	if !n.enabled {
+		return nil
+	}
+
+	docs, err := n.firebase.GetFirestoreClient().
+		Collection("trip_topic_subscriptions").
+		Where("trip_id", "==", tripID).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to get trip topic subscriptions: %w", err)
+	}
+
+	var failed int
+	for _, doc := range docs {
+		var sub TripTopicSubscription
+		if err := doc.DataTo(&sub); err != nil {
+			log.Printf("Skipping malformed trip topic subscription %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		if err := n.UnsubscribeDeviceFromTripTopic(ctx, tripID, sub.Role, sub.DeviceToken); err != nil {
+			log.Printf("Failed to unsubscribe device from trip %s: %v", tripID, err)
+			failed++
+			continue
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			log.Printf("Failed to delete trip topic subscription %s: %v", doc.Ref.ID, err)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to unsubscribe %d of %d devices from trip %s", failed, len(docs), tripID)
+	}
+	return nil
+}
+
+// SendTripTopicNotification sends a single message to every device following
+// the trip, instead of one multicast per collaborator. When roles are given,
+// only devices of collaborators with one of those roles receive it; the
+// owner's always do. Per-user preferences and quiet hours are not applied,
+// so use it for updates that should reach all collaborators at once.
+func (n *NotificationService) SendTripTopicNotification(ctx context.Context, tripID string, req *NotificationRequest, roles ...string) (string, error) {
+	if !n.enabled {
+		return "", fmt.Errorf("notification service not enabled")
+	}
+
+	multicast := n.buildFCMMessage(ctx, req, nil)
+	message := &messaging.Message{
+		Condition:    tripTopicCondition(tripID, roles...),
+		Notification: multicast.Notification,
+		Data:         multicast.Data,
+		Android:      multicast.Android,
+		APNS:         multicast.APNS,
+	}
+
+	_, span := tracing.Start(ctx, "fcm.send", tracing.KindClient, tracing.Attr("peer.service", "fcm"))
+	start := time.Now()
+	messageID, err := n.messagingClient.Send(ctx, message)
+	span.EndWithError(err)
+	metrics.ObserveExternalCall("fcm", start, err)
+	if err != nil {
+		recordNotification(req, "failed")
+		return "", fmt.Errorf("failed to send trip topic notification: %w", err)
+	}
+	recordNotification(req, "sent")
+
+	log.Printf("Sent notification to trip %s topics: %s", tripID, messageID)
+	return messageID, nil
+}
+
+// ScheduledNotification is a notification waiting in scheduled_notifications
+type ScheduledNotification struct {
+	Request      NotificationRequest `firestore:"request"`
+	ScheduleTime time.Time           `firestore:"schedule_time"`
+	Attempts     int                 `firestore:"attempts"`
+	LeaseUntil   time.Time           `firestore:"lease_until"`
+	LastError    string              `firestore:"last_error"`
+	CreatedAt    time.Time           `firestore:"created_at"`
+}
+
+const (
+	// scheduledNotificationLease is how long a processor owns a claimed
+	// notification before another processor may pick it up
+	scheduledNotificationLease = 5 * time.Minute
+	// maxScheduledNotificationAttempts is how many sends are tried before a
+	// notification is moved to the dead-letter collection
+	maxScheduledNotificationAttempts = 5
+	// scheduledNotificationBaseBackoff is the delay after the first failure,
+	// doubled for every further attempt
+	scheduledNotificationBaseBackoff = time.Minute
+)
+
+// errNotificationClaimed signals that another processor holds the lease
+var errNotificationClaimed = errors.New("scheduled notification already claimed")
+
+// ScheduleNotification schedules a notification for future delivery. Each
+// is stored under a random ID, so several of the same type for the same user
+// and time are all delivered.
 func (n *NotificationService) ScheduleNotification(ctx context.Context, req *NotificationRequest) error {
 	if req.ScheduleTime == nil {
-		return n.SendNotification(ctx, req)
+		_, err := n.SendNotification(ctx, req)
+		return err
+	}
+
+	scheduled := ScheduledNotification{
+		Request:      *req,
+		ScheduleTime: *req.ScheduleTime,
+		CreatedAt:    time.Now(),
 	}
 
 	// Store scheduled notification in Firestore
 	_, err := n.firebase.GetFirestoreClient().
 		Collection("scheduled_notifications").
-		Doc(fmt.Sprintf("%s_%d", req.UserID, req.ScheduleTime.Unix())).
-		Set(ctx, req)
+		Doc(uuid.New().String()).
+		Create(ctx, scheduled)
 
 	if err != nil {
 		return fmt.Errorf("failed to schedule notification: %w", err)
@@ -318,16 +945,21 @@ func (n *NotificationService) ScheduleNotification(ctx context.Context, req *Not
 	return nil
 }
 
-// ProcessScheduledNotifications processes notifications that are due
+// ProcessScheduledNotifications processes notifications that are due. Each
+// notification is claimed with a lease in a transaction before sending, so
+// concurrent processors never send the same one twice. Failed sends are
+// retried with exponential backoff and dead-lettered after
+// maxScheduledNotificationAttempts.
 func (n *NotificationService) ProcessScheduledNotifications(ctx context.Context) error {
 	if !n.enabled {
 		return nil
 	}
 
 	now := time.Now()
+	client := n.firebase.GetFirestoreClient()
 
 	// Query scheduled notifications that are due
-	docs, err := n.firebase.GetFirestoreClient().
+	docs, err := client.
 		Collection("scheduled_notifications").
 		Where("schedule_time", "<=", now).
 		Documents(ctx).
@@ -337,32 +969,276 @@ func (n *NotificationService) ProcessScheduledNotifications(ctx context.Context)
 		return fmt.Errorf("failed to query scheduled notifications: %w", err)
 	}
 
+	processed := 0
 	for _, doc := range docs {
-		var req NotificationRequest
-		if err := doc.DataTo(&req); err != nil {
-			log.Printf("Failed to parse scheduled notification: %v", err)
+		// Claim the notification
+		var scheduled ScheduledNotification
+		err := client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+			snap, err := tx.Get(doc.Ref)
+			if err != nil {
+				return err
+			}
+			if err := snap.DataTo(&scheduled); err != nil {
+				return err
+			}
+			if scheduled.LeaseUntil.After(time.Now()) {
+				return errNotificationClaimed
+			}
+			scheduled.Attempts++
+			return tx.Update(doc.Ref, []firestore.Update{
+				{Path: "lease_until", Value: time.Now().Add(scheduledNotificationLease)},
+				{Path: "attempts", Value: scheduled.Attempts},
+			})
+		})
+		if errors.Is(err, errNotificationClaimed) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to claim scheduled notification %s: %v", doc.Ref.ID, err)
 			continue
 		}
 
 		// Send the notification
-		if err := n.SendNotification(ctx, &req); err != nil {
-			log.Printf("Failed to send scheduled notification: %v", err)
+		result, sendErr := n.SendNotification(ctx, &scheduled.Request)
+		if sendErr == nil && result != nil && result.Status == "failed" {
+			sendErr = fmt.Errorf("delivery failed on all %d devices", result.FailureCount)
+		}
+
+		if sendErr == nil {
+			// Delete the scheduled notification
+			if _, err := doc.Ref.Delete(ctx); err != nil {
+				log.Printf("Failed to delete sent scheduled notification %s: %v", doc.Ref.ID, err)
+			}
+			processed++
 			continue
 		}
 
-		// Delete the scheduled notification
-		doc.Ref.Delete(ctx)
+		log.Printf("Failed to send scheduled notification %s (attempt %d): %v", doc.Ref.ID, scheduled.Attempts, sendErr)
+		n.handleScheduledFailure(ctx, doc.Ref, scheduled, sendErr)
 	}
 
-	if len(docs) > 0 {
-		log.Printf("Processed %d scheduled notifications", len(docs))
+	if processed > 0 {
+		log.Printf("Processed %d scheduled notifications", processed)
 	}
 
 	return nil
 }
 
+// handleScheduledFailure reschedules a failed notification with backoff, or
+// moves it to the dead-letter collection once it is out of attempts
+func (n *NotificationService) handleScheduledFailure(ctx context.Context, ref *firestore.DocumentRef, scheduled ScheduledNotification, sendErr error) {
+	if scheduled.Attempts >= maxScheduledNotificationAttempts {
+		scheduled.LastError = sendErr.Error()
+		scheduled.LeaseUntil = time.Time{}
+		_, err := n.firebase.GetFirestoreClient().
+			Collection("scheduled_notifications_dead_letter").
+			Doc(ref.ID).
+			Set(ctx, scheduled)
+		if err != nil {
+			log.Printf("Failed to dead-letter scheduled notification %s: %v", ref.ID, err)
+			return
+		}
+		ref.Delete(ctx)
+		log.Printf("Moved scheduled notification %s to dead letter after %d attempts", ref.ID, scheduled.Attempts)
+		return
+	}
+
+	backoff := scheduledNotificationBaseBackoff << uint(scheduled.Attempts-1)
+	_, err := ref.Update(ctx, []firestore.Update{
+		{Path: "schedule_time", Value: time.Now().Add(backoff)},
+		{Path: "lease_until", Value: time.Time{}},
+		{Path: "last_error", Value: sendErr.Error()},
+	})
+	if err != nil {
+		log.Printf("Failed to reschedule notification %s: %v", ref.ID, err)
+	}
+}
+
 // Helper methods
 
+// suppressionReason returns why the user's settings block this notification,
+// or "" if it may be sent. Critical priority and emergency alerts always send.
+func (n *NotificationService) suppressionReason(ctx context.Context, req *NotificationRequest) string {
+	if req.Priority == PriorityCritical || req.Type == EmergencyAlert {
+		return ""
+	}
+
+	settings := n.getNotificationSettings(ctx, req.UserID)
+	if !settings.Push {
+		return "push notifications disabled"
+	}
+
+	switch req.Type {
+	case TripReminder:
+		if !settings.TripReminders {
+			return "trip reminders disabled"
+		}
+	case PriceAlertType:
+		if !settings.PriceAlerts {
+			return "price alerts disabled"
+		}
+	case Recommendation:
+		if !settings.Recommendations {
+			return "recommendations disabled"
+		}
+	}
+
+	return ""
+}
+
+// getNotificationSettings loads a user's notification settings from their
+// profile, caching them briefly. Users without saved settings get everything
+// enabled.
+func (n *NotificationService) getNotificationSettings(ctx context.Context, userID string) models.NotificationSettings {
+	n.settingsMu.Lock()
+	if cached, ok := n.settingsCache[userID]; ok && time.Since(cached.loadedAt) < notificationSettingsTTL {
+		n.settingsMu.Unlock()
+		return cached.settings
+	}
+	n.settingsMu.Unlock()
+
+	settings := models.NotificationSettings{
+		Email:           true,
+		SMS:             true,
+		Push:            true,
+		TripReminders:   true,
+		PriceAlerts:     true,
+		Recommendations: true,
+	}
+
+	if n.firebase != nil {
+		doc, err := n.firebase.GetFirestoreClient().Collection("users").Doc(userID).Get(ctx)
+		if err == nil {
+			if prefs, ok := doc.Data()["travel_preferences"].(map[string]interface{}); ok {
+				if stored, ok := prefs["notification_settings"].(map[string]interface{}); ok {
+					settings.Email = boolSetting(stored, "email", settings.Email)
+					settings.SMS = boolSetting(stored, "sms", settings.SMS)
+					settings.Push = boolSetting(stored, "push", settings.Push)
+					settings.TripReminders = boolSetting(stored, "trip_reminders", settings.TripReminders)
+					settings.PriceAlerts = boolSetting(stored, "price_alerts", settings.PriceAlerts)
+					settings.Recommendations = boolSetting(stored, "recommendations", settings.Recommendations)
+					settings.QuietHoursStart, _ = stored["quiet_hours_start"].(string)
+					settings.QuietHoursEnd, _ = stored["quiet_hours_end"].(string)
+				}
+			}
+		}
+	}
+
+	n.settingsMu.Lock()
+	if n.settingsCache == nil {
+		n.settingsCache = make(map[string]cachedNotificationSettings)
+	}
+	n.settingsCache[userID] = cachedNotificationSettings{settings: settings, loadedAt: time.Now()}
+	n.settingsMu.Unlock()
+
+	return settings
+}
+
+// Default quiet window, in the device's local time
+const (
+	defaultQuietHoursStart = "22:00"
+	defaultQuietHoursEnd   = "08:00"
+)
+
+// quietHoursDeferral reports whether a notification should wait for the user's
+// quiet hours to end and, if so, until when. With devices in several
+// timezones the notification goes out as soon as any device is outside its
+// quiet window. Critical notifications are never deferred.
+func (n *NotificationService) quietHoursDeferral(ctx context.Context, req *NotificationRequest, tokens []UserDeviceToken, now time.Time) (time.Time, bool) {
+	if req.Priority == PriorityCritical || req.Type == EmergencyAlert {
+		return time.Time{}, false
+	}
+
+	settings := n.getNotificationSettings(ctx, req.UserID)
+	start, ok := parseClock(settings.QuietHoursStart)
+	if !ok {
+		start, _ = parseClock(defaultQuietHoursStart)
+	}
+	end, ok := parseClock(settings.QuietHoursEnd)
+	if !ok {
+		end, _ = parseClock(defaultQuietHoursEnd)
+	}
+	if start == end {
+		return time.Time{}, false
+	}
+
+	var earliest time.Time
+	for _, token := range tokens {
+		loc, err := time.LoadLocation(token.Timezone)
+		if err != nil || token.Timezone == "" {
+			loc = time.UTC
+		}
+
+		wakeAt, quiet := quietWindowEnd(now, loc, start, end)
+		if !quiet {
+			// At least one device is awake; send now
+			return time.Time{}, false
+		}
+		if earliest.IsZero() || wakeAt.Before(earliest) {
+			earliest = wakeAt
+		}
+	}
+
+	if earliest.IsZero() {
+		return time.Time{}, false
+	}
+	return earliest, true
+}
+
+// quietWindowEnd reports whether now falls inside the quiet window [start, end)
+// in loc, and when the window ends. start and end are minutes after midnight;
+// a start later than end spans midnight. Wall-clock arithmetic through
+// time.Date keeps the result correct across DST changes, and an end skipped
+// by a spring-forward gap resolves to the moment of the jump.
+func quietWindowEnd(now time.Time, loc *time.Location, start, end int) (time.Time, bool) {
+	local := now.In(loc)
+	minutes := local.Hour()*60 + local.Minute()
+
+	var quiet bool
+	dayOffset := 0
+	if start < end {
+		quiet = minutes >= start && minutes < end
+	} else {
+		quiet = minutes >= start || minutes < end
+		if minutes >= start {
+			dayOffset = 1
+		}
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	wakeAt := time.Date(local.Year(), local.Month(), local.Day()+dayOffset, end/60, end%60, 0, 0, loc)
+	// An end inside a spring-forward gap doesn't exist on the wall clock; the
+	// window ends when the clock jumps past it
+	if wakeAt.Hour()*60+wakeAt.Minute() != end {
+		zoneStart, zoneEnd := wakeAt.ZoneBounds()
+		if wakeAt.Hour()*60+wakeAt.Minute() < end {
+			wakeAt = zoneEnd
+		} else {
+			wakeAt = zoneStart
+		}
+	}
+	return wakeAt, true
+}
+
+// parseClock parses "HH:MM" into minutes after midnight
+func parseClock(value string) (int, bool) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// boolSetting reads a boolean setting, falling back to def when it is absent
+func boolSetting(settings map[string]interface{}, key string, def bool) bool {
+	if v, ok := settings[key].(bool); ok {
+		return v
+	}
+	return def
+}
+
 func (n *NotificationService) getUserDeviceTokens(ctx context.Context, userID string) ([]UserDeviceToken, error) {
 	docs, err := n.firebase.GetFirestoreClient().
 		Collection("user_device_tokens").
@@ -386,7 +1262,75 @@ func (n *NotificationService) getUserDeviceTokens(ctx context.Context, userID st
 	return tokens, nil
 }
 
-func (n *NotificationService) buildFCMMessage(req *NotificationRequest, tokens []UserDeviceToken) *messaging.MulticastMessage {
+// fcmMulticastLimit is the maximum number of tokens FCM accepts per multicast
+const fcmMulticastLimit = 500
+
+// chunkSendError is the error of every token in a multicast chunk that
+// couldn't be sent at all. It says nothing about the tokens themselves.
+type chunkSendError struct {
+	err error
+}
+
+func (e *chunkSendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *chunkSendError) Unwrap() error {
+	return e.err
+}
+
+// sendMulticastChunked sends the notification to every token, splitting the
+// tokens into multicast-sized chunks. The returned BatchResponse aggregates all
+// chunks and its Responses stay index-aligned with tokens.
+func (n *NotificationService) sendMulticastChunked(ctx context.Context, req *NotificationRequest, tokens []UserDeviceToken) (*messaging.BatchResponse, error) {
+	aggregate := &messaging.BatchResponse{
+		Responses: make([]*messaging.SendResponse, 0, len(tokens)),
+	}
+
+	var lastErr error
+	failedChunks, chunks := 0, 0
+	for start := 0; start < len(tokens); start += fcmMulticastLimit {
+		end := start + fcmMulticastLimit
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		chunk := tokens[start:end]
+		chunks++
+
+		_, span := tracing.Start(ctx, "fcm.send_multicast", tracing.KindClient,
+			tracing.Attr("peer.service", "fcm"),
+			tracing.Attr("fcm.token_count", len(chunk)),
+		)
+		sentAt := time.Now()
+		response, err := n.messagingClient.SendEachForMulticast(ctx, n.buildFCMMessage(ctx, req, chunk))
+		span.EndWithError(err)
+		metrics.ObserveExternalCall("fcm", sentAt, err)
+		if err != nil {
+			log.Printf("Failed to send notification chunk %d-%d: %v", start, end, err)
+			lastErr = err
+			failedChunks++
+			// Keep Responses aligned with tokens so later chunks map correctly
+			chunkErr := &chunkSendError{err: err}
+			for range chunk {
+				aggregate.Responses = append(aggregate.Responses, &messaging.SendResponse{Success: false, Error: chunkErr})
+			}
+			aggregate.FailureCount += len(chunk)
+			continue
+		}
+
+		aggregate.SuccessCount += response.SuccessCount
+		aggregate.FailureCount += response.FailureCount
+		aggregate.Responses = append(aggregate.Responses, response.Responses...)
+	}
+
+	if chunks > 0 && failedChunks == chunks {
+		return nil, lastErr
+	}
+
+	return aggregate, nil
+}
+
+func (n *NotificationService) buildFCMMessage(ctx context.Context, req *NotificationRequest, tokens []UserDeviceToken) *messaging.MulticastMessage {
 	// Extract device tokens
 	var deviceTokens []string
 	for _, token := range tokens {
@@ -443,7 +1387,7 @@ func (n *NotificationService) buildFCMMessage(req *NotificationRequest, tokens [
 					Body:  req.Body,
 				},
 				Sound: n.getSoundForPriority(req.Priority),
-				Badge: n.getBadgeCount(req.UserID),
+				Badge: n.getBadgeCount(ctx, req.UserID),
 			},
 		},
 	}
@@ -469,63 +1413,121 @@ func (n *NotificationService) localizeNotification(req *NotificationRequest, lan
 		return req // Fallback to original
 	}
 
+	variables := n.templateVariables(req, template)
+	title, titleOK := n.applyTemplate(template.TitleTmpl, variables)
+	body, bodyOK := n.applyTemplate(template.BodyTmpl, variables)
+	if !titleOK || !bodyOK {
+		log.Printf("Template %s has unresolved variables, sending original text", templateKey(template.Type, template.Language))
+		return req
+	}
+
 	// Clone request and apply localization
 	localizedReq := *req
-	localizedReq.Title = n.applyTemplate(template.TitleTmpl, req.Data)
-	localizedReq.Body = n.applyTemplate(template.BodyTmpl, req.Data)
-	localizedReq.Language = language
+	localizedReq.Title = title
+	localizedReq.Body = body
+	localizedReq.Language = template.Language
 
 	return &localizedReq
 }
 
+// getNotificationTemplate finds the template for a type, walking the locale
+// fallback chain (e.g. "hi-IN" -> "hi"). nil means use the original English.
 func (n *NotificationService) getNotificationTemplate(notifType NotificationType, language string) *NotificationTemplate {
-	// In production, load from database or configuration
-	templates := map[string]*NotificationTemplate{
-		string(WeatherAlertType) + "_hi": {
-			Type:      WeatherAlertType,
-			Language:  "hi",
-			TitleTmpl: "मौसम चेतावनी",
-			BodyTmpl:  "आपकी यात्रा के लिए मौसम की चेतावनी: {{description}}",
-		},
-		string(ItineraryUpdate) + "_hi": {
-			Type:      ItineraryUpdate,
-			Language:  "hi",
-			TitleTmpl: "यात्रा अपडेट",
-			BodyTmpl:  "आपका यात्रा कार्यक्रम अपडेट किया गया है",
-		},
+	n.templatesMu.RLock()
+	templates := n.templates
+	n.templatesMu.RUnlock()
+	if templates == nil {
+		templates = defaultNotificationTemplates()
 	}
 
-	key := string(notifType) + "_" + language
-	return templates[key]
+	for _, locale := range localeFallbackChain(language) {
+		if template, ok := templates[templateKey(notifType, locale)]; ok {
+			return template
+		}
+	}
+	return nil
 }
 
-func (n *NotificationService) applyTemplate(template string, data map[string]string) string {
-	result := template
-	if data != nil {
-		for key, value := range data {
-			placeholder := "{{" + key + "}}"
-			result = strings.ReplaceAll(result, placeholder, value)
+// localeFallbackChain returns a locale followed by its progressively less
+// specific parents, e.g. "zh-Hant-TW" -> ["zh-Hant-TW", "zh-Hant", "zh"]
+func localeFallbackChain(language string) []string {
+	language = strings.ReplaceAll(language, "_", "-")
+	chain := []string{language}
+	for {
+		i := strings.LastIndex(language, "-")
+		if i <= 0 {
+			break
 		}
+		language = language[:i]
+		chain = append(chain, language)
+	}
+	return chain
+}
+
+// templateVariables collects the values available to a template: the
+// template's own defaults, standard request fields, then the request data
+func (n *NotificationService) templateVariables(req *NotificationRequest, template *NotificationTemplate) map[string]string {
+	variables := make(map[string]string)
+	for k, v := range template.Variables {
+		variables[k] = v
+	}
+
+	variables["title"] = req.Title
+	variables["body"] = req.Body
+	variables["description"] = req.Body
+	variables["type"] = string(req.Type)
+	variables["trip_id"] = req.TripID
+	variables["user_id"] = req.UserID
+
+	for k, v := range req.Data {
+		variables[k] = v
+	}
+
+	// Reminders say how long until the trip in the template's language
+	if timeUntil, err := time.ParseDuration(req.Data["time_until"]); err == nil {
+		variables["relative_time"] = formatRelativeDuration(timeUntil, template.Language)
+	}
+	return variables
+}
+
+// applyTemplate substitutes {{variable}} placeholders. The boolean is false if
+// any placeholder was left unresolved.
+func (n *NotificationService) applyTemplate(template string, data map[string]string) (string, bool) {
+	result := template
+	for key, value := range data {
+		placeholder := "{{" + key + "}}"
+		result = strings.ReplaceAll(result, placeholder, value)
 	}
-	return result
+	return result, !strings.Contains(result, "{{")
 }
 
 func (n *NotificationService) handleFailedTokens(ctx context.Context, response *messaging.BatchResponse, tokens []UserDeviceToken) {
 	for i, resp := range response.Responses {
-		if !resp.Success && i < len(tokens) {
-			// Handle invalid tokens
-			if messaging.IsRegistrationTokenNotRegistered(resp.Error) ||
-				messaging.IsInvalidArgument(resp.Error) {
-				// Deactivate invalid token
-				n.deactivateDeviceToken(ctx, tokens[i].DeviceToken)
-			}
+		if !resp.Success && i < len(tokens) && tokenRejected(resp.Error) {
+			n.deactivateDeviceToken(ctx, tokens[i].DeviceToken)
 		}
 	}
 }
 
+// tokenRejected reports whether a send error means the token itself is dead.
+// FCM also answers INVALID_ARGUMENT for bad messages, such as an oversized
+// payload, so that only counts when the error names the registration token.
+func tokenRejected(err error) bool {
+	// A chunk that failed as a whole says nothing about its tokens
+	var chunkErr *chunkSendError
+	if err == nil || errors.As(err, &chunkErr) {
+		return false
+	}
+	if messaging.IsRegistrationTokenNotRegistered(err) {
+		return true
+	}
+	return messaging.IsInvalidArgument(err) && strings.Contains(strings.ToLower(err.Error()), "registration token")
+}
+
+// deactivateDeviceToken marks every registration of a device token inactive.
+// FCM may recycle a token across users, so all matching documents are updated.
 func (n *NotificationService) deactivateDeviceToken(ctx context.Context, deviceToken string) {
-	// Mark token as inactive
-	_, err := n.firebase.GetFirestoreClient().
+	docs, err := n.firebase.GetFirestoreClient().
 		Collection("user_device_tokens").
 		Where("device_token", "==", deviceToken).
 		Documents(ctx).
@@ -535,18 +1537,36 @@ func (n *NotificationService) deactivateDeviceToken(ctx context.Context, deviceT
 		log.Printf("Failed to query device token for deactivation: %v", err)
 		return
 	}
+
+	if len(docs) == 0 {
+		log.Printf("Device token to deactivate not found, skipping")
+		return
+	}
+
+	for _, doc := range docs {
+		_, err := doc.Ref.Update(ctx, []firestore.Update{
+			{Path: "active", Value: false},
+			{Path: "last_used", Value: time.Now()},
+		})
+		if err != nil {
+			log.Printf("Failed to deactivate device token %s: %v", doc.Ref.ID, err)
+			continue
+		}
+		log.Printf("Deactivated device token %s", doc.Ref.ID)
+	}
 }
 
 func (n *NotificationService) storeNotificationHistory(ctx context.Context, req *NotificationRequest, response *messaging.BatchResponse) {
-	history := map[string]interface{}{
-		"user_id":       req.UserID,
-		"trip_id":       req.TripID,
-		"type":          req.Type,
-		"title":         req.Title,
-		"body":          req.Body,
-		"sent_at":       time.Now(),
-		"success_count": response.SuccessCount,
-		"failure_count": response.FailureCount,
+	history := NotificationHistoryItem{
+		UserID:       req.UserID,
+		TripID:       req.TripID,
+		Type:         req.Type,
+		Title:        req.Title,
+		Body:         req.Body,
+		SentAt:       time.Now(),
+		SuccessCount: response.SuccessCount,
+		FailureCount: response.FailureCount,
+		Read:         false,
 	}
 
 	n.firebase.GetFirestoreClient().
@@ -554,9 +1574,75 @@ func (n *NotificationService) storeNotificationHistory(ctx context.Context, req
 		Add(ctx, history)
 }
 
-func (n *NotificationService) getTripUserIDs(ctx context.Context, tripID string) ([]string, error) {
-	// Mock implementation - get users associated with trip
-	return []string{"user_123"}, nil
+// countQuery runs a Firestore count aggregation over a query
+func countQuery(ctx context.Context, query firestore.Query) (int, error) {
+	result, err := query.NewAggregationQuery().WithCount("count").Get(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := result["count"].(*firestorepb.Value)
+	if !ok {
+		return 0, fmt.Errorf("unexpected count result type %T", result["count"])
+	}
+	return int(value.GetIntegerValue()), nil
+}
+
+// getTripUserIDs returns the owner and accepted collaborators of a trip.
+// Roles (editor, viewer) restrict which collaborators are included; the
+// owner always is.
+func (n *NotificationService) getTripUserIDs(ctx context.Context, tripID string, roles ...string) ([]string, error) {
+	if n.firebase == nil {
+		return nil, fmt.Errorf("firebase service not available")
+	}
+
+	allowed := func(role string) bool {
+		if len(roles) == 0 {
+			return true
+		}
+		for _, r := range roles {
+			if r == role {
+				return true
+			}
+		}
+		return false
+	}
+
+	client := n.firebase.GetFirestoreClient()
+	seen := make(map[string]bool)
+	var userIDs []string
+
+	// Trip owner
+	tripDoc, err := client.Collection("trips").Doc(tripID).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip: %w", err)
+	}
+	if ownerID, _ := tripDoc.Data()["user_id"].(string); ownerID != "" {
+		seen[ownerID] = true
+		userIDs = append(userIDs, ownerID)
+	}
+
+	// Collaborators who accepted their invitation
+	docs, err := client.Collection("trip_collaborators").
+		Where("trip_id", "==", tripID).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip collaborators: %w", err)
+	}
+
+	for _, doc := range docs {
+		data := doc.Data()
+		userID, _ := data["user_id"].(string)
+		role, _ := data["role"].(string)
+		if userID == "" || seen[userID] || data["accepted_at"] == nil || !allowed(role) {
+			continue
+		}
+		seen[userID] = true
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
 }
 
 // Priority and alert mapping methods
@@ -613,9 +1699,45 @@ func (n *NotificationService) getSoundForPriority(priority NotificationPriority)
 	}
 }
 
-func (n *NotificationService) getBadgeCount(userID string) *int {
-	// In production, calculate unread notification count
-	count := 1
+// getBadgeCount returns the user's unread notification count for the iOS
+// badge, capped at maxBadgeCount. nil leaves the badge unchanged, which is
+// used whenever the count can't be determined.
+func (n *NotificationService) getBadgeCount(ctx context.Context, userID string) *int {
+	if userID == "" || n.firebase == nil {
+		return nil
+	}
+
+	n.badgeMu.Lock()
+	if cached, ok := n.badgeCache[userID]; ok && time.Since(cached.loadedAt) < badgeCountTTL {
+		n.badgeMu.Unlock()
+		count := cached.count
+		return &count
+	}
+	n.badgeMu.Unlock()
+
+	count, err := countQuery(ctx, n.firebase.GetFirestoreClient().
+		Collection("notification_history").
+		Where("user_id", "==", userID).
+		Where("read", "==", false).
+		Limit(maxBadgeCount))
+	if err != nil {
+		log.Printf("Failed to count unread notifications for %s: %v", userID, err)
+		return nil
+	}
+
+	// The notification being sent is recorded in history after delivery, so
+	// include it in the badge
+	if count < maxBadgeCount {
+		count++
+	}
+
+	n.badgeMu.Lock()
+	if n.badgeCache == nil {
+		n.badgeCache = make(map[string]cachedBadgeCount)
+	}
+	n.badgeCache[userID] = cachedBadgeCount{count: count, loadedAt: time.Now()}
+	n.badgeMu.Unlock()
+
 	return &count
 }
 
@@ -657,40 +1779,70 @@ func (n *NotificationService) getTripReminderTitle(reminderType string, timeUnti
 	}
 }
 
-func (n *NotificationService) getTripReminderBody(reminderType string, timeUntil time.Duration) string {
-	timeStr := n.formatDuration(timeUntil)
-	switch reminderType {
-	case "departure":
-		return fmt.Sprintf("Your trip starts in %s. Have a great journey!", timeStr)
-	case "checkin":
-		return fmt.Sprintf("Don't forget to check in for your flight/hotel in %s", timeStr)
-	case "activity":
-		return fmt.Sprintf("Your next activity starts in %s", timeStr)
-	default:
-		return fmt.Sprintf("Trip reminder: %s", timeStr)
-	}
+// tripReminderBodies are reminder bodies by locale and reminder type, with
+// %s for the relative time; "" is the pattern for other reminder types
+var tripReminderBodies = map[string]map[string]string{
+	"en": {
+		"departure": "Your trip starts %s. Have a great journey!",
+		"checkin":   "Don't forget to check in for your flight/hotel %s",
+		"activity":  "Your next activity starts %s",
+		"":          "Trip reminder: %s",
+	},
+	"hi": {
+		"departure": "आपकी यात्रा %s शुरू होगी। आपकी यात्रा मंगलमय हो!",
+		"checkin":   "अपनी फ़्लाइट/होटल में %s चेक-इन करना न भूलें",
+		"activity":  "आपकी अगली गतिविधि %s शुरू होगी",
+		"":          "यात्रा अनुस्मारक: %s",
+	},
+	"bn": {
+		"departure": "আপনার ভ্রমণ %s শুরু হবে। শুভ যাত্রা!",
+		"checkin":   "%s আপনার ফ্লাইট/হোটেলে চেক-ইন করতে ভুলবেন না",
+		"activity":  "আপনার পরবর্তী কার্যকলাপ %s শুরু হবে",
+		"":          "ভ্রমণ অনুস্মারক: %s",
+	},
+	"ta": {
+		"departure": "உங்கள் பயணம் %s தொடங்கும். இனிய பயணம்!",
+		"checkin":   "%s உங்கள் விமானம்/ஹோட்டலில் செக்-இன் செய்ய மறக்காதீர்கள்",
+		"activity":  "உங்கள் அடுத்த செயல்பாடு %s தொடங்கும்",
+		"":          "பயண நினைவூட்டல்: %s",
+	},
+	"mr": {
+		"departure": "तुमचा प्रवास %s सुरू होईल. प्रवासासाठी शुभेच्छा!",
+		"checkin":   "%s तुमच्या फ्लाइट/हॉटेलसाठी चेक-इन करायला विसरू नका",
+		"activity":  "तुमचा पुढील उपक्रम %s सुरू होईल",
+		"":          "प्रवास स्मरणपत्र: %s",
+	},
 }
 
-func (n *NotificationService) formatDuration(d time.Duration) string {
-	hours := int(d.Hours())
-	if hours >= 24 {
-		days := hours / 24
-		if days == 1 {
-			return "1 day"
+// getTripReminderBody says how long until the trip in locale, falling back
+// to English for locales without reminder text
+func (n *NotificationService) getTripReminderBody(reminderType string, timeUntil time.Duration, locale string) string {
+	bodies, language := tripReminderBodies["en"], "en"
+	for _, candidate := range localeFallbackChain(locale) {
+		if localized, ok := tripReminderBodies[candidate]; ok {
+			bodies, language = localized, candidate
+			break
 		}
-		return fmt.Sprintf("%d days", days)
 	}
-	if hours >= 1 {
-		if hours == 1 {
-			return "1 hour"
-		}
-		return fmt.Sprintf("%d hours", hours)
+
+	pattern, ok := bodies[reminderType]
+	if !ok {
+		pattern = bodies[""]
+	}
+	return fmt.Sprintf(pattern, formatRelativeDuration(timeUntil, language))
+}
+
+// recipientLanguage is the language a user's notifications are sent in, the
+// same one localizeNotification picks; English when it can't be read
+func (n *NotificationService) recipientLanguage(ctx context.Context, userID string) string {
+	if n.firebase == nil {
+		return "en"
 	}
-	minutes := int(d.Minutes())
-	if minutes == 1 {
-		return "1 minute"
+	tokens, err := n.getUserDeviceTokens(ctx, userID)
+	if err != nil || len(tokens) == 0 || tokens[0].Language == "" {
+		return "en"
 	}
-	return fmt.Sprintf("%d minutes", minutes)
+	return tokens[0].Language
 }
 
 // Shutdown gracefully shuts down the notification service
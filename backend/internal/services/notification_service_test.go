@@ -0,0 +1,699 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	"firebase.google.com/go/v4/messaging"
+	"google.golang.org/api/option"
+)
+
+func TestGetTripUserIDs(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	accepted := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	seed(t, fb, "trips/t1", map[string]interface{}{"user_id": "owner"})
+	seed(t, fb, "trip_collaborators/t1_ed", map[string]interface{}{"trip_id": "t1", "user_id": "ed", "role": TripRoleEditor, "accepted_at": accepted})
+	seed(t, fb, "trip_collaborators/t1_vi", map[string]interface{}{"trip_id": "t1", "user_id": "vi", "role": TripRoleViewer, "accepted_at": accepted})
+	seed(t, fb, "trip_collaborators/t1_pending", map[string]interface{}{"trip_id": "t1", "user_id": "pending", "role": TripRoleEditor})
+	seed(t, fb, "trip_collaborators/t1_owner", map[string]interface{}{"trip_id": "t1", "user_id": "owner", "role": TripRoleEditor, "accepted_at": accepted})
+	seed(t, fb, "trip_collaborators/t2_other", map[string]interface{}{"trip_id": "t2", "user_id": "other", "role": TripRoleEditor, "accepted_at": accepted})
+	n := &NotificationService{firebase: fb}
+
+	tests := []struct {
+		name  string
+		roles []string
+		want  string
+	}{
+		{"everyone", nil, "ed,owner,vi"},
+		{"editors only", []string{TripRoleEditor}, "ed,owner"},
+		{"viewers only", []string{TripRoleViewer}, "owner,vi"},
+		{"owner always included", []string{"nobody"}, "owner"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := n.getTripUserIDs(context.Background(), "t1", tt.roles...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+			if joined := strings.Join(got, ","); joined != tt.want {
+				t.Errorf("getTripUserIDs = %s, want %s", joined, tt.want)
+			}
+		})
+	}
+
+	if _, err := n.getTripUserIDs(context.Background(), "missing"); err == nil {
+		t.Error("getTripUserIDs found users for a missing trip")
+	}
+}
+
+func TestTripTopicCondition(t *testing.T) {
+	tests := []struct {
+		roles []string
+		want  string
+	}{
+		{nil, "'trip_a_b_owner' in topics || 'trip_a_b_editor' in topics || 'trip_a_b_viewer' in topics"},
+		{[]string{TripRoleEditor}, "'trip_a_b_owner' in topics || 'trip_a_b_editor' in topics"},
+		{[]string{TripRoleOwner}, "'trip_a_b_owner' in topics"},
+	}
+	for _, tt := range tests {
+		if got := tripTopicCondition("a/b", tt.roles...); got != tt.want {
+			t.Errorf("tripTopicCondition(%v) = %s, want %s", tt.roles, got, tt.want)
+		}
+	}
+}
+
+// stubFCM answers multicasts without a network. Chunks listed in failChunks
+// fail as a whole with chunkErr; tokenErrs fail single tokens.
+type stubFCM struct {
+	fcmClient
+	chunkSizes []int
+	failChunks map[int]bool
+	chunkErr   error
+	tokenErrs  map[string]error
+}
+
+func (s *stubFCM) SendEachForMulticast(ctx context.Context, message *messaging.MulticastMessage) (*messaging.BatchResponse, error) {
+	chunk := len(s.chunkSizes)
+	s.chunkSizes = append(s.chunkSizes, len(message.Tokens))
+	if s.failChunks[chunk] {
+		return nil, s.chunkErr
+	}
+	response := &messaging.BatchResponse{}
+	for _, token := range message.Tokens {
+		if err := s.tokenErrs[token]; err != nil {
+			response.FailureCount++
+			response.Responses = append(response.Responses, &messaging.SendResponse{Error: err})
+			continue
+		}
+		response.SuccessCount++
+		response.Responses = append(response.Responses, &messaging.SendResponse{Success: true, MessageID: "m-" + token})
+	}
+	return response, nil
+}
+
+// fcmError returns the error a real FCM client reports for an error
+// response from the FCM API
+func fcmError(t *testing.T, status int, body string) error {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	app, err := firebase.NewApp(ctx, &firebase.Config{ProjectID: "test"}, option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := app.Messaging(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Send(ctx, &messaging.Message{Token: "token"})
+	if err == nil {
+		t.Fatal("FCM stub accepted the message")
+	}
+	return err
+}
+
+const (
+	fcmInvalidPayload = `{"error":{"code":400,"message":"Invalid value at 'message.data'","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"INVALID_ARGUMENT"}]}}`
+	fcmUnregistered   = `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`
+)
+
+func deviceTokens(n int) []UserDeviceToken {
+	tokens := make([]UserDeviceToken, n)
+	for i := range tokens {
+		tokens[i] = UserDeviceToken{UserID: "u1", DeviceToken: fmt.Sprintf("token-%d", i), DeviceType: fmt.Sprintf("device-%d", i), Active: true}
+	}
+	return tokens
+}
+
+func TestSendMulticastChunked(t *testing.T) {
+	tokens := deviceTokens(600)
+	unregistered := fcmError(t, http.StatusNotFound, fcmUnregistered)
+	req := &NotificationRequest{Title: "Gate change", Body: "Now boarding at 12"}
+
+	t.Run("two chunks", func(t *testing.T) {
+		fcm := &stubFCM{tokenErrs: map[string]error{"token-550": unregistered}}
+		n := &NotificationService{messagingClient: fcm, enabled: true}
+		response, err := n.sendMulticastChunked(context.Background(), req, tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(fcm.chunkSizes) != "[500 100]" {
+			t.Errorf("chunk sizes = %v, want [500 100]", fcm.chunkSizes)
+		}
+		if response.SuccessCount != 599 || response.FailureCount != 1 || len(response.Responses) != 600 {
+			t.Errorf("aggregate = %d sent, %d failed, %d responses; want 599, 1, 600", response.SuccessCount, response.FailureCount, len(response.Responses))
+		}
+		if response.Responses[550].Success || response.Responses[599].MessageID != "m-token-599" {
+			t.Error("responses are not aligned with their tokens")
+		}
+	})
+
+	t.Run("failed chunk", func(t *testing.T) {
+		fcm := &stubFCM{failChunks: map[int]bool{1: true}, chunkErr: fcmError(t, http.StatusBadRequest, fcmInvalidPayload)}
+		n := &NotificationService{messagingClient: fcm, enabled: true}
+		response, err := n.sendMulticastChunked(context.Background(), req, tokens)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.SuccessCount != 500 || response.FailureCount != 100 || len(response.Responses) != 600 {
+			t.Errorf("aggregate = %d sent, %d failed, %d responses; want 500, 100, 600", response.SuccessCount, response.FailureCount, len(response.Responses))
+		}
+
+		// The chunk's error doesn't make its tokens dead
+		fb, fake := newTestFirebase(t)
+		n.firebase = fb
+		for _, token := range tokens[500:503] {
+			seed(t, fb, "user_device_tokens/"+token.UserID+"_"+token.DeviceType, token)
+		}
+		n.handleFailedTokens(context.Background(), response, tokens)
+		for _, token := range tokens[500:503] {
+			if !fake.fields("user_device_tokens/" + token.UserID + "_" + token.DeviceType)["active"].GetBooleanValue() {
+				t.Errorf("%s was deactivated for its chunk's error", token.DeviceToken)
+			}
+		}
+	})
+
+	t.Run("every chunk failed", func(t *testing.T) {
+		fcm := &stubFCM{failChunks: map[int]bool{0: true, 1: true}, chunkErr: errors.New("connection reset")}
+		n := &NotificationService{messagingClient: fcm, enabled: true}
+		if _, err := n.sendMulticastChunked(context.Background(), req, tokens); err == nil {
+			t.Error("sendMulticastChunked succeeded with every chunk failing")
+		}
+	})
+}
+
+const fcmInvalidToken = `{"error":{"code":400,"message":"The registration token is not a valid FCM registration token","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"INVALID_ARGUMENT"}]}}`
+
+func TestTokenRejected(t *testing.T) {
+	invalidPayload := fcmError(t, http.StatusBadRequest, fcmInvalidPayload)
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unregistered", fcmError(t, http.StatusNotFound, fcmUnregistered), true},
+		{"invalid token", fcmError(t, http.StatusBadRequest, fcmInvalidToken), true},
+		{"invalid payload", invalidPayload, false},
+		{"whole chunk failed", &chunkSendError{err: fcmError(t, http.StatusNotFound, fcmUnregistered)}, false},
+		{"auth error", fcmError(t, http.StatusUnauthorized, `{"error":{"code":401,"message":"Request is missing required authentication credential","status":"UNAUTHENTICATED"}}`), false},
+		{"no error", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenRejected(tt.err); got != tt.want {
+				t.Errorf("tokenRejected(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleFailedTokens(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	tokens := deviceTokens(4)
+	for _, token := range tokens {
+		seed(t, fb, "user_device_tokens/"+token.UserID+"_"+token.DeviceType, token)
+	}
+	response := &messaging.BatchResponse{Responses: []*messaging.SendResponse{
+		{Success: true, MessageID: "m-token-0"},
+		{Error: fcmError(t, http.StatusNotFound, fcmUnregistered)},
+		{Error: fcmError(t, http.StatusBadRequest, fcmInvalidToken)},
+		{Error: fcmError(t, http.StatusBadRequest, fcmInvalidPayload)},
+	}}
+
+	n := &NotificationService{firebase: fb}
+	n.handleFailedTokens(context.Background(), response, tokens)
+
+	for i, wantActive := range []bool{true, false, false, true} {
+		doc := "user_device_tokens/" + tokens[i].UserID + "_" + tokens[i].DeviceType
+		if got := fake.fields(doc)["active"].GetBooleanValue(); got != wantActive {
+			t.Errorf("%s active = %v, want %v", tokens[i].DeviceToken, got, wantActive)
+		}
+	}
+}
+
+func TestRegisterDeviceTokenTimezone(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	n := &NotificationService{firebase: fb, enabled: true}
+	tests := []struct {
+		timezone string
+		want     string
+		wantErr  bool
+	}{
+		{"Asia/Kolkata", "Asia/Kolkata", false},
+		{"", "UTC", false},
+		{"Mars/Olympus_Mons", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			err := n.RegisterDeviceToken(context.Background(), "u1", "token", "web", tt.timezone)
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) {
+					t.Errorf("RegisterDeviceToken(%q) = %v, want a validation error", tt.timezone, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.fields("user_device_tokens/u1_web")["timezone"].GetStringValue(); got != tt.want {
+				t.Errorf("stored timezone = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuietWindowEnd(t *testing.T) {
+	location := func(name string) *time.Location {
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loc
+	}
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	night := [2]int{22 * 60, 8 * 60}
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		loc       string
+		window    [2]int
+		wantQuiet bool
+		want      time.Time
+	}{
+		// New York springs forward at 02:00 on March 8, so the night is an hour shorter
+		{"spring forward", utc(3, 8, 3, 0), "America/New_York", night, true, utc(3, 8, 12, 0)},
+		// and falls back at 02:00 on November 1, so it is an hour longer
+		{"fall back", utc(11, 1, 4, 0), "America/New_York", night, true, utc(11, 1, 13, 0)},
+		// 02:30 never happens that night; the window ends when 02:00 jumps to 03:00
+		{"window ends in the skipped hour", utc(3, 8, 5, 0), "America/New_York", [2]int{0, 2*60 + 30}, true, utc(3, 8, 7, 0)},
+		{"before midnight east of UTC", utc(6, 1, 14, 0), "Asia/Tokyo", night, true, utc(6, 1, 23, 0)},
+		{"after midnight on a half-hour offset", utc(6, 1, 2, 0), "Asia/Kolkata", night, true, utc(6, 1, 2, 30)},
+		{"UTC date differs from local date", utc(6, 2, 1, 0), "America/Los_Angeles", night, false, time.Time{}},
+		{"daytime window", utc(7, 1, 12, 30), "Europe/London", [2]int{13 * 60, 15 * 60}, true, utc(7, 1, 14, 0)},
+		{"daytime window passed", utc(7, 1, 14, 0), "Europe/London", [2]int{13 * 60, 15 * 60}, false, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, quiet := quietWindowEnd(tt.now, location(tt.loc), tt.window[0], tt.window[1])
+			if quiet != tt.wantQuiet || !got.Equal(tt.want) {
+				t.Errorf("quietWindowEnd = %s, %v; want %s, %v", got.UTC(), quiet, tt.want, tt.wantQuiet)
+			}
+		})
+	}
+}
+
+func TestQuietHoursDeferral(t *testing.T) {
+	// 23:00 in New York, 04:00 in London, 12:00 in Tokyo
+	now := time.Date(2026, 1, 15, 4, 0, 0, 0, time.UTC)
+	device := func(timezone string) UserDeviceToken {
+		return UserDeviceToken{UserID: "u1", Timezone: timezone}
+	}
+	n := &NotificationService{}
+
+	tests := []struct {
+		name      string
+		req       NotificationRequest
+		devices   []UserDeviceToken
+		wantDefer bool
+		want      time.Time
+	}{
+		{"asleep", NotificationRequest{}, []UserDeviceToken{device("America/New_York")}, true, time.Date(2026, 1, 15, 13, 0, 0, 0, time.UTC)},
+		{"earliest wake wins", NotificationRequest{}, []UserDeviceToken{device("America/New_York"), device("Europe/London")}, true, time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"one device awake", NotificationRequest{}, []UserDeviceToken{device("America/New_York"), device("Asia/Tokyo")}, false, time.Time{}},
+		{"no timezone is UTC", NotificationRequest{}, []UserDeviceToken{device("")}, true, time.Date(2026, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"critical", NotificationRequest{Priority: PriorityCritical}, []UserDeviceToken{device("America/New_York")}, false, time.Time{}},
+		{"emergency", NotificationRequest{Type: EmergencyAlert}, []UserDeviceToken{device("America/New_York")}, false, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			req.UserID = "u1"
+			got, deferred := n.quietHoursDeferral(context.Background(), &req, tt.devices, now)
+			if deferred != tt.wantDefer || !got.Equal(tt.want) {
+				t.Errorf("quietHoursDeferral = %s, %v; want %s, %v", got.UTC(), deferred, tt.want, tt.wantDefer)
+			}
+		})
+	}
+}
+
+func TestSuppressionReason(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	settings := func(userID string, stored map[string]interface{}) string {
+		seed(t, fb, "users/"+userID, map[string]interface{}{
+			"travel_preferences": map[string]interface{}{"notification_settings": stored},
+		})
+		return userID
+	}
+	pushOff := settings("push-off", map[string]interface{}{"push": false})
+	remindersOff := settings("reminders-off", map[string]interface{}{"trip_reminders": false})
+	alertsOff := settings("alerts-off", map[string]interface{}{"price_alerts": false})
+	recommendationsOff := settings("recommendations-off", map[string]interface{}{"recommendations": false})
+	n := &NotificationService{firebase: fb}
+
+	tests := []struct {
+		name string
+		req  NotificationRequest
+		want string
+	}{
+		{"no saved settings", NotificationRequest{UserID: "new-user", Type: TripReminder}, ""},
+		{"push off", NotificationRequest{UserID: pushOff, Type: GeneralUpdate}, "push notifications disabled"},
+		{"trip reminders off", NotificationRequest{UserID: remindersOff, Type: TripReminder}, "trip reminders disabled"},
+		{"other types still send", NotificationRequest{UserID: remindersOff, Type: PriceAlertType}, ""},
+		{"price alerts off", NotificationRequest{UserID: alertsOff, Type: PriceAlertType}, "price alerts disabled"},
+		{"recommendations off", NotificationRequest{UserID: recommendationsOff, Type: Recommendation}, "recommendations disabled"},
+		{"critical overrides", NotificationRequest{UserID: pushOff, Type: TripReminder, Priority: PriorityCritical}, ""},
+		{"emergency overrides", NotificationRequest{UserID: pushOff, Type: EmergencyAlert}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := n.suppressionReason(context.Background(), &tt.req); got != tt.want {
+				t.Errorf("suppressionReason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendNotificationResult(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	// Quiet hours are off for everyone so the test doesn't depend on the clock
+	awake := map[string]interface{}{"quiet_hours_start": "00:00", "quiet_hours_end": "00:00"}
+	user := func(userID string, settings map[string]interface{}, devices ...string) {
+		seed(t, fb, "users/"+userID, map[string]interface{}{
+			"travel_preferences": map[string]interface{}{"notification_settings": settings},
+		})
+		for _, device := range devices {
+			seed(t, fb, "user_device_tokens/"+userID+"_"+device, UserDeviceToken{UserID: userID, DeviceToken: userID + "-" + device, DeviceType: device, Active: true})
+		}
+	}
+	user("sent", awake, "ios", "web")
+	user("partial", awake, "ios", "web")
+	user("failed", awake, "android")
+	user("no-devices", awake)
+	user("muted", map[string]interface{}{"push": false}, "ios")
+	unregistered := fcmError(t, http.StatusNotFound, fcmUnregistered)
+	fcm := &stubFCM{tokenErrs: map[string]error{"partial-web": unregistered, "failed-android": unregistered}}
+
+	tests := []struct {
+		name        string
+		userID      string
+		enabled     bool
+		wantStatus  string
+		wantReason  string
+		wantDevices string
+	}{
+		{"sent", "sent", true, "sent", "", "ios:true,web:true"},
+		{"partial", "partial", true, "partial", "", "ios:true,web:false"},
+		{"failed", "failed", true, "failed", "", "android:false"},
+		{"no devices", "no-devices", true, "skipped", "no active devices", ""},
+		{"suppressed", "muted", true, "suppressed", "push notifications disabled", ""},
+		{"service disabled", "sent", false, "skipped", "notification service disabled", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &NotificationService{firebase: fb, messagingClient: fcm, enabled: tt.enabled}
+			result, err := n.SendNotification(context.Background(), &NotificationRequest{UserID: tt.userID, Type: GeneralUpdate, Title: "Gate change"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var devices []string
+			for _, device := range result.Devices {
+				devices = append(devices, fmt.Sprintf("%s:%v", device.DeviceType, device.Success))
+			}
+			sort.Strings(devices)
+			if result.UserID != tt.userID || result.Status != tt.wantStatus || result.SuppressionReason != tt.wantReason || strings.Join(devices, ",") != tt.wantDevices {
+				t.Errorf("SendNotification = %s %q %q %v; want %s %q %q %s", result.UserID, result.Status, result.SuppressionReason, devices, tt.userID, tt.wantStatus, tt.wantReason, tt.wantDevices)
+			}
+			if result.SuccessCount+result.FailureCount != len(result.Devices) {
+				t.Errorf("counts %d + %d don't match %d devices", result.SuccessCount, result.FailureCount, len(result.Devices))
+			}
+		})
+	}
+
+	t.Run("whole trip", func(t *testing.T) {
+		seed(t, fb, "trips/t1", map[string]interface{}{"user_id": "sent"})
+		seed(t, fb, "trip_collaborators/t1_muted", map[string]interface{}{"trip_id": "t1", "user_id": "muted", "role": TripRoleEditor, "accepted_at": time.Now()})
+		n := &NotificationService{firebase: fb, messagingClient: &stubFCM{}, enabled: true}
+		results, err := n.SendToTrip(context.Background(), &NotificationRequest{TripID: "t1", Type: ItineraryUpdate, Title: "Day 2 changed"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses := map[string]string{}
+		for _, result := range results {
+			statuses[result.UserID] = result.Status
+		}
+		if len(results) != 2 || statuses["sent"] != "sent" || statuses["muted"] != "suppressed" {
+			t.Errorf("SendToTrip statuses = %v, want sent for the owner and suppressed for the muted editor", statuses)
+		}
+	})
+}
+
+func TestLocaleFallbackChain(t *testing.T) {
+	tests := []struct {
+		language string
+		want     string
+	}{
+		{"hi", "hi"},
+		{"hi-IN", "hi-IN,hi"},
+		{"zh_Hant_TW", "zh-Hant-TW,zh-Hant,zh"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(localeFallbackChain(tt.language), ","); got != tt.want {
+			t.Errorf("localeFallbackChain(%q) = %s, want %s", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizeNotification(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	seed(t, fb, "notification_templates/booking_confirmation_es", NotificationTemplate{
+		Type: BookingConfirm, Language: "es", TitleTmpl: "Reserva confirmada", BodyTmpl: "Tu reserva en {{place}} para {{guests}} está confirmada",
+		Variables: map[string]string{"guests": "ti"},
+	})
+	seed(t, fb, "notification_templates/general_update_es", NotificationTemplate{
+		Type: GeneralUpdate, Language: "es", TitleTmpl: "Novedades", BodyTmpl: "{{missing}}",
+	})
+	n := &NotificationService{firebase: fb}
+	if err := n.ReloadTemplates(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	booking := NotificationRequest{Type: BookingConfirm, Title: "Booking confirmed", Body: "Your booking is confirmed", Data: map[string]string{"place": "Casa Azul"}}
+	weather := NotificationRequest{Type: WeatherAlertType, Title: "Weather alert", Body: "Heavy rain"}
+	tests := []struct {
+		name      string
+		req       NotificationRequest
+		language  string
+		wantTitle string
+		wantBody  string
+		wantLang  string
+	}{
+		{"english is untouched", booking, "en", "Booking confirmed", "Your booking is confirmed", ""},
+		{"loaded from Firestore", booking, "es", "Reserva confirmada", "Tu reserva en Casa Azul para ti está confirmada", "es"},
+		{"regional locale falls back", booking, "es-MX", "Reserva confirmada", "Tu reserva en Casa Azul para ti está confirmada", "es"},
+		{"built-in default", weather, "hi-IN", "मौसम चेतावनी", "आपकी यात्रा के लिए मौसम की चेतावनी: Heavy rain", "hi"},
+		{"no template", weather, "fr", "Weather alert", "Heavy rain", ""},
+		{"unresolved variable", NotificationRequest{Type: GeneralUpdate, Title: "News", Body: "Something changed"}, "es", "News", "Something changed", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := n.localizeNotification(&tt.req, tt.language)
+			if got.Title != tt.wantTitle || got.Body != tt.wantBody || got.Language != tt.wantLang {
+				t.Errorf("localizeNotification = %q / %q (%s), want %q / %q (%s)", got.Title, got.Body, got.Language, tt.wantTitle, tt.wantBody, tt.wantLang)
+			}
+		})
+	}
+}
+
+func TestProcessScheduledNotifications(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	awake := map[string]interface{}{"quiet_hours_start": "00:00", "quiet_hours_end": "00:00"}
+	for _, userID := range []string{"reachable", "unreachable"} {
+		seed(t, fb, "users/"+userID, map[string]interface{}{
+			"travel_preferences": map[string]interface{}{"notification_settings": awake},
+		})
+		seed(t, fb, "user_device_tokens/"+userID+"_ios", UserDeviceToken{UserID: userID, DeviceToken: userID + "-ios", DeviceType: "ios", Active: true})
+	}
+	fcm := &stubFCM{tokenErrs: map[string]error{"unreachable-ios": errors.New("service unavailable")}}
+	n := &NotificationService{firebase: fb, messagingClient: fcm, enabled: true}
+
+	now := time.Now()
+	scheduled := func(userID string, at time.Time, attempts int, leaseUntil time.Time) ScheduledNotification {
+		return ScheduledNotification{
+			Request:      NotificationRequest{UserID: userID, Type: TripReminder, Title: "Trip tomorrow"},
+			ScheduleTime: at,
+			Attempts:     attempts,
+			LeaseUntil:   leaseUntil,
+		}
+	}
+	tests := []struct {
+		id           string
+		notification ScheduledNotification
+		wantPending  bool
+		wantDead     bool
+		wantAttempts int64
+		wantBackoff  time.Duration
+	}{
+		{"sent", scheduled("reachable", now.Add(-time.Minute), 0, time.Time{}), false, false, 0, 0},
+		{"not due", scheduled("reachable", now.Add(time.Hour), 0, time.Time{}), true, false, 0, 0},
+		{"leased elsewhere", scheduled("reachable", now.Add(-time.Minute), 1, now.Add(time.Minute)), true, false, 1, 0},
+		{"first failure", scheduled("unreachable", now.Add(-time.Minute), 0, time.Time{}), true, false, 1, scheduledNotificationBaseBackoff},
+		{"third failure", scheduled("unreachable", now.Add(-time.Minute), 2, time.Time{}), true, false, 3, 4 * scheduledNotificationBaseBackoff},
+		{"out of attempts", scheduled("unreachable", now.Add(-time.Minute), maxScheduledNotificationAttempts-1, time.Time{}), false, true, 0, 0},
+	}
+	for _, tt := range tests {
+		seed(t, fb, "scheduled_notifications/"+tt.id, tt.notification)
+	}
+
+	if err := n.ProcessScheduledNotifications(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			pending := fake.fields("scheduled_notifications/" + tt.id)
+			dead := fake.fields("scheduled_notifications_dead_letter/" + tt.id)
+			if (pending != nil) != tt.wantPending || (dead != nil) != tt.wantDead {
+				t.Fatalf("pending = %v, dead-lettered = %v; want %v, %v", pending != nil, dead != nil, tt.wantPending, tt.wantDead)
+			}
+			if dead != nil && dead["last_error"].GetStringValue() == "" {
+				t.Error("dead-lettered notification has no last_error")
+			}
+			if pending == nil {
+				return
+			}
+			if got := pending["attempts"].GetIntegerValue(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			if tt.wantBackoff == 0 {
+				return
+			}
+			retryAt := pending["schedule_time"].GetTimestampValue().AsTime()
+			if retryAt.Before(now.Add(tt.wantBackoff)) || retryAt.After(time.Now().Add(tt.wantBackoff)) {
+				t.Errorf("retry at %v, want %v after now", retryAt, tt.wantBackoff)
+			}
+			if !pending["lease_until"].GetTimestampValue().AsTime().Before(now) || pending["last_error"].GetStringValue() == "" {
+				t.Error("failed notification kept its lease or lost its error")
+			}
+		})
+	}
+}
+
+func TestGetNotificationHistory(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	sentAt := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		seed(t, fb, fmt.Sprintf("notification_history/n%d", i), NotificationHistoryItem{UserID: "u1", Title: fmt.Sprintf("n%d", i), SentAt: sentAt.Add(time.Duration(i) * time.Hour)})
+	}
+	seed(t, fb, "notification_history/other", NotificationHistoryItem{UserID: "u2", Title: "other", SentAt: sentAt})
+	n := &NotificationService{firebase: fb}
+
+	tests := []struct {
+		name   string
+		limit  int
+		offset int
+		want   string
+	}{
+		{"newest first", 2, 0, "n4,n3"},
+		{"second page", 2, 2, "n2,n1"},
+		{"last page is short", 2, 4, "n0"},
+		{"past the end", 2, 6, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, total, err := n.GetNotificationHistory(context.Background(), "u1", tt.limit, tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, len(items))
+			for i, item := range items {
+				ids[i] = item.ID
+			}
+			if got := strings.Join(ids, ","); got != tt.want || total != 5 {
+				t.Errorf("GetNotificationHistory = %s of %d, want %s of 5", got, total, tt.want)
+			}
+		})
+	}
+
+	markTests := []struct {
+		name    string
+		userID  string
+		id      string
+		wantErr bool
+	}{
+		{"own notification", "u1", "n1", false},
+		{"someone else's", "u1", "other", true},
+		{"missing", "u1", "nope", true},
+	}
+	for _, tt := range markTests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := n.MarkRead(context.Background(), tt.userID, tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MarkRead = %v, want error %v", err, tt.wantErr)
+			}
+			if read := fake.fields("notification_history/" + tt.id)["read"].GetBooleanValue(); read == tt.wantErr {
+				t.Errorf("read = %v after MarkRead", read)
+			}
+		})
+	}
+}
+
+func TestGetBadgeCount(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	history := func(userID string, unread, read int) {
+		for i := 0; i < unread+read; i++ {
+			seed(t, fb, fmt.Sprintf("notification_history/%s_%d", userID, i), NotificationHistoryItem{UserID: userID, SentAt: time.Now(), Read: i >= unread})
+		}
+	}
+	history("some", 3, 2)
+	history("all-read", 0, 4)
+	history("many", maxBadgeCount+5, 0)
+	n := &NotificationService{firebase: fb}
+
+	tests := []struct {
+		name   string
+		userID string
+		want   int
+	}{
+		{"unread plus the one being sent", "some", 4},
+		{"nothing unread", "all-read", 1},
+		{"capped", "many", maxBadgeCount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := n.getBadgeCount(context.Background(), tt.userID)
+			if got == nil || *got != tt.want {
+				t.Errorf("getBadgeCount = %v, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if got := n.getBadgeCount(context.Background(), ""); got != nil {
+		t.Errorf("getBadgeCount without a user = %d, want nil", *got)
+	}
+
+	// Reading a notification drops the cached count
+	if err := n.MarkRead(context.Background(), "some", "some_0"); err != nil {
+		t.Fatal(err)
+	}
+	if got := n.getBadgeCount(context.Background(), "some"); got == nil || *got != 3 {
+		t.Errorf("getBadgeCount after MarkRead = %v, want 3", got)
+	}
+}
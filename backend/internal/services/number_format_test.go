@@ -0,0 +1,60 @@
+package services
+
+import "testing"
+
+func TestFormatNumberDecimalPlaces(t *testing.T) {
+	l := NewLocalizationService(nil, nil)
+	tests := []struct {
+		number   float64
+		format   string
+		decimals int
+		want     string
+	}{
+		{1234567.891, "1,23,456", 0, "12,34,568"},
+		{1234567.891, "1,23,456", 2, "12,34,567.89"},
+		{1234567.891, "1,23,456", 3, "12,34,567.891"},
+		{1234567.891, "123,456", 0, "1,234,568"},
+		{1234567.891, "123,456", 2, "1,234,567.89"},
+		{1234567.891, "123,456", 3, "1,234,567.891"},
+		{-1234.5, "123,456", 2, "-1,234.50"},
+		{-0.001, "123,456", 2, "0.00"},
+		// Whole numbers never gain decimals
+		{1500, "1,23,456", 2, "1,500"},
+		{1500, "123,456", 3, "1,500"},
+	}
+	for _, tt := range tests {
+		if got := l.formatNumber(tt.number, tt.format, tt.decimals); got != tt.want {
+			t.Errorf("formatNumber(%v, %q, %d) = %q, want %q", tt.number, tt.format, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatCurrencyDecimalPlacesAcrossLocales(t *testing.T) {
+	l := NewLocalizationService(nil, nil)
+	// Whole rupees in Hindi, three-decimal dinars in Tamil, and yen in
+	// Bengali, which has no minor units
+	l.supportedLocales["hi"].CurrencyDecimalPlaces = map[string]int{"INR": 0}
+	l.supportedLocales["ta"].Currency, l.supportedLocales["ta"].CurrencySymbol = "KWD", "KD"
+	l.supportedLocales["bn"].Currency, l.supportedLocales["bn"].CurrencySymbol = "JPY", "¥"
+
+	tests := []struct {
+		locale string
+		amount float64
+		want   string
+	}{
+		{"en", 123456.789, "₹ 1,23,456.79"},
+		{"hi", 123456.789, "₹ 1,23,457"},
+		{"ta", 1234.5678, "KD 1,234.568"},
+		{"bn", 123456.789, "¥ 1,23,457"},
+		{"en", 2000, "₹ 2,000"},
+	}
+	for _, tt := range tests {
+		got, err := l.FormatCurrency(tt.amount, tt.locale)
+		if err != nil {
+			t.Fatalf("FormatCurrency(%v, %s): %v", tt.amount, tt.locale, err)
+		}
+		if got != tt.want {
+			t.Errorf("FormatCurrency(%v, %s) = %q, want %q", tt.amount, tt.locale, got, tt.want)
+		}
+	}
+}
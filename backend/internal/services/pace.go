@@ -0,0 +1,66 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Trip paces, read from the "pace" preference
+const (
+	PaceRelaxed  = "relaxed"
+	PaceModerate = "moderate"
+	PacePacked   = "packed"
+)
+
+// paceStop is when one of a day's attractions is visited
+type paceStop struct {
+	slot  string
+	start string
+}
+
+// paceStops lays out each pace's attractions across the day. Every pace
+// leaves 12:30-14:00 free for lunch and finishes sightseeing by 17:30, so
+// even a packed day keeps its meals and ends in daylight.
+var paceStops = map[string][]paceStop{
+	PaceRelaxed:  {{slot: "morning", start: "10:00"}},
+	PaceModerate: {{slot: "morning", start: "09:30"}, {slot: "afternoon", start: "14:30"}},
+	PacePacked: {
+		{slot: "morning", start: "09:00"},
+		{slot: "morning", start: "11:00"},
+		{slot: "afternoon", start: "14:00"},
+		{slot: "afternoon", start: "16:00"},
+	},
+}
+
+// tripPace returns the pace preference, or moderate when it's missing or
+// not one of the paces
+func tripPace(preferences map[string]interface{}) string {
+	pace, _ := preferences["pace"].(string)
+	pace = strings.ToLower(strings.TrimSpace(pace))
+	if _, ok := paceStops[pace]; ok {
+		return pace
+	}
+	return PaceModerate
+}
+
+// ActivitiesPerDay returns how many attractions a day holds at a pace
+func ActivitiesPerDay(pace string) int {
+	if stops, ok := paceStops[pace]; ok {
+		return len(stops)
+	}
+	return len(paceStops[PaceModerate])
+}
+
+// buildPacePromptSection tells the model how full to make each day
+func buildPacePromptSection(preferences map[string]interface{}) string {
+	pace := tripPace(preferences)
+	perDay := ActivitiesPerDay(pace)
+	plural := "s"
+	if perDay == 1 {
+		plural = ""
+	}
+	return fmt.Sprintf(`
+Pace: %s. Plan %d attraction%s per day. Leave time for breakfast, lunch around 12:30-14:00 and
+dinner, and finish sightseeing by 17:30 so no visit runs past daylight.
+`, pace, perDay, plural)
+}
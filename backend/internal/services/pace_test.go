@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTripPace(t *testing.T) {
+	tests := []struct {
+		preferences map[string]interface{}
+		want        string
+		wantPerDay  int
+	}{
+		{map[string]interface{}{"pace": "relaxed"}, PaceRelaxed, 1},
+		{map[string]interface{}{"pace": " Packed "}, PacePacked, 4},
+		{map[string]interface{}{"pace": "moderate"}, PaceModerate, 2},
+		{map[string]interface{}{"pace": "frantic"}, PaceModerate, 2},
+		{map[string]interface{}{"pace": 3}, PaceModerate, 2},
+		{nil, PaceModerate, 2},
+	}
+	for _, tt := range tests {
+		pace := tripPace(tt.preferences)
+		if pace != tt.want || ActivitiesPerDay(pace) != tt.wantPerDay {
+			t.Errorf("tripPace(%v) = %s with %d per day, want %s with %d", tt.preferences, pace, ActivitiesPerDay(pace), tt.want, tt.wantPerDay)
+		}
+		if section := buildPacePromptSection(tt.preferences); !strings.Contains(section, fmt.Sprintf("Pace: %s. Plan %d attraction", tt.want, tt.wantPerDay)) {
+			t.Errorf("prompt section for %v = %q", tt.preferences, section)
+		}
+	}
+}
+
+func TestDayPlanPace(t *testing.T) {
+	var attractions []Attraction
+	for i := 1; i <= 8; i++ {
+		attractions = append(attractions, Attraction{Name: fmt.Sprintf("Museum %d", i), Type: "museum", Description: "exhibits"})
+	}
+	ragContext := TripContext{Attractions: attractions}
+
+	tests := []struct {
+		pace          string
+		day           int
+		wantMorning   string
+		wantAfternoon string
+		wantLunch     bool
+	}{
+		{PaceRelaxed, 2, "Visit Museum 2 - exhibits", "", false},
+		{PaceModerate, 1, "Visit Museum 1 - exhibits", "Explore Museum 2 - exhibits", false},
+		{PacePacked, 2, "[09:00 Visit Museum 5 - exhibits 11:00 Visit Museum 6 - exhibits]", "[14:00 Explore Museum 7 - exhibits 16:00 Explore Museum 8 - exhibits]", true},
+		{PacePacked, 3, "", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s day %d", tt.pace, tt.day), func(t *testing.T) {
+			plan := (&GeminiService{}).buildDayPlan(tt.day, ragContext, map[string]interface{}{"pace": tt.pace})
+			slot := func(name string) string {
+				if value, ok := plan[name]; ok {
+					return fmt.Sprint(value)
+				}
+				return ""
+			}
+			if plan["pace"] != tt.pace || slot("morning") != tt.wantMorning || slot("afternoon") != tt.wantAfternoon {
+				t.Errorf("%s plan: morning %q, afternoon %q\nwant morning %q, afternoon %q", plan["pace"], slot("morning"), slot("afternoon"), tt.wantMorning, tt.wantAfternoon)
+			}
+			if _, ok := plan["lunch"]; ok != tt.wantLunch {
+				t.Errorf("lunch break scheduled: %v, want %v", ok, tt.wantLunch)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"auratravel-backend/internal/logging"
+)
+
+// Packing list categories
+const (
+	PackingClothing   = "clothing"
+	PackingGear       = "gear"
+	PackingDocuments  = "documents"
+	PackingToiletries = "toiletries"
+)
+
+const (
+	// hotWeatherThreshold and coldWeatherThreshold are in Celsius
+	hotWeatherThreshold  = 28.0
+	coldWeatherThreshold = 12.0
+	// windyThreshold is in km/h
+	windyThreshold = 30.0
+	// maxDailyClothingSets caps per-person clothing; longer trips do laundry
+	maxDailyClothingSets = 7
+)
+
+// PackingItem is one entry of a packing checklist. Quantity is the total for
+// the whole group.
+type PackingItem struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	Quantity int    `json:"quantity"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// GeneratePackingList builds a packing checklist from the forecast and the
+// planned activities. Quantities scale with the trip length (one forecast
+// entry per day) and the number of travelers. Gemini suggestions are merged
+// into the rule-based list when an API key is configured.
+func (g *GeminiService) GeneratePackingList(ctx context.Context, forecast WeatherForecast, activities []string, travelers int) ([]PackingItem, error) {
+	if travelers < 1 {
+		travelers = 1
+	}
+
+	items := buildPackingList(forecast, activities, travelers)
+	if g.apiKey == "" {
+		return items, nil
+	}
+
+	response, err := g.callGeminiAPI(ctx, buildPackingPrompt(forecast, activities, travelers), GeminiCallOptions{UseCase: GeminiStructured})
+	if err != nil {
+		logging.FromContext(ctx).Warn("Gemini API call failed, using rule-based packing list", "error", err)
+		return items, nil
+	}
+
+	var suggested []PackingItem
+	if err := json.Unmarshal([]byte(extractJSON(response)), &suggested); err != nil {
+		logging.FromContext(ctx).Warn("Failed to parse packing list response, using rule-based list", "error", err)
+		return items, nil
+	}
+
+	return mergePackingItems(items, suggested), nil
+}
+
+// buildPackingList is the rule-based checklist, also used as the mock
+func buildPackingList(forecast WeatherForecast, activities []string, travelers int) []PackingItem {
+	days := len(forecast.Forecast)
+	if days < 1 {
+		days = 1
+	}
+	sets := days
+	if sets > maxDailyClothingSets {
+		sets = maxDailyClothingSets
+	}
+
+	// perPerson scales a per-traveler count to the group
+	perPerson := func(n int) int { return n * travelers }
+	// shared items are split between travelers, one per two people
+	shared := int(math.Ceil(float64(travelers) / 2))
+
+	items := []PackingItem{
+		{Name: "Underwear", Category: PackingClothing, Quantity: perPerson(sets + 1)},
+		{Name: "Socks", Category: PackingClothing, Quantity: perPerson(sets + 1)},
+		{Name: "Tops", Category: PackingClothing, Quantity: perPerson(sets)},
+		{Name: "Trousers or skirts", Category: PackingClothing, Quantity: perPerson(int(math.Ceil(float64(sets) / 2)))},
+		{Name: "Sleepwear", Category: PackingClothing, Quantity: perPerson(1)},
+		{Name: "Comfortable walking shoes", Category: PackingClothing, Quantity: perPerson(1)},
+		{Name: "Passport or government ID", Category: PackingDocuments, Quantity: perPerson(1)},
+		{Name: "Booking confirmations", Category: PackingDocuments, Quantity: 1},
+		{Name: "Travel insurance details", Category: PackingDocuments, Quantity: 1},
+		{Name: "Toothbrush", Category: PackingToiletries, Quantity: perPerson(1)},
+		{Name: "Toothpaste", Category: PackingToiletries, Quantity: shared},
+		{Name: "Personal medication", Category: PackingToiletries, Quantity: perPerson(1)},
+		{Name: "Phone charger", Category: PackingGear, Quantity: perPerson(1)},
+		{Name: "Reusable water bottle", Category: PackingGear, Quantity: perPerson(1)},
+	}
+
+	conditions := forecast.Forecast
+	if len(conditions) == 0 {
+		conditions = []WeatherCondition{forecast.Current}
+	}
+
+	maxTemp, minTemp := math.Inf(-1), math.Inf(1)
+	var rainyDays, snowyDays, humidDays, windyDays int
+	for _, day := range conditions {
+		maxTemp = math.Max(maxTemp, day.Temperature)
+		minTemp = math.Min(minTemp, day.Temperature)
+		description := strings.ToLower(day.Description)
+		if strings.Contains(description, "rain") || strings.Contains(description, "shower") ||
+			strings.Contains(description, "storm") || strings.Contains(description, "drizzle") {
+			rainyDays++
+		}
+		if strings.Contains(description, "snow") || strings.Contains(description, "sleet") {
+			snowyDays++
+		}
+		if day.WindSpeed >= windyThreshold {
+			windyDays++
+		}
+		if day.Humidity > 80 {
+			humidDays++
+		}
+	}
+
+	if maxTemp >= hotWeatherThreshold {
+		reason := fmt.Sprintf("Highs up to %.0f°C", maxTemp)
+		items = append(items,
+			PackingItem{Name: "Sunscreen", Category: PackingToiletries, Quantity: shared, Reason: reason},
+			PackingItem{Name: "Sun hat", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Sunglasses", Category: PackingGear, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Breathable shorts", Category: PackingClothing, Quantity: perPerson(int(math.Ceil(float64(sets) / 2))), Reason: reason},
+		)
+	}
+	if minTemp <= coldWeatherThreshold {
+		reason := fmt.Sprintf("Lows down to %.0f°C", minTemp)
+		items = append(items,
+			PackingItem{Name: "Warm jacket", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Thermal base layers", Category: PackingClothing, Quantity: perPerson(2), Reason: reason},
+			PackingItem{Name: "Sweater or fleece", Category: PackingClothing, Quantity: perPerson(2), Reason: reason},
+		)
+		if minTemp <= 0 {
+			items = append(items,
+				PackingItem{Name: "Gloves", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+				PackingItem{Name: "Beanie", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			)
+		}
+	}
+	if rainyDays > 0 {
+		reason := fmt.Sprintf("Rain expected on %d day(s)", rainyDays)
+		items = append(items,
+			PackingItem{Name: "Rain jacket", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Compact umbrella", Category: PackingGear, Quantity: shared, Reason: reason},
+			PackingItem{Name: "Waterproof phone pouch", Category: PackingGear, Quantity: perPerson(1), Reason: reason},
+		)
+	}
+	if snowyDays > 0 {
+		reason := fmt.Sprintf("Snow expected on %d day(s)", snowyDays)
+		items = append(items,
+			PackingItem{Name: "Waterproof boots", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Gloves", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+		)
+	}
+	if windyDays > 0 {
+		items = append(items, PackingItem{Name: "Windbreaker", Category: PackingClothing, Quantity: perPerson(1), Reason: "Windy conditions"})
+	}
+	if humidDays > 0 {
+		items = append(items, PackingItem{Name: "Insect repellent", Category: PackingToiletries, Quantity: shared, Reason: "Humid conditions"})
+	}
+
+	for _, activity := range activities {
+		items = append(items, activityPackingItems(activity, perPerson, shared)...)
+	}
+
+	return mergePackingItems(nil, items)
+}
+
+// activityPackingItems maps a planned activity to the items it needs
+func activityPackingItems(activity string, perPerson func(int) int, shared int) []PackingItem {
+	lower := strings.ToLower(activity)
+	has := func(keywords ...string) bool {
+		for _, keyword := range keywords {
+			if strings.Contains(lower, keyword) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var items []PackingItem
+	reason := "For " + activity
+	if has("beach", "swim", "snorkel", "pool", "water park") {
+		items = append(items,
+			PackingItem{Name: "Swimwear", Category: PackingClothing, Quantity: perPerson(2), Reason: reason},
+			PackingItem{Name: "Quick-dry towel", Category: PackingGear, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Flip-flops", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Sunscreen", Category: PackingToiletries, Quantity: shared, Reason: reason},
+		)
+	}
+	if has("hik", "trek", "mountain", "camp") {
+		items = append(items,
+			PackingItem{Name: "Hiking boots", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Daypack", Category: PackingGear, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "First aid kit", Category: PackingGear, Quantity: 1, Reason: reason},
+			PackingItem{Name: "Headlamp", Category: PackingGear, Quantity: perPerson(1), Reason: reason},
+		)
+	}
+	if has("ski", "snow") {
+		items = append(items,
+			PackingItem{Name: "Waterproof gloves", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Thermal base layers", Category: PackingClothing, Quantity: perPerson(2), Reason: reason},
+			PackingItem{Name: "Goggles", Category: PackingGear, Quantity: perPerson(1), Reason: reason},
+		)
+	}
+	if has("temple", "mosque", "church", "gurudwara", "religious", "spiritual") {
+		items = append(items,
+			PackingItem{Name: "Modest clothing covering shoulders and knees", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+			PackingItem{Name: "Scarf or shawl", Category: PackingClothing, Quantity: perPerson(1), Reason: reason},
+		)
+	}
+	if has("business", "formal", "wedding", "fine dining") {
+		items = append(items, PackingItem{Name: "Formal outfit", Category: PackingClothing, Quantity: perPerson(1), Reason: reason})
+	}
+	if has("photo", "wildlife", "safari") {
+		items = append(items,
+			PackingItem{Name: "Camera and spare batteries", Category: PackingGear, Quantity: 1, Reason: reason},
+			PackingItem{Name: "Power bank", Category: PackingGear, Quantity: shared, Reason: reason},
+		)
+	}
+	return items
+}
+
+// mergePackingItems deduplicates items by name (case-insensitive), keeping
+// the larger quantity and the first reason, and sorts by category then name
+func mergePackingItems(base, extra []PackingItem) []PackingItem {
+	byName := make(map[string]*PackingItem)
+	var order []string
+
+	for _, item := range append(append([]PackingItem{}, base...), extra...) {
+		name := strings.TrimSpace(item.Name)
+		if name == "" {
+			continue
+		}
+		key := strings.ToLower(name)
+		if existing, ok := byName[key]; ok {
+			if item.Quantity > existing.Quantity {
+				existing.Quantity = item.Quantity
+			}
+			if existing.Reason == "" {
+				existing.Reason = item.Reason
+			}
+			continue
+		}
+
+		item.Name = name
+		if item.Quantity < 1 {
+			item.Quantity = 1
+		}
+		switch item.Category {
+		case PackingClothing, PackingGear, PackingDocuments, PackingToiletries:
+		default:
+			item.Category = PackingGear
+		}
+		byName[key] = &item
+		order = append(order, key)
+	}
+
+	items := make([]PackingItem, 0, len(order))
+	for _, key := range order {
+		items = append(items, *byName[key])
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Category != items[j].Category {
+			return items[i].Category < items[j].Category
+		}
+		return items[i].Name < items[j].Name
+	})
+	return items
+}
+
+func buildPackingPrompt(forecast WeatherForecast, activities []string, travelers int) string {
+	var weather strings.Builder
+	for _, day := range forecast.Forecast {
+		weather.WriteString(fmt.Sprintf("- %s: %.0f°C, %s, humidity %d%%\n",
+			day.Date.Format("2006-01-02"), day.Temperature, day.Description, day.Humidity))
+	}
+
+	return fmt.Sprintf(`Create a packing list for %d traveler(s) on a %d-day trip.
+
+Forecast:
+%s
+Planned activities: %s
+
+Respond with only a JSON array of objects with fields "name", "category"
+(one of clothing, gear, documents, toiletries), "quantity" (total for the
+group) and "reason".`, travelers, len(forecast.Forecast), weather.String(), strings.Join(activities, ", "))
+}
+
+// extractJSON strips markdown code fences Gemini sometimes wraps JSON in
+func extractJSON(response string) string {
+	response = strings.TrimSpace(response)
+	response = strings.TrimPrefix(response, "```json")
+	response = strings.TrimPrefix(response, "```")
+	response = strings.TrimSuffix(response, "```")
+	return strings.TrimSpace(response)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildPackingList(t *testing.T) {
+	days := func(n int, day WeatherCondition) WeatherForecast {
+		forecast := WeatherForecast{}
+		for i := 0; i < n; i++ {
+			forecast.Forecast = append(forecast.Forecast, day)
+		}
+		return forecast
+	}
+	mild := WeatherCondition{Temperature: 20, Description: "clear sky", Humidity: 50}
+
+	tests := []struct {
+		name       string
+		forecast   WeatherForecast
+		activities []string
+		travelers  int
+		want       map[string]int
+		absent     []string
+	}{
+		{
+			name:     "mild weekend for two",
+			forecast: days(3, mild), travelers: 2,
+			want:   map[string]int{"Underwear": 8, "Tops": 6, "Trousers or skirts": 4, "Toothpaste": 1, "Booking confirmations": 1},
+			absent: []string{"Sunscreen", "Warm jacket", "Rain jacket", "Swimwear"},
+		},
+		{
+			name:     "long hot trip caps clothing",
+			forecast: days(10, WeatherCondition{Temperature: 34, Description: "sunny"}), travelers: 1,
+			want:   map[string]int{"Underwear": 8, "Tops": 7, "Sunscreen": 1, "Breathable shorts": 4},
+			absent: []string{"Warm jacket"},
+		},
+		{
+			name:     "freezing and snowy",
+			forecast: days(2, WeatherCondition{Temperature: -2, Description: "light snow"}), travelers: 3,
+			want:   map[string]int{"Warm jacket": 3, "Thermal base layers": 6, "Gloves": 3, "Beanie": 3, "Waterproof boots": 3},
+			absent: []string{"Sunscreen"},
+		},
+		{
+			name:       "rain, wind, humidity and activities",
+			forecast:   days(1, WeatherCondition{Temperature: 22, Description: "Heavy Rain", Humidity: 90, WindSpeed: 40}),
+			activities: []string{"Beach day", "Golden Temple visit"},
+			travelers:  4,
+			want:       map[string]int{"Rain jacket": 4, "Compact umbrella": 2, "Windbreaker": 4, "Insect repellent": 2, "Swimwear": 8, "Sunscreen": 2, "Scarf or shawl": 4},
+			absent:     []string{"Hiking boots", "Warm jacket"},
+		},
+		{
+			name:     "no forecast falls back to current weather",
+			forecast: WeatherForecast{Current: WeatherCondition{Temperature: 30, Description: "sunny"}}, travelers: 1,
+			want: map[string]int{"Tops": 1, "Sunscreen": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]int)
+			for _, item := range buildPackingList(tt.forecast, tt.activities, tt.travelers) {
+				if _, dup := got[item.Name]; dup {
+					t.Errorf("%s listed twice", item.Name)
+				}
+				got[item.Name] = item.Quantity
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s quantity = %d, want %d", name, got[name], want)
+				}
+			}
+			for _, name := range tt.absent {
+				if _, ok := got[name]; ok {
+					t.Errorf("%s packed, want it left out", name)
+				}
+			}
+		})
+	}
+}
+
+func TestMergePackingItems(t *testing.T) {
+	base := []PackingItem{
+		{Name: "Sunscreen", Category: PackingToiletries, Quantity: 1},
+		{Name: "Tops", Category: PackingClothing, Quantity: 3, Reason: "Daily wear"},
+	}
+	tests := []struct {
+		name  string
+		extra []PackingItem
+		want  string
+	}{
+		{"nothing to add", nil, "clothing/Tops x3 (Daily wear),toiletries/Sunscreen x1 ()"},
+		{
+			"duplicates keep the larger quantity and fill a missing reason",
+			[]PackingItem{{Name: " sunscreen ", Category: PackingToiletries, Quantity: 2, Reason: "Beach"}, {Name: "TOPS", Quantity: 1, Reason: "Other"}},
+			"clothing/Tops x3 (Daily wear),toiletries/Sunscreen x2 (Beach)",
+		},
+		{
+			"suggestions are cleaned up",
+			[]PackingItem{{Name: "Travel pillow", Category: "comfort"}, {Name: "  "}, {Name: "Visa", Category: PackingDocuments, Quantity: -1}},
+			"clothing/Tops x3 (Daily wear),documents/Visa x1 (),gear/Travel pillow x1 (),toiletries/Sunscreen x1 ()",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged := mergePackingItems(base, tt.extra)
+			parts := make([]string, len(merged))
+			for i, item := range merged {
+				parts[i] = fmt.Sprintf("%s/%s x%d (%s)", item.Category, item.Name, item.Quantity, item.Reason)
+			}
+			if got := strings.Join(parts, ","); got != tt.want {
+				t.Errorf("merged = %s\nwant     %s", got, tt.want)
+			}
+		})
+	}
+	if base[0].Quantity != 1 {
+		t.Error("mergePackingItems modified its input")
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Plan diff statuses
+const (
+	DiffAdded    = "added"
+	DiffRemoved  = "removed"
+	DiffModified = "modified"
+)
+
+// planSlots are the day keys holding activities, in display order
+var planSlots = []string{"morning", "afternoon", "evening", "night", "activities"}
+
+// planDayKey matches day keys such as "day_1", "day1" and "Day 1"
+var planDayKey = regexp.MustCompile(`(?i)^day[_ ]?(\d+)$`)
+
+// PlanDiff is a structured, per-day and per-slot comparison of two plans
+type PlanDiff struct {
+	Days     []DayDiff `json:"days"`
+	Added    int       `json:"added"`
+	Removed  int       `json:"removed"`
+	Modified int       `json:"modified"`
+}
+
+// DayDiff holds the changes to one day. Status is empty when the day exists
+// in both plans.
+type DayDiff struct {
+	Day    int        `json:"day"`
+	Status string     `json:"status,omitempty"`
+	Slots  []SlotDiff `json:"slots"`
+}
+
+// SlotDiff holds the changes to one part of a day
+type SlotDiff struct {
+	Slot       string         `json:"slot"`
+	Activities []ActivityDiff `json:"activities"`
+}
+
+// ActivityDiff is an added, removed or modified activity
+type ActivityDiff struct {
+	Status   string                 `json:"status"`
+	Name     string                 `json:"name"`
+	Original map[string]interface{} `json:"original,omitempty"`
+	Revised  map[string]interface{} `json:"revised,omitempty"`
+	Fields   []FieldChange          `json:"fields,omitempty"`
+}
+
+// FieldChange is one changed field of a modified activity
+type FieldChange struct {
+	Field    string      `json:"field"`
+	Original interface{} `json:"original,omitempty"`
+	Revised  interface{} `json:"revised,omitempty"`
+}
+
+// ComputePlanDiff compares two itineraries day by day and slot by slot.
+// Plans may be maps or structs in any of the shapes the app produces: days
+// keyed "day_1" or "day1", at the top level or under an "itinerary" key, with
+// slots holding a description string, an activity object or a list of
+// either. Activities are matched by id, then by name; a matched activity
+// whose other fields differ is reported as modified. A nil plan counts as
+// empty.
+func ComputePlanDiff(original, revised interface{}) (*PlanDiff, error) {
+	originalDays, err := planDays(original)
+	if err != nil {
+		return nil, fmt.Errorf("invalid original plan: %w", err)
+	}
+	revisedDays, err := planDays(revised)
+	if err != nil {
+		return nil, fmt.Errorf("invalid revised plan: %w", err)
+	}
+
+	dayNums := make(map[int]bool)
+	for day := range originalDays {
+		dayNums[day] = true
+	}
+	for day := range revisedDays {
+		dayNums[day] = true
+	}
+	sortedDays := make([]int, 0, len(dayNums))
+	for day := range dayNums {
+		sortedDays = append(sortedDays, day)
+	}
+	sort.Ints(sortedDays)
+
+	diff := &PlanDiff{Days: []DayDiff{}}
+	for _, day := range sortedDays {
+		originalDay, inOriginal := originalDays[day]
+		revisedDay, inRevised := revisedDays[day]
+
+		dayDiff := DayDiff{Day: day}
+		switch {
+		case !inOriginal:
+			dayDiff.Status = DiffAdded
+		case !inRevised:
+			dayDiff.Status = DiffRemoved
+		}
+
+		for _, slot := range planSlots {
+			changes := diffActivities(planActivities(originalDay[slot]), planActivities(revisedDay[slot]))
+			if len(changes) == 0 {
+				continue
+			}
+			for _, change := range changes {
+				switch change.Status {
+				case DiffAdded:
+					diff.Added++
+				case DiffRemoved:
+					diff.Removed++
+				case DiffModified:
+					diff.Modified++
+				}
+			}
+			dayDiff.Slots = append(dayDiff.Slots, SlotDiff{Slot: slot, Activities: changes})
+		}
+		if len(dayDiff.Slots) > 0 || dayDiff.Status != "" {
+			if dayDiff.Slots == nil {
+				dayDiff.Slots = []SlotDiff{}
+			}
+			diff.Days = append(diff.Days, dayDiff)
+		}
+	}
+	return diff, nil
+}
+
+// planDays normalizes a plan to its days, keyed by day number
+func planDays(plan interface{}) (map[int]map[string]interface{}, error) {
+	days := make(map[int]map[string]interface{})
+	if plan == nil || (reflect.ValueOf(plan).Kind() == reflect.Ptr && reflect.ValueOf(plan).IsNil()) {
+		return days, nil
+	}
+
+	// Round-trip through JSON so structs and typed maps share one shape
+	encoded, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+	var root map[string]interface{}
+	if err := json.Unmarshal(encoded, &root); err != nil {
+		return nil, fmt.Errorf("plan must be an object")
+	}
+
+	for key, value := range root {
+		switch strings.ToLower(key) {
+		case "itinerary", "daily_itinerary":
+			if nested, ok := value.(map[string]interface{}); ok {
+				root = nested
+			}
+		}
+	}
+
+	for key, value := range root {
+		match := planDayKey.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		dayPlan, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		day, _ := strconv.Atoi(match[1])
+		days[day] = dayPlan
+	}
+	return days, nil
+}
+
+// planActivities normalizes a slot's value to a list of activity objects
+func planActivities(value interface{}) []map[string]interface{} {
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return nil
+		}
+		return []map[string]interface{}{{"name": v}}
+	case map[string]interface{}:
+		return []map[string]interface{}{v}
+	case []interface{}:
+		var activities []map[string]interface{}
+		for _, item := range v {
+			activities = append(activities, planActivities(item)...)
+		}
+		return activities
+	default:
+		return nil
+	}
+}
+
+// activityName returns the activity's name, or its title
+func activityName(activity map[string]interface{}) string {
+	for _, key := range []string{"name", "title", "activity"} {
+		if name, ok := activity[key].(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// diffActivities matches a slot's activities by id, then by name, and
+// reports what was added, removed or modified
+func diffActivities(original, revised []map[string]interface{}) []ActivityDiff {
+	matched := make([]bool, len(revised))
+	match := func(activity map[string]interface{}) int {
+		if id, ok := activity["id"].(string); ok && id != "" {
+			for j, candidate := range revised {
+				if !matched[j] && candidate["id"] == id {
+					return j
+				}
+			}
+		}
+		name := strings.ToLower(strings.TrimSpace(activityName(activity)))
+		for j, candidate := range revised {
+			if !matched[j] && name != "" && strings.ToLower(strings.TrimSpace(activityName(candidate))) == name {
+				return j
+			}
+		}
+		return -1
+	}
+
+	var changes []ActivityDiff
+	for _, activity := range original {
+		j := match(activity)
+		if j < 0 {
+			changes = append(changes, ActivityDiff{Status: DiffRemoved, Name: activityName(activity), Original: activity})
+			continue
+		}
+		matched[j] = true
+		if fields := diffFields(activity, revised[j]); len(fields) > 0 {
+			changes = append(changes, ActivityDiff{
+				Status:   DiffModified,
+				Name:     activityName(revised[j]),
+				Original: activity,
+				Revised:  revised[j],
+				Fields:   fields,
+			})
+		}
+	}
+	for j, activity := range revised {
+		if !matched[j] {
+			changes = append(changes, ActivityDiff{Status: DiffAdded, Name: activityName(activity), Revised: activity})
+		}
+	}
+	return changes
+}
+
+// diffFields lists the fields that differ between two activities, sorted by
+// name
+func diffFields(original, revised map[string]interface{}) []FieldChange {
+	keys := make(map[string]bool)
+	for key := range original {
+		keys[key] = true
+	}
+	for key := range revised {
+		keys[key] = true
+	}
+
+	var fields []FieldChange
+	for key := range keys {
+		if !reflect.DeepEqual(original[key], revised[key]) {
+			fields = append(fields, FieldChange{Field: key, Original: original[key], Revised: revised[key]})
+		}
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Field < fields[j].Field })
+	return fields
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestComputePlanDiff(t *testing.T) {
+	type plan = map[string]interface{}
+
+	tests := []struct {
+		name      string
+		original  interface{}
+		revised   interface{}
+		want      string
+		wantCount string
+		wantErr   bool
+	}{
+		{
+			name:      "unchanged",
+			original:  plan{"day_1": plan{"morning": "Fort"}},
+			revised:   plan{"day_1": plan{"morning": "Fort"}},
+			wantCount: "+0 -0 ~0",
+		},
+		{
+			name:      "description replaced",
+			original:  plan{"day_1": plan{"morning": "Fort"}},
+			revised:   plan{"day_1": plan{"morning": "Palace"}},
+			want:      "1: morning[removed Fort, added Palace]",
+			wantCount: "+1 -1 ~0",
+		},
+		{
+			name:      "matched by name",
+			original:  plan{"day_1": plan{"morning": []interface{}{plan{"name": "Fort", "time": "09:00"}}}},
+			revised:   plan{"day_1": plan{"morning": []interface{}{plan{"name": "Fort", "time": "10:00"}}}},
+			want:      "1: morning[modified Fort(time)]",
+			wantCount: "+0 -0 ~1",
+		},
+		{
+			name:      "matched by id despite a rename",
+			original:  plan{"day_1": plan{"evening": plan{"id": "a1", "name": "Fort", "cost": 10}}},
+			revised:   plan{"day_1": plan{"evening": plan{"id": "a1", "name": "Amber Fort", "cost": 12}}},
+			want:      "1: evening[modified Amber Fort(cost,name)]",
+			wantCount: "+0 -0 ~1",
+		},
+		{
+			name:      "days added and removed across shapes",
+			original:  plan{"itinerary": plan{"Day 1": plan{"evening": "Show"}, "day_3": plan{"morning": "Hike"}}},
+			revised:   plan{"day1": plan{"evening": "Show"}, "day_2": plan{"afternoon": []interface{}{"Lunch", plan{"title": "Museum"}}}},
+			want:      "2 added: afternoon[added Lunch, added Museum]; 3 removed: morning[removed Hike]",
+			wantCount: "+2 -1 ~0",
+		},
+		{
+			name:      "no original plan",
+			revised:   plan{"day_1": plan{"morning": "Fort"}},
+			want:      "1 added: morning[added Fort]",
+			wantCount: "+1 -0 ~0",
+		},
+		{
+			name:     "not an object",
+			original: []int{1},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := ComputePlanDiff(tt.original, tt.revised)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ComputePlanDiff error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			days := make([]string, len(diff.Days))
+			for i, day := range diff.Days {
+				slots := make([]string, len(day.Slots))
+				for j, slot := range day.Slots {
+					changes := make([]string, len(slot.Activities))
+					for k, change := range slot.Activities {
+						changes[k] = change.Status + " " + change.Name
+						if len(change.Fields) > 0 {
+							fields := make([]string, len(change.Fields))
+							for f, field := range change.Fields {
+								fields[f] = field.Field
+							}
+							changes[k] += "(" + strings.Join(fields, ",") + ")"
+						}
+					}
+					slots[j] = slot.Slot + "[" + strings.Join(changes, ", ") + "]"
+				}
+				days[i] = strings.TrimSpace(fmt.Sprintf("%d %s", day.Day, day.Status)) + ": " + strings.Join(slots, " ")
+			}
+			if got := strings.Join(days, "; "); got != tt.want {
+				t.Errorf("diff = %s\nwant   %s", got, tt.want)
+			}
+			if got := fmt.Sprintf("+%d -%d ~%d", diff.Added, diff.Removed, diff.Modified); got != tt.wantCount {
+				t.Errorf("counts = %s, want %s", got, tt.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auratravel-backend/internal/handlers"
+	"auratravel-backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestPlanTripBudgetCurrency checks the budget breakdown is given in the
+// traveler's preferred currency, with the trip's own currency as original
+func TestPlanTripBudgetCurrency(t *testing.T) {
+	gemini := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"{\"day_1\":{\"morning\":\"Baga Beach\"}}"}]}}]}`)
+	}))
+	defer gemini.Close()
+
+	firebase := services.NewTestFirebase(t)
+	for userID, currency := range map[string]string{"rupees": "inr", "same": "EUR", "unknown": "XYZ"} {
+		if _, err := firebase.GetFirestoreClient().Collection("users").Doc(userID).Set(context.Background(), map[string]interface{}{"preferred_currency": currency}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	geminiService := services.NewTestGemini(gemini.URL, gemini.Client())
+	retriever := services.NewRAGRetriever(nil, geminiService, nil, "", "", nil)
+	handler := handlers.NewAITripHandler(&services.Services{
+		Firebase:           firebase,
+		GenerationPipeline: services.NewGenerationPipelineFromOrder([]string{services.StrategyRAG}, retriever, geminiService),
+	})
+
+	tests := []struct {
+		name             string
+		userID           string
+		currency         string
+		wantStatus       int
+		wantCurrency     string
+		wantTotal        float64
+		wantOriginal     string
+		wantTripCurrency string
+	}{
+		{"no preference", "nobody", "", http.StatusOK, "USD", 1000, "", "USD"},
+		{"converted to the preference", "rupees", "usd", http.StatusOK, "INR", 83200, "USD", "USD"},
+		{"preference matches the trip", "same", "EUR", http.StatusOK, "EUR", 1000, "", "EUR"},
+		{"unknown preference", "unknown", "USD", http.StatusBadRequest, "", 0, "", ""},
+		{"unknown trip currency", "nobody", "DOGE", http.StatusBadRequest, "", 0, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.POST("/plan-trip", func(c *gin.Context) { c.Set("userID", tt.userID) }, handler.PlanTrip)
+
+			body := fmt.Sprintf(`{"destination":"Goa","start_date":"2027-03-01","end_date":"2027-03-04","travelers":2,"budget":1000,"currency":%q}`, tt.currency)
+			req := httptest.NewRequest(http.MethodPost, "/plan-trip", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("plan-trip = %d %s, want %d", w.Code, w.Body.String(), tt.wantStatus)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp handlers.PlanTripResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			originalCurrency := ""
+			if resp.Budget.Original != nil {
+				originalCurrency = resp.Budget.Original.Currency
+			}
+			if resp.Budget.Currency != tt.wantCurrency || resp.Budget.Total != tt.wantTotal || originalCurrency != tt.wantOriginal {
+				t.Errorf("budget %.2f %s (original %q), want %.2f %s (original %q)", resp.Budget.Total, resp.Budget.Currency, originalCurrency, tt.wantTotal, tt.wantCurrency, tt.wantOriginal)
+			}
+			if resp.Itinerary["currency"] != tt.wantTripCurrency {
+				t.Errorf("itinerary currency = %v, want %s", resp.Itinerary["currency"], tt.wantTripCurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auratravel-backend/internal/handlers"
+	"auratravel-backend/internal/middleware"
+	"auratravel-backend/internal/services"
+	"auratravel-backend/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestPlanTripSpans checks that planning a trip records its Gemini, RAG and
+// Firestore calls as descendants of the request's server span
+func TestPlanTripSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(tracing.Use(provider))
+	defer provider.Shutdown(context.Background())
+
+	gemini := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"candidates":[{"content":{"parts":[{"text":"{\"day_1\":{\"morning\":\"Baga Beach\"}}"}]}}]}`)
+	}))
+	defer gemini.Close()
+
+	geminiService := services.NewTestGemini(gemini.URL, gemini.Client())
+	retriever := services.NewRAGRetriever(nil, geminiService, nil, "", "", nil)
+	handler := handlers.NewAITripHandler(&services.Services{
+		Firebase:           services.NewTestFirebase(t),
+		GenerationPipeline: services.NewGenerationPipelineFromOrder([]string{services.StrategyRAG}, retriever, geminiService),
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.TracingMiddleware())
+	router.POST("/api/v1/ai/plan-trip", func(c *gin.Context) { c.Set("userID", "u1") }, handler.PlanTrip)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/ai/plan-trip",
+		strings.NewReader(`{"destination":"Goa","start_date":"2027-03-01","end_date":"2027-03-04","travelers":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("plan-trip = %d %s", w.Code, w.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+	byID := make(map[trace.SpanID]tracetest.SpanStub, len(spans))
+	var server *tracetest.SpanStub
+	for i, span := range spans {
+		byID[span.SpanContext.SpanID()] = span
+		if span.SpanKind == trace.SpanKindServer {
+			server = &spans[i]
+		}
+	}
+	if server == nil || server.Name != "POST /api/v1/ai/plan-trip" {
+		t.Fatalf("no server span for the request among %d spans", len(spans))
+	}
+	underServer := func(span tracetest.SpanStub) bool {
+		for parent, ok := span, true; ok; parent, ok = byID[parent.Parent.SpanID()] {
+			if parent.Parent.SpanID() == server.SpanContext.SpanID() {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, prefix := range []string{"gemini.generateContent", "rag.retrieve_context", "cloud.google.com/go/firestore."} {
+		found := false
+		for _, span := range spans {
+			if strings.HasPrefix(span.Name, prefix) {
+				found = true
+				if span.SpanContext.TraceID() != server.SpanContext.TraceID() || !underServer(span) {
+					t.Errorf("%s span isn't under the server span", span.Name)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no %s span recorded", prefix)
+		}
+	}
+}
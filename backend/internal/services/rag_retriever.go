@@ -3,26 +3,38 @@ package services
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
-	"sort"
+	"strings"
 	"time"
+
+	"auratravel-backend/internal/cache"
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/tracing"
+)
+
+const (
+	// attractionsCacheTTL is how long fetched attractions are reused
+	attractionsCacheTTL = 24 * time.Hour
+	// weatherCacheTTL keeps forecasts fresh enough to plan around
+	weatherCacheTTL = time.Hour
 )
 
 // RAGRetriever handles retrieval of contextual data for AI generation
 type RAGRetriever struct {
 	firebase      *FirebaseService
-	gemini        *GeminiService
+	gemini        AIGenerator
 	vision        *VisionService
 	dataConnector *DataSourceConnector
 	validator     *DataValidator
 	mapsAPIKey    string
 	weatherKey    string
 	httpClient    *http.Client
+	cache         cache.Cache
 }
 
 // NewRAGRetriever creates a new RAG retriever instance
-func NewRAGRetriever(firebase *FirebaseService, gemini *GeminiService, vision *VisionService, mapsAPIKey, weatherKey string) *RAGRetriever {
+func NewRAGRetriever(firebase *FirebaseService, gemini AIGenerator, vision *VisionService, mapsAPIKey, weatherKey string, resultCache cache.Cache) *RAGRetriever {
 	dataConnector := NewDataSourceConnector(mapsAPIKey, weatherKey, "")
 	
 	retriever := &RAGRetriever{
@@ -32,7 +44,8 @@ func NewRAGRetriever(firebase *FirebaseService, gemini *GeminiService, vision *V
 		dataConnector: dataConnector,
 		mapsAPIKey:    mapsAPIKey,
 		weatherKey:    weatherKey,
-		httpClient:    &http.Client{Timeout: 30 * time.Second},
+		httpClient:    httpclient.New(httpclient.Options{}),
+		cache:         resultCache,
 	}
 	
 	// Initialize validator with self-reference
@@ -41,6 +54,11 @@ func NewRAGRetriever(firebase *FirebaseService, gemini *GeminiService, vision *V
 	return retriever
 }
 
+// SetVectorDatabase indexes the hotels the retriever fetches in vdb
+func (r *RAGRetriever) SetVectorDatabase(vdb *VectorDatabase) {
+	r.dataConnector.SetVectorDatabase(vdb)
+}
+
 // TripContext represents the context retrieved for trip planning
 type TripContext struct {
 	Destination    string            `json:"destination"`
@@ -52,6 +70,10 @@ type TripContext struct {
 	Transportation []TransportOption `json:"transportation"`
 	SimilarTrips   []TripData        `json:"similar_trips"`
 	EMTInventory   []EMTItem         `json:"emt_inventory"`
+	// Legs and IntercityTransport are only set for multi-city trips; the
+	// fields above then aggregate every leg
+	Legs               []LegContext      `json:"legs,omitempty"`
+	IntercityTransport []TransportOption `json:"intercity_transport,omitempty"`
 }
 
 // Attraction represents a tourist attraction
@@ -66,6 +88,9 @@ type Attraction struct {
 	Description  string   `json:"description"`
 	Tags         []string `json:"tags"`
 	Available    bool     `json:"available"`
+	// AccessibilityStatus is set when accessibility was requested:
+	// accessible, inaccessible or unverified
+	AccessibilityStatus string `json:"accessibility_status,omitempty"`
 }
 
 // Hotel represents accommodation options
@@ -78,6 +103,9 @@ type Hotel struct {
 	Amenities     []string `json:"amenities"`
 	Available     bool     `json:"available"`
 	BookingURL    string   `json:"booking_url,omitempty"`
+	// AccessibilityStatus is set when accessibility was requested:
+	// accessible, inaccessible or unverified
+	AccessibilityStatus string `json:"accessibility_status,omitempty"`
 }
 
 // Location represents geographical coordinates
@@ -125,6 +153,14 @@ type TransportOption struct {
 	Available  bool    `json:"available"`
 	BookingURL string  `json:"booking_url,omitempty"`
 	Provider   string  `json:"provider"`
+	// DepartureDate is set for inter-city legs of multi-city trips
+	DepartureDate time.Time `json:"departure_date,omitzero"`
+	// FromLocation and ToLocation, when known, give DistanceKm; CarbonKg is
+	// the estimated kg CO2e per passenger
+	FromLocation *Location `json:"from_location,omitempty"`
+	ToLocation   *Location `json:"to_location,omitempty"`
+	DistanceKm   float64   `json:"distance_km,omitempty"`
+	CarbonKg     float64   `json:"carbon_kg,omitempty"`
 }
 
 // EMTItem represents Emergency Medical Tourism inventory
@@ -148,11 +184,53 @@ type RetrievalRequest struct {
 	Travelers   int                    `json:"travelers"`
 	Interests   []string               `json:"interests"`
 	Preferences map[string]interface{} `json:"preferences"`
+	// TravelStyle picks a BudgetAllocationPresets entry ("budget", "balanced",
+	// "luxury"); BudgetAllocation, when set, takes precedence
+	TravelStyle      string            `json:"travel_style"`
+	BudgetAllocation *BudgetAllocation `json:"budget_allocation,omitempty"`
+	// RankingWeights overrides DefaultRankingWeights; weights that don't sum
+	// to 1 are normalized
+	RankingWeights *RankingWeights `json:"ranking_weights,omitempty"`
+	// Destinations, when set, makes this a multi-city trip and Destination is
+	// ignored
+	Destinations []DestinationLeg `json:"destinations,omitempty"`
+}
+
+// budgetAllocation resolves the request's budget split
+func (req RetrievalRequest) budgetAllocation() (BudgetAllocation, error) {
+	if req.BudgetAllocation != nil {
+		if err := req.BudgetAllocation.Validate(); err != nil {
+			return BudgetAllocation{}, err
+		}
+		return *req.BudgetAllocation, nil
+	}
+	return BudgetAllocationForStyle(req.TravelStyle)
 }
 
 // RetrieveContext fetches comprehensive context for trip planning
 func (r *RAGRetriever) RetrieveContext(ctx context.Context, req RetrievalRequest) (*TripContext, error) {
-	log.Printf("Retrieving context for destination: %s", req.Destination)
+	if len(req.Destinations) > 0 {
+		return r.retrieveMultiCityContext(ctx, req)
+	}
+
+	ctx, span := tracing.Start(ctx, "rag.retrieve_context", tracing.KindInternal, tracing.Attr("destination", req.Destination))
+	defer span.End()
+
+	logger := logging.FromContext(ctx).With("destination", req.Destination)
+	start := time.Now()
+	logger.Info("Retrieving trip context")
+
+	allocation, err := req.budgetAllocation()
+	if err != nil {
+		return nil, fmt.Errorf("invalid budget allocation: %v", err)
+	}
+
+	weights := DefaultRankingWeights()
+	if req.RankingWeights != nil {
+		if weights, err = req.RankingWeights.Normalized(); err != nil {
+			return nil, fmt.Errorf("invalid ranking weights: %v", err)
+		}
+	}
 
 	tripContext := &TripContext{
 		Destination: req.Destination,
@@ -169,28 +247,28 @@ func (r *RAGRetriever) RetrieveContext(ctx context.Context, req RetrievalRequest
 	// Fetch attractions using data connector
 	attractions, err := r.dataConnector.FetchAttractions(ctx, req.Destination, req.Interests)
 	if err != nil {
-		log.Printf("Error fetching attractions: %v", err)
+		logger.Warn("Error fetching attractions", "error", err)
 		attractions = r.getMockAttractions(req.Destination)
 	}
 
 	// Fetch hotels using data connector
 	hotels, err := r.dataConnector.FetchHotels(ctx, req.Destination, req.StartDate, req.EndDate, req.Budget)
 	if err != nil {
-		log.Printf("Error fetching hotels: %v", err)
+		logger.Warn("Error fetching hotels", "error", err)
 		hotels = r.getMockHotels(req.Destination)
 	}
 
 	// Fetch weather forecast
 	weather, err := r.fetchWeather(ctx, req.Destination, req.StartDate, req.EndDate)
 	if err != nil {
-		log.Printf("Error fetching weather: %v", err)
+		logger.Warn("Error fetching weather", "error", err)
 		weather = WeatherForecast{} // Empty weather
 	}
 
 	// Fetch local events
 	events, err := r.fetchLocalEvents(ctx, req.Destination, req.StartDate, req.EndDate)
 	if err != nil {
-		log.Printf("Error fetching local events: %v", err)
+		logger.Warn("Error fetching local events", "error", err)
 	} else {
 		tripContext.LocalEvents = events
 	}
@@ -198,21 +276,15 @@ func (r *RAGRetriever) RetrieveContext(ctx context.Context, req RetrievalRequest
 	// Apply validation and ranking
 	if r.validator != nil {
 		criteria := ValidationCriteria{
-			Budget:            req.Budget,
-			RequiredRating:    3.0, // Minimum rating
-			PreferredTypes:    req.Interests,
-			Preferences:       req.Preferences,
-			AvailabilityCheck: true,
-		}
-		
-		weights := DefaultRankingWeights()
-
-		// Validate and rank attractions
-		validatedAttractions, err := r.validator.ValidateAndRankAttractions(ctx, attractions, criteria, weights)
-		if err == nil {
-			tripContext.Attractions = validatedAttractions
-		} else {
-			tripContext.Attractions = attractions
+			Budget:              req.Budget,
+			RequiredRating:      3.0, // Minimum rating
+			PreferredTypes:      req.Interests,
+			Preferences:         req.Preferences,
+			AvailabilityCheck:   true,
+			BudgetAllocation:    &allocation,
+			Nights:              TripNights(req.StartDate, req.EndDate),
+			Accessibility:       boolSetting(req.Preferences, "accessibility", false),
+			StrictAccessibility: boolSetting(req.Preferences, "strict_accessibility", false),
 		}
 
 		// Validate and rank hotels
@@ -223,9 +295,20 @@ func (r *RAGRetriever) RetrieveContext(ctx context.Context, req RetrievalRequest
 			tripContext.Hotels = hotels
 		}
 
+		// Validate and rank attractions, scoring distance from the top hotel
+		if len(tripContext.Hotels) > 0 {
+			criteria.ReferenceLocation = &tripContext.Hotels[0].Location
+		}
+		validatedAttractions, err := r.validator.ValidateAndRankAttractions(ctx, attractions, criteria, weights)
+		if err == nil {
+			tripContext.Attractions = validatedAttractions
+		} else {
+			tripContext.Attractions = attractions
+		}
+
 		// Apply budget constraints to the entire context
-		if err := r.validator.ApplyBudgetConstraints(ctx, tripContext, req.Budget); err != nil {
-			log.Printf("Error applying budget constraints: %v", err)
+		if err := r.validator.ApplyBudgetConstraints(ctx, tripContext, criteria); err != nil {
+			logger.Warn("Error applying budget constraints", "error", err)
 		}
 	} else {
 		tripContext.Attractions = attractions
@@ -237,8 +320,14 @@ func (r *RAGRetriever) RetrieveContext(ctx context.Context, req RetrievalRequest
 	// Fetch transportation options
 	transport, err := r.fetchTransportation(ctx, req.Destination, req.StartDate, req.EndDate)
 	if err != nil {
-		log.Printf("Error fetching transportation: %v", err)
+		logger.Warn("Error fetching transportation", "error", err)
 	} else {
+		for i := range transport {
+			transport[i].annotateCarbon()
+		}
+		if boolSetting(req.Preferences, "prefer_low_carbon", false) {
+			rankTransportByCarbon(transport)
+		}
 		tripContext.Transportation = transport
 	}
 
@@ -253,27 +342,45 @@ func (r *RAGRetriever) RetrieveContext(ctx context.Context, req RetrievalRequest
 	// Fetch EMT inventory
 	emtItems, err := r.fetchEMTInventory(ctx, req.Destination)
 	if err != nil {
-		log.Printf("Error fetching EMT inventory: %v", err)
+		logger.Warn("Error fetching EMT inventory", "error", err)
 	} else {
 		tripContext.EMTInventory = emtItems
 	}
 
+	logger.Info("Retrieved trip context",
+		"attractions", len(tripContext.Attractions),
+		"hotels", len(tripContext.Hotels),
+		"latency", time.Since(start))
 	return tripContext, nil
 }
 
 // fetchAttractions retrieves attractions using Google Places API
 func (r *RAGRetriever) fetchAttractions(ctx context.Context, destination string, interests []string) ([]Attraction, error) {
+	ctx, span := tracing.Start(ctx, "places.fetch_attractions", tracing.KindClient, tracing.Attr("destination", destination))
+	defer span.End()
+
 	if r.mapsAPIKey == "" {
 		return r.getMockAttractions(destination), nil
 	}
 
+	cacheKey := cache.Key("attractions", destination, strings.Join(interests, ","))
+	var attractions []Attraction
+	if cache.GetJSON(ctx, r.cache, cacheKey, &attractions) {
+		return attractions, nil
+	}
+
 	// Google Places API implementation would go here
 	// For now, return mock data
-	return r.getMockAttractions(destination), nil
+	attractions = r.getMockAttractions(destination)
+	cache.SetJSON(ctx, r.cache, cacheKey, attractions, attractionsCacheTTL)
+	return attractions, nil
 }
 
 // fetchHotels retrieves hotel options
 func (r *RAGRetriever) fetchHotels(ctx context.Context, destination string, budget float64) ([]Hotel, error) {
+	ctx, span := tracing.Start(ctx, "hotels.fetch", tracing.KindClient, tracing.Attr("destination", destination))
+	defer span.End()
+
 	// Mock hotel data - in production, integrate with booking APIs
 	return []Hotel{
 		{
@@ -299,8 +406,17 @@ func (r *RAGRetriever) fetchHotels(ctx context.Context, destination string, budg
 
 // fetchWeather retrieves weather forecast
 func (r *RAGRetriever) fetchWeather(ctx context.Context, destination string, startDate, endDate time.Time) (WeatherForecast, error) {
+	ctx, span := tracing.Start(ctx, "weather.fetch_forecast", tracing.KindClient, tracing.Attr("destination", destination))
+	defer span.End()
+
+	cacheKey := cache.Key("weather", destination, startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+	var forecast WeatherForecast
+	if cache.GetJSON(ctx, r.cache, cacheKey, &forecast) {
+		return forecast, nil
+	}
+
 	// Mock weather data - in production, integrate with weather API
-	forecast := WeatherForecast{
+	forecast = WeatherForecast{
 		Current: WeatherCondition{
 			Date:        time.Now(),
 			Temperature: 22.0,
@@ -323,11 +439,21 @@ func (r *RAGRetriever) fetchWeather(ctx context.Context, destination string, sta
 		})
 	}
 
+	cache.SetJSON(ctx, r.cache, cacheKey, forecast, weatherCacheTTL)
 	return forecast, nil
 }
 
+// GetWeatherForecast returns the daily forecast for a destination between
+// startDate and endDate
+func (r *RAGRetriever) GetWeatherForecast(ctx context.Context, destination string, startDate, endDate time.Time) (WeatherForecast, error) {
+	return r.fetchWeather(ctx, destination, startDate, endDate)
+}
+
 // fetchLocalEvents retrieves local events and activities
 func (r *RAGRetriever) fetchLocalEvents(ctx context.Context, destination string, startDate, endDate time.Time) ([]LocalEvent, error) {
+	ctx, span := tracing.Start(ctx, "events.fetch", tracing.KindClient, tracing.Attr("destination", destination))
+	defer span.End()
+
 	// Mock events data
 	return []LocalEvent{
 		{
@@ -355,6 +481,9 @@ func (r *RAGRetriever) fetchLocalEvents(ctx context.Context, destination string,
 
 // fetchTransportation retrieves transportation options
 func (r *RAGRetriever) fetchTransportation(ctx context.Context, destination string, startDate, endDate time.Time) ([]TransportOption, error) {
+	ctx, span := tracing.Start(ctx, "transport.fetch", tracing.KindClient, tracing.Attr("destination", destination))
+	defer span.End()
+
 	// Mock transportation data
 	return []TransportOption{
 		{
@@ -444,7 +573,7 @@ func (r *RAGRetriever) getMockAttractions(destination string) []Attraction {
 			PriceLevel:   2,
 			OpeningHours: []string{"9:00-17:00", "Mon-Sun"},
 			Description:  "Explore the rich history and culture",
-			Tags:         []string{"history", "culture", "educational"},
+			Tags:         []string{"history", "culture", "educational", "wheelchair_accessible", "elevator"},
 			Available:    true,
 		},
 		{
@@ -456,7 +585,7 @@ func (r *RAGRetriever) getMockAttractions(destination string) []Attraction {
 			PriceLevel:   0,
 			OpeningHours: []string{"6:00-22:00", "Daily"},
 			Description:  "Beautiful park perfect for relaxation",
-			Tags:         []string{"nature", "outdoor", "relaxation"},
+			Tags:         []string{"nature", "outdoor", "relaxation", "step_free"},
 			Available:    true,
 		},
 		{
@@ -482,7 +611,7 @@ func (r *RAGRetriever) getMockHotels(destination string) []Hotel {
 			Location:      Location{Address: fmt.Sprintf("Downtown, %s", destination)},
 			Rating:        4.2,
 			PricePerNight: 120,
-			Amenities:     []string{"WiFi", "Pool", "Restaurant", "Gym"},
+			Amenities:     []string{"WiFi", "Pool", "Restaurant", "Gym", "Elevator", "Accessible Room"},
 			Available:     true,
 			BookingURL:    "https://example.com/book",
 		},
@@ -492,7 +621,7 @@ func (r *RAGRetriever) getMockHotels(destination string) []Hotel {
 			Location:      Location{Address: fmt.Sprintf("City Center, %s", destination)},
 			Rating:        3.8,
 			PricePerNight: 80,
-			Amenities:     []string{"WiFi", "Parking"},
+			Amenities:     []string{"WiFi", "Parking", "Stairs Only"},
 			Available:     true,
 			BookingURL:    "https://example.com/book",
 		},
@@ -521,31 +650,3 @@ func (r *RAGRetriever) ValidateAvailability(ctx context.Context, tripContext *Tr
 
 	return nil
 }
-
-// RankByRelevance sorts retrieved items by relevance to user preferences
-func (r *RAGRetriever) RankByRelevance(tripContext *TripContext, interests []string) {
-	// Rank attractions by relevance
-	sort.Slice(tripContext.Attractions, func(i, j int) bool {
-		scoreI := r.calculateRelevanceScore(tripContext.Attractions[i].Tags, interests)
-		scoreJ := r.calculateRelevanceScore(tripContext.Attractions[j].Tags, interests)
-		return scoreI > scoreJ
-	})
-
-	// Rank hotels by rating and price
-	sort.Slice(tripContext.Hotels, func(i, j int) bool {
-		return tripContext.Hotels[i].Rating > tripContext.Hotels[j].Rating
-	})
-}
-
-// calculateRelevanceScore calculates how relevant an item is to user interests
-func (r *RAGRetriever) calculateRelevanceScore(tags, interests []string) int {
-	score := 0
-	for _, tag := range tags {
-		for _, interest := range interests {
-			if tag == interest {
-				score++
-			}
-		}
-	}
-	return score
-}
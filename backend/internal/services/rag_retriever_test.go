@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRAGRetrieverRetrieveContext(t *testing.T) {
+	start := time.Date(2026, 11, 10, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		req              RetrievalRequest
+		wantErr          bool
+		wantDestination  string
+		wantLegs         int
+		wantForecastDays int
+	}{
+		{
+			name:             "single city",
+			req:              RetrievalRequest{Destination: "Goa", StartDate: start, EndDate: start.AddDate(0, 0, 2), Budget: 30000, Travelers: 2},
+			wantDestination:  "Goa",
+			wantForecastDays: 3,
+		},
+		{
+			name: "multi-city",
+			req: RetrievalRequest{
+				Destinations: []DestinationLeg{{Destination: "Mumbai", Nights: 2}, {Destination: "Goa", Nights: 3}},
+				StartDate:    start,
+				Budget:       50000,
+				Travelers:    2,
+			},
+			wantDestination:  RouteName([]DestinationLeg{{Destination: "Mumbai", Nights: 2}, {Destination: "Goa", Nights: 3}}),
+			wantLegs:         2,
+			wantForecastDays: 6,
+		},
+		{
+			name:    "unknown travel style",
+			req:     RetrievalRequest{Destination: "Goa", StartDate: start, EndDate: start, TravelStyle: "backpacker"},
+			wantErr: true,
+		},
+		{
+			name:    "negative ranking weights",
+			req:     RetrievalRequest{Destination: "Goa", StartDate: start, EndDate: start, RankingWeights: &RankingWeights{Rating: -1}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator := &fakeGenerator{}
+			retriever := NewRAGRetriever(nil, generator, nil, "", "", nil)
+
+			tripContext, err := retriever.RetrieveContext(context.Background(), tt.req)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("RetrieveContext succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RetrieveContext: %v", err)
+			}
+			if tripContext.Destination != tt.wantDestination || len(tripContext.Legs) != tt.wantLegs {
+				t.Errorf("context for %q with %d legs, want %q with %d", tripContext.Destination, len(tripContext.Legs), tt.wantDestination, tt.wantLegs)
+			}
+			if len(tripContext.Attractions) == 0 || len(tripContext.Hotels) == 0 {
+				t.Errorf("context has %d attractions and %d hotels, want some of each", len(tripContext.Attractions), len(tripContext.Hotels))
+			}
+			if len(tripContext.Weather.Forecast) != tt.wantForecastDays {
+				t.Errorf("forecast covers %d days, want %d", len(tripContext.Weather.Forecast), tt.wantForecastDays)
+			}
+			if len(generator.prompts) != 0 {
+				t.Errorf("retrieval prompted the generator %d times, want none", len(generator.prompts))
+			}
+		})
+	}
+}
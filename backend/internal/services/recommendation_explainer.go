@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/models"
+)
+
+// maxExplanationTrips is how many past trips are described to Gemini
+const maxExplanationTrips = 5
+
+// RecommendationNotFoundError is returned when a recommendation doesn't exist
+// or belongs to another user
+type RecommendationNotFoundError struct {
+	RecommendationID string
+}
+
+func (e *RecommendationNotFoundError) Error() string {
+	return fmt.Sprintf("recommendation %s not found", e.RecommendationID)
+}
+
+// Is makes the error match ErrNotFound
+func (e *RecommendationNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// RecommendationService explains stored recommendations to their users
+type RecommendationService struct {
+	gemini   AIGenerator
+	firebase *FirebaseService
+}
+
+// NewRecommendationService creates a new recommendation service
+func NewRecommendationService(gemini AIGenerator, firebase *FirebaseService) *RecommendationService {
+	return &RecommendationService{
+		gemini:   gemini,
+		firebase: firebase,
+	}
+}
+
+// explanationContext is what's known about the user when explaining
+type explanationContext struct {
+	pastDestinations []string
+	interests        []string
+}
+
+func (e explanationContext) hasHistory() bool {
+	return len(e.pastDestinations) > 0 || len(e.interests) > 0
+}
+
+// ExplainRecommendation returns a personalized justification for one of the
+// user's stored recommendations, referencing their past trips and interests.
+// The explanation is saved on the recommendation, along with reasons when it
+// had none, so later calls return it without asking Gemini again.
+func (r *RecommendationService) ExplainRecommendation(ctx context.Context, userID, recommendationID string) (string, error) {
+	if r.firebase == nil {
+		return "", fmt.Errorf("firebase service not available")
+	}
+
+	data, err := r.firebase.GetRecommendation(ctx, recommendationID)
+	if err != nil {
+		return "", err
+	}
+	if owner, _ := data["user_id"].(string); owner != userID {
+		return "", &RecommendationNotFoundError{RecommendationID: recommendationID}
+	}
+	if explanation, _ := data["explanation"].(string); explanation != "" {
+		return explanation, nil
+	}
+
+	rec := recommendationFromDoc(data)
+	explainCtx := r.loadExplanationContext(ctx, userID)
+	explanation, reasons := r.generateExplanation(ctx, rec, explainCtx)
+
+	updates := map[string]interface{}{
+		"explanation":  explanation,
+		"explained_at": time.Now(),
+	}
+	if len(rec.Reasons) == 0 && len(reasons) > 0 {
+		updates["reasons"] = reasons
+	}
+	if err := r.firebase.UpdateRecommendation(ctx, recommendationID, updates); err != nil {
+		logging.FromContext(ctx).Warn("Failed to cache recommendation explanation", "recommendation_id", recommendationID, "error", err)
+	}
+
+	return explanation, nil
+}
+
+// recommendationFromDoc reads a stored recommendation, which may be in the
+// models.Recommendation shape or the raw shape Gemini returns
+func recommendationFromDoc(data map[string]interface{}) models.Recommendation {
+	rec := models.Recommendation{}
+	rec.ID, _ = data["id"].(string)
+	rec.UserID, _ = data["user_id"].(string)
+	rec.Type, _ = data["type"].(string)
+	rec.Title, _ = data["title"].(string)
+	if rec.Title == "" {
+		rec.Title, _ = data["destination"].(string)
+	}
+	rec.Description, _ = data["description"].(string)
+	rec.Rating, _ = data["rating"].(float64)
+	rec.Confidence, _ = data["confidence"].(float64)
+	rec.Reasons = stringList(data["reasons"])
+	rec.Tags = stringList(data["tags"])
+	if len(rec.Tags) == 0 {
+		rec.Tags = stringList(data["highlights"])
+	}
+	return rec
+}
+
+// stringList reads a Firestore array of strings
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				list = append(list, s)
+			}
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+// loadExplanationContext gathers the user's recent destinations and stated
+// interests. Missing data just leaves the context empty.
+func (r *RecommendationService) loadExplanationContext(ctx context.Context, userID string) explanationContext {
+	var explainCtx explanationContext
+
+	if trips, err := r.firebase.GetUserTrips(ctx, userID); err == nil {
+		sort.SliceStable(trips, func(i, j int) bool {
+			return timeFromValue(trips[i].StartDate).After(timeFromValue(trips[j].StartDate))
+		})
+		for _, trip := range trips {
+			if trip.Status == "deleted" || trip.Destination == "" {
+				continue
+			}
+			explainCtx.pastDestinations = append(explainCtx.pastDestinations, trip.Destination)
+		}
+		explainCtx.pastDestinations = removeDuplicateStrings(explainCtx.pastDestinations)
+		if len(explainCtx.pastDestinations) > maxExplanationTrips {
+			explainCtx.pastDestinations = explainCtx.pastDestinations[:maxExplanationTrips]
+		}
+	}
+
+	if profile, err := r.firebase.GetUserProfile(ctx, userID); err == nil {
+		explainCtx.interests = stringList(profile.TravelPreferences["interests"])
+	}
+	return explainCtx
+}
+
+// generateExplanation asks Gemini for an explanation and reasons, falling
+// back to a rule-based explanation without an API key or on failure
+func (r *RecommendationService) generateExplanation(ctx context.Context, rec models.Recommendation, explainCtx explanationContext) (string, []string) {
+	if r.gemini == nil {
+		return basicExplanation(rec, explainCtx)
+	}
+
+	response, err := r.gemini.GenerateText(ctx, buildExplanationPrompt(rec, explainCtx))
+	if err != nil {
+		if !errors.Is(err, ErrAIUnavailable) {
+			logging.FromContext(ctx).Warn("Gemini API call failed, falling back to basic explanation", "error", err)
+		}
+		return basicExplanation(rec, explainCtx)
+	}
+
+	var parsed struct {
+		Explanation string   `json:"explanation"`
+		Reasons     []string `json:"reasons"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(response)), &parsed); err != nil || parsed.Explanation == "" {
+		logging.FromContext(ctx).Warn("Failed to parse Gemini explanation, falling back to basic explanation", "error", err)
+		return basicExplanation(rec, explainCtx)
+	}
+	return parsed.Explanation, parsed.Reasons
+}
+
+func buildExplanationPrompt(rec models.Recommendation, explainCtx explanationContext) string {
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Explain to a traveler why we recommended %s", rec.Title))
+	if rec.Type != "" {
+		prompt.WriteString(fmt.Sprintf(" (%s)", rec.Type))
+	}
+	prompt.WriteString(".\n")
+	if rec.Description != "" {
+		prompt.WriteString(fmt.Sprintf("Description: %s\n", rec.Description))
+	}
+	if len(rec.Tags) > 0 {
+		prompt.WriteString(fmt.Sprintf("Highlights: %s\n", strings.Join(rec.Tags, ", ")))
+	}
+	if len(rec.Reasons) > 0 {
+		prompt.WriteString(fmt.Sprintf("Known reasons: %s\n", strings.Join(rec.Reasons, "; ")))
+	}
+
+	if explainCtx.hasHistory() {
+		if len(explainCtx.pastDestinations) > 0 {
+			prompt.WriteString(fmt.Sprintf("The traveler's recent trips: %s\n", strings.Join(explainCtx.pastDestinations, ", ")))
+		}
+		if len(explainCtx.interests) > 0 {
+			prompt.WriteString(fmt.Sprintf("The traveler's stated interests: %s\n", strings.Join(explainCtx.interests, ", ")))
+		}
+		prompt.WriteString("Refer to their past trips and interests where they genuinely relate; don't invent connections.\n")
+	} else {
+		prompt.WriteString("We know nothing about this traveler's past trips or interests. Say so plainly and explain the general appeal instead; don't claim it is personalized.\n")
+	}
+
+	prompt.WriteString(`Respond with only a JSON object:
+{"explanation": "2-3 friendly sentences addressed to the traveler", "reasons": ["short reason", "..."]}`)
+	return prompt.String()
+}
+
+// basicExplanation explains a recommendation from its own data and the
+// user's interests and trips, without Gemini
+func basicExplanation(rec models.Recommendation, explainCtx explanationContext) (string, []string) {
+	title := rec.Title
+	if title == "" {
+		title = "This recommendation"
+	}
+
+	haystack := strings.ToLower(strings.Join(append([]string{rec.Type, rec.Description}, append(rec.Tags, rec.Reasons...)...), " "))
+	var matched []string
+	for _, interest := range explainCtx.interests {
+		if interest != "" && strings.Contains(haystack, strings.ToLower(interest)) {
+			matched = append(matched, interest)
+		}
+	}
+
+	reasons := append([]string{}, rec.Reasons...)
+	for _, interest := range matched {
+		reasons = append(reasons, fmt.Sprintf("Matches your interest in %s", interest))
+	}
+
+	var explanation strings.Builder
+	if !explainCtx.hasHistory() {
+		explanation.WriteString(fmt.Sprintf("We don't know your past trips or interests yet, so %s is a general suggestion rather than a personalized one.", title))
+		if rec.Description != "" {
+			explanation.WriteString(fmt.Sprintf(" %s.", strings.TrimSuffix(rec.Description, ".")))
+		}
+		if len(rec.Reasons) > 0 {
+			explanation.WriteString(fmt.Sprintf(" It's popular for: %s.", strings.ToLower(strings.Join(rec.Reasons, ", "))))
+		}
+		explanation.WriteString(" Plan a trip or add your interests to get tailored recommendations.")
+		if len(reasons) == 0 && rec.Type != "" {
+			reasons = append(reasons, fmt.Sprintf("Popular %s destination", rec.Type))
+		}
+		return explanation.String(), reasons
+	}
+
+	explanation.WriteString(fmt.Sprintf("We suggested %s", title))
+	switch {
+	case len(matched) > 0:
+		explanation.WriteString(fmt.Sprintf(" because it fits your interest in %s", joinWithAnd(matched)))
+	case len(explainCtx.interests) > 0:
+		explanation.WriteString(fmt.Sprintf(" as something different from your usual interests (%s)", strings.Join(explainCtx.interests, ", ")))
+	}
+	explanation.WriteString(".")
+	if len(explainCtx.pastDestinations) > 0 {
+		revisit := false
+		for _, destination := range explainCtx.pastDestinations {
+			if strings.EqualFold(destination, rec.Title) {
+				revisit = true
+				break
+			}
+		}
+		if revisit {
+			explanation.WriteString(fmt.Sprintf(" You've been to %s before, so it's a chance to go back and see more.", title))
+			reasons = append(reasons, "A place you've visited before")
+		} else {
+			explanation.WriteString(fmt.Sprintf(" You've recently been to %s, and this adds somewhere new to that list.", joinWithAnd(explainCtx.pastDestinations)))
+			reasons = append(reasons, fmt.Sprintf("Somewhere new after %s", explainCtx.pastDestinations[0]))
+		}
+	}
+	if len(rec.Reasons) > 0 {
+		explanation.WriteString(fmt.Sprintf(" Travelers choose it for: %s.", strings.ToLower(strings.Join(rec.Reasons, ", "))))
+	}
+	if len(reasons) == 0 && rec.Type != "" {
+		reasons = append(reasons, fmt.Sprintf("Popular %s destination", rec.Type))
+	}
+	return explanation.String(), reasons
+}
+
+// joinWithAnd joins items as "a, b and c"
+func joinWithAnd(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExplainRecommendation(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	seed(t, fb, "users/u1", map[string]interface{}{"travel_preferences": map[string]interface{}{"interests": []string{"beaches", "food"}}})
+	seed(t, fb, "trips/goa", map[string]interface{}{"id": "goa", "user_id": "u1", "destination": "Goa", "status": "completed", "start_date": time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)})
+	seed(t, fb, "trips/kerala", map[string]interface{}{"id": "kerala", "user_id": "u1", "destination": "Kerala", "status": "completed", "start_date": time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC)})
+	andaman := map[string]interface{}{"user_id": "u1", "title": "Andaman", "type": "beach", "description": "Quiet beaches and coral reefs"}
+	seed(t, fb, "recommendations/basic", andaman)
+	seed(t, fb, "recommendations/gemini", andaman)
+	seed(t, fb, "recommendations/garbled", andaman)
+	seed(t, fb, "recommendations/theirs", map[string]interface{}{"user_id": "u2", "title": "Ladakh"})
+	seed(t, fb, "recommendations/cached", map[string]interface{}{"user_id": "u1", "title": "Ooty", "explanation": "Explained before."})
+	seed(t, fb, "recommendations/newcomer", map[string]interface{}{"user_id": "u3", "title": "Ladakh", "type": "adventure", "reasons": []string{"Mountain passes"}})
+
+	personalized := "We suggested Andaman because it fits your interest in beaches. You've recently been to Kerala and Goa, and this adds somewhere new to that list."
+	tests := []struct {
+		name             string
+		gemini           AIGenerator
+		userID           string
+		recommendationID string
+		want             string
+		wantReasons      string
+		wantNotFound     bool
+	}{
+		{
+			name: "rule-based", userID: "u1", recommendationID: "basic",
+			want: personalized, wantReasons: "Matches your interest in beaches|Somewhere new after Kerala",
+		},
+		{
+			name:   "gemini",
+			gemini: &fakeGenerator{text: func(string) (string, error) { return "```json\n{\"explanation\": \"You loved Goa's beaches.\", \"reasons\": [\"Beaches\"]}\n```", nil }},
+			userID: "u1", recommendationID: "gemini",
+			want: "You loved Goa's beaches.", wantReasons: "Beaches",
+		},
+		{
+			name:   "unparseable gemini response",
+			gemini: &fakeGenerator{text: func(string) (string, error) { return "Andaman is lovely", nil }},
+			userID: "u1", recommendationID: "garbled",
+			want: personalized, wantReasons: "Matches your interest in beaches|Somewhere new after Kerala",
+		},
+		{
+			name: "no history", userID: "u3", recommendationID: "newcomer",
+			want: "We don't know your past trips or interests yet, so Ladakh is a general suggestion rather than a personalized one. It's popular for: mountain passes. Plan a trip or add your interests to get tailored recommendations.",
+			// Existing reasons are kept
+			wantReasons: "Mountain passes",
+		},
+		{name: "cached", gemini: &fakeGenerator{err: errors.New("not called")}, userID: "u1", recommendationID: "cached", want: "Explained before."},
+		{name: "someone else's", userID: "u1", recommendationID: "theirs", wantNotFound: true},
+		{name: "missing", userID: "u1", recommendationID: "nope", wantNotFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRecommendationService(tt.gemini, fb).ExplainRecommendation(context.Background(), tt.userID, tt.recommendationID)
+			if errors.Is(err, ErrNotFound) != tt.wantNotFound || (err != nil && !tt.wantNotFound) {
+				t.Fatalf("ExplainRecommendation error = %v, want not found %v", err, tt.wantNotFound)
+			}
+			if got != tt.want {
+				t.Errorf("explanation = %q\nwant          %q", got, tt.want)
+			}
+			if err != nil || tt.name == "cached" {
+				return
+			}
+
+			stored := fake.fields("recommendations/" + tt.recommendationID)
+			if stored["explanation"].GetStringValue() != tt.want {
+				t.Errorf("stored explanation = %q, want it cached", stored["explanation"].GetStringValue())
+			}
+			var reasons []string
+			for _, reason := range stored["reasons"].GetArrayValue().GetValues() {
+				reasons = append(reasons, reason.GetStringValue())
+			}
+			if got := strings.Join(reasons, "|"); got != tt.wantReasons {
+				t.Errorf("stored reasons = %s, want %s", got, tt.wantReasons)
+			}
+		})
+	}
+}
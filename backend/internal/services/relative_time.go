@@ -0,0 +1,168 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Plural categories, as defined by the CLDR plural rules
+const (
+	pluralOne   = "one"
+	pluralTwo   = "two"
+	pluralFew   = "few"
+	pluralMany  = "many"
+	pluralOther = "other"
+)
+
+// relativeTimeWords is how a locale says how far away something is. Units
+// map plural categories to a pattern with %d for the count; a missing
+// category falls back to pluralOther.
+type relativeTimeWords struct {
+	now      string
+	overWeek string
+	plural   func(n int) string
+	minutes  map[string]string
+	hours    map[string]string
+	days     map[string]string
+}
+
+// relativeTimeLocales holds relative time words for the supported locales,
+// plus Arabic for its dual and few/many forms
+var relativeTimeLocales = map[string]relativeTimeWords{
+	"en": {
+		now:      "now",
+		overWeek: "in more than a week",
+		plural:   pluralOneOther,
+		minutes:  map[string]string{pluralOne: "in %d minute", pluralOther: "in %d minutes"},
+		hours:    map[string]string{pluralOne: "in %d hour", pluralOther: "in %d hours"},
+		days:     map[string]string{pluralOne: "in %d day", pluralOther: "in %d days"},
+	},
+	"hi": {
+		now:      "अभी",
+		overWeek: "एक सप्ताह से अधिक में",
+		plural:   pluralZeroOneOther,
+		minutes:  map[string]string{pluralOther: "%d मिनट में"},
+		hours:    map[string]string{pluralOther: "%d घंटे में"},
+		days:     map[string]string{pluralOther: "%d दिन में"},
+	},
+	"bn": {
+		now:      "এখনই",
+		overWeek: "এক সপ্তাহেরও বেশি পরে",
+		plural:   pluralZeroOneOther,
+		minutes:  map[string]string{pluralOther: "%d মিনিটের মধ্যে"},
+		hours:    map[string]string{pluralOther: "%d ঘণ্টার মধ্যে"},
+		days:     map[string]string{pluralOther: "%d দিনের মধ্যে"},
+	},
+	"ta": {
+		now:      "இப்போது",
+		overWeek: "ஒரு வாரத்திற்கும் மேல்",
+		plural:   pluralOneOther,
+		minutes:  map[string]string{pluralOne: "%d நிமிடத்தில்", pluralOther: "%d நிமிடங்களில்"},
+		hours:    map[string]string{pluralOther: "%d மணிநேரத்தில்"},
+		days:     map[string]string{pluralOne: "%d நாளில்", pluralOther: "%d நாட்களில்"},
+	},
+	"mr": {
+		now:      "आत्ता",
+		overWeek: "एका आठवड्यापेक्षा जास्त काळात",
+		plural:   pluralOneOther,
+		minutes:  map[string]string{pluralOne: "%d मिनिटामध्ये", pluralOther: "%d मिनिटांमध्ये"},
+		hours:    map[string]string{pluralOne: "%d तासामध्ये", pluralOther: "%d तासांमध्ये"},
+		days:     map[string]string{pluralOne: "%d दिवसामध्ये", pluralOther: "%d दिवसांमध्ये"},
+	},
+	"ar": {
+		now:      "الآن",
+		overWeek: "في أكثر من أسبوع",
+		plural:   pluralArabic,
+		minutes: map[string]string{
+			pluralOne: "في دقيقة واحدة", pluralTwo: "في دقيقتين",
+			pluralFew: "في %d دقائق", pluralMany: "في %d دقيقة", pluralOther: "في %d دقيقة",
+		},
+		hours: map[string]string{
+			pluralOne: "في ساعة واحدة", pluralTwo: "في ساعتين",
+			pluralFew: "في %d ساعات", pluralMany: "في %d ساعة", pluralOther: "في %d ساعة",
+		},
+		days: map[string]string{
+			pluralOne: "في يوم واحد", pluralTwo: "في يومين",
+			pluralFew: "في %d أيام", pluralMany: "في %d يومًا", pluralOther: "في %d يوم",
+		},
+	},
+}
+
+// pluralOneOther is the rule of English, Tamil and Marathi: 1 is singular
+func pluralOneOther(n int) string {
+	if n == 1 {
+		return pluralOne
+	}
+	return pluralOther
+}
+
+// pluralZeroOneOther is the rule of Hindi and Bengali: 0 and 1 are singular
+func pluralZeroOneOther(n int) string {
+	if n == 0 || n == 1 {
+		return pluralOne
+	}
+	return pluralOther
+}
+
+// pluralArabic is Arabic's rule, with a dual and separate forms for 3-10 and
+// 11-99 (modulo 100)
+func pluralArabic(n int) string {
+	switch mod := n % 100; {
+	case n == 1:
+		return pluralOne
+	case n == 2:
+		return pluralTwo
+	case mod >= 3 && mod <= 10:
+		return pluralFew
+	case mod >= 11 && mod <= 99:
+		return pluralMany
+	default:
+		return pluralOther
+	}
+}
+
+// FormatRelativeDuration says how long until something d from now happens,
+// like "in 2 days" or "2 दिन में", in the locale's words and plural forms.
+// Under a minute (or already past) is "now"; over a week is "in more than a
+// week". Locales without relative time words fall back to English.
+func (l *LocalizationService) FormatRelativeDuration(d time.Duration, locale string) string {
+	return formatRelativeDuration(d, locale)
+}
+
+func formatRelativeDuration(d time.Duration, locale string) string {
+	words, ok := relativeTimeWords{}, false
+	for _, candidate := range localeFallbackChain(locale) {
+		if words, ok = relativeTimeLocales[candidate]; ok {
+			break
+		}
+	}
+	if !ok {
+		words = relativeTimeLocales["en"]
+	}
+
+	var count int
+	var unit map[string]string
+	switch {
+	case d < time.Minute:
+		return words.now
+	case d > 7*24*time.Hour:
+		return words.overWeek
+	case d >= 24*time.Hour:
+		count, unit = int(d/(24*time.Hour)), words.days
+	case d >= time.Hour:
+		count, unit = int(d/time.Hour), words.hours
+	default:
+		count, unit = int(d/time.Minute), words.minutes
+	}
+
+	pattern, ok := unit[words.plural(count)]
+	if !ok {
+		pattern = unit[pluralOther]
+	}
+	// Arabic's singular and dual forms carry the count in the word itself
+	if !strings.Contains(pattern, "%d") {
+		return pattern
+	}
+	return fmt.Sprintf(pattern, count)
+}
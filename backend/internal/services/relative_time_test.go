@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatRelativeDuration(t *testing.T) {
+	tests := []struct {
+		locale string
+		d      time.Duration
+		want   string
+	}{
+		{"en", 30 * time.Second, "now"},
+		{"en", -time.Hour, "now"},
+		{"en", time.Minute, "in 1 minute"},
+		{"en", 2 * 24 * time.Hour, "in 2 days"},
+		{"en", 8 * 24 * time.Hour, "in more than a week"},
+		{"hi", 2 * 24 * time.Hour, "2 दिन में"},
+		{"hi-IN", 3 * time.Hour, "3 घंटे में"},
+		{"ta", 24 * time.Hour, "1 நாளில்"},
+		{"ta", 5 * 24 * time.Hour, "5 நாட்களில்"},
+		{"ar", 2 * 24 * time.Hour, "في يومين"},
+		{"ar", 5 * time.Hour, "في 5 ساعات"},
+		{"fr", 2 * time.Hour, "in 2 hours"},
+	}
+	for _, tt := range tests {
+		if got := formatRelativeDuration(tt.d, tt.locale); got != tt.want {
+			t.Errorf("formatRelativeDuration(%v, %s) = %q, want %q", tt.d, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestTripReminderIsLocalized(t *testing.T) {
+	n := &NotificationService{}
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "Your trip starts in 2 days. Have a great journey!"},
+		{"hi", "आपकी यात्रा 2 दिन में शुरू होगी। आपकी यात्रा मंगलमय हो!"},
+		{"mr-IN", "तुमचा प्रवास 2 दिवसांमध्ये सुरू होईल. प्रवासासाठी शुभेच्छा!"},
+		{"de", "Your trip starts in 2 days. Have a great journey!"},
+	}
+	for _, tt := range tests {
+		if got := n.getTripReminderBody("departure", 2*24*time.Hour, tt.locale); got != tt.want {
+			t.Errorf("getTripReminderBody(%s) = %q, want %q", tt.locale, got, tt.want)
+		}
+	}
+
+	req := &NotificationRequest{
+		Type:  TripReminder,
+		Title: "Trip Departure Reminder",
+		Body:  n.getTripReminderBody("departure", 26*time.Hour, "bn"),
+	}
+	localized := n.localizeNotification(req, "bn")
+	if localized.Title != "ভ্রমণ অনুস্মারক" || localized.Body != "আপনার ভ্রমণ 1 দিনের মধ্যে শুরু হবে। শুভ যাত্রা!" {
+		t.Errorf("localized reminder = %q / %q", localized.Title, localized.Body)
+	}
+}
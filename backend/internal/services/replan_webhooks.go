@@ -0,0 +1,440 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/metrics"
+	"auratravel-backend/internal/tracing"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"google.golang.org/api/iterator"
+)
+
+// Webhook deliveries are signed so receivers can check they came from
+// AuraTravel and weren't altered or replayed:
+//
+//	X-AuraTravel-Event:     itinerary.replanned
+//	X-AuraTravel-Delivery:  unique ID, the same across retries of one event
+//	X-AuraTravel-Signature: t=<unix seconds>,v1=<hex signature>
+//
+// The signature is HMAC-SHA256, keyed with the webhook's secret, over the
+// timestamp, a ".", and the raw request body. Receivers should recompute it,
+// compare in constant time and reject timestamps more than a few minutes old;
+// VerifyWebhookSignature does all three. The body is a WebhookEvent whose
+// data is the ReplanningResult.
+const (
+	WebhookEventHeader     = "X-AuraTravel-Event"
+	WebhookDeliveryHeader  = "X-AuraTravel-Delivery"
+	WebhookSignatureHeader = "X-AuraTravel-Signature"
+
+	// WebhookEventReplanned is sent after replanning changes an itinerary
+	WebhookEventReplanned = "itinerary.replanned"
+)
+
+const (
+	// maxWebhookAttempts is how many deliveries are tried before the event is
+	// written to the dead-letter collection
+	maxWebhookAttempts = 5
+	// webhookBaseBackoff is the delay after the first failure, doubled for
+	// every further attempt
+	webhookBaseBackoff = time.Second
+	// webhookTimeout bounds a single delivery attempt
+	webhookTimeout = 10 * time.Second
+	// webhookResolveTimeout bounds resolving a webhook's host when it's
+	// registered
+	webhookResolveTimeout = 5 * time.Second
+	// DefaultWebhookTolerance is how old a signature timestamp may be
+	DefaultWebhookTolerance = 5 * time.Minute
+)
+
+var (
+	// ErrWebhookSignature is returned for missing, malformed or wrong
+	// signatures
+	ErrWebhookSignature = errors.New("invalid webhook signature")
+	// ErrWebhookTimestamp is returned for signatures outside the tolerance
+	ErrWebhookTimestamp = errors.New("webhook timestamp outside tolerance")
+)
+
+// WebhookConfig is a receiver registered by a user. Without a TripID it
+// receives events for all of the user's trips.
+type WebhookConfig struct {
+	ID        string    `json:"id" firestore:"id"`
+	UserID    string    `json:"user_id" firestore:"user_id"`
+	TripID    string    `json:"trip_id,omitempty" firestore:"trip_id"`
+	URL       string    `json:"url" firestore:"url"`
+	Secret    string    `json:"-" firestore:"secret"`
+	Active    bool      `json:"active" firestore:"active"`
+	CreatedAt time.Time `json:"created_at" firestore:"created_at"`
+}
+
+// WebhookEvent is the body of every webhook delivery
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookDeadLetter records an event that could not be delivered
+type webhookDeadLetter struct {
+	WebhookID string    `firestore:"webhook_id"`
+	UserID    string    `firestore:"user_id"`
+	TripID    string    `firestore:"trip_id"`
+	URL       string    `firestore:"url"`
+	EventID   string    `firestore:"event_id"`
+	EventType string    `firestore:"event_type"`
+	Payload   string    `firestore:"payload"`
+	Attempts  int       `firestore:"attempts"`
+	LastError string    `firestore:"last_error"`
+	FailedAt  time.Time `firestore:"failed_at"`
+}
+
+// SignWebhookPayload returns the X-AuraTravel-Signature value for body
+func SignWebhookPayload(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, webhookSignature(secret, ts, body))
+}
+
+// VerifyWebhookSignature checks an X-AuraTravel-Signature header against the
+// raw request body. Timestamps further than tolerance from now are rejected.
+func VerifyWebhookSignature(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			signature = value
+		}
+	}
+	if ts == "" || signature == "" {
+		return ErrWebhookSignature
+	}
+
+	expected := webhookSignature(secret, ts, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrWebhookSignature
+	}
+
+	seconds, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrWebhookSignature
+	}
+	age := now.Sub(time.Unix(seconds, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrWebhookTimestamp
+	}
+	return nil
+}
+
+func webhookSignature(secret, ts string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateWebhookURL requires an absolute https URL, or http when allowHTTP
+// is set for local development, whose host resolves only to public
+// addresses. Deliveries check the address again as they connect, since DNS
+// can change after registration.
+func ValidateWebhookURL(ctx context.Context, rawURL string, allowHTTP bool) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return newKindError(ErrValidation, "url must be an absolute URL")
+	}
+	if parsed.Scheme != "https" && !(allowHTTP && parsed.Scheme == "http") {
+		return newKindError(ErrValidation, "url must use https")
+	}
+
+	host := parsed.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !httpclient.PublicAddress(addr) {
+			return newKindError(ErrValidation, "url must point to a public address")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return kindErrorf(ErrValidation, "url host %s could not be resolved", host)
+	}
+	for _, addr := range addrs {
+		if !httpclient.PublicAddress(addr) {
+			return newKindError(ErrValidation, "url must point to a public address")
+		}
+	}
+	return nil
+}
+
+// RegisterWebhook stores a new webhook for userID, optionally limited to one
+// trip, and returns it with its generated secret. The secret is only
+// returned here.
+func (d *DynamicReplanningService) RegisterWebhook(ctx context.Context, userID, tripID, webhookURL string) (*WebhookConfig, error) {
+	if d.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	webhook := &WebhookConfig{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		TripID:    tripID,
+		URL:       webhookURL,
+		Secret:    "whsec_" + hex.EncodeToString(secret),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+	_, err := d.firebase.GetFirestoreClient().
+		Collection("replan_webhooks").
+		Doc(webhook.ID).
+		Set(ctx, webhook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save webhook: %w", err)
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns the user's registered webhooks
+func (d *DynamicReplanningService) ListWebhooks(ctx context.Context, userID string) ([]*WebhookConfig, error) {
+	if d.firebase == nil {
+		return nil, newKindError(ErrUnavailable, "firebase service not available")
+	}
+	return d.queryWebhooks(ctx, d.firebase.GetFirestoreClient().
+		Collection("replan_webhooks").
+		Where("user_id", "==", userID))
+}
+
+// DeleteWebhook removes one of the user's webhooks. It reports false when the
+// webhook doesn't exist or belongs to someone else.
+func (d *DynamicReplanningService) DeleteWebhook(ctx context.Context, userID, webhookID string) (bool, error) {
+	if d.firebase == nil {
+		return false, newKindError(ErrUnavailable, "firebase service not available")
+	}
+
+	ref := d.firebase.GetFirestoreClient().Collection("replan_webhooks").Doc(webhookID)
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return false, nil
+	}
+	var webhook WebhookConfig
+	if err := doc.DataTo(&webhook); err != nil || webhook.UserID != userID {
+		return false, nil
+	}
+	if _, err := ref.Delete(ctx); err != nil {
+		return false, fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	return true, nil
+}
+
+func (d *DynamicReplanningService) queryWebhooks(ctx context.Context, query firestore.Query) ([]*WebhookConfig, error) {
+	var webhooks []*WebhookConfig
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list webhooks: %w", err)
+		}
+		var webhook WebhookConfig
+		if err := doc.DataTo(&webhook); err != nil {
+			continue
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	return webhooks, nil
+}
+
+// webhooksForTrip returns the active webhooks registered for the trip or for
+// all of its owner's trips
+func (d *DynamicReplanningService) webhooksForTrip(ctx context.Context, userID, tripID string) ([]*WebhookConfig, error) {
+	webhooks, err := d.queryWebhooks(ctx, d.firebase.GetFirestoreClient().
+		Collection("replan_webhooks").
+		Where("trip_id", "==", tripID))
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" {
+		userWebhooks, err := d.queryWebhooks(ctx, d.firebase.GetFirestoreClient().
+			Collection("replan_webhooks").
+			Where("user_id", "==", userID).
+			Where("trip_id", "==", ""))
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, userWebhooks...)
+	}
+
+	active := webhooks[:0]
+	for _, webhook := range webhooks {
+		if webhook.Active {
+			active = append(active, webhook)
+		}
+	}
+	return active, nil
+}
+
+// dispatchReplanWebhooks sends the replan webhooks in the background, so
+// slow receivers don't hold up the monitor. Deliveries outlive the monitor
+// that started them, and Shutdown waits for them.
+func (d *DynamicReplanningService) dispatchReplanWebhooks(ctx context.Context, userID string, result *ReplanningResult) {
+	d.webhooksWG.Add(1)
+	go func() {
+		defer d.webhooksWG.Done()
+		d.sendReplanWebhooks(context.WithoutCancel(ctx), userID, result)
+	}()
+}
+
+// sendReplanWebhooks posts the result to every webhook registered for the
+// trip. Each delivery is retried with exponential backoff and dead-lettered
+// after maxWebhookAttempts.
+func (d *DynamicReplanningService) sendReplanWebhooks(ctx context.Context, userID string, result *ReplanningResult) {
+	if d.firebase == nil {
+		return
+	}
+
+	webhooks, err := d.webhooksForTrip(ctx, userID, result.TripID)
+	if err != nil {
+		log.Printf("Failed to load webhooks for trip %s: %v", result.TripID, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	event := WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      WebhookEventReplanned,
+		CreatedAt: time.Now(),
+		Data:      result,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode webhook event for trip %s: %v", result.TripID, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		attempts, err := d.deliverWebhook(ctx, webhook, event, body)
+		if err == nil {
+			continue
+		}
+		log.Printf("Webhook %s for trip %s failed after %d attempts: %v", webhook.ID, result.TripID, attempts, err)
+		d.deadLetterWebhook(ctx, webhook, event, body, attempts, err)
+	}
+}
+
+// deliverWebhook posts body until the receiver returns 2xx, the response is
+// a non-retryable 4xx, or attempts run out. It returns the attempts made.
+func (d *DynamicReplanningService) deliverWebhook(ctx context.Context, webhook *WebhookConfig, event WebhookEvent, body []byte) (int, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxWebhookAttempts; attempt++ {
+		retryable, err := d.postWebhook(ctx, webhook, event, body)
+		if err == nil {
+			return attempt, nil
+		}
+		lastErr = err
+		if !retryable || attempt == maxWebhookAttempts {
+			return attempt, lastErr
+		}
+
+		select {
+		case <-time.After(webhookBaseBackoff << uint(attempt-1)):
+		case <-ctx.Done():
+			return attempt, fmt.Errorf("%w (stopped retrying: %v)", lastErr, ctx.Err())
+		}
+	}
+	return maxWebhookAttempts, lastErr
+}
+
+// postWebhook makes one signed delivery attempt and reports whether a
+// failure is worth retrying
+func (d *DynamicReplanningService) postWebhook(ctx context.Context, webhook *WebhookConfig, event WebhookEvent, body []byte) (retryable bool, err error) {
+	ctx, span := tracing.Start(ctx, "webhook.deliver", tracing.KindClient, tracing.Attr("event", event.Type))
+	start := time.Now()
+	defer func() {
+		span.EndWithError(err)
+		metrics.ObserveExternalCall("webhook", start, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AuraTravel-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, event.Type)
+	req.Header.Set(WebhookDeliveryHeader, event.ID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, time.Now(), body))
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	span.SetAttributes(tracing.Attr("http.status_code", resp.StatusCode))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+}
+
+func (d *DynamicReplanningService) deadLetterWebhook(ctx context.Context, webhook *WebhookConfig, event WebhookEvent, body []byte, attempts int, deliveryErr error) {
+	_, err := d.firebase.GetFirestoreClient().
+		Collection("replan_webhooks_dead_letter").
+		Doc(event.ID+"_"+webhook.ID).
+		Set(ctx, webhookDeadLetter{
+			WebhookID: webhook.ID,
+			UserID:    webhook.UserID,
+			TripID:    webhook.TripID,
+			URL:       webhook.URL,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   string(body),
+			Attempts:  attempts,
+			LastError: deliveryErr.Error(),
+			FailedAt:  time.Now(),
+		})
+	if err != nil {
+		log.Printf("Failed to dead-letter webhook event %s: %v", event.ID, err)
+	}
+}
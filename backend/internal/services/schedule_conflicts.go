@@ -0,0 +1,157 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Conflict severities, by how long the overlap is
+const (
+	ConflictMinor    = "minor"    // under 15 minutes
+	ConflictModerate = "moderate" // 15 minutes to an hour
+	ConflictMajor    = "major"    // an hour or more
+)
+
+// defaultActivityDuration is assumed for activities without an end time
+const defaultActivityDuration = time.Hour
+
+// mealDurations are how long each type of meal is assumed to take
+var mealDurations = map[string]time.Duration{
+	"breakfast": 45 * time.Minute,
+	"lunch":     time.Hour,
+	"dinner":    90 * time.Minute,
+	"snack":     20 * time.Minute,
+}
+
+// Conflict is a pair of a day's activities or meals that can't both happen
+// as scheduled: the second starts before the first ends, or before there is
+// time to travel between them
+type Conflict struct {
+	First          string    `json:"first"`
+	Second         string    `json:"second"`
+	FirstEnds      time.Time `json:"first_ends"`
+	SecondStarts   time.Time `json:"second_starts"`
+	TravelMinutes  int       `json:"travel_minutes"`
+	OverlapMinutes int       `json:"overlap_minutes"`
+	Severity       string    `json:"severity"`
+	Message        string    `json:"message"`
+}
+
+// scheduledItem is an activity or meal with its time window
+type scheduledItem struct {
+	name     string
+	start    time.Time
+	end      time.Time
+	location Location
+}
+
+// DetectScheduleConflicts flags activities and meals whose time windows
+// overlap once the travel time between their locations is added. Activities
+// without an end time are assumed to take an hour, and meals take a typical
+// time for their type; items without a start time are ignored. Conflicts
+// are ordered by overlap, longest first.
+func DetectScheduleConflicts(day DayItinerary) []Conflict {
+	items := scheduledItems(day)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].start.Before(items[j].start)
+	})
+
+	var conflicts []Conflict
+	for i, first := range items {
+		for _, second := range items[i+1:] {
+			var travel time.Duration
+			if hasLatLng(first.location) && hasLatLng(second.location) {
+				travel = estimateTravelTime(first.location, second.location).Round(time.Minute)
+			}
+			overlap := first.end.Add(travel).Sub(second.start)
+			if overlap <= 0 {
+				continue
+			}
+			conflicts = append(conflicts, newConflict(first, second, travel, overlap))
+		}
+	}
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].OverlapMinutes > conflicts[j].OverlapMinutes
+	})
+	return conflicts
+}
+
+func newConflict(first, second scheduledItem, travel, overlap time.Duration) Conflict {
+	conflict := Conflict{
+		First:          first.name,
+		Second:         second.name,
+		FirstEnds:      first.end,
+		SecondStarts:   second.start,
+		TravelMinutes:  int(travel.Minutes()),
+		OverlapMinutes: int(overlap.Round(time.Minute).Minutes()),
+	}
+	if conflict.OverlapMinutes < 1 {
+		conflict.OverlapMinutes = 1
+	}
+
+	switch {
+	case overlap >= time.Hour:
+		conflict.Severity = ConflictMajor
+	case overlap >= 15*time.Minute:
+		conflict.Severity = ConflictModerate
+	default:
+		conflict.Severity = ConflictMinor
+	}
+
+	if first.end.After(second.start) {
+		conflict.Message = fmt.Sprintf("%s runs until %s, after %s starts at %s",
+			first.name, first.end.Format("3:04 PM"), second.name, second.start.Format("3:04 PM"))
+	} else {
+		conflict.Message = fmt.Sprintf("%s ends at %s, leaving too little time for the %d-minute trip to %s at %s",
+			first.name, first.end.Format("3:04 PM"), conflict.TravelMinutes, second.name, second.start.Format("3:04 PM"))
+	}
+	return conflict
+}
+
+// scheduledItems lists a day's timed activities and meals
+func scheduledItems(day DayItinerary) []scheduledItem {
+	var items []scheduledItem
+	for _, slot := range [][]Activity{day.Morning, day.Afternoon, day.Evening} {
+		for _, activity := range slot {
+			if activity.StartTime.IsZero() {
+				continue
+			}
+			end := activity.EndTime
+			if !end.After(activity.StartTime) {
+				end = activity.StartTime.Add(defaultActivityDuration)
+			}
+			items = append(items, scheduledItem{
+				name:     activity.Name,
+				start:    activity.StartTime,
+				end:      end,
+				location: activity.Location,
+			})
+		}
+	}
+
+	for _, meal := range day.Meals {
+		if meal.Time.IsZero() {
+			continue
+		}
+		duration, ok := mealDurations[strings.ToLower(meal.Type)]
+		if !ok {
+			duration = time.Hour
+		}
+		name := meal.Restaurant
+		if name == "" {
+			name = meal.Type
+		} else if meal.Type != "" {
+			name = fmt.Sprintf("%s at %s", strings.ToLower(meal.Type), meal.Restaurant)
+		}
+		items = append(items, scheduledItem{
+			name:     name,
+			start:    meal.Time,
+			end:      meal.Time.Add(duration),
+			location: meal.Location,
+		})
+	}
+	return items
+}
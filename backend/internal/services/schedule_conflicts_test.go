@@ -0,0 +1,98 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a time of day on a fixed date
+func clock(hour, minute int) time.Time {
+	return time.Date(2026, 6, 1, hour, minute, 0, 0, time.UTC)
+}
+
+func TestDetectScheduleConflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		day  DayItinerary
+		want string
+	}{
+		{
+			name: "back to back at one place",
+			day: DayItinerary{Morning: []Activity{
+				{Name: "Fort", StartTime: clock(9, 0), EndTime: clock(10, 0), Location: at(77.2)},
+				{Name: "Museum", StartTime: clock(10, 0), Location: at(77.2)},
+			}},
+		},
+		{
+			name: "overlaps ranked longest first",
+			day: DayItinerary{Morning: []Activity{
+				{Name: "Fort", StartTime: clock(9, 0), EndTime: clock(11, 30)},
+				{Name: "Museum", StartTime: clock(10, 0), EndTime: clock(10, 30)},
+				{Name: "Bazaar", StartTime: clock(11, 20)},
+			}},
+			want: "Fort>Museum major 90, Fort>Bazaar minor 10",
+		},
+		{
+			name: "missing end time assumes an hour",
+			day: DayItinerary{Morning: []Activity{
+				{Name: "Fort", StartTime: clock(9, 0)},
+				{Name: "Museum", StartTime: clock(9, 40)},
+			}},
+			want: "Fort>Museum moderate 20",
+		},
+		{
+			name: "travel time between places",
+			day: DayItinerary{Afternoon: []Activity{
+				{Name: "Fort", StartTime: clock(14, 0), EndTime: clock(15, 0), Location: at(77.2)},
+				{Name: "Temple", StartTime: clock(15, 10), Location: at(77.3)},
+			}},
+			want: "Fort>Temple minor 13",
+		},
+		{
+			name: "meals take their typical time",
+			day: DayItinerary{
+				Evening: []Activity{{Name: "Light show", StartTime: clock(20, 0)}},
+				Meals:   []Meal{{Type: "Dinner", Restaurant: "Spice Route", Time: clock(19, 0)}, {Type: "snack", Time: clock(16, 0)}},
+			},
+			want: "dinner at Spice Route>Light show moderate 30",
+		},
+		{
+			name: "untimed items ignored",
+			day: DayItinerary{
+				Morning: []Activity{{Name: "Fort"}, {Name: "Museum"}},
+				Meals:   []Meal{{Type: "lunch"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflicts := DetectScheduleConflicts(tt.day)
+			got := make([]string, len(conflicts))
+			for i, conflict := range conflicts {
+				got[i] = fmt.Sprintf("%s>%s %s %d", conflict.First, conflict.Second, conflict.Severity, conflict.OverlapMinutes)
+			}
+			if strings.Join(got, ", ") != tt.want {
+				t.Errorf("conflicts = %q, want %q", strings.Join(got, ", "), tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleConflictMessage(t *testing.T) {
+	tests := []struct {
+		second Activity
+		want   string
+	}{
+		{Activity{Name: "Museum", StartTime: clock(9, 30), Location: at(77.2)}, "Fort runs until 10:00 AM, after Museum starts at 9:30 AM"},
+		{Activity{Name: "Temple", StartTime: clock(10, 10), Location: at(77.3)}, "Fort ends at 10:00 AM, leaving too little time for the 23-minute trip to Temple at 10:10 AM"},
+	}
+	for _, tt := range tests {
+		day := DayItinerary{Morning: []Activity{{Name: "Fort", StartTime: clock(9, 0), EndTime: clock(10, 0), Location: at(77.2)}, tt.second}}
+		conflicts := DetectScheduleConflicts(day)
+		if len(conflicts) != 1 || conflicts[0].Message != tt.want {
+			t.Errorf("conflicts with %s = %+v, want one saying %q", tt.second.Name, conflicts, tt.want)
+		}
+	}
+}
@@ -3,6 +3,10 @@ package services
 import (
 	"context"
 	"log"
+	"strings"
+
+	"auratravel-backend/internal/cache"
+	"auratravel-backend/internal/config"
 )
 
 // Services holds all service instances
@@ -17,23 +21,38 @@ type Services struct {
 	DataConnector    *DataSourceConnector
 	CostPredictor    *TravelCostPredictor
 	EmbeddingService *EmbeddingService
+	Cache            cache.Cache
+	ExchangeRates    ExchangeRateProvider
 
 	// New real-time services
 	DynamicReplanningService *DynamicReplanningService
 	NotificationService      *NotificationService
 	ItineraryDeliveryService *ItineraryDeliveryService
 	LocalizationService      *LocalizationService
+	RecommendationService    *RecommendationService
+	CollaborationService     *CollaborationService
+	AnalyticsService         *AnalyticsService
+
+	DestinationSuggestService *DestinationSuggestService
+	NearbyService             *NearbyService
+	BookingService            *BookingService
+	GenerationPipeline        *ItineraryGenerationPipeline
+	BudgetCutService          *BudgetCutService
+	BudgetTracker             *BudgetTracker
 }
 
 // NewServices initializes and returns all services
 func NewServices() (*Services, error) {
+	// Shared cache for expensive AI and data source results
+	appCache := cache.New(config.GetConfig())
+
 	// Initialize Google AI services
-	geminiService, err := NewGeminiService()
+	geminiService, err := NewGeminiService(appCache)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize Gemini service: %v", err)
 	}
 
-	vertexService, err := NewVertexService()
+	vertexService, err := NewVertexService(appCache)
 	if err != nil {
 		log.Printf("Warning: Failed to initialize Vertex AI service: %v", err)
 	}
@@ -60,12 +79,16 @@ func NewServices() (*Services, error) {
 	var vectorDB *VectorDatabase
 	if firebaseService != nil && geminiService != nil {
 		vectorDB = NewVectorDatabase(firebaseService.GetFirestoreClient(), geminiService)
+		dataConnector.SetVectorDatabase(vectorDB)
 	}
 
 	// Initialize RAG Retriever
 	var ragRetriever *RAGRetriever
 	if firebaseService != nil && geminiService != nil && visionService != nil {
-		ragRetriever = NewRAGRetriever(firebaseService, geminiService, visionService, "", "")
+		ragRetriever = NewRAGRetriever(firebaseService, geminiService, visionService, "", "", appCache)
+		if vectorDB != nil {
+			ragRetriever.SetVectorDatabase(vectorDB)
+		}
 	}
 
 	// Initialize Cost Predictor
@@ -77,13 +100,21 @@ func NewServices() (*Services, error) {
 		log.Printf("Warning: Failed to initialize Embedding service: %v", err)
 	}
 
+	zoneResolver := NewZoneResolver(config.GetConfig().GoogleMapsAPIKey, appCache)
+
 	// Initialize new real-time services
 	var localizationService *LocalizationService
 	if geminiService != nil && firebaseService != nil {
 		localizationService = NewLocalizationService(geminiService, firebaseService)
+		localizationService.SetZoneResolver(zoneResolver)
 		log.Println("Localization service initialized")
 	}
 
+	var recommendationService *RecommendationService
+	if firebaseService != nil {
+		recommendationService = NewRecommendationService(geminiService, firebaseService)
+	}
+
 	var notificationService *NotificationService
 	if firebaseService != nil {
 		notificationService, err = NewNotificationService(firebaseService)
@@ -94,19 +125,41 @@ func NewServices() (*Services, error) {
 		}
 	}
 
+	var collaborationService *CollaborationService
+	if firebaseService != nil {
+		collaborationService = NewCollaborationService(firebaseService, notificationService)
+	}
+
+	var analyticsService *AnalyticsService
+	if firebaseService != nil {
+		analyticsService = NewAnalyticsService(firebaseService, bigQueryService)
+	}
+
+	var destinationSuggestService *DestinationSuggestService
+	if firebaseService != nil {
+		destinationSuggestService = NewDestinationSuggestService(firebaseService, bigQueryService)
+	}
+
 	var itineraryDeliveryService *ItineraryDeliveryService
 	if firebaseService != nil {
-		// Initialize with default configs (these would come from environment variables in production)
+		cfg := config.GetConfig()
 		emailConfig := &EmailConfig{
-			SMTPHost:  "smtp.gmail.com",
-			SMTPPort:  587,
-			Username:  "", // Would be loaded from env
-			Password:  "", // Would be loaded from env
-			FromEmail: "noreply@auratravel.ai",
-			FromName:  "AuraTravel",
-			Enabled:   true,
+			SMTPHost:           cfg.SMTPHost,
+			SMTPPort:           cfg.SMTPPort,
+			Username:           cfg.SMTPUsername,
+			Password:           cfg.SMTPPassword,
+			FromEmail:          cfg.SMTPFromEmail,
+			FromName:           "AuraTravel",
+			Enabled:            true,
+			TLSMode:            cfg.SMTPTLSMode,
+			AuthMethod:         cfg.SMTPAuthMethod,
+			InsecureSkipVerify: cfg.SMTPInsecureSkipVerify,
+		}
+		if token := cfg.SMTPOAuth2Token; token != "" {
+			emailConfig.OAuth2Token = StaticOAuth2Token(token)
 		}
 
+		// SMS and storage use defaults (these would come from environment variables in production)
 		smsConfig := &SMSConfig{
 			TwilioAccountSID:  "", // Would be loaded from env
 			TwilioAuthToken:   "", // Would be loaded from env
@@ -122,17 +175,52 @@ func NewServices() (*Services, error) {
 		}
 
 		itineraryDeliveryService = NewItineraryDeliveryService(emailConfig, smsConfig, storageConfig, firebaseService)
+		itineraryDeliveryService.SetLocalization(localizationService)
+		itineraryDeliveryService.SetGemini(geminiService)
+		itineraryDeliveryService.SetBundleConfig(&BundleConfig{
+			MapsAPIKey: config.GetConfig().GoogleMapsAPIKey,
+			MaxBytes:   config.GetConfig().OfflineBundleMaxMB << 20,
+		})
 		log.Println("Itinerary delivery service initialized")
 	}
 
 	var dynamicReplanningService *DynamicReplanningService
 	if ragRetriever != nil && geminiService != nil && vectorDB != nil && firebaseService != nil && notificationService != nil {
-		dynamicReplanningService = NewDynamicReplanningService(ragRetriever, geminiService, vectorDB, firebaseService, notificationService, "default")
+		dynamicReplanningService = NewDynamicReplanningService(ragRetriever, geminiService, vectorDB, firebaseService, notificationService, localizationService, "default")
 		log.Println("Dynamic replanning service initialized")
 	}
 
+	var bookingService *BookingService
+	if name := config.GetConfig().BookingProvider; firebaseService != nil && name != "" {
+		if provider, err := NewBookingProvider(name); err != nil {
+			log.Printf("Booking service disabled: %v", err)
+		} else {
+			bookingService = NewBookingService(firebaseService, notificationService)
+			for _, kind := range []string{BookingHotel, BookingTransport, BookingActivity} {
+				bookingService.SetProvider(kind, provider)
+			}
+			log.Printf("Booking service initialized with the %s provider", name)
+		}
+	}
+
+	var budgetCutService *BudgetCutService
+	if firebaseService != nil && vectorDB != nil {
+		budgetCutService = NewBudgetCutService(firebaseService, vectorDB)
+	}
+
+	var budgetTracker *BudgetTracker
+	if firebaseService != nil {
+		budgetTracker = NewBudgetTracker(firebaseService, notificationService, DefaultExchangeRates(), config.GetConfig().BudgetAlertThreshold)
+	}
+
+	generationPipeline := NewGenerationPipelineFromOrder(strings.Split(config.GetConfig().GenerationOrder, ","), ragRetriever, geminiService)
+	log.Printf("Itinerary generation order: %s", strings.Join(generationPipeline.Strategies(), ", "))
+
 	log.Println("All services initialized successfully")
 
+	nearbyService := NewNearbyService(vectorDB, dataConnector)
+	nearbyService.SetZoneResolver(zoneResolver)
+
 	return &Services{
 		Gemini:                   geminiService,
 		Vertex:                   vertexService,
@@ -144,10 +232,22 @@ func NewServices() (*Services, error) {
 		DataConnector:            dataConnector,
 		CostPredictor:            costPredictor,
 		EmbeddingService:         embeddingService,
+		Cache:                    appCache,
+		ExchangeRates:            DefaultExchangeRates(),
 		DynamicReplanningService: dynamicReplanningService,
 		NotificationService:      notificationService,
 		ItineraryDeliveryService: itineraryDeliveryService,
 		LocalizationService:      localizationService,
+		RecommendationService:    recommendationService,
+		CollaborationService:     collaborationService,
+		AnalyticsService:         analyticsService,
+
+		DestinationSuggestService: destinationSuggestService,
+		NearbyService:             nearbyService,
+		BookingService:            bookingService,
+		GenerationPipeline:        generationPipeline,
+		BudgetCutService:          budgetCutService,
+		BudgetTracker:             budgetTracker,
 	}, nil
 }
 
@@ -155,6 +255,30 @@ func NewServices() (*Services, error) {
 func (s *Services) Shutdown(ctx context.Context) error {
 	var lastError error
 
+	// Stop background work first so it doesn't call services being shut down
+	if s.DynamicReplanningService != nil {
+		if err := s.DynamicReplanningService.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down dynamic replanning service: %v", err)
+			lastError = err
+		}
+	}
+
+	if s.NotificationService != nil {
+		if err := s.NotificationService.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down notification service: %v", err)
+			lastError = err
+		}
+	}
+
+	// Flush queued analytics events before the stores they're written to
+	// are closed
+	if s.AnalyticsService != nil {
+		if err := s.AnalyticsService.Shutdown(ctx); err != nil {
+			log.Printf("Error shutting down analytics service: %v", err)
+			lastError = err
+		}
+	}
+
 	// Shutdown AI services
 	if s.Gemini != nil {
 		if err := s.Gemini.Shutdown(ctx); err != nil {
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SlotWeights are how well an attraction category suits each time of day,
+// from 0 (avoid) to 1 (ideal)
+type SlotWeights struct {
+	Morning   float64 `json:"morning"`
+	Afternoon float64 `json:"afternoon"`
+	Evening   float64 `json:"evening"`
+}
+
+// forSlot returns the weight of a slot by name
+func (w SlotWeights) forSlot(slot string) float64 {
+	switch slot {
+	case "morning":
+		return w.Morning
+	case "afternoon":
+		return w.Afternoon
+	default:
+		return w.Evening
+	}
+}
+
+// Attraction categories, for weighting by time of day
+const (
+	CategoryIndoor        = "indoor"
+	CategoryViewpoint     = "viewpoint"
+	CategoryMarket        = "market"
+	CategoryNightlife     = "nightlife"
+	CategoryEntertainment = "entertainment"
+	CategoryDining        = "dining"
+	CategoryOutdoor       = "outdoor"
+	CategoryReligious     = "religious"
+	CategoryLandmark      = "landmark"
+)
+
+// DefaultSlotWeights put museums in the midday heat, markets and the
+// outdoors in the morning, and viewpoints, shows and dining in the evening.
+// SetSlotWeights overrides them.
+var DefaultSlotWeights = map[string]SlotWeights{
+	CategoryIndoor:        {Morning: 0.6, Afternoon: 1.0, Evening: 0.3},
+	CategoryViewpoint:     {Morning: 0.4, Afternoon: 0.3, Evening: 1.0},
+	CategoryMarket:        {Morning: 1.0, Afternoon: 0.6, Evening: 0.5},
+	CategoryNightlife:     {Morning: 0.0, Afternoon: 0.1, Evening: 1.0},
+	CategoryEntertainment: {Morning: 0.1, Afternoon: 0.4, Evening: 1.0},
+	CategoryDining:        {Morning: 0.2, Afternoon: 0.4, Evening: 0.9},
+	CategoryOutdoor:       {Morning: 1.0, Afternoon: 0.5, Evening: 0.4},
+	CategoryReligious:     {Morning: 1.0, Afternoon: 0.6, Evening: 0.5},
+	CategoryLandmark:      {Morning: 0.8, Afternoon: 0.7, Evening: 0.3},
+}
+
+// categoryKeywords classify an attraction by the words in its type and
+// tags. Earlier categories win, so a night market is nightlife rather than
+// a market.
+var categoryKeywords = []struct {
+	category string
+	keywords []string
+}{
+	{CategoryNightlife, []string{"bar", "night club", "nightclub", "nightlife", "night market", "pub"}},
+	{CategoryEntertainment, []string{"theater", "theatre", "show", "performance", "concert", "light and sound"}},
+	{CategoryViewpoint, []string{"viewpoint", "view point", "sunset", "lookout", "observation deck", "beach", "promenade", "ghat", "rooftop", "skyline"}},
+	{CategoryMarket, []string{"market", "bazaar", "bazar", "flea market"}},
+	{CategoryDining, []string{"restaurant", "dining", "food", "street food"}},
+	{CategoryIndoor, []string{"museum", "gallery", "art gallery", "aquarium", "shopping mall", "mall", "planetarium", "indoor", "library"}},
+	{CategoryReligious, []string{"temple", "church", "mosque", "gurudwara", "place of worship", "monastery", "shrine", "cathedral"}},
+	{CategoryOutdoor, []string{"park", "garden", "zoo", "hike", "hiking", "trail", "nature", "lake", "outdoor", "natural feature", "waterfall"}},
+}
+
+// outdoorCategories suffer in heat and rain
+var outdoorCategories = map[string]bool{
+	CategoryViewpoint: true,
+	CategoryMarket:    true,
+	CategoryOutdoor:   true,
+	CategoryLandmark:  true,
+	CategoryReligious: true,
+}
+
+// Weather that changes how slots are weighted
+const (
+	hotDayCelsius  = 30.0
+	coldDayCelsius = 5.0
+)
+
+// eveningStop is when the evening's attraction is visited, after daytime
+// sightseeing ends at 17:30
+var eveningStop = paceStop{slot: "evening", start: "18:30"}
+
+// slotVisitMinutes is how long an attraction must stay open after a stop
+// starts to be worth visiting then
+const slotVisitMinutes = 90
+
+// AttractionCategory classifies an attraction for time-of-day weighting by
+// its type and tags. Unrecognized attractions are landmarks.
+func AttractionCategory(attraction Attraction) string {
+	words := make([]string, 0, len(attraction.Tags)+1)
+	for _, word := range append([]string{attraction.Type}, attraction.Tags...) {
+		words = append(words, strings.NewReplacer("_", " ", "-", " ").Replace(word))
+	}
+	text := strings.Join(words, ", ")
+	for _, category := range categoryKeywords {
+		for _, keyword := range category.keywords {
+			if containsWord(text, keyword) {
+				return category.category
+			}
+		}
+	}
+	return CategoryLandmark
+}
+
+// SetSlotWeights overrides the time-of-day weights of attraction
+// categories. Categories left out keep their default weights.
+// NewGeminiService sets the weights configured in ATTRACTION_SLOT_WEIGHTS.
+func (g *GeminiService) SetSlotWeights(weights map[string]SlotWeights) {
+	g.slotWeights = weights
+}
+
+// ParseSlotWeights reads slot weights from JSON keyed by category, like
+// {"indoor": {"morning": 0.5, "afternoon": 1, "evening": 0.2}}. Unknown
+// categories and weights outside 0-1 are rejected. Empty input gives no
+// overrides.
+func ParseSlotWeights(raw string) (map[string]SlotWeights, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var weights map[string]SlotWeights
+	if err := json.Unmarshal([]byte(raw), &weights); err != nil {
+		return nil, fmt.Errorf("invalid slot weights: %v", err)
+	}
+	for category, w := range weights {
+		if _, ok := DefaultSlotWeights[category]; !ok {
+			return nil, fmt.Errorf("invalid slot weights: unknown category %q", category)
+		}
+		for _, value := range []float64{w.Morning, w.Afternoon, w.Evening} {
+			if value < 0 || value > 1 {
+				return nil, fmt.Errorf("invalid slot weights: %s weights must be between 0 and 1", category)
+			}
+		}
+	}
+	return weights, nil
+}
+
+// categoryWeights returns the configured weights of a category
+func (g *GeminiService) categoryWeights(category string) SlotWeights {
+	if weights, ok := g.slotWeights[category]; ok {
+		return weights
+	}
+	if weights, ok := DefaultSlotWeights[category]; ok {
+		return weights
+	}
+	return DefaultSlotWeights[CategoryLandmark]
+}
+
+// slotScore is how well an attraction suits a stop in the given weather,
+// or -1 when it's closed then. Outdoor attractions lose weight in the
+// afternoon heat, in the rain, and on cold evenings, which sends indoor ones
+// to those slots instead.
+func (g *GeminiService) slotScore(attraction Attraction, category string, stop paceStop, weather *WeatherCondition) float64 {
+	if !openForStop(attraction.OpeningHours, stop) {
+		return -1
+	}
+	score := g.categoryWeights(category).forSlot(stop.slot)
+	if weather == nil {
+		return score
+	}
+
+	rainy := strings.Contains(strings.ToLower(weather.Description), "rain") || strings.Contains(strings.ToLower(weather.Description), "storm")
+	switch {
+	case outdoorCategories[category] && rainy:
+		score *= 0.5
+	case outdoorCategories[category] && stop.slot == "afternoon" && weather.Temperature >= hotDayCelsius:
+		score *= 0.4
+	case outdoorCategories[category] && stop.slot == "evening" && weather.Temperature <= coldDayCelsius:
+		score *= 0.5
+	case category == CategoryIndoor && (rainy || (stop.slot == "afternoon" && weather.Temperature >= hotDayCelsius)):
+		score = math.Min(score*1.2, 1)
+	}
+	return score
+}
+
+// openForStop reports whether opening hours allow a visit starting at the
+// stop. Hours that can't be read don't rule a stop out; per-day hours allow
+// the stop if any day does, since the date isn't known.
+func openForStop(hours []string, stop paceStop) bool {
+	start, ok := parseClock(stop.start)
+	if !ok {
+		return true
+	}
+	known := false
+	for _, entry := range hours {
+		_, text := splitWeekday(entry)
+		windows, ok := openingWindows(text)
+		if !ok {
+			continue
+		}
+		known = true
+		for _, window := range windows {
+			if start >= window.opens && start+slotVisitMinutes <= window.closes {
+				return true
+			}
+		}
+	}
+	return !known
+}
+
+// splitEveningAttractions separates the attractions best visited in the
+// evening, and open then, from the daytime ones. Both keep their order, so
+// each day can take the next of each.
+func (g *GeminiService) splitEveningAttractions(attractions []Attraction) (daytime, evening []Attraction) {
+	for _, attraction := range attractions {
+		weights := g.categoryWeights(AttractionCategory(attraction))
+		if weights.Evening > weights.Morning && weights.Evening > weights.Afternoon && openForStop(attraction.OpeningHours, eveningStop) {
+			evening = append(evening, attraction)
+			continue
+		}
+		daytime = append(daytime, attraction)
+	}
+	return daytime, evening
+}
+
+// slottedAttraction is an attraction placed at one of a day's stops
+type slottedAttraction struct {
+	attraction Attraction
+	stop       int
+}
+
+// arrangeBySlot places attractions at the stops that suit them best,
+// maximizing the total score with at most one attraction per stop.
+// Attractions closed at every free stop are left out. The result is in
+// stop order.
+func (g *GeminiService) arrangeBySlot(attractions []Attraction, stops []paceStop, weather *WeatherCondition) []slottedAttraction {
+	if len(attractions) > len(stops) {
+		attractions = attractions[:len(stops)]
+	}
+	scores := make([][]float64, len(attractions))
+	for i, attraction := range attractions {
+		category := AttractionCategory(attraction)
+		scores[i] = make([]float64, len(stops))
+		for j, stop := range stops {
+			scores[i][j] = g.slotScore(attraction, category, stop, weather)
+		}
+	}
+
+	// A day has at most four stops, so every placement can be tried
+	best, bestScore := make([]int, len(attractions)), math.Inf(-1)
+	current := make([]int, len(attractions))
+	used := make([]bool, len(stops))
+	var place func(i int, score float64)
+	place = func(i int, score float64) {
+		if i == len(attractions) {
+			if score > bestScore {
+				bestScore = score
+				copy(best, current)
+			}
+			return
+		}
+		for j := range stops {
+			if used[j] || scores[i][j] < 0 {
+				continue
+			}
+			used[j], current[i] = true, j
+			place(i+1, score+scores[i][j])
+			used[j] = false
+		}
+		// Leaving an attraction out costs more than any placement gains
+		current[i] = -1
+		place(i+1, score-float64(len(stops)))
+	}
+	place(0, 0)
+
+	var slotted []slottedAttraction
+	for j := range stops {
+		for i, stop := range best {
+			if stop == j {
+				slotted = append(slotted, slottedAttraction{attraction: attractions[i], stop: j})
+			}
+		}
+	}
+	return slotted
+}
+
+// dayWeather is the forecast for a day of the trip, or the current weather
+// when there's no forecast that far ahead
+func dayWeather(ragContext TripContext, day int) *WeatherCondition {
+	if day >= 1 && day <= len(ragContext.Weather.Forecast) {
+		return &ragContext.Weather.Forecast[day-1]
+	}
+	if ragContext.Weather.Current.Description != "" || ragContext.Weather.Current.Temperature != 0 {
+		return &ragContext.Weather.Current
+	}
+	return nil
+}
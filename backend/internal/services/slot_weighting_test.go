@@ -0,0 +1,73 @@
+package services
+
+import "testing"
+
+func TestArrangeBySlot(t *testing.T) {
+	museum := Attraction{Name: "City Palace Museum", Type: "museum"}
+	sunset := Attraction{Name: "Nahargarh sunset point", Type: "tourist_attraction", Tags: []string{"sunset", "viewpoint"}}
+	market := Attraction{Name: "Johari Bazaar", Type: "market"}
+	closedEvenings := Attraction{Name: "Albert Hall", Type: "museum", OpeningHours: []string{"09:00-17:00"}}
+	stops := []paceStop{{slot: "morning", start: "09:30"}, {slot: "afternoon", start: "14:30"}, eveningStop}
+
+	tests := []struct {
+		name        string
+		attractions []Attraction
+		weather     *WeatherCondition
+		want        map[string]string
+	}{
+		{
+			name:        "sunset in the evening",
+			attractions: []Attraction{sunset, museum, market},
+			want:        map[string]string{sunset.Name: "evening", museum.Name: "afternoon", market.Name: "morning"},
+		},
+		{
+			name:        "closed stops are skipped",
+			attractions: []Attraction{closedEvenings, sunset},
+			want:        map[string]string{closedEvenings.Name: "afternoon", sunset.Name: "evening"},
+		},
+		{
+			name:        "hot afternoons go indoors",
+			attractions: []Attraction{market, museum},
+			weather:     &WeatherCondition{Temperature: 38, Description: "sunny"},
+			want:        map[string]string{market.Name: "morning", museum.Name: "afternoon"},
+		},
+	}
+	g := &GeminiService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string]string)
+			for _, placed := range g.arrangeBySlot(tt.attractions, stops, tt.weather) {
+				got[placed.attraction.Name] = stops[placed.stop].slot
+			}
+			for name, slot := range tt.want {
+				if got[name] != slot {
+					t.Errorf("%s placed in %q, want %q (all: %v)", name, got[name], slot, got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSlotWeights(t *testing.T) {
+	weights, err := ParseSlotWeights(`{"indoor": {"morning": 1, "afternoon": 0.2, "evening": 0}}`)
+	if err != nil {
+		t.Fatalf("ParseSlotWeights: %v", err)
+	}
+	g := &GeminiService{}
+	g.SetSlotWeights(weights)
+	if got := g.categoryWeights(CategoryIndoor); got.Morning != 1 || got.Afternoon != 0.2 {
+		t.Errorf("indoor weights = %+v, want the configured ones", got)
+	}
+	if got := g.categoryWeights(CategoryMarket); got != DefaultSlotWeights[CategoryMarket] {
+		t.Errorf("market weights = %+v, want the defaults", got)
+	}
+
+	for _, raw := range []string{`{"casino": {"evening": 1}}`, `{"indoor": {"morning": 2}}`, `[1, 2]`} {
+		if _, err := ParseSlotWeights(raw); err == nil {
+			t.Errorf("ParseSlotWeights(%s) succeeded, want an error", raw)
+		}
+	}
+	if weights, err := ParseSlotWeights(""); weights != nil || err != nil {
+		t.Errorf("ParseSlotWeights(\"\") = %v, %v; want no overrides", weights, err)
+	}
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP TLS modes
+const (
+	SMTPStartTLS    = "starttls"
+	SMTPImplicitTLS = "implicit"
+	SMTPNoTLS       = "none"
+)
+
+// SMTP authentication methods
+const (
+	SMTPAuthPlain   = "plain"
+	SMTPAuthXOAUTH2 = "xoauth2"
+)
+
+// smtpTimeout bounds a whole SMTP exchange when the context has no deadline
+const smtpTimeout = 30 * time.Second
+
+// ErrSMTPTLSUnavailable is returned when STARTTLS is required but the server
+// doesn't offer it. Mail is never sent unencrypted as a fallback.
+var ErrSMTPTLSUnavailable = errors.New("SMTP server does not offer STARTTLS")
+
+// sendSMTPMail delivers msg to recipients through the server in config,
+// encrypting the connection and authenticating as configured
+func sendSMTPMail(ctx context.Context, config *EmailConfig, recipients []string, msg []byte) error {
+	mode := strings.ToLower(config.TLSMode)
+	if mode == "" {
+		mode = SMTPStartTLS
+	}
+	addr := smtpAddress(config, mode)
+	tlsConfig := &tls.Config{
+		ServerName:         config.SMTPHost,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	dialer := &net.Dialer{Deadline: deadline}
+
+	var conn net.Conn
+	var err error
+	switch mode {
+	case SMTPImplicitTLS:
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	case SMTPStartTLS, SMTPNoTLS:
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return fmt.Errorf("unknown SMTP TLS mode %q", config.TLSMode)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to SMTP server %s: %w", addr, err)
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		conn.Close()
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session with %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if mode == SMTPStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%w: %s", ErrSMTPTLSUnavailable, addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS with %s: %w", addr, err)
+		}
+	}
+
+	auth, err := smtpAuth(ctx, config)
+	if err != nil {
+		return err
+	}
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("SMTP server %s does not support authentication", addr)
+		}
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(config.FromEmail); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(msg); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// smtpAddress returns the server's address, on the standard port for the
+// TLS mode when config doesn't set one
+func smtpAddress(config *EmailConfig, mode string) string {
+	port := config.SMTPPort
+	if port == 0 {
+		port = 587
+		if mode == SMTPImplicitTLS {
+			port = 465
+		}
+	}
+	return net.JoinHostPort(config.SMTPHost, strconv.Itoa(port))
+}
+
+// StaticOAuth2Token returns an EmailConfig.OAuth2Token source for a token
+// kept fresh outside the process, like a mounted secret rotated on restart
+func StaticOAuth2Token(token string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		return token, nil
+	}
+}
+
+// smtpAuth returns the authentication config asks for, or nil when there
+// are no credentials to send
+func smtpAuth(ctx context.Context, config *EmailConfig) (smtp.Auth, error) {
+	switch strings.ToLower(config.AuthMethod) {
+	case "", SMTPAuthPlain:
+		if config.Username == "" {
+			return nil, nil
+		}
+		// PlainAuth itself refuses to send the password unencrypted to
+		// anything but localhost
+		return smtp.PlainAuth("", config.Username, config.Password, config.SMTPHost), nil
+	case SMTPAuthXOAUTH2:
+		if config.OAuth2Token == nil {
+			return nil, fmt.Errorf("SMTP XOAUTH2 authentication needs an OAuth2 token source")
+		}
+		token, err := config.OAuth2Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get SMTP OAuth2 token: %w", err)
+		}
+		return &xoauth2Auth{username: config.Username, token: token, host: config.SMTPHost}, nil
+	default:
+		return nil, fmt.Errorf("unknown SMTP auth method %q", config.AuthMethod)
+	}
+}
+
+// xoauth2Auth implements the XOAUTH2 SASL mechanism Gmail and Office 365
+// accept in place of passwords
+type xoauth2Auth struct {
+	username string
+	token    string
+	host     string
+}
+
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like PlainAuth, only send the token over TLS or to localhost
+	if !server.TLS && !isLocalSMTPHost(server.Name) {
+		return "", nil, errors.New("refusing to send OAuth2 token over an unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "XOAUTH2", []byte("user=" + a.username + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next answers the server's error challenge with an empty response, which
+// makes it finish with the actual error
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+func isLocalSMTPHost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
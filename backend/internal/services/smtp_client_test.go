@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpSession is what a stub SMTP server saw of one connection
+type smtpSession struct {
+	tls  bool
+	auth string
+	from string
+	data string
+}
+
+// smtpStub is a local SMTP server that accepts any mail. It offers STARTTLS
+// when startTLS is set, and speaks TLS from the start when implicitTLS is.
+type smtpStub struct {
+	addr      string
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	sessions []smtpSession
+}
+
+func newSMTPStub(t *testing.T, startTLS, implicitTLS bool) *smtpStub {
+	t.Helper()
+	stub := &smtpStub{tlsConfig: &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if implicitTLS {
+		listener = tls.NewListener(listener, stub.tlsConfig)
+	}
+	t.Cleanup(func() { listener.Close() })
+	stub.addr = listener.Addr().String()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn, startTLS, implicitTLS)
+		}
+	}()
+	return stub
+}
+
+func (s *smtpStub) serve(conn net.Conn, startTLS, secure bool) {
+	defer conn.Close()
+	session := smtpSession{tls: secure}
+	defer func() {
+		s.mu.Lock()
+		s.sessions = append(s.sessions, session)
+		s.mu.Unlock()
+	}()
+
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			text.PrintfLine("250-stub")
+			if startTLS && !session.tls {
+				text.PrintfLine("250-STARTTLS")
+			}
+			text.PrintfLine("250 AUTH PLAIN XOAUTH2")
+		case "STARTTLS":
+			text.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, text, session.tls = tlsConn, textproto.NewConn(tlsConn), true
+		case "AUTH":
+			mechanism, response, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(response)
+			session.auth = mechanism + " " + string(decoded)
+			text.PrintfLine("235 accepted")
+		case "MAIL":
+			session.from = arg
+			text.PrintfLine("250 ok")
+		case "RCPT":
+			text.PrintfLine("250 ok")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			session.data = strings.Join(lines, "\n")
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// session waits for the stub's first connection to close and returns it
+func (s *smtpStub) session(t *testing.T) smtpSession {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		s.mu.Lock()
+		if len(s.sessions) > 0 {
+			defer s.mu.Unlock()
+			return s.sessions[0]
+		}
+		s.mu.Unlock()
+	}
+	t.Fatal("the stub saw no SMTP session")
+	return smtpSession{}
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSendSMTPMail(t *testing.T) {
+	tests := []struct {
+		name        string
+		startTLS    bool
+		implicitTLS bool
+		config      EmailConfig
+		wantErr     error
+		wantAuth    string
+	}{
+		{
+			name:     "STARTTLS with a password",
+			startTLS: true,
+			config:   EmailConfig{TLSMode: SMTPStartTLS, Username: "mailer", Password: "secret", InsecureSkipVerify: true},
+			wantAuth: "PLAIN \x00mailer\x00secret",
+		},
+		{
+			name:     "STARTTLS with XOAUTH2",
+			startTLS: true,
+			config: EmailConfig{TLSMode: SMTPStartTLS, Username: "mailer@example.com", AuthMethod: SMTPAuthXOAUTH2,
+				OAuth2Token: StaticOAuth2Token("ya29.token"), InsecureSkipVerify: true},
+			wantAuth: "XOAUTH2 user=mailer@example.com\x01auth=Bearer ya29.token\x01\x01",
+		},
+		{
+			name:        "implicit TLS",
+			implicitTLS: true,
+			config:      EmailConfig{TLSMode: SMTPImplicitTLS, Username: "mailer", Password: "secret", InsecureSkipVerify: true},
+			wantAuth:    "PLAIN \x00mailer\x00secret",
+		},
+		{
+			name:    "STARTTLS not offered",
+			config:  EmailConfig{TLSMode: SMTPStartTLS, Username: "mailer", Password: "secret", InsecureSkipVerify: true},
+			wantErr: ErrSMTPTLSUnavailable,
+		},
+		{
+			name:     "self-signed certificate rejected",
+			startTLS: true,
+			config:   EmailConfig{TLSMode: SMTPStartTLS, Username: "mailer", Password: "secret"},
+			wantErr:  errAny,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newSMTPStub(t, tt.startTLS, tt.implicitTLS)
+			host, port, _ := net.SplitHostPort(stub.addr)
+			config := tt.config
+			config.SMTPHost = host
+			config.SMTPPort, _ = strconv.Atoi(port)
+			config.FromEmail = "noreply@auratravel.ai"
+
+			err := sendSMTPMail(context.Background(), &config, []string{"traveler@example.com"}, []byte("Subject: Trip\r\n\r\nYour itinerary\r\n"))
+			session := stub.session(t)
+			if tt.wantErr != nil {
+				if err == nil || (tt.wantErr != errAny && !errors.Is(err, tt.wantErr)) {
+					t.Fatalf("sendSMTPMail = %v, want %v", err, tt.wantErr)
+				}
+				if session.auth != "" || session.from != "" {
+					t.Errorf("credentials or mail were sent after the failure: %+v", session)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sendSMTPMail: %v", err)
+			}
+			if !session.tls || session.auth != tt.wantAuth || !strings.Contains(session.data, "Your itinerary") {
+				t.Errorf("session = %+v, want %q authenticated over TLS with the message", session, tt.wantAuth)
+			}
+		})
+	}
+}
+
+// errAny marks a test case that expects some error
+var errAny = errors.New("any error")
+
+func TestSMTPAddress(t *testing.T) {
+	tests := []struct {
+		mode string
+		port int
+		want string
+	}{
+		{SMTPStartTLS, 0, "smtp.example.com:587"},
+		{SMTPImplicitTLS, 0, "smtp.example.com:465"},
+		{SMTPNoTLS, 0, "smtp.example.com:587"},
+		{SMTPImplicitTLS, 2465, "smtp.example.com:2465"},
+	}
+	for _, tt := range tests {
+		if got := smtpAddress(&EmailConfig{SMTPHost: "smtp.example.com", SMTPPort: tt.port}, tt.mode); got != tt.want {
+			t.Errorf("smtpAddress(%s, port %d) = %s, want %s", tt.mode, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestSMTPAuth(t *testing.T) {
+	failing := func(ctx context.Context) (string, error) { return "", errors.New("token expired") }
+	tests := []struct {
+		name    string
+		config  EmailConfig
+		wantNil bool
+		wantErr bool
+	}{
+		{"no credentials", EmailConfig{}, true, false},
+		{"plain", EmailConfig{Username: "mailer", Password: "secret"}, false, false},
+		{"xoauth2 without a token source", EmailConfig{AuthMethod: SMTPAuthXOAUTH2}, true, true},
+		{"xoauth2 token source fails", EmailConfig{AuthMethod: SMTPAuthXOAUTH2, OAuth2Token: failing}, true, true},
+		{"unknown method", EmailConfig{AuthMethod: "cram-md5"}, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth, err := smtpAuth(context.Background(), &tt.config)
+			if (auth == nil) != tt.wantNil || (err != nil) != tt.wantErr {
+				t.Errorf("smtpAuth = %v, %v; want nil auth %v, error %v", auth, err, tt.wantNil, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	// The zone database is embedded so zones resolve in containers that
+	// don't ship one
+	_ "time/tzdata"
+
+	"auratravel-backend/internal/cache"
+	"auratravel-backend/internal/httpclient"
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/metrics"
+)
+
+const (
+	// timeZoneAPIURL is the Google Maps Time Zone API
+	timeZoneAPIURL = "https://maps.googleapis.com/maps/api/timezone/json"
+	// zoneCacheTTL is how long a looked-up zone is reused; zone boundaries
+	// rarely move
+	zoneCacheTTL = 30 * 24 * time.Hour
+)
+
+// ZoneResolver looks up the time zone at a location with the Google Maps
+// Time Zone API. Without an API key, or when the lookup fails, it falls
+// back to ResolveDestinationZone. A nil *ZoneResolver only uses the
+// fallback.
+type ZoneResolver struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+	cache      cache.Cache
+}
+
+// NewZoneResolver creates a resolver. resultCache may be nil.
+func NewZoneResolver(mapsAPIKey string, resultCache cache.Cache) *ZoneResolver {
+	return &ZoneResolver{
+		apiKey:     mapsAPIKey,
+		baseURL:    timeZoneAPIURL,
+		httpClient: httpclient.New(httpclient.Options{}),
+		cache:      resultCache,
+	}
+}
+
+// Resolve returns the time zone at location
+func (z *ZoneResolver) Resolve(ctx context.Context, location Location) *time.Location {
+	if z == nil || z.apiKey == "" || !hasLatLng(location) {
+		return ResolveDestinationZone(location)
+	}
+
+	// Two decimals is about a kilometre, far finer than zone boundaries
+	lat := strconv.FormatFloat(location.Latitude, 'f', 2, 64)
+	lng := strconv.FormatFloat(location.Longitude, 'f', 2, 64)
+	cacheKey := cache.Key("timezone", lat, lng)
+	var zoneID string
+	if !cache.GetJSON(ctx, z.cache, cacheKey, &zoneID) {
+		var err error
+		if zoneID, err = z.lookup(ctx, lat, lng); err != nil {
+			logging.FromContext(ctx).Warn("Time zone lookup failed, estimating the zone", "error", err)
+			return ResolveDestinationZone(location)
+		}
+		cache.SetJSON(ctx, z.cache, cacheKey, zoneID, zoneCacheTTL)
+	}
+
+	zone, err := time.LoadLocation(zoneID)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Time zone lookup returned an unknown zone, estimating the zone", "zone", zoneID)
+		return ResolveDestinationZone(location)
+	}
+	return zone
+}
+
+// timeZoneResponse is the subset of the Time Zone API response used
+type timeZoneResponse struct {
+	Status       string `json:"status"`
+	TimeZoneID   string `json:"timeZoneId"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// lookup asks the Time Zone API for the IANA zone at lat, lng
+func (z *ZoneResolver) lookup(ctx context.Context, lat, lng string) (string, error) {
+	params := url.Values{}
+	params.Add("location", lat+","+lng)
+	params.Add("timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	params.Add("key", z.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, z.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create time zone request: %v", err)
+	}
+
+	start := time.Now()
+	resp, err := z.httpClient.Do(req)
+	metrics.ObserveExternalCall("timezone", start, err)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch time zone: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var tzResp timeZoneResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tzResp); err != nil {
+		return "", fmt.Errorf("failed to decode time zone response: %v", err)
+	}
+	if tzResp.Status != "OK" || tzResp.TimeZoneID == "" {
+		return "", fmt.Errorf("time zone API returned %s: %s", tzResp.Status, tzResp.ErrorMessage)
+	}
+	return tzResp.TimeZoneID, nil
+}
+
+// zoneCityRadiusKm is how near a known city a destination must be to take
+// its zone
+const zoneCityRadiusKm = 300
+
+// zoneCity is a destination whose time zone is known
+type zoneCity struct {
+	zone     string
+	location Location
+}
+
+// zoneCities are popular destinations, a location near one of which takes
+// its zone
+var zoneCities = []zoneCity{
+	{"Asia/Kolkata", Location{Latitude: 28.61, Longitude: 77.21}},   // Delhi
+	{"Asia/Kolkata", Location{Latitude: 19.08, Longitude: 72.88}},   // Mumbai
+	{"Asia/Kolkata", Location{Latitude: 12.97, Longitude: 77.59}},   // Bengaluru
+	{"Asia/Kolkata", Location{Latitude: 13.08, Longitude: 80.27}},   // Chennai
+	{"Asia/Kolkata", Location{Latitude: 22.57, Longitude: 88.36}},   // Kolkata
+	{"Asia/Kolkata", Location{Latitude: 15.30, Longitude: 74.12}},   // Goa
+	{"Asia/Kolkata", Location{Latitude: 31.63, Longitude: 74.87}},   // Amritsar
+	{"Asia/Karachi", Location{Latitude: 31.55, Longitude: 74.34}},   // Lahore
+	{"Asia/Karachi", Location{Latitude: 33.68, Longitude: 73.05}},   // Islamabad
+	{"Asia/Kathmandu", Location{Latitude: 27.72, Longitude: 85.32}}, // Kathmandu
+	{"Asia/Thimphu", Location{Latitude: 27.47, Longitude: 89.64}},   // Thimphu
+	{"Asia/Kabul", Location{Latitude: 34.56, Longitude: 69.21}},     // Kabul
+	{"Asia/Yangon", Location{Latitude: 16.87, Longitude: 96.20}},    // Yangon
+	{"Asia/Shanghai", Location{Latitude: 29.65, Longitude: 91.17}},  // Lhasa
+	{"Asia/Shanghai", Location{Latitude: 30.57, Longitude: 104.07}}, // Chengdu
+	{"Asia/Colombo", Location{Latitude: 6.93, Longitude: 79.85}},    // Colombo
+	{"Asia/Dhaka", Location{Latitude: 23.81, Longitude: 90.41}},     // Dhaka
+	{"Asia/Dubai", Location{Latitude: 25.20, Longitude: 55.27}},     // Dubai
+	{"Asia/Singapore", Location{Latitude: 1.35, Longitude: 103.82}}, // Singapore
+	{"Asia/Bangkok", Location{Latitude: 13.76, Longitude: 100.50}},  // Bangkok
+	{"Asia/Shanghai", Location{Latitude: 39.90, Longitude: 116.40}}, // Beijing
+	{"Asia/Tokyo", Location{Latitude: 35.68, Longitude: 139.69}},    // Tokyo
+	{"Australia/Adelaide", Location{Latitude: -34.93, Longitude: 138.60}},
+	{"Australia/Sydney", Location{Latitude: -33.87, Longitude: 151.21}},
+	{"Europe/London", Location{Latitude: 51.51, Longitude: -0.13}},
+	{"Europe/Paris", Location{Latitude: 48.86, Longitude: 2.35}},
+	{"Europe/Madrid", Location{Latitude: 40.42, Longitude: -3.70}},
+	{"Europe/Rome", Location{Latitude: 41.90, Longitude: 12.50}},
+	{"Europe/Berlin", Location{Latitude: 52.52, Longitude: 13.40}},
+	{"America/New_York", Location{Latitude: 40.71, Longitude: -74.01}},
+	{"America/Chicago", Location{Latitude: 41.88, Longitude: -87.63}},
+	{"America/Denver", Location{Latitude: 39.74, Longitude: -104.99}},
+	{"America/Los_Angeles", Location{Latitude: 34.05, Longitude: -118.24}},
+}
+
+// India keeps one zone, half an hour off the meridians, across the whole
+// country, so locations inside it that aren't near a known city still get
+// Asia/Kolkata
+const (
+	indiaMinLat, indiaMaxLat = 6.5, 35.7
+	indiaMinLng, indiaMaxLng = 68.0, 97.5
+)
+
+// ResolveDestinationZone estimates the time zone at a destination without
+// calling out: the zone of the nearest known city within zoneCityRadiusKm,
+// then Asia/Kolkata inside India, and otherwise a guess from the longitude.
+// Prefer ZoneResolver, which looks the zone up.
+func ResolveDestinationZone(location Location) *time.Location {
+	if !hasLatLng(location) {
+		return time.UTC
+	}
+
+	nearest, nearestKm := "", float64(zoneCityRadiusKm)
+	for _, city := range zoneCities {
+		if km := haversineKm(location, city.location); km <= nearestKm {
+			nearest, nearestKm = city.zone, km
+		}
+	}
+	if nearest == "" && location.Latitude >= indiaMinLat && location.Latitude <= indiaMaxLat &&
+		location.Longitude >= indiaMinLng && location.Longitude <= indiaMaxLng {
+		nearest = "Asia/Kolkata"
+	}
+	if nearest != "" {
+		if zone, err := time.LoadLocation(nearest); err == nil {
+			return zone
+		}
+	}
+	return approximateZone(location.Longitude)
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"auratravel-backend/internal/cache"
+)
+
+func TestResolveDestinationZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		location Location
+		want     string
+	}{
+		{"Jaipur", Location{Latitude: 26.91, Longitude: 75.79}, "Asia/Kolkata"},
+		{"Yangon", Location{Latitude: 16.84, Longitude: 96.17}, "Asia/Yangon"},
+		{"Thimphu", Location{Latitude: 27.47, Longitude: 89.64}, "Asia/Thimphu"},
+		{"Lhasa", Location{Latitude: 29.65, Longitude: 91.12}, "Asia/Shanghai"},
+		{"Kabul", Location{Latitude: 34.53, Longitude: 69.17}, "Asia/Kabul"},
+		{"Chengdu", Location{Latitude: 30.66, Longitude: 104.06}, "Asia/Shanghai"},
+		{"Lahore", Location{Latitude: 31.52, Longitude: 74.36}, "Asia/Karachi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveDestinationZone(tt.location).String(); got != tt.want {
+				t.Errorf("ResolveDestinationZone = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZoneResolverLooksUpZones(t *testing.T) {
+	lookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lookups++
+		switch r.URL.Query().Get("location") {
+		case "16.84,96.17":
+			fmt.Fprint(w, `{"status":"OK","timeZoneId":"Asia/Yangon"}`)
+		default:
+			fmt.Fprint(w, `{"status":"OVER_QUERY_LIMIT","errorMessage":"quota"}`)
+		}
+	}))
+	defer server.Close()
+
+	z := NewZoneResolver("key", cache.NewMemoryCache())
+	z.baseURL = server.URL
+	z.httpClient = server.Client()
+	ctx := context.Background()
+
+	yangon := Location{Latitude: 16.84, Longitude: 96.17}
+	for i := 0; i < 2; i++ {
+		if got := z.Resolve(ctx, yangon).String(); got != "Asia/Yangon" {
+			t.Errorf("Resolve(Yangon) = %s, want Asia/Yangon", got)
+		}
+	}
+	if lookups != 1 {
+		t.Errorf("made %d lookups, want the second served from cache", lookups)
+	}
+
+	if got := z.Resolve(ctx, Location{Latitude: 26.91, Longitude: 75.79}).String(); got != "Asia/Kolkata" {
+		t.Errorf("Resolve after a failed lookup = %s, want the estimated Asia/Kolkata", got)
+	}
+
+	var none *ZoneResolver
+	if got := none.Resolve(ctx, yangon).String(); got != "Asia/Yangon" {
+		t.Errorf("nil resolver = %s, want the estimate", got)
+	}
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/cache"
+	"auratravel-backend/internal/logging"
+)
+
+// Where an advisory's details came from
+const (
+	AdvisorySourceDataset = "dataset"
+	AdvisorySourceGemini  = "gemini"
+)
+
+// advisoryCacheTTL is how long a generated advisory is reused
+const advisoryCacheTTL = 24 * time.Hour
+
+// Advisory levels, from safest to least safe
+const (
+	AdvisoryNormal           = 1
+	AdvisoryIncreasedCaution = 2
+	AdvisoryReconsider       = 3
+	AdvisoryDoNotTravel      = 4
+)
+
+// advisoryLevelLabels describe each advisory level
+var advisoryLevelLabels = map[int]string{
+	AdvisoryNormal:           "Exercise normal precautions",
+	AdvisoryIncreasedCaution: "Exercise increased caution",
+	AdvisoryReconsider:       "Reconsider travel",
+	AdvisoryDoNotTravel:      "Do not travel",
+}
+
+// Advisory is the travel advice for one destination and nationality: how
+// safe it is, the vaccinations needed, whether a visa is needed, and what
+// to watch out for
+type Advisory struct {
+	Destination             string   `json:"destination"`
+	Country                 string   `json:"country"`
+	CountryCode             string   `json:"country_code,omitempty"`
+	Level                   int      `json:"level"`
+	LevelLabel              string   `json:"level_label"`
+	RequiredVaccinations    []string `json:"required_vaccinations"`
+	RecommendedVaccinations []string `json:"recommended_vaccinations"`
+	Visa                    string   `json:"visa"`
+	SafetyNotes             []string `json:"safety_notes"`
+	Source                  string   `json:"source"`
+	// AIGenerated marks advisories written by Gemini, which aren't
+	// authoritative
+	AIGenerated bool   `json:"ai_generated"`
+	Note        string `json:"note"`
+}
+
+// HighRisk reports whether travelers are advised to reconsider or avoid
+// the trip
+func (a *Advisory) HighRisk() bool {
+	return a.Level >= AdvisoryReconsider
+}
+
+// ImportantInfo lists the advisory as lines for an itinerary's important
+// information
+func (a *Advisory) ImportantInfo() []string {
+	lines := []string{fmt.Sprintf("Travel advisory for %s: level %d, %s", a.Country, a.Level, strings.ToLower(a.LevelLabel))}
+	if len(a.RequiredVaccinations) > 0 {
+		lines = append(lines, "Required vaccinations: "+strings.Join(a.RequiredVaccinations, ", "))
+	}
+	if len(a.RecommendedVaccinations) > 0 {
+		lines = append(lines, "Recommended vaccinations: "+strings.Join(a.RecommendedVaccinations, ", "))
+	}
+	if a.Visa != "" {
+		lines = append(lines, "Visa: "+a.Visa)
+	}
+	lines = append(lines, a.SafetyNotes...)
+	return append(lines, a.Note)
+}
+
+// countryAdvisory is a country's entry in the advisory dataset
+type countryAdvisory struct {
+	level       int
+	required    []string
+	recommended []string
+	notes       []string
+	// visa is the visa summary for nationalities not in visaByNationality
+	visa              string
+	visaByNationality map[string]string
+}
+
+// advisoryCountries are destination countries with advisories that aren't
+// in the emergency dataset, found by name or one of their places
+var advisoryCountries = []emergencyCountry{
+	{code: "AF", name: "Afghanistan", places: []string{"Kabul", "Kandahar"}},
+	{code: "SY", name: "Syria", places: []string{"Damascus", "Aleppo"}},
+	{code: "YE", name: "Yemen", places: []string{"Sanaa", "Aden"}},
+	{code: "SO", name: "Somalia", places: []string{"Mogadishu"}},
+	{code: "HT", name: "Haiti", places: []string{"Port-au-Prince"}},
+	{code: "MM", name: "Myanmar", places: []string{"Burma", "Yangon", "Mandalay"}},
+	{code: "PK", name: "Pakistan", places: []string{"Karachi", "Lahore", "Islamabad"}},
+	{code: "NG", name: "Nigeria", places: []string{"Lagos", "Abuja"}},
+	{code: "CO", name: "Colombia", places: []string{"Bogota", "Bogotá", "Medellin", "Medellín", "Cartagena"}},
+}
+
+// Visa summaries shared by several countries
+const (
+	schengenVisaFree = "No visa needed for stays of up to 90 days in any 180 within the Schengen area"
+	schengenVisa     = "Schengen visa required for many nationalities; apply at the embassy of your main destination"
+	schengenCitizen  = "No visa needed; EU citizens can travel freely within the Schengen area"
+	avoidTravelVisa  = "Visa required; most governments advise against all travel, so consular help may be unavailable"
+)
+
+// schengenVisas gives the Schengen visa summaries of each nationality
+var schengenVisas = map[string]string{
+	"US": schengenVisaFree, "GB": schengenVisaFree, "AU": schengenVisaFree, "CA": schengenVisaFree,
+	"JP": schengenVisaFree, "SG": schengenVisaFree, "AE": schengenVisaFree,
+	"FR": schengenCitizen, "ES": schengenCitizen, "IT": schengenCitizen, "DE": schengenCitizen,
+}
+
+// advisoryDataset is the maintained advisory dataset, by country code.
+// Levels follow the common four-level scale used by government travel
+// advice.
+var advisoryDataset = map[string]countryAdvisory{
+	"IN": {
+		level:       AdvisoryIncreasedCaution,
+		recommended: []string{"Hepatitis A", "Typhoid"},
+		notes: []string{
+			"Petty theft and scams are common at busy stations and tourist sites",
+			"Avoid travel to Jammu and Kashmir outside Ladakh, and to the India-Pakistan border",
+		},
+		visa:              "e-Visa available for most nationalities; apply online at least 4 days before arrival",
+		visaByNationality: map[string]string{"NP": "No visa needed for Nepali citizens"},
+	},
+	"NP": {
+		level:       AdvisoryIncreasedCaution,
+		recommended: []string{"Hepatitis A", "Typhoid"},
+		notes: []string{
+			"Trek with a licensed guide and allow time to acclimatize above 3,000m",
+			"Monsoon landslides close roads between June and September",
+		},
+		visa:              "Visa on arrival at Kathmandu airport and the main land borders",
+		visaByNationality: map[string]string{"IN": "No visa needed for Indian citizens; carry a passport or voter ID"},
+	},
+	"US": {
+		level: AdvisoryNormal,
+		notes: []string{"Medical care is expensive; carry travel insurance that covers it"},
+		visa:  "Visa required unless eligible for the Visa Waiver Program",
+		visaByNationality: map[string]string{
+			"GB": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"FR": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"ES": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"IT": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"DE": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"JP": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"SG": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"AU": "ESTA required under the Visa Waiver Program for stays of up to 90 days",
+			"CA": "No visa needed for Canadian citizens visiting for up to 6 months",
+		},
+	},
+	"GB": {
+		level: AdvisoryIncreasedCaution,
+		notes: []string{"Pickpocketing is common on public transport in London"},
+		visa:  "Visa required for many nationalities; others need an Electronic Travel Authorisation (ETA)",
+		visaByNationality: map[string]string{
+			"US": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"CA": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"AU": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"JP": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"SG": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"FR": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"ES": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"IT": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+			"DE": "Electronic Travel Authorisation (ETA) required for visits of up to 6 months",
+		},
+	},
+	"FR": {
+		level:             AdvisoryIncreasedCaution,
+		notes:             []string{"Stay alert in crowded places; the national terrorism threat level is high", "Demonstrations can disrupt transport in Paris"},
+		visa:              schengenVisa,
+		visaByNationality: schengenVisas,
+	},
+	"ES": {
+		level:             AdvisoryIncreasedCaution,
+		notes:             []string{"Pickpocketing is common in Barcelona and Madrid tourist areas"},
+		visa:              schengenVisa,
+		visaByNationality: schengenVisas,
+	},
+	"IT": {
+		level:             AdvisoryIncreasedCaution,
+		notes:             []string{"Pickpocketing is common at stations and major sights"},
+		visa:              schengenVisa,
+		visaByNationality: schengenVisas,
+	},
+	"DE": {
+		level:             AdvisoryIncreasedCaution,
+		notes:             []string{"Stay alert at large events and Christmas markets"},
+		visa:              schengenVisa,
+		visaByNationality: schengenVisas,
+	},
+	"JP": {
+		level: AdvisoryNormal,
+		notes: []string{"Learn the earthquake and tsunami guidance for where you stay"},
+		visa:  "Visa required for many nationalities; check with a Japanese embassy",
+		visaByNationality: map[string]string{
+			"US": "No visa needed for stays of up to 90 days", "GB": "No visa needed for stays of up to 90 days",
+			"CA": "No visa needed for stays of up to 90 days", "AU": "No visa needed for stays of up to 90 days",
+			"FR": "No visa needed for stays of up to 90 days", "ES": "No visa needed for stays of up to 90 days",
+			"IT": "No visa needed for stays of up to 90 days", "DE": "No visa needed for stays of up to 90 days",
+			"SG": "No visa needed for stays of up to 90 days",
+		},
+	},
+	"TH": {
+		level:       AdvisoryNormal,
+		recommended: []string{"Hepatitis A", "Typhoid"},
+		notes:       []string{"Avoid the provinces bordering Malaysia", "Use licensed taxis and agree on fares before jet ski or tuk-tuk rides"},
+		visa:        "Visa exemption for many nationalities; check the current length of stay before travel",
+	},
+	"AE": {
+		level: AdvisoryNormal,
+		notes: []string{"Laws on alcohol, dress and public behaviour are strict", "Some medicines need approval before you bring them in"},
+		visa:  "Visa on arrival or visa-free entry for many nationalities; others need a visa arranged in advance",
+		visaByNationality: map[string]string{
+			"IN": "Visa on arrival for Indian citizens with a US visa or green card, or a UK or EU residence permit; otherwise apply in advance",
+		},
+	},
+	"SG": {
+		level: AdvisoryNormal,
+		notes: []string{"Fines for littering, jaywalking and chewing gum are strictly enforced"},
+		visa:  "Visa-free entry for most nationalities; every visitor submits an SG Arrival Card",
+		visaByNationality: map[string]string{
+			"IN": "Visa required for Indian citizens; apply through an authorised agent",
+		},
+	},
+	"AU": {
+		level: AdvisoryNormal,
+		notes: []string{"Swim between the flags at patrolled beaches"},
+		visa:  "An ETA, eVisitor or visa is required for every visitor; apply before travel",
+	},
+	"AF": {
+		level:    AdvisoryDoNotTravel,
+		required: []string{"Polio (for stays over 4 weeks)"},
+		notes:    []string{"Risk of terrorism, kidnapping and armed conflict", "Most embassies have closed, so consular help is very limited"},
+		visa:     avoidTravelVisa,
+	},
+	"SY": {
+		level: AdvisoryDoNotTravel,
+		notes: []string{"Risk of armed conflict, terrorism and kidnapping", "Most embassies have closed, so consular help is very limited"},
+		visa:  avoidTravelVisa,
+	},
+	"YE": {
+		level:       AdvisoryDoNotTravel,
+		recommended: []string{"Hepatitis A", "Typhoid", "Cholera"},
+		notes:       []string{"Risk of armed conflict, terrorism and kidnapping", "Flights in and out are limited and may be cancelled without notice"},
+		visa:        avoidTravelVisa,
+	},
+	"SO": {
+		level:    AdvisoryDoNotTravel,
+		required: []string{"Yellow fever (if arriving from a country with risk)"},
+		notes:    []string{"Risk of terrorism, kidnapping and piracy"},
+		visa:     avoidTravelVisa,
+	},
+	"HT": {
+		level:       AdvisoryDoNotTravel,
+		recommended: []string{"Hepatitis A", "Typhoid", "Cholera"},
+		notes:       []string{"Widespread gang violence and kidnapping", "Airports and roads may close without notice"},
+		visa:        "Visa not required for many nationalities, but most governments advise against all travel",
+	},
+	"MM": {
+		level:       AdvisoryDoNotTravel,
+		recommended: []string{"Hepatitis A", "Typhoid", "Japanese encephalitis"},
+		notes:       []string{"Armed conflict and civil unrest across much of the country", "Landmines in border areas"},
+		visa:        avoidTravelVisa,
+	},
+	"PK": {
+		level:       AdvisoryReconsider,
+		required:    []string{"Polio (for stays over 4 weeks)"},
+		recommended: []string{"Hepatitis A", "Typhoid"},
+		notes:       []string{"Risk of terrorism and sectarian violence", "Avoid Khyber Pakhtunkhwa, Balochistan and areas near the Line of Control"},
+		visa:        "Visa required; most nationalities can apply online for an e-Visa",
+	},
+	"NG": {
+		level:       AdvisoryReconsider,
+		required:    []string{"Yellow fever"},
+		recommended: []string{"Hepatitis A", "Typhoid", "Meningitis"},
+		notes:       []string{"Risk of crime, terrorism and kidnapping", "Avoid the north-east and travel by road after dark"},
+		visa:        "Visa required for most nationalities; apply online before travel",
+	},
+	"CO": {
+		level:       AdvisoryReconsider,
+		recommended: []string{"Hepatitis A", "Typhoid", "Yellow fever (for some areas)"},
+		notes:       []string{"Crime and armed groups in some regions", "Use app or radio taxis rather than hailing them"},
+		visa:        "No visa needed for stays of up to 90 days for many nationalities",
+	},
+}
+
+// GetTravelAdvisory returns the travel advisory for a destination and the
+// traveler's nationality: its advisory level, vaccinations, a visa summary
+// and safety notes. Destinations outside the dataset are asked of Gemini,
+// with the result cached per destination and nationality and marked as
+// AI-generated; without an API key they're ErrNotFound.
+func (g *GeminiService) GetTravelAdvisory(ctx context.Context, destination, userNationality string) (*Advisory, error) {
+	if strings.TrimSpace(destination) == "" {
+		return nil, newKindError(ErrValidation, "destination is required")
+	}
+	traveler, hasNationality := findNationality(userNationality)
+
+	if country, ok := findAdvisoryCountry(destination); ok {
+		if advisory, ok := advisoryDataset[country.code]; ok {
+			return &Advisory{
+				Destination:             destination,
+				Country:                 country.name,
+				CountryCode:             country.code,
+				Level:                   advisory.level,
+				LevelLabel:              advisoryLevelLabels[advisory.level],
+				RequiredVaccinations:    nonNilStrings(advisory.required),
+				RecommendedVaccinations: nonNilStrings(advisory.recommended),
+				Visa:                    advisory.visaFor(country, traveler, hasNationality),
+				SafetyNotes:             nonNilStrings(advisory.notes),
+				Source:                  AdvisorySourceDataset,
+				Note:                    "Check your government's official travel advice before you go",
+			}, nil
+		}
+	}
+
+	if g.apiKey == "" {
+		return nil, kindErrorf(ErrNotFound, "no travel advisory for %s", destination)
+	}
+	cacheKey := cache.Key("advisory", destination, userNationality)
+	var advisory Advisory
+	if cache.GetJSON(ctx, g.cache, cacheKey, &advisory) {
+		return &advisory, nil
+	}
+	generated, err := g.generateTravelAdvisory(ctx, destination, userNationality)
+	if err != nil {
+		return nil, err
+	}
+	cache.SetJSON(ctx, g.cache, cacheKey, generated, advisoryCacheTTL)
+	return generated, nil
+}
+
+// generateTravelAdvisory asks Gemini for a destination's travel advisory
+func (g *GeminiService) generateTravelAdvisory(ctx context.Context, destination, userNationality string) (*Advisory, error) {
+	prompt := fmt.Sprintf(`Give the travel advisory for a traveler going to %s.
+The traveler's nationality is %s.
+
+Respond with only a JSON object:
+{"country": "...", "country_code": "ISO 3166 alpha-2", "level": 1,
+ "required_vaccinations": ["..."], "recommended_vaccinations": ["..."],
+ "visa": "one sentence on whether the traveler needs a visa and how to get one",
+ "safety_notes": ["..."]}
+
+"level" is 1 (exercise normal precautions), 2 (exercise increased caution), 3 (reconsider travel) or
+4 (do not travel). Keep safety notes to the few most important current risks, one short sentence each.`,
+		userInput("destination", destination), userInput("nationality", userNationality)) + userInputNotice
+
+	response, err := g.callGeminiAPI(ctx, prompt, GeminiCallOptions{UseCase: GeminiStructured})
+	if err != nil {
+		return nil, kindErrorf(ErrUnavailable, "failed to generate travel advisory: %w", err)
+	}
+	var advisory Advisory
+	if err := json.Unmarshal([]byte(extractJSON(response)), &advisory); err != nil || advisory.Country == "" {
+		logging.FromContext(ctx).Warn("Failed to parse travel advisory response", "destination", destination, "error", err)
+		return nil, kindErrorf(ErrNotFound, "no travel advisory for %s", destination)
+	}
+	if _, ok := advisoryLevelLabels[advisory.Level]; !ok {
+		logging.FromContext(ctx).Warn("Travel advisory response has an unknown level", "destination", destination, "level", advisory.Level)
+		return nil, kindErrorf(ErrNotFound, "no travel advisory for %s", destination)
+	}
+
+	advisory.Destination = destination
+	advisory.LevelLabel = advisoryLevelLabels[advisory.Level]
+	advisory.RequiredVaccinations = nonNilStrings(advisory.RequiredVaccinations)
+	advisory.RecommendedVaccinations = nonNilStrings(advisory.RecommendedVaccinations)
+	advisory.SafetyNotes = nonNilStrings(advisory.SafetyNotes)
+	advisory.Source = AdvisorySourceGemini
+	advisory.AIGenerated = true
+	advisory.Note = "Generated by AI and not authoritative; check your government's official travel advice before you go"
+	return &advisory, nil
+}
+
+// visaFor summarizes the visa a traveler needs for the country
+func (a countryAdvisory) visaFor(country emergencyCountry, traveler nationality, hasNationality bool) string {
+	if !hasNationality {
+		return a.visa
+	}
+	if traveler.code == country.code {
+		return fmt.Sprintf("No visa needed for %s citizens", traveler.demonym)
+	}
+	if visa, ok := a.visaByNationality[traveler.code]; ok {
+		return visa
+	}
+	return a.visa
+}
+
+// findAdvisoryCountry finds the country a destination is in, from the
+// emergency dataset's countries and then the advisory-only ones
+func findAdvisoryCountry(destination string) (emergencyCountry, bool) {
+	if country, ok := findEmergencyCountry(destination); ok {
+		return country, true
+	}
+	return findCountryIn(advisoryCountries, destination)
+}
+
+// nonNilStrings returns values, or an empty list for nil, so lists encode
+// as [] rather than null
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
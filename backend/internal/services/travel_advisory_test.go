@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auratravel-backend/internal/cache"
+)
+
+func TestGetTravelAdvisoryFromDataset(t *testing.T) {
+	tests := []struct {
+		name         string
+		destination  string
+		nationality  string
+		wantCountry  string
+		wantLevel    int
+		wantVisa     string
+		wantRequired int
+		wantHighRisk bool
+	}{
+		{
+			name: "visa by nationality", destination: "Kathmandu", nationality: "Indian",
+			wantCountry: "NP", wantLevel: AdvisoryIncreasedCaution, wantVisa: "No visa needed for Indian citizens; carry a passport or voter ID",
+		},
+		{
+			name: "own country", destination: "Goa, India", nationality: "IN",
+			wantCountry: "IN", wantLevel: AdvisoryIncreasedCaution, wantVisa: "No visa needed for Indian citizens",
+		},
+		{
+			name: "shared Schengen visa", destination: "Paris", nationality: "American",
+			wantCountry: "FR", wantLevel: AdvisoryIncreasedCaution, wantVisa: schengenVisaFree,
+		},
+		{
+			name: "unknown nationality", destination: "Barcelona, Spain", nationality: "Martian",
+			wantCountry: "ES", wantLevel: AdvisoryIncreasedCaution, wantVisa: schengenVisa,
+		},
+		{
+			name: "advisory-only country", destination: "Kabul", nationality: "Indian",
+			wantCountry: "AF", wantLevel: AdvisoryDoNotTravel, wantVisa: avoidTravelVisa, wantRequired: 1, wantHighRisk: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			advisory, err := (&GeminiService{}).GetTravelAdvisory(context.Background(), tt.destination, tt.nationality)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if advisory.CountryCode != tt.wantCountry || advisory.Level != tt.wantLevel || advisory.Source != AdvisorySourceDataset || advisory.AIGenerated {
+				t.Errorf("advisory = %s level %d from %s (AI %v); want %s level %d from the dataset", advisory.CountryCode, advisory.Level, advisory.Source, advisory.AIGenerated, tt.wantCountry, tt.wantLevel)
+			}
+			if advisory.LevelLabel != advisoryLevelLabels[tt.wantLevel] {
+				t.Errorf("level label = %q, want %q", advisory.LevelLabel, advisoryLevelLabels[tt.wantLevel])
+			}
+			if advisory.Visa != tt.wantVisa {
+				t.Errorf("visa = %q, want %q", advisory.Visa, tt.wantVisa)
+			}
+			if len(advisory.RequiredVaccinations) != tt.wantRequired || advisory.RecommendedVaccinations == nil || advisory.SafetyNotes == nil {
+				t.Errorf("vaccinations = %q, %q; want %d required and non-nil lists", advisory.RequiredVaccinations, advisory.RecommendedVaccinations, tt.wantRequired)
+			}
+			if advisory.HighRisk() != tt.wantHighRisk {
+				t.Errorf("HighRisk() = %v, want %v", advisory.HighRisk(), tt.wantHighRisk)
+			}
+		})
+	}
+}
+
+func TestGetTravelAdvisoryFromGemini(t *testing.T) {
+	advisory := `{"country":"Iceland","country_code":"IS","level":1,"safety_notes":["Check road conditions before driving in winter"],"visa":"No visa needed for stays of up to 90 days"}`
+	tests := []struct {
+		name        string
+		destination string
+		apiKey      string
+		reply       string
+		wantErr     error
+	}{
+		{name: "generated", destination: "Reykjavik", apiKey: "key", reply: "```json\n" + advisory + "\n```"},
+		{name: "unknown level", destination: "Reykjavik", apiKey: "key", reply: `{"country":"Iceland","level":7}`, wantErr: ErrNotFound},
+		{name: "unusable reply", destination: "Reykjavik", apiKey: "key", reply: "I can't help with that", wantErr: ErrNotFound},
+		{name: "no API key", destination: "Reykjavik", wantErr: ErrNotFound},
+		{name: "no destination", destination: " ", apiKey: "key", wantErr: ErrValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				text, _ := json.Marshal(tt.reply)
+				fmt.Fprintf(w, `{"candidates":[{"content":{"parts":[{"text":%s}]}}]}`, text)
+			}))
+			defer server.Close()
+
+			g := &GeminiService{apiKey: tt.apiKey, httpClient: server.Client(), baseURL: server.URL, cache: cache.NewMemoryCache()}
+			got, err := g.GetTravelAdvisory(context.Background(), tt.destination, "Indian")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetTravelAdvisory error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.Country != "Iceland" || got.Source != AdvisorySourceGemini || !got.AIGenerated || got.LevelLabel != advisoryLevelLabels[AdvisoryNormal] {
+				t.Errorf("advisory = %+v, want a labelled AI-generated advisory for Iceland", got)
+			}
+			if got.RequiredVaccinations == nil || got.RecommendedVaccinations == nil {
+				t.Errorf("vaccinations = %v, %v; want empty lists rather than nil", got.RequiredVaccinations, got.RecommendedVaccinations)
+			}
+
+			if _, err := g.GetTravelAdvisory(context.Background(), tt.destination, "Indian"); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 1 {
+				t.Errorf("Gemini called %d times, want the second lookup served from the cache", calls)
+			}
+		})
+	}
+}
+
+func TestAdvisoryImportantInfo(t *testing.T) {
+	tests := []struct {
+		name     string
+		advisory Advisory
+		want     string
+	}{
+		{
+			name: "everything",
+			advisory: Advisory{
+				Country: "Yemen", Level: AdvisoryDoNotTravel, LevelLabel: "Do not travel",
+				RequiredVaccinations: []string{"Polio"}, RecommendedVaccinations: []string{"Typhoid", "Cholera"},
+				Visa: "Visa required", SafetyNotes: []string{"Risk of armed conflict"}, Note: "Check official advice",
+			},
+			want: "Travel advisory for Yemen: level 4, do not travel|Required vaccinations: Polio|Recommended vaccinations: Typhoid, Cholera|Visa: Visa required|Risk of armed conflict|Check official advice",
+		},
+		{
+			name:     "no vaccinations or visa",
+			advisory: Advisory{Country: "Japan", Level: AdvisoryNormal, LevelLabel: "Exercise normal precautions", Note: "Check official advice"},
+			want:     "Travel advisory for Japan: level 1, exercise normal precautions|Check official advice",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(tt.advisory.ImportantInfo(), "|"); got != tt.want {
+				t.Errorf("ImportantInfo() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// budgetTrendThreshold is the relative change in average budget below which
+// the trend is reported as stable
+const budgetTrendThreshold = 0.1
+
+// TravelPatterns summarizes a user's trip history
+type TravelPatterns struct {
+	TripCount           int            `json:"trip_count"`
+	SeasonCounts        map[string]int `json:"season_counts"`
+	PreferredSeasons    []string       `json:"preferred_seasons"`
+	AverageTripDays     float64        `json:"average_trip_days"`
+	TopRegions          []RegionCount  `json:"top_regions"`
+	BudgetTrend         string         `json:"budget_trend"` // increasing, decreasing, stable, insufficient_data
+	BudgetChangePercent float64        `json:"budget_change_percent"`
+	SoloTrips           int            `json:"solo_trips"`
+	GroupTrips          int            `json:"group_trips"`
+	SoloRatio           float64        `json:"solo_ratio"`
+}
+
+// RegionCount is how many trips went to a region
+type RegionCount struct {
+	Region string `json:"region"`
+	Trips  int    `json:"trips"`
+}
+
+// AnalyzeTravelPatterns summarizes the user's stored trips
+func (f *FirebaseService) AnalyzeTravelPatterns(ctx context.Context, userID string) (*TravelPatterns, error) {
+	trips, err := f.GetUserTrips(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user trips: %v", err)
+	}
+
+	return summarizeTravelPatterns(trips), nil
+}
+
+// summarizeTravelPatterns computes the patterns. Sections that need more
+// history than the user has are left empty rather than guessed.
+func summarizeTravelPatterns(trips []TripData) *TravelPatterns {
+	patterns := &TravelPatterns{
+		SeasonCounts:     make(map[string]int),
+		PreferredSeasons: []string{},
+		TopRegions:       []RegionCount{},
+		BudgetTrend:      "insufficient_data",
+	}
+
+	type datedBudget struct {
+		start  time.Time
+		budget float64
+	}
+	var budgets []datedBudget
+	var totalDays, datedTrips int
+	regions := make(map[string]int)
+
+	for _, trip := range trips {
+		if trip.Status == "deleted" {
+			continue
+		}
+		patterns.TripCount++
+
+		start := timeFromValue(trip.StartDate)
+		end := timeFromValue(trip.EndDate)
+		if !start.IsZero() {
+			patterns.SeasonCounts[seasonOf(start)]++
+			if !end.IsZero() && !end.Before(start) {
+				totalDays += int(end.Sub(start).Hours()/24) + 1
+				datedTrips++
+			}
+			if trip.Budget > 0 {
+				budgets = append(budgets, datedBudget{start, trip.Budget})
+			}
+		}
+
+		if region := regionOf(trip.Destination); region != "" {
+			regions[region]++
+		}
+
+		if trip.Travelers <= 1 {
+			patterns.SoloTrips++
+		} else {
+			patterns.GroupTrips++
+		}
+	}
+
+	if patterns.TripCount > 0 {
+		patterns.SoloRatio = float64(patterns.SoloTrips) / float64(patterns.TripCount)
+	}
+	if datedTrips > 0 {
+		patterns.AverageTripDays = float64(totalDays) / float64(datedTrips)
+	}
+
+	// Preferred seasons are all seasons tied for the most trips
+	best := 0
+	for _, count := range patterns.SeasonCounts {
+		if count > best {
+			best = count
+		}
+	}
+	for _, season := range []string{"Spring", "Summer", "Fall", "Winter"} {
+		if best > 0 && patterns.SeasonCounts[season] == best {
+			patterns.PreferredSeasons = append(patterns.PreferredSeasons, season)
+		}
+	}
+
+	for region, count := range regions {
+		patterns.TopRegions = append(patterns.TopRegions, RegionCount{Region: region, Trips: count})
+	}
+	sort.Slice(patterns.TopRegions, func(i, j int) bool {
+		if patterns.TopRegions[i].Trips != patterns.TopRegions[j].Trips {
+			return patterns.TopRegions[i].Trips > patterns.TopRegions[j].Trips
+		}
+		return patterns.TopRegions[i].Region < patterns.TopRegions[j].Region
+	})
+	if len(patterns.TopRegions) > 3 {
+		patterns.TopRegions = patterns.TopRegions[:3]
+	}
+
+	// Budget trend compares the older half of trips with the newer half
+	if len(budgets) >= 2 {
+		sort.Slice(budgets, func(i, j int) bool { return budgets[i].start.Before(budgets[j].start) })
+		half := len(budgets) / 2
+		var older, newer float64
+		for i, b := range budgets {
+			if i < half {
+				older += b.budget
+			} else {
+				newer += b.budget
+			}
+		}
+		older /= float64(half)
+		newer /= float64(len(budgets) - half)
+
+		change := (newer - older) / older
+		patterns.BudgetChangePercent = math.Round(change*1000) / 10
+		switch {
+		case change > budgetTrendThreshold:
+			patterns.BudgetTrend = "increasing"
+		case change < -budgetTrendThreshold:
+			patterns.BudgetTrend = "decreasing"
+		default:
+			patterns.BudgetTrend = "stable"
+		}
+	}
+
+	return patterns
+}
+
+// seasonOf uses the same month buckets as GetSeasonalTrends
+func seasonOf(t time.Time) string {
+	switch t.Month() {
+	case time.December, time.January, time.February:
+		return "Winter"
+	case time.March, time.April, time.May:
+		return "Spring"
+	case time.June, time.July, time.August:
+		return "Summer"
+	default:
+		return "Fall"
+	}
+}
+
+// regionOf takes the last component of a destination such as
+// "Jaipur, Rajasthan, India" as its region
+func regionOf(destination string) string {
+	parts := strings.Split(destination, ",")
+	return strings.TrimSpace(parts[len(parts)-1])
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummarizeTravelPatterns(t *testing.T) {
+	trip := func(destination string, month time.Month, days int, budget float64, travelers int) TripData {
+		start := time.Date(2025, month, 10, 0, 0, 0, 0, time.UTC)
+		return TripData{Destination: destination, StartDate: start, EndDate: start.AddDate(0, 0, days-1), Budget: budget, Travelers: travelers, Status: "planned"}
+	}
+	deleted := trip("Paris, France", time.July, 5, 90000, 1)
+	deleted.Status = "deleted"
+	undated := TripData{Destination: "Kyoto, Japan", Travelers: 2, Budget: 50000}
+
+	tests := []struct {
+		name        string
+		trips       []TripData
+		wantTrips   int
+		wantSeasons string
+		wantDays    float64
+		wantRegions string
+		wantTrend   string
+		wantChange  float64
+		wantSolo    float64
+	}{
+		{
+			name: "rising budgets",
+			trips: []TripData{
+				trip("Goa, India", time.January, 3, 10000, 1),
+				trip("Jaipur, Rajasthan, India", time.February, 4, 12000, 2),
+				trip("Bali, Indonesia", time.June, 5, 20000, 2),
+				trip("Kyoto, Japan", time.July, 7, 24000, 1),
+			},
+			wantTrips: 4, wantSeasons: "Summer,Winter", wantDays: 4.75, wantRegions: "India:2,Indonesia:1,Japan:1",
+			wantTrend: "increasing", wantChange: 100, wantSolo: 0.5,
+		},
+		{
+			name: "falling budgets",
+			trips: []TripData{
+				trip("Lisbon, Portugal", time.March, 4, 30000, 2),
+				trip("Porto, Portugal", time.October, 3, 18000, 2),
+			},
+			wantTrips: 2, wantSeasons: "Spring,Fall", wantDays: 3.5, wantRegions: "Portugal:2",
+			wantTrend: "decreasing", wantChange: -40, wantSolo: 0,
+		},
+		{
+			name: "stable within the threshold",
+			trips: []TripData{
+				trip("Goa, India", time.April, 2, 10000, 1),
+				trip("Goa, India", time.May, 2, 10500, 1),
+			},
+			wantTrips: 2, wantSeasons: "Spring", wantDays: 2, wantRegions: "India:2",
+			wantTrend: "stable", wantChange: 5, wantSolo: 1,
+		},
+		{
+			name:      "deleted and undated trips",
+			trips:     []TripData{deleted, undated, trip("Goa, India", time.December, 3, 15000, 1)},
+			wantTrips: 2, wantSeasons: "Winter", wantDays: 3, wantRegions: "India:1,Japan:1",
+			wantTrend: "insufficient_data", wantSolo: 0.5,
+		},
+		{
+			name:      "no trips",
+			wantTrend: "insufficient_data",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarizeTravelPatterns(tt.trips)
+			regions := make([]string, len(got.TopRegions))
+			for i, region := range got.TopRegions {
+				regions[i] = fmt.Sprintf("%s:%d", region.Region, region.Trips)
+			}
+			if got.TripCount != tt.wantTrips || strings.Join(got.PreferredSeasons, ",") != tt.wantSeasons || got.AverageTripDays != tt.wantDays {
+				t.Errorf("trips %d, seasons %v, days %.2f; want %d, %s, %.2f", got.TripCount, got.PreferredSeasons, got.AverageTripDays, tt.wantTrips, tt.wantSeasons, tt.wantDays)
+			}
+			if strings.Join(regions, ",") != tt.wantRegions {
+				t.Errorf("top regions = %v, want %s", regions, tt.wantRegions)
+			}
+			if got.BudgetTrend != tt.wantTrend || got.BudgetChangePercent != tt.wantChange || got.SoloRatio != tt.wantSolo {
+				t.Errorf("budget %s (%.1f%%), solo ratio %.2f; want %s (%.1f%%), %.2f", got.BudgetTrend, got.BudgetChangePercent, got.SoloRatio, tt.wantTrend, tt.wantChange, tt.wantSolo)
+			}
+		})
+	}
+}
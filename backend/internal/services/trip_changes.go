@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"auratravel-backend/internal/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TripChanges is what changed on a trip between Since and Until, grouped by
+// type
+type TripChanges struct {
+	TripID         string                  `json:"trip_id"`
+	Since          time.Time               `json:"since"`
+	Until          time.Time               `json:"until"`
+	Activities     []models.Activity       `json:"activities"`
+	Accommodations []models.Accommodation  `json:"accommodations"`
+	Transportation []models.Transportation `json:"transportation"`
+	Replans        []TripReplanChange      `json:"replans"`
+	Total          int                     `json:"total"`
+}
+
+// TripReplanChange is a replan of the trip, without the full plans it
+// changed between
+type TripReplanChange struct {
+	ReplanTimestamp time.Time           `json:"replan_timestamp"`
+	Triggers        []ReplanningTrigger `json:"triggers"`
+	Changes         []ItineraryChange   `json:"changes"`
+}
+
+// tripView records when a user last viewed a trip
+type tripView struct {
+	TripID       string    `firestore:"trip_id"`
+	UserID       string    `firestore:"user_id"`
+	LastViewedAt time.Time `firestore:"last_viewed_at"`
+}
+
+func tripViewID(tripID, userID string) string {
+	return tripID + "_" + userID
+}
+
+// TripLastViewed returns when the user last viewed the trip, or the zero
+// time if they never have
+func (f *FirebaseService) TripLastViewed(ctx context.Context, tripID, userID string) (time.Time, error) {
+	doc, err := f.firestore.Collection("trip_views").Doc(tripViewID(tripID, userID)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get last viewed time: %v", err)
+	}
+	var view tripView
+	if err := doc.DataTo(&view); err != nil {
+		return time.Time{}, fmt.Errorf("failed to convert trip view: %v", err)
+	}
+	return view.LastViewedAt, nil
+}
+
+// MarkTripViewed records that the user viewed the trip at the given time
+func (f *FirebaseService) MarkTripViewed(ctx context.Context, tripID, userID string, at time.Time) error {
+	_, err := f.firestore.Collection("trip_views").Doc(tripViewID(tripID, userID)).Set(ctx, tripView{
+		TripID:       tripID,
+		UserID:       userID,
+		LastViewedAt: at,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record trip view: %v", err)
+	}
+	return nil
+}
+
+// GetTripChanges returns the activities, bookings and replans of a trip
+// created or modified after since. userID must be able to view the trip.
+func (f *FirebaseService) GetTripChanges(ctx context.Context, tripID, userID string, since time.Time) (*TripChanges, error) {
+	details, err := f.GetTripWithItinerary(ctx, tripID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	docs, err := f.firestore.Collection("trip_replanning").Where("TripID", "==", tripID).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip replans: %v", err)
+	}
+	replans := make([]ReplanningResult, 0, len(docs))
+	for _, doc := range docs {
+		var replan ReplanningResult
+		if err := doc.DataTo(&replan); err != nil {
+			return nil, fmt.Errorf("failed to convert trip replan: %v", err)
+		}
+		replans = append(replans, replan)
+	}
+
+	return tripChangesSince(details, replans, since, time.Now()), nil
+}
+
+// tripChangesSince picks out what in a trip was created or modified after
+// since, oldest first
+func tripChangesSince(details *models.TripWithDetails, replans []ReplanningResult, since, until time.Time) *TripChanges {
+	changes := &TripChanges{
+		TripID:         details.Trip.ID,
+		Since:          since,
+		Until:          until,
+		Activities:     []models.Activity{},
+		Accommodations: []models.Accommodation{},
+		Transportation: []models.Transportation{},
+		Replans:        []TripReplanChange{},
+	}
+
+	for _, day := range details.Days {
+		for _, activity := range day.Activities {
+			if changedSince(activity.CreatedAt, activity.UpdatedAt, since) {
+				changes.Activities = append(changes.Activities, activity)
+			}
+		}
+	}
+	for _, accommodation := range details.Accommodations {
+		if changedSince(accommodation.CreatedAt, accommodation.UpdatedAt, since) {
+			changes.Accommodations = append(changes.Accommodations, accommodation)
+		}
+	}
+	for _, transport := range details.Transportation {
+		if changedSince(transport.CreatedAt, transport.UpdatedAt, since) {
+			changes.Transportation = append(changes.Transportation, transport)
+		}
+	}
+	for _, replan := range replans {
+		if replan.ReplanTimestamp.After(since) {
+			changes.Replans = append(changes.Replans, TripReplanChange{
+				ReplanTimestamp: replan.ReplanTimestamp,
+				Triggers:        replan.Triggers,
+				Changes:         replan.Changes,
+			})
+		}
+	}
+
+	sort.SliceStable(changes.Activities, func(i, j int) bool {
+		return changes.Activities[i].UpdatedAt.Before(changes.Activities[j].UpdatedAt)
+	})
+	sort.SliceStable(changes.Accommodations, func(i, j int) bool {
+		return changes.Accommodations[i].UpdatedAt.Before(changes.Accommodations[j].UpdatedAt)
+	})
+	sort.SliceStable(changes.Transportation, func(i, j int) bool {
+		return changes.Transportation[i].UpdatedAt.Before(changes.Transportation[j].UpdatedAt)
+	})
+	sort.SliceStable(changes.Replans, func(i, j int) bool {
+		return changes.Replans[i].ReplanTimestamp.Before(changes.Replans[j].ReplanTimestamp)
+	})
+
+	changes.Total = len(changes.Activities) + len(changes.Accommodations) + len(changes.Transportation) + len(changes.Replans)
+	return changes
+}
+
+// changedSince reports whether a row was created or modified after since
+func changedSince(createdAt, updatedAt, since time.Time) bool {
+	return createdAt.After(since) || updatedAt.After(since)
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"auratravel-backend/internal/models"
+)
+
+func TestTripChangesSince(t *testing.T) {
+	since := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	before, after, later := since.Add(-time.Hour), since.Add(time.Hour), since.Add(2*time.Hour)
+
+	details := &models.TripWithDetails{
+		Trip: models.Trip{ID: "trip-1"},
+		Days: []models.DayPlanDetails{
+			{Activities: []models.Activity{
+				{ID: "unchanged", CreatedAt: before, UpdatedAt: before},
+				{ID: "edited-later", CreatedAt: before, UpdatedAt: later},
+			}},
+			{Activities: []models.Activity{
+				{ID: "added", CreatedAt: after, UpdatedAt: after},
+				{ID: "edited-at-since", CreatedAt: before, UpdatedAt: since},
+			}},
+		},
+		Accommodations: []models.Accommodation{
+			{ID: "old-hotel", CreatedAt: before, UpdatedAt: before},
+			{ID: "new-hotel", CreatedAt: after, UpdatedAt: after},
+		},
+		Transportation: []models.Transportation{
+			{ID: "flight", CreatedAt: before, UpdatedAt: later},
+		},
+	}
+	replans := []ReplanningResult{
+		{ReplanTimestamp: later, Triggers: []ReplanningTrigger{{Type: "weather"}}},
+		{ReplanTimestamp: before},
+		{ReplanTimestamp: after},
+	}
+
+	changes := tripChangesSince(details, replans, since, later)
+
+	var activityIDs []string
+	for _, activity := range changes.Activities {
+		activityIDs = append(activityIDs, activity.ID)
+	}
+	if len(activityIDs) != 2 || activityIDs[0] != "added" || activityIDs[1] != "edited-later" {
+		t.Errorf("activities = %v, want [added edited-later], oldest change first", activityIDs)
+	}
+	if len(changes.Accommodations) != 1 || changes.Accommodations[0].ID != "new-hotel" {
+		t.Errorf("accommodations = %+v, want only new-hotel", changes.Accommodations)
+	}
+	if len(changes.Transportation) != 1 || changes.Transportation[0].ID != "flight" {
+		t.Errorf("transportation = %+v, want only flight", changes.Transportation)
+	}
+	if len(changes.Replans) != 2 || !changes.Replans[0].ReplanTimestamp.Equal(after) || len(changes.Replans[1].Triggers) != 1 {
+		t.Errorf("replans = %+v, want the two after since, oldest first", changes.Replans)
+	}
+	if changes.Total != 6 || changes.TripID != "trip-1" {
+		t.Errorf("total = %d for %s, want 6 for trip-1", changes.Total, changes.TripID)
+	}
+}
+
+func TestTripChangesSinceWithNothingNew(t *testing.T) {
+	changes := tripChangesSince(&models.TripWithDetails{Trip: models.Trip{ID: "trip-1"}}, nil, time.Now(), time.Now())
+	// Empty lists, not nulls, so clients can iterate without checking
+	if changes.Activities == nil || changes.Accommodations == nil || changes.Transportation == nil || changes.Replans == nil || changes.Total != 0 {
+		t.Errorf("changes = %+v, want empty lists", changes)
+	}
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/models"
+
+	"firebase.google.com/go/v4/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Trip roles
+const (
+	TripRoleOwner  = "owner"
+	TripRoleEditor = "editor"
+	TripRoleViewer = "viewer"
+)
+
+// TripAction is something a user can do to a trip
+type TripAction string
+
+// Trip actions, from least to most privileged
+const (
+	// TripActionView reads the trip and renders or downloads its itinerary
+	TripActionView TripAction = "view"
+	// TripActionEdit changes the itinerary or sends it to others
+	TripActionEdit TripAction = "edit"
+	// TripActionManage deletes or restores the trip, changes its
+	// visibility and invites collaborators
+	TripActionManage TripAction = "manage"
+)
+
+// Collaboration errors
+var (
+	ErrInvalidRole         = errors.New("role must be editor or viewer")
+	ErrInviteeNotFound     = errors.New("no user with that email")
+	ErrAlreadyCollaborator = errors.New("user already has access to this trip")
+	ErrInviteNotFound      = errors.New("no pending invite for this trip")
+)
+
+// RoleAllows reports whether a trip role permits an action. Viewers can only
+// view, editors can also edit, and only the owner can manage the trip.
+func RoleAllows(role string, action TripAction) bool {
+	switch role {
+	case TripRoleOwner:
+		return true
+	case TripRoleEditor:
+		return action == TripActionView || action == TripActionEdit
+	case TripRoleViewer:
+		return action == TripActionView
+	default:
+		return false
+	}
+}
+
+// AuthorizeTrip loads a trip and returns it with the user's role, provided
+// the role permits action. Deleted trips are only found for
+// TripActionManage, so their owner can restore them. It returns
+// *TripNotFoundError or *TripPermissionError when access is refused.
+func (f *FirebaseService) AuthorizeTrip(ctx context.Context, tripID, userID string, action TripAction) (*TripData, string, error) {
+	tripDoc, err := f.firestore.Collection("trips").Doc(tripID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, "", &TripNotFoundError{TripID: tripID}
+		}
+		return nil, "", fmt.Errorf("failed to get trip: %v", err)
+	}
+
+	var trip TripData
+	if err := tripDoc.DataTo(&trip); err != nil {
+		return nil, "", fmt.Errorf("failed to convert trip data: %v", err)
+	}
+	trip.ID = tripID
+	if trip.Status == "deleted" && action != TripActionManage {
+		return nil, "", &TripNotFoundError{TripID: tripID}
+	}
+
+	role, err := f.tripRole(ctx, &trip, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !RoleAllows(role, action) {
+		return nil, role, &TripPermissionError{TripID: tripID, UserID: userID}
+	}
+	return &trip, role, nil
+}
+
+// tripRole returns the user's role on a trip: owner, the role of an accepted
+// collaborator, or "" when they have none
+func (f *FirebaseService) tripRole(ctx context.Context, trip *TripData, userID string) (string, error) {
+	if userID == "" {
+		return "", nil
+	}
+	if trip.UserID == userID {
+		return TripRoleOwner, nil
+	}
+
+	doc, err := f.firestore.Collection("trip_collaborators").Doc(collaboratorDocID(trip.ID, userID)).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to get trip collaborator: %v", err)
+	}
+	data := doc.Data()
+	if data["accepted_at"] == nil {
+		return "", nil
+	}
+	role, _ := data["role"].(string)
+	return role, nil
+}
+
+// collaboratorDocID keys collaborators by trip and user, so a user has at
+// most one invite per trip
+func collaboratorDocID(tripID, userID string) string {
+	return tripID + "_" + userID
+}
+
+// CollaborationService invites users to trips and accepts invites
+type CollaborationService struct {
+	firebase      *FirebaseService
+	notifications *NotificationService
+}
+
+// NewCollaborationService creates a new collaboration service
+func NewCollaborationService(firebase *FirebaseService, notifications *NotificationService) *CollaborationService {
+	return &CollaborationService{
+		firebase:      firebase,
+		notifications: notifications,
+	}
+}
+
+// InviteCollaborator invites the user with inviteeEmail to a trip as an
+// editor or viewer. Only the owner can invite. The invite stays pending until
+// the invitee accepts it; inviting someone with a pending invite again
+// replaces its role.
+func (s *CollaborationService) InviteCollaborator(ctx context.Context, tripID, inviterID, inviteeEmail, role string) (*models.TripCollaborator, error) {
+	if role != TripRoleEditor && role != TripRoleViewer {
+		return nil, ErrInvalidRole
+	}
+
+	trip, _, err := s.firebase.AuthorizeTrip(ctx, tripID, inviterID, TripActionManage)
+	if err != nil {
+		return nil, err
+	}
+
+	inviteeEmail = strings.TrimSpace(inviteeEmail)
+	invitee, err := s.firebase.auth.GetUserByEmail(ctx, inviteeEmail)
+	if err != nil {
+		if auth.IsUserNotFound(err) {
+			return nil, ErrInviteeNotFound
+		}
+		return nil, fmt.Errorf("failed to look up invitee: %v", err)
+	}
+	if invitee.UID == trip.UserID {
+		return nil, ErrAlreadyCollaborator
+	}
+
+	ref := s.firebase.firestore.Collection("trip_collaborators").Doc(collaboratorDocID(tripID, invitee.UID))
+	if existing, err := ref.Get(ctx); err == nil {
+		if existing.Data()["accepted_at"] != nil {
+			return nil, ErrAlreadyCollaborator
+		}
+	} else if status.Code(err) != codes.NotFound {
+		return nil, fmt.Errorf("failed to check existing invite: %v", err)
+	}
+
+	now := time.Now()
+	collaborator := &models.TripCollaborator{
+		ID:           ref.ID,
+		TripID:       tripID,
+		UserID:       invitee.UID,
+		Role:         role,
+		InvitedBy:    inviterID,
+		InvitedAt:    now,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		InviteeEmail: inviteeEmail,
+	}
+	data, err := modelDoc(collaborator)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ref.Set(ctx, data); err != nil {
+		return nil, fmt.Errorf("failed to save invite: %v", err)
+	}
+
+	s.sendInviteNotification(ctx, trip, inviterID, collaborator)
+	return collaborator, nil
+}
+
+// sendInviteNotification tells the invitee about their invite. The invite is
+// already saved, so a failure is only logged.
+func (s *CollaborationService) sendInviteNotification(ctx context.Context, trip *TripData, inviterID string, collaborator *models.TripCollaborator) {
+	if s.notifications == nil {
+		return
+	}
+
+	inviterName := "A fellow traveler"
+	if inviter, err := s.firebase.GetUser(ctx, inviterID); err == nil && inviter.DisplayName != "" {
+		inviterName = inviter.DisplayName
+	}
+	tripName := trip.Title
+	if tripName == "" {
+		tripName = fmt.Sprintf("a trip to %s", trip.Destination)
+	}
+
+	_, err := s.notifications.SendNotification(ctx, &NotificationRequest{
+		UserID:   collaborator.UserID,
+		TripID:   trip.ID,
+		Type:     TripInviteType,
+		Priority: PriorityNormal,
+		Title:    "Trip invitation",
+		Body:     fmt.Sprintf("%s invited you to %s as %s", inviterName, tripName, collaborator.Role),
+		Data: map[string]string{
+			"trip_id":    trip.ID,
+			"role":       collaborator.Role,
+			"invited_by": inviterID,
+		},
+		ActionURL: fmt.Sprintf("/trips/%s/accept-invite", trip.ID),
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to send trip invite notification", "trip_id", trip.ID, "invitee", collaborator.UserID, "error", err)
+	}
+}
+
+// AcceptInvite accepts the user's pending invite to a trip. Accepting an
+// invite twice returns the collaborator unchanged. Invites to a deleted trip
+// can't be accepted; the trip is reported as not found, as it is to them
+// everywhere else.
+func (s *CollaborationService) AcceptInvite(ctx context.Context, tripID, userID string) (*models.TripCollaborator, error) {
+	tripDoc, err := s.firebase.firestore.Collection("trips").Doc(tripID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &TripNotFoundError{TripID: tripID}
+		}
+		return nil, fmt.Errorf("failed to get trip: %v", err)
+	}
+	if tripStatus, _ := tripDoc.Data()["status"].(string); tripStatus == "deleted" {
+		return nil, &TripNotFoundError{TripID: tripID}
+	}
+
+	ref := s.firebase.firestore.Collection("trip_collaborators").Doc(collaboratorDocID(tripID, userID))
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrInviteNotFound
+		}
+		return nil, fmt.Errorf("failed to get invite: %v", err)
+	}
+
+	// Collaborators are stored in their JSON representation, like the other
+	// models
+	var collaborator models.TripCollaborator
+	raw, err := json.Marshal(doc.Data())
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &collaborator); err != nil {
+		return nil, fmt.Errorf("failed to decode invite: %v", err)
+	}
+	if collaborator.AcceptedAt != nil {
+		return &collaborator, nil
+	}
+
+	now := time.Now()
+	collaborator.AcceptedAt = &now
+	collaborator.UpdatedAt = now
+	data, err := modelDoc(collaborator)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ref.Set(ctx, data); err != nil {
+		return nil, fmt.Errorf("failed to accept invite: %v", err)
+	}
+	return &collaborator, nil
+}
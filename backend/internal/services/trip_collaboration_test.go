@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role               string
+		view, edit, manage bool
+	}{
+		{TripRoleOwner, true, true, true},
+		{TripRoleEditor, true, true, false},
+		{TripRoleViewer, true, false, false},
+		{"", false, false, false},
+		{"admin", false, false, false},
+	}
+	for _, tt := range tests {
+		for action, want := range map[TripAction]bool{TripActionView: tt.view, TripActionEdit: tt.edit, TripActionManage: tt.manage} {
+			if got := RoleAllows(tt.role, action); got != want {
+				t.Errorf("RoleAllows(%q, %s) = %v, want %v", tt.role, action, got, want)
+			}
+		}
+	}
+}
+
+func TestAcceptInvite(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	collaboration := NewCollaborationService(fb, nil)
+	ctx := context.Background()
+	invite := func(tripID, userID string) {
+		seed(t, fb, "trip_collaborators/"+collaboratorDocID(tripID, userID), map[string]interface{}{
+			"trip_id": tripID, "user_id": userID, "role": TripRoleEditor, "invited_by": "owner",
+		})
+	}
+	seed(t, fb, "trips/t1", map[string]interface{}{"user_id": "owner", "status": "planned"})
+	seed(t, fb, "trips/gone", map[string]interface{}{"user_id": "owner", "status": "deleted"})
+	invite("t1", "u1")
+	invite("gone", "u1")
+	invite("missing", "u1")
+
+	t.Run("pending invite", func(t *testing.T) {
+		collaborator, err := collaboration.AcceptInvite(ctx, "t1", "u1")
+		if err != nil || collaborator.AcceptedAt == nil {
+			t.Fatalf("AcceptInvite = %+v, %v; want it accepted", collaborator, err)
+		}
+		if _, role, err := fb.AuthorizeTrip(ctx, "t1", "u1", TripActionEdit); err != nil || role != TripRoleEditor {
+			t.Errorf("AuthorizeTrip after accepting = %q, %v; want editor", role, err)
+		}
+
+		again, err := collaboration.AcceptInvite(ctx, "t1", "u1")
+		if err != nil || !again.AcceptedAt.Equal(*collaborator.AcceptedAt) {
+			t.Errorf("accepting again = %+v, %v; want it unchanged", again, err)
+		}
+	})
+
+	t.Run("no invite", func(t *testing.T) {
+		if _, err := collaboration.AcceptInvite(ctx, "t1", "u2"); !errors.Is(err, ErrInviteNotFound) {
+			t.Errorf("AcceptInvite = %v, want ErrInviteNotFound", err)
+		}
+	})
+
+	for _, tripID := range []string{"gone", "missing"} {
+		t.Run(tripID+" trip", func(t *testing.T) {
+			var notFound *TripNotFoundError
+			if _, err := collaboration.AcceptInvite(ctx, tripID, "u1"); !errors.As(err, &notFound) {
+				t.Errorf("AcceptInvite = %v, want the trip not found", err)
+			}
+			if accepted := fake.fields("trip_collaborators/" + collaboratorDocID(tripID, "u1"))["accepted_at"]; accepted != nil {
+				t.Errorf("invite to a %s trip was accepted", tripID)
+			}
+		})
+	}
+}
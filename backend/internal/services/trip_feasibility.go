@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feasibility issue severities. An error means the trip can't work as
+// asked; a warning means it can, but probably not as the traveler expects.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// FeasibilityIssue is one problem found with a trip before planning it
+type FeasibilityIssue struct {
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// TripFeasibilityRequest is a trip to check before planning it. Budget is in
+// Currency, USD when empty.
+type TripFeasibilityRequest struct {
+	Destination  string
+	Destinations []DestinationLeg
+	StartDate    time.Time
+	EndDate      time.Time
+	Budget       float64
+	Currency     string
+	Travelers    int
+	TravelStyle  string
+}
+
+// stayLength is the range of days a destination is usually visited for
+type stayLength struct {
+	minDays int
+	maxDays int
+}
+
+// destinationStayLengths are typical stays at popular destinations. Shorter
+// trips miss most of what they're visited for; longer ones run out of it.
+var destinationStayLengths = map[string]stayLength{
+	"paris":     {minDays: 3, maxDays: 10},
+	"london":    {minDays: 3, maxDays: 10},
+	"rome":      {minDays: 3, maxDays: 8},
+	"tokyo":     {minDays: 3, maxDays: 14},
+	"new york":  {minDays: 3, maxDays: 10},
+	"bangkok":   {minDays: 2, maxDays: 10},
+	"delhi":     {minDays: 2, maxDays: 6},
+	"agra":      {minDays: 1, maxDays: 2},
+	"jaipur":    {minDays: 2, maxDays: 5},
+	"goa":       {minDays: 3, maxDays: 14},
+	"mumbai":    {minDays: 2, maxDays: 6},
+	"varanasi":  {minDays: 2, maxDays: 4},
+	"kerala":    {minDays: 4, maxDays: 14},
+	"singapore": {minDays: 2, maxDays: 6},
+	"dubai":     {minDays: 2, maxDays: 7},
+}
+
+// travelStyleBudgetPreference maps a trip's travel style to the cost
+// predictor's budget preference
+var travelStyleBudgetPreference = map[string]string{
+	"budget":   "budget",
+	"balanced": "mid-range",
+	"luxury":   "luxury",
+}
+
+// CheckTripFeasibility runs pre-flight checks on a trip without planning
+// it: that its dates haven't passed, each stop's length suits the
+// destination, multi-city legs leave time to see each city, and the budget
+// covers the estimated cost. Errors come before warnings.
+func (tcp *TravelCostPredictor) CheckTripFeasibility(ctx context.Context, req TripFeasibilityRequest, rates ExchangeRateProvider) ([]FeasibilityIssue, error) {
+	var issues []FeasibilityIssue
+	add := func(field, code, severity, format string, args ...interface{}) {
+		issues = append(issues, FeasibilityIssue{Field: field, Code: code, Severity: severity, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// Dates
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.StartDate.Before(today) {
+		add("start_date", "start_in_past", SeverityError, "the trip starts on %s, which has already passed", req.StartDate.Format("2006-01-02"))
+	}
+	if !req.EndDate.After(req.StartDate) {
+		add("end_date", "end_before_start", SeverityError, "the trip must end after it starts")
+		return sortFeasibilityIssues(issues), nil
+	}
+	nights := int(req.EndDate.Sub(req.StartDate).Hours() / 24)
+
+	// Stops: each leg of a multi-city trip, or the whole trip
+	stops := []ScheduledLeg{{
+		DestinationLeg: DestinationLeg{Destination: req.Destination, Nights: nights},
+		ArrivalDate:    req.StartDate,
+		DepartureDate:  req.EndDate,
+	}}
+	if len(req.Destinations) > 0 {
+		stops = ScheduleLegs(req.Destinations, req.StartDate)
+		sameDay := false
+		for i, leg := range req.Destinations {
+			if leg.Nights < 1 {
+				sameDay = true
+				add("destinations", "same_day_leg", SeverityError,
+					"%s has no nights; a city can't be arrived at and left for the next on the same day", legName(leg, i))
+			}
+		}
+		// Same-day legs would be planned with a night each, so the legs'
+		// lengths only mean something once they're fixed
+		if !sameDay {
+			if total := TotalNights(req.Destinations); total != nights {
+				add("destinations", "nights_mismatch", SeverityError,
+					"the legs have %d nights but the trip has %d", total, nights)
+			}
+			for i := 1; i < len(stops); i++ {
+				if stops[i].Nights == 1 {
+					add("destinations", "short_leg", SeverityWarning,
+						"only one night in %s; most of it goes to getting there from %s", stops[i].Destination, stops[i-1].Destination)
+				}
+			}
+		}
+	}
+
+	for _, stop := range stops {
+		stay, ok := findStayLength(stop.Destination)
+		if !ok {
+			continue
+		}
+		field := "end_date"
+		if len(req.Destinations) > 0 {
+			field = "destinations"
+		}
+		// A stop of n nights gives n+1 days when it's the whole trip, and n
+		// days when the next leg's travel takes the last one
+		days := stop.Nights
+		if len(stops) == 1 {
+			days++
+		}
+		switch {
+		case days < stay.minDays:
+			add(field, "too_short", SeverityWarning,
+				"a %d-day stay in %s is short; it usually takes at least %d days", days, stop.Destination, stay.minDays)
+		case days > stay.maxDays:
+			add(field, "too_long", SeverityWarning,
+				"a %d-day stay in %s is long; most visits take at most %d days", days, stop.Destination, stay.maxDays)
+		}
+	}
+
+	// Budget against the estimated cost of every stop
+	if req.Budget > 0 {
+		estimate, minimum := 0.0, 0.0
+		for _, stop := range stops {
+			prediction, err := tcp.PredictTravelCost(ctx, CostPredictionRequest{
+				Destination:      tcp.costDestination(stop.Destination),
+				TravelDate:       stop.ArrivalDate,
+				Duration:         max(stop.Nights, 1),
+				Travelers:        max(req.Travelers, 1),
+				BudgetPreference: travelStyleBudgetPreference[strings.ToLower(req.TravelStyle)],
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to estimate trip cost: %w", err)
+			}
+			estimate += prediction.TotalEstimatedCost
+			minimum += prediction.CostRange.Minimum
+		}
+
+		// Estimates are in USD; compare them in the budget's currency
+		currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+		if currency == "" {
+			currency = "USD"
+		}
+		estimate, err := rates.Convert(estimate, "USD", currency)
+		if err != nil {
+			return nil, err
+		}
+		minimum, err = rates.Convert(minimum, "USD", currency)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case req.Budget < minimum:
+			add("budget", "budget_too_low", SeverityError,
+				"a budget of %.0f %s is below the lowest likely cost of %.0f %s", req.Budget, currency, minimum, currency)
+		case req.Budget < estimate:
+			add("budget", "budget_tight", SeverityWarning,
+				"a budget of %.0f %s is under the estimated cost of %.0f %s", req.Budget, currency, estimate, currency)
+		}
+	}
+
+	return sortFeasibilityIssues(issues), nil
+}
+
+// costDestination returns the destination the cost predictor has data for
+// that the given one names, like "paris" for "Paris, France"
+func (tcp *TravelCostPredictor) costDestination(destination string) string {
+	for name := range tcp.costFactors {
+		if containsWord(destination, name) {
+			return name
+		}
+	}
+	return destination
+}
+
+// findStayLength returns the typical stay at a destination
+func findStayLength(destination string) (stayLength, bool) {
+	for name, stay := range destinationStayLengths {
+		if containsWord(destination, name) {
+			return stay, true
+		}
+	}
+	return stayLength{}, false
+}
+
+// legName names a leg in messages, by its destination when it has one
+func legName(leg DestinationLeg, index int) string {
+	if leg.Destination != "" {
+		return leg.Destination
+	}
+	return fmt.Sprintf("leg %d", index+1)
+}
+
+// sortFeasibilityIssues puts errors before warnings, otherwise keeping the
+// order the checks found them in
+func sortFeasibilityIssues(issues []FeasibilityIssue) []FeasibilityIssue {
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Severity == SeverityError && issues[j].Severity != SeverityError
+	})
+	return issues
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckTripFeasibility(t *testing.T) {
+	tcp := NewTravelCostPredictor()
+	start := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 1, 0)
+	nights := func(n int) time.Time { return start.AddDate(0, 0, n) }
+
+	// A budget between the lowest likely cost and the estimate is tight
+	prediction, err := tcp.PredictTravelCost(context.Background(), CostPredictionRequest{Destination: "paris", TravelDate: start, Duration: 5, Travelers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tight := (prediction.CostRange.Minimum + prediction.TotalEstimatedCost) / 2
+	tightRupees, err := DefaultExchangeRates().Convert(tight, "USD", "INR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		req     TripFeasibilityRequest
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "feasible",
+			req:  TripFeasibilityRequest{Destination: "Paris, France", StartDate: start, EndDate: nights(5), Budget: 1e6, Travelers: 2},
+		},
+		{
+			name: "already started",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start.AddDate(0, -2, 0), EndDate: start.AddDate(0, -2, 5)},
+			want: "error:start_in_past",
+		},
+		{
+			name: "ends before it starts",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: start, Budget: 1},
+			want: "error:end_before_start",
+		},
+		{
+			name: "too short",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: nights(1)},
+			want: "warning:too_short",
+		},
+		{
+			name: "too long",
+			req:  TripFeasibilityRequest{Destination: "Agra", StartDate: start, EndDate: nights(5)},
+			want: "warning:too_long",
+		},
+		{
+			name: "legs don't add up",
+			req: TripFeasibilityRequest{StartDate: start, EndDate: nights(5),
+				Destinations: []DestinationLeg{{Destination: "Delhi", Nights: 2}, {Destination: "Agra", Nights: 1}}},
+			want: "error:nights_mismatch,warning:short_leg",
+		},
+		{
+			name: "same-day leg",
+			req: TripFeasibilityRequest{StartDate: start, EndDate: nights(3),
+				Destinations: []DestinationLeg{{Destination: "Delhi", Nights: 3}, {Nights: 0}}},
+			want: "error:same_day_leg",
+		},
+		{
+			name: "budget far too low",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: nights(5), Budget: 1, Travelers: 2},
+			want: "error:budget_too_low",
+		},
+		{
+			name: "tight budget",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: nights(5), Budget: tight, Travelers: 2},
+			want: "warning:budget_tight",
+		},
+		{
+			name: "tight budget in rupees",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: nights(5), Budget: tightRupees, Currency: "inr", Travelers: 2},
+			want: "warning:budget_tight",
+		},
+		{
+			name: "dollar amount in rupees",
+			req:  TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: nights(5), Budget: tight, Currency: "INR", Travelers: 2},
+			want: "error:budget_too_low",
+		},
+		{
+			name:    "unknown currency",
+			req:     TripFeasibilityRequest{Destination: "Paris", StartDate: start, EndDate: nights(5), Budget: 100, Currency: "XYZ"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := tcp.CheckTripFeasibility(context.Background(), tt.req, DefaultExchangeRates())
+			if tt.wantErr {
+				var unknown *UnknownCurrencyError
+				if !errors.As(err, &unknown) {
+					t.Fatalf("CheckTripFeasibility error = %v, want an unknown currency", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			codes := make([]string, len(issues))
+			for i, issue := range issues {
+				codes[i] = issue.Severity + ":" + issue.Code
+			}
+			if got := strings.Join(codes, ","); got != tt.want {
+				t.Errorf("issues = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// tripImportNamespace derives the IDs of trips imported under an external key
+var tripImportNamespace = uuid.MustParse("6f1c2f0e-4b7a-4d8e-9a53-2c1d7e5b8f40")
+
+// MaxTripImportBatch caps the trips one import can hold
+const MaxTripImportBatch = 200
+
+// Import row outcomes
+const (
+	TripImportCreated   = "created"
+	TripImportDuplicate = "duplicate"
+	TripImportInvalid   = "invalid"
+	TripImportFailed    = "failed"
+)
+
+// tripImportColumns are the CSV columns an import understands. The header
+// row names them, in any order; external_key and itinerary are optional.
+var tripImportColumns = []string{"external_key", "title", "destination", "start_date", "end_date", "budget", "travelers", "itinerary"}
+
+// TripImportRow is one trip to import
+type TripImportRow struct {
+	// ExternalKey is the client's own ID for the trip. A trip whose key is
+	// already imported is skipped, so re-running an import is safe.
+	ExternalKey string                 `json:"external_key"`
+	Title       string                 `json:"title"`
+	Destination string                 `json:"destination"`
+	StartDate   string                 `json:"start_date"` // YYYY-MM-DD
+	EndDate     string                 `json:"end_date"`   // YYYY-MM-DD
+	Budget      float64                `json:"budget"`
+	Travelers   int                    `json:"travelers"`
+	Itinerary   map[string]interface{} `json:"itinerary,omitempty"`
+
+	// parseErr is set when a CSV row's fields couldn't be read
+	parseErr error
+}
+
+// TripImportResult is the outcome of importing one row. Row numbers count
+// from 1, like the rows of the upload.
+type TripImportResult struct {
+	Row         int    `json:"row"`
+	ExternalKey string `json:"external_key,omitempty"`
+	Status      string `json:"status"`
+	TripID      string `json:"trip_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// TripImportSummary reports an import row by row
+type TripImportSummary struct {
+	Total      int                `json:"total"`
+	Created    int                `json:"created"`
+	Duplicates int                `json:"duplicates"`
+	Invalid    int                `json:"invalid"`
+	Failed     int                `json:"failed"`
+	Results    []TripImportResult `json:"results"`
+}
+
+// ParseTripImportJSON reads a JSON array of trips
+func ParseTripImportJSON(data []byte) ([]TripImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, kindErrorf(ErrValidation, "import must be a JSON array of trips: %v", err)
+	}
+
+	// Rows are decoded one at a time so a malformed row fails on its own
+	rows := make([]TripImportRow, len(raw))
+	for i, item := range raw {
+		if err := json.Unmarshal(item, &rows[i]); err != nil {
+			rows[i] = TripImportRow{parseErr: fmt.Errorf("malformed trip: %v", err)}
+		}
+	}
+	return rows, nil
+}
+
+// ParseTripImportCSV reads trips from CSV with a header row naming the
+// columns. The itinerary column, when present, holds JSON.
+func ParseTripImportCSV(data []byte) ([]TripImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, kindErrorf(ErrValidation, "import CSV needs a header row: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"destination", "start_date", "end_date"} {
+		if _, ok := columns[required]; !ok {
+			return nil, kindErrorf(ErrValidation, "import CSV is missing the %s column (columns: %s)", required, strings.Join(tripImportColumns, ", "))
+		}
+	}
+
+	var rows []TripImportRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// A row the CSV reader can't split fails on its own
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rows = append(rows, TripImportRow{parseErr: fmt.Errorf("malformed CSV row: %v", parseErr.Err)})
+				continue
+			}
+			return nil, kindErrorf(ErrValidation, "failed to read import CSV: %v", err)
+		}
+		rows = append(rows, tripImportRowFromCSV(columns, record))
+	}
+	return rows, nil
+}
+
+// tripImportRowFromCSV maps a CSV record onto a row, recording the first
+// field that can't be read
+func tripImportRowFromCSV(columns map[string]int, record []string) TripImportRow {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := TripImportRow{
+		ExternalKey: field("external_key"),
+		Title:       field("title"),
+		Destination: field("destination"),
+		StartDate:   field("start_date"),
+		EndDate:     field("end_date"),
+	}
+	if budget := field("budget"); budget != "" {
+		value, err := strconv.ParseFloat(budget, 64)
+		if err != nil {
+			row.parseErr = fmt.Errorf("budget %q is not a number", budget)
+			return row
+		}
+		row.Budget = value
+	}
+	if travelers := field("travelers"); travelers != "" {
+		value, err := strconv.Atoi(travelers)
+		if err != nil {
+			row.parseErr = fmt.Errorf("travelers %q is not a whole number", travelers)
+			return row
+		}
+		row.Travelers = value
+	}
+	if itinerary := field("itinerary"); itinerary != "" {
+		if err := json.Unmarshal([]byte(itinerary), &row.Itinerary); err != nil {
+			row.parseErr = fmt.Errorf("itinerary is not a JSON object: %v", err)
+			return row
+		}
+	}
+	return row
+}
+
+// Validate checks a row can become a trip
+func (r TripImportRow) Validate() error {
+	if r.parseErr != nil {
+		return r.parseErr
+	}
+	if strings.TrimSpace(r.Destination) == "" {
+		return fmt.Errorf("destination is required")
+	}
+	start, err := time.Parse("2006-01-02", r.StartDate)
+	if err != nil {
+		return fmt.Errorf("start_date must be YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", r.EndDate)
+	if err != nil {
+		return fmt.Errorf("end_date must be YYYY-MM-DD")
+	}
+	if end.Before(start) {
+		return fmt.Errorf("end_date must not be before start_date")
+	}
+	if r.Budget < 0 {
+		return fmt.Errorf("budget must not be negative")
+	}
+	if r.Travelers < 0 {
+		return fmt.Errorf("travelers must not be negative")
+	}
+	return nil
+}
+
+// tripData builds the trip a valid row imports as
+func (r TripImportRow) tripData(userID string, now time.Time) TripData {
+	start, _ := time.Parse("2006-01-02", r.StartDate)
+	end, _ := time.Parse("2006-01-02", r.EndDate)
+
+	title := strings.TrimSpace(r.Title)
+	if title == "" {
+		title = fmt.Sprintf("Trip to %s", strings.TrimSpace(r.Destination))
+	}
+	travelers := r.Travelers
+	if travelers == 0 {
+		travelers = 1
+	}
+	itinerary := r.Itinerary
+	if itinerary == nil {
+		itinerary = make(map[string]interface{})
+	}
+
+	id := uuid.New().String()
+	if key := strings.TrimSpace(r.ExternalKey); key != "" {
+		id = importedTripID(userID, key)
+	}
+
+	return TripData{
+		ID:          id,
+		UserID:      userID,
+		Title:       title,
+		Destination: strings.TrimSpace(r.Destination),
+		StartDate:   start,
+		EndDate:     end,
+		Status:      "planned",
+		Itinerary:   itinerary,
+		Budget:      r.Budget,
+		Travelers:   travelers,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		ExternalKey: strings.TrimSpace(r.ExternalKey),
+	}
+}
+
+// importedTripID is the ID of the trip a user imports under an external key.
+// It's the same for every import of the key, so two imports racing each
+// other can't both create the trip.
+func importedTripID(userID, key string) string {
+	return uuid.NewSHA1(tripImportNamespace, []byte(userID+"\x00"+key)).String()
+}
+
+// createImportedTrip saves an imported trip unless a trip that hasn't been
+// deleted already has its ID, and reports whether it was saved. A deleted
+// trip with the ID is replaced.
+func (f *FirebaseService) createImportedTrip(ctx context.Context, trip TripData) (bool, error) {
+	ref := f.firestore.Collection("trips").Doc(trip.ID)
+	var created bool
+	err := f.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		created = false
+		doc, err := tx.Get(ref)
+		switch {
+		case status.Code(err) == codes.NotFound:
+		case err != nil:
+			return err
+		default:
+			var existing TripData
+			if err := doc.DataTo(&existing); err != nil {
+				return err
+			}
+			if existing.Status != "deleted" {
+				return nil
+			}
+		}
+		created = true
+		return tx.Set(ref, trip)
+	})
+	return created, err
+}
+
+// ImportTrips creates a trip for each valid row, owned by userID. Bad rows
+// and rows whose external key the user has already imported are reported and
+// skipped; one bad row never stops the rest.
+func (f *FirebaseService) ImportTrips(ctx context.Context, userID string, rows []TripImportRow, now time.Time) (*TripImportSummary, error) {
+	if len(rows) == 0 {
+		return nil, kindErrorf(ErrValidation, "import has no trips")
+	}
+	if len(rows) > MaxTripImportBatch {
+		return nil, kindErrorf(ErrValidation, "import has %d trips; at most %d can be imported at once", len(rows), MaxTripImportBatch)
+	}
+
+	existing, err := f.GetUserTrips(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load existing trips: %w", err)
+	}
+	imported := make(map[string]string, len(existing))
+	for _, trip := range existing {
+		if trip.ExternalKey != "" {
+			imported[trip.ExternalKey] = trip.ID
+		}
+	}
+
+	summary := &TripImportSummary{Total: len(rows), Results: make([]TripImportResult, 0, len(rows))}
+	for i, row := range rows {
+		key := strings.TrimSpace(row.ExternalKey)
+		result := TripImportResult{Row: i + 1, ExternalKey: key}
+
+		switch err := row.Validate(); {
+		case err != nil:
+			result.Status = TripImportInvalid
+			result.Error = err.Error()
+			summary.Invalid++
+		case key != "" && imported[key] != "":
+			result.Status = TripImportDuplicate
+			result.TripID = imported[key]
+			summary.Duplicates++
+		default:
+			trip := row.tripData(userID, now)
+			created, err := f.createImportedTrip(ctx, trip)
+			if err != nil {
+				result.Status = TripImportFailed
+				result.Error = "failed to save trip"
+				summary.Failed++
+				log.Printf("Failed to import row %d for user %s: %v", i+1, userID, err)
+				break
+			}
+			if !created {
+				// Another import created the trip since the existing ones loaded
+				result.Status = TripImportDuplicate
+				result.TripID = trip.ID
+				summary.Duplicates++
+				imported[key] = trip.ID
+				break
+			}
+
+			// Mirror the itinerary into the relational rows, as planning does
+			if tripRows, err := MapTripToRows(trip, now); err != nil {
+				log.Printf("Failed to map trip %s to rows: %v", trip.ID, err)
+			} else if err := f.SaveTripRows(ctx, tripRows); err != nil {
+				log.Printf("Failed to save rows for trip %s: %v", trip.ID, err)
+			}
+
+			result.Status = TripImportCreated
+			result.TripID = trip.ID
+			summary.Created++
+			if key != "" {
+				imported[key] = trip.ID
+			}
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	return summary, nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTripImportRowValidate(t *testing.T) {
+	valid := TripImportRow{Destination: "Goa", StartDate: "2026-12-01", EndDate: "2026-12-05", Budget: 900, Travelers: 2}
+	with := func(change func(*TripImportRow)) TripImportRow {
+		row := valid
+		change(&row)
+		return row
+	}
+
+	tests := []struct {
+		name    string
+		row     TripImportRow
+		wantErr string
+	}{
+		{"valid", valid, ""},
+		{"day trip", with(func(r *TripImportRow) { r.EndDate = r.StartDate }), ""},
+		{"travelers left out", with(func(r *TripImportRow) { r.Travelers = 0 }), ""},
+		{"no destination", with(func(r *TripImportRow) { r.Destination = "  " }), "destination is required"},
+		{"bad start date", with(func(r *TripImportRow) { r.StartDate = "01/12/2026" }), "start_date must be YYYY-MM-DD"},
+		{"bad end date", with(func(r *TripImportRow) { r.EndDate = "" }), "end_date must be YYYY-MM-DD"},
+		{"ends before it starts", with(func(r *TripImportRow) { r.EndDate = "2026-11-30" }), "end_date must not be before start_date"},
+		{"negative budget", with(func(r *TripImportRow) { r.Budget = -1 }), "budget must not be negative"},
+		{"negative travelers", with(func(r *TripImportRow) { r.Travelers = -2 }), "travelers must not be negative"},
+		{"unreadable fields", with(func(r *TripImportRow) { r.parseErr = errors.New("budget \"lots\" is not a number") }), "is not a number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.row.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// rowErrors returns each row's validation error, or "" for a valid row
+func rowErrors(rows []TripImportRow) []string {
+	errs := make([]string, len(rows))
+	for i, row := range rows {
+		if err := row.Validate(); err != nil {
+			errs[i] = err.Error()
+		}
+	}
+	return errs
+}
+
+func TestParseTripImportCSV(t *testing.T) {
+	data := strings.Join([]string{
+		"Destination, Start_Date, end_date, budget, travelers, external_key, itinerary",
+		"Goa, 2026-12-01, 2026-12-05, 900, 2, k1,",
+		"Jaipur, 2026-12-01, 2026-12-03, lots, 2, k2,",
+		"Agra, 2026-12-01, 2026-12-02, 100, two, k3,",
+		"Delhi, 2026-12-01, 2026-12-02, 100, 1, k4, {days",
+		`Kochi, 2026-12-01, 2026-12-02, 100, 1, k"5,`,
+		`Mumbai, 2026-12-01, 2026-12-04, , , k6, "{""days"": []}"`,
+		", 2026-12-01, 2026-12-02",
+	}, "\n")
+
+	rows, err := ParseTripImportCSV([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", "not a number", "not a whole number", "not a JSON object", "malformed CSV row", "", "destination is required"}
+	errs := rowErrors(rows)
+	if len(errs) != len(want) {
+		t.Fatalf("parsed %d rows, want %d: %q", len(errs), len(want), errs)
+	}
+	for i := range want {
+		if (want[i] == "") != (errs[i] == "") || !strings.Contains(errs[i], want[i]) {
+			t.Errorf("row %d error = %q, want %q", i+1, errs[i], want[i])
+		}
+	}
+	if rows[0].ExternalKey != "k1" || rows[0].Budget != 900 || rows[0].Travelers != 2 {
+		t.Errorf("row 1 = %+v, want k1 with a budget of 900 for 2", rows[0])
+	}
+	if _, ok := rows[5].Itinerary["days"]; !ok {
+		t.Errorf("row 6 itinerary = %v, want the days from the JSON column", rows[5].Itinerary)
+	}
+
+	for _, bad := range []string{"", "destination,start_date\nGoa,2026-12-01"} {
+		if _, err := ParseTripImportCSV([]byte(bad)); !errors.Is(err, ErrValidation) {
+			t.Errorf("ParseTripImportCSV(%q) = %v, want a validation error", bad, err)
+		}
+	}
+}
+
+func TestParseTripImportJSON(t *testing.T) {
+	rows, err := ParseTripImportJSON([]byte(`[
+		{"destination": "Goa", "start_date": "2026-12-01", "end_date": "2026-12-05", "travelers": 2},
+		{"destination": "Jaipur", "start_date": "2026-12-01", "end_date": "2026-12-03", "budget": "lots"},
+		{"destination": "Agra", "start_date": "2026-12-03", "end_date": "2026-12-01"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"", "malformed trip", "must not be before"}
+	errs := rowErrors(rows)
+	if len(errs) != len(want) {
+		t.Fatalf("parsed %d rows, want %d: %q", len(errs), len(want), errs)
+	}
+	for i := range want {
+		if (want[i] == "") != (errs[i] == "") || !strings.Contains(errs[i], want[i]) {
+			t.Errorf("row %d error = %q, want %q", i+1, errs[i], want[i])
+		}
+	}
+
+	if _, err := ParseTripImportJSON([]byte(`{"destination": "Goa"}`)); !errors.Is(err, ErrValidation) {
+		t.Errorf("ParseTripImportJSON(object) = %v, want a validation error", err)
+	}
+}
+
+func TestImportTrips(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	seed(t, fb, "trips/existing", map[string]interface{}{"id": "existing", "user_id": "u1", "status": "planned", "external_key": "k-old"})
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	trip := func(key, destination string) TripImportRow {
+		return TripImportRow{ExternalKey: key, Destination: destination, StartDate: "2026-12-01", EndDate: "2026-12-04"}
+	}
+	rows := []TripImportRow{
+		trip("k1", "Goa"),
+		trip("k2", ""), // invalid, and must not stop the rows after it
+		trip("k-old", "Jaipur"),
+		trip("", "Agra"),
+		trip("k1", "Goa"), // repeated within the batch
+		{ExternalKey: "k3", parseErr: errors.New("malformed trip")},
+		trip("k4", "Delhi"),
+	}
+	summary, err := fb.ImportTrips(context.Background(), "u1", rows, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantStatuses := []string{TripImportCreated, TripImportInvalid, TripImportDuplicate, TripImportCreated, TripImportDuplicate, TripImportInvalid, TripImportCreated}
+	for i, result := range summary.Results {
+		if result.Row != i+1 || result.Status != wantStatuses[i] {
+			t.Errorf("result %d = row %d %s (%s), want row %d %s", i, result.Row, result.Status, result.Error, i+1, wantStatuses[i])
+		}
+	}
+	if summary.Results[2].TripID != "existing" || summary.Results[4].TripID != summary.Results[0].TripID {
+		t.Errorf("duplicates point at %s and %s, want existing and %s", summary.Results[2].TripID, summary.Results[4].TripID, summary.Results[0].TripID)
+	}
+	if summary.Results[1].Error != "destination is required" {
+		t.Errorf("row 2 error = %q, want the reason it's invalid", summary.Results[1].Error)
+	}
+	if summary.Total != 7 || summary.Created != 3 || summary.Duplicates != 2 || summary.Invalid != 2 || summary.Failed != 0 {
+		t.Errorf("summary = %+v, want 3 created, 2 duplicates, 2 invalid", summary)
+	}
+	if got := fake.count("trips"); got != 4 {
+		t.Errorf("%d trips stored, want the existing one and 3 imported", got)
+	}
+
+	// Importing again creates nothing new
+	again, err := fb.ImportTrips(context.Background(), "u1", rows[:1], now)
+	if err != nil || again.Duplicates != 1 || again.Results[0].TripID != summary.Results[0].TripID {
+		t.Errorf("re-import = %+v, %v; want a duplicate of %s", again, err, summary.Results[0].TripID)
+	}
+
+	for _, n := range []int{0, MaxTripImportBatch + 1} {
+		if _, err := fb.ImportTrips(context.Background(), "u1", make([]TripImportRow, n), now); !errors.Is(err, ErrValidation) {
+			t.Errorf("importing %d rows = %v, want a validation error", n, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ItineraryFromTrip builds the itinerary delivered to travelers from a
+// stored trip. Days are read from the itinerary's day plans in any of the
+// shapes the app stores (see ComputePlanDiff) and dated from the trip's
+// start date; activities without a time start when their slot does. Night
+// activities are delivered with the evening's, and a day's unslotted
+// activities go in the slot their start time falls in. Hotels and transport
+// come from the itinerary's hotel, accommodation and transport entries.
+func ItineraryFromTrip(trip *TripData) *ItineraryData {
+	itinerary := trip.Itinerary
+	data := &ItineraryData{
+		TripID:         trip.ID,
+		Destination:    trip.Destination,
+		StartDate:      timeFromValue(trip.StartDate),
+		EndDate:        timeFromValue(trip.EndDate),
+		Travelers:      trip.Travelers,
+		Budget:         trip.Budget,
+		Currency:       tripCurrency(trip),
+		Title:          trip.Title,
+		Description:    firstString(itinerary, "description", "summary", "overview"),
+		DailyItinerary: make(map[int]DayItinerary),
+		ImportantInfo:  stringList(itinerary["important_info"]),
+		CreatedAt:      timeFromValue(trip.CreatedAt),
+		LastModified:   timeFromValue(trip.UpdatedAt),
+	}
+	if data.Title == "" {
+		data.Title = fmt.Sprintf("Trip to %s", trip.Destination)
+	}
+
+	for dayNum, dayPlan := range storedDayPlans(itinerary) {
+		data.DailyItinerary[dayNum] = dayFromPlan(dayPlan, dayNum, data.StartDate)
+	}
+
+	for _, key := range []string{"hotel", "accommodation"} {
+		for _, item := range planActivities(itinerary[key]) {
+			data.Hotels = append(data.Hotels, hotelFromPlan(item, data.StartDate, data.EndDate))
+		}
+	}
+	for _, key := range []string{"transportation", "transport"} {
+		for _, item := range planActivities(itinerary[key]) {
+			data.Transportation = append(data.Transportation, transportFromPlan(item))
+		}
+	}
+
+	data.TotalCost = ComputeTotalCost(data)
+	data.CarbonFootprintKg = TripCarbonFootprint(data)
+	return data
+}
+
+// storedDayPlans returns the day plans of a stored itinerary by day number.
+// Unlike planDays, the plans are the stored maps themselves, so changes to
+// them change the itinerary.
+func storedDayPlans(itinerary map[string]interface{}) map[int]map[string]interface{} {
+	days := itinerary
+	for _, key := range []string{"itinerary", "daily_itinerary"} {
+		if nested, ok := itinerary[key].(map[string]interface{}); ok {
+			days = nested
+			break
+		}
+	}
+
+	plans := make(map[int]map[string]interface{})
+	for key, value := range days {
+		match := planDayKey.FindStringSubmatch(key)
+		if match == nil {
+			continue
+		}
+		if dayPlan, ok := value.(map[string]interface{}); ok {
+			dayNum, _ := strconv.Atoi(match[1])
+			plans[dayNum] = dayPlan
+		}
+	}
+	return plans
+}
+
+// dayFromPlan converts one stored day plan, dated from the trip's start
+func dayFromPlan(dayPlan map[string]interface{}, dayNum int, tripStart time.Time) DayItinerary {
+	day := DayItinerary{
+		Date:      timeFromValue(dayPlan["date"]),
+		DayNumber: dayNum,
+		Title:     firstString(dayPlan, "title", "theme", "city"),
+		Notes:     firstString(dayPlan, "notes", "description"),
+		source:    dayPlan,
+	}
+	if day.Date.IsZero() && !tripStart.IsZero() {
+		day.Date = truncateToDate(tripStart).AddDate(0, 0, dayNum-1)
+	}
+	if day.Title == "" {
+		day.Title = fmt.Sprintf("Day %d", dayNum)
+	}
+
+	for _, slot := range planSlots {
+		for _, item := range planActivities(dayPlan[slot]) {
+			activity := activityFromPlan(item, day.Date, slot)
+			placed := slot
+			if slot == "activities" && !activity.StartTime.IsZero() {
+				placed = icsSlot(activity.StartTime)
+			}
+			switch placed {
+			case "morning":
+				day.Morning = append(day.Morning, activity)
+			case "afternoon", "activities":
+				day.Afternoon = append(day.Afternoon, activity)
+			default:
+				day.Evening = append(day.Evening, activity)
+			}
+			day.TotalCost += activity.Cost
+		}
+	}
+	day.Morning = sortedByStart(day.Morning)
+	day.Afternoon = sortedByStart(day.Afternoon)
+	day.Evening = sortedByStart(day.Evening)
+	day.TotalCost = roundCost(day.TotalCost)
+	return day
+}
+
+// activityFromPlan converts one stored activity of a slot on date
+func activityFromPlan(item map[string]interface{}, date time.Time, slot string) Activity {
+	activity := Activity{
+		ID:          firstString(item, "id", "place_id"),
+		Name:        activityName(item),
+		Type:        firstString(item, "type", "category"),
+		Description: firstString(item, "description"),
+		Cost:        floatValue(item, "cost", "estimated_cost", "price"),
+		BookingRef:  firstString(item, "booking_reference", "confirmation_number", "booking_ref"),
+		Status:      firstString(item, "booking_status", "status"),
+		Tips:        stringList(item["tips"]),
+		Currency:    firstString(item, "currency"),
+		Location:    locationFromPlan(item["location"]),
+		source:      item,
+	}
+
+	if start, ok := instant(item["start_time"]); ok {
+		activity.StartTime = start
+	} else if !date.IsZero() {
+		hour, minute, ok := activityClockTime(item)
+		if !ok {
+			start, known := slotStartTimes[slot]
+			hour, minute, ok = start[0], start[1], known
+		}
+		if ok {
+			activity.StartTime = time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+		}
+	}
+
+	if end, ok := instant(item["end_time"]); ok {
+		activity.EndTime = end
+	} else if !activity.StartTime.IsZero() {
+		duration := defaultActivityDuration
+		if minutes := floatValue(item, "duration", "duration_minutes"); minutes > 0 {
+			duration = time.Duration(minutes) * time.Minute
+		}
+		activity.EndTime = activity.StartTime.Add(duration)
+	}
+	return activity
+}
+
+// instant reads a stored date and time, as opposed to a time of day
+func instant(value interface{}) (time.Time, bool) {
+	switch t := value.(type) {
+	case time.Time:
+		return t, !t.IsZero()
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		return parsed, err == nil
+	}
+	return time.Time{}, false
+}
+
+// locationFromPlan reads a stored location, either an address or an object
+func locationFromPlan(value interface{}) Location {
+	switch location := value.(type) {
+	case string:
+		return Location{Address: location}
+	case map[string]interface{}:
+		return Location{
+			Address:   firstString(location, "address", "name"),
+			Latitude:  floatValue(location, "latitude", "lat"),
+			Longitude: floatValue(location, "longitude", "lng"),
+		}
+	}
+	return Location{}
+}
+
+// hotelFromPlan converts a stored hotel, staying the whole trip unless it
+// says otherwise
+func hotelFromPlan(item map[string]interface{}, tripStart, tripEnd time.Time) HotelBooking {
+	hotel := HotelBooking{
+		Name:            activityName(item),
+		Address:         locationFromPlan(item["location"]).Address,
+		CheckIn:         timeFromValue(item["check_in"]),
+		CheckOut:        timeFromValue(item["check_out"]),
+		RoomType:        firstString(item, "room_type"),
+		TotalCost:       floatValue(item, "total_cost", "cost", "price"),
+		ConfirmationNum: firstString(item, "booking_reference", "confirmation_number"),
+		Contact:         firstString(item, "contact", "phone"),
+		Amenities:       stringList(item["amenities"]),
+		Currency:        firstString(item, "currency"),
+	}
+	if hotel.Address == "" {
+		hotel.Address = firstString(item, "address")
+	}
+	if hotel.CheckIn.IsZero() {
+		hotel.CheckIn = tripStart
+	}
+	if hotel.CheckOut.IsZero() {
+		hotel.CheckOut = tripEnd
+	}
+	if !hotel.CheckIn.IsZero() && hotel.CheckOut.After(hotel.CheckIn) {
+		hotel.Nights = int(hotel.CheckOut.Sub(hotel.CheckIn).Hours()/24 + 0.5)
+	}
+	if hotel.TotalCost == 0 {
+		hotel.TotalCost = floatValue(item, "price_per_night") * float64(max(hotel.Nights, 1))
+	}
+	return hotel
+}
+
+// transportFromPlan converts a stored transport booking
+func transportFromPlan(item map[string]interface{}) TransportBooking {
+	transport := TransportBooking{
+		Type:          firstString(item, "type", "mode"),
+		From:          firstString(item, "from", "origin"),
+		To:            firstString(item, "to", "destination"),
+		DepartureTime: timeFromValue(item["departure_time"]),
+		ArrivalTime:   timeFromValue(item["arrival_time"]),
+		Provider:      firstString(item, "provider", "carrier", "name"),
+		BookingRef:    firstString(item, "booking_reference", "confirmation_number", "booking_ref"),
+		SeatNumber:    firstString(item, "seat_number"),
+		Cost:          floatValue(item, "cost", "price"),
+		Status:        firstString(item, "booking_status", "status"),
+		DistanceKm:    floatValue(item, "distance_km"),
+		Currency:      firstString(item, "currency"),
+	}
+	transport.CarbonKg = EstimateCarbonFootprint(transport.Type, transport.DistanceKm)
+	return transport
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestItineraryFromTrip(t *testing.T) {
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	trip := &TripData{
+		ID:          "trip-1",
+		Title:       "Jaipur long weekend",
+		Destination: "Jaipur",
+		StartDate:   start,
+		EndDate:     start.AddDate(0, 0, 1),
+		Travelers:   2,
+		Itinerary: map[string]interface{}{
+			"currency": "inr",
+			"itinerary": map[string]interface{}{
+				"day_1": map[string]interface{}{
+					"title":   "Forts",
+					"morning": []interface{}{map[string]interface{}{"name": "Amber Fort", "cost": 500.0, "duration": 90.0}},
+					"night":   "Chokhi Dhani",
+				},
+				"day_2": map[string]interface{}{
+					"activities": []interface{}{
+						map[string]interface{}{"name": "Hawa Mahal", "time": "15:30"},
+					},
+				},
+			},
+			"hotel":          map[string]interface{}{"name": "Rambagh Palace", "price_per_night": 20000.0},
+			"transportation": map[string]interface{}{"type": "train", "from": "Delhi", "to": "Jaipur", "distance_km": 280.0},
+		},
+	}
+
+	data := ItineraryFromTrip(trip)
+
+	if data.Currency != "INR" || data.Title != "Jaipur long weekend" || len(data.DailyItinerary) != 2 {
+		t.Fatalf("itinerary = %+v", data)
+	}
+	day1 := data.DailyItinerary[1]
+	if len(day1.Morning) != 1 || len(day1.Evening) != 1 {
+		t.Fatalf("day 1 = %+v, want one morning and one evening activity", day1)
+	}
+	fort := day1.Morning[0]
+	if want := start.Add(9 * time.Hour); !fort.StartTime.Equal(want) || !fort.EndTime.Equal(want.Add(90*time.Minute)) {
+		t.Errorf("Amber Fort runs %v to %v, want 90 minutes from %v", fort.StartTime, fort.EndTime, want)
+	}
+	day2 := data.DailyItinerary[2]
+	if len(day2.Afternoon) != 1 || day2.Afternoon[0].StartTime.Hour() != 15 {
+		t.Errorf("day 2 = %+v, want Hawa Mahal in the afternoon at 15:30", day2)
+	}
+	if len(data.Hotels) != 1 || data.Hotels[0].Nights != 1 || data.Hotels[0].TotalCost != 20000 {
+		t.Errorf("hotels = %+v, want one night at Rambagh Palace", data.Hotels)
+	}
+	if len(data.Transportation) != 1 || data.Transportation[0].CarbonKg == 0 {
+		t.Errorf("transportation = %+v, want the train with its footprint", data.Transportation)
+	}
+}
@@ -0,0 +1,208 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"auratravel-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TripNotFoundError is returned when a trip doesn't exist (or is deleted)
+type TripNotFoundError struct {
+	TripID string
+}
+
+func (e *TripNotFoundError) Error() string {
+	return fmt.Sprintf("trip %s not found", e.TripID)
+}
+
+// Is makes the error match ErrNotFound
+func (e *TripNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// TripPermissionError is returned when a user is neither the owner nor an
+// accepted collaborator of a trip
+type TripPermissionError struct {
+	TripID string
+	UserID string
+}
+
+func (e *TripPermissionError) Error() string {
+	return fmt.Sprintf("user %s does not have access to trip %s", e.UserID, e.TripID)
+}
+
+// Is makes the error match ErrPermission
+func (e *TripPermissionError) Is(target error) bool {
+	return target == ErrPermission
+}
+
+// GetTripWithItinerary loads a trip with its itinerary, day plans, activities,
+// meals, accommodations and transportation. userID must be the trip owner or
+// an accepted collaborator; otherwise a *TripPermissionError is returned.
+func (f *FirebaseService) GetTripWithItinerary(ctx context.Context, tripID, userID string) (*models.TripWithDetails, error) {
+	tripDoc, err := f.firestore.Collection("trips").Doc(tripID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &TripNotFoundError{TripID: tripID}
+		}
+		return nil, fmt.Errorf("failed to get trip: %v", err)
+	}
+
+	var tripData TripData
+	if err := tripDoc.DataTo(&tripData); err != nil {
+		return nil, fmt.Errorf("failed to convert trip data: %v", err)
+	}
+	if tripData.Status == "deleted" {
+		return nil, &TripNotFoundError{TripID: tripID}
+	}
+
+	details := &models.TripWithDetails{
+		Trip: models.Trip{
+			ID:          tripID,
+			UserID:      tripData.UserID,
+			Title:       tripData.Title,
+			Destination: tripData.Destination,
+			StartDate:   timeFromValue(tripData.StartDate),
+			EndDate:     timeFromValue(tripData.EndDate),
+			Status:      tripData.Status,
+			Travelers:   tripData.Travelers,
+			TotalBudget: tripData.Budget,
+			CreatedAt:   timeFromValue(tripData.CreatedAt),
+			UpdatedAt:   timeFromValue(tripData.UpdatedAt),
+			Version:     tripData.Version,
+		},
+		RawItinerary: tripData.Itinerary,
+	}
+	data := tripDoc.Data()
+	details.Trip.Description, _ = data["description"].(string)
+	details.Trip.Currency, _ = data["currency"].(string)
+	details.Trip.IsPublic, _ = data["is_public"].(bool)
+	details.Trip.ShareCode, _ = data["share_code"].(string)
+
+	// Collaborators and access check
+	if err := f.queryInto(ctx, f.firestore.Collection("trip_collaborators").Where("trip_id", "==", tripID), &details.Collaborators); err != nil {
+		return nil, fmt.Errorf("failed to get trip collaborators: %v", err)
+	}
+
+	switch {
+	case tripData.UserID == userID:
+		details.Role = "owner"
+	default:
+		for _, collaborator := range details.Collaborators {
+			if collaborator.UserID == userID && collaborator.AcceptedAt != nil {
+				details.Role = collaborator.Role
+				break
+			}
+		}
+	}
+	if details.Role == "" {
+		return nil, &TripPermissionError{TripID: tripID, UserID: userID}
+	}
+
+	// Itinerary graph
+	var itineraries []models.Itinerary
+	if err := f.queryInto(ctx, f.firestore.Collection("itineraries").Where("trip_id", "==", tripID).Limit(1), &itineraries); err != nil {
+		return nil, fmt.Errorf("failed to get itinerary: %v", err)
+	}
+	if len(itineraries) > 0 {
+		details.Itinerary = &itineraries[0]
+
+		var dayPlans []models.DayPlan
+		if err := f.queryInto(ctx, f.firestore.Collection("day_plans").Where("itinerary_id", "==", details.Itinerary.ID), &dayPlans); err != nil {
+			return nil, fmt.Errorf("failed to get day plans: %v", err)
+		}
+		sort.Slice(dayPlans, func(i, j int) bool { return dayPlans[i].DayNumber < dayPlans[j].DayNumber })
+
+		var activities []models.Activity
+		if err := f.queryInto(ctx, f.firestore.Collection("activities").Where("trip_id", "==", tripID), &activities); err != nil {
+			return nil, fmt.Errorf("failed to get activities: %v", err)
+		}
+
+		activitiesByDay := make(map[string][]models.Activity)
+		for _, activity := range activities {
+			if activity.DayPlanID != nil {
+				activitiesByDay[*activity.DayPlanID] = append(activitiesByDay[*activity.DayPlanID], activity)
+			}
+		}
+
+		for _, dayPlan := range dayPlans {
+			var meals []models.Meal
+			if err := f.queryInto(ctx, f.firestore.Collection("meals").Where("day_plan_id", "==", dayPlan.ID), &meals); err != nil {
+				return nil, fmt.Errorf("failed to get meals: %v", err)
+			}
+
+			dayActivities := activitiesByDay[dayPlan.ID]
+			sort.SliceStable(dayActivities, func(i, j int) bool {
+				a, b := dayActivities[i].ScheduledTime, dayActivities[j].ScheduledTime
+				return a != nil && (b == nil || a.Before(*b))
+			})
+
+			details.Days = append(details.Days, models.DayPlanDetails{
+				DayPlan:    dayPlan,
+				Activities: dayActivities,
+				Meals:      meals,
+			})
+		}
+	}
+
+	if err := f.queryInto(ctx, f.firestore.Collection("accommodations").Where("trip_id", "==", tripID), &details.Accommodations); err != nil {
+		return nil, fmt.Errorf("failed to get accommodations: %v", err)
+	}
+	if err := f.queryInto(ctx, f.firestore.Collection("transportation").Where("trip_id", "==", tripID), &details.Transportation); err != nil {
+		return nil, fmt.Errorf("failed to get transportation: %v", err)
+	}
+
+	return details, nil
+}
+
+// queryInto runs a query and decodes every document into out, which must be a
+// pointer to a slice of a models type. The models carry json (not firestore)
+// tags, so documents are decoded through their JSON representation.
+func (f *FirebaseService) queryInto(ctx context.Context, query firestore.Query, out interface{}) error {
+	docs, err := query.Documents(ctx).GetAll()
+	if err != nil {
+		return err
+	}
+
+	records := make([]map[string]interface{}, 0, len(docs))
+	for _, doc := range docs {
+		data := doc.Data()
+		if _, ok := data["id"]; !ok {
+			data["id"] = doc.Ref.ID
+		}
+		records = append(records, data)
+	}
+
+	raw, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// timeFromValue converts the loosely typed date fields of TripData
+func timeFromValue(val interface{}) time.Time {
+	switch t := val.(type) {
+	case time.Time:
+		return t
+	case *time.Time:
+		if t != nil {
+			return *t
+		}
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed
+			}
+		}
+	}
+	return time.Time{}
+}
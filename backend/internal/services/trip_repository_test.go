@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetTripWithItinerary(t *testing.T) {
+	fb, _ := newTestFirebase(t)
+	at := func(hour int) time.Time {
+		return time.Date(2026, 4, 1, hour, 0, 0, 0, time.UTC)
+	}
+	seed(t, fb, "trips/t1", map[string]interface{}{"id": "t1", "user_id": "owner", "title": "Jaipur", "status": "planned", "currency": "INR"})
+	seed(t, fb, "trips/gone", map[string]interface{}{"id": "gone", "user_id": "owner", "status": "deleted"})
+	seed(t, fb, "trip_collaborators/t1_ed", map[string]interface{}{"trip_id": "t1", "user_id": "ed", "role": TripRoleEditor, "accepted_at": at(0)})
+	seed(t, fb, "trip_collaborators/t1_pending", map[string]interface{}{"trip_id": "t1", "user_id": "pending", "role": TripRoleViewer})
+	seed(t, fb, "itineraries/t1_itinerary", map[string]interface{}{"trip_id": "t1", "total_activities": 3})
+	seed(t, fb, "day_plans/d2", map[string]interface{}{"itinerary_id": "t1_itinerary", "day_number": 2})
+	seed(t, fb, "day_plans/d1", map[string]interface{}{"itinerary_id": "t1_itinerary", "day_number": 1})
+	seed(t, fb, "activities/fort", map[string]interface{}{"trip_id": "t1", "day_plan_id": "d1", "name": "Fort", "scheduled_time": at(9)})
+	seed(t, fb, "activities/bazaar", map[string]interface{}{"trip_id": "t1", "day_plan_id": "d1", "name": "Bazaar", "scheduled_time": at(16)})
+	seed(t, fb, "activities/palace", map[string]interface{}{"trip_id": "t1", "day_plan_id": "d1", "name": "Palace", "scheduled_time": at(12)})
+	seed(t, fb, "activities/stepwell", map[string]interface{}{"trip_id": "t1", "day_plan_id": "d2", "name": "Stepwell"})
+	seed(t, fb, "meals/lunch", map[string]interface{}{"day_plan_id": "d1", "name": "Lunch"})
+	seed(t, fb, "accommodations/haveli", map[string]interface{}{"trip_id": "t1", "name": "Haveli"})
+
+	tests := []struct {
+		name           string
+		tripID         string
+		userID         string
+		wantRole       string
+		wantPermission bool
+		wantNotFound   bool
+	}{
+		{"owner", "t1", "owner", TripRoleOwner, false, false},
+		{"accepted collaborator", "t1", "ed", TripRoleEditor, false, false},
+		{"pending invite", "t1", "pending", "", true, false},
+		{"stranger", "t1", "stranger", "", true, false},
+		{"deleted trip", "gone", "owner", "", false, true},
+		{"missing trip", "nope", "owner", "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details, err := fb.GetTripWithItinerary(context.Background(), tt.tripID, tt.userID)
+			var permission *TripPermissionError
+			if errors.As(err, &permission) != tt.wantPermission || errors.Is(err, ErrNotFound) != tt.wantNotFound {
+				t.Fatalf("GetTripWithItinerary = %v, want permission error %v, not found %v", err, tt.wantPermission, tt.wantNotFound)
+			}
+			if err != nil {
+				return
+			}
+
+			if details.Role != tt.wantRole || details.Trip.Currency != "INR" {
+				t.Errorf("role %q, currency %q; want %q, INR", details.Role, details.Trip.Currency, tt.wantRole)
+			}
+			if details.Itinerary == nil || len(details.Days) != 2 || len(details.Accommodations) != 1 || len(details.Collaborators) != 2 {
+				t.Fatalf("got itinerary %v, %d days, %d accommodations, %d collaborators; want the whole graph", details.Itinerary, len(details.Days), len(details.Accommodations), len(details.Collaborators))
+			}
+			day1, day2 := details.Days[0], details.Days[1]
+			if day1.DayPlan.ID != "d1" || day2.DayPlan.ID != "d2" {
+				t.Errorf("days = %s, %s; want d1, d2", day1.DayPlan.ID, day2.DayPlan.ID)
+			}
+			if got := activityNames(day1.Activities); got != "Fort,Palace,Bazaar" {
+				t.Errorf("day 1 activities = %s, want them in scheduled order", got)
+			}
+			if len(day1.Meals) != 1 || len(day2.Meals) != 0 || activityNames(day2.Activities) != "Stepwell" {
+				t.Errorf("meals/activities not attached to their days: %+v", details.Days)
+			}
+		})
+	}
+}
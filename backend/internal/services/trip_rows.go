@@ -0,0 +1,301 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"auratravel-backend/internal/models"
+
+	"cloud.google.com/go/firestore"
+)
+
+// slotStartTimes are the default start times, as hour and minute, of the
+// parts of a day in a generated itinerary
+var slotStartTimes = map[string][2]int{
+	"morning":   {9, 0},
+	"afternoon": {14, 0},
+	"evening":   {19, 0},
+	"night":     {21, 0},
+}
+
+// clockTime matches times such as "9:30" and "14:00"
+var clockTime = regexp.MustCompile(`^(\d{1,2}):(\d{2})`)
+
+// TripRows is a trip's itinerary mapped to the relational models, one row per
+// itinerary, day plan and activity
+type TripRows struct {
+	Trip       models.Trip
+	Itinerary  models.Itinerary
+	DayPlans   []models.DayPlan
+	Activities []models.Activity
+}
+
+// MapTripToRows maps a trip and its generated itinerary to the relational
+// models. IDs are derived from the trip ID, so mapping the same trip again
+// yields the same rows. Activities without an explicit time are scheduled at
+// the start of their slot on the trip's calendar date, when it's known.
+func MapTripToRows(trip TripData, now time.Time) (*TripRows, error) {
+	days, err := planDays(trip.Itinerary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid itinerary: %w", err)
+	}
+
+	startDate := timeFromValue(trip.StartDate)
+	createdAt := timeFromValue(trip.CreatedAt)
+	if createdAt.IsZero() {
+		createdAt = now
+	}
+	currency, _ := trip.Itinerary["currency"].(string)
+
+	rows := &TripRows{
+		Trip: models.Trip{
+			ID:          trip.ID,
+			UserID:      trip.UserID,
+			Title:       trip.Title,
+			Destination: trip.Destination,
+			StartDate:   startDate,
+			EndDate:     timeFromValue(trip.EndDate),
+			Status:      trip.Status,
+			Travelers:   trip.Travelers,
+			TotalBudget: trip.Budget,
+			Currency:    currency,
+			CreatedAt:   createdAt,
+			UpdatedAt:   now,
+		},
+		Itinerary: models.Itinerary{
+			ID:          trip.ID + "_itinerary",
+			TripID:      trip.ID,
+			Currency:    currency,
+			GeneratedBy: "template",
+			GeneratedAt: createdAt,
+			CreatedAt:   createdAt,
+			UpdatedAt:   now,
+		},
+	}
+	if aiGenerated, _ := trip.Itinerary["ai_generated"].(bool); aiGenerated {
+		rows.Itinerary.GeneratedBy = "ai"
+	}
+
+	for _, day := range sortedDayNumbers(days) {
+		dayData := days[day]
+		dayPlan := models.DayPlan{
+			ID:          fmt.Sprintf("%s_day_%d", trip.ID, day),
+			ItineraryID: rows.Itinerary.ID,
+			DayNumber:   day,
+			CreatedAt:   createdAt,
+			UpdatedAt:   now,
+		}
+		if !startDate.IsZero() {
+			dayPlan.Date = startDate.AddDate(0, 0, day-1)
+		}
+		dayPlan.Title = firstString(dayData, "title", "theme", "city")
+		if dayPlan.Title == "" {
+			dayPlan.Title = fmt.Sprintf("Day %d", day)
+		}
+		dayPlan.Description, _ = dayData["description"].(string)
+		dayPlan.Notes, _ = dayData["notes"].(string)
+
+		for _, slot := range planSlots {
+			for i, data := range planActivities(dayData[slot]) {
+				activity := activityRow(data, trip.ID, dayPlan, slot, i)
+				activity.Currency = currency
+				activity.Location.City, _ = dayData["city"].(string)
+				if activity.Location.City == "" {
+					activity.Location.City = trip.Destination
+				}
+				activity.CreatedAt = createdAt
+				activity.UpdatedAt = now
+
+				dayPlan.TotalCost += activity.Cost
+				dayPlan.EstimatedTime += activity.Duration
+				rows.Activities = append(rows.Activities, activity)
+			}
+		}
+
+		rows.Itinerary.EstimatedCost += dayPlan.TotalCost
+		rows.DayPlans = append(rows.DayPlans, dayPlan)
+	}
+	rows.Itinerary.TotalActivities = len(rows.Activities)
+
+	return rows, nil
+}
+
+// activityRow maps one activity of a day plan's slot
+func activityRow(data map[string]interface{}, tripID string, dayPlan models.DayPlan, slot string, index int) models.Activity {
+	dayPlanID := dayPlan.ID
+	activity := models.Activity{
+		ID:        fmt.Sprintf("%s_%s_%d", dayPlan.ID, slot, index+1),
+		TripID:    tripID,
+		DayPlanID: &dayPlanID,
+		Name:      activityName(data),
+		Status:    "planned",
+		Source:    "ai",
+		Priority:  index + 1,
+	}
+	if id, ok := data["id"].(string); ok && id != "" {
+		activity.ExternalID = id
+	}
+	activity.Description, _ = data["description"].(string)
+	activity.Type, _ = data["type"].(string)
+	activity.BookingURL, _ = data["booking_url"].(string)
+	activity.Cost = floatValue(data, "cost", "estimated_cost", "price")
+	activity.Duration = int(floatValue(data, "duration", "duration_minutes"))
+	activity.Rating = floatValue(data, "rating")
+	activity.BookingRequired, _ = data["booking_required"].(bool)
+	activity.Tips = stringList(data["tips"])
+	activity.Tags = stringList(data["tags"])
+	if slot != "activities" {
+		activity.Tags = append(activity.Tags, slot)
+	}
+
+	switch location := data["location"].(type) {
+	case string:
+		activity.Location.Name = location
+	case map[string]interface{}:
+		activity.Location.Name = firstString(location, "name", "address")
+		activity.Location.Address, _ = location["address"].(string)
+		activity.Location.Latitude = floatValue(location, "latitude", "lat")
+		activity.Location.Longitude = floatValue(location, "longitude", "lng")
+	}
+
+	if !dayPlan.Date.IsZero() {
+		hour, minute, ok := activityClockTime(data)
+		if !ok {
+			start, known := slotStartTimes[slot]
+			hour, minute, ok = start[0], start[1], known
+		}
+		if ok {
+			date := dayPlan.Date
+			scheduled := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, date.Location())
+			activity.ScheduledTime = &scheduled
+		}
+	}
+
+	return activity
+}
+
+// activityClockTime reads an activity's "time" or "start_time" as a time of
+// day
+func activityClockTime(data map[string]interface{}) (int, int, bool) {
+	value := firstString(data, "time", "start_time")
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed.Hour(), parsed.Minute(), true
+	}
+	match := clockTime.FindStringSubmatch(value)
+	if match == nil {
+		return 0, 0, false
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	if hour > 23 || minute > 59 {
+		return 0, 0, false
+	}
+	return hour, minute, true
+}
+
+// sortedDayNumbers returns the day numbers of a plan in ascending order
+func sortedDayNumbers(days map[int]map[string]interface{}) []int {
+	numbers := make([]int, 0, len(days))
+	for day := range days {
+		numbers = append(numbers, day)
+	}
+	sort.Ints(numbers)
+	return numbers
+}
+
+// firstString returns the first non-empty string among keys
+func firstString(data map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := data[key].(string); ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// floatValue returns the first numeric value among keys, accepting numeric
+// strings such as "25"
+func floatValue(data map[string]interface{}, keys ...string) float64 {
+	for _, key := range keys {
+		switch v := data[key].(type) {
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		case int64:
+			return float64(v)
+		case string:
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				return parsed
+			}
+		}
+	}
+	return 0
+}
+
+// SaveTripRows writes a trip's itinerary, day plan and activity rows to the
+// collections GetTripWithItinerary reads. The trip row is the trips
+// document itself, so it isn't written again. Rows are keyed by their IDs,
+// so saving the same trip twice overwrites rather than duplicates them.
+func (f *FirebaseService) SaveTripRows(ctx context.Context, rows *TripRows) error {
+	writer := f.firestore.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+
+	set := func(collection, id string, row interface{}) error {
+		data, err := modelDoc(row)
+		if err != nil {
+			return err
+		}
+		job, err := writer.Set(f.firestore.Collection(collection).Doc(id), data)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+		return nil
+	}
+
+	err := set("itineraries", rows.Itinerary.ID, rows.Itinerary)
+	for _, dayPlan := range rows.DayPlans {
+		if err != nil {
+			break
+		}
+		err = set("day_plans", dayPlan.ID, dayPlan)
+	}
+	for _, activity := range rows.Activities {
+		if err != nil {
+			break
+		}
+		err = set("activities", activity.ID, activity)
+	}
+	writer.End()
+	if err != nil {
+		return fmt.Errorf("failed to queue trip rows: %v", err)
+	}
+
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return fmt.Errorf("failed to save trip rows: %v", err)
+		}
+	}
+	return nil
+}
+
+// modelDoc converts a models value to a Firestore document. The models carry
+// json (not firestore) tags, so they're encoded through their JSON
+// representation, the inverse of queryInto.
+func modelDoc(row interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMapTripToRows(t *testing.T) {
+	start := time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)
+	now := start.AddDate(0, 0, -10)
+	trip := TripData{
+		ID:          "trip-1",
+		UserID:      "u1",
+		Title:       "Jaipur long weekend",
+		Destination: "Jaipur",
+		StartDate:   start,
+		EndDate:     start.AddDate(0, 0, 1),
+		Status:      "planned",
+		Budget:      15000,
+		Travelers:   2,
+		Itinerary: map[string]interface{}{
+			"currency":     "INR",
+			"ai_generated": true,
+			"itinerary": map[string]interface{}{
+				"day_2": map[string]interface{}{
+					"activities": []interface{}{
+						map[string]interface{}{"name": "Hawa Mahal", "time": "15:30", "cost": "200", "location": "Badi Choupad"},
+					},
+				},
+				"day_1": map[string]interface{}{
+					"theme": "Forts",
+					"morning": []interface{}{
+						map[string]interface{}{
+							"id": "place-amber", "name": "Amber Fort", "cost": 500.0, "duration": 90.0, "rating": 4.6,
+							"location": map[string]interface{}{"name": "Amber Fort", "lat": 26.98, "lng": 75.85},
+						},
+					},
+					"night": "Chokhi Dhani",
+				},
+			},
+		},
+	}
+
+	rows, err := MapTripToRows(trip, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows.Trip.ID != "trip-1" || rows.Trip.TotalBudget != 15000 || rows.Trip.Currency != "INR" || !rows.Trip.StartDate.Equal(start) {
+		t.Errorf("trip row = %+v", rows.Trip)
+	}
+	if rows.Itinerary.ID != "trip-1_itinerary" || rows.Itinerary.GeneratedBy != "ai" || rows.Itinerary.EstimatedCost != 700 || rows.Itinerary.TotalActivities != 3 {
+		t.Errorf("itinerary row = %+v, want 3 AI-generated activities costing 700", rows.Itinerary)
+	}
+
+	type dayRow struct {
+		ID, Title string
+		Date      time.Time
+		Cost      float64
+	}
+	var days []dayRow
+	for _, day := range rows.DayPlans {
+		days = append(days, dayRow{day.ID, day.Title, day.Date, day.TotalCost})
+	}
+	wantDays := []dayRow{
+		{"trip-1_day_1", "Forts", start, 500},
+		{"trip-1_day_2", "Day 2", start.AddDate(0, 0, 1), 200},
+	}
+	if !reflect.DeepEqual(days, wantDays) {
+		t.Errorf("day plans = %+v, want %+v", days, wantDays)
+	}
+
+	type activityRow struct {
+		ID, DayPlanID, Name, ExternalID, Location string
+		At                                        time.Time
+		Tags                                      []string
+	}
+	var activities []activityRow
+	for _, activity := range rows.Activities {
+		activities = append(activities, activityRow{
+			activity.ID, *activity.DayPlanID, activity.Name, activity.ExternalID, activity.Location.Name,
+			*activity.ScheduledTime, activity.Tags,
+		})
+	}
+	wantActivities := []activityRow{
+		{"trip-1_day_1_morning_1", "trip-1_day_1", "Amber Fort", "place-amber", "Amber Fort", start.Add(9 * time.Hour), []string{"morning"}},
+		{"trip-1_day_1_night_1", "trip-1_day_1", "Chokhi Dhani", "", "", start.Add(21 * time.Hour), []string{"night"}},
+		{"trip-1_day_2_activities_1", "trip-1_day_2", "Hawa Mahal", "", "Badi Choupad", start.Add(24*time.Hour + 15*time.Hour + 30*time.Minute), nil},
+	}
+	if !reflect.DeepEqual(activities, wantActivities) {
+		t.Errorf("activities = %+v\nwant %+v", activities, wantActivities)
+	}
+	fort := rows.Activities[0]
+	if fort.Duration != 90 || fort.Rating != 4.6 || fort.Location.Latitude != 26.98 || fort.Location.City != "Jaipur" || fort.Currency != "INR" {
+		t.Errorf("Amber Fort = %+v", fort)
+	}
+
+	// Mapping again yields the same rows, so saving twice overwrites them
+	again, err := MapTripToRows(trip, now)
+	if err != nil || !reflect.DeepEqual(again, rows) {
+		t.Errorf("mapping again = %+v, %v; want the same rows", again, err)
+	}
+
+	fb, fake := newTestFirebase(t)
+	for i := 0; i < 2; i++ {
+		if err := fb.SaveTripRows(context.Background(), rows); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fake.count("itineraries") != 1 || fake.count("day_plans") != 2 || fake.count("activities") != 3 {
+		t.Errorf("saved %d itineraries, %d day plans, %d activities; want 1, 2, 3",
+			fake.count("itineraries"), fake.count("day_plans"), fake.count("activities"))
+	}
+}
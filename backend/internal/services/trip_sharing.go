@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Share codes avoid characters that are easily confused when read aloud or
+// typed (0/O, 1/I/L)
+const (
+	shareCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+	shareCodeLength   = 8
+
+	// shareCodeAttempts bounds retries after a code collision. With 31^8
+	// possible codes a second attempt is already vanishingly rare.
+	shareCodeAttempts = 5
+)
+
+// publicPIIKeys are itinerary keys removed from public trip views, at any
+// depth. "members" holds traveler names in group alternatives.
+var publicPIIKeys = map[string]bool{
+	"emergency_contacts": true,
+	"emergency_contact":  true,
+	"email":              true,
+	"user_email":         true,
+	"phone":              true,
+	"phone_number":       true,
+	"user_id":            true,
+	"members":            true,
+	"home_location":      true,
+	"home_address":       true,
+	"home_coordinates":   true,
+}
+
+// PublicTrip is the view of a trip served by its share code. It carries no
+// owner details.
+type PublicTrip struct {
+	ShareCode   string                 `json:"share_code"`
+	Title       string                 `json:"title"`
+	Destination string                 `json:"destination"`
+	StartDate   time.Time              `json:"start_date"`
+	EndDate     time.Time              `json:"end_date"`
+	Travelers   int                    `json:"travelers"`
+	Status      string                 `json:"status"`
+	Itinerary   map[string]interface{} `json:"itinerary,omitempty"`
+}
+
+// GenerateShareCode returns a random share code. It doesn't check for
+// collisions; use reserveShareCode for that.
+func GenerateShareCode() (string, error) {
+	max := big.NewInt(int64(len(shareCodeAlphabet)))
+	code := make([]byte, shareCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate share code: %v", err)
+		}
+		code[i] = shareCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// reserveShareCode claims an unused share code for a trip. Codes are
+// reserved by creating a document keyed by the code, which fails if another
+// trip already holds it.
+func (f *FirebaseService) reserveShareCode(ctx context.Context, tripID string) (string, error) {
+	for attempt := 0; attempt < shareCodeAttempts; attempt++ {
+		code, err := GenerateShareCode()
+		if err != nil {
+			return "", err
+		}
+		_, err = f.firestore.Collection("trip_share_codes").Doc(code).Create(ctx, map[string]interface{}{
+			"trip_id":    tripID,
+			"created_at": firestore.ServerTimestamp,
+		})
+		if err == nil {
+			return code, nil
+		}
+		if status.Code(err) != codes.AlreadyExists {
+			return "", fmt.Errorf("failed to reserve share code: %v", err)
+		}
+	}
+	return "", fmt.Errorf("failed to reserve share code after %d attempts", shareCodeAttempts)
+}
+
+// SetTripVisibility makes a trip public or private, assigning it a share
+// code first if it was created before codes existed. It returns the share
+// code.
+func (f *FirebaseService) SetTripVisibility(ctx context.Context, trip *TripData, isPublic bool) (string, error) {
+	shareCode := trip.ShareCode
+	updates := map[string]interface{}{"is_public": isPublic}
+	if shareCode == "" {
+		code, err := f.reserveShareCode(ctx, trip.ID)
+		if err != nil {
+			return "", err
+		}
+		shareCode = code
+		updates["share_code"] = code
+	}
+	if err := f.UpdateTrip(ctx, trip.ID, updates); err != nil {
+		return "", err
+	}
+	return shareCode, nil
+}
+
+// GetPublicTrip returns the public view of the trip holding shareCode.
+// Private, deleted and unknown trips all return *TripNotFoundError, so a
+// share code reveals nothing about a trip that isn't public.
+func (f *FirebaseService) GetPublicTrip(ctx context.Context, shareCode string) (*PublicTrip, error) {
+	shareCode = strings.ToUpper(strings.TrimSpace(shareCode))
+	notFound := &TripNotFoundError{TripID: shareCode}
+
+	codeDoc, err := f.firestore.Collection("trip_share_codes").Doc(shareCode).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, notFound
+		}
+		return nil, fmt.Errorf("failed to look up share code: %v", err)
+	}
+	tripID, _ := codeDoc.Data()["trip_id"].(string)
+	if tripID == "" {
+		return nil, notFound
+	}
+
+	tripDoc, err := f.firestore.Collection("trips").Doc(tripID).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, notFound
+		}
+		return nil, fmt.Errorf("failed to get trip: %v", err)
+	}
+	var trip TripData
+	if err := tripDoc.DataTo(&trip); err != nil {
+		return nil, fmt.Errorf("failed to convert trip data: %v", err)
+	}
+	if !trip.IsPublic || trip.Status == "deleted" || trip.ShareCode != shareCode {
+		return nil, notFound
+	}
+
+	return PublicTripView(&trip), nil
+}
+
+// PublicTripView builds the public view of a trip, stripping owner details
+// and personal data from the itinerary
+func PublicTripView(trip *TripData) *PublicTrip {
+	view := &PublicTrip{
+		ShareCode:   trip.ShareCode,
+		Title:       trip.Title,
+		Destination: trip.Destination,
+		StartDate:   timeFromValue(trip.StartDate),
+		EndDate:     timeFromValue(trip.EndDate),
+		Travelers:   trip.Travelers,
+		Status:      trip.Status,
+	}
+	if itinerary, ok := stripPII(trip.Itinerary).(map[string]interface{}); ok {
+		view.Itinerary = itinerary
+	}
+	return view
+}
+
+// stripPII returns a copy of value without publicPIIKeys. The original is
+// left untouched.
+func stripPII(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if v == nil {
+			return nil
+		}
+		clean := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if publicPIIKeys[strings.ToLower(key)] {
+				continue
+			}
+			clean[key] = stripPII(item)
+		}
+		return clean
+	case []interface{}:
+		clean := make([]interface{}, len(v))
+		for i, item := range v {
+			clean[i] = stripPII(item)
+		}
+		return clean
+	case []map[string]interface{}:
+		clean := make([]interface{}, len(v))
+		for i, item := range v {
+			clean[i] = stripPII(item)
+		}
+		return clean
+	default:
+		return value
+	}
+}
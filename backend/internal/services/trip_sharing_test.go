@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStripPII(t *testing.T) {
+	itinerary := map[string]interface{}{
+		"summary":            "Four days in Goa",
+		"Emergency_Contacts": []interface{}{map[string]interface{}{"name": "Asha", "phone": "+91 98765 43210"}},
+		"user_email":         "owner@example.com",
+		"home_location":      map[string]interface{}{"latitude": 19.07, "longitude": 72.87},
+		"days": []interface{}{
+			map[string]interface{}{
+				"day":        1,
+				"activities": []map[string]interface{}{{"name": "Baga Beach", "phone": "+91 0832 000000"}},
+			},
+		},
+		"group_alternatives": []interface{}{map[string]interface{}{"label": "Seniors", "members": []interface{}{"Asha", "Ravi"}}},
+	}
+	want := map[string]interface{}{
+		"summary": "Four days in Goa",
+		"days": []interface{}{
+			map[string]interface{}{
+				"day":        1,
+				"activities": []interface{}{map[string]interface{}{"name": "Baga Beach"}},
+			},
+		},
+		"group_alternatives": []interface{}{map[string]interface{}{"label": "Seniors"}},
+	}
+
+	if got := stripPII(itinerary); !reflect.DeepEqual(got, want) {
+		t.Errorf("stripPII = %v, want %v", got, want)
+	}
+	if _, ok := itinerary["user_email"]; !ok {
+		t.Error("stripPII changed the original itinerary")
+	}
+	if got := stripPII("plain"); got != "plain" {
+		t.Errorf("stripPII(string) = %v, want it unchanged", got)
+	}
+}
+
+func TestGetPublicTrip(t *testing.T) {
+	fb, fake := newTestFirebase(t)
+	ctx := context.Background()
+	start := time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	save := func(id string, public bool, status string) string {
+		trip := &TripData{
+			ID: id, UserID: "owner", Title: "Goa", Destination: "Goa", Status: status, Travelers: 2,
+			StartDate: start, EndDate: start.AddDate(0, 0, 3), IsPublic: public,
+			Itinerary: map[string]interface{}{"summary": "Beaches", "emergency_contacts": []interface{}{"+91 98765 43210"}},
+		}
+		if err := fb.SaveTrip(ctx, trip); err != nil {
+			t.Fatal(err)
+		}
+		if len(trip.ShareCode) != shareCodeLength {
+			t.Fatalf("SaveTrip gave trip %s share code %q", id, trip.ShareCode)
+		}
+		if got := fake.fields("trip_share_codes/" + trip.ShareCode)["trip_id"].GetStringValue(); got != id {
+			t.Fatalf("share code %s reserved for %q, want %s", trip.ShareCode, got, id)
+		}
+		return trip.ShareCode
+	}
+	public := save("public", true, "planned")
+	private := save("private", false, "planned")
+	deleted := save("deleted", true, "deleted")
+
+	trip, err := fb.GetPublicTrip(ctx, " "+strings.ToLower(public)+" ")
+	if err != nil {
+		t.Fatalf("GetPublicTrip(public) = %v", err)
+	}
+	if trip.ShareCode != public || trip.Destination != "Goa" || !trip.StartDate.Equal(start) || trip.Itinerary["summary"] != "Beaches" {
+		t.Errorf("public trip = %+v, want the Goa trip", trip)
+	}
+	if _, ok := trip.Itinerary["emergency_contacts"]; ok {
+		t.Error("public trip includes emergency contacts")
+	}
+
+	for name, code := range map[string]string{"private": private, "deleted": deleted, "unknown": "ZZZZZZZZ"} {
+		var notFound *TripNotFoundError
+		if _, err := fb.GetPublicTrip(ctx, code); !errors.As(err, &notFound) {
+			t.Errorf("GetPublicTrip(%s) = %v, want not found", name, err)
+		}
+	}
+
+	// Making the private trip public serves it under the code it already has
+	privateTrip, err := fb.GetTrip(ctx, "private")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := fb.SetTripVisibility(ctx, privateTrip, true); err != nil || code != private {
+		t.Fatalf("SetTripVisibility = %q, %v; want %s", code, err, private)
+	}
+	if _, err := fb.GetPublicTrip(ctx, private); err != nil {
+		t.Errorf("GetPublicTrip after making it public = %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// smsSummaryMaxLen keeps an SMS summary, with its link, to two concatenated
+// GSM segments
+const smsSummaryMaxLen = 306
+
+// summaryDefaultLocale formats summaries without a language
+var summaryDefaultLocale = &LocaleConfig{DateFormat: "DD/MM/YYYY", TimeFormat: "12h"}
+
+// summaryLine is one detail of a trip summary. Lines are kept by priority,
+// lowest dropped first, but shown in order.
+type summaryLine struct {
+	order    int
+	priority int
+	text     string
+}
+
+// GenerateTripSummary writes a compact, plain-text summary of a trip for
+// SMS or voice: destination and dates, the first activity, the hotel and an
+// emergency number, one per line. It fits within maxLen characters, dropping
+// the least important details first.
+func GenerateTripSummary(data *ItineraryData, maxLen int) string {
+	return tripSummary(data, maxLen, summaryDefaultLocale)
+}
+
+// tripSummary is GenerateTripSummary with the labels, dates and times of a
+// locale
+func tripSummary(data *ItineraryData, maxLen int, config *LocaleConfig) string {
+	label := func(key, fallback string) string {
+		if translated := config.Translations[key]; translated != "" {
+			return translated
+		}
+		return fallback
+	}
+
+	var lines []summaryLine
+	add := func(order, priority int, text string) {
+		if text != "" {
+			lines = append(lines, summaryLine{order: order, priority: priority, text: text})
+		}
+	}
+
+	heading := data.Destination
+	if !data.StartDate.IsZero() {
+		heading += ", " + formatLocaleDate(data.StartDate, config)
+		if !data.EndDate.IsZero() && !data.EndDate.Equal(data.StartDate) {
+			heading += " - " + formatLocaleDate(data.EndDate, config)
+		}
+	}
+	add(0, 0, heading)
+	if contact, ok := summaryEmergencyContact(data.EmergencyContacts); ok {
+		add(3, 1, fmt.Sprintf("%s: %s %s", label("emergency", "Emergency"), contact.Name, contact.Phone))
+	}
+	if len(data.Hotels) > 0 && data.Hotels[0].Name != "" {
+		add(2, 2, fmt.Sprintf("%s: %s", label("hotel", "Hotel"), data.Hotels[0].Name))
+	}
+	if activity, ok := summaryFirstActivity(data); ok {
+		text := activity.Name
+		if !activity.StartTime.IsZero() {
+			text += " " + formatLocaleTime(activity.StartTime, config)
+		}
+		add(1, 3, fmt.Sprintf("%s: %s", label("activities", "Activities"), text))
+	}
+	if len(lines) == 0 || maxLen <= 0 {
+		return ""
+	}
+
+	// Keep details in priority order until one doesn't fit; everything less
+	// important goes with it
+	sort.Slice(lines, func(i, j int) bool { return lines[i].priority < lines[j].priority })
+	kept := lines[:0]
+	length := -1
+	for _, line := range lines {
+		lineLen := utf8.RuneCountInString(line.text) + 1
+		if length+lineLen > maxLen {
+			break
+		}
+		kept = append(kept, line)
+		length += lineLen
+	}
+	if len(kept) == 0 {
+		return truncateRunes(lines[0].text, maxLen)
+	}
+
+	sort.Slice(kept, func(i, j int) bool { return kept[i].order < kept[j].order })
+	texts := make([]string, len(kept))
+	for i, line := range kept {
+		texts[i] = line.text
+	}
+	return strings.Join(texts, "\n")
+}
+
+// summaryEmergencyContact picks the contact to call, preferring one
+// available around the clock
+func summaryEmergencyContact(contacts []EmergencyContact) (EmergencyContact, bool) {
+	var found *EmergencyContact
+	for i := range contacts {
+		if contacts[i].Phone == "" {
+			continue
+		}
+		if contacts[i].Available24h {
+			return contacts[i], true
+		}
+		if found == nil {
+			found = &contacts[i]
+		}
+	}
+	if found == nil {
+		return EmergencyContact{}, false
+	}
+	return *found, true
+}
+
+// summaryFirstActivity returns the trip's first planned activity
+func summaryFirstActivity(data *ItineraryData) (Activity, bool) {
+	days := make([]int, 0, len(data.DailyItinerary))
+	for day := range data.DailyItinerary {
+		days = append(days, day)
+	}
+	sort.Ints(days)
+	for _, day := range days {
+		plan := data.DailyItinerary[day]
+		for _, slot := range [][]Activity{plan.Morning, plan.Afternoon, plan.Evening} {
+			for _, activity := range slot {
+				if activity.Name != "" {
+					return activity, true
+				}
+			}
+		}
+	}
+	return Activity{}, false
+}
+
+// truncateRunes cuts text to at most maxLen characters, marking the cut
+func truncateRunes(text string, maxLen int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	if maxLen == 1 {
+		return "…"
+	}
+	return string(runes[:maxLen-1]) + "…"
+}
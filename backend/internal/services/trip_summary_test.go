@@ -0,0 +1,74 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateTripSummary(t *testing.T) {
+	start := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	data := &ItineraryData{
+		Destination: "Goa",
+		StartDate:   start,
+		EndDate:     start.AddDate(0, 0, 4),
+		Hotels:      []HotelBooking{{Name: "Taj Exotica"}},
+		EmergencyContacts: []EmergencyContact{
+			{Name: "Front desk", Phone: "+91 832 000 0000"},
+			{Name: "Police", Phone: "100", Available24h: true},
+		},
+		DailyItinerary: map[int]DayItinerary{
+			2: {Morning: []Activity{{Name: "Spice farm"}}},
+			1: {Morning: []Activity{{Name: ""}}, Afternoon: []Activity{{Name: "Fort Aguada", StartTime: start.Add(15*time.Hour + 30*time.Minute)}}},
+		},
+	}
+	heading := "Goa, 01/06/2026 - 05/06/2026"
+	activity := "Activities: Fort Aguada 3:30 PM"
+	hotel := "Hotel: Taj Exotica"
+	emergency := "Emergency: Police 100"
+
+	tests := []struct {
+		name   string
+		data   *ItineraryData
+		maxLen int
+		want   []string
+	}{
+		{"everything fits", data, smsSummaryMaxLen, []string{heading, activity, hotel, emergency}},
+		{"activity dropped first", data, len(heading + hotel + emergency + "\n\n"), []string{heading, hotel, emergency}},
+		{"then the hotel", data, len(heading+emergency+"\n") + 5, []string{heading, emergency}},
+		{"heading alone", data, len(heading), []string{heading}},
+		{"heading cut short", data, 8, []string{"Goa, 01…"}},
+		{"destination only", &ItineraryData{Destination: "Goa"}, smsSummaryMaxLen, []string{"Goa"}},
+		{"no room", data, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GenerateTripSummary(tt.data, tt.maxLen)
+			if want := strings.Join(tt.want, "\n"); got != want {
+				t.Errorf("summary =\n%s\nwant\n%s", got, want)
+			}
+			if len([]rune(got)) > tt.maxLen {
+				t.Errorf("summary is %d characters, over %d", len([]rune(got)), tt.maxLen)
+			}
+		})
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	tests := []struct {
+		text   string
+		maxLen int
+		want   string
+	}{
+		{"Jaipur", 10, "Jaipur"},
+		{"Jaipur", 6, "Jaipur"},
+		{"Jaipur", 4, "Jai…"},
+		{"जयपुर शहर", 4, "जयप…"},
+		{"Jaipur", 1, "…"},
+	}
+	for _, tt := range tests {
+		if got := truncateRunes(tt.text, tt.maxLen); got != tt.want {
+			t.Errorf("truncateRunes(%q, %d) = %q, want %q", tt.text, tt.maxLen, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TripVersionConflictError is returned when a write is based on a version of
+// the trip older than the stored one: someone else changed the trip since
+// the writer read it. Current is the trip as stored, for the client to
+// resolve the conflict against.
+type TripVersionConflictError struct {
+	TripID         string
+	BaseVersion    int64
+	CurrentVersion int64
+	Current        *TripData
+}
+
+func (e *TripVersionConflictError) Error() string {
+	return fmt.Sprintf("trip %s was changed since version %d; it is now at version %d", e.TripID, e.BaseVersion, e.CurrentVersion)
+}
+
+// Is makes the error match ErrConflict
+func (e *TripVersionConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// UpdateTripAtVersion applies updates to a trip only if it's still at
+// baseVersion, returning the trip's new version. A trip changed since
+// returns *TripVersionConflictError and is left as it is.
+func (f *FirebaseService) UpdateTripAtVersion(ctx context.Context, tripID string, baseVersion int64, updates map[string]interface{}) (int64, error) {
+	firestoreUpdates := make([]firestore.Update, 0, len(updates))
+	for key, value := range updates {
+		if key == "version" {
+			continue
+		}
+		firestoreUpdates = append(firestoreUpdates, firestore.Update{Path: key, Value: value})
+	}
+	return f.updateTripAtVersion(ctx, tripID, baseVersion, firestoreUpdates, nil)
+}
+
+// updateTripAtVersion runs within, if given, inside the transaction once the
+// version matches, to check the stored trip or write related documents
+func (f *FirebaseService) updateTripAtVersion(ctx context.Context, tripID string, baseVersion int64, updates []firestore.Update, within func(*firestore.Transaction, *TripData) error) (int64, error) {
+	ref := f.firestore.Collection("trips").Doc(tripID)
+	newVersion := baseVersion + 1
+	err := f.firestore.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return &TripNotFoundError{TripID: tripID}
+			}
+			return err
+		}
+		var trip TripData
+		if err := doc.DataTo(&trip); err != nil {
+			return err
+		}
+		if trip.Status == "deleted" {
+			return &TripNotFoundError{TripID: tripID}
+		}
+		if trip.Version != baseVersion {
+			return &TripVersionConflictError{
+				TripID:         tripID,
+				BaseVersion:    baseVersion,
+				CurrentVersion: trip.Version,
+				Current:        &trip,
+			}
+		}
+		if within != nil {
+			if err := within(tx, &trip); err != nil {
+				return err
+			}
+		}
+
+		writes := append([]firestore.Update{{Path: "version", Value: newVersion}}, updates...)
+		// Firestore rejects a field written twice, so callers may set
+		// updated_at themselves
+		if !hasUpdatePath(updates, "updated_at") {
+			writes = append(writes, firestore.Update{Path: "updated_at", Value: firestore.ServerTimestamp})
+		}
+		return tx.Update(ref, writes)
+	})
+	if err != nil {
+		var notFound *TripNotFoundError
+		var conflict *TripVersionConflictError
+		if errors.As(err, &notFound) || errors.As(err, &conflict) || errors.Is(err, ErrValidation) {
+			return 0, err
+		}
+		return 0, fmt.Errorf("failed to update trip: %v", err)
+	}
+	return newVersion, nil
+}
+
+// patchableTripFields are the trip fields PatchTrip can change. Itinerary
+// fields are changed one at a time, as "itinerary.<key>" (like
+// "itinerary.day_2"), so collaborators editing different days don't
+// overwrite each other's days.
+var patchableTripFields = map[string]bool{
+	"title":       true,
+	"destination": true,
+	"start_date":  true,
+	"end_date":    true,
+	"budget":      true,
+	"travelers":   true,
+	"status":      true,
+}
+
+// tripPatchStatuses are the statuses a trip can be patched to
+var tripPatchStatuses = map[string]bool{
+	"draft":     true,
+	"planned":   true,
+	"ongoing":   true,
+	"completed": true,
+	"cancelled": true,
+}
+
+// itineraryFieldPattern matches an itinerary key a patch can change
+var itineraryFieldPattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// PatchTrip applies field-level changes to a trip written against
+// baseVersion, returning the trip's new version. Changes map a field (see
+// patchableTripFields) or "itinerary.<key>" to its new value; a nil
+// itinerary value removes the key. Unknown fields and bad values return
+// ErrValidation; a trip changed since baseVersion returns
+// *TripVersionConflictError.
+func (f *FirebaseService) PatchTrip(ctx context.Context, tripID string, baseVersion int64, changes map[string]interface{}) (int64, error) {
+	updates, err := tripPatchUpdates(changes)
+	if err != nil {
+		return 0, err
+	}
+	return f.updateTripAtVersion(ctx, tripID, baseVersion, updates, datesInOrder(updates))
+}
+
+// tripPatchUpdates validates a patch's changes and converts them to
+// Firestore updates
+func tripPatchUpdates(changes map[string]interface{}) ([]firestore.Update, error) {
+	if len(changes) == 0 {
+		return nil, kindErrorf(ErrValidation, "a patch needs at least one change")
+	}
+
+	updates := make([]firestore.Update, 0, len(changes))
+	for field, value := range changes {
+		if key, ok := strings.CutPrefix(field, "itinerary."); ok {
+			if !itineraryFieldPattern.MatchString(key) {
+				return nil, kindErrorf(ErrValidation, "invalid itinerary field %q", key)
+			}
+			if value == nil {
+				value = firestore.Delete
+			}
+			updates = append(updates, firestore.Update{Path: field, Value: value})
+			continue
+		}
+		if !patchableTripFields[field] {
+			return nil, kindErrorf(ErrValidation, "field %q can't be patched", field)
+		}
+
+		value, err := tripPatchValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, firestore.Update{Path: field, Value: value})
+	}
+
+	// Dates patched together must still be in order; a single date is
+	// checked against the stored trip when the patch is applied
+	start, hasStart := changeTime(updates, "start_date")
+	end, hasEnd := changeTime(updates, "end_date")
+	if hasStart && hasEnd && !end.After(start) {
+		return nil, kindErrorf(ErrValidation, "end_date must be after start_date")
+	}
+	return updates, nil
+}
+
+// datesInOrder checks that a trip's dates are still in order once updates
+// are applied to it
+func datesInOrder(updates []firestore.Update) func(*firestore.Transaction, *TripData) error {
+	return func(_ *firestore.Transaction, trip *TripData) error {
+		start, ok := changeTime(updates, "start_date")
+		if !ok {
+			start = timeFromValue(trip.StartDate)
+		}
+		end, ok := changeTime(updates, "end_date")
+		if !ok {
+			end = timeFromValue(trip.EndDate)
+		}
+		if !start.IsZero() && !end.IsZero() && !end.After(start) {
+			return kindErrorf(ErrValidation, "end_date must be after start_date")
+		}
+		return nil
+	}
+}
+
+// tripPatchValue checks and normalizes the new value of a trip field
+func tripPatchValue(field string, value interface{}) (interface{}, error) {
+	switch field {
+	case "start_date", "end_date":
+		s, _ := value.(string)
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+		return nil, kindErrorf(ErrValidation, "%s must be a date (YYYY-MM-DD or RFC 3339)", field)
+	case "budget":
+		budget, ok := value.(float64)
+		if !ok || budget < 0 {
+			return nil, kindErrorf(ErrValidation, "budget must be a number of at least 0")
+		}
+		return budget, nil
+	case "travelers":
+		travelers, ok := value.(float64)
+		if !ok || travelers < 1 || travelers != float64(int(travelers)) {
+			return nil, kindErrorf(ErrValidation, "travelers must be a whole number of at least 1")
+		}
+		return int(travelers), nil
+	case "status":
+		s, _ := value.(string)
+		if !tripPatchStatuses[s] {
+			return nil, kindErrorf(ErrValidation, "invalid status %q", s)
+		}
+		return s, nil
+	default:
+		s, ok := value.(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return nil, kindErrorf(ErrValidation, "%s must be a non-empty string", field)
+		}
+		return strings.TrimSpace(s), nil
+	}
+}
+
+// changeTime returns the time a list of updates sets a field to
+func changeTime(updates []firestore.Update, field string) (time.Time, bool) {
+	for _, update := range updates {
+		if update.Path == field {
+			t, ok := update.Value.(time.Time)
+			return t, ok
+		}
+	}
+	return time.Time{}, false
+}
+
+// hasUpdatePath reports whether a list of updates writes a field
+func hasUpdatePath(updates []firestore.Update, path string) bool {
+	for _, update := range updates {
+		if update.Path == path {
+			return true
+		}
+	}
+	return false
+}
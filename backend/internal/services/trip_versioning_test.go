@@ -0,0 +1,34 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+func TestDatesInOrderChecksAgainstStoredTrip(t *testing.T) {
+	trip := &TripData{
+		StartDate: time.Date(2026, 6, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		name    string
+		updates []firestore.Update
+		wantErr bool
+	}{
+		{"new end after stored start", []firestore.Update{{Path: "end_date", Value: time.Date(2026, 6, 12, 0, 0, 0, 0, time.UTC)}}, false},
+		{"new end before stored start", []firestore.Update{{Path: "end_date", Value: time.Date(2026, 6, 8, 0, 0, 0, 0, time.UTC)}}, true},
+		{"new start after stored end", []firestore.Update{{Path: "start_date", Value: time.Date(2026, 6, 20, 0, 0, 0, 0, time.UTC)}}, true},
+		{"no dates", []firestore.Update{{Path: "budget", Value: 100.0}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := datesInOrder(tt.updates)(nil, trip)
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrValidation)) {
+				t.Errorf("datesInOrder = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
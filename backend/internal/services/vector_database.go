@@ -9,30 +9,48 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// dimensionMismatchLogged ensures the embedding dimension mismatch warning is
+// only emitted once per process instead of once per scored document
+var dimensionMismatchLogged sync.Once
+
 // VectorDatabase handles embedding storage and similarity search
 type VectorDatabase struct {
 	firestore        *firestore.Client
-	gemini           *GeminiService
+	gemini           AIGenerator
 	embeddingService *EmbeddingService
+	indexes          *vectorIndexCache
+
+	// predictEmbeddings calls the embeddings API for several texts at once;
+	// nil means mock embeddings
+	predictEmbeddings func(ctx context.Context, texts []string) ([][]float64, error)
 }
 
 // NewVectorDatabase creates a new vector database instance
-func NewVectorDatabase(firestoreClient *firestore.Client, gemini *GeminiService) *VectorDatabase {
+func NewVectorDatabase(firestoreClient *firestore.Client, gemini AIGenerator) *VectorDatabase {
 	embeddingService, err := NewEmbeddingService()
 	if err != nil {
 		log.Printf("Failed to create embedding service: %v", err)
 	}
 
-	return &VectorDatabase{
+	vdb := &VectorDatabase{
 		firestore:        firestoreClient,
 		gemini:           gemini,
 		embeddingService: embeddingService,
+		indexes:          newVectorIndexCache(),
+	}
+	if embeddingService != nil {
+		vdb.predictEmbeddings = embeddingService.predictEmbeddings
 	}
+	return vdb
 }
 
 // EmbeddingDocument represents a document with embeddings
@@ -58,15 +76,21 @@ func (vdb *VectorDatabase) StoreEmbedding(ctx context.Context, doc EmbeddingDocu
 		return fmt.Errorf("document ID is required")
 	}
 
-	// Generate embedding if not provided
+	// Generate embedding if not provided, unless the stored document
+	// already has one for the same content
 	if len(doc.Embedding) == 0 && doc.Content != "" {
-		embedding, err := vdb.generateEmbedding(ctx, doc.Content)
-		if err != nil {
-			log.Printf("Failed to generate embedding: %v", err)
-			// Continue without embedding - store document anyway
-		} else {
-			doc.Embedding = embedding
+		hash := vdb.contentHash(doc.Content)
+		existing := vdb.storedEmbeddings(ctx, []EmbeddingDocument{doc})[storedEmbeddingKey(doc)]
+		if !reuseEmbedding(&doc, existing, hash) {
+			embeddings, err := vdb.tryGenerateEmbeddings(ctx, []string{doc.Content})
+			var embedding []float64
+			if err == nil && len(embeddings) == 1 {
+				embedding = embeddings[0]
+			}
+			vdb.settleEmbedding(&doc, existing, hash, embedding, err)
 		}
+	} else if doc.Content != "" {
+		doc.Metadata = withMetadata(doc.Metadata, contentHashKey, vdb.contentHash(doc.Content))
 	}
 
 	doc.UpdatedAt = time.Now()
@@ -79,27 +103,224 @@ func (vdb *VectorDatabase) StoreEmbedding(ctx context.Context, doc EmbeddingDocu
 	if err != nil {
 		return fmt.Errorf("failed to store embedding document: %v", err)
 	}
+	vdb.indexes.invalidate(doc.Type)
 
 	log.Printf("Stored embedding document: %s in collection: %s", doc.ID, collection)
 	return nil
 }
 
-// SearchSimilar finds similar documents using cosine similarity
+// embeddingBatchConcurrency bounds how many embedding batch requests
+// StoreEmbeddingsBatch keeps in flight at once
+const embeddingBatchConcurrency = 4
+
+// BatchStoreError reports the documents that could not be stored by
+// StoreEmbeddingsBatch, keyed by document ID
+type BatchStoreError struct {
+	Failed map[string]error
+}
+
+func (e *BatchStoreError) Error() string {
+	ids := make([]string, 0, len(e.Failed))
+	for id := range e.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return fmt.Sprintf("failed to store %d embedding documents: %s", len(ids), strings.Join(ids, ", "))
+}
+
+// StoreEmbeddingsBatch stores many documents at once. Missing embeddings are
+// generated in batched API requests with bounded concurrency, and documents are
+// written with a Firestore bulk writer. Documents that already carry an
+// embedding, or whose stored version has one for the same content, skip
+// generation. A failure for one document does not abort the rest; the
+// returned *BatchStoreError lists the IDs that failed.
+func (vdb *VectorDatabase) StoreEmbeddingsBatch(ctx context.Context, docs []EmbeddingDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	// Work on a copy so generated embeddings don't leak into the caller's slice
+	docs = append([]EmbeddingDocument(nil), docs...)
+
+	failed := make(map[string]error)
+	var missing []int
+	for i, doc := range docs {
+		if doc.ID == "" {
+			failed[fmt.Sprintf("index_%d", i)] = fmt.Errorf("document ID is required")
+			continue
+		}
+		switch {
+		case len(doc.Embedding) == 0 && doc.Content != "":
+			missing = append(missing, i)
+		case doc.Content != "":
+			docs[i].Metadata = withMetadata(doc.Metadata, contentHashKey, vdb.contentHash(doc.Content))
+		}
+	}
+
+	// Reuse stored embeddings whose content hasn't changed
+	lookup := make([]EmbeddingDocument, len(missing))
+	for i, idx := range missing {
+		lookup[i] = docs[idx]
+	}
+	stored := vdb.storedEmbeddings(ctx, lookup)
+	hashes := make(map[int]string, len(missing))
+	var pending []int
+	for _, idx := range missing {
+		hashes[idx] = vdb.contentHash(docs[idx].Content)
+		if !reuseEmbedding(&docs[idx], stored[storedEmbeddingKey(docs[idx])], hashes[idx]) {
+			pending = append(pending, idx)
+		}
+	}
+
+	// Generate missing embeddings in chunks, a few chunks at a time
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, embeddingBatchConcurrency)
+	for start := 0; start < len(pending); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		chunk := pending[start:end]
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(chunk []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			texts := make([]string, len(chunk))
+			for i, idx := range chunk {
+				texts[i] = docs[idx].Content
+			}
+
+			embeddings, err := vdb.tryGenerateEmbeddings(ctx, texts)
+			if err == nil && len(embeddings) != len(texts) {
+				err = fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+			}
+			for i, idx := range chunk {
+				// Each goroutine owns distinct indexes, so no locking is needed
+				var embedding []float64
+				if err == nil {
+					embedding = embeddings[i]
+				}
+				vdb.settleEmbedding(&docs[idx], stored[storedEmbeddingKey(docs[idx])], hashes[idx], embedding, err)
+			}
+		}(chunk)
+	}
+	wg.Wait()
+
+	// Write all documents with a bulk writer
+	now := time.Now()
+	writer := vdb.firestore.BulkWriter(ctx)
+	jobs := make(map[string]*firestore.BulkWriterJob)
+	for _, doc := range docs {
+		if doc.ID == "" {
+			continue
+		}
+		doc.UpdatedAt = now
+		if doc.CreatedAt.IsZero() {
+			doc.CreatedAt = now
+		}
+
+		ref := vdb.firestore.Collection(vdb.getCollectionName(doc.Type)).Doc(doc.ID)
+		job, err := writer.Set(ref, doc)
+		if err != nil {
+			failed[doc.ID] = err
+			continue
+		}
+		jobs[doc.ID] = job
+	}
+	writer.End()
+
+	types := make(map[string]bool)
+	for _, doc := range docs {
+		types[doc.Type] = true
+	}
+	for docType := range types {
+		vdb.indexes.invalidate(docType)
+	}
+
+	for id, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			failed[id] = err
+		}
+	}
+
+	log.Printf("Stored %d/%d embedding documents in batch (%d embeddings generated)", len(docs)-len(failed), len(docs), len(pending))
+
+	if len(failed) > 0 {
+		return &BatchStoreError{Failed: failed}
+	}
+	return nil
+}
+
+// SearchSimilar finds similar documents using cosine similarity. Large
+// collections are searched through the approximate nearest-neighbor index.
 func (vdb *VectorDatabase) SearchSimilar(ctx context.Context, query string, docType string, limit int) ([]SimilarityResult, error) {
+	return vdb.SearchSimilarWithOptions(ctx, query, docType, limit, SearchOptions{})
+}
+
+// SearchSimilarWithOptions finds similar documents, optionally filtering on
+// metadata and forcing an exact full-collection scan instead of the approximate
+// index
+func (vdb *VectorDatabase) SearchSimilarWithOptions(ctx context.Context, query string, docType string, limit int, opts SearchOptions) ([]SimilarityResult, error) {
 	// Generate embedding for query
 	queryEmbedding, err := vdb.generateEmbedding(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate query embedding: %v", err)
 	}
 
-	// Retrieve all documents of the specified type
 	collection := vdb.getCollectionName(docType)
+
+	// Large collections are searched through the ANN index, which is built
+	// in the background; until it's ready queries scan the collection
+	var candidates []EmbeddingDocument
+	if !opts.Exact {
+		if idx, fresh := vdb.indexes.get(docType, len(queryEmbedding)); idx != nil {
+			if !fresh {
+				vdb.indexes.rebuild(docType, len(queryEmbedding), func(ctx context.Context) ([]EmbeddingDocument, error) {
+					return vdb.loadEmbeddingDocuments(ctx, collection)
+				})
+			}
+			candidates = filterDocuments(idx.candidates(queryEmbedding), opts.Filter)
+			// Too few candidates to fill the page; score the whole cached set
+			if len(candidates) < limit {
+				candidates = filterDocuments(idx.documents, opts.Filter)
+			}
+		}
+	}
+
+	if candidates == nil {
+		docs, err := vdb.loadEmbeddingDocuments(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		candidates = filterDocuments(docs, opts.Filter)
+
+		if !opts.Exact && len(docs) >= annMinDocuments {
+			vdb.indexes.rebuild(docType, len(queryEmbedding), func(context.Context) ([]EmbeddingDocument, error) {
+				return docs, nil
+			})
+		}
+	}
+
+	results := vdb.rankBySimilarity(queryEmbedding, candidates, collection)
+
+	// Limit results
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// loadEmbeddingDocuments reads every document with an embedding from a collection
+func (vdb *VectorDatabase) loadEmbeddingDocuments(ctx context.Context, collection string) ([]EmbeddingDocument, error) {
 	docs, err := vdb.firestore.Collection(collection).Documents(ctx).GetAll()
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve documents: %v", err)
 	}
 
-	var results []SimilarityResult
+	embeddingDocs := make([]EmbeddingDocument, 0, len(docs))
 	for _, doc := range docs {
 		var embeddingDoc EmbeddingDocument
 		if err := doc.DataTo(&embeddingDoc); err != nil {
@@ -112,25 +333,118 @@ func (vdb *VectorDatabase) SearchSimilar(ctx context.Context, query string, docT
 			continue
 		}
 
-		// Calculate cosine similarity
-		similarity := vdb.cosineSimilarity(queryEmbedding, embeddingDoc.Embedding)
+		embeddingDocs = append(embeddingDocs, embeddingDoc)
+	}
+
+	return embeddingDocs, nil
+}
+
+// rankBySimilarity scores documents against the query embedding and sorts them
+// by descending similarity
+func (vdb *VectorDatabase) rankBySimilarity(queryEmbedding []float64, docs []EmbeddingDocument, collection string) []SimilarityResult {
+	var results []SimilarityResult
+	skippedDimension := 0
+	for _, doc := range docs {
+		// Skip documents embedded with a different model/dimension (e.g. a mock
+		// embedding stored while the embedding service was unavailable)
+		if len(doc.Embedding) != len(queryEmbedding) {
+			skippedDimension++
+			continue
+		}
+
 		results = append(results, SimilarityResult{
-			Document:   embeddingDoc,
-			Similarity: similarity,
+			Document:   doc,
+			Similarity: vdb.cosineSimilarity(queryEmbedding, doc.Embedding),
 		})
 	}
 
+	if skippedDimension > 0 {
+		log.Printf("Skipped %d documents in %s with embedding dimension != %d", skippedDimension, collection, len(queryEmbedding))
+	}
+
 	// Sort by similarity (descending)
 	sort.Slice(results, func(i, j int) bool {
 		return results[i].Similarity > results[j].Similarity
 	})
 
-	// Limit results
-	if limit > 0 && len(results) > limit {
-		results = results[:limit]
+	return results
+}
+
+const (
+	// reindexInterval spaces out embedding requests during reindexing so a
+	// maintenance run doesn't exhaust the embedding API quota
+	reindexInterval = 100 * time.Millisecond
+	// reindexLogEvery controls how often reindex progress is logged
+	reindexLogEvery = 50
+)
+
+// ReindexMissingEmbeddings regenerates embeddings for documents of docType that
+// were stored without one, or whose embedding wasn't generated from their
+// current content (e.g. because generation failed at write time), and returns
+// how many were fixed. Documents with an up-to-date embedding are left
+// untouched, so the method is safe to re-run after an interruption.
+func (vdb *VectorDatabase) ReindexMissingEmbeddings(ctx context.Context, docType string) (int, error) {
+	collection := vdb.getCollectionName(docType)
+	iter := vdb.firestore.Collection(collection).Documents(ctx)
+	defer iter.Stop()
+
+	ticker := time.NewTicker(reindexInterval)
+	defer ticker.Stop()
+
+	scanned, fixed := 0, 0
+	for {
+		snap, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fixed, fmt.Errorf("failed to scan %s: %v", collection, err)
+		}
+		scanned++
+
+		if scanned%reindexLogEvery == 0 {
+			log.Printf("Reindexing %s: scanned %d documents, fixed %d", collection, scanned, fixed)
+		}
+
+		var doc EmbeddingDocument
+		if err := snap.DataTo(&doc); err != nil {
+			log.Printf("Failed to unmarshal document %s: %v", snap.Ref.ID, err)
+			continue
+		}
+		if doc.Content == "" || len(doc.Embedding) > 0 && getStringFromMetadata(doc.Metadata, contentHashKey) == vdb.contentHash(doc.Content) {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fixed, ctx.Err()
+		case <-ticker.C:
+		}
+
+		embeddings, err := vdb.tryGenerateEmbeddings(ctx, []string{doc.Content})
+		if err != nil || len(embeddings) != 1 {
+			log.Printf("Failed to generate embedding for %s: %v", snap.Ref.ID, err)
+			continue
+		}
+
+		_, err = snap.Ref.Update(ctx, []firestore.Update{
+			{Path: "embedding", Value: embeddings[0]},
+			{Path: "metadata." + contentHashKey, Value: vdb.contentHash(doc.Content)},
+			{Path: "updated_at", Value: time.Now()},
+		})
+		if err != nil {
+			log.Printf("Failed to update embedding for %s: %v", snap.Ref.ID, err)
+			continue
+		}
+		fixed++
 	}
 
-	return results, nil
+	if fixed > 0 {
+		vdb.indexes.invalidate(docType)
+	}
+
+	log.Printf("Reindexed %s: scanned %d documents, fixed %d", collection, scanned, fixed)
+	return fixed, nil
 }
 
 // StoreAttractionEmbedding stores an attraction with embedding
@@ -149,6 +463,8 @@ func (vdb *VectorDatabase) StoreAttractionEmbedding(ctx context.Context, attract
 		"location":    attraction.Location,
 		"tags":        attraction.Tags,
 		"available":   attraction.Available,
+
+		"opening_hours": attraction.OpeningHours,
 	}
 
 	doc := EmbeddingDocument{
@@ -161,6 +477,45 @@ func (vdb *VectorDatabase) StoreAttractionEmbedding(ctx context.Context, attract
 	return vdb.StoreEmbedding(ctx, doc)
 }
 
+// StoreHotelEmbedding stores a hotel with embedding
+func (vdb *VectorDatabase) StoreHotelEmbedding(ctx context.Context, hotel Hotel) error {
+	return vdb.StoreEmbedding(ctx, hotelDocument(hotel))
+}
+
+// StoreHotelEmbeddings stores many hotels at once; see StoreEmbeddingsBatch
+func (vdb *VectorDatabase) StoreHotelEmbeddings(ctx context.Context, hotels []Hotel) error {
+	docs := make([]EmbeddingDocument, 0, len(hotels))
+	for _, hotel := range hotels {
+		docs = append(docs, hotelDocument(hotel))
+	}
+	return vdb.StoreEmbeddingsBatch(ctx, docs)
+}
+
+// hotelDocument is the embedding document a hotel is indexed as
+func hotelDocument(hotel Hotel) EmbeddingDocument {
+	content := fmt.Sprintf("%s %s %s",
+		hotel.Name,
+		hotel.Location.Address,
+		strings.Join(hotel.Amenities, " "))
+
+	metadata := map[string]interface{}{
+		"name":            hotel.Name,
+		"rating":          hotel.Rating,
+		"price_per_night": hotel.PricePerNight,
+		"location":        hotel.Location,
+		"amenities":       hotel.Amenities,
+		"available":       hotel.Available,
+		"booking_url":     hotel.BookingURL,
+	}
+
+	return EmbeddingDocument{
+		ID:       hotel.ID,
+		Type:     "hotel",
+		Content:  content,
+		Metadata: metadata,
+	}
+}
+
 // StoreTripEmbedding stores a trip with embedding
 func (vdb *VectorDatabase) StoreTripEmbedding(ctx context.Context, trip TripData) error {
 	// Create content from trip details
@@ -187,6 +542,24 @@ func (vdb *VectorDatabase) StoreTripEmbedding(ctx context.Context, trip TripData
 	return vdb.StoreEmbedding(ctx, doc)
 }
 
+// SetTripEmbeddingStatus updates the status stored with a trip's embedding,
+// so FindSimilarTrips can skip deleted trips without re-embedding them. A
+// trip that was never embedded is left alone.
+func (vdb *VectorDatabase) SetTripEmbeddingStatus(ctx context.Context, tripID, tripStatus string) error {
+	_, err := vdb.firestore.Collection(vdb.getCollectionName("trip")).Doc(tripID).Update(ctx, []firestore.Update{
+		{Path: "metadata.status", Value: tripStatus},
+		{Path: "updated_at", Value: time.Now()},
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		return fmt.Errorf("failed to update trip embedding status: %v", err)
+	}
+	vdb.indexes.invalidate("trip")
+	return nil
+}
+
 // StoreUserPreferencesEmbedding stores user preferences with embedding
 func (vdb *VectorDatabase) StoreUserPreferencesEmbedding(ctx context.Context, userProfile UserProfile) error {
 	// Create content from user preferences
@@ -220,51 +593,96 @@ func (vdb *VectorDatabase) FindSimilarAttractions(ctx context.Context, interests
 
 	var attractions []Attraction
 	for _, result := range results {
-		// Convert metadata back to Attraction
-		attraction := Attraction{
-			ID:          result.Document.ID,
-			Name:        getStringFromMetadata(result.Document.Metadata, "name"),
-			Type:        getStringFromMetadata(result.Document.Metadata, "type"),
-			Rating:      getFloatFromMetadata(result.Document.Metadata, "rating"),
-			PriceLevel:  getIntFromMetadata(result.Document.Metadata, "price_level"),
-			Description: result.Document.Content,
-			Available:   getBoolFromMetadata(result.Document.Metadata, "available"),
-		}
-
-		// Extract tags
-		if tagsInterface, ok := result.Document.Metadata["tags"]; ok {
-			if tags, ok := tagsInterface.([]interface{}); ok {
-				for _, tag := range tags {
-					if tagStr, ok := tag.(string); ok {
-						attraction.Tags = append(attraction.Tags, tagStr)
-					}
-				}
+		attractions = append(attractions, attractionFromDocument(result.Document))
+	}
+
+	return attractions, nil
+}
+
+// attractionFromDocument converts an attraction's embedding document back
+// to an Attraction
+func attractionFromDocument(doc EmbeddingDocument) Attraction {
+	attraction := Attraction{
+		ID:           doc.ID,
+		Name:         getStringFromMetadata(doc.Metadata, "name"),
+		Type:         getStringFromMetadata(doc.Metadata, "type"),
+		Rating:       getFloatFromMetadata(doc.Metadata, "rating"),
+		PriceLevel:   getIntFromMetadata(doc.Metadata, "price_level"),
+		Description:  doc.Content,
+		Tags:         metadataStrings(doc.Metadata, "tags"),
+		OpeningHours: metadataStrings(doc.Metadata, "opening_hours"),
+		Available:    getBoolFromMetadata(doc.Metadata, "available"),
+	}
+
+	if location, ok := metadataLocation(doc.Metadata); ok {
+		attraction.Location = location
+		if locationMap, ok := doc.Metadata["location"].(map[string]interface{}); ok {
+			attraction.Location.Address = getStringFromMetadata(locationMap, "address")
+			if attraction.Location.Address == "" {
+				attraction.Location.Address = getStringFromMetadata(locationMap, "Address")
 			}
 		}
+	}
 
-		// Extract location
-		if locationInterface, ok := result.Document.Metadata["location"]; ok {
-			if locationMap, ok := locationInterface.(map[string]interface{}); ok {
-				attraction.Location = Location{
-					Latitude:  getFloatFromMetadata(locationMap, "latitude"),
-					Longitude: getFloatFromMetadata(locationMap, "longitude"),
-					Address:   getStringFromMetadata(locationMap, "address"),
-				}
+	return attraction
+}
+
+// hotelFromDocument converts a hotel's embedding document back to a Hotel
+func hotelFromDocument(doc EmbeddingDocument) Hotel {
+	hotel := Hotel{
+		ID:            doc.ID,
+		Name:          getStringFromMetadata(doc.Metadata, "name"),
+		Rating:        getFloatFromMetadata(doc.Metadata, "rating"),
+		PricePerNight: getFloatFromMetadata(doc.Metadata, "price_per_night"),
+		Amenities:     metadataStrings(doc.Metadata, "amenities"),
+		Available:     getBoolFromMetadata(doc.Metadata, "available"),
+		BookingURL:    getStringFromMetadata(doc.Metadata, "booking_url"),
+	}
+	if hotel.Name == "" {
+		hotel.Name = doc.Content
+	}
+
+	if location, ok := metadataLocation(doc.Metadata); ok {
+		hotel.Location = location
+		if locationMap, ok := doc.Metadata["location"].(map[string]interface{}); ok {
+			hotel.Location.Address = getStringFromMetadata(locationMap, "address")
+			if hotel.Location.Address == "" {
+				hotel.Location.Address = getStringFromMetadata(locationMap, "Address")
 			}
 		}
-
-		attractions = append(attractions, attraction)
 	}
 
-	return attractions, nil
+	return hotel
 }
 
-// FindSimilarTrips finds trips similar to the given destination and preferences
+// metadataStrings reads a list of strings from document metadata
+func metadataStrings(metadata map[string]interface{}, key string) []string {
+	switch values := metadata[key].(type) {
+	case []string:
+		return values
+	case []interface{}:
+		var strs []string
+		for _, value := range values {
+			if str, ok := value.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+// FindSimilarTrips finds trips similar to the given destination and
+// preferences, excluding deleted trips
 func (vdb *VectorDatabase) FindSimilarTrips(ctx context.Context, destination string, preferences map[string]interface{}, limit int) ([]TripData, error) {
 	preferencesStr, _ := json.Marshal(preferences)
 	query := fmt.Sprintf("%s %s", destination, string(preferencesStr))
 
-	results, err := vdb.SearchSimilar(ctx, query, "trip", limit)
+	results, err := vdb.SearchSimilarWithOptions(ctx, query, "trip", limit, SearchOptions{
+		Filter: &MetadataFilter{Predicate: func(doc EmbeddingDocument) bool {
+			return getStringFromMetadata(doc.Metadata, "status") != "deleted"
+		}},
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -340,9 +758,17 @@ func (vdb *VectorDatabase) generateMockEmbedding(text string) []float64 {
 	return vdb.normalizeVector(embedding)
 }
 
-// cosineSimilarity calculates cosine similarity between two vectors
+// cosineSimilarity calculates cosine similarity between two vectors.
+// It returns 0 instead of NaN when the vectors differ in length, are empty,
+// or either one has zero magnitude.
 func (vdb *VectorDatabase) cosineSimilarity(a, b []float64) float64 {
 	if len(a) != len(b) {
+		dimensionMismatchLogged.Do(func() {
+			log.Printf("cosineSimilarity: embedding dimension mismatch (%d vs %d), returning 0", len(a), len(b))
+		})
+		return 0.0
+	}
+	if len(a) == 0 {
 		return 0.0
 	}
 
@@ -357,7 +783,12 @@ func (vdb *VectorDatabase) cosineSimilarity(a, b []float64) float64 {
 		return 0.0
 	}
 
-	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+	similarity := dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
+	if math.IsNaN(similarity) || math.IsInf(similarity, 0) {
+		return 0.0
+	}
+
+	return similarity
 }
 
 // normalizeVector normalizes a vector to unit length
@@ -401,6 +832,8 @@ func getFloatFromMetadata(metadata map[string]interface{}, key string) float64 {
 			return v
 		case int:
 			return float64(v)
+		case int64:
+			return float64(v)
 		case string:
 			if f, err := strconv.ParseFloat(v, 64); err == nil {
 				return f
@@ -415,6 +848,8 @@ func getIntFromMetadata(metadata map[string]interface{}, key string) int {
 		switch v := val.(type) {
 		case int:
 			return v
+		case int64:
+			return int(v)
 		case float64:
 			return int(v)
 		case string:
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "cloud.google.com/go/firestore/apiv1/firestorepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestHotelDocumentRoundTrip(t *testing.T) {
+	hotel := Hotel{
+		ID:            "place-1",
+		Name:          "Zostel Jaipur",
+		Location:      Location{Address: "Hathroi Fort"},
+		Rating:        4.4,
+		PricePerNight: 1200,
+		Amenities:     []string{"WiFi", "Rooftop"},
+		Available:     true,
+	}
+
+	doc := hotelDocument(hotel)
+	if doc.ID != hotel.ID || doc.Type != "hotel" || doc.Content != "Zostel Jaipur Hathroi Fort WiFi Rooftop" {
+		t.Fatalf("document = %+v", doc)
+	}
+	got := hotelFromDocument(doc)
+	if got.Name != hotel.Name || got.PricePerNight != hotel.PricePerNight || got.Rating != hotel.Rating || !got.Available || len(got.Amenities) != 2 {
+		t.Errorf("hotelFromDocument = %+v, want %+v", got, hotel)
+	}
+}
+
+// stubEmbeddings stands in for the embeddings API, recording the texts of
+// each request
+type stubEmbeddings struct {
+	mu      sync.Mutex
+	calls   [][]string
+	latency time.Duration
+	fail    string // requests with a text containing this fail
+}
+
+func (s *stubEmbeddings) predict(ctx context.Context, texts []string) ([][]float64, error) {
+	s.mu.Lock()
+	s.calls = append(s.calls, texts)
+	s.mu.Unlock()
+	time.Sleep(s.latency)
+
+	values := make([][]float64, len(texts))
+	for i, text := range texts {
+		if s.fail != "" && strings.Contains(text, s.fail) {
+			return nil, errors.New("embedding API unavailable")
+		}
+		values[i] = []float64{float64(len(text)), 1}
+	}
+	return values, nil
+}
+
+func (s *stubEmbeddings) texts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var texts []string
+	for _, call := range s.calls {
+		texts = append(texts, call...)
+	}
+	sort.Strings(texts)
+	return texts
+}
+
+func newTestVectorDatabase(t testing.TB, stub *stubEmbeddings) (*VectorDatabase, *fakeFirestore) {
+	fb, fake := newTestFirebase(t)
+	return &VectorDatabase{firestore: fb.firestore, indexes: newVectorIndexCache(), predictEmbeddings: stub.predict}, fake
+}
+
+func TestStoreEmbeddingsBatchSkipsEmbeddedDocuments(t *testing.T) {
+	stub := &stubEmbeddings{}
+	vdb, fake := newTestVectorDatabase(t, stub)
+	ctx := context.Background()
+
+	unchanged := EmbeddingDocument{ID: "unchanged", Type: "attraction", Content: "Amber Fort", Embedding: []float64{9, 9},
+		Metadata: map[string]interface{}{contentHashKey: vdb.contentHash("Amber Fort")}}
+	if err := vdb.StoreEmbeddingsBatch(ctx, []EmbeddingDocument{unchanged, {ID: "edited", Type: "attraction", Content: "Old text", Embedding: []float64{9, 9}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.calls) != 0 {
+		t.Fatalf("documents with embeddings were sent for embedding: %v", stub.calls)
+	}
+
+	docs := []EmbeddingDocument{
+		{ID: "given", Type: "attraction", Content: "Hawa Mahal", Embedding: []float64{3, 4}},
+		{ID: "unchanged", Type: "attraction", Content: "Amber Fort"},
+		{ID: "edited", Type: "attraction", Content: "City Palace"},
+		{ID: "new", Type: "attraction", Content: "Jal Mahal"},
+	}
+	if err := vdb.StoreEmbeddingsBatch(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(stub.texts(), ","); got != "City Palace,Jal Mahal" {
+		t.Errorf("embedded %s, want only the edited and new documents", got)
+	}
+	if len(docs[1].Embedding) != 0 {
+		t.Error("generated embeddings leaked into the caller's documents")
+	}
+
+	for _, tt := range []struct {
+		id   string
+		want float64
+	}{{"given", 3}, {"unchanged", 9}, {"edited", float64(len("City Palace"))}, {"new", float64(len("Jal Mahal"))}} {
+		fields := fake.fields("embeddings_attraction/" + tt.id)
+		embedding := fields["embedding"].GetArrayValue().GetValues()
+		if len(embedding) == 0 || embedding[0].GetDoubleValue() != tt.want {
+			t.Errorf("%s stored embedding %v, want one starting %v", tt.id, embedding, tt.want)
+		}
+		if fields["metadata"].GetMapValue().GetFields()[contentHashKey].GetStringValue() == "" {
+			t.Errorf("%s stored without a content hash", tt.id)
+		}
+	}
+}
+
+func TestStoreEmbeddingsBatchReportsFailuresByID(t *testing.T) {
+	stub := &stubEmbeddings{fail: "offline"}
+	vdb, fake := newTestVectorDatabase(t, stub)
+	fake.failWrite = func(w *pb.Write) error {
+		if strings.HasSuffix(writeName(w), "/rejected") {
+			return status.Error(codes.InvalidArgument, "document too large")
+		}
+		return nil
+	}
+
+	docs := []EmbeddingDocument{
+		{ID: "stored", Type: "hotel", Content: "Taj Lake Palace"},
+		{ID: "rejected", Type: "hotel", Content: "Oberoi Udaivilas"},
+		{Type: "hotel", Content: "No ID"},
+		{ID: "offline", Type: "hotel", Content: "offline guesthouse"},
+	}
+	err := vdb.StoreEmbeddingsBatch(context.Background(), docs)
+	var batchErr *BatchStoreError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("StoreEmbeddingsBatch = %v, want a *BatchStoreError", err)
+	}
+	failed := make([]string, 0, len(batchErr.Failed))
+	for id := range batchErr.Failed {
+		failed = append(failed, id)
+	}
+	sort.Strings(failed)
+	if got := strings.Join(failed, ","); got != "index_2,rejected" {
+		t.Errorf("failed IDs = %s, want index_2,rejected", got)
+	}
+
+	// The rest are stored; a failed embedding request falls back to a mock
+	// embedding without a hash, so the next store retries it
+	if fake.fields("embeddings_hotel/stored") == nil {
+		t.Error("a failure elsewhere in the batch stopped stored from being written")
+	}
+	offline := fake.fields("embeddings_hotel/offline")
+	if len(offline["embedding"].GetArrayValue().GetValues()) != 128 || offline["metadata"].GetMapValue().GetFields()[contentHashKey] != nil {
+		t.Errorf("offline stored %v, want a mock embedding without a hash", offline)
+	}
+}
+
+func TestReindexMissingEmbeddings(t *testing.T) {
+	stub := &stubEmbeddings{fail: "offline"}
+	vdb, fake := newTestVectorDatabase(t, stub)
+	fb := &FirebaseService{firestore: vdb.firestore}
+	embedded := func(id, content, hash string) {
+		seed(t, fb, "embeddings_hotel/"+id, EmbeddingDocument{
+			ID: id, Type: "hotel", Content: content, Embedding: []float64{9, 9},
+			Metadata: map[string]interface{}{contentHashKey: hash},
+		})
+	}
+	missing := func(id, content string) {
+		seed(t, fb, "embeddings_hotel/"+id, EmbeddingDocument{ID: id, Type: "hotel", Content: content})
+	}
+	embedded("current", "Taj Lake Palace", vdb.contentHash("Taj Lake Palace"))
+	embedded("edited", "Oberoi Udaivilas", vdb.contentHash("an older description"))
+	missing("missing", "Rambagh Palace")
+	missing("empty", "")
+	missing("offline", "offline guesthouse")
+
+	fixed, err := vdb.ReindexMissingEmbeddings(context.Background(), "hotel")
+	if err != nil || fixed != 2 {
+		t.Fatalf("ReindexMissingEmbeddings = %d, %v; want 2 fixed", fixed, err)
+	}
+	if got := strings.Join(stub.texts(), ","); got != "Oberoi Udaivilas,Rambagh Palace,offline guesthouse" {
+		t.Errorf("embedded %s, want the edited, missing and offline documents", got)
+	}
+
+	for _, tt := range []struct {
+		id   string
+		want float64 // first embedding value, 0 for none
+	}{{"current", 9}, {"edited", float64(len("Oberoi Udaivilas"))}, {"missing", float64(len("Rambagh Palace"))}, {"empty", 0}, {"offline", 0}} {
+		fields := fake.fields("embeddings_hotel/" + tt.id)
+		var got float64
+		if values := fields["embedding"].GetArrayValue().GetValues(); len(values) > 0 {
+			got = values[0].GetDoubleValue()
+		}
+		if got != tt.want {
+			t.Errorf("%s embedding starts %v, want %v", tt.id, got, tt.want)
+		}
+	}
+	if hash := fake.fields("embeddings_hotel/missing")["metadata"].GetMapValue().GetFields()[contentHashKey].GetStringValue(); hash != vdb.contentHash("Rambagh Palace") {
+		t.Errorf("reindexed document hash = %q, want the content's", hash)
+	}
+
+	// A second run finds nothing left but the document that keeps failing
+	if fixed, err := vdb.ReindexMissingEmbeddings(context.Background(), "hotel"); err != nil || fixed != 0 {
+		t.Errorf("second run = %d, %v; want nothing fixed", fixed, err)
+	}
+}
+
+// BenchmarkStoreEmbeddings compares StoreEmbeddingsBatch with storing the
+// same documents one StoreEmbedding at a time, against an embeddings API
+// taking 5ms a request
+func BenchmarkStoreEmbeddings(b *testing.B) {
+	const documents = 40
+	docs := func(run int) []EmbeddingDocument {
+		batch := make([]EmbeddingDocument, documents)
+		for i := range batch {
+			batch[i] = EmbeddingDocument{ID: fmt.Sprintf("doc-%d", i), Type: "attraction", Content: fmt.Sprintf("attraction %d, run %d", i, run)}
+		}
+		return batch
+	}
+
+	b.Run("batch", func(b *testing.B) {
+		vdb, _ := newTestVectorDatabase(b, &stubEmbeddings{latency: 5 * time.Millisecond})
+		for run := 0; run < b.N; run++ {
+			if err := vdb.StoreEmbeddingsBatch(context.Background(), docs(run)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("naive loop", func(b *testing.B) {
+		vdb, _ := newTestVectorDatabase(b, &stubEmbeddings{latency: 5 * time.Millisecond})
+		for run := 0; run < b.N; run++ {
+			for _, doc := range docs(run) {
+				if err := vdb.StoreEmbedding(context.Background(), doc); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	vdb := &VectorDatabase{}
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"known value", []float64{1, 2, 3}, []float64{4, 5, 6}, 32 / math.Sqrt(14*77)},
+		{"identical", []float64{0.3, -0.4}, []float64{0.3, -0.4}, 1},
+		{"scaled", []float64{1, 1}, []float64{5, 5}, 1},
+		{"opposite", []float64{1, 0}, []float64{-1, 0}, -1},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0},
+		{"zero vector", []float64{0, 0, 0}, []float64{1, 2, 3}, 0},
+		{"both zero", []float64{0, 0}, []float64{0, 0}, 0},
+		{"empty", nil, nil, 0},
+		{"mismatched dimensions", []float64{1, 2, 3}, []float64{1, 2}, 0},
+		{"overflow", []float64{math.MaxFloat64, math.MaxFloat64}, []float64{1, 1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vdb.cosineSimilarity(tt.a, tt.b); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("cosineSimilarity(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import "math"
+
+// MetadataFilter narrows SearchSimilar candidates on EmbeddingDocument.Metadata
+// before any similarity is computed. Zero-valued fields are ignored.
+type MetadataFilter struct {
+	MinPrice      float64   `json:"min_price,omitempty"`
+	MaxPrice      float64   `json:"max_price,omitempty"`
+	MaxPriceLevel int       `json:"max_price_level,omitempty"` // Places-style 1-4
+	MinRating     float64   `json:"min_rating,omitempty"`
+	AvailableOnly bool      `json:"available_only,omitempty"`
+	Near          *Location `json:"near,omitempty"`
+	RadiusKm      float64   `json:"radius_km,omitempty"`
+
+	// Predicate is an optional custom check applied after the structured fields
+	Predicate func(doc EmbeddingDocument) bool `json:"-"`
+}
+
+// Matches reports whether a document passes every configured condition. The
+// cheapest checks run first so failing documents short-circuit early.
+func (f *MetadataFilter) Matches(doc EmbeddingDocument) bool {
+	if f == nil {
+		return true
+	}
+	metadata := doc.Metadata
+
+	if f.AvailableOnly {
+		if _, ok := metadata["available"]; ok && !getBoolFromMetadata(metadata, "available") {
+			return false
+		}
+	}
+
+	if f.MinRating > 0 && getFloatFromMetadata(metadata, "rating") < f.MinRating {
+		return false
+	}
+
+	if f.MinPrice > 0 || f.MaxPrice > 0 {
+		price, ok := metadataPrice(metadata)
+		if !ok {
+			return false
+		}
+		if f.MinPrice > 0 && price < f.MinPrice {
+			return false
+		}
+		if f.MaxPrice > 0 && price > f.MaxPrice {
+			return false
+		}
+	}
+
+	if f.MaxPriceLevel > 0 {
+		if _, ok := metadata["price_level"]; !ok || getIntFromMetadata(metadata, "price_level") > f.MaxPriceLevel {
+			return false
+		}
+	}
+
+	if f.Near != nil && f.RadiusKm > 0 {
+		location, ok := metadataLocation(metadata)
+		if !ok || haversineKm(*f.Near, location) > f.RadiusKm {
+			return false
+		}
+	}
+
+	if f.Predicate != nil && !f.Predicate(doc) {
+		return false
+	}
+
+	return true
+}
+
+// filterDocuments returns the documents matching the filter
+func filterDocuments(docs []EmbeddingDocument, filter *MetadataFilter) []EmbeddingDocument {
+	if filter == nil {
+		return docs
+	}
+
+	filtered := make([]EmbeddingDocument, 0, len(docs))
+	for _, doc := range docs {
+		if filter.Matches(doc) {
+			filtered = append(filtered, doc)
+		}
+	}
+	return filtered
+}
+
+// metadataPrice reads the price of a document. The Places-style price_level is
+// a 0-4 scale, not an amount, so it is left to MaxPriceLevel.
+func metadataPrice(metadata map[string]interface{}) (float64, bool) {
+	for _, key := range []string{"price", "price_per_night"} {
+		if _, ok := metadata[key]; ok {
+			return getFloatFromMetadata(metadata, key), true
+		}
+	}
+	return 0, false
+}
+
+// metadataLocation reads the location of a document. Locations written as the
+// Location struct come back from Firestore with Go field names as keys.
+func metadataLocation(metadata map[string]interface{}) (Location, bool) {
+	switch loc := metadata["location"].(type) {
+	case Location:
+		return loc, true
+	case map[string]interface{}:
+		location := Location{
+			Latitude:  getFloatFromMetadata(loc, "latitude"),
+			Longitude: getFloatFromMetadata(loc, "longitude"),
+		}
+		if location.Latitude == 0 && location.Longitude == 0 {
+			location.Latitude = getFloatFromMetadata(loc, "Latitude")
+			location.Longitude = getFloatFromMetadata(loc, "Longitude")
+		}
+		if location.Latitude == 0 && location.Longitude == 0 {
+			return Location{}, false
+		}
+		return location, true
+	}
+	return Location{}, false
+}
+
+// haversineKm returns the great-circle distance between two points in kilometers
+func haversineKm(a, b Location) float64 {
+	const earthRadiusKm = 6371.0
+
+	lat1 := a.Latitude * math.Pi / 180
+	lat2 := b.Latitude * math.Pi / 180
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
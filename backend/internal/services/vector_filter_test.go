@@ -0,0 +1,47 @@
+package services
+
+import "testing"
+
+func TestMetadataFilterMatches(t *testing.T) {
+	delhi := Location{Latitude: 28.6139, Longitude: 77.2090}
+	doc := func(metadata map[string]interface{}) EmbeddingDocument {
+		return EmbeddingDocument{ID: "d1", Metadata: metadata}
+	}
+
+	tests := []struct {
+		name     string
+		filter   *MetadataFilter
+		metadata map[string]interface{}
+		want     bool
+	}{
+		{"nil filter", nil, nil, true},
+		{"price within range", &MetadataFilter{MinPrice: 50, MaxPrice: 100}, map[string]interface{}{"price": 80.0}, true},
+		{"price over max", &MetadataFilter{MaxPrice: 100}, map[string]interface{}{"price": 120.0}, false},
+		{"price under min", &MetadataFilter{MinPrice: 50}, map[string]interface{}{"price": 20}, false},
+		{"price per night", &MetadataFilter{MaxPrice: 100}, map[string]interface{}{"price_per_night": "90"}, true},
+		{"price preferred over price per night", &MetadataFilter{MaxPrice: 100}, map[string]interface{}{"price": 150.0, "price_per_night": 90.0}, false},
+		{"no price fails a price range", &MetadataFilter{MaxPrice: 100}, map[string]interface{}{}, false},
+		{"price level is not a price", &MetadataFilter{MaxPrice: 100}, map[string]interface{}{"price_level": 2}, false},
+		{"price level within max", &MetadataFilter{MaxPriceLevel: 2}, map[string]interface{}{"price_level": 2}, true},
+		{"free is within any max level", &MetadataFilter{MaxPriceLevel: 1}, map[string]interface{}{"price_level": int64(0)}, true},
+		{"price level over max", &MetadataFilter{MaxPriceLevel: 2}, map[string]interface{}{"price_level": int64(3)}, false},
+		{"no price level fails a max level", &MetadataFilter{MaxPriceLevel: 2}, map[string]interface{}{"price": 10.0}, false},
+		{"price and level both checked", &MetadataFilter{MaxPrice: 100, MaxPriceLevel: 2}, map[string]interface{}{"price": 40.0, "price_level": 4}, false},
+		{"rating at minimum", &MetadataFilter{MinRating: 4}, map[string]interface{}{"rating": 4.0}, true},
+		{"rating below minimum", &MetadataFilter{MinRating: 4}, map[string]interface{}{"rating": 3.9}, false},
+		{"unavailable", &MetadataFilter{AvailableOnly: true}, map[string]interface{}{"available": false}, false},
+		{"availability unknown", &MetadataFilter{AvailableOnly: true}, map[string]interface{}{}, true},
+		{"within radius", &MetadataFilter{Near: &delhi, RadiusKm: 5}, map[string]interface{}{"location": map[string]interface{}{"latitude": 28.62, "longitude": 77.21}}, true},
+		{"within radius, Go field names", &MetadataFilter{Near: &delhi, RadiusKm: 5}, map[string]interface{}{"location": map[string]interface{}{"Latitude": 28.62, "Longitude": 77.21}}, true},
+		{"outside radius", &MetadataFilter{Near: &delhi, RadiusKm: 5}, map[string]interface{}{"location": Location{Latitude: 27.1751, Longitude: 78.0421}}, false},
+		{"no location fails a radius", &MetadataFilter{Near: &delhi, RadiusKm: 5}, map[string]interface{}{}, false},
+		{"predicate rejects", &MetadataFilter{Predicate: func(doc EmbeddingDocument) bool { return doc.ID != "d1" }}, map[string]interface{}{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(doc(tt.metadata)); got != tt.want {
+				t.Errorf("Matches(%v) = %v, want %v", tt.metadata, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	// lshTables is the number of independent hash tables in the index
+	lshTables = 4
+	// lshPlanes is the number of random hyperplanes (hash bits) per table
+	lshPlanes = 10
+	// annMinDocuments is the collection size below which an exact scan is
+	// cheap enough that the approximate index is not worth using
+	annMinDocuments = 1000
+	// annIndexTTL controls how long an index is reused before being rebuilt
+	annIndexTTL = 10 * time.Minute
+	// annRebuildTimeout bounds loading a collection for a background rebuild
+	annRebuildTimeout = time.Minute
+)
+
+// SearchOptions tunes SearchSimilarWithOptions
+type SearchOptions struct {
+	// Exact forces a full scan of the collection instead of the approximate
+	// nearest-neighbor index. Use it when recall matters more than latency.
+	Exact bool
+
+	// Filter, when set, drops documents whose metadata doesn't match before
+	// similarity scoring
+	Filter *MetadataFilter
+}
+
+// vectorIndex is an in-memory locality-sensitive hashing index using random
+// hyperplanes. Vectors that hash to the same bucket in any table are treated
+// as nearest-neighbor candidates.
+type vectorIndex struct {
+	dimension int
+	planes    [lshTables][lshPlanes][]float64
+	buckets   [lshTables]map[uint64][]int
+	documents []EmbeddingDocument
+	builtAt   time.Time
+}
+
+// newVectorIndex builds an index over documents whose embedding has the given
+// dimension. Documents with a different dimension are left out.
+func newVectorIndex(dimension int, documents []EmbeddingDocument) *vectorIndex {
+	idx := &vectorIndex{
+		dimension: dimension,
+		builtAt:   time.Now(),
+	}
+
+	// Deterministic planes keep results stable across rebuilds
+	rng := rand.New(rand.NewSource(int64(dimension)))
+	for t := 0; t < lshTables; t++ {
+		for p := 0; p < lshPlanes; p++ {
+			plane := make([]float64, dimension)
+			for i := range plane {
+				plane[i] = rng.NormFloat64()
+			}
+			idx.planes[t][p] = plane
+		}
+		idx.buckets[t] = make(map[uint64][]int)
+	}
+
+	for _, doc := range documents {
+		if len(doc.Embedding) != dimension {
+			continue
+		}
+		pos := len(idx.documents)
+		idx.documents = append(idx.documents, doc)
+		for t := 0; t < lshTables; t++ {
+			key := idx.hash(t, doc.Embedding)
+			idx.buckets[t][key] = append(idx.buckets[t][key], pos)
+		}
+	}
+
+	return idx
+}
+
+// hash computes the bucket key of a vector in table t
+func (idx *vectorIndex) hash(t int, vector []float64) uint64 {
+	var key uint64
+	for p, plane := range idx.planes[t] {
+		var dot float64
+		for i, v := range vector {
+			dot += v * plane[i]
+		}
+		if dot >= 0 {
+			key |= 1 << uint(p)
+		}
+	}
+	return key
+}
+
+// candidates returns the documents sharing a bucket with the query in any
+// table, plus buckets one bit away so near-boundary neighbors aren't missed
+func (idx *vectorIndex) candidates(query []float64) []EmbeddingDocument {
+	seen := make(map[int]bool)
+	var result []EmbeddingDocument
+
+	for t := 0; t < lshTables; t++ {
+		key := idx.hash(t, query)
+		probes := []uint64{key}
+		for p := 0; p < lshPlanes; p++ {
+			probes = append(probes, key^(1<<uint(p)))
+		}
+
+		for _, probe := range probes {
+			for _, pos := range idx.buckets[t][probe] {
+				if seen[pos] {
+					continue
+				}
+				seen[pos] = true
+				result = append(result, idx.documents[pos])
+			}
+		}
+	}
+
+	return result
+}
+
+// expired reports whether the index should be rebuilt
+func (idx *vectorIndex) expired() bool {
+	return time.Since(idx.builtAt) > annIndexTTL
+}
+
+// vectorIndexCache holds one index per document type. Indexes are built in
+// the background: an expired or invalidated index keeps serving queries
+// until its replacement is ready, so no query waits for a build.
+type vectorIndexCache struct {
+	mu       sync.Mutex
+	indexes  map[string]*vectorIndex
+	stale    map[string]bool
+	building map[string]bool
+	// generation counts invalidations, so a build that loaded documents
+	// before a write doesn't mark its index fresh
+	generation map[string]int
+	builds     sync.WaitGroup
+}
+
+func newVectorIndexCache() *vectorIndexCache {
+	return &vectorIndexCache{
+		indexes:    make(map[string]*vectorIndex),
+		stale:      make(map[string]bool),
+		building:   make(map[string]bool),
+		generation: make(map[string]int),
+	}
+}
+
+// get returns the index for docType with the given dimension, or nil, and
+// whether it is fresh. A stale index is still usable while it's rebuilt.
+func (c *vectorIndexCache) get(docType string, dimension int) (*vectorIndex, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, ok := c.indexes[docType]
+	if !ok || idx.dimension != dimension {
+		return nil, false
+	}
+	return idx, !idx.expired() && !c.stale[docType]
+}
+
+// rebuild builds a new index for docType in the background from the
+// documents load returns, unless a build is already running. Collections
+// that have shrunk below annMinDocuments drop their index.
+func (c *vectorIndexCache) rebuild(docType string, dimension int, load func(ctx context.Context) ([]EmbeddingDocument, error)) {
+	c.mu.Lock()
+	if c.building[docType] {
+		c.mu.Unlock()
+		return
+	}
+	c.building[docType] = true
+	generation := c.generation[docType]
+	c.builds.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.builds.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), annRebuildTimeout)
+		defer cancel()
+
+		docs, err := load(ctx)
+		var idx *vectorIndex
+		if err == nil && len(docs) >= annMinDocuments {
+			idx = newVectorIndex(dimension, docs)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.building, docType)
+		if err != nil {
+			log.Printf("Failed to rebuild ANN index for %s, serving the previous one: %v", docType, err)
+			return
+		}
+		if idx == nil {
+			delete(c.indexes, docType)
+			return
+		}
+		c.indexes[docType] = idx
+		c.stale[docType] = c.generation[docType] != generation
+		log.Printf("Built ANN index for %s with %d documents", docType, len(idx.documents))
+	}()
+}
+
+// invalidate marks the index for docType stale so the next query rebuilds it
+func (c *vectorIndexCache) invalidate(docType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stale[docType] = true
+	c.generation[docType]++
+}
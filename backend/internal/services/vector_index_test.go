@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// clusteredDocuments generates n documents around a few hundred centers, the
+// way embeddings of similar places cluster
+func clusteredDocuments(n, dimension int, rng *rand.Rand) []EmbeddingDocument {
+	centers := make([][]float64, 500)
+	for i := range centers {
+		centers[i] = make([]float64, dimension)
+		for d := range centers[i] {
+			centers[i][d] = rng.NormFloat64()
+		}
+	}
+	docs := make([]EmbeddingDocument, n)
+	for i := range docs {
+		center := centers[rng.Intn(len(centers))]
+		embedding := make([]float64, dimension)
+		for d := range embedding {
+			embedding[d] = center[d] + 0.3*rng.NormFloat64()
+		}
+		docs[i] = EmbeddingDocument{ID: fmt.Sprintf("doc-%d", i), Embedding: embedding}
+	}
+	return docs
+}
+
+func TestVectorIndexCacheRebuildsInBackground(t *testing.T) {
+	docs := clusteredDocuments(annMinDocuments, 8, rand.New(rand.NewSource(1)))
+	c := newVectorIndexCache()
+	loads := 0
+	load := func(context.Context) ([]EmbeddingDocument, error) {
+		loads++
+		return docs, nil
+	}
+
+	if idx, _ := c.get("hotel", 8); idx != nil {
+		t.Fatal("index before any build")
+	}
+	c.rebuild("hotel", 8, load)
+	c.builds.Wait()
+	first, fresh := c.get("hotel", 8)
+	if first == nil || !fresh || len(first.documents) != len(docs) {
+		t.Fatalf("after a build get = %v, %v; want a fresh index of %d documents", first, fresh, len(docs))
+	}
+	if idx, _ := c.get("hotel", 16); idx != nil {
+		t.Error("index served for another dimension")
+	}
+
+	// A write marks the index stale, but it keeps serving while a slow
+	// rebuild runs, and a second rebuild doesn't start
+	c.invalidate("hotel")
+	release := make(chan struct{})
+	c.rebuild("hotel", 8, func(ctx context.Context) ([]EmbeddingDocument, error) {
+		<-release
+		return load(ctx)
+	})
+	c.rebuild("hotel", 8, load)
+	if idx, fresh := c.get("hotel", 8); idx != first || fresh {
+		t.Errorf("during a rebuild get = %p, %v; want the stale index %p", idx, fresh, first)
+	}
+	// A write while rebuilding leaves the new index stale too
+	c.invalidate("hotel")
+	close(release)
+	c.builds.Wait()
+	second, fresh := c.get("hotel", 8)
+	if second == first || fresh || loads != 2 {
+		t.Errorf("after the rebuild got the old index %v, fresh %v, %d loads; want a new stale index from 2 loads", second == first, fresh, loads)
+	}
+
+	// A failed rebuild keeps serving the previous index
+	c.rebuild("hotel", 8, func(context.Context) ([]EmbeddingDocument, error) {
+		return nil, errors.New("firestore unavailable")
+	})
+	c.builds.Wait()
+	if idx, _ := c.get("hotel", 8); idx != second {
+		t.Error("failed rebuild dropped the index")
+	}
+
+	// A collection that shrank below annMinDocuments goes back to scans
+	c.rebuild("hotel", 8, func(context.Context) ([]EmbeddingDocument, error) {
+		return docs[:10], nil
+	})
+	c.builds.Wait()
+	if idx, _ := c.get("hotel", 8); idx != nil {
+		t.Error("index kept for a small collection")
+	}
+}
+
+var (
+	annBenchmarkOnce    sync.Once
+	annBenchmarkDocs    []EmbeddingDocument
+	annBenchmarkQueries [][]float64
+	annBenchmarkIndex   *vectorIndex
+)
+
+// annBenchmarkSet builds 50k 128-dimensional documents, their index, and
+// queries near documents of the set
+func annBenchmarkSet() ([]EmbeddingDocument, [][]float64, *vectorIndex) {
+	annBenchmarkOnce.Do(func() {
+		rng := rand.New(rand.NewSource(42))
+		annBenchmarkDocs = clusteredDocuments(50000, 128, rng)
+		for i := 0; i < 100; i++ {
+			doc := annBenchmarkDocs[rng.Intn(len(annBenchmarkDocs))]
+			query := make([]float64, len(doc.Embedding))
+			for d, v := range doc.Embedding {
+				query[d] = v + 0.1*rng.NormFloat64()
+			}
+			annBenchmarkQueries = append(annBenchmarkQueries, query)
+		}
+		annBenchmarkIndex = newVectorIndex(128, annBenchmarkDocs)
+	})
+	return annBenchmarkDocs, annBenchmarkQueries, annBenchmarkIndex
+}
+
+// topIDs ranks docs against query and returns the IDs of the best k
+func topIDs(vdb *VectorDatabase, query []float64, docs []EmbeddingDocument, k int) []string {
+	results := vdb.rankBySimilarity(query, docs, "bench")
+	if len(results) > k {
+		results = results[:k]
+	}
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.Document.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// BenchmarkVectorSearch compares an exact scan of 50k documents with the
+// LSH index, reporting the index's recall of the exact top 10
+func BenchmarkVectorSearch(b *testing.B) {
+	docs, queries, idx := annBenchmarkSet()
+	vdb := &VectorDatabase{}
+	const k = 10
+
+	b.Run("exact", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			topIDs(vdb, queries[i%len(queries)], docs, k)
+		}
+	})
+	b.Run("lsh", func(b *testing.B) {
+		candidates := 0
+		for i := 0; i < b.N; i++ {
+			c := idx.candidates(queries[i%len(queries)])
+			candidates += len(c)
+			topIDs(vdb, queries[i%len(queries)], c, k)
+		}
+		b.ReportMetric(float64(candidates)/float64(b.N), "candidates/op")
+
+		b.StopTimer()
+		found := 0
+		for _, query := range queries {
+			exact := make(map[string]bool)
+			for _, id := range topIDs(vdb, query, docs, k) {
+				exact[id] = true
+			}
+			for _, id := range topIDs(vdb, query, idx.candidates(query), k) {
+				if exact[id] {
+					found++
+				}
+			}
+		}
+		b.ReportMetric(float64(found)/float64(k*len(queries)), "recall@10")
+	})
+}
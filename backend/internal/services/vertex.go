@@ -2,35 +2,67 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"strings"
+	"time"
 
+	"auratravel-backend/internal/cache"
 	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/metrics"
+	"auratravel-backend/internal/tracing"
 
 	aiplatform "cloud.google.com/go/aiplatform/apiv1"
 	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
 	"google.golang.org/api/option"
 )
 
-// VertexService handles Vertex AI interactions
+// VertexService handles Vertex AI interactions. It implements AIGenerator
+// with Gemini models served from Vertex AI, and falls back to the same mock
+// responses as GeminiService when no project is configured.
 type VertexService struct {
 	client    *aiplatform.PredictionClient
 	projectID string
 	location  string
+	model     string
 	cfg       *config.Config
+
+	// prompts builds prompts, parses responses and provides the mock
+	// fallbacks shared with GeminiService; it never calls the Gemini API
+	prompts *GeminiService
 }
 
 // NewVertexService creates a new Vertex AI service
-func NewVertexService() (*VertexService, error) {
+func NewVertexService(resultCache cache.Cache) (*VertexService, error) {
 	cfg := config.GetConfig()
 
-	if cfg.GoogleCloudProjectID == "" {
-		return nil, fmt.Errorf("GOOGLE_CLOUD_PROJECT_ID is required")
+	vertex := &VertexService{
+		projectID: cfg.VertexAIProjectID,
+		location:  cfg.VertexAILocation,
+		model:     cfg.VertexAIModel,
+		cfg:       cfg,
+		prompts:   &GeminiService{cfg: cfg, cache: resultCache},
+	}
+	if vertex.projectID == "" {
+		vertex.projectID = cfg.GoogleCloudProjectID
+	}
+	if vertex.location == "" {
+		vertex.location = cfg.GoogleCloudRegion
+	}
+
+	if vertex.projectID == "" {
+		slog.Warn("VERTEX_AI_PROJECT_ID not set, using mock Vertex AI service")
+		return vertex, nil
 	}
 
 	ctx := context.Background()
 
-	var opts []option.ClientOption
+	opts := []option.ClientOption{
+		option.WithEndpoint(fmt.Sprintf("%s-aiplatform.googleapis.com:443", vertex.location)),
+	}
 	if cfg.GoogleApplicationCredentials != "" {
 		opts = append(opts, option.WithCredentialsFile(cfg.GoogleApplicationCredentials))
 	}
@@ -39,13 +71,183 @@ func NewVertexService() (*VertexService, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Vertex AI client: %v", err)
 	}
+	vertex.client = client
 
-	return &VertexService{
-		client:    client,
-		projectID: cfg.GoogleCloudProjectID,
-		location:  cfg.GoogleCloudRegion,
-		cfg:       cfg,
-	}, nil
+	return vertex, nil
+}
+
+var _ AIGenerator = (*VertexService)(nil)
+
+// HasCredentials reports whether the service calls Vertex AI rather than
+// returning mock responses
+func (v *VertexService) HasCredentials() bool {
+	return v.client != nil
+}
+
+// GenerateItinerary creates an itinerary with a Vertex AI Gemini model
+func (v *VertexService) GenerateItinerary(ctx context.Context, req ItineraryRequest) (map[string]interface{}, error) {
+	if v.client == nil {
+		return v.prompts.mockItinerary(req), nil
+	}
+
+	response, err := v.GenerateText(ctx, v.prompts.buildItineraryPrompt(req))
+	if err != nil {
+		logging.FromContext(ctx).Warn("Vertex AI call failed, falling back to mock", "error", err, "destination", req.Destination)
+		return v.prompts.mockItinerary(req), nil
+	}
+	return v.prompts.parseItineraryResponse(ctx, response, req), nil
+}
+
+// GenerateItineraryWithRAG creates an itinerary grounded in retrieved context
+func (v *VertexService) GenerateItineraryWithRAG(ctx context.Context, req ItineraryRequest, ragContext TripContext) (map[string]interface{}, error) {
+	if v.client == nil {
+		return v.prompts.mockItineraryWithRAG(req, ragContext), nil
+	}
+
+	response, err := v.GenerateText(ctx, v.prompts.buildRAGItineraryPrompt(req, ragContext))
+	if err != nil {
+		logging.FromContext(ctx).Warn("Vertex AI call failed, falling back to mock", "error", err, "destination", req.Destination)
+		return v.prompts.mockItineraryWithRAG(req, ragContext), nil
+	}
+	return v.prompts.parseRAGItineraryResponse(ctx, response, req, ragContext), nil
+}
+
+// GetDestinationRecommendations gets destination recommendations from
+// Vertex AI
+func (v *VertexService) GetDestinationRecommendations(ctx context.Context, req RecommendationRequest) ([]map[string]interface{}, error) {
+	if v.client == nil {
+		return v.prompts.mockRecommendations(req), nil
+	}
+
+	cacheKey := cache.HashKey("vertex_recommendations", req)
+	var recommendations []map[string]interface{}
+	if cache.GetJSON(ctx, v.prompts.cache, cacheKey, &recommendations) {
+		return recommendations, nil
+	}
+
+	response, err := v.GenerateText(ctx, v.prompts.buildRecommendationPrompt(req))
+	if err != nil {
+		logging.FromContext(ctx).Warn("Vertex AI call failed, falling back to mock", "error", err)
+		return v.prompts.mockRecommendations(req), nil
+	}
+
+	recommendations = v.prompts.parseRecommendationsResponse(ctx, response, req)
+	for _, recommendation := range recommendations {
+		recommendation["source"] = "vertex_ai"
+	}
+	cache.SetJSON(ctx, v.prompts.cache, cacheKey, recommendations, recommendationsCacheTTL)
+	return recommendations, nil
+}
+
+// GetActivitySuggestions gets activity suggestions from Vertex AI
+func (v *VertexService) GetActivitySuggestions(ctx context.Context, destination string, interests []string) ([]string, error) {
+	if v.client == nil {
+		return v.prompts.mockActivitySuggestions(destination, interests), nil
+	}
+
+	response, err := v.GenerateText(ctx, v.prompts.buildActivityPrompt(destination, interests))
+	if err != nil {
+		logging.FromContext(ctx).Warn("Vertex AI call failed, falling back to mock", "error", err, "destination", destination)
+		return v.prompts.mockActivitySuggestions(destination, interests), nil
+	}
+	return v.prompts.parseActivitiesResponse(response), nil
+}
+
+// GenerateText returns the model's response to a free-form prompt
+func (v *VertexService) GenerateText(ctx context.Context, prompt string) (text string, err error) {
+	if v.client == nil {
+		return "", ErrAIUnavailable
+	}
+
+	ctx, span := tracing.Start(ctx, "vertex.generateContent", tracing.KindClient,
+		tracing.Attr("peer.service", "vertex_ai"),
+		tracing.Attr("gen_ai.request.model", v.model),
+	)
+	start := time.Now()
+	defer func() {
+		span.EndWithError(err)
+		metrics.GeminiCallsTotal.WithLabelValues(v.model, metrics.Outcome(err)).Inc()
+		metrics.ObserveExternalCall("vertex_ai", start, err)
+	}()
+
+	resp, err := v.client.GenerateContent(ctx, v.generateContentRequest(prompt))
+	if err != nil {
+		return "", fmt.Errorf("vertex AI request failed: %w", err)
+	}
+
+	var out strings.Builder
+	if len(resp.GetCandidates()) > 0 {
+		for _, part := range resp.GetCandidates()[0].GetContent().GetParts() {
+			out.WriteString(part.GetText())
+		}
+	}
+	if out.Len() == 0 {
+		return "", fmt.Errorf("no content in Vertex AI response")
+	}
+	return out.String(), nil
+}
+
+// modelName is the full resource name of the configured publisher model
+func (v *VertexService) modelName() string {
+	return fmt.Sprintf("projects/%s/locations/%s/publishers/google/models/%s", v.projectID, v.location, v.model)
+}
+
+func (v *VertexService) generateContentRequest(prompt string) *aiplatformpb.GenerateContentRequest {
+	return &aiplatformpb.GenerateContentRequest{
+		Model: v.modelName(),
+		Contents: []*aiplatformpb.Content{{
+			Role:  "user",
+			Parts: []*aiplatformpb.Part{{Data: &aiplatformpb.Part_Text{Text: prompt}}},
+		}},
+	}
+}
+
+// SuggestItineraryImprovements asks the model for practical changes to a
+// trip's days beyond route ordering, honoring the traveler's preferences and
+// constraints
+func (v *VertexService) SuggestItineraryImprovements(ctx context.Context, destination string, days interface{}, preferences, constraints map[string]interface{}) ([]string, error) {
+	if v.client == nil {
+		return mockItineraryImprovements(constraints), nil
+	}
+
+	daysJSON, err := json.Marshal(days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode itinerary: %w", err)
+	}
+	preferencesJSON, _ := json.Marshal(preferences)
+	constraintsJSON, _ := json.Marshal(constraints)
+
+	prompt := fmt.Sprintf(`Suggest up to 5 practical improvements to this %s itinerary, such as pacing,
+opening hours, meal timing or cost savings. Don't suggest reordering stops; routes are already optimized.
+Traveler preferences: %s
+Constraints: %s
+Itinerary: %s
+Respond with only a JSON array of short strings.`, userInput("destination", destination),
+		userInput("preferences", string(preferencesJSON)), userInput("constraints", string(constraintsJSON)), daysJSON) + userInputNotice
+
+	response, err := v.GenerateText(ctx, prompt)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Vertex AI call failed, falling back to mock", "error", err)
+		return mockItineraryImprovements(constraints), nil
+	}
+
+	var suggestions []string
+	if err := json.Unmarshal([]byte(extractJSON(response)), &suggestions); err != nil {
+		logging.FromContext(ctx).Warn("Failed to parse Vertex AI suggestions", "error", err)
+		return mockItineraryImprovements(constraints), nil
+	}
+	return suggestions, nil
+}
+
+func mockItineraryImprovements(constraints map[string]interface{}) []string {
+	suggestions := []string{
+		"Check opening hours and book timed-entry tickets for popular sights in advance",
+		"Leave a free hour each afternoon as a buffer for delays and rest",
+	}
+	if _, ok := constraints["budget"]; ok {
+		suggestions = append(suggestions, "Swap one paid attraction per day for a free walking route to stay within budget")
+	}
+	return suggestions
 }
 
 // TravelPreferences represents user travel preferences for ML
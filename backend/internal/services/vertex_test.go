@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	aiplatform "cloud.google.com/go/aiplatform/apiv1"
+	"cloud.google.com/go/aiplatform/apiv1/aiplatformpb"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakePrediction serves GenerateContent with a canned reply
+type fakePrediction struct {
+	aiplatformpb.UnimplementedPredictionServiceServer
+
+	reply  string // no candidates when empty
+	fail   bool
+	models []string
+}
+
+func (f *fakePrediction) GenerateContent(ctx context.Context, req *aiplatformpb.GenerateContentRequest) (*aiplatformpb.GenerateContentResponse, error) {
+	f.models = append(f.models, req.GetModel())
+	if f.fail {
+		return nil, status.Error(codes.InvalidArgument, "bad request")
+	}
+	if f.reply == "" {
+		return &aiplatformpb.GenerateContentResponse{}, nil
+	}
+	// Split the reply across parts so joining them is exercised
+	half := len(f.reply) / 2
+	return &aiplatformpb.GenerateContentResponse{Candidates: []*aiplatformpb.Candidate{{
+		Content: &aiplatformpb.Content{Parts: []*aiplatformpb.Part{
+			{Data: &aiplatformpb.Part_Text{Text: f.reply[:half]}},
+			{Data: &aiplatformpb.Part_Text{Text: f.reply[half:]}},
+		}},
+	}}}, nil
+}
+
+// newTestVertex returns a VertexService whose client talks to server over
+// a local gRPC connection
+func newTestVertex(t *testing.T, server *fakePrediction) *VertexService {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := grpc.NewServer()
+	aiplatformpb.RegisterPredictionServiceServer(grpcServer, server)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	client, err := aiplatform.NewPredictionClient(context.Background(),
+		option.WithEndpoint(lis.Addr().String()),
+		option.WithoutAuthentication(),
+		option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return &VertexService{client: client, projectID: "proj", location: "asia-south1", model: "gemini-1.5-flash", prompts: &GeminiService{}}
+}
+
+func TestVertexGenerateText(t *testing.T) {
+	tests := []struct {
+		name       string
+		server     *fakePrediction
+		want       string
+		wantErr    bool
+		wantNoAuth bool
+	}{
+		{name: "reply parts are joined", server: &fakePrediction{reply: "Visit the Amber Fort"}, want: "Visit the Amber Fort"},
+		{name: "request error", server: &fakePrediction{fail: true}, wantErr: true},
+		{name: "no candidates", server: &fakePrediction{}, wantErr: true},
+		{name: "not configured", wantErr: true, wantNoAuth: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vertex := &VertexService{}
+			if tt.server != nil {
+				vertex = newTestVertex(t, tt.server)
+			}
+			got, err := vertex.GenerateText(context.Background(), "Plan a day in Jaipur")
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("GenerateText = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+			if errors.Is(err, ErrAIUnavailable) != tt.wantNoAuth {
+				t.Errorf("GenerateText error = %v, want ErrAIUnavailable %v", err, tt.wantNoAuth)
+			}
+			if tt.server != nil && fmt.Sprint(tt.server.models) != "[projects/proj/locations/asia-south1/publishers/google/models/gemini-1.5-flash]" {
+				t.Errorf("requested models %v", tt.server.models)
+			}
+		})
+	}
+}
+
+func TestVertexSuggestItineraryImprovements(t *testing.T) {
+	budget := map[string]interface{}{"budget": 500}
+	mock := strings.Join(mockItineraryImprovements(budget), "|")
+
+	tests := []struct {
+		name   string
+		server *fakePrediction
+		want   string
+	}{
+		{"model suggestions", &fakePrediction{reply: "```json\n[\"Lunch before the museum\", \"Take the metro\"]\n```"}, "Lunch before the museum|Take the metro"},
+		{"unparseable reply", &fakePrediction{reply: "Try lunch earlier."}, mock},
+		{"request error", &fakePrediction{fail: true}, mock},
+		{"not configured", nil, mock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vertex := &VertexService{}
+			if tt.server != nil {
+				vertex = newTestVertex(t, tt.server)
+			}
+			suggestions, err := vertex.SuggestItineraryImprovements(context.Background(), "Jaipur", []string{"day 1"}, nil, budget)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(suggestions, "|"); got != tt.want {
+				t.Errorf("suggestions = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVertexActivitySuggestionsFallBackToMock(t *testing.T) {
+	interests := []string{"adventure"}
+	mock := strings.Join((&GeminiService{}).mockActivitySuggestions("Goa", interests), "|")
+
+	tests := []struct {
+		name   string
+		server *fakePrediction
+		want   string
+	}{
+		{"model reply", &fakePrediction{reply: "- Surfing lesson\n- Spice farm tour"}, "Surfing lesson|Spice farm tour"},
+		{"request error", &fakePrediction{fail: true}, mock},
+		{"not configured", nil, mock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vertex := &VertexService{prompts: &GeminiService{}}
+			if tt.server != nil {
+				vertex = newTestVertex(t, tt.server)
+			}
+			if vertex.HasCredentials() != (tt.server != nil) {
+				t.Errorf("HasCredentials = %v with server %v", vertex.HasCredentials(), tt.server != nil)
+			}
+			activities, err := vertex.GetActivitySuggestions(context.Background(), "Goa", interests)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(activities, "|"); got != tt.want {
+				t.Errorf("activities = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
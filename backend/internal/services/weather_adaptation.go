@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Adverse weather thresholds for outdoor plans
+const (
+	adverseHeatCelsius = 38.0
+	adverseColdCelsius = -5.0
+	adverseWindKmh     = 50.0
+)
+
+// adverseConditions are forecast descriptions that rule out outdoor plans
+var adverseConditions = []string{"rain", "drizzle", "shower", "storm", "thunder", "snow", "sleet", "hail", "cyclone", "typhoon"}
+
+// Weather adaptation errors
+var (
+	ErrNoForecast  = newKindError(ErrNotFound, "no forecast for that day")
+	ErrDayNotFound = newKindError(ErrNotFound, "day not found in itinerary")
+)
+
+// ForecastProvider returns daily weather forecasts for a destination
+type ForecastProvider interface {
+	GetWeatherForecast(ctx context.Context, destination string, startDate, endDate time.Time) (WeatherForecast, error)
+}
+
+// WeatherAdaptation is a proposed revision of one day of a trip for its
+// forecast. It isn't saved until ApplyWeatherAdaptation is called.
+type WeatherAdaptation struct {
+	TripID      string                 `json:"trip_id"`
+	Day         int                    `json:"day"`
+	Date        time.Time              `json:"date"`
+	Forecast    WeatherCondition       `json:"forecast"`
+	Adverse     bool                   `json:"adverse"`
+	Changed     bool                   `json:"changed"`
+	OriginalDay map[string]interface{} `json:"original_day"`
+	RevisedDay  map[string]interface{} `json:"revised_day"`
+	Changes     []ItineraryChange      `json:"changes"`
+	Notes       []string               `json:"notes,omitempty"`
+}
+
+// AdaptDayForWeather checks the forecast for one day of a trip and, when
+// it's adverse, proposes the day with its outdoor activities swapped for
+// indoor alternatives. It works on demand, whether or not the trip is being
+// monitored. When every activity is already indoors, or no alternative is
+// found, the day is returned unchanged with a note saying why.
+func (d *DynamicReplanningService) AdaptDayForWeather(ctx context.Context, trip *TripData, day int) (*WeatherAdaptation, error) {
+	days, err := planDays(trip.Itinerary)
+	if err != nil {
+		return nil, fmt.Errorf("invalid itinerary: %w", err)
+	}
+	dayPlan, ok := days[day]
+	if !ok {
+		return nil, ErrDayNotFound
+	}
+
+	startDate := timeFromValue(trip.StartDate)
+	if startDate.IsZero() {
+		return nil, ErrNoForecast
+	}
+	date := startDate.AddDate(0, 0, day-1)
+
+	if d.forecasts == nil {
+		return nil, newKindError(ErrUnavailable, "weather forecasts not available")
+	}
+	forecast, err := d.forecasts.GetWeatherForecast(ctx, trip.Destination, date, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get forecast: %v", err)
+	}
+	condition, ok := forecastForDate(forecast, date)
+	if !ok {
+		return nil, ErrNoForecast
+	}
+
+	// planDays decodes a fresh copy on every call, so the revision can't
+	// touch the trip's own itinerary
+	revisedDays, _ := planDays(trip.Itinerary)
+	adaptation := &WeatherAdaptation{
+		TripID:      trip.ID,
+		Day:         day,
+		Date:        date,
+		Forecast:    condition,
+		OriginalDay: dayPlan,
+		RevisedDay:  revisedDays[day],
+		Changes:     []ItineraryChange{},
+	}
+
+	reason, adverse := adverseWeather(condition)
+	adaptation.Adverse = adverse
+	if !adverse {
+		adaptation.Notes = append(adaptation.Notes, fmt.Sprintf("The forecast for day %d (%s) doesn't call for changes", day, condition.Description))
+		return adaptation, nil
+	}
+
+	outdoor := 0
+	for _, slot := range planSlots {
+		activities := planActivities(adaptation.RevisedDay[slot])
+		if len(activities) == 0 {
+			continue
+		}
+
+		slotChanged := false
+		for i, activity := range activities {
+			if !d.isOutdoorActivity(activity) {
+				continue
+			}
+			outdoor++
+
+			alternative, ok := d.findIndoorAlternative(ctx, activity, trip.Destination).(map[string]interface{})
+			if !ok || alternative == nil {
+				adaptation.Notes = append(adaptation.Notes, fmt.Sprintf("No indoor alternative found for %s; it's kept as planned", activityName(activity)))
+				continue
+			}
+			replacement := make(map[string]interface{}, len(alternative)+1)
+			for key, value := range alternative {
+				replacement[key] = value
+			}
+			replacement["replaces"] = activityName(activity)
+
+			adaptation.Changes = append(adaptation.Changes, ItineraryChange{
+				Type:        "replacement",
+				Day:         fmt.Sprintf("day_%d", day),
+				TimeSlot:    slot,
+				Original:    activity,
+				Replacement: replacement,
+				Reason:      fmt.Sprintf("Weather: %s", reason),
+				Impact:      "moderate",
+				CostDelta:   floatValue(replacement, "cost") - floatValue(activity, "cost", "estimated_cost", "price"),
+			})
+			activities[i] = replacement
+			slotChanged = true
+		}
+
+		if slotChanged {
+			adaptation.RevisedDay[slot] = slotValue(adaptation.RevisedDay[slot], activities)
+			adaptation.Changed = true
+		}
+	}
+
+	switch {
+	case outdoor == 0:
+		adaptation.Notes = append(adaptation.Notes, fmt.Sprintf("Day %d is already all indoors, so %s won't affect it", day, reason))
+	case !adaptation.Changed:
+		adaptation.Notes = append(adaptation.Notes, fmt.Sprintf("No indoor alternatives were found for day %d; it's unchanged despite %s", day, reason))
+	}
+	return adaptation, nil
+}
+
+// ApplyWeatherAdaptation saves a revised day into the trip's itinerary,
+// replacing the day with the same number. The trip must not have changed
+// since it was read, or *TripVersionConflictError is returned.
+func (d *DynamicReplanningService) ApplyWeatherAdaptation(ctx context.Context, trip *TripData, day int, revisedDay map[string]interface{}) error {
+	if err := SetItineraryDay(trip.Itinerary, day, revisedDay); err != nil {
+		return err
+	}
+	_, err := d.firebase.UpdateTripAtVersion(ctx, trip.ID, trip.Version, map[string]interface{}{"itinerary": trip.Itinerary})
+	return err
+}
+
+// adverseWeather reports whether a forecast rules out outdoor plans, and why
+func adverseWeather(condition WeatherCondition) (string, bool) {
+	description := strings.ToLower(condition.Description)
+	for _, adverse := range adverseConditions {
+		if strings.Contains(description, adverse) {
+			return strings.ToLower(condition.Description), true
+		}
+	}
+	switch {
+	case condition.Temperature >= adverseHeatCelsius:
+		return fmt.Sprintf("extreme heat (%.0f°C)", condition.Temperature), true
+	case condition.Temperature <= adverseColdCelsius:
+		return fmt.Sprintf("extreme cold (%.0f°C)", condition.Temperature), true
+	case condition.WindSpeed >= adverseWindKmh:
+		return fmt.Sprintf("strong winds (%.0f km/h)", condition.WindSpeed), true
+	}
+	return "", false
+}
+
+// forecastForDate returns the forecast for date's calendar day
+func forecastForDate(forecast WeatherForecast, date time.Time) (WeatherCondition, bool) {
+	day := date.Format("2006-01-02")
+	for _, condition := range forecast.Forecast {
+		if condition.Date.Format("2006-01-02") == day {
+			return condition, true
+		}
+	}
+	return WeatherCondition{}, false
+}
+
+// slotValue stores activities back in the shape the slot had: a single
+// object stays an object, anything else becomes a list
+func slotValue(original interface{}, activities []map[string]interface{}) interface{} {
+	if _, ok := original.(map[string]interface{}); ok && len(activities) == 1 {
+		return activities[0]
+	}
+	list := make([]interface{}, len(activities))
+	for i, activity := range activities {
+		list[i] = activity
+	}
+	return list
+}
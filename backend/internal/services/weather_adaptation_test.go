@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubForecasts returns the listed conditions, keyed by date
+type stubForecasts map[string]WeatherCondition
+
+func (s stubForecasts) GetWeatherForecast(ctx context.Context, destination string, startDate, endDate time.Time) (WeatherForecast, error) {
+	var forecast WeatherForecast
+	for date, condition := range s {
+		condition.Date, _ = time.Parse("2006-01-02", date)
+		forecast.Forecast = append(forecast.Forecast, condition)
+	}
+	return forecast, nil
+}
+
+func TestAdverseWeather(t *testing.T) {
+	tests := []struct {
+		condition   WeatherCondition
+		wantReason  string
+		wantAdverse bool
+	}{
+		{WeatherCondition{Description: "Light Drizzle", Temperature: 24}, "light drizzle", true},
+		{WeatherCondition{Description: "clear sky", Temperature: 41}, "extreme heat (41°C)", true},
+		{WeatherCondition{Description: "clear sky", Temperature: -8}, "extreme cold (-8°C)", true},
+		{WeatherCondition{Description: "few clouds", Temperature: 20, WindSpeed: 60}, "strong winds (60 km/h)", true},
+		{WeatherCondition{Description: "few clouds", Temperature: 20, WindSpeed: 20}, "", false},
+	}
+	for _, tt := range tests {
+		if reason, adverse := adverseWeather(tt.condition); reason != tt.wantReason || adverse != tt.wantAdverse {
+			t.Errorf("adverseWeather(%+v) = %q, %v; want %q, %v", tt.condition, reason, adverse, tt.wantReason, tt.wantAdverse)
+		}
+	}
+}
+
+func TestAdaptDayForWeather(t *testing.T) {
+	trip := &TripData{
+		ID:          "t1",
+		Destination: "Goa",
+		StartDate:   time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		Itinerary: map[string]interface{}{
+			"day_1": map[string]interface{}{
+				"morning":   map[string]interface{}{"name": "Beach walk", "type": "outdoor"},
+				"afternoon": []interface{}{map[string]interface{}{"name": "Fort", "type": "outdoor", "cost": 50}, map[string]interface{}{"name": "Cafe", "type": "indoor"}},
+			},
+			"day_2": map[string]interface{}{"morning": map[string]interface{}{"name": "Museum", "type": "indoor"}},
+			"day_3": map[string]interface{}{"morning": map[string]interface{}{"name": "Kayaking", "type": "outdoor"}},
+			"day_4": map[string]interface{}{"morning": "Spice farm"},
+		},
+	}
+	forecasts := stubForecasts{
+		"2026-07-01": {Description: "heavy rain"},
+		"2026-07-02": {Description: "thunderstorm"},
+		"2026-07-03": {Description: "clear sky", Temperature: 30},
+	}
+
+	tests := []struct {
+		name        string
+		forecasts   ForecastProvider
+		day         int
+		wantChanges string
+		wantAdverse bool
+		wantNote    string
+		wantErr     error
+	}{
+		{
+			name: "outdoor plans replaced", forecasts: forecasts, day: 1, wantAdverse: true,
+			wantChanges: "morning: Beach walk -> National Museum (+100); afternoon: Fort -> National Museum (+50)",
+		},
+		{name: "already indoors", forecasts: forecasts, day: 2, wantAdverse: true, wantNote: "already all indoors"},
+		{name: "fine weather", forecasts: forecasts, day: 3, wantNote: "doesn't call for changes"},
+		{name: "no forecast", forecasts: forecasts, day: 4, wantErr: ErrNoForecast},
+		{name: "no such day", forecasts: forecasts, day: 9, wantErr: ErrDayNotFound},
+		{name: "no forecast provider", day: 1, wantErr: ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &DynamicReplanningService{forecasts: tt.forecasts}
+			adaptation, err := d.AdaptDayForWeather(context.Background(), trip, tt.day)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AdaptDayForWeather error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			changes := make([]string, len(adaptation.Changes))
+			for i, change := range adaptation.Changes {
+				changes[i] = fmt.Sprintf("%s: %s -> %s (%+.0f)", change.TimeSlot, activityName(change.Original.(map[string]interface{})),
+					activityName(change.Replacement.(map[string]interface{})), change.CostDelta)
+			}
+			if got := strings.Join(changes, "; "); got != tt.wantChanges {
+				t.Errorf("changes = %s\nwant      %s", got, tt.wantChanges)
+			}
+			if adaptation.Adverse != tt.wantAdverse || adaptation.Changed != (tt.wantChanges != "") {
+				t.Errorf("adverse %v, changed %v; want %v, %v", adaptation.Adverse, adaptation.Changed, tt.wantAdverse, tt.wantChanges != "")
+			}
+			if !strings.Contains(strings.Join(adaptation.Notes, " "), tt.wantNote) {
+				t.Errorf("notes = %q, want one mentioning %q", adaptation.Notes, tt.wantNote)
+			}
+		})
+	}
+
+	// The proposal leaves the trip alone and keeps each slot's shape
+	adaptation, err := (&DynamicReplanningService{forecasts: forecasts}).AdaptDayForWeather(context.Background(), trip, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := adaptation.RevisedDay["morning"].(map[string]interface{}); !ok {
+		t.Errorf("revised morning = %T, want the single activity kept as an object", adaptation.RevisedDay["morning"])
+	}
+	original := trip.Itinerary["day_1"].(map[string]interface{})["morning"].(map[string]interface{})
+	if original["name"] != "Beach walk" {
+		t.Errorf("trip's own morning became %v", original["name"])
+	}
+}
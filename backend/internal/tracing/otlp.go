@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"auratravel-backend/internal/httpclient"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	otlpQueueSize     = 2048
+	otlpBatchSize     = 512
+	otlpFlushInterval = 5 * time.Second
+	otlpTimeout       = 10 * time.Second
+)
+
+// Setup installs a tracer provider that batches spans to an OTLP/HTTP
+// collector at endpoint, e.g. "http://otel-collector:4318", and returns a
+// func that flushes and stops it. Spans are dropped, not blocked on, when
+// the queue is full. With no endpoint tracing stays disabled.
+func Setup(endpoint, serviceName string) func(context.Context) error {
+	noop := func(context.Context) error { return nil }
+	if endpoint == "" {
+		return noop
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(strings.TrimRight(endpoint, "/")+"/v1/traces"),
+		otlptracehttp.WithHTTPClient(httpclient.New(httpclient.Options{Timeout: otlpTimeout})),
+		otlptracehttp.WithTimeout(otlpTimeout),
+	)
+	if err != nil {
+		log.Printf("Tracing disabled, failed to create OTLP exporter: %v", err)
+		return noop
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName)))
+	if err != nil {
+		log.Printf("Failed to build tracing resource, using the default: %v", err)
+		res = resource.Default()
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxQueueSize(otlpQueueSize),
+			sdktrace.WithMaxExportBatchSize(otlpBatchSize),
+			sdktrace.WithBatchTimeout(otlpFlushInterval),
+		),
+	)
+	Use(provider)
+	log.Printf("Tracing enabled, exporting to %s", endpoint)
+
+	return func(ctx context.Context) error {
+		enabled.Store(false)
+		return provider.Shutdown(ctx)
+	}
+}
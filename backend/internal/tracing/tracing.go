@@ -0,0 +1,139 @@
+// Package tracing records OpenTelemetry spans through the global tracer
+// provider, which Setup points at an OTLP/HTTP collector. Until then every
+// call is a no-op, so instrumented code doesn't need to check whether
+// tracing is on.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope spans are recorded under
+const tracerName = "auratravel-backend"
+
+// Span kinds
+const (
+	KindInternal = trace.SpanKindInternal
+	KindServer   = trace.SpanKindServer
+	KindClient   = trace.SpanKindClient
+)
+
+// Span status codes
+const (
+	StatusUnset = codes.Unset
+	StatusOK    = codes.Ok
+	StatusError = codes.Error
+)
+
+// enabled is set while Setup's tracer provider is installed
+var enabled atomic.Bool
+
+// Enabled reports whether spans are being exported
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// Use installs provider as the global tracer provider and turns tracing on,
+// as Setup does with its OTLP exporter. It returns a func that turns tracing
+// off and restores the previous provider, for tests that record spans in
+// memory.
+func Use(provider trace.TracerProvider) (restore func()) {
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	enabled.Store(true)
+	return func() {
+		enabled.Store(false)
+		otel.SetTracerProvider(previous)
+	}
+}
+
+// Attr builds a span attribute. Values must not carry credentials or
+// personal data; types without an attribute equivalent are recorded as
+// their string form.
+func Attr(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
+
+// Span is an in-progress operation. A nil *Span is valid and ignores every
+// call.
+type Span struct {
+	span trace.Span
+}
+
+// Start begins a span as a child of the span in ctx, or of a remote parent
+// extracted by ContextWithTraceParent, and returns a context carrying it
+func Start(ctx context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, *Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	return ctx, &Span{span: span}
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...attribute.KeyValue) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(attrs...)
+}
+
+// SetStatus sets the span status. The message is only kept for errors.
+func (s *Span) SetStatus(code codes.Code, message string) {
+	if s == nil {
+		return
+	}
+	s.span.SetStatus(code, message)
+}
+
+// EndWithError records err, if any, as the span status and ends the span.
+// Only the error's type is recorded since messages can echo request data.
+func (s *Span) EndWithError(err error) {
+	if s == nil {
+		return
+	}
+	if err != nil {
+		s.SetStatus(StatusError, fmt.Sprintf("%T", err))
+	} else {
+		s.SetStatus(StatusOK, "")
+	}
+	s.End()
+}
+
+// End finishes the span and hands it to the exporter
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
+
+// ContextWithTraceParent returns a context whose next span continues the
+// trace in a W3C traceparent header. Malformed headers are ignored.
+func ContextWithTraceParent(ctx context.Context, header string) context.Context {
+	carrier := propagation.HeaderCarrier(http.Header{})
+	carrier.Set("traceparent", header)
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpansContinueTraceParent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	defer provider.Shutdown(context.Background())
+
+	ctx := ContextWithTraceParent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, parent := Start(ctx, "GET /trips", KindServer, Attr("http.route", "/trips"))
+	_, child := Start(ctx, "gemini.generateContent", KindClient, Attr("attempt", 2))
+	child.EndWithError(errors.New("quota exceeded for user@example.com"))
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	gemini, request := spans[0], spans[1]
+	if got := request.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the traceparent's", got)
+	}
+	if request.Parent.SpanID().String() != "00f067aa0ba902b7" || gemini.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("parents = %s, %s; want the remote span, then the request span", request.Parent.SpanID(), gemini.Parent.SpanID())
+	}
+	if gemini.Status.Code != codes.Error || gemini.Status.Description != "*errors.errorString" {
+		t.Errorf("status = %+v, want an error naming only the error type", gemini.Status)
+	}
+}
+
+func TestContextWithTraceParentIgnoresMalformedHeaders(t *testing.T) {
+	for _, header := range []string{"", "00-zz-00f067aa0ba902b7-01", "00-00000000000000000000000000000000-00f067aa0ba902b7-01"} {
+		ctx := ContextWithTraceParent(context.Background(), header)
+		if ctx != context.Background() {
+			t.Errorf("ContextWithTraceParent(%q) added a parent", header)
+		}
+	}
+}
+
+func TestNilSpanIgnoresCalls(t *testing.T) {
+	var span *Span
+	span.SetAttributes(Attr("k", "v"))
+	span.EndWithError(errors.New("boom"))
+	span.End()
+}
@@ -1,20 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"auratravel-backend/internal/config"
+	"auratravel-backend/internal/logging"
+	"auratravel-backend/internal/middleware"
 	"auratravel-backend/internal/routes"
 	"auratravel-backend/internal/services"
+	"auratravel-backend/internal/tracing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
+// shutdownTimeout bounds how long in-flight requests and background work get
+// to finish after a shutdown signal
+const shutdownTimeout = 30 * time.Second
+
 // @title AuraTravel AI Backend API
 // @version 1.0
 // @description AI-powered travel planning platform backend
@@ -36,13 +48,13 @@ import (
 // @description Type "Bearer" followed by a space and JWT token.
 
 func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
-	}
-
-	// Initialize configuration
+	// Initialize configuration; the config package has loaded .env
 	cfg := config.GetConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	logging.Setup(cfg.Environment)
+	shutdownTracing := tracing.Setup(cfg.OTLPEndpoint, cfg.OTelServiceName)
 
 	// Initialize services (Firebase, AI, etc.)
 	services, err := services.NewServices()
@@ -56,6 +68,8 @@ func main() {
 	}
 
 	router := gin.Default()
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.TracingMiddleware())
 
 	// Configure CORS
 	corsConfig := cors.DefaultConfig()
@@ -83,15 +97,6 @@ func main() {
 
 	router.Use(cors.New(corsConfig))
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "healthy",
-			"service": "auratravel-backend",
-			"version": "1.0.0",
-		})
-	})
-
 	// Setup routes
 	routes.SetupRoutes(router, services)
 
@@ -104,10 +109,47 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Starting AuraTravel AI Backend on port %s", port)
-	log.Printf("Swagger documentation available at: http://localhost:%s/docs/index.html", port)
+	// Cancelled on SIGINT/SIGTERM to start the shutdown sequence
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+
+	go func() {
+		log.Printf("Starting AuraTravel AI Backend on port %s", port)
+		log.Printf("Swagger documentation available at: http://localhost:%s/docs/index.html", port)
 
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutdown signal received, draining in-flight requests")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown did not complete: %v", err)
+	} else {
+		log.Println("HTTP server stopped")
+	}
+
+	if services != nil {
+		log.Println("Stopping trip monitors and shutting down services")
+		if err := services.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Service shutdown finished with errors: %v", err)
+		}
+	}
+
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("Failed to flush traces: %v", err)
 	}
+
+	log.Println("AuraTravel AI Backend stopped")
 }
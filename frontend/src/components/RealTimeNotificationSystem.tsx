@@ -168,7 +168,8 @@ export default function RealTimeNotificationSystem({
                     userId,
                     deviceToken: token,
                     platform: 'web',
-                    locale
+                    locale,
+                    timezone: Intl.DateTimeFormat().resolvedOptions().timeZone
                 })
             })
 